@@ -20,19 +20,144 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"kontroler-controller/pkg/conditions"
+	"kontroler-controller/pkg/templating"
 
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type DagParameterSpec struct {
 	Name string `json:"name"`
 	// +optional
 	DefaultValue string `json:"defaultValue,omitempty"`
+	// Secret the default is read from, either <secret> for its "secret" key or <secret>/<key>
 	// +optional
 	DefaultFromSecret string `json:"defaultFromSecret,omitempty"`
+	// +optional
+	DefaultSecretKeyRef *KeyRef `json:"defaultSecretKeyRef,omitempty"`
+	// +optional
+	DefaultConfigMapKeyRef *KeyRef `json:"defaultConfigMapKeyRef,omitempty"`
+	// +optional
+	ParameterSchema `json:",inline"`
+}
+
+// DefaultSecretKey is the key read from a secret when a parameter does not name one
+const DefaultSecretKey = "secret"
+
+// KeyRef selects a key of a Secret or ConfigMap in the namespace of the run
+type KeyRef struct {
+	Name string `json:"name"`
+	// Defaults to "secret" for secrets, ConfigMaps have to name the key
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ParseSecretRef reads a fromSecret value, either <secret> or <secret>/<key>
+func ParseSecretRef(ref string) KeyRef {
+	name, key, ok := strings.Cut(ref, "/")
+	if !ok {
+		return KeyRef{Name: ref, Key: DefaultSecretKey}
+	}
+	return KeyRef{Name: name, Key: key}
+}
+
+// validateKeyRef checks a reference has a name and, for a ConfigMap, a key
+func validateKeyRef(ref *KeyRef, configMap bool) error {
+	if ref.Name == "" {
+		return errors.New("reference has an empty name")
+	}
+	if configMap && ref.Key == "" {
+		return fmt.Errorf("configMap %s reference has an empty key", ref.Name)
+	}
+	if strings.Contains(ref.Name, "/") {
+		return fmt.Errorf("reference name %s cannot contain /", ref.Name)
+	}
+	return nil
+}
+
+// secretRef returns the secret key selected by either form, nil when there is none
+func secretRef(fromSecret string, keyRef *KeyRef) *KeyRef {
+	if keyRef != nil {
+		ref := *keyRef
+		if ref.Key == "" {
+			ref.Key = DefaultSecretKey
+		}
+		return &ref
+	}
+	if fromSecret != "" {
+		ref := ParseSecretRef(fromSecret)
+		return &ref
+	}
+	return nil
+}
+
+// DefaultRef returns where the default of the parameter is read from, a nil ref means a literal default
+func (p DagParameterSpec) DefaultRef() (ref *KeyRef, configMap bool) {
+	if p.DefaultConfigMapKeyRef != nil {
+		return p.DefaultConfigMapKeyRef, true
+	}
+	return secretRef(p.DefaultFromSecret, p.DefaultSecretKeyRef), false
+}
+
+// IsReference reports whether the default of the parameter is only known once a task runs
+func (p DagParameterSpec) IsReference() bool {
+	ref, _ := p.DefaultRef()
+	return ref != nil
+}
+
+// defaultSources counts the ways the default of the parameter is given, at most one is allowed
+func (p DagParameterSpec) defaultSources() int {
+	count := 0
+	for _, set := range []bool{p.DefaultValue != "", p.DefaultFromSecret != "", p.DefaultSecretKeyRef != nil, p.DefaultConfigMapKeyRef != nil} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+// Parameter types, a parameter without a type is a string
+const (
+	ParameterTypeString = "string"
+	ParameterTypeInt    = "int"
+	ParameterTypeBool   = "bool"
+	ParameterTypeEnum   = "enum"
+	ParameterTypeJSON   = "json"
+)
+
+// ParameterSchema describes the values a parameter accepts, runs with other values are rejected
+type ParameterSchema struct {
+	// +kubebuilder:validation:Enum=string;int;bool;enum;json
+	// +optional
+	Type string `json:"type,omitempty"`
+	// A required parameter has no default, every run has to set it
+	// +optional
+	Required bool `json:"required,omitempty"`
+	// Values an enum parameter can take
+	// +optional
+	Enum []string `json:"enum,omitempty"`
+	// Regular expression a string parameter has to match
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+	// Smallest value of an int parameter
+	// +optional
+	Min *int64 `json:"min,omitempty"`
+	// Largest value of an int parameter
+	// +optional
+	Max *int64 `json:"max,omitempty"`
+	// +optional
+	Description string `json:"description,omitempty"`
 }
 
 // PodTemplateSpec defines the template for the pod of a task
@@ -47,6 +172,9 @@ type PodTemplateSpec struct {
 	ImagePullSecrets []LocalObjectReference `json:"imagePullSecrets,omitempty"`
 	// +optional
 	SecurityContext *PodSecurityContext `json:"securityContext,omitempty"`
+	// Security context of the task container, its fields take precedence over the pod securityContext
+	// +optional
+	ContainerSecurityContext *SecurityContext `json:"containerSecurityContext,omitempty"`
 	// +optional
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 	// +optional
@@ -61,8 +189,46 @@ type PodTemplateSpec struct {
 	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
 	// +optional
 	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+	// PriorityClass of the task pod, deciding its scheduling and preemption order in the cluster
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// +optional
+	Resources *ResourceRequirements `json:"resources,omitempty"`
+	// Environment variables of the task container, ones with the same name as a parameter are ignored
+	// +optional
+	Env []EnvVar `json:"env,omitempty"`
+	// ConfigMaps and Secrets whose keys become environment variables of the task container
+	// +optional
+	EnvFrom []EnvFromSource `json:"envFrom,omitempty"`
+	// Containers that run to completion, in order, before the task container starts
+	// +optional
+	InitContainers []Container `json:"initContainers,omitempty"`
+	// Containers that run alongside the task container, e.g. a database proxy or a log shipper.
+	// They start after the initContainers and are stopped once the task container finishes,
+	// only the task container decides whether the task succeeded
+	// +optional
+	Sidecars []Container `json:"sidecars,omitempty"`
+}
+
+// Container is an extra container in the pod of a task
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// +optional
+	Env []EnvVar `json:"env,omitempty"`
+	// +optional
+	EnvFrom []EnvFromSource `json:"envFrom,omitempty"`
+	// Mounts of the volumes declared in the pod template
+	// +optional
+	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
 	// +optional
 	Resources *ResourceRequirements `json:"resources,omitempty"`
+	// +optional
+	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
 }
 
 // Local CRD-safe types mirroring the fields we need from core/v1
@@ -74,15 +240,132 @@ type PersistentVolumeClaimVolumeSource struct {
 
 type Volume struct {
 	Name string `json:"name"`
-	// Only support EmptyDir and PersistentVolumeClaim for now
+	// Only one source should be set
 	EmptyDir              *EmptyDirVolumeSource              `json:"emptyDir,omitempty"`
 	PersistentVolumeClaim *PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	// +optional
+	ConfigMap *ConfigMapVolumeSource `json:"configMap,omitempty"`
+	// +optional
+	Secret *SecretVolumeSource `json:"secret,omitempty"`
+	// +optional
+	Projected *ProjectedVolumeSource `json:"projected,omitempty"`
+	// +optional
+	CSI *CSIVolumeSource `json:"csi,omitempty"`
+}
+
+// KeyToPath projects a key of a ConfigMap or Secret to a file
+type KeyToPath struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+	// +optional
+	Mode *int32 `json:"mode,omitempty"`
+}
+
+type ConfigMapVolumeSource struct {
+	Name string `json:"name"`
+	// Keys to project, all keys are projected when empty
+	// +optional
+	Items []KeyToPath `json:"items,omitempty"`
+	// +optional
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+type SecretVolumeSource struct {
+	SecretName string `json:"secretName"`
+	// Keys to project, all keys are projected when empty
+	// +optional
+	Items []KeyToPath `json:"items,omitempty"`
+	// +optional
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+type ProjectedVolumeSource struct {
+	Sources []VolumeProjection `json:"sources"`
+	// +optional
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
+}
+
+// VolumeProjection is one source of a projected volume, only one of its fields should be set
+type VolumeProjection struct {
+	// +optional
+	ConfigMap *ConfigMapProjection `json:"configMap,omitempty"`
+	// +optional
+	Secret *SecretProjection `json:"secret,omitempty"`
+	// +optional
+	ServiceAccountToken *ServiceAccountTokenProjection `json:"serviceAccountToken,omitempty"`
+}
+
+type ConfigMapProjection struct {
+	Name string `json:"name"`
+	// +optional
+	Items []KeyToPath `json:"items,omitempty"`
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+type SecretProjection struct {
+	Name string `json:"name"`
+	// +optional
+	Items []KeyToPath `json:"items,omitempty"`
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+type ServiceAccountTokenProjection struct {
+	Path string `json:"path"`
+	// +optional
+	Audience string `json:"audience,omitempty"`
+	// +optional
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+type CSIVolumeSource struct {
+	Driver string `json:"driver"`
+	// +optional
+	ReadOnly *bool `json:"readOnly,omitempty"`
+	// +optional
+	FSType *string `json:"fsType,omitempty"`
+	// +optional
+	VolumeAttributes map[string]string `json:"volumeAttributes,omitempty"`
+	// +optional
+	NodePublishSecretRef *LocalObjectReference `json:"nodePublishSecretRef,omitempty"`
 }
 
 type VolumeMount struct {
 	Name      string `json:"name"`
 	MountPath string `json:"mountPath"`
 	ReadOnly  bool   `json:"readOnly,omitempty"`
+	// Path within the volume to mount instead of its root
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+}
+
+// EnvVar is an environment variable with a literal value
+type EnvVar struct {
+	Name string `json:"name"`
+	// +optional
+	Value string `json:"value,omitempty"`
+}
+
+// EnvFromSource is a ConfigMap or Secret whose keys become environment variables, only one of them should be set
+type EnvFromSource struct {
+	// Prepended to the name of every variable
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// +optional
+	ConfigMapRef *EnvFromReference `json:"configMapRef,omitempty"`
+	// +optional
+	SecretRef *EnvFromReference `json:"secretRef,omitempty"`
+}
+
+type EnvFromReference struct {
+	Name string `json:"name"`
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
 }
 
 type LocalObjectReference struct {
@@ -90,8 +373,52 @@ type LocalObjectReference struct {
 }
 
 type PodSecurityContext struct {
-	// minimal mapping — expand if needed
+	// +optional
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+	// +optional
+	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
+	// +optional
+	RunAsNonRoot *bool `json:"runAsNonRoot,omitempty"`
+	// +optional
 	FSGroup *int64 `json:"fsGroup,omitempty"`
+	// +optional
+	SupplementalGroups []int64 `json:"supplementalGroups,omitempty"`
+	// +optional
+	SeccompProfile *SeccompProfile `json:"seccompProfile,omitempty"`
+}
+
+// SecurityContext is the security context of a container
+type SecurityContext struct {
+	// +optional
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+	// +optional
+	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
+	// +optional
+	RunAsNonRoot *bool `json:"runAsNonRoot,omitempty"`
+	// +optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+	// +optional
+	AllowPrivilegeEscalation *bool `json:"allowPrivilegeEscalation,omitempty"`
+	// +optional
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// +optional
+	SeccompProfile *SeccompProfile `json:"seccompProfile,omitempty"`
+}
+
+// Capabilities are the POSIX capabilities added to and dropped from a container
+type Capabilities struct {
+	// +optional
+	Add []string `json:"add,omitempty"`
+	// +optional
+	Drop []string `json:"drop,omitempty"`
+}
+
+type SeccompProfile struct {
+	// +kubebuilder:validation:Enum=RuntimeDefault;Localhost;Unconfined
+	Type string `json:"type"`
+	// Profile on the node to use, only set when type is Localhost
+	// +optional
+	LocalhostProfile *string `json:"localhostProfile,omitempty"`
 }
 
 type Toleration struct {
@@ -140,41 +467,43 @@ func (p PodTemplateSpec) Serialize() (string, error) {
 	return string(jsonData), nil
 }
 
+// K8sPodParts is a PodTemplateSpec converted into k8s core types, ready to be applied to a pod spec
+// +kubebuilder:object:generate=false
+type K8sPodParts struct {
+	Volumes          []corev1.Volume
+	VolumeMounts     []corev1.VolumeMount
+	ImagePullSecrets []corev1.LocalObjectReference
+	SecurityContext  *corev1.PodSecurityContext
+	Tolerations      []corev1.Toleration
+	Affinity         *corev1.Affinity
+	Resources        *corev1.ResourceRequirements
+	Env              []corev1.EnvVar
+	EnvFrom          []corev1.EnvFromSource
+}
+
 // Conversion helper: convert this CRD-safe PodTemplateSpec into k8s core types
-func (p *PodTemplateSpec) ToK8sParts() (volumes []corev1.Volume, volumeMounts []corev1.VolumeMount, imagePullSecrets []corev1.LocalObjectReference, securityContext *corev1.PodSecurityContext, tolerations []corev1.Toleration, affinity *corev1.Affinity, resources *corev1.ResourceRequirements) {
-	// volumes
-	for _, v := range p.Volumes {
-		var vol corev1.Volume
-		vol.Name = v.Name
-		if v.EmptyDir != nil {
-			vol.VolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
-		} else if v.PersistentVolumeClaim != nil {
-			vol.VolumeSource = corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim.ClaimName}}
-		}
-		volumes = append(volumes, vol)
+func (p *PodTemplateSpec) ToK8sParts() K8sPodParts {
+	parts := K8sPodParts{
+		VolumeMounts:    volumeMountsToK8s(p.VolumeMounts),
+		Env:             envToK8s(p.Env),
+		EnvFrom:         envFromToK8s(p.EnvFrom),
+		SecurityContext: p.SecurityContext.ToK8s(),
+		Resources:       p.Resources.toK8s(),
 	}
 
-	// volumeMounts
-	for _, vm := range p.VolumeMounts {
-		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: vm.Name, MountPath: vm.MountPath, ReadOnly: vm.ReadOnly})
+	// volumes
+	for _, v := range p.Volumes {
+		parts.Volumes = append(parts.Volumes, corev1.Volume{Name: v.Name, VolumeSource: v.toK8sSource()})
 	}
 
 	// imagePullSecrets
 	for _, s := range p.ImagePullSecrets {
-		imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{Name: s.Name})
-	}
-
-	// securityContext (limited)
-	if p.SecurityContext != nil {
-		securityContext = &corev1.PodSecurityContext{}
-		if p.SecurityContext.FSGroup != nil {
-			securityContext.FSGroup = p.SecurityContext.FSGroup
-		}
+		parts.ImagePullSecrets = append(parts.ImagePullSecrets, corev1.LocalObjectReference{Name: s.Name})
 	}
 
 	// tolerations
 	for _, t := range p.Tolerations {
-		tolerations = append(tolerations, corev1.Toleration{Key: t.Key, Operator: corev1.TolerationOperator(t.Operator), Value: t.Value, Effect: corev1.TaintEffect(t.Effect), TolerationSeconds: t.TolerationSeconds})
+		parts.Tolerations = append(parts.Tolerations, corev1.Toleration{Key: t.Key, Operator: corev1.TolerationOperator(t.Operator), Value: t.Value, Effect: corev1.TaintEffect(t.Effect), TolerationSeconds: t.TolerationSeconds})
 	}
 
 	// affinity: best-effort (not converting complex structures)
@@ -183,148 +512,558 @@ func (p *PodTemplateSpec) ToK8sParts() (volumes []corev1.Volume, volumeMounts []
 		b, _ := json.Marshal(p.Affinity)
 		var a corev1.Affinity
 		_ = json.Unmarshal(b, &a)
-		affinity = &a
+		parts.Affinity = &a
 	}
 
-	// resources
-	if p.Resources != nil {
-		r := corev1.ResourceList{}
-		l := corev1.ResourceList{}
-		for k, v := range p.Resources.Limits {
-			q, err := resource.ParseQuantity(v)
-			if err == nil {
-				l[corev1.ResourceName(k)] = q
-			}
-		}
-		for k, v := range p.Resources.Requests {
-			q, err := resource.ParseQuantity(v)
-			if err == nil {
-				r[corev1.ResourceName(k)] = q
-			}
-		}
-		resources = &corev1.ResourceRequirements{Limits: l, Requests: r}
+	return parts
+}
+
+// InitContainersToK8s converts the initContainers and sidecars of the template into the init containers
+// of a pod. Sidecars come last and are native sidecars, init containers with a restartPolicy of Always.
+func (p *PodTemplateSpec) InitContainersToK8s() []corev1.Container {
+	var containers []corev1.Container
+	for _, c := range p.InitContainers {
+		containers = append(containers, c.toK8s())
+	}
+
+	always := corev1.ContainerRestartPolicyAlways
+	for _, c := range p.Sidecars {
+		sidecar := c.toK8s()
+		sidecar.RestartPolicy = &always
+		containers = append(containers, sidecar)
 	}
 
-	return
+	return containers
 }
 
-func (p PVC) ToK8sPersistentVolumeClaimSpec() corev1.PersistentVolumeClaimSpec {
-	accessModes := make([]corev1.PersistentVolumeAccessMode, 0, len(p.AccessModes))
-	for _, mode := range p.AccessModes {
-		accessModes = append(accessModes, corev1.PersistentVolumeAccessMode(mode))
+// Validate ensures the extra containers can be added to the pod of the task container named taskName
+func (p *PodTemplateSpec) Validate(taskName string) error {
+	if p == nil {
+		return nil
 	}
 
-	var volumeMode *corev1.PersistentVolumeMode
-	if p.VolumeMode != nil {
-		vm := corev1.PersistentVolumeMode(*p.VolumeMode)
-		volumeMode = &vm
+	names := map[string]bool{
+		taskName:                             true,
+		ReservedScriptContainerName:          true,
+		ReservedArtifactsContainerName:       true,
+		ReservedArtifactsUploadContainerName: true,
 	}
-
-	resources := corev1.VolumeResourceRequirements{}
-	if p.Resources != nil {
-		if len(p.Resources.Limits) > 0 {
-			resources.Limits = corev1.ResourceList{}
-			for k, v := range p.Resources.Limits {
-				q, err := resource.ParseQuantity(v)
-				if err == nil {
-					resources.Limits[corev1.ResourceName(k)] = q
-				}
-			}
+	for _, c := range slices.Concat(p.InitContainers, p.Sidecars) {
+		if c.Name == "" {
+			return errors.New("container name must be specified")
 		}
-		if len(p.Resources.Requests) > 0 {
-			resources.Requests = corev1.ResourceList{}
-			for k, v := range p.Resources.Requests {
-				q, err := resource.ParseQuantity(v)
-				if err == nil {
-					resources.Requests[corev1.ResourceName(k)] = q
-				}
-			}
+		if c.Image == "" {
+			return fmt.Errorf("container %s image must be specified", c.Name)
 		}
+		if names[c.Name] {
+			return fmt.Errorf("container name %s is already used", c.Name)
+		}
+		names[c.Name] = true
 	}
 
-	return corev1.PersistentVolumeClaimSpec{
-		AccessModes:      accessModes,
-		Resources:        resources,
-		Selector:         p.Selector,
-		StorageClassName: p.StorageClassName,
-		VolumeMode:       volumeMode,
+	return nil
+}
+
+// ReservedScriptContainerName is the init container that copies the script of a task into its pod
+const ReservedScriptContainerName = "script-copier"
+
+// ReservedArtifactsContainerName is the init container that downloads the artifacts of upstream tasks into the pod of a task
+const ReservedArtifactsContainerName = "kontroler-artifacts"
+
+// ReservedArtifactsUploadContainerName is the sidecar that uploads the artifacts a task publishes
+const ReservedArtifactsUploadContainerName = "kontroler-artifacts-upload"
+
+func (c Container) toK8s() corev1.Container {
+	container := corev1.Container{
+		Name:            c.Name,
+		Image:           c.Image,
+		Command:         c.Command,
+		Args:            c.Args,
+		Env:             envToK8s(c.Env),
+		EnvFrom:         envFromToK8s(c.EnvFrom),
+		VolumeMounts:    volumeMountsToK8s(c.VolumeMounts),
+		SecurityContext: c.SecurityContext.ToK8s(),
+	}
+
+	if resources := c.Resources.toK8s(); resources != nil {
+		container.Resources = *resources
 	}
+
+	return container
 }
 
-func PodTemplateSpecFromK8s(podSpec *corev1.PodSpec, container *corev1.Container) *PodTemplateSpec {
-	if podSpec == nil || container == nil {
-		return &PodTemplateSpec{}
+func containerFromK8s(c corev1.Container) Container {
+	return Container{
+		Name:            c.Name,
+		Image:           c.Image,
+		Command:         c.Command,
+		Args:            c.Args,
+		Env:             envFromK8s(c.Env),
+		EnvFrom:         envFromSourcesFromK8s(c.EnvFrom),
+		VolumeMounts:    volumeMountsFromK8s(c.VolumeMounts),
+		Resources:       resourcesFromK8s(c.Resources),
+		SecurityContext: securityContextFromK8s(c.SecurityContext),
 	}
+}
 
-	pt := &PodTemplateSpec{
-		NodeSelector:                 podSpec.NodeSelector,
-		ServiceAccountName:           podSpec.ServiceAccountName,
-		AutomountServiceAccountToken: podSpec.AutomountServiceAccountToken,
-		ActiveDeadlineSeconds:        podSpec.ActiveDeadlineSeconds,
+func (r *ResourceRequirements) toK8s() *corev1.ResourceRequirements {
+	if r == nil {
+		return nil
 	}
 
-	for _, v := range podSpec.Volumes {
-		lv := Volume{Name: v.Name}
-		if v.EmptyDir != nil {
-			lv.EmptyDir = &EmptyDirVolumeSource{}
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	for k, v := range r.Limits {
+		q, err := resource.ParseQuantity(v)
+		if err == nil {
+			limits[corev1.ResourceName(k)] = q
 		}
-		if v.PersistentVolumeClaim != nil {
-			lv.PersistentVolumeClaim = &PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim.ClaimName}
+	}
+	for k, v := range r.Requests {
+		q, err := resource.ParseQuantity(v)
+		if err == nil {
+			requests[corev1.ResourceName(k)] = q
 		}
-		pt.Volumes = append(pt.Volumes, lv)
 	}
 
-	for _, vm := range container.VolumeMounts {
-		pt.VolumeMounts = append(pt.VolumeMounts, VolumeMount{Name: vm.Name, MountPath: vm.MountPath, ReadOnly: vm.ReadOnly})
+	return &corev1.ResourceRequirements{Limits: limits, Requests: requests}
+}
+
+func resourcesFromK8s(resources corev1.ResourceRequirements) *ResourceRequirements {
+	if len(resources.Limits) == 0 && len(resources.Requests) == 0 {
+		return nil
 	}
 
-	for _, s := range podSpec.ImagePullSecrets {
-		pt.ImagePullSecrets = append(pt.ImagePullSecrets, LocalObjectReference{Name: s.Name})
+	r := &ResourceRequirements{Limits: map[string]string{}, Requests: map[string]string{}}
+	for k, v := range resources.Limits {
+		r.Limits[string(k)] = v.String()
+	}
+	for k, v := range resources.Requests {
+		r.Requests[string(k)] = v.String()
+	}
+
+	return r
+}
+
+func volumeMountsToK8s(mounts []VolumeMount) []corev1.VolumeMount {
+	var volumeMounts []corev1.VolumeMount
+	for _, vm := range mounts {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: vm.Name, MountPath: vm.MountPath, ReadOnly: vm.ReadOnly, SubPath: vm.SubPath})
 	}
+	return volumeMounts
+}
 
-	if podSpec.SecurityContext != nil {
-		pt.SecurityContext = &PodSecurityContext{FSGroup: podSpec.SecurityContext.FSGroup}
+func volumeMountsFromK8s(mounts []corev1.VolumeMount) []VolumeMount {
+	var volumeMounts []VolumeMount
+	for _, vm := range mounts {
+		volumeMounts = append(volumeMounts, VolumeMount{Name: vm.Name, MountPath: vm.MountPath, ReadOnly: vm.ReadOnly, SubPath: vm.SubPath})
 	}
+	return volumeMounts
+}
 
-	for _, t := range podSpec.Tolerations {
-		pt.Tolerations = append(pt.Tolerations, Toleration{
-			Key:               t.Key,
-			Operator:          string(t.Operator),
-			Value:             t.Value,
-			Effect:            string(t.Effect),
-			TolerationSeconds: t.TolerationSeconds,
-		})
+func envToK8s(vars []EnvVar) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	for _, e := range vars {
+		env = append(env, corev1.EnvVar{Name: e.Name, Value: e.Value})
 	}
+	return env
+}
 
-	if podSpec.Affinity != nil {
-		b, _ := json.Marshal(podSpec.Affinity)
-		var a Affinity
-		_ = json.Unmarshal(b, &a)
-		pt.Affinity = &a
+// envFromK8s keeps the literal variables, secret parameters use valueFrom and are rebuilt from the parameters instead
+func envFromK8s(vars []corev1.EnvVar) []EnvVar {
+	var env []EnvVar
+	for _, e := range vars {
+		if e.ValueFrom == nil {
+			env = append(env, EnvVar{Name: e.Name, Value: e.Value})
+		}
 	}
+	return env
+}
 
-	if len(container.Resources.Limits) > 0 || len(container.Resources.Requests) > 0 {
-		r := &ResourceRequirements{Limits: map[string]string{}, Requests: map[string]string{}}
-		for k, v := range container.Resources.Limits {
-			r.Limits[string(k)] = v.String()
+func envFromToK8s(sources []EnvFromSource) []corev1.EnvFromSource {
+	var envFrom []corev1.EnvFromSource
+	for _, e := range sources {
+		source := corev1.EnvFromSource{Prefix: e.Prefix}
+		if e.ConfigMapRef != nil {
+			source.ConfigMapRef = &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: e.ConfigMapRef.Name}, Optional: e.ConfigMapRef.Optional}
 		}
-		for k, v := range container.Resources.Requests {
-			r.Requests[string(k)] = v.String()
+		if e.SecretRef != nil {
+			source.SecretRef = &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: e.SecretRef.Name}, Optional: e.SecretRef.Optional}
 		}
-		pt.Resources = r
+		envFrom = append(envFrom, source)
 	}
+	return envFrom
+}
 
-	return pt
+func envFromSourcesFromK8s(sources []corev1.EnvFromSource) []EnvFromSource {
+	var envFrom []EnvFromSource
+	for _, e := range sources {
+		source := EnvFromSource{Prefix: e.Prefix}
+		if e.ConfigMapRef != nil {
+			source.ConfigMapRef = &EnvFromReference{Name: e.ConfigMapRef.Name, Optional: e.ConfigMapRef.Optional}
+		}
+		if e.SecretRef != nil {
+			source.SecretRef = &EnvFromReference{Name: e.SecretRef.Name, Optional: e.SecretRef.Optional}
+		}
+		envFrom = append(envFrom, source)
+	}
+	return envFrom
 }
 
-// TaskSpec defines the structure of a task in the DAG
-type TaskSpec struct {
-	Name string `json:"name"`
-	// +optional
-	Command []string `json:"command,omitempty"`
-	// +optional
-	Args []string `json:"args,omitempty"`
-	// +optional
+func (v Volume) toK8sSource() corev1.VolumeSource {
+	switch {
+	case v.EmptyDir != nil:
+		return corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	case v.PersistentVolumeClaim != nil:
+		return corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim.ClaimName}}
+	case v.ConfigMap != nil:
+		return corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: v.ConfigMap.Name},
+			Items:                keysToK8s(v.ConfigMap.Items),
+			DefaultMode:          v.ConfigMap.DefaultMode,
+			Optional:             v.ConfigMap.Optional,
+		}}
+	case v.Secret != nil:
+		return corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName:  v.Secret.SecretName,
+			Items:       keysToK8s(v.Secret.Items),
+			DefaultMode: v.Secret.DefaultMode,
+			Optional:    v.Secret.Optional,
+		}}
+	case v.Projected != nil:
+		projected := &corev1.ProjectedVolumeSource{DefaultMode: v.Projected.DefaultMode}
+		for _, source := range v.Projected.Sources {
+			var projection corev1.VolumeProjection
+			if source.ConfigMap != nil {
+				projection.ConfigMap = &corev1.ConfigMapProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: source.ConfigMap.Name},
+					Items:                keysToK8s(source.ConfigMap.Items),
+					Optional:             source.ConfigMap.Optional,
+				}
+			}
+			if source.Secret != nil {
+				projection.Secret = &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: source.Secret.Name},
+					Items:                keysToK8s(source.Secret.Items),
+					Optional:             source.Secret.Optional,
+				}
+			}
+			if source.ServiceAccountToken != nil {
+				projection.ServiceAccountToken = &corev1.ServiceAccountTokenProjection{
+					Audience:          source.ServiceAccountToken.Audience,
+					ExpirationSeconds: source.ServiceAccountToken.ExpirationSeconds,
+					Path:              source.ServiceAccountToken.Path,
+				}
+			}
+			projected.Sources = append(projected.Sources, projection)
+		}
+		return corev1.VolumeSource{Projected: projected}
+	case v.CSI != nil:
+		csi := &corev1.CSIVolumeSource{
+			Driver:           v.CSI.Driver,
+			ReadOnly:         v.CSI.ReadOnly,
+			FSType:           v.CSI.FSType,
+			VolumeAttributes: v.CSI.VolumeAttributes,
+		}
+		if v.CSI.NodePublishSecretRef != nil {
+			csi.NodePublishSecretRef = &corev1.LocalObjectReference{Name: v.CSI.NodePublishSecretRef.Name}
+		}
+		return corev1.VolumeSource{CSI: csi}
+	}
+
+	return corev1.VolumeSource{}
+}
+
+func volumeFromK8s(v corev1.Volume) Volume {
+	lv := Volume{Name: v.Name}
+	if v.EmptyDir != nil {
+		lv.EmptyDir = &EmptyDirVolumeSource{}
+	}
+	if v.PersistentVolumeClaim != nil {
+		lv.PersistentVolumeClaim = &PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim.ClaimName}
+	}
+	if v.ConfigMap != nil {
+		lv.ConfigMap = &ConfigMapVolumeSource{
+			Name:        v.ConfigMap.Name,
+			Items:       keysFromK8s(v.ConfigMap.Items),
+			DefaultMode: v.ConfigMap.DefaultMode,
+			Optional:    v.ConfigMap.Optional,
+		}
+	}
+	if v.Secret != nil {
+		lv.Secret = &SecretVolumeSource{
+			SecretName:  v.Secret.SecretName,
+			Items:       keysFromK8s(v.Secret.Items),
+			DefaultMode: v.Secret.DefaultMode,
+			Optional:    v.Secret.Optional,
+		}
+	}
+	if v.Projected != nil {
+		lv.Projected = &ProjectedVolumeSource{DefaultMode: v.Projected.DefaultMode}
+		for _, source := range v.Projected.Sources {
+			var projection VolumeProjection
+			if source.ConfigMap != nil {
+				projection.ConfigMap = &ConfigMapProjection{Name: source.ConfigMap.Name, Items: keysFromK8s(source.ConfigMap.Items), Optional: source.ConfigMap.Optional}
+			}
+			if source.Secret != nil {
+				projection.Secret = &SecretProjection{Name: source.Secret.Name, Items: keysFromK8s(source.Secret.Items), Optional: source.Secret.Optional}
+			}
+			if source.ServiceAccountToken != nil {
+				projection.ServiceAccountToken = &ServiceAccountTokenProjection{
+					Audience:          source.ServiceAccountToken.Audience,
+					ExpirationSeconds: source.ServiceAccountToken.ExpirationSeconds,
+					Path:              source.ServiceAccountToken.Path,
+				}
+			}
+			lv.Projected.Sources = append(lv.Projected.Sources, projection)
+		}
+	}
+	if v.CSI != nil {
+		lv.CSI = &CSIVolumeSource{
+			Driver:           v.CSI.Driver,
+			ReadOnly:         v.CSI.ReadOnly,
+			FSType:           v.CSI.FSType,
+			VolumeAttributes: v.CSI.VolumeAttributes,
+		}
+		if v.CSI.NodePublishSecretRef != nil {
+			lv.CSI.NodePublishSecretRef = &LocalObjectReference{Name: v.CSI.NodePublishSecretRef.Name}
+		}
+	}
+
+	return lv
+}
+
+func keysToK8s(items []KeyToPath) []corev1.KeyToPath {
+	var keys []corev1.KeyToPath
+	for _, item := range items {
+		keys = append(keys, corev1.KeyToPath{Key: item.Key, Path: item.Path, Mode: item.Mode})
+	}
+	return keys
+}
+
+func keysFromK8s(items []corev1.KeyToPath) []KeyToPath {
+	var keys []KeyToPath
+	for _, item := range items {
+		keys = append(keys, KeyToPath{Key: item.Key, Path: item.Path, Mode: item.Mode})
+	}
+	return keys
+}
+
+// ToK8s converts the pod security context to its core type, nil when it is not set
+func (s *PodSecurityContext) ToK8s() *corev1.PodSecurityContext {
+	if s == nil {
+		return nil
+	}
+
+	return &corev1.PodSecurityContext{
+		RunAsUser:          s.RunAsUser,
+		RunAsGroup:         s.RunAsGroup,
+		RunAsNonRoot:       s.RunAsNonRoot,
+		FSGroup:            s.FSGroup,
+		SupplementalGroups: s.SupplementalGroups,
+		SeccompProfile:     s.SeccompProfile.toK8s(),
+	}
+}
+
+// ToK8s converts the container security context to its core type, nil when it is not set
+func (s *SecurityContext) ToK8s() *corev1.SecurityContext {
+	if s == nil {
+		return nil
+	}
+
+	sc := &corev1.SecurityContext{
+		RunAsUser:                s.RunAsUser,
+		RunAsGroup:               s.RunAsGroup,
+		RunAsNonRoot:             s.RunAsNonRoot,
+		ReadOnlyRootFilesystem:   s.ReadOnlyRootFilesystem,
+		AllowPrivilegeEscalation: s.AllowPrivilegeEscalation,
+		SeccompProfile:           s.SeccompProfile.toK8s(),
+	}
+
+	if s.Capabilities != nil {
+		sc.Capabilities = &corev1.Capabilities{}
+		for _, c := range s.Capabilities.Add {
+			sc.Capabilities.Add = append(sc.Capabilities.Add, corev1.Capability(c))
+		}
+		for _, c := range s.Capabilities.Drop {
+			sc.Capabilities.Drop = append(sc.Capabilities.Drop, corev1.Capability(c))
+		}
+	}
+
+	return sc
+}
+
+func (s *SeccompProfile) toK8s() *corev1.SeccompProfile {
+	if s == nil {
+		return nil
+	}
+
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileType(s.Type), LocalhostProfile: s.LocalhostProfile}
+}
+
+func podSecurityContextFromK8s(sc *corev1.PodSecurityContext) *PodSecurityContext {
+	if sc == nil {
+		return nil
+	}
+
+	return &PodSecurityContext{
+		RunAsUser:          sc.RunAsUser,
+		RunAsGroup:         sc.RunAsGroup,
+		RunAsNonRoot:       sc.RunAsNonRoot,
+		FSGroup:            sc.FSGroup,
+		SupplementalGroups: sc.SupplementalGroups,
+		SeccompProfile:     seccompProfileFromK8s(sc.SeccompProfile),
+	}
+}
+
+func securityContextFromK8s(sc *corev1.SecurityContext) *SecurityContext {
+	if sc == nil {
+		return nil
+	}
+
+	s := &SecurityContext{
+		RunAsUser:                sc.RunAsUser,
+		RunAsGroup:               sc.RunAsGroup,
+		RunAsNonRoot:             sc.RunAsNonRoot,
+		ReadOnlyRootFilesystem:   sc.ReadOnlyRootFilesystem,
+		AllowPrivilegeEscalation: sc.AllowPrivilegeEscalation,
+		SeccompProfile:           seccompProfileFromK8s(sc.SeccompProfile),
+	}
+
+	if sc.Capabilities != nil {
+		s.Capabilities = &Capabilities{}
+		for _, c := range sc.Capabilities.Add {
+			s.Capabilities.Add = append(s.Capabilities.Add, string(c))
+		}
+		for _, c := range sc.Capabilities.Drop {
+			s.Capabilities.Drop = append(s.Capabilities.Drop, string(c))
+		}
+	}
+
+	return s
+}
+
+func seccompProfileFromK8s(p *corev1.SeccompProfile) *SeccompProfile {
+	if p == nil {
+		return nil
+	}
+
+	return &SeccompProfile{Type: string(p.Type), LocalhostProfile: p.LocalhostProfile}
+}
+
+func (p PVC) ToK8sPersistentVolumeClaimSpec() corev1.PersistentVolumeClaimSpec {
+	accessModes := make([]corev1.PersistentVolumeAccessMode, 0, len(p.AccessModes))
+	for _, mode := range p.AccessModes {
+		accessModes = append(accessModes, corev1.PersistentVolumeAccessMode(mode))
+	}
+
+	var volumeMode *corev1.PersistentVolumeMode
+	if p.VolumeMode != nil {
+		vm := corev1.PersistentVolumeMode(*p.VolumeMode)
+		volumeMode = &vm
+	}
+
+	resources := corev1.VolumeResourceRequirements{}
+	if p.Resources != nil {
+		if len(p.Resources.Limits) > 0 {
+			resources.Limits = corev1.ResourceList{}
+			for k, v := range p.Resources.Limits {
+				q, err := resource.ParseQuantity(v)
+				if err == nil {
+					resources.Limits[corev1.ResourceName(k)] = q
+				}
+			}
+		}
+		if len(p.Resources.Requests) > 0 {
+			resources.Requests = corev1.ResourceList{}
+			for k, v := range p.Resources.Requests {
+				q, err := resource.ParseQuantity(v)
+				if err == nil {
+					resources.Requests[corev1.ResourceName(k)] = q
+				}
+			}
+		}
+	}
+
+	return corev1.PersistentVolumeClaimSpec{
+		AccessModes:      accessModes,
+		Resources:        resources,
+		Selector:         p.Selector,
+		StorageClassName: p.StorageClassName,
+		VolumeMode:       volumeMode,
+	}
+}
+
+func PodTemplateSpecFromK8s(podSpec *corev1.PodSpec, container *corev1.Container) *PodTemplateSpec {
+	if podSpec == nil || container == nil {
+		return &PodTemplateSpec{}
+	}
+
+	pt := &PodTemplateSpec{
+		NodeSelector:                 podSpec.NodeSelector,
+		ServiceAccountName:           podSpec.ServiceAccountName,
+		PriorityClassName:            podSpec.PriorityClassName,
+		AutomountServiceAccountToken: podSpec.AutomountServiceAccountToken,
+		ActiveDeadlineSeconds:        podSpec.ActiveDeadlineSeconds,
+	}
+
+	for _, v := range podSpec.Volumes {
+		pt.Volumes = append(pt.Volumes, volumeFromK8s(v))
+	}
+
+	pt.VolumeMounts = volumeMountsFromK8s(container.VolumeMounts)
+	pt.Env = envFromK8s(container.Env)
+	pt.EnvFrom = envFromSourcesFromK8s(container.EnvFrom)
+
+	// the script copier and artifacts containers are added back when the pod is created from the task
+	for _, c := range podSpec.InitContainers {
+		if c.Name == ReservedScriptContainerName || c.Name == ReservedArtifactsContainerName || c.Name == ReservedArtifactsUploadContainerName {
+			continue
+		}
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			pt.Sidecars = append(pt.Sidecars, containerFromK8s(c))
+		} else {
+			pt.InitContainers = append(pt.InitContainers, containerFromK8s(c))
+		}
+	}
+
+	for _, s := range podSpec.ImagePullSecrets {
+		pt.ImagePullSecrets = append(pt.ImagePullSecrets, LocalObjectReference{Name: s.Name})
+	}
+
+	pt.SecurityContext = podSecurityContextFromK8s(podSpec.SecurityContext)
+	pt.ContainerSecurityContext = securityContextFromK8s(container.SecurityContext)
+
+	for _, t := range podSpec.Tolerations {
+		pt.Tolerations = append(pt.Tolerations, Toleration{
+			Key:               t.Key,
+			Operator:          string(t.Operator),
+			Value:             t.Value,
+			Effect:            string(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+
+	if podSpec.Affinity != nil {
+		b, _ := json.Marshal(podSpec.Affinity)
+		var a Affinity
+		_ = json.Unmarshal(b, &a)
+		pt.Affinity = &a
+	}
+
+	pt.Resources = resourcesFromK8s(container.Resources)
+
+	return pt
+}
+
+// TaskSpec defines the structure of a task in the DAG
+type TaskSpec struct {
+	Name string `json:"name"`
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// +optional
 	Image string `json:"image"`
 	// +optional
 	RunAfter []string `json:"runAfter,omitempty"`
@@ -344,283 +1083,1698 @@ type TaskSpec struct {
 	// Using reference to existing pre-created task - cannot reference another in-line task
 	// +optional
 	TaskRef *TaskRef `json:"taskRef,omitempty"`
+	// Names of the values this task publishes to downstream tasks, read from the
+	// termination message of the task container as KEY=value lines or a JSON object
+	// +optional
+	Outputs []string `json:"outputs,omitempty"`
+	// Expression deciding whether the task runs, e.g. params.env == "prod".
+	// It can read params.<name>, tasks.<name>.status and tasks.<name>.outputs.<output>
+	// of upstream tasks; when it is false the task is marked as skipped
+	// +optional
+	When string `json:"when,omitempty"`
+	// Decides how the outcome of the runAfter tasks gates this task, defaults to all_success.
+	// A run still fails if any of its tasks fail
+	// +kubebuilder:validation:Enum=all_success;all_done;one_failed;one_success
+	// +optional
+	TriggerRule string `json:"triggerRule,omitempty"`
+	// Expands the task into one instance per item of a list, each getting its item and
+	// index as MAP_ITEM and MAP_INDEX. Tasks that run after it wait for every instance
+	// +optional
+	Map *MapSpec `json:"map,omitempty"`
+	// Runs another DAG as a child DagRun instead of a pod. The task succeeds or fails
+	// with the child run, and cannot set an image, command, script or taskRef
+	// +optional
+	DagRef *DagRef `json:"dagRef,omitempty"`
+	// Maximum time the task may take, from being queued until it finishes, including
+	// time spent pending and retrying. When it passes, the task is stopped and marked timed_out
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Reuses the result of an earlier successful run of the task, instead of starting a pod,
+	// when it runs again with the same definition and inputs
+	// +optional
+	Cache *CacheSpec `json:"cache,omitempty"`
+	// Files or directories the task publishes to downstream tasks through the object store.
+	// They are uploaded once the task command succeeds
+	// +optional
+	Artifacts []ArtifactSpec `json:"artifacts,omitempty"`
+	// Added to the priority of the run for this task, so tasks of a DAG can be ordered against each other
+	// +kubebuilder:validation:Minimum=-100
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// Name of the Pool in the DAG's namespace the task takes slots from while its pod runs.
+	// The task is only claimed once the pool has enough free slots
+	// +optional
+	Pool string `json:"pool,omitempty"`
+	// Number of slots of the pool the task takes, defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PoolSlots int32 `json:"poolSlots,omitempty"`
+	// Waits for a condition checked by the worker on an interval instead of running a pod.
+	// The task succeeds once the condition holds and fails when its timeout passes first
+	// +optional
+	Sensor *SensorSpec `json:"sensor,omitempty"`
 }
 
-type TaskRef struct {
-	Name    string `json:"name"`
-	Version int    `json:"version"`
+// CacheSpec decides how long the result of a successful task run can be reused for. Runs
+// match when the task definition, its image, command, args and script after templates are
+// expanded, its parameters, its map item and the outputs it reads from upstream tasks are
+// the same. Secret and ConfigMap parameters match on their name and key, not on their content
+type CacheSpec struct {
+	// How long a successful run can be reused for, e.g. 24h
+	TTL metav1.Duration `json:"ttl"`
 }
 
-// Backoff defines the backoff strategy for a task
-type Backoff struct {
-	Limit int `json:"limit"`
+// ArtifactSpec names a file or directory a task publishes through the object store. Tasks
+// that run after it find a copy under /kontroler/artifacts/<task>/<name>, or
+// /kontroler/artifacts/<task>/<mapIndex>/<name> for the instances of a map task
+type ArtifactSpec struct {
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_-]+$`
+	Name string `json:"name"`
+	// Absolute path of the file or directory in the task container
+	Path string `json:"path"`
 }
 
-// Conditional defines the conditional execution parameters
-type Conditional struct {
-	Enabled    bool  `json:"enabled"`
-	RetryCodes []int `json:"retryCodes"`
-}
+// artifact names end up in object keys and directory names
+var artifactNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
 
-type Webhook struct {
-	URL       string `json:"url"`
-	VerifySSL bool   `json:"verifySSL"`
+// ValidateArtifacts ensures the artifacts of a task have usable, unique names and absolute paths.
+func ValidateArtifacts(taskName string, artifacts []ArtifactSpec) error {
+	seen := map[string]bool{}
+	for _, artifact := range artifacts {
+		if !artifactNameRegex.MatchString(artifact.Name) {
+			return fmt.Errorf("task %s has an invalid artifact name: %q", taskName, artifact.Name)
+		}
+
+		if seen[artifact.Name] {
+			return fmt.Errorf("task %s has duplicate artifact: %s", taskName, artifact.Name)
+		}
+		seen[artifact.Name] = true
+
+		if !path.IsAbs(artifact.Path) || path.Clean(artifact.Path) == "/" {
+			return fmt.Errorf("task %s artifact %s must have an absolute path below /, got %q", taskName, artifact.Name, artifact.Path)
+		}
+	}
+
+	return nil
 }
 
-// DAGSpec defines the desired state of DAG
-type DAGSpec struct {
+// DefaultSensorInterval is how often the condition of a sensor is checked when it sets no interval
+const DefaultSensorInterval = 30 * time.Second
+
+// SensorSpec is a condition a task waits for. Exactly one of http, object or resource is set
+type SensorSpec struct {
+	// How often the condition is checked, defaults to 30s
 	// +optional
-	Schedule string `json:"schedule"`
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// How long to wait for the condition, counted from when a worker starts checking it
+	Timeout metav1.Duration `json:"timeout"`
 	// +optional
-	Task []TaskSpec `json:"task,omitempty"`
+	HTTP *HTTPSensor `json:"http,omitempty"`
 	// +optional
-	Parameters []DagParameterSpec `json:"parameters,omitempty"`
+	Object *ObjectSensor `json:"object,omitempty"`
 	// +optional
-	Webhook Webhook `json:"webhook,omitempty"`
+	Resource *ResourceSensor `json:"resource,omitempty"`
+}
+
+// HTTPSensor waits for a GET of a URL to answer with an expected status and, optionally, a matching body
+type HTTPSensor struct {
+	URL string `json:"url"`
+	// Status codes the response may have, defaults to 200
 	// +optional
-	Workspace Workspace `json:"workspace,omitempty"`
+	StatusCodes []int `json:"statusCodes,omitempty"`
+	// Regular expression the response body must match
 	// +optional
-	Suspended bool `json:"suspended,omitempty"`
-	// DSL string to define the DAG using the DSL syntax
-	// When provided, this takes precedence over the individual fields above
+	BodyMatch string `json:"bodyMatch,omitempty"`
+}
+
+// ObjectSensor waits for an object to exist in the store the controller keeps logs in
+type ObjectSensor struct {
+	// Key of the object in the s3 bucket, or its path below the base directory of the filesystem store
+	Key string `json:"key"`
+}
+
+// ResourceSensor waits for a Kubernetes resource to exist and, optionally, to have a condition
+type ResourceSensor struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Must be the namespace of the DAG, which it defaults to. Cluster scoped kinds cannot be watched
 	// +optional
-	DSL string `json:"dsl,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Type of a status condition that must be True, such as Ready or Complete
+	// +optional
+	Condition string `json:"condition,omitempty"`
+}
+
+// GetInterval returns how often the condition is checked
+func (s *SensorSpec) GetInterval() time.Duration {
+	if s.Interval == nil {
+		return DefaultSensorInterval
+	}
+
+	return s.Interval.Duration
+}
+
+// Validate ensures the sensor checks exactly one usable condition on a usable interval.
+func (s *SensorSpec) Validate() error {
+	if s.Interval != nil && s.Interval.Duration < time.Second {
+		return fmt.Errorf("interval must be at least 1s, got %s", s.Interval.Duration)
+	}
+
+	if s.Timeout.Duration < time.Second {
+		return fmt.Errorf("timeout must be at least 1s, got %s", s.Timeout.Duration)
+	}
+
+	set := 0
+	for _, isSet := range []bool{s.HTTP != nil, s.Object != nil, s.Resource != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("must set exactly one of http, object or resource")
+	}
+
+	switch {
+	case s.HTTP != nil:
+		if !strings.HasPrefix(s.HTTP.URL, "http://") && !strings.HasPrefix(s.HTTP.URL, "https://") {
+			return fmt.Errorf("http url must start with http:// or https://, got %q", s.HTTP.URL)
+		}
+
+		for _, code := range s.HTTP.StatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("http status code must be between 100 and 599, got %d", code)
+			}
+		}
+
+		if _, err := regexp.Compile(s.HTTP.BodyMatch); err != nil {
+			return fmt.Errorf("invalid http bodyMatch: %w", err)
+		}
+	case s.Object != nil:
+		if s.Object.Key == "" {
+			return errors.New("object key must be specified")
+		}
+	case s.Resource != nil:
+		if s.Resource.APIVersion == "" || s.Resource.Kind == "" || s.Resource.Name == "" {
+			return errors.New("resource apiVersion, kind and name must be specified")
+		}
+	}
+
+	return nil
 }
 
-// DAGStatus defines the observed state of DAG
-type DAGStatus struct {
-	// shows the current phase of the DAG
-	Phase string `json:"phase,omitempty"`
-	// reason for the current phase
-	Message string `json:"message,omitempty"`
-}
+// templateFields lists the fields of a sensor that are expanded as templates
+func (s *SensorSpec) templateFields() []string {
+	switch {
+	case s == nil:
+		return nil
+	case s.HTTP != nil:
+		return []string{s.HTTP.URL}
+	case s.Object != nil:
+		return []string{s.Object.Key}
+	case s.Resource != nil:
+		return []string{s.Resource.Name, s.Resource.Namespace}
+	default:
+		return nil
+	}
+}
+
+// MapSpec defines where the items of a map task come from
+type MapSpec struct {
+	// Name of a DAG parameter holding the items, as a JSON array or a comma separated list
+	// +optional
+	Parameter string `json:"parameter,omitempty"`
+	// Upstream output holding the items, as <task>.<output>
+	// +optional
+	Output string `json:"output,omitempty"`
+	// Maximum number of instances running at once, 0 runs them all together
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxParallelism int `json:"maxParallelism,omitempty"`
+}
+
+// DagRef points at the DAG a sub-DAG task runs
+type DagRef struct {
+	Name string `json:"name"`
+	// Parameters passed to the child run, any left out use the defaults of the child DAG
+	// +optional
+	Parameters []DagRefParameter `json:"parameters,omitempty"`
+}
+
+// DagRefParameter sets a parameter of the child run, either to a value or to the
+// value of a parameter of the parent run
+type DagRefParameter struct {
+	Name string `json:"name"`
+	// +optional
+	Value string `json:"value,omitempty"`
+	// Name of the parent DAG parameter to copy, secret parameters stay secret
+	// +optional
+	FromParameter string `json:"fromParameter,omitempty"`
+}
+
+const (
+	// TriggerRuleAllSuccess runs the task once every upstream task succeeded or was skipped
+	TriggerRuleAllSuccess = "all_success"
+	// TriggerRuleAllDone runs the task once every upstream task finished, whatever the outcome
+	TriggerRuleAllDone = "all_done"
+	// TriggerRuleOneFailed runs the task as soon as an upstream task fails
+	TriggerRuleOneFailed = "one_failed"
+	// TriggerRuleOneSuccess runs the task as soon as an upstream task succeeds
+	TriggerRuleOneSuccess = "one_success"
+)
+
+type TaskRef struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// Backoff defines the backoff strategy for a task
+type Backoff struct {
+	Limit int `json:"limit"`
+	// How long to wait before the first retry, retries start straight away when it is not set
+	// +optional
+	InitialDelay *metav1.Duration `json:"initialDelay,omitempty"`
+	// Upper bound on the wait between two attempts
+	// +optional
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+	// Factor the wait grows by after each retry, such as "2" or "1.5". Defaults to "2"
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	Multiplier string `json:"multiplier,omitempty"`
+	// Fraction of the wait, between "0" and "1", that is randomly added or taken away
+	// so that retries of many runs do not line up
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	Jitter string `json:"jitter,omitempty"`
+}
+
+// DefaultBackoffMultiplier is used when a backoff has an initialDelay but no multiplier
+const DefaultBackoffMultiplier = 2.0
+
+// Validate ensures the delays can be stored in whole seconds and that the multiplier and jitter are usable.
+func (b *Backoff) Validate() error {
+	if b.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", b.Limit)
+	}
+
+	if b.InitialDelay == nil {
+		if b.MaxDelay != nil || b.Multiplier != "" || b.Jitter != "" {
+			return fmt.Errorf("maxDelay, multiplier and jitter require an initialDelay")
+		}
+		return nil
+	}
+
+	if b.InitialDelay.Duration < time.Second {
+		return fmt.Errorf("initialDelay must be at least 1s, got %s", b.InitialDelay.Duration)
+	}
+
+	if b.MaxDelay != nil && b.MaxDelay.Duration < b.InitialDelay.Duration {
+		return fmt.Errorf("maxDelay %s must not be less than initialDelay %s", b.MaxDelay.Duration, b.InitialDelay.Duration)
+	}
+
+	if _, err := b.ParseMultiplier(); err != nil {
+		return err
+	}
+
+	if _, err := b.ParseJitter(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ParseMultiplier returns the multiplier as a number, DefaultBackoffMultiplier when it is not set
+func (b *Backoff) ParseMultiplier() (float64, error) {
+	if b.Multiplier == "" {
+		return DefaultBackoffMultiplier, nil
+	}
+
+	multiplier, err := strconv.ParseFloat(b.Multiplier, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid multiplier %q: %w", b.Multiplier, err)
+	}
+
+	if multiplier < 1 {
+		return 0, fmt.Errorf("multiplier must be at least 1, got %s", b.Multiplier)
+	}
+
+	return multiplier, nil
+}
+
+// ParseJitter returns the jitter as a number, 0 when it is not set
+func (b *Backoff) ParseJitter() (float64, error) {
+	if b.Jitter == "" {
+		return 0, nil
+	}
+
+	jitter, err := strconv.ParseFloat(b.Jitter, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid jitter %q: %w", b.Jitter, err)
+	}
+
+	if jitter < 0 || jitter > 1 {
+		return 0, fmt.Errorf("jitter must be between 0 and 1, got %s", b.Jitter)
+	}
+
+	return jitter, nil
+}
+
+// Conditional defines the conditional execution parameters
+type Conditional struct {
+	Enabled    bool  `json:"enabled"`
+	RetryCodes []int `json:"retryCodes"`
+}
+
+type Webhook struct {
+	URL       string `json:"url"`
+	VerifySSL bool   `json:"verifySSL"`
+}
+
+// DAGSpec defines the desired state of DAG
+type DAGSpec struct {
+	// +optional
+	Schedule string `json:"schedule"`
+	// +optional
+	Task []TaskSpec `json:"task,omitempty"`
+	// +optional
+	Parameters []DagParameterSpec `json:"parameters,omitempty"`
+	// +optional
+	Webhook Webhook `json:"webhook,omitempty"`
+	// +optional
+	Workspace Workspace `json:"workspace,omitempty"`
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+	// DSL string to define the DAG using the DSL syntax
+	// When provided, this takes precedence over the individual fields above
+	// +optional
+	DSL string `json:"dsl,omitempty"`
+	// Maximum time a run of the DAG may take. When it passes, the tasks still running
+	// are stopped and the run is marked timed_out
+	// +optional
+	RunTimeout *metav1.Duration `json:"runTimeout,omitempty"`
+	// What happens to a new run while maxActiveRuns runs of the DAG are already active, defaults to Allow
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// Number of runs that may be active at once, defaults to 1 when a concurrencyPolicy other than Allow is set
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxActiveRuns int `json:"maxActiveRuns,omitempty"`
+	// Start a run for every schedule time that was missed while the controller was down,
+	// rather than a single run for the latest of them
+	// +optional
+	Catchup bool `json:"catchup,omitempty"`
+	// Most recent missed schedule times to catch up on, defaults to 10
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCatchupRuns int `json:"maxCatchupRuns,omitempty"`
+	// Start a run for every schedule time within the range. Each range is only backfilled once,
+	// setting a new one starts the runs for it
+	// +optional
+	Backfill *Backfill `json:"backfill,omitempty"`
+	// IANA time zone the schedule is evaluated in, such as Europe/London. Defaults to the time zone of the controller
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+	// No scheduled runs start before this time
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// No scheduled runs start after this time
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+	// Order in which workers claim the tasks of its runs, higher first. Tasks waiting to be
+	// claimed gain priority as they wait, so lower priority DAGs are not starved. Defaults to 0
+	// +kubebuilder:validation:Minimum=-100
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// Start runs of the DAG on events other than its schedule
+	// +optional
+	Triggers *DagTriggers `json:"triggers,omitempty"`
+}
+
+// DagTriggers are the events other than its schedule that start runs of a DAG
+type DagTriggers struct {
+	// Start a run whenever a run of another DAG finishes with the required outcome
+	// +optional
+	OnDagCompletion []DagCompletionTrigger `json:"onDagCompletion,omitempty"`
+}
+
+// DagCompletionTrigger starts a run of the DAG when a run of an upstream DAG finishes
+type DagCompletionTrigger struct {
+	// Name of the upstream DAG
+	DagName string `json:"dagName"`
+	// Namespace of the upstream DAG, defaults to the namespace of this DAG
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Outcome the upstream run has to finish with, defaults to success
+	// +optional
+	Outcome DagCompletionOutcome `json:"outcome,omitempty"`
+	// Parameters of the run, fromParameter copies a parameter of the upstream run.
+	// Any left out use the defaults of this DAG
+	// +optional
+	Parameters []DagRefParameter `json:"parameters,omitempty"`
+}
+
+// DagCompletionOutcome is the outcome of an upstream run that starts a run of the DAG.
+// Runs replaced by a newer run of their DAG never start one
+// +kubebuilder:validation:Enum=success;failed;any
+type DagCompletionOutcome string
+
+const (
+	// DagCompletionOutcomeSuccess starts a run when the upstream run succeeds
+	DagCompletionOutcomeSuccess DagCompletionOutcome = "success"
+	// DagCompletionOutcomeFailed starts a run when the upstream run fails or times out
+	DagCompletionOutcomeFailed DagCompletionOutcome = "failed"
+	// DagCompletionOutcomeAny starts a run whichever way the upstream run finishes
+	DagCompletionOutcomeAny DagCompletionOutcome = "any"
+)
+
+// GetOutcome returns the outcome the trigger waits for
+func (t *DagCompletionTrigger) GetOutcome() DagCompletionOutcome {
+	if t.Outcome == "" {
+		return DagCompletionOutcomeSuccess
+	}
+	return t.Outcome
+}
+
+// GetNamespace returns the namespace of the upstream DAG, given the namespace of the DAG the trigger belongs to
+func (t *DagCompletionTrigger) GetNamespace(namespace string) string {
+	if t.Namespace == "" {
+		return namespace
+	}
+	return t.Namespace
+}
+
+// Matches reports whether an upstream run that finished with a status starts a run
+func (o DagCompletionOutcome) Matches(status string) bool {
+	switch o {
+	case DagCompletionOutcomeSuccess:
+		return status == "success"
+	case DagCompletionOutcomeFailed:
+		return status == "failed" || status == "timed_out"
+	case DagCompletionOutcomeAny:
+		return status == "success" || status == "failed" || status == "timed_out"
+	}
+	return false
+}
+
+// DefaultMaxCatchupRuns is the number of missed schedule times caught up on when maxCatchupRuns is not set
+const DefaultMaxCatchupRuns = 10
+
+// MinPriority and MaxPriority bound the priority of DAGs, runs and tasks
+const (
+	MinPriority = -100
+	MaxPriority = 100
+)
+
+// Backfill is a range of schedule times to start runs for, both ends are inclusive
+type Backfill struct {
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+}
+
+// ConcurrencyPolicy describes how a DAG treats a new run while others are still active.
+// It applies to scheduled and manual runs, but not to the child runs of dagRef tasks
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace;Queue
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyAllow starts every run, however many are active
+	ConcurrencyPolicyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyPolicyForbid skips new runs while the limit is reached
+	ConcurrencyPolicyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyPolicyReplace stops the oldest active runs to make room for new ones
+	ConcurrencyPolicyReplace ConcurrencyPolicy = "Replace"
+	// ConcurrencyPolicyQueue holds new runs as queued until an active run finishes
+	ConcurrencyPolicyQueue ConcurrencyPolicy = "Queue"
+)
+
+// DAGStatus defines the observed state of DAG
+type DAGStatus struct {
+	// shows the current phase of the DAG
+	Phase string `json:"phase,omitempty"`
+	// reason for the current phase
+	Message string `json:"message,omitempty"`
+	// Generation of the DAG the status was last updated for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Version of the DAG stored in the database, starting at 0 and going up every time the spec changes
+	// +optional
+	Version *int `json:"version,omitempty"`
+	// Hash of the stored spec
+	// +optional
+	Hash string `json:"hash,omitempty"`
+	// Latest run of the DAG
+	// +optional
+	LastRun *DAGLastRun `json:"lastRun,omitempty"`
+	// Time of the next scheduled run, not set when the DAG is not scheduled
+	// +optional
+	NextScheduledTime *metav1.Time `json:"nextScheduledTime,omitempty"`
+	// Valid, Scheduled and Suspended conditions
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// DAGLastRun is the latest run of a DAG and its outcome
+type DAGLastRun struct {
+	DagRunId int `json:"dagRunId"`
+	// Name of the DagRun
+	Name string `json:"name"`
+	// +optional
+	Phase DagRunPhase `json:"phase,omitempty"`
+}
+
+const (
+	// DAGConditionValid is True once the DAG has passed validation and been stored
+	DAGConditionValid = "Valid"
+	// DAGConditionScheduled is True while the schedule of the DAG is starting runs
+	DAGConditionScheduled = "Scheduled"
+	// DAGConditionSuspended is True while the DAG is suspended
+	DAGConditionSuspended = "Suspended"
+)
+
+// DAGAnnotationInvalidateCache asks the controller to drop the cached task results of a DAG. Its value is a
+// comma separated list of task names, empty for every task. The controller removes it once the results are dropped
+const DAGAnnotationInvalidateCache = "kontroler/invalidate-cache"
+
+// CacheInvalidationTasks returns the tasks named by an invalidate-cache annotation, nil meaning every task
+func CacheInvalidationTasks(value string) []string {
+	var tasks []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			tasks = append(tasks, name)
+		}
+	}
+	return tasks
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.status.version`
+//+kubebuilder:printcolumn:name="Last Run",type=string,JSONPath=`.status.lastRun.phase`
+//+kubebuilder:printcolumn:name="Next Run",type=date,JSONPath=`.status.nextScheduledTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DAG is the Schema for the dags API
+type DAG struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DAGSpec   `json:"spec,omitempty"`
+	Status DAGStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DAGList contains a list of DAG
+type DAGList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DAG `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DAG{}, &DAGList{})
+}
+
+// ValidateDAG checks if the DAG is valid.
+func (dag *DAG) ValidateDAG(refParams map[TaskRef][]string) error {
+	if err := dag.checkFieldsFilled(); err != nil {
+		return err
+	}
+
+	if err := dag.checkParameters(refParams); err != nil {
+		return err
+	}
+	if err := dag.checkNoCycles(); err != nil {
+		return err
+	}
+	if err := dag.checkRunAfterTasksExist(); err != nil {
+		return err
+	}
+	if err := dag.checkAllTasksConnected(); err != nil {
+		return err
+	}
+	if err := dag.checkStartingTask(); err != nil {
+		return err
+	}
+	if err := dag.checkOutputs(); err != nil {
+		return err
+	}
+	if err := dag.checkConditions(); err != nil {
+		return err
+	}
+	if err := dag.checkTemplates(); err != nil {
+		return err
+	}
+	if err := dag.checkTriggerRules(); err != nil {
+		return err
+	}
+	if err := dag.checkMaps(); err != nil {
+		return err
+	}
+	if err := dag.checkDagRefs(); err != nil {
+		return err
+	}
+	if err := dag.checkTimeouts(); err != nil {
+		return err
+	}
+	if err := dag.checkCaches(); err != nil {
+		return err
+	}
+	if err := dag.checkArtifacts(); err != nil {
+		return err
+	}
+	if err := dag.checkPools(); err != nil {
+		return err
+	}
+	if err := dag.checkSensors(); err != nil {
+		return err
+	}
+	if err := dag.checkCompletionTriggers(); err != nil {
+		return err
+	}
+
+	if err := dag.checkBackoffs(); err != nil {
+		return err
+	}
+
+	if err := dag.checkPodTemplates(); err != nil {
+		return err
+	}
+
+	if err := dag.checkConcurrency(); err != nil {
+		return err
+	}
+
+	if err := dag.checkCatchup(); err != nil {
+		return err
+	}
+
+	if err := dag.checkScheduleWindow(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkFieldsFilled ensures all necessary fields in the DAG are filled.
+func (dag *DAG) checkFieldsFilled() error {
+	taskNames := make(map[string]bool)
+
+	for _, task := range dag.Spec.Task {
+		if task.Name == "" {
+			return errors.New("task name must be specified")
+		}
+
+		if task.TaskRef != nil {
+			// We ignore any further checks as they will not be used
+			// Previous check will have confirmed if this task exists or not
+			continue
+		}
+
+		// Sub-DAG and sensor tasks do not run a pod, checkDagRefs and checkSensors cover them
+		if task.DagRef == nil && task.Sensor == nil {
+			// Either have a script or command
+			if len(task.Script) == 0 && len(task.Command) == 0 {
+				return errors.New("must provide a script or a command")
+			}
+
+			if task.Image == "" {
+				return errors.New("task image must be specified")
+			}
+		}
+
+		if _, exists := taskNames[task.Name]; exists {
+			return errors.New("duplicate task name: " + task.Name)
+		}
+
+		taskNames[task.Name] = true
+	}
+
+	return nil
+}
+
+// checkNoCycles ensures there are no cyclic dependencies in the tasks.
+func (dag *DAG) checkNoCycles() error {
+	visited := make(map[string]bool)
+	recStack := make(map[string]bool)
+
+	var visit func(string) bool
+	visit = func(name string) bool {
+		if recStack[name] {
+			return true // cycle detected
+		}
+		if visited[name] {
+			return false
+		}
+		visited[name] = true
+		recStack[name] = true
+
+		for _, task := range dag.Spec.Task {
+			if task.Name == name {
+				for _, dep := range task.RunAfter {
+					if visit(dep) {
+						return true
+					}
+				}
+			}
+		}
+		recStack[name] = false
+		return false
+	}
+
+	for _, task := range dag.Spec.Task {
+		if visit(task.Name) {
+			return errors.New("cyclic dependency detected")
+		}
+	}
+	return nil
+}
+
+// checkRunAfterTasksExist ensures that all runAfter references point to existing tasks.
+func (dag *DAG) checkRunAfterTasksExist() error {
+	taskMap := make(map[string]bool)
+	for _, task := range dag.Spec.Task {
+		taskMap[task.Name] = true
+	}
+	for _, task := range dag.Spec.Task {
+		for _, dep := range task.RunAfter {
+			if !taskMap[dep] {
+				return fmt.Errorf("task %s has runAfter dependency on non-existent task %s", task.Name, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// checkAllTasksConnected ensures that all tasks are part of a single connected component.
+func (dag *DAG) checkAllTasksConnected() error {
+	adjList := make(map[string][]string)
+	taskMap := make(map[string]bool)
+	for _, task := range dag.Spec.Task {
+		taskMap[task.Name] = true
+		adjList[task.Name] = append(adjList[task.Name], task.RunAfter...)
+		for _, dep := range task.RunAfter {
+			adjList[dep] = append(adjList[dep], task.Name)
+		}
+	}
+
+	visited := make(map[string]bool)
+	var dfs func(string)
+	dfs = func(name string) {
+		visited[name] = true
+		for _, neighbor := range adjList[name] {
+			if !visited[neighbor] {
+				dfs(neighbor)
+			}
+		}
+	}
+
+	var startNode string
+	// Get first node
+	for name := range taskMap {
+		startNode = name
+		break
+	}
+
+	dfs(startNode)
+
+	for task := range taskMap {
+		if !visited[task] {
+			return errors.New("not all tasks are connected")
+		}
+	}
+	return nil
+}
+
+// checkStartingTask ensures there is at least one task that has no runAfter dependencies.
+func (dag *DAG) checkStartingTask() error {
+	for _, task := range dag.Spec.Task {
+		if len(task.RunAfter) == 0 {
+			return nil
+		}
+	}
+	return errors.New("no starting task found (a task with no runAfter dependencies)")
+}
+
+// checkParameters ensures there is at least one task that has no runAfter dependencies.
+func (dag *DAG) checkParameters(refParams map[TaskRef][]string) error {
+	paramsMap := map[string]bool{}
+	for _, value := range dag.Spec.Parameters {
+		if value.Name == "" {
+			return fmt.Errorf("parameter has an empty name")
+		}
+
+		if value.Required {
+			if value.defaultSources() != 0 {
+				return fmt.Errorf("parameter %s is required and cannot have a default", value.Name)
+			}
+
+			// scheduled runs do not set any parameters
+			if dag.Spec.Schedule != "" {
+				return fmt.Errorf("parameter %s is required, which a scheduled DAG cannot provide", value.Name)
+			}
+		} else {
+			if value.defaultSources() != 1 {
+				return fmt.Errorf("parameter %s must provide one of defaultValue, defaultFromSecret, defaultSecretKeyRef or defaultConfigMapKeyRef", value.Name)
+			}
+
+			if ref, configMap := value.DefaultRef(); ref != nil {
+				if err := validateKeyRef(ref, configMap); err != nil {
+					return fmt.Errorf("parameter %s: %w", value.Name, err)
+				}
+			}
+		}
+
+		if err := value.ParameterSchema.Validate(); err != nil {
+			return fmt.Errorf("parameter %s: %w", value.Name, err)
+		}
+
+		// the value of a secret is only known once the task runs
+		if value.DefaultValue != "" {
+			if err := value.ValidateValue(value.DefaultValue); err != nil {
+				return fmt.Errorf("parameter %s defaultValue: %w", value.Name, err)
+			}
+		}
+
+		paramsMap[value.Name] = true
+	}
+
+	for _, task := range dag.Spec.Task {
+		if task.TaskRef != nil {
+			values, ok := refParams[*task.TaskRef]
+			if !ok {
+				continue
+			}
+
+			for _, value := range values {
+				if _, ok := paramsMap[value]; !ok {
+					return fmt.Errorf("parameter selected in task does not exist")
+				}
+			}
+		}
+
+		for _, value := range task.Parameters {
+			if _, ok := paramsMap[value]; !ok {
+				return fmt.Errorf("parameter selected in task does not exist")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate ensures the schema is consistent with its type
+func (s *ParameterSchema) Validate() error {
+	switch s.Type {
+	case "", ParameterTypeString, ParameterTypeInt, ParameterTypeBool, ParameterTypeEnum, ParameterTypeJSON:
+	default:
+		return fmt.Errorf("unknown type %s", s.Type)
+	}
+
+	if s.Type == ParameterTypeEnum && len(s.Enum) == 0 {
+		return errors.New("enum type requires enum values")
+	}
+	if s.Type != ParameterTypeEnum && len(s.Enum) > 0 {
+		return errors.New("enum values require the enum type")
+	}
+
+	if s.Pattern != "" {
+		if s.Type != "" && s.Type != ParameterTypeString {
+			return errors.New("pattern requires the string type")
+		}
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	if s.Min != nil || s.Max != nil {
+		if s.Type != ParameterTypeInt {
+			return errors.New("min and max require the int type")
+		}
+		if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+			return fmt.Errorf("min %d is greater than max %d", *s.Min, *s.Max)
+		}
+	}
+
+	return nil
+}
+
+// ValidateValue ensures a value is accepted by the schema
+func (s *ParameterSchema) ValidateValue(value string) error {
+	switch s.Type {
+	case ParameterTypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an int", value)
+		}
+		if s.Min != nil && n < *s.Min {
+			return fmt.Errorf("%d is less than the min of %d", n, *s.Min)
+		}
+		if s.Max != nil && n > *s.Max {
+			return fmt.Errorf("%d is greater than the max of %d", n, *s.Max)
+		}
+	case ParameterTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a bool", value)
+		}
+	case ParameterTypeEnum:
+		if !slices.Contains(s.Enum, value) {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(s.Enum, ", "))
+		}
+	case ParameterTypeJSON:
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("%q is not valid json", value)
+		}
+	default:
+		if s.Pattern != "" {
+			matched, err := regexp.MatchString(s.Pattern, value)
+			if err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+			if !matched {
+				return fmt.Errorf("%q does not match the pattern %s", value, s.Pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateRunParameters checks the parameters of a run against the parameters of its DAG. Every
+// parameter has to be declared by the DAG, required ones have to be set and values have to match
+// their schema. Values read from secrets are only known once the task runs so they are not checked.
+func ValidateRunParameters(specs []DagParameterSpec, params []ParameterSpec) error {
+	declared := make(map[string]DagParameterSpec, len(specs))
+	for _, spec := range specs {
+		declared[spec.Name] = spec
+	}
+
+	set := make(map[string]bool, len(params))
+	for _, param := range params {
+		spec, ok := declared[param.Name]
+		if !ok {
+			return fmt.Errorf("parameter %s is not declared by the DAG", param.Name)
+		}
+		set[param.Name] = true
+
+		if param.IsReference() {
+			continue
+		}
+
+		if err := spec.ValidateValue(param.Value); err != nil {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+	}
+
+	for _, spec := range specs {
+		if spec.Required && !set[spec.Name] {
+			return fmt.Errorf("parameter %s is required", spec.Name)
+		}
+	}
+
+	return nil
+}
+
+// output names end up in environment variable names of downstream tasks
+var outputNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkOutputs ensures declared outputs have usable, unique names.
+func (dag *DAG) checkOutputs() error {
+	// outputs of different tasks can map to the same env var, e.g. task a_b output c and task a output b_c
+	envs := map[string]string{}
+	for _, task := range dag.Spec.Task {
+		seen := map[string]bool{}
+		for _, output := range task.Outputs {
+			if !outputNameRegex.MatchString(output) {
+				return fmt.Errorf("task %s has an invalid output name: %q", task.Name, output)
+			}
+
+			if seen[output] {
+				return fmt.Errorf("task %s has duplicate output: %s", task.Name, output)
+			}
+
+			seen[output] = true
+
+			env := OutputEnvName(task.Name, output)
+			if other, ok := envs[env]; ok {
+				return fmt.Errorf("task %s output %s and %s are both passed as %s", task.Name, output, other, env)
+			}
+			envs[env] = fmt.Sprintf("task %s output %s", task.Name, output)
+		}
+	}
+
+	// the prefix is kept for outputs so they never clash with env vars set by the DAG
+	for _, param := range dag.Spec.Parameters {
+		if strings.HasPrefix(param.Name, OutputEnvPrefix) {
+			return fmt.Errorf("parameter %s cannot start with %s, it is kept for task outputs", param.Name, OutputEnvPrefix)
+		}
+	}
+
+	for _, task := range dag.Spec.Task {
+		if task.PodTemplate == nil {
+			continue
+		}
+
+		for _, env := range task.PodTemplate.Env {
+			if strings.HasPrefix(env.Name, OutputEnvPrefix) {
+				return fmt.Errorf("task %s env var %s cannot start with %s, it is kept for task outputs", task.Name, env.Name, OutputEnvPrefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// OutputEnvPrefix starts the names of the env vars the outputs of upstream tasks are passed in
+const OutputEnvPrefix = "OUTPUT_"
+
+// OutputEnvName builds the env var name an upstream output is exposed as,
+// e.g. task "fetch-data" with output "rowCount" becomes OUTPUT_FETCH_DATA_ROWCOUNT
+func OutputEnvName(taskName, output string) string {
+	return OutputEnvPrefix + envSafe(taskName) + "_" + envSafe(output)
+}
+
+func envSafe(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+}
+
+// checkTemplates ensures the templates in the command, args, script and image of the tasks only
+// reference parameters whose value is known when the pod is built
+func (dag *DAG) checkTemplates() error {
+	params := map[string]bool{}
+	secrets := map[string]bool{}
+	for _, param := range dag.Spec.Parameters {
+		params[param.Name] = true
+		secrets[param.Name] = param.IsReference()
+	}
+
+	for _, task := range dag.Spec.Task {
+		fields := append(templateFields(task.Image, task.Command, task.Args, task.Script), task.Sensor.templateFields()...)
+		for _, field := range fields {
+			if err := templating.Check(field, params); err != nil {
+				return fmt.Errorf("task %s: %w", task.Name, err)
+			}
+
+			for _, ref := range templating.References(field) {
+				if ref.Kind == templating.KindParams && secrets[ref.Name] {
+					return fmt.Errorf("task %s template cannot reference secret parameter: %s", task.Name, ref.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// templateFields lists the fields of a task that are expanded as templates
+func templateFields(image string, command, args []string, script string) []string {
+	fields := []string{image, script}
+	fields = append(fields, command...)
+	return append(fields, args...)
+}
+
+// checkConditions ensures when expressions parse and only read parameters of the
+// DAG and tasks that are guaranteed to have finished before the task starts.
+func (dag *DAG) checkConditions() error {
+	params := map[string]DagParameterSpec{}
+	for _, param := range dag.Spec.Parameters {
+		params[param.Name] = param
+	}
+
+	runAfter := map[string][]string{}
+	for _, task := range dag.Spec.Task {
+		runAfter[task.Name] = task.RunAfter
+	}
+
+	for _, task := range dag.Spec.Task {
+		if task.When == "" {
+			continue
+		}
+
+		expr, err := conditions.Parse(task.When)
+		if err != nil {
+			return fmt.Errorf("task %s has an invalid when expression: %w", task.Name, err)
+		}
+
+		for _, name := range expr.Params() {
+			param, ok := params[name]
+			if !ok {
+				return fmt.Errorf("task %s when expression references unknown parameter: %s", task.Name, name)
+			}
+
+			if param.IsReference() {
+				return fmt.Errorf("task %s when expression cannot reference secret parameter: %s", task.Name, name)
+			}
+		}
+
+		upstream := upstreamTasks(task.Name, runAfter)
+		for _, name := range expr.Tasks() {
+			if !upstream[name] {
+				return fmt.Errorf("task %s when expression references %s, which is not upstream of it", task.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// upstreamTasks returns every task the named task depends on, directly or transitively
+func upstreamTasks(name string, runAfter map[string][]string) map[string]bool {
+	upstream := map[string]bool{}
+	stack := append([]string{}, runAfter[name]...)
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if upstream[current] {
+			continue
+		}
+
+		upstream[current] = true
+		stack = append(stack, runAfter[current]...)
+	}
+
+	return upstream
+}
+
+// checkTriggerRules ensures trigger rules are known and only set on tasks that have upstream tasks.
+func (dag *DAG) checkTriggerRules() error {
+	for _, task := range dag.Spec.Task {
+		switch task.TriggerRule {
+		case "", TriggerRuleAllSuccess:
+			continue
+		case TriggerRuleAllDone, TriggerRuleOneFailed, TriggerRuleOneSuccess:
+		default:
+			return fmt.Errorf("task %s has an unknown trigger rule: %s", task.Name, task.TriggerRule)
+		}
+
+		if len(task.RunAfter) == 0 {
+			return fmt.Errorf("task %s sets trigger rule %s but has no runAfter tasks", task.Name, task.TriggerRule)
+		}
+	}
+
+	return nil
+}
+
+// checkMaps ensures every map task reads its items from exactly one source that
+// is known by the time the task runs.
+func (dag *DAG) checkMaps() error {
+	params := map[string]DagParameterSpec{}
+	for _, param := range dag.Spec.Parameters {
+		params[param.Name] = param
+	}
+
+	runAfter := map[string][]string{}
+	tasks := map[string]TaskSpec{}
+	for _, task := range dag.Spec.Task {
+		runAfter[task.Name] = task.RunAfter
+		tasks[task.Name] = task
+	}
+
+	for _, task := range dag.Spec.Task {
+		if task.Map == nil {
+			continue
+		}
+
+		if (task.Map.Parameter == "") == (task.Map.Output == "") {
+			return fmt.Errorf("task %s map must set exactly one of parameter or output", task.Name)
+		}
+
+		if task.Map.MaxParallelism < 0 {
+			return fmt.Errorf("task %s map maxParallelism cannot be negative", task.Name)
+		}
+
+		if task.Map.Parameter != "" {
+			param, ok := params[task.Map.Parameter]
+			if !ok {
+				return fmt.Errorf("task %s map references unknown parameter: %s", task.Name, task.Map.Parameter)
+			}
+
+			if param.IsReference() {
+				return fmt.Errorf("task %s map cannot read secret parameter: %s", task.Name, task.Map.Parameter)
+			}
+
+			continue
+		}
+
+		taskName, output, ok := strings.Cut(task.Map.Output, ".")
+		if !ok || taskName == "" || output == "" {
+			return fmt.Errorf("task %s map output must be in the form <task>.<output>: %s", task.Name, task.Map.Output)
+		}
+
+		if !upstreamTasks(task.Name, runAfter)[taskName] {
+			return fmt.Errorf("task %s map reads %s, which is not upstream of it", task.Name, taskName)
+		}
+
+		source := tasks[taskName]
+		if source.Map != nil {
+			return fmt.Errorf("task %s map cannot read the outputs of map task %s", task.Name, taskName)
+		}
+
+		// outputs of referenced tasks live on the DagTask and are not known here
+		if source.TaskRef == nil && !slices.Contains(source.Outputs, output) {
+			return fmt.Errorf("task %s map reads %s, which task %s does not declare as an output", task.Name, output, taskName)
+		}
+	}
+
+	return nil
+}
+
+// checkDagRefs ensures sub-DAG tasks only describe the child run and fill its
+// parameters from values or parameters of this DAG. Loops through other DAGs are found by CheckDagRefLoops
+func (dag *DAG) checkDagRefs() error {
+	params := map[string]bool{}
+	for _, param := range dag.Spec.Parameters {
+		params[param.Name] = true
+	}
+
+	for _, task := range dag.Spec.Task {
+		if task.DagRef == nil {
+			continue
+		}
+
+		if task.DagRef.Name == "" {
+			return fmt.Errorf("task %s dagRef must name a DAG", task.Name)
+		}
+
+		if task.DagRef.Name == dag.Name {
+			return fmt.Errorf("task %s dagRef cannot run the DAG it belongs to", task.Name)
+		}
+
+		if task.Image != "" || len(task.Command) > 0 || task.Script != "" || task.TaskRef != nil {
+			return fmt.Errorf("task %s cannot set an image, command, script or taskRef alongside dagRef", task.Name)
+		}
 
-//+kubebuilder:object:root=true
-//+kubebuilder:subresource:status
+		// the child run publishes no outputs and runs exactly once
+		if len(task.Outputs) > 0 || task.Map != nil {
+			return fmt.Errorf("task %s cannot declare outputs or a map alongside dagRef", task.Name)
+		}
 
-// DAG is the Schema for the dags API
-type DAG struct {
-	metav1.TypeMeta   `json:",inline"`
-	metav1.ObjectMeta `json:"metadata,omitempty"`
+		seen := map[string]bool{}
+		for _, param := range task.DagRef.Parameters {
+			if param.Name == "" {
+				return fmt.Errorf("task %s dagRef has a parameter with an empty name", task.Name)
+			}
 
-	Spec   DAGSpec   `json:"spec,omitempty"`
-	Status DAGStatus `json:"status,omitempty"`
-}
+			if seen[param.Name] {
+				return fmt.Errorf("task %s dagRef has duplicate parameter: %s", task.Name, param.Name)
+			}
+			seen[param.Name] = true
 
-//+kubebuilder:object:root=true
+			if (param.Value == "") == (param.FromParameter == "") {
+				return fmt.Errorf("task %s dagRef parameter %s must set exactly one of value or fromParameter", task.Name, param.Name)
+			}
 
-// DAGList contains a list of DAG
-type DAGList struct {
-	metav1.TypeMeta `json:",inline"`
-	metav1.ListMeta `json:"metadata,omitempty"`
-	Items           []DAG `json:"items"`
-}
+			if param.FromParameter != "" && !params[param.FromParameter] {
+				return fmt.Errorf("task %s dagRef parameter %s references unknown parameter: %s", task.Name, param.Name, param.FromParameter)
+			}
+		}
+	}
 
-func init() {
-	SchemeBuilder.Register(&DAG{}, &DAGList{})
+	return nil
 }
 
-// ValidateDAG checks if the DAG is valid.
-func (dag *DAG) ValidateDAG(refParams map[TaskRef][]string) error {
-	if err := dag.checkFieldsFilled(); err != nil {
-		return err
+// checkTimeouts ensures the task and run timeouts are positive and can be stored in whole seconds.
+func (dag *DAG) checkTimeouts() error {
+	if dag.Spec.RunTimeout != nil && dag.Spec.RunTimeout.Duration < time.Second {
+		return fmt.Errorf("runTimeout must be at least 1s, got %s", dag.Spec.RunTimeout.Duration)
 	}
 
-	if err := dag.checkParameters(refParams); err != nil {
-		return err
+	for _, task := range dag.Spec.Task {
+		if task.Timeout != nil && task.Timeout.Duration < time.Second {
+			return fmt.Errorf("task %s timeout must be at least 1s, got %s", task.Name, task.Timeout.Duration)
+		}
 	}
-	if err := dag.checkNoCycles(); err != nil {
-		return err
+
+	return nil
+}
+
+// checkCaches ensures cached results live for whole seconds and are only kept for tasks that run a pod.
+func (dag *DAG) checkCaches() error {
+	for _, task := range dag.Spec.Task {
+		if task.Cache == nil {
+			continue
+		}
+
+		if task.Cache.TTL.Duration < time.Second {
+			return fmt.Errorf("task %s cache ttl must be at least 1s, got %s", task.Name, task.Cache.TTL.Duration)
+		}
+
+		if task.DagRef != nil {
+			return fmt.Errorf("task %s cannot cache the result of a dagRef", task.Name)
+		}
 	}
-	if err := dag.checkRunAfterTasksExist(); err != nil {
-		return err
+
+	return nil
+}
+
+// checkArtifacts ensures the artifacts of every task are valid and only declared by tasks that
+// run a pod. A cached run starts no pod, so it would have nothing to publish
+func (dag *DAG) checkArtifacts() error {
+	for _, task := range dag.Spec.Task {
+		if len(task.Artifacts) == 0 {
+			continue
+		}
+
+		if task.DagRef != nil {
+			return fmt.Errorf("task %s cannot declare artifacts alongside dagRef", task.Name)
+		}
+
+		if task.Cache != nil {
+			return fmt.Errorf("task %s cannot declare artifacts alongside cache", task.Name)
+		}
+
+		if err := ValidateArtifacts(task.Name, task.Artifacts); err != nil {
+			return err
+		}
 	}
-	if err := dag.checkAllTasksConnected(); err != nil {
-		return err
+
+	return nil
+}
+
+// CheckArtifactsConfigured ensures no task declares artifacts while the controller has no artifacts
+// image configured, as the pods of those tasks could never be created
+func (dag *DAG) CheckArtifactsConfigured(configured bool) error {
+	if configured {
+		return nil
 	}
-	if err := dag.checkStartingTask(); err != nil {
-		return err
+
+	for _, task := range dag.Spec.Task {
+		if len(task.Artifacts) > 0 {
+			return fmt.Errorf("task %s declares artifacts but artifacts are not configured", task.Name)
+		}
 	}
 
 	return nil
 }
 
-// checkFieldsFilled ensures all necessary fields in the DAG are filled.
-func (dag *DAG) checkFieldsFilled() error {
-	taskNames := make(map[string]bool)
-
+// checkPools ensures pool slots are only asked for by tasks that run a pod in a pool.
+func (dag *DAG) checkPools() error {
 	for _, task := range dag.Spec.Task {
-		if task.Name == "" {
-			return errors.New("task name must be specified")
+		if task.Pool == "" {
+			if task.PoolSlots != 0 {
+				return fmt.Errorf("task %s sets poolSlots without a pool", task.Name)
+			}
+			continue
 		}
 
-		if task.TaskRef != nil {
-			// We ignore any further checks as they will not be used
-			// Previous check will have confirmed if this task exists or not
+		if task.DagRef != nil {
+			return fmt.Errorf("task %s cannot take pool slots alongside dagRef", task.Name)
+		}
+
+		if task.PoolSlots < 0 {
+			return fmt.Errorf("task %s poolSlots must be at least 1, got %d", task.Name, task.PoolSlots)
+		}
+	}
+
+	return nil
+}
+
+// checkSensors ensures sensor tasks only describe the condition they wait for. The worker checks
+// it without a pod, so there is nothing to run, publish or retry
+func (dag *DAG) checkSensors() error {
+	for _, task := range dag.Spec.Task {
+		if task.Sensor == nil {
 			continue
 		}
 
-		// Either have a script or command
-		if len(task.Script) == 0 && len(task.Command) == 0 {
-			return errors.New("must provide a script or a command")
+		if task.Image != "" || len(task.Command) > 0 || len(task.Args) > 0 || task.Script != "" || task.PodTemplate != nil {
+			return fmt.Errorf("task %s cannot set an image, command, args, script or podTemplate alongside sensor", task.Name)
 		}
 
-		if task.Image == "" {
-			return errors.New("task image must be specified")
+		if task.TaskRef != nil || task.DagRef != nil || task.Map != nil {
+			return fmt.Errorf("task %s cannot set a taskRef, dagRef or map alongside sensor", task.Name)
 		}
 
-		if _, exists := taskNames[task.Name]; exists {
-			return errors.New("duplicate task name: " + task.Name)
+		if len(task.Outputs) > 0 || len(task.Artifacts) > 0 || task.Cache != nil || task.Backoff.Limit > 0 {
+			return fmt.Errorf("task %s cannot declare outputs, artifacts, cache or a backoff limit alongside sensor", task.Name)
 		}
 
-		taskNames[task.Name] = true
+		if err := task.Sensor.Validate(); err != nil {
+			return fmt.Errorf("task %s sensor: %w", task.Name, err)
+		}
+
+		// a templated namespace is checked by the worker once it is expanded
+		if r := task.Sensor.Resource; r != nil && r.Namespace != "" && dag.Namespace != "" && !strings.Contains(r.Namespace, "{{") && r.Namespace != dag.Namespace {
+			return fmt.Errorf("task %s sensor can only watch resources in the namespace of the DAG", task.Name)
+		}
 	}
 
 	return nil
 }
 
-// checkNoCycles ensures there are no cyclic dependencies in the tasks.
-func (dag *DAG) checkNoCycles() error {
-	visited := make(map[string]bool)
-	recStack := make(map[string]bool)
+// checkCompletionTriggers ensures onDagCompletion triggers name another DAG and an outcome,
+// and only fill parameters this DAG declares. Loops through other DAGs are found by CheckCompletionTriggerLoops
+func (dag *DAG) checkCompletionTriggers() error {
+	if dag.Spec.Triggers == nil {
+		return nil
+	}
 
-	var visit func(string) bool
-	visit = func(name string) bool {
-		if recStack[name] {
-			return true // cycle detected
+	params := map[string]bool{}
+	for _, param := range dag.Spec.Parameters {
+		params[param.Name] = true
+	}
+
+	seen := map[types.NamespacedName]bool{}
+	for _, trigger := range dag.Spec.Triggers.OnDagCompletion {
+		if trigger.DagName == "" {
+			return errors.New("onDagCompletion trigger must name a DAG")
 		}
-		if visited[name] {
-			return false
+
+		upstream := types.NamespacedName{Namespace: trigger.GetNamespace(dag.Namespace), Name: trigger.DagName}
+		if upstream.Name == dag.Name && upstream.Namespace == dag.Namespace {
+			return errors.New("onDagCompletion trigger cannot wait for the DAG it belongs to")
 		}
-		visited[name] = true
-		recStack[name] = true
 
-		for _, task := range dag.Spec.Task {
-			if task.Name == name {
-				for _, dep := range task.RunAfter {
-					if visit(dep) {
-						return true
-					}
-				}
-			}
+		if seen[upstream] {
+			return fmt.Errorf("duplicate onDagCompletion trigger for DAG %s", upstream)
 		}
-		recStack[name] = false
-		return false
-	}
+		seen[upstream] = true
 
-	for _, task := range dag.Spec.Task {
-		if visit(task.Name) {
-			return errors.New("cyclic dependency detected")
+		switch trigger.GetOutcome() {
+		case DagCompletionOutcomeSuccess, DagCompletionOutcomeFailed, DagCompletionOutcomeAny:
+		default:
+			return fmt.Errorf("onDagCompletion trigger for DAG %s has invalid outcome: %s", upstream, trigger.Outcome)
+		}
+
+		set := map[string]bool{}
+		for _, param := range trigger.Parameters {
+			if param.Name == "" {
+				return fmt.Errorf("onDagCompletion trigger for DAG %s has a parameter with an empty name", upstream)
+			}
+
+			if set[param.Name] {
+				return fmt.Errorf("onDagCompletion trigger for DAG %s has duplicate parameter: %s", upstream, param.Name)
+			}
+			set[param.Name] = true
+
+			if !params[param.Name] {
+				return fmt.Errorf("onDagCompletion trigger for DAG %s sets unknown parameter: %s", upstream, param.Name)
+			}
+
+			if (param.Value == "") == (param.FromParameter == "") {
+				return fmt.Errorf("onDagCompletion trigger for DAG %s parameter %s must set exactly one of value or fromParameter", upstream, param.Name)
+			}
 		}
 	}
+
 	return nil
 }
 
-// checkRunAfterTasksExist ensures that all runAfter references point to existing tasks.
-func (dag *DAG) checkRunAfterTasksExist() error {
-	taskMap := make(map[string]bool)
-	for _, task := range dag.Spec.Task {
-		taskMap[task.Name] = true
+// CheckCompletionTriggerLoops ensures the onDagCompletion triggers of the DAG do not make it start
+// itself through other DAGs. upstreams holds, for every stored DAG, the DAGs it waits for
+func (dag *DAG) CheckCompletionTriggerLoops(namespace string, upstreams map[types.NamespacedName][]types.NamespacedName) error {
+	if dag.Spec.Triggers == nil || len(dag.Spec.Triggers.OnDagCompletion) == 0 {
+		return nil
 	}
+
+	// the triggers of this DAG replace the stored ones
+	own := make([]types.NamespacedName, 0, len(dag.Spec.Triggers.OnDagCompletion))
+	for _, trigger := range dag.Spec.Triggers.OnDagCompletion {
+		own = append(own, types.NamespacedName{Namespace: trigger.GetNamespace(namespace), Name: trigger.DagName})
+	}
+
+	chain := findLoop(types.NamespacedName{Namespace: namespace, Name: dag.Name}, own, upstreams)
+	if chain == nil {
+		return nil
+	}
+	return fmt.Errorf("onDagCompletion triggers form a loop: %s", joinChain(chain, " waits for "))
+}
+
+// CheckDagRefLoops ensures the dagRef tasks of the DAG do not make it run itself through other DAGs.
+// children holds, for every stored DAG, the DAGs its dagRef tasks run
+func (dag *DAG) CheckDagRefLoops(namespace string, children map[types.NamespacedName][]types.NamespacedName) error {
+	// the dagRef tasks of this DAG replace the stored ones
+	own := []types.NamespacedName{}
 	for _, task := range dag.Spec.Task {
-		for _, dep := range task.RunAfter {
-			if !taskMap[dep] {
-				return fmt.Errorf("task %s has runAfter dependency on non-existent task %s", task.Name, dep)
-			}
+		if task.DagRef != nil {
+			own = append(own, types.NamespacedName{Namespace: namespace, Name: task.DagRef.Name})
 		}
 	}
-	return nil
+
+	if len(own) == 0 {
+		return nil
+	}
+
+	chain := findLoop(types.NamespacedName{Namespace: namespace, Name: dag.Name}, own, children)
+	if chain == nil {
+		return nil
+	}
+	return fmt.Errorf("dagRef tasks form a loop: %s", joinChain(chain, " runs "))
 }
 
-// checkAllTasksConnected ensures that all tasks are part of a single connected component.
-func (dag *DAG) checkAllTasksConnected() error {
-	adjList := make(map[string][]string)
-	taskMap := make(map[string]bool)
-	for _, task := range dag.Spec.Task {
-		taskMap[task.Name] = true
-		adjList[task.Name] = append(adjList[task.Name], task.RunAfter...)
-		for _, dep := range task.RunAfter {
-			adjList[dep] = append(adjList[dep], task.Name)
+// findLoop follows the edges from self depth first, own replacing the stored edges of self,
+// and returns the path back to self if there is one
+func findLoop(self types.NamespacedName, own []types.NamespacedName, edges map[types.NamespacedName][]types.NamespacedName) []types.NamespacedName {
+	next := func(name types.NamespacedName) []types.NamespacedName {
+		if name == self {
+			return own
 		}
+		return edges[name]
 	}
 
-	visited := make(map[string]bool)
-	var dfs func(string)
-	dfs = func(name string) {
-		visited[name] = true
-		for _, neighbor := range adjList[name] {
-			if !visited[neighbor] {
-				dfs(neighbor)
+	visited := map[types.NamespacedName]bool{}
+	var chain []types.NamespacedName
+	var visit func(types.NamespacedName) bool
+	visit = func(name types.NamespacedName) bool {
+		chain = append(chain, name)
+		for _, to := range next(name) {
+			if to == self {
+				chain = append(chain, to)
+				return true
+			}
+
+			if visited[to] {
+				continue
+			}
+			visited[to] = true
+
+			if visit(to) {
+				return true
 			}
 		}
+		chain = chain[:len(chain)-1]
+		return false
 	}
 
-	var startNode string
-	// Get first node
-	for name := range taskMap {
-		startNode = name
-		break
+	if !visit(self) {
+		return nil
 	}
+	return chain
+}
 
-	dfs(startNode)
+func joinChain(chain []types.NamespacedName, sep string) string {
+	names := make([]string, len(chain))
+	for i, name := range chain {
+		names[i] = name.String()
+	}
+	return strings.Join(names, sep)
+}
 
-	for task := range taskMap {
-		if !visited[task] {
-			return errors.New("not all tasks are connected")
+// checkBackoffs ensures the retry delays of every task are valid.
+func (dag *DAG) checkBackoffs() error {
+	for _, task := range dag.Spec.Task {
+		if err := task.Backoff.Validate(); err != nil {
+			return fmt.Errorf("task %s backoff: %w", task.Name, err)
 		}
 	}
+
 	return nil
 }
 
-// checkStartingTask ensures there is at least one task that has no runAfter dependencies.
-func (dag *DAG) checkStartingTask() error {
+// checkPodTemplates ensures the extra containers of every task are valid.
+func (dag *DAG) checkPodTemplates() error {
 	for _, task := range dag.Spec.Task {
-		if len(task.RunAfter) == 0 {
-			return nil
+		if err := task.PodTemplate.Validate(task.Name); err != nil {
+			return fmt.Errorf("task %s podTemplate: %w", task.Name, err)
 		}
 	}
-	return errors.New("no starting task found (a task with no runAfter dependencies)")
+
+	return nil
 }
 
-// checkParameters ensures there is at least one task that has no runAfter dependencies.
-func (dag *DAG) checkParameters(refParams map[TaskRef][]string) error {
-	paramsMap := map[string]bool{}
-	for _, value := range dag.Spec.Parameters {
-		if value.Name == "" {
-			return fmt.Errorf("parameter has an empty name")
+// checkConcurrency ensures maxActiveRuns is only set alongside a policy that enforces it.
+func (dag *DAG) checkConcurrency() error {
+	switch dag.Spec.ConcurrencyPolicy {
+	case "", ConcurrencyPolicyAllow:
+		if dag.Spec.MaxActiveRuns != 0 {
+			return fmt.Errorf("maxActiveRuns requires a concurrencyPolicy of Forbid, Replace or Queue")
 		}
+	case ConcurrencyPolicyForbid, ConcurrencyPolicyReplace, ConcurrencyPolicyQueue:
+	default:
+		return fmt.Errorf("unknown concurrencyPolicy %s", dag.Spec.ConcurrencyPolicy)
+	}
 
-		if value.DefaultValue == "" && value.DefaultFromSecret == "" {
-			return fmt.Errorf("parameter does not provide defaultValue or defaultFromSecret")
-		}
+	if dag.Spec.MaxActiveRuns < 0 {
+		return fmt.Errorf("maxActiveRuns must not be negative, got %d", dag.Spec.MaxActiveRuns)
+	}
 
-		if value.DefaultValue != "" && value.DefaultFromSecret != "" {
-			return fmt.Errorf("parameter does not provide defaultValue or defaultFromSecret")
-		}
+	return nil
+}
 
-		paramsMap[value.Name] = true
+// checkCatchup ensures catchup and backfill are only set on scheduled DAGs, with a backfill range that ends after it starts.
+func (dag *DAG) checkCatchup() error {
+	if dag.Spec.MaxCatchupRuns < 0 {
+		return fmt.Errorf("maxCatchupRuns must not be negative, got %d", dag.Spec.MaxCatchupRuns)
 	}
 
-	for _, task := range dag.Spec.Task {
-		if task.TaskRef != nil {
-			values, ok := refParams[*task.TaskRef]
-			if !ok {
-				continue
-			}
+	if dag.Spec.MaxCatchupRuns != 0 && !dag.Spec.Catchup {
+		return fmt.Errorf("maxCatchupRuns requires catchup to be enabled")
+	}
 
-			for _, value := range values {
-				if _, ok := paramsMap[value]; !ok {
-					return fmt.Errorf("parameter selected in task does not exist")
-				}
-			}
-		}
+	if dag.Spec.Schedule == "" && dag.Spec.Catchup {
+		return fmt.Errorf("catchup requires a schedule")
+	}
 
-		for _, value := range task.Parameters {
-			if _, ok := paramsMap[value]; !ok {
-				return fmt.Errorf("parameter selected in task does not exist")
-			}
+	if dag.Spec.Backfill == nil {
+		return nil
+	}
+
+	if dag.Spec.Schedule == "" {
+		return fmt.Errorf("backfill requires a schedule")
+	}
+
+	if dag.Spec.Backfill.End.Before(&dag.Spec.Backfill.Start) {
+		return fmt.Errorf("backfill end %s is before its start %s", dag.Spec.Backfill.End.Format(time.RFC3339), dag.Spec.Backfill.Start.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// checkScheduleWindow ensures the time zone is known and the schedule window is only set on scheduled DAGs, ending after it starts.
+func (dag *DAG) checkScheduleWindow() error {
+	if dag.Spec.Timezone != "" {
+		if _, err := time.LoadLocation(dag.Spec.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %s: %w", dag.Spec.Timezone, err)
 		}
 	}
 
+	if dag.Spec.StartTime == nil && dag.Spec.EndTime == nil {
+		return nil
+	}
+
+	if dag.Spec.Schedule == "" {
+		return fmt.Errorf("startTime and endTime require a schedule")
+	}
+
+	if dag.Spec.StartTime != nil && dag.Spec.EndTime != nil && dag.Spec.EndTime.Before(dag.Spec.StartTime) {
+		return fmt.Errorf("endTime %s is before startTime %s", dag.Spec.EndTime.Format(time.RFC3339), dag.Spec.StartTime.Format(time.RFC3339))
+	}
+
 	return nil
 }
@@ -1,12 +1,20 @@
 package v1alpha1_test
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"kontroler-controller/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestValidateDAG(t *testing.T) {
+	one, five := int64(1), int64(5)
+
 	tests := []struct {
 		name    string
 		dag     v1alpha1.DAG
@@ -320,175 +328,2171 @@ func TestValidateDAG(t *testing.T) {
 			},
 			wantErr: false,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.dag.ValidateDAG(map[v1alpha1.TaskRef][]string{}); (err != nil) != tt.wantErr {
-				t.Errorf("ValidateDAG() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestValidateDAG_taskRef(t *testing.T) {
-	tests := []struct {
-		name      string
-		dag       v1alpha1.DAG
-		refParams map[v1alpha1.TaskRef][]string
-		wantErr   bool
-	}{
 		{
-			name: "valid single task DAG",
+			name: "valid outputs",
 			dag: v1alpha1.DAG{
 				Spec: v1alpha1.DAGSpec{
-					Schedule: "*/5 * * * *",
-					Parameters: []v1alpha1.DagParameterSpec{
+					Task: []v1alpha1.TaskSpec{
 						{
-							Name:         "paramOne",
-							DefaultValue: "one",
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"rowCount", "file_name"},
 						},
 					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid output name",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
 					Task: []v1alpha1.TaskSpec{
 						{
-							Name: "task1",
-							TaskRef: &v1alpha1.TaskRef{
-								Name:    "first",
-								Version: 1,
-							},
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"row-count"},
 						},
 					},
 				},
 			},
-			refParams: map[v1alpha1.TaskRef][]string{
-				{
-					Name:    "first",
-					Version: 1,
-				}: {"paramOne"},
-			},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
-			name: "invalid single task DAG",
+			name: "duplicate output name",
 			dag: v1alpha1.DAG{
 				Spec: v1alpha1.DAGSpec{
-					Schedule: "*/5 * * * *",
-					Parameters: []v1alpha1.DagParameterSpec{
+					Task: []v1alpha1.TaskSpec{
 						{
-							Name:         "paramOne",
-							DefaultValue: "one",
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"rowCount", "rowCount"},
 						},
 					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "outputs of different tasks passed as the same env var",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
 					Task: []v1alpha1.TaskSpec{
 						{
-							Name: "task1",
-							TaskRef: &v1alpha1.TaskRef{
-								Name:    "first",
-								Version: 1,
-							},
+							Name:    "fetch-data",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"rowCount"},
+						},
+						{
+							Name:     "fetch_data",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							Outputs:  []string{"rowcount"},
+							RunAfter: []string{"fetch-data"},
 						},
 					},
 				},
 			},
-			refParams: map[v1alpha1.TaskRef][]string{
-				{
-					Name:    "first",
-					Version: 1,
-				}: {"paramTwo"},
-			},
 			wantErr: true,
 		},
 		{
-			name: "valid multiple task DAG",
+			name: "parameter using the output env prefix",
 			dag: v1alpha1.DAG{
 				Spec: v1alpha1.DAGSpec{
-					Schedule: "*/5 * * * *",
 					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "OUTPUT_TASK1_ROWCOUNT", DefaultValue: "0"},
+					},
+					Task: []v1alpha1.TaskSpec{
 						{
-							Name:         "paramOne",
-							DefaultValue: "one",
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"rowCount"},
 						},
 					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pod template env var using the output env prefix",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
 					Task: []v1alpha1.TaskSpec{
 						{
-							Name: "task1",
-							TaskRef: &v1alpha1.TaskRef{
-								Name:    "first",
-								Version: 1,
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							PodTemplate: &v1alpha1.PodTemplateSpec{
+								Env: []v1alpha1.EnvVar{{Name: "OUTPUT_UPSTREAM_ROWCOUNT", Value: "0"}},
 							},
 						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid when expression",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "env", DefaultValue: "dev"},
+					},
+					Task: []v1alpha1.TaskSpec{
 						{
-							Name: "task2",
-							TaskRef: &v1alpha1.TaskRef{
-								Name:    "second",
-								Version: 1,
-							},
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"rowCount"},
+						},
+						{
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
 							RunAfter: []string{"task1"},
+							When:     `params.env == "prod" && tasks.task1.outputs.rowCount > 0`,
 						},
 					},
 				},
 			},
-			refParams: map[v1alpha1.TaskRef][]string{
-				{
-					Name:    "first",
-					Version: 1,
-				}: {"paramOne"},
-				{
-					Name:    "second",
-					Version: 1,
-				}: {"paramOne"},
-			},
 			wantErr: false,
 		},
 		{
-			name: "invalid multiple task DAG - missing parameters",
+			name: "when expression does not parse",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							When:    `env ==`,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "when expression references unknown parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							When:    `params.env == "prod"`,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "when expression references secret parameter",
 			dag: v1alpha1.DAG{
 				Spec: v1alpha1.DAGSpec{
-					Schedule: "*/5 * * * *",
 					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "token", DefaultFromSecret: "token-secret"},
+					},
+					Task: []v1alpha1.TaskSpec{
 						{
-							Name:         "paramOne",
-							DefaultValue: "one",
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							When:    `params.token == "abc"`,
 						},
 					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "when expression references task that is not upstream",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
 					Task: []v1alpha1.TaskSpec{
 						{
-							Name: "task1",
-							TaskRef: &v1alpha1.TaskRef{
-								Name:    "first",
-								Version: 1,
-							},
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
 						},
 						{
-							Name: "task2",
-							TaskRef: &v1alpha1.TaskRef{
-								Name:    "second",
-								Version: 1,
-							},
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							RunAfter: []string{"task1"},
+						},
+						{
+							Name:     "task3",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
 							RunAfter: []string{"task1"},
+							When:     `tasks.task2.status == "success"`,
 						},
 					},
 				},
 			},
-			refParams: map[v1alpha1.TaskRef][]string{
-				{
-					Name:    "first",
-					Version: 1,
-				}: {"paramOne"},
-				{
-					Name:    "second",
-					Version: 1,
-				}: {"paramTwo"},
+			wantErr: true,
+		},
+		{
+			name: "valid trigger rule",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:        "task2",
+							Command:     []string{"sh", "-c"},
+							Image:       "alpine:latest",
+							RunAfter:    []string{"task1"},
+							TriggerRule: v1alpha1.TriggerRuleAllDone,
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown trigger rule",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:        "task2",
+							Command:     []string{"sh", "-c"},
+							Image:       "alpine:latest",
+							RunAfter:    []string{"task1"},
+							TriggerRule: "any",
+						},
+					},
+				},
 			},
 			wantErr: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.dag.ValidateDAG(tt.refParams); (err != nil) != tt.wantErr {
-				t.Errorf("ValidateDAG() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
+		{
+			name: "trigger rule on starting task",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:        "task2",
+							Command:     []string{"sh", "-c"},
+							Image:       "alpine:latest",
+							TriggerRule: v1alpha1.TriggerRuleOneFailed,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid map over parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "items", DefaultValue: "a,b"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							RunAfter: []string{"task1"},
+							Map:      &v1alpha1.MapSpec{Parameter: "items", MaxParallelism: 2},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid map over upstream output",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"files"},
+						},
+						{
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							RunAfter: []string{"task1"},
+							Map:      &v1alpha1.MapSpec{Output: "task1.files"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "map with both parameter and output",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "items", DefaultValue: "a,b"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+							Outputs: []string{"files"},
+						},
+						{
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							RunAfter: []string{"task1"},
+							Map:      &v1alpha1.MapSpec{Parameter: "items", Output: "task1.files"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "map over unknown parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							RunAfter: []string{"task1"},
+							Map:      &v1alpha1.MapSpec{Parameter: "items"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "map over undeclared output",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:     "task2",
+							Command:  []string{"sh", "-c"},
+							Image:    "alpine:latest",
+							RunAfter: []string{"task1"},
+							Map:      &v1alpha1.MapSpec{Output: "task1.files"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid dagRef",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "env", DefaultValue: "prod"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Command: []string{"sh", "-c"},
+							Image:   "alpine:latest",
+						},
+						{
+							Name:     "task2",
+							RunAfter: []string{"task1"},
+							DagRef: &v1alpha1.DagRef{
+								Name: "child",
+								Parameters: []v1alpha1.DagRefParameter{
+									{Name: "environment", FromParameter: "env"},
+									{Name: "region", Value: "eu-west-1"},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "dagRef with an image",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:   "task1",
+							Image:  "alpine:latest",
+							DagRef: &v1alpha1.DagRef{Name: "child"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dagRef from unknown parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							DagRef: &v1alpha1.DagRef{
+								Name:       "child",
+								Parameters: []v1alpha1.DagRefParameter{{Name: "env", FromParameter: "env"}},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dagRef parameter with value and fromParameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "env", DefaultValue: "prod"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							DagRef: &v1alpha1.DagRef{
+								Name:       "child",
+								Parameters: []v1alpha1.DagRefParameter{{Name: "env", Value: "dev", FromParameter: "env"}},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dagRef to itself",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "parent"},
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:   "task1",
+							DagRef: &v1alpha1.DagRef{Name: "parent"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid timeouts",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					RunTimeout: &metav1.Duration{Duration: time.Hour},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Timeout: &metav1.Duration{Duration: 10 * time.Minute},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "task timeout below a second",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Timeout: &metav1.Duration{Duration: 500 * time.Millisecond},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative run timeout",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					RunTimeout: &metav1.Duration{Duration: -time.Minute},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid cache",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"echo", "hello"},
+							Cache:   &v1alpha1.CacheSpec{TTL: metav1.Duration{Duration: 24 * time.Hour}},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cache ttl below a second",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"echo", "hello"},
+							Cache:   &v1alpha1.CacheSpec{},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cache on a dagRef task",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "parent"},
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:   "child",
+							DagRef: &v1alpha1.DagRef{Name: "child"},
+							Cache:  &v1alpha1.CacheSpec{TTL: metav1.Duration{Duration: time.Hour}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid artifacts",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							Artifacts: []v1alpha1.ArtifactSpec{{Name: "data", Path: "/tmp/data"}, {Name: "report_v2", Path: "/out/report.html"}},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "artifact with an invalid name",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							Artifacts: []v1alpha1.ArtifactSpec{{Name: "my data", Path: "/tmp/data"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate artifact",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							Artifacts: []v1alpha1.ArtifactSpec{{Name: "data", Path: "/tmp/a"}, {Name: "data", Path: "/tmp/b"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "artifact with a relative path",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							Artifacts: []v1alpha1.ArtifactSpec{{Name: "data", Path: "tmp/data"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "artifacts on a cached task",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							Artifacts: []v1alpha1.ArtifactSpec{{Name: "data", Path: "/tmp/data"}},
+							Cache:     &v1alpha1.CacheSpec{TTL: metav1.Duration{Duration: time.Hour}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid pool",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							Pool:      "warehouse",
+							PoolSlots: 2,
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "pool slots without a pool",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:      "task1",
+							Image:     "alpine:latest",
+							Command:   []string{"echo", "hello"},
+							PoolSlots: 2,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pool on a dagRef task",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:   "task1",
+							Pool:   "warehouse",
+							DagRef: &v1alpha1.DagRef{Name: "child"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid http sensor",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Interval: &metav1.Duration{Duration: 10 * time.Second},
+								Timeout:  metav1.Duration{Duration: time.Hour},
+								HTTP:     &v1alpha1.HTTPSensor{URL: "https://example.com/{{ run.id }}/ready", StatusCodes: []int{200, 204}, BodyMatch: "ready"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid resource sensor",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout:  metav1.Duration{Duration: time.Hour},
+								Resource: &v1alpha1.ResourceSensor{APIVersion: "batch/v1", Kind: "Job", Name: "load", Condition: "Complete"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "resource sensor in another namespace",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"},
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout:  metav1.Duration{Duration: time.Hour},
+								Resource: &v1alpha1.ResourceSensor{APIVersion: "batch/v1", Kind: "Job", Name: "load", Namespace: "team-b"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor with an image",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:  "task1",
+							Image: "alpine:latest",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout: metav1.Duration{Duration: time.Hour},
+								Object:  &v1alpha1.ObjectSensor{Key: "incoming/data.csv"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor with retries",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Backoff: v1alpha1.Backoff{Limit: 2},
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout: metav1.Duration{Duration: time.Hour},
+								Object:  &v1alpha1.ObjectSensor{Key: "incoming/data.csv"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor without a condition",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout: metav1.Duration{Duration: time.Hour},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor with two conditions",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout: metav1.Duration{Duration: time.Hour},
+								HTTP:    &v1alpha1.HTTPSensor{URL: "https://example.com/ready"},
+								Object:  &v1alpha1.ObjectSensor{Key: "incoming/data.csv"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor without a timeout",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Object: &v1alpha1.ObjectSensor{Key: "incoming/data.csv"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http sensor with an invalid url",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout: metav1.Duration{Duration: time.Hour},
+								HTTP:    &v1alpha1.HTTPSensor{URL: "example.com/ready"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http sensor with an invalid body match",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							Sensor: &v1alpha1.SensorSpec{
+								Timeout: metav1.Duration{Duration: time.Hour},
+								HTTP:    &v1alpha1.HTTPSensor{URL: "https://example.com/ready", BodyMatch: "("},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid onDagCompletion trigger",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "transform", Namespace: "etl", Outcome: v1alpha1.DagCompletionOutcomeAny, Parameters: []v1alpha1.DagRefParameter{{Name: "date", FromParameter: "date"}}},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "onDagCompletion trigger without a DAG",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{Outcome: v1alpha1.DagCompletionOutcomeSuccess},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "onDagCompletion trigger waiting for its own DAG",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "report"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "onDagCompletion trigger waiting for a DAG of the same name in another namespace",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "report", Namespace: "etl"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "duplicate onDagCompletion triggers",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "transform"},
+							{DagName: "transform", Namespace: "default", Outcome: v1alpha1.DagCompletionOutcomeFailed},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "onDagCompletion trigger with an invalid outcome",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "transform", Outcome: "skipped"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "onDagCompletion trigger setting an unknown parameter",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "transform", Parameters: []v1alpha1.DagRefParameter{{Name: "region", Value: "eu"}}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "onDagCompletion trigger parameter with a value and fromParameter",
+			dag: v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "today"}},
+					Task: []v1alpha1.TaskSpec{
+						{Name: "task1", Image: "alpine:latest", Command: []string{"echo"}},
+					},
+					Triggers: &v1alpha1.DagTriggers{
+						OnDagCompletion: []v1alpha1.DagCompletionTrigger{
+							{DagName: "transform", Parameters: []v1alpha1.DagRefParameter{{Name: "date", Value: "today", FromParameter: "date"}}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid retry backoff",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Backoff: v1alpha1.Backoff{
+								Limit:        3,
+								InitialDelay: &metav1.Duration{Duration: 10 * time.Second},
+								MaxDelay:     &metav1.Duration{Duration: 5 * time.Minute},
+								Multiplier:   "1.5",
+								Jitter:       "0.2",
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "backoff multiplier without an initial delay",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Backoff: v1alpha1.Backoff{
+								Limit:      3,
+								Multiplier: "2",
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backoff max delay below the initial delay",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Backoff: v1alpha1.Backoff{
+								Limit:        3,
+								InitialDelay: &metav1.Duration{Duration: time.Minute},
+								MaxDelay:     &metav1.Duration{Duration: 10 * time.Second},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backoff multiplier below one",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Backoff: v1alpha1.Backoff{
+								Limit:        3,
+								InitialDelay: &metav1.Duration{Duration: time.Minute},
+								Multiplier:   "0.5",
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backoff jitter above one",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+							Backoff: v1alpha1.Backoff{
+								Limit:        3,
+								InitialDelay: &metav1.Duration{Duration: time.Minute},
+								Jitter:       "1.5",
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid concurrency policy",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					ConcurrencyPolicy: v1alpha1.ConcurrencyPolicyQueue,
+					MaxActiveRuns:     2,
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "maxActiveRuns without a concurrency policy",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					MaxActiveRuns: 2,
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown concurrency policy",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					ConcurrencyPolicy: "Sometimes",
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid catchup and backfill",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule:       "0 * * * *",
+					Catchup:        true,
+					MaxCatchupRuns: 5,
+					Backfill: &v1alpha1.Backfill{
+						Start: metav1.NewTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+						End:   metav1.NewTime(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)),
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "maxCatchupRuns without catchup",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule:       "0 * * * *",
+					MaxCatchupRuns: 5,
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backfill without a schedule",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Backfill: &v1alpha1.Backfill{
+						Start: metav1.NewTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+						End:   metav1.NewTime(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)),
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backfill ending before it starts",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "0 * * * *",
+					Backfill: &v1alpha1.Backfill{
+						Start: metav1.NewTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+						End:   metav1.NewTime(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)),
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid timezone and schedule window",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule:  "0 9 * * *",
+					Timezone:  "Europe/London",
+					StartTime: &metav1.Time{Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+					EndTime:   &metav1.Time{Time: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown timezone",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "0 9 * * *",
+					Timezone: "Mars/Olympus_Mons",
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "endTime before startTime",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule:  "0 9 * * *",
+					StartTime: &metav1.Time{Time: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+					EndTime:   &metav1.Time{Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"sleep", "60"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "typed parameters",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "count", DefaultValue: "3", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeInt, Min: &one, Max: &five}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$count"},
+							Parameters: []string{"count"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "parameters read from secret and configMap keys",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "token", DefaultFromSecret: "creds/api-token"},
+						{Name: "password", DefaultSecretKeyRef: &v1alpha1.KeyRef{Name: "creds"}},
+						{Name: "region", DefaultConfigMapKeyRef: &v1alpha1.KeyRef{Name: "settings", Key: "region"}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$region"},
+							Parameters: []string{"token", "password", "region"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "configMap parameter without a key",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "region", DefaultConfigMapKeyRef: &v1alpha1.KeyRef{Name: "settings"}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$region"},
+							Parameters: []string{"region"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "parameter with a secret and a configMap default",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "region", DefaultFromSecret: "creds", DefaultConfigMapKeyRef: &v1alpha1.KeyRef{Name: "settings", Key: "region"}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$region"},
+							Parameters: []string{"region"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "templates in the command",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "region", DefaultValue: "eu-west-1"},
+						{Name: "token", DefaultFromSecret: "creds"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"echo", "{{ params.region }} {{ run.id }} {{ run.logicalTime }} {{ task.name }}"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "template references unknown parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "region", DefaultValue: "eu-west-1"},
+						{Name: "token", DefaultFromSecret: "creds"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"echo", "{{ params.zone }}"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "template references secret parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "region", DefaultValue: "eu-west-1"},
+						{Name: "token", DefaultFromSecret: "creds"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"echo", "{{ params.token }}"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "template references unknown run field",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "region", DefaultValue: "eu-west-1"},
+						{Name: "token", DefaultFromSecret: "creds"},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:    "task1",
+							Image:   "alpine:latest",
+							Command: []string{"echo", "{{ run.name }}"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "default outside of the parameter bounds",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "count", DefaultValue: "9", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeInt, Max: &five}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$count"},
+							Parameters: []string{"count"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "required parameter",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "count", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeInt, Required: true}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$count"},
+							Parameters: []string{"count"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "required parameter with a default",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "count", DefaultValue: "3", ParameterSchema: v1alpha1.ParameterSchema{Required: true}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$count"},
+							Parameters: []string{"count"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "required parameter on a scheduled DAG",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "*/5 * * * *",
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "count", ParameterSchema: v1alpha1.ParameterSchema{Required: true}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$count"},
+							Parameters: []string{"count"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "enum values without the enum type",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Parameters: []v1alpha1.DagParameterSpec{
+						{Name: "count", DefaultValue: "3", ParameterSchema: v1alpha1.ParameterSchema{Enum: []string{"3", "4"}}},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name:       "task1",
+							Image:      "alpine:latest",
+							Command:    []string{"echo", "$count"},
+							Parameters: []string{"count"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dag.ValidateDAG(map[v1alpha1.TaskRef][]string{}); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDAG() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCompletionTriggerLoops(t *testing.T) {
+	ingest := types.NamespacedName{Namespace: "ingest", Name: "ingestion"}
+	transform := types.NamespacedName{Namespace: "etl", Name: "transform"}
+	report := types.NamespacedName{Namespace: "etl", Name: "report"}
+
+	tests := []struct {
+		name      string
+		triggers  []v1alpha1.DagCompletionTrigger
+		upstreams map[types.NamespacedName][]types.NamespacedName
+		wantErr   bool
+	}{
+		{
+			name:     "chain",
+			triggers: []v1alpha1.DagCompletionTrigger{{DagName: transform.Name}},
+			upstreams: map[types.NamespacedName][]types.NamespacedName{
+				transform: {ingest},
+			},
+		},
+		{
+			name:     "loop through other DAGs",
+			triggers: []v1alpha1.DagCompletionTrigger{{DagName: transform.Name}},
+			upstreams: map[types.NamespacedName][]types.NamespacedName{
+				transform: {ingest},
+				ingest:    {report},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "stored triggers of the DAG are replaced",
+			triggers: []v1alpha1.DagCompletionTrigger{{DagName: transform.Name}},
+			upstreams: map[types.NamespacedName][]types.NamespacedName{
+				report: {ingest},
+				ingest: {report},
+			},
+		},
+		{
+			name:     "waits for itself",
+			triggers: []v1alpha1.DagCompletionTrigger{{DagName: report.Name}},
+			wantErr:  true,
+		},
+		{
+			name:      "no triggers",
+			upstreams: map[types.NamespacedName][]types.NamespacedName{transform: {report}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dag := v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: report.Name, Namespace: report.Namespace},
+				Spec: v1alpha1.DAGSpec{
+					Triggers: &v1alpha1.DagTriggers{OnDagCompletion: tt.triggers},
+				},
+			}
+
+			if err := dag.CheckCompletionTriggerLoops(report.Namespace, tt.upstreams); (err != nil) != tt.wantErr {
+				t.Errorf("CheckCompletionTriggerLoops() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckDagRefLoops(t *testing.T) {
+	parent := types.NamespacedName{Namespace: "etl", Name: "parent"}
+	child := types.NamespacedName{Namespace: "etl", Name: "child"}
+	grandchild := types.NamespacedName{Namespace: "etl", Name: "grandchild"}
+
+	tests := []struct {
+		name     string
+		dagRefs  []string
+		children map[types.NamespacedName][]types.NamespacedName
+		wantErr  bool
+	}{
+		{
+			name:    "chain",
+			dagRefs: []string{child.Name},
+			children: map[types.NamespacedName][]types.NamespacedName{
+				child: {grandchild},
+			},
+		},
+		{
+			name:    "loop through other DAGs",
+			dagRefs: []string{child.Name},
+			children: map[types.NamespacedName][]types.NamespacedName{
+				child:      {grandchild},
+				grandchild: {parent},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "stored dagRefs of the DAG are replaced",
+			dagRefs: []string{grandchild.Name},
+			children: map[types.NamespacedName][]types.NamespacedName{
+				parent: {child},
+				child:  {parent},
+			},
+		},
+		{
+			name:     "no dagRefs",
+			children: map[types.NamespacedName][]types.NamespacedName{child: {parent}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dag := v1alpha1.DAG{
+				ObjectMeta: metav1.ObjectMeta{Name: parent.Name, Namespace: parent.Namespace},
+			}
+			for _, name := range tt.dagRefs {
+				dag.Spec.Task = append(dag.Spec.Task, v1alpha1.TaskSpec{Name: "run-" + name, DagRef: &v1alpha1.DagRef{Name: name}})
+			}
+
+			if err := dag.CheckDagRefLoops(parent.Namespace, tt.children); (err != nil) != tt.wantErr {
+				t.Errorf("CheckDagRefLoops() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDagCompletionOutcome_Matches(t *testing.T) {
+	tests := []struct {
+		outcome v1alpha1.DagCompletionOutcome
+		status  string
+		want    bool
+	}{
+		{v1alpha1.DagCompletionOutcomeSuccess, "success", true},
+		{v1alpha1.DagCompletionOutcomeSuccess, "failed", false},
+		{v1alpha1.DagCompletionOutcomeFailed, "failed", true},
+		{v1alpha1.DagCompletionOutcomeFailed, "timed_out", true},
+		{v1alpha1.DagCompletionOutcomeFailed, "success", false},
+		{v1alpha1.DagCompletionOutcomeAny, "timed_out", true},
+		{v1alpha1.DagCompletionOutcomeAny, "suspended", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.outcome.Matches(tt.status); got != tt.want {
+			t.Errorf("%s.Matches(%s) = %v, want %v", tt.outcome, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDAG_taskRef(t *testing.T) {
+	tests := []struct {
+		name      string
+		dag       v1alpha1.DAG
+		refParams map[v1alpha1.TaskRef][]string
+		wantErr   bool
+	}{
+		{
+			name: "valid single task DAG",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "*/5 * * * *",
+					Parameters: []v1alpha1.DagParameterSpec{
+						{
+							Name:         "paramOne",
+							DefaultValue: "one",
+						},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							TaskRef: &v1alpha1.TaskRef{
+								Name:    "first",
+								Version: 1,
+							},
+						},
+					},
+				},
+			},
+			refParams: map[v1alpha1.TaskRef][]string{
+				{
+					Name:    "first",
+					Version: 1,
+				}: {"paramOne"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid single task DAG",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "*/5 * * * *",
+					Parameters: []v1alpha1.DagParameterSpec{
+						{
+							Name:         "paramOne",
+							DefaultValue: "one",
+						},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							TaskRef: &v1alpha1.TaskRef{
+								Name:    "first",
+								Version: 1,
+							},
+						},
+					},
+				},
+			},
+			refParams: map[v1alpha1.TaskRef][]string{
+				{
+					Name:    "first",
+					Version: 1,
+				}: {"paramTwo"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid multiple task DAG",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "*/5 * * * *",
+					Parameters: []v1alpha1.DagParameterSpec{
+						{
+							Name:         "paramOne",
+							DefaultValue: "one",
+						},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							TaskRef: &v1alpha1.TaskRef{
+								Name:    "first",
+								Version: 1,
+							},
+						},
+						{
+							Name: "task2",
+							TaskRef: &v1alpha1.TaskRef{
+								Name:    "second",
+								Version: 1,
+							},
+							RunAfter: []string{"task1"},
+						},
+					},
+				},
+			},
+			refParams: map[v1alpha1.TaskRef][]string{
+				{
+					Name:    "first",
+					Version: 1,
+				}: {"paramOne"},
+				{
+					Name:    "second",
+					Version: 1,
+				}: {"paramOne"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid multiple task DAG - missing parameters",
+			dag: v1alpha1.DAG{
+				Spec: v1alpha1.DAGSpec{
+					Schedule: "*/5 * * * *",
+					Parameters: []v1alpha1.DagParameterSpec{
+						{
+							Name:         "paramOne",
+							DefaultValue: "one",
+						},
+					},
+					Task: []v1alpha1.TaskSpec{
+						{
+							Name: "task1",
+							TaskRef: &v1alpha1.TaskRef{
+								Name:    "first",
+								Version: 1,
+							},
+						},
+						{
+							Name: "task2",
+							TaskRef: &v1alpha1.TaskRef{
+								Name:    "second",
+								Version: 1,
+							},
+							RunAfter: []string{"task1"},
+						},
+					},
+				},
+			},
+			refParams: map[v1alpha1.TaskRef][]string{
+				{
+					Name:    "first",
+					Version: 1,
+				}: {"paramOne"},
+				{
+					Name:    "second",
+					Version: 1,
+				}: {"paramTwo"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dag.ValidateDAG(tt.refParams); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDAG() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPodTemplateSpec_SecurityContextRoundTrip(t *testing.T) {
+	user, group, fsGroup := int64(1000), int64(3000), int64(2000)
+	nonRoot, readOnly, escalation := true, true, false
+	profile := "profiles/audit.json"
+
+	pt := &v1alpha1.PodTemplateSpec{
+		SecurityContext: &v1alpha1.PodSecurityContext{
+			RunAsUser:          &user,
+			RunAsGroup:         &group,
+			RunAsNonRoot:       &nonRoot,
+			FSGroup:            &fsGroup,
+			SupplementalGroups: []int64{4000},
+			SeccompProfile:     &v1alpha1.SeccompProfile{Type: "RuntimeDefault"},
+		},
+		ContainerSecurityContext: &v1alpha1.SecurityContext{
+			RunAsUser:                &user,
+			RunAsNonRoot:             &nonRoot,
+			ReadOnlyRootFilesystem:   &readOnly,
+			AllowPrivilegeEscalation: &escalation,
+			Capabilities:             &v1alpha1.Capabilities{Add: []string{"NET_BIND_SERVICE"}, Drop: []string{"ALL"}},
+			SeccompProfile:           &v1alpha1.SeccompProfile{Type: "Localhost", LocalhostProfile: &profile},
+		},
+	}
+
+	podSecurityContext := pt.ToK8sParts().SecurityContext
+	if podSecurityContext == nil || *podSecurityContext.RunAsUser != user || *podSecurityContext.RunAsGroup != group || podSecurityContext.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+		t.Fatalf("pod security context was not converted, got %+v", podSecurityContext)
+	}
+
+	containerSecurityContext := pt.ContainerSecurityContext.ToK8s()
+	if containerSecurityContext.Capabilities == nil || containerSecurityContext.Capabilities.Drop[0] != "ALL" || !*containerSecurityContext.ReadOnlyRootFilesystem {
+		t.Fatalf("container security context was not converted, got %+v", containerSecurityContext)
+	}
+
+	got := v1alpha1.PodTemplateSpecFromK8s(
+		&corev1.PodSpec{SecurityContext: podSecurityContext},
+		&corev1.Container{SecurityContext: containerSecurityContext},
+	)
+
+	if !reflect.DeepEqual(pt.SecurityContext, got.SecurityContext) {
+		t.Errorf("pod security context did not round-trip, want %+v, got %+v", pt.SecurityContext, got.SecurityContext)
+	}
+	if !reflect.DeepEqual(pt.ContainerSecurityContext, got.ContainerSecurityContext) {
+		t.Errorf("container security context did not round-trip, want %+v, got %+v", pt.ContainerSecurityContext, got.ContainerSecurityContext)
+	}
+}
+
+func TestPodTemplateSpec_VolumesAndEnvRoundTrip(t *testing.T) {
+	mode, expiration := int32(0400), int64(3600)
+	optional, readOnly := true, true
+
+	pt := &v1alpha1.PodTemplateSpec{
+		Volumes: []v1alpha1.Volume{
+			{
+				Name: "config",
+				ConfigMap: &v1alpha1.ConfigMapVolumeSource{
+					Name:  "app-config",
+					Items: []v1alpha1.KeyToPath{{Key: "settings.yaml", Path: "settings.yaml"}},
+				},
+			},
+			{
+				Name:   "creds",
+				Secret: &v1alpha1.SecretVolumeSource{SecretName: "app-creds", DefaultMode: &mode, Optional: &optional},
+			},
+			{
+				Name: "combined",
+				Projected: &v1alpha1.ProjectedVolumeSource{
+					Sources: []v1alpha1.VolumeProjection{
+						{ConfigMap: &v1alpha1.ConfigMapProjection{Name: "app-config"}},
+						{Secret: &v1alpha1.SecretProjection{Name: "app-creds", Items: []v1alpha1.KeyToPath{{Key: "token", Path: "token", Mode: &mode}}}},
+						{ServiceAccountToken: &v1alpha1.ServiceAccountTokenProjection{Path: "sa-token", Audience: "vault", ExpirationSeconds: &expiration}},
+					},
+				},
+			},
+			{
+				Name: "secrets-store",
+				CSI: &v1alpha1.CSIVolumeSource{
+					Driver:               "secrets-store.csi.k8s.io",
+					ReadOnly:             &readOnly,
+					VolumeAttributes:     map[string]string{"secretProviderClass": "vault"},
+					NodePublishSecretRef: &v1alpha1.LocalObjectReference{Name: "csi-creds"},
+				},
+			},
+		},
+		VolumeMounts: []v1alpha1.VolumeMount{
+			{Name: "config", MountPath: "/etc/app/settings.yaml", SubPath: "settings.yaml", ReadOnly: true},
+		},
+		Env: []v1alpha1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+		EnvFrom: []v1alpha1.EnvFromSource{
+			{ConfigMapRef: &v1alpha1.EnvFromReference{Name: "app-env"}},
+			{Prefix: "DB_", SecretRef: &v1alpha1.EnvFromReference{Name: "db-creds", Optional: &optional}},
+		},
+	}
+
+	parts := pt.ToK8sParts()
+	volumes, volumeMounts, env, envFrom := parts.Volumes, parts.VolumeMounts, parts.Env, parts.EnvFrom
+	if len(volumes) != 4 || volumes[0].ConfigMap == nil || volumes[1].Secret == nil || volumes[2].Projected == nil || volumes[3].CSI == nil {
+		t.Fatalf("volumes were not converted, got %+v", volumes)
+	}
+	if volumes[2].Projected.Sources[2].ServiceAccountToken.Audience != "vault" {
+		t.Fatalf("projected service account token was not converted, got %+v", volumes[2].Projected.Sources[2])
+	}
+	if volumeMounts[0].SubPath != "settings.yaml" {
+		t.Fatalf("subPath was not converted, got %+v", volumeMounts[0])
+	}
+	if len(env) != 1 || env[0].Value != "debug" || len(envFrom) != 2 || envFrom[1].Prefix != "DB_" || envFrom[1].SecretRef.Name != "db-creds" {
+		t.Fatalf("env was not converted, got %+v and %+v", env, envFrom)
+	}
+
+	got := v1alpha1.PodTemplateSpecFromK8s(
+		&corev1.PodSpec{Volumes: volumes},
+		&corev1.Container{VolumeMounts: volumeMounts, Env: env, EnvFrom: envFrom},
+	)
+
+	if !reflect.DeepEqual(pt.Volumes, got.Volumes) {
+		t.Errorf("volumes did not round-trip, want %+v, got %+v", pt.Volumes, got.Volumes)
+	}
+	if !reflect.DeepEqual(pt.VolumeMounts, got.VolumeMounts) {
+		t.Errorf("volume mounts did not round-trip, want %+v, got %+v", pt.VolumeMounts, got.VolumeMounts)
+	}
+	if !reflect.DeepEqual(pt.Env, got.Env) || !reflect.DeepEqual(pt.EnvFrom, got.EnvFrom) {
+		t.Errorf("env did not round-trip, want %+v and %+v, got %+v and %+v", pt.Env, pt.EnvFrom, got.Env, got.EnvFrom)
+	}
+}
+
+func TestPodTemplateSpec_InitContainersAndSidecars(t *testing.T) {
+	pt := &v1alpha1.PodTemplateSpec{
+		Volumes: []v1alpha1.Volume{{Name: "shared", EmptyDir: &v1alpha1.EmptyDirVolumeSource{}}},
+		InitContainers: []v1alpha1.Container{
+			{
+				Name:         "migrate",
+				Image:        "migrate:latest",
+				Command:      []string{"migrate", "up"},
+				VolumeMounts: []v1alpha1.VolumeMount{{Name: "shared", MountPath: "/shared"}},
+			},
+		},
+		Sidecars: []v1alpha1.Container{
+			{
+				Name:      "cloud-sql-proxy",
+				Image:     "gcr.io/cloud-sql-connectors/cloud-sql-proxy:2",
+				Args:      []string{"project:region:instance"},
+				Env:       []v1alpha1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}},
+				Resources: &v1alpha1.ResourceRequirements{Limits: map[string]string{}, Requests: map[string]string{"cpu": "100m"}},
+			},
+		},
+	}
+
+	if err := pt.Validate("task"); err != nil {
+		t.Fatalf("expected the pod template to be valid, got %v", err)
+	}
+
+	containers := pt.InitContainersToK8s()
+	if len(containers) != 2 || containers[0].Name != "migrate" || containers[0].RestartPolicy != nil {
+		t.Fatalf("expected the init container first without a restart policy, got %+v", containers)
+	}
+	if containers[1].RestartPolicy == nil || *containers[1].RestartPolicy != corev1.ContainerRestartPolicyAlways {
+		t.Fatalf("expected the sidecar to be a native sidecar, got %+v", containers[1])
+	}
+
+	// the script copier is not part of the template
+	initContainers := append([]corev1.Container{{Name: v1alpha1.ReservedScriptContainerName}}, containers...)
+	got := v1alpha1.PodTemplateSpecFromK8s(&corev1.PodSpec{InitContainers: initContainers}, &corev1.Container{})
+
+	if !reflect.DeepEqual(pt.InitContainers, got.InitContainers) {
+		t.Errorf("init containers did not round-trip, want %+v, got %+v", pt.InitContainers, got.InitContainers)
+	}
+	if !reflect.DeepEqual(pt.Sidecars, got.Sidecars) {
+		t.Errorf("sidecars did not round-trip, want %+v, got %+v", pt.Sidecars, got.Sidecars)
+	}
+
+	for _, invalid := range []v1alpha1.PodTemplateSpec{
+		{Sidecars: []v1alpha1.Container{{Name: "task", Image: "proxy:latest"}}},
+		{InitContainers: []v1alpha1.Container{{Name: v1alpha1.ReservedScriptContainerName, Image: "busybox"}}},
+		{InitContainers: []v1alpha1.Container{{Name: "setup", Image: "busybox"}}, Sidecars: []v1alpha1.Container{{Name: "setup", Image: "busybox"}}},
+		{Sidecars: []v1alpha1.Container{{Name: "proxy"}}},
+	} {
+		if err := invalid.Validate("task"); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestValidateRunParameters(t *testing.T) {
+	one := int64(1)
+
+	specs := []v1alpha1.DagParameterSpec{
+		{Name: "count", DefaultValue: "3", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeInt, Min: &one}},
+		{Name: "env", DefaultValue: "dev", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeEnum, Enum: []string{"dev", "prod"}}},
+		{Name: "dryRun", DefaultValue: "true", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeBool}},
+		{Name: "config", DefaultValue: "{}", ParameterSchema: v1alpha1.ParameterSchema{Type: v1alpha1.ParameterTypeJSON}},
+		{Name: "ticket", ParameterSchema: v1alpha1.ParameterSchema{Required: true, Pattern: "^OPS-[0-9]+$"}},
+	}
+
+	tests := []struct {
+		name    string
+		params  []v1alpha1.ParameterSpec
+		wantErr bool
+	}{
+		{name: "valid values", params: []v1alpha1.ParameterSpec{{Name: "count", Value: "2"}, {Name: "env", Value: "prod"}, {Name: "dryRun", Value: "false"}, {Name: "config", Value: `{"a": 1}`}, {Name: "ticket", Value: "OPS-12"}}},
+		{name: "missing required parameter", params: []v1alpha1.ParameterSpec{{Name: "count", Value: "2"}}, wantErr: true},
+		{name: "required parameter from a secret", params: []v1alpha1.ParameterSpec{{Name: "ticket", FromSecret: "ticket-secret"}}},
+		{name: "required parameter from a configMap key", params: []v1alpha1.ParameterSpec{{Name: "ticket", ConfigMapKeyRef: &v1alpha1.KeyRef{Name: "tickets", Key: "current"}}}},
+		{name: "undeclared parameter", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "OPS-1"}, {Name: "other", Value: "x"}}, wantErr: true},
+		{name: "not an int", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "OPS-1"}, {Name: "count", Value: "two"}}, wantErr: true},
+		{name: "below min", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "OPS-1"}, {Name: "count", Value: "0"}}, wantErr: true},
+		{name: "not in enum", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "OPS-1"}, {Name: "env", Value: "staging"}}, wantErr: true},
+		{name: "not a bool", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "OPS-1"}, {Name: "dryRun", Value: "maybe"}}, wantErr: true},
+		{name: "invalid json", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "OPS-1"}, {Name: "config", Value: "{"}}, wantErr: true},
+		{name: "pattern mismatch", params: []v1alpha1.ParameterSpec{{Name: "ticket", Value: "DEV-1"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v1alpha1.ValidateRunParameters(specs, tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRunParameters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSecretRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want v1alpha1.KeyRef
+	}{
+		{ref: "creds", want: v1alpha1.KeyRef{Name: "creds", Key: v1alpha1.DefaultSecretKey}},
+		{ref: "creds/api-token", want: v1alpha1.KeyRef{Name: "creds", Key: "api-token"}},
+	}
+
+	for _, tt := range tests {
+		if got := v1alpha1.ParseSecretRef(tt.ref); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSecretRef(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestDagTaskSpec_CheckTemplates(t *testing.T) {
+	spec := v1alpha1.DagTaskSpec{
+		Image:      "registry.local/extract:{{ params.version }}",
+		Command:    []string{"extract", "--run", "{{ run.id }}"},
+		Parameters: []string{"version"},
+	}
+	if err := spec.CheckTemplates(); err != nil {
+		t.Fatalf("expected the templates to be valid, got %v", err)
+	}
+
+	spec.Script = "echo {{ params.region }}"
+	if err := spec.CheckTemplates(); err == nil {
+		t.Fatalf("expected an error for a parameter the task does not declare")
+	}
+}
+
+func TestCacheInvalidationTasks(t *testing.T) {
+	if got := v1alpha1.CacheInvalidationTasks(""); got != nil {
+		t.Errorf("expected every task for an empty value, got %v", got)
+	}
+
+	want := []string{"extract", "load"}
+	if got := v1alpha1.CacheInvalidationTasks("extract, load,"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CacheInvalidationTasks() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckArtifactsConfigured(t *testing.T) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "artifacts"},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{Name: "extract", Command: []string{"echo"}, Image: "alpine"},
+				{Name: "train", Command: []string{"echo"}, Image: "alpine", Artifacts: []v1alpha1.ArtifactSpec{{Name: "model", Path: "/out/model"}}},
+			},
+		},
+	}
+
+	if err := dag.CheckArtifactsConfigured(true); err != nil {
+		t.Errorf("CheckArtifactsConfigured(true) error = %v", err)
+	}
+	if err := dag.CheckArtifactsConfigured(false); err == nil || err.Error() != "task train declares artifacts but artifacts are not configured" {
+		t.Errorf("CheckArtifactsConfigured(false) error = %v", err)
+	}
+
+	dag.Spec.Task = dag.Spec.Task[:1]
+	if err := dag.CheckArtifactsConfigured(false); err != nil {
+		t.Errorf("CheckArtifactsConfigured(false) without artifacts error = %v", err)
 	}
 }
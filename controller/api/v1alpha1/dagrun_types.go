@@ -20,14 +20,52 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ParentTaskRunAnnotation is set on DagRuns started by a dagRef task and holds the id of
+// the task run in the parent DAG run that is waiting for it
+const ParentTaskRunAnnotation = "kontroler.greedykomodo/parent-task-run-id"
+
+// UpstreamDagRunAnnotation is set on DagRuns started by an onDagCompletion trigger and holds
+// the namespace and name of the upstream run that completed, as <namespace>/<name>
+const UpstreamDagRunAnnotation = "kontroler.greedykomodo/upstream-dag-run"
+
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 type ParameterSpec struct {
 	Name string `json:"name"`
 	// +optional
 	Value string `json:"value,omitempty"`
+	// Secret the value is read from, either <secret> for its "secret" key or <secret>/<key>
 	// +optional
 	FromSecret string `json:"fromSecret,omitempty"`
+	// +optional
+	SecretKeyRef *KeyRef `json:"secretKeyRef,omitempty"`
+	// +optional
+	ConfigMapKeyRef *KeyRef `json:"configMapKeyRef,omitempty"`
+}
+
+// Ref returns where the value of the parameter is read from, a nil ref means a literal value
+func (p ParameterSpec) Ref() (ref *KeyRef, configMap bool) {
+	if p.ConfigMapKeyRef != nil {
+		return p.ConfigMapKeyRef, true
+	}
+	return secretRef(p.FromSecret, p.SecretKeyRef), false
+}
+
+// IsReference reports whether the value of the parameter is only known once a task runs
+func (p ParameterSpec) IsReference() bool {
+	ref, _ := p.Ref()
+	return ref != nil
+}
+
+// sources counts the ways the value of the parameter is given, exactly one is allowed
+func (p ParameterSpec) sources() int {
+	count := 0
+	for _, set := range []bool{p.Value != "", p.FromSecret != "", p.SecretKeyRef != nil, p.ConfigMapKeyRef != nil} {
+		if set {
+			count++
+		}
+	}
+	return count
 }
 
 // DagRunSpec defines the desired state of DagRun
@@ -35,15 +73,93 @@ type DagRunSpec struct {
 	DagName string `json:"dagName"`
 	// +optional
 	Parameters []ParameterSpec `json:"parameters"`
+	// Schedule time the run stands for, set on runs started by the schedule, catchup or a backfill.
+	// Its tasks receive it in the SCHEDULED_TIME environment variable
+	// +optional
+	ScheduledTime *metav1.Time `json:"scheduledTime,omitempty"`
+	// Overrides the priority of the DAG for this run, e.g. to keep a backfill behind scheduled runs
+	// +kubebuilder:validation:Minimum=-100
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Priority *int32 `json:"priority,omitempty"`
+}
+
+// DagRunPhase is where a DagRun is in its lifecycle, it is synced from the database
+// +kubebuilder:validation:Enum=Queued;Running;Succeeded;Failed;Suspended;TimedOut
+type DagRunPhase string
+
+const (
+	// DagRunQueued is waiting for a slot under the concurrency policy of its DAG
+	DagRunQueued DagRunPhase = "Queued"
+	// DagRunRunning still has tasks to run, including failed runs with tasks left running
+	DagRunRunning   DagRunPhase = "Running"
+	DagRunSucceeded DagRunPhase = "Succeeded"
+	DagRunFailed    DagRunPhase = "Failed"
+	// DagRunSuspended was stopped before it finished, e.g. replaced by a newer run
+	DagRunSuspended DagRunPhase = "Suspended"
+	// DagRunTimedOut ran past the runTimeout of its DAG
+	DagRunTimedOut DagRunPhase = "TimedOut"
+)
+
+const (
+	// DagRunConditionComplete is True once the run has finished, whatever its outcome
+	DagRunConditionComplete = "Complete"
+	// DagRunConditionSucceeded is True once the run has finished successfully and False
+	// once it has finished any other way
+	DagRunConditionSucceeded = "Succeeded"
+)
+
+// DagRunTaskCounts is how many task runs of a DagRun are in each state
+type DagRunTaskCounts struct {
+	// Pending task runs, including ones waiting to be retried
+	// +optional
+	Pending int `json:"pending,omitempty"`
+	// +optional
+	Running int `json:"running,omitempty"`
+	// +optional
+	Succeeded int `json:"succeeded,omitempty"`
+	// Task runs that reused the result of an earlier run instead of starting a pod
+	// +optional
+	Cached int `json:"cached,omitempty"`
+	// +optional
+	Failed int `json:"failed,omitempty"`
+	// +optional
+	Skipped int `json:"skipped,omitempty"`
+	// +optional
+	Suspended int `json:"suspended,omitempty"`
+	// +optional
+	TimedOut int `json:"timedOut,omitempty"`
 }
 
 // DagRunStatus defines the observed state of DagRun
 type DagRunStatus struct {
 	DagRunId int `json:"dagRunId"`
+	// +optional
+	Phase DagRunPhase `json:"phase,omitempty"`
+	// When the run started, not set while it is queued
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// When the last task of the run finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// +optional
+	Tasks DagRunTaskCounts `json:"tasks,omitempty"`
+	// Complete and Succeeded conditions, kubectl wait --for=condition=Complete blocks until the run finishes
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="DAG",type=string,JSONPath=`.spec.dagName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Succeeded",type=integer,JSONPath=`.status.tasks.succeeded`
+//+kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.tasks.failed`
+//+kubebuilder:printcolumn:name="Started",type=date,JSONPath=`.status.startTime`
+//+kubebuilder:printcolumn:name="Completed",type=date,JSONPath=`.status.completionTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // DagRun is the Schema for the dagruns API
 type DagRun struct {
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -18,6 +22,8 @@ var dagrunlog = logf.Log.WithName("dagrun-resource")
 func (r *DagRun) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithDefaulter(r).
+		WithValidator(&dagRunValidator{reader: mgr.GetClient()}).
 		Complete()
 }
 
@@ -37,28 +43,61 @@ func (r *DagRun) Default(ctx context.Context, obj runtime.Object) error {
 
 //+kubebuilder:webhook:path=/validate-kontroler-greedykomodo-v1alpha1-dagrun,mutating=false,failurePolicy=fail,sideEffects=None,groups=kontroler.greedykomodo,resources=dagruns,verbs=create;update,versions=v1alpha1,name=vdagrun.kb.io,admissionReviewVersions=v1
 
-var _ admission.CustomValidator = &DagRun{}
+// dagRunValidator checks DagRuns against the parameters of the DAG they run
+type dagRunValidator struct {
+	reader client.Reader
+}
+
+var _ admission.CustomValidator = &dagRunValidator{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *DagRun) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+func (v *dagRunValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	dagRun, ok := obj.(*DagRun)
 	if !ok {
 		return nil, fmt.Errorf("expected *DagRun, got %T", obj)
 	}
-	return dagRun.validateDagRun()
+
+	dag, err := v.getDag(ctx, dagRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return dagRun.validateDagRun(dag)
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *DagRun) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The parameters were checked when the run was created, so updates that leave them alone are always
+// allowed, as are updates to a run being deleted, which would otherwise keep its finalizers in place
+// once the DAG it runs has changed
+func (v *dagRunValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldDagRun, ok := oldObj.(*DagRun)
+	if !ok {
+		return nil, fmt.Errorf("expected *DagRun, got %T", oldObj)
+	}
+
 	dagRun, ok := newObj.(*DagRun)
 	if !ok {
 		return nil, fmt.Errorf("expected *DagRun, got %T", newObj)
 	}
-	return dagRun.validateDagRun()
+
+	if dagRun.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	if dagRun.Spec.DagName == oldDagRun.Spec.DagName && equality.Semantic.DeepEqual(dagRun.Spec.Parameters, oldDagRun.Spec.Parameters) {
+		return nil, nil
+	}
+
+	dag, err := v.getDag(ctx, dagRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return dagRun.validateDagRun(dag)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (r *DagRun) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+func (v *dagRunValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	dagRun, ok := obj.(*DagRun)
 	if !ok {
 		return nil, fmt.Errorf("expected *DagRun, got %T", obj)
@@ -67,8 +106,26 @@ func (r *DagRun) ValidateDelete(ctx context.Context, obj runtime.Object) (admiss
 	return nil, nil
 }
 
+// getDag returns the DAG a DagRun runs, nil when it does not exist yet
+func (v *dagRunValidator) getDag(ctx context.Context, dagRun *DagRun) (*DAG, error) {
+	if dagRun.Spec.DagName == "" {
+		return nil, nil
+	}
+
+	var dag DAG
+	if err := v.reader.Get(ctx, types.NamespacedName{Name: dagRun.Spec.DagName, Namespace: dagRun.Namespace}, &dag); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get DAG %s: %w", dagRun.Spec.DagName, err)
+	}
+
+	return &dag, nil
+}
+
 // validateDagRun is a helper function to validate DagRun creation and update.
-func (r *DagRun) validateDagRun() (admission.Warnings, error) {
+// The parameters are checked against the schema of dag, when it is known.
+func (r *DagRun) validateDagRun(dag *DAG) (admission.Warnings, error) {
 	if r.Spec.DagName == "" {
 		return nil, errors.New("DagName cannot be empty")
 	}
@@ -77,12 +134,23 @@ func (r *DagRun) validateDagRun() (admission.Warnings, error) {
 		if param.Name == "" {
 			return nil, fmt.Errorf("parameter name must be set")
 		}
-		if param.Value != "" && param.FromSecret != "" {
-			return nil, fmt.Errorf("only one of value or fromSecret can be set for parameter %s", param.Name)
+		if sources := param.sources(); sources > 1 {
+			return nil, fmt.Errorf("only one of value, fromSecret, secretKeyRef or configMapKeyRef can be set for parameter %s", param.Name)
+		} else if sources == 0 {
+			return nil, fmt.Errorf("either value, fromSecret, secretKeyRef or configMapKeyRef must be set for parameter %s", param.Name)
+		}
+		if ref, configMap := param.Ref(); ref != nil {
+			if err := validateKeyRef(ref, configMap); err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", param.Name, err)
+			}
 		}
-		if param.Value == "" && param.FromSecret == "" {
-			return nil, fmt.Errorf("either value or fromSecret must be set for parameter %s", param.Name)
+	}
+
+	if dag != nil {
+		if err := ValidateRunParameters(dag.Spec.Parameters, r.Spec.Parameters); err != nil {
+			return nil, err
 		}
 	}
+
 	return nil, nil
 }
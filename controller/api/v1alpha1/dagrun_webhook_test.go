@@ -17,7 +17,12 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"testing"
+
 	. "github.com/onsi/ginkgo/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("DagRun Webhook", func() {
@@ -45,3 +50,42 @@ var _ = Describe("DagRun Webhook", func() {
 	})
 
 })
+
+// dagReader serves a single DAG to the validator
+type dagReader struct {
+	client.Reader
+	dag DAG
+}
+
+func (r *dagReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	r.dag.DeepCopyInto(obj.(*DAG))
+	return nil
+}
+
+func TestDagRunValidator_ValidateUpdate(t *testing.T) {
+	// the DAG has since made a parameter required that the run does not set
+	validator := &dagRunValidator{reader: &dagReader{dag: DAG{
+		Spec: DAGSpec{Parameters: []DagParameterSpec{{Name: "stage", ParameterSchema: ParameterSchema{Required: true}}}},
+	}}}
+
+	oldRun := &DagRun{Spec: DagRunSpec{DagName: "etl"}}
+
+	withFinalizer := oldRun.DeepCopy()
+	withFinalizer.Finalizers = []string{"kontroler/dagrun"}
+	if _, err := validator.ValidateUpdate(context.Background(), oldRun, withFinalizer); err != nil {
+		t.Errorf("update leaving the parameters alone was rejected: %v", err)
+	}
+
+	deleting := oldRun.DeepCopy()
+	deleting.DeletionTimestamp = &metav1.Time{}
+	deleting.Spec.Parameters = []ParameterSpec{{Name: "other", Value: "x"}}
+	if _, err := validator.ValidateUpdate(context.Background(), oldRun, deleting); err != nil {
+		t.Errorf("update of a run being deleted was rejected: %v", err)
+	}
+
+	changed := oldRun.DeepCopy()
+	changed.Spec.Parameters = []ParameterSpec{{Name: "stage", Value: "prod"}, {Name: "other", Value: "x"}}
+	if _, err := validator.ValidateUpdate(context.Background(), oldRun, changed); err == nil {
+		t.Error("update setting an undeclared parameter was allowed")
+	}
+}
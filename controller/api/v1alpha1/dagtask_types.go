@@ -27,6 +27,8 @@ This is done to avoid having to de-taggle DAGs as they are deleted
 package v1alpha1
 
 import (
+	"kontroler-controller/pkg/templating"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -49,10 +51,31 @@ type DagTaskSpec struct {
 	// Used to select the image that is used to push to script into the pod
 	// +optional
 	ScriptInjectorImage string `json:"scriptInjectorImage,omitempty"`
+	// Names of the values this task publishes to downstream tasks
+	// +optional
+	Outputs []string `json:"outputs,omitempty"`
+	// Files or directories the task publishes to downstream tasks through the object store
+	// +optional
+	Artifacts []ArtifactSpec `json:"artifacts,omitempty"`
 	// Using reference to existing pre-created task - cannot reference another in-line task
 	// +optional
 }
 
+// CheckTemplates ensures the templates of the task only reference the parameters it declares
+func (s *DagTaskSpec) CheckTemplates() error {
+	params := map[string]bool{}
+	for _, param := range s.Parameters {
+		params[param] = true
+	}
+
+	for _, field := range templateFields(s.Image, s.Command, s.Args, s.Script) {
+		if err := templating.Check(field, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DagTaskStatus defines the observed state of DagTask
 type DagTaskStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
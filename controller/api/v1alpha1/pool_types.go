@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+
+Purpose of this API is to cap how many tasks use a shared resource, such as a
+database or an API, at once across every DAG in a namespace.
+
+Tasks join a pool by name and take one or more of its slots while their pod runs.
+A task is only claimed once its pool has enough free slots, tasks naming a pool
+that does not exist wait until it is created
+
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PoolSpec defines the desired state of Pool
+type PoolSpec struct {
+	// Number of slots shared by the tasks running in the pool
+	// +kubebuilder:validation:Minimum=0
+	Slots int32 `json:"slots"`
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+// PoolStatus defines the observed state of Pool
+type PoolStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Slots",type=integer,JSONPath=`.spec.slots`
+
+// Pool is the Schema for the pools API
+type Pool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PoolSpec   `json:"spec,omitempty"`
+	Status PoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PoolList contains a list of Pool
+type PoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Pool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Pool{}, &PoolList{})
+}
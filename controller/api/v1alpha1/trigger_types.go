@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+
+Purpose of this API is to start runs of a DAG from events sent by other systems,
+such as a webhook or a CloudEvents broker.
+
+A trigger serves an endpoint of the controller at /triggers/<namespace>/<path>. Every
+verified request to it starts a run of the DAG, with parameters read from the fields
+of the event. An event carrying an id starts at most one run, however often it is sent
+
+*/
+
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// TriggerAnnotation is set on DagRuns started by a trigger and holds the name of the trigger
+	TriggerAnnotation = "kontroler.greedykomodo/trigger"
+	// TriggerEventAnnotation is set on DagRuns started by a trigger for an event with an id
+	TriggerEventAnnotation = "kontroler.greedykomodo/trigger-event-id"
+
+	// DefaultSignatureHeader is the header a HMAC signature is read from when none is named
+	DefaultSignatureHeader = "X-Signature-256"
+)
+
+// path segments of a trigger endpoint, nested paths such as github/push are allowed
+var triggerPathRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(/[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// TriggerSpec defines the desired state of Trigger
+type TriggerSpec struct {
+	// DAG in the namespace of the trigger that is run for every event
+	DagName string `json:"dagName"`
+	// Path the trigger is served at, under /triggers/<namespace>/
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(/[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	Path string `json:"path"`
+	// Verifies the HMAC-SHA256 signature of the request body
+	// +optional
+	HMAC *HMACAuth `json:"hmac,omitempty"`
+	// Secret key holding the token requests send as "Authorization: Bearer <token>"
+	// +optional
+	BearerToken *KeyRef `json:"bearerToken,omitempty"`
+	// Run parameters read from fields of the event
+	// +optional
+	Parameters []TriggerParameter `json:"parameters,omitempty"`
+	// Field of a JSON event holding its id, CloudEvents always use their id
+	// +optional
+	EventIDField string `json:"eventIdField,omitempty"`
+	// Header holding the id of a JSON event, used when eventIdField is not set
+	// +optional
+	EventIDHeader string `json:"eventIdHeader,omitempty"`
+}
+
+// HMACAuth verifies requests signed with a secret shared with the sender
+type HMACAuth struct {
+	// Secret key holding the shared secret
+	SecretKeyRef KeyRef `json:"secretKeyRef"`
+	// Header holding the hex encoded signature, optionally prefixed with "sha256=".
+	// Defaults to X-Signature-256
+	// +optional
+	Header string `json:"header,omitempty"`
+}
+
+// TriggerParameter sets a run parameter from a field of the event
+type TriggerParameter struct {
+	Name string `json:"name"`
+	// Dotted path of the field, e.g. data.bucket.name for a CloudEvent or items.0.id for a JSON event.
+	// A missing field leaves the parameter to the default of the DAG
+	Field string `json:"field"`
+}
+
+// GetSignatureHeader returns the header the signature is read from
+func (h *HMACAuth) GetSignatureHeader() string {
+	if h.Header == "" {
+		return DefaultSignatureHeader
+	}
+	return h.Header
+}
+
+// Validate checks what the schema of the CRD cannot, a trigger has to verify its requests
+func (s *TriggerSpec) Validate() error {
+	if s.DagName == "" {
+		return errors.New("dagName must be specified")
+	}
+
+	if !triggerPathRegex.MatchString(s.Path) {
+		return fmt.Errorf("invalid path %q", s.Path)
+	}
+
+	if s.HMAC == nil && s.BearerToken == nil {
+		return errors.New("must set hmac or bearerToken")
+	}
+
+	if s.HMAC != nil && s.HMAC.SecretKeyRef.Name == "" {
+		return errors.New("hmac secretKeyRef name must be specified")
+	}
+
+	if s.BearerToken != nil && s.BearerToken.Name == "" {
+		return errors.New("bearerToken name must be specified")
+	}
+
+	seen := map[string]bool{}
+	for _, param := range s.Parameters {
+		if param.Name == "" || param.Field == "" {
+			return errors.New("parameters must set a name and a field")
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("duplicate parameter %s", param.Name)
+		}
+		seen[param.Name] = true
+	}
+
+	return nil
+}
+
+// TriggerStatus defines the observed state of Trigger
+type TriggerStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="DAG",type=string,JSONPath=`.spec.dagName`
+//+kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.path`
+
+// Trigger is the Schema for the triggers API
+type Trigger struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TriggerSpec   `json:"spec,omitempty"`
+	Status TriggerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// TriggerList contains a list of Trigger
+type TriggerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Trigger `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Trigger{}, &TriggerList{})
+}
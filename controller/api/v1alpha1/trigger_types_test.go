@@ -0,0 +1,84 @@
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"kontroler-controller/api/v1alpha1"
+)
+
+func TestTriggerSpec_Validate(t *testing.T) {
+	token := &v1alpha1.KeyRef{Name: "webhook", Key: "token"}
+
+	tests := []struct {
+		name    string
+		spec    v1alpha1.TriggerSpec
+		wantErr bool
+	}{
+		{
+			name: "valid bearer token",
+			spec: v1alpha1.TriggerSpec{DagName: "release", Path: "ci/deploy", BearerToken: token},
+		},
+		{
+			name: "valid hmac",
+			spec: v1alpha1.TriggerSpec{
+				DagName: "ingest",
+				Path:    "storage",
+				HMAC:    &v1alpha1.HMACAuth{SecretKeyRef: v1alpha1.KeyRef{Name: "webhook"}},
+				Parameters: []v1alpha1.TriggerParameter{
+					{Name: "bucket", Field: "data.bucket"},
+				},
+			},
+		},
+		{
+			name:    "missing dagName",
+			spec:    v1alpha1.TriggerSpec{Path: "deploy", BearerToken: token},
+			wantErr: true,
+		},
+		{
+			name:    "invalid path",
+			spec:    v1alpha1.TriggerSpec{DagName: "release", Path: "/deploy/", BearerToken: token},
+			wantErr: true,
+		},
+		{
+			name:    "no verification",
+			spec:    v1alpha1.TriggerSpec{DagName: "release", Path: "deploy"},
+			wantErr: true,
+		},
+		{
+			name:    "hmac without a secret",
+			spec:    v1alpha1.TriggerSpec{DagName: "release", Path: "deploy", HMAC: &v1alpha1.HMACAuth{}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate parameter",
+			spec: v1alpha1.TriggerSpec{
+				DagName:     "release",
+				Path:        "deploy",
+				BearerToken: token,
+				Parameters: []v1alpha1.TriggerParameter{
+					{Name: "version", Field: "tag"},
+					{Name: "version", Field: "release.tag"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "parameter without a field",
+			spec: v1alpha1.TriggerSpec{
+				DagName:     "release",
+				Path:        "deploy",
+				BearerToken: token,
+				Parameters:  []v1alpha1.TriggerParameter{{Name: "version"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
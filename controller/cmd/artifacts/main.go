@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"kontroler-controller/internal/artifacts"
+)
+
+// transfers collects the repeated -artifact flags
+type transfers []artifacts.Transfer
+
+func (t *transfers) String() string {
+	values := make([]string, len(*t))
+	for i, transfer := range *t {
+		values[i] = transfer.String()
+	}
+	return strings.Join(values, ",")
+}
+
+func (t *transfers) Set(value string) error {
+	transfer, err := artifacts.ParseTransfer(value)
+	if err != nil {
+		return err
+	}
+
+	*t = append(*t, transfer)
+	return nil
+}
+
+const usage = `usage:
+  kontroler-artifacts download [-install <dir>] [-artifact <key>=<dir>]...
+  kontroler-artifacts exec [-artifact <key>=<path>]... -- <command> [args]...
+  kontroler-artifacts upload [-artifact <key>]...`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "download":
+		os.Exit(download(ctx, os.Args[2:]))
+	case "exec":
+		os.Exit(execCommand(ctx, os.Args[2:]))
+	case "upload":
+		os.Exit(upload(ctx, os.Args[2:]))
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// download copies the binary into the shared volume and fetches the artifacts of upstream tasks
+func download(ctx context.Context, args []string) int {
+	var downloads transfers
+	var installDir string
+
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	flags.StringVar(&installDir, "install", "", "directory to copy this binary to")
+	flags.Var(&downloads, "artifact", "artifact to download, as <key>=<dir>")
+	_ = flags.Parse(args)
+
+	if installDir != "" {
+		if err := artifacts.Install(installDir); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to install %s: %v\n", artifacts.BinaryName, err)
+			return 1
+		}
+	}
+
+	if len(downloads) == 0 {
+		return 0
+	}
+
+	store, err := artifacts.StoreFromEnv(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the artifact store: %v\n", err)
+		return 1
+	}
+
+	if err := artifacts.Download(ctx, store, downloads); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "downloaded %d artifacts\n", len(downloads))
+	return 0
+}
+
+// execCommand runs the task command and, once it succeeds, stages its artifacts for the uploader
+// sidecar, exiting with the code of the command or 1 when the artifacts could not be uploaded
+func execCommand(ctx context.Context, args []string) int {
+	var uploads transfers
+
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	flags.Var(&uploads, "artifact", "artifact to upload after the command succeeds, as <key>=<path>")
+	_ = flags.Parse(args)
+
+	// keep the store variables from the command before they are read
+	env := artifacts.CommandEnv(os.Environ())
+
+	code, err := artifacts.Run(flags.Args(), env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run command: %v\n", err)
+		return 127
+	}
+
+	if code != 0 || len(uploads) == 0 {
+		return code
+	}
+
+	if err := artifacts.Stage(uploads, artifacts.UploadDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := artifacts.MarkStaged(artifacts.UploadDir); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hand artifacts over: %v\n", err)
+		return 1
+	}
+
+	// the task only succeeds once its artifacts are in the store
+	if err := artifacts.WaitUploaded(ctx, artifacts.UploadDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "uploaded %d artifacts\n", len(uploads))
+	return 0
+}
+
+// upload runs as a sidecar of the task container, uploading the artifacts it stages. It keeps running
+// until the pod stops it once the task container exits, as a sidecar that exits is restarted
+func upload(ctx context.Context, args []string) int {
+	var keys []string
+
+	flags := flag.NewFlagSet("upload", flag.ExitOnError)
+	flags.Func("artifact", "key of an artifact to upload", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	_ = flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// the task container failed or was stopped before staging anything
+	if err := artifacts.WaitStaged(ctx, artifacts.UploadDir); err != nil {
+		return 0
+	}
+
+	store, err := artifacts.StoreFromEnv(ctx)
+	if err == nil {
+		err = artifacts.Upload(ctx, store, keys, artifacts.UploadDir)
+	} else {
+		err = fmt.Errorf("failed to connect to the artifact store: %w", err)
+	}
+
+	if err := artifacts.MarkUploaded(artifacts.UploadDir, err); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hand the upload result over: %v\n", err)
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "uploaded %d artifacts\n", len(keys))
+	}
+
+	<-ctx.Done()
+	return 0
+}
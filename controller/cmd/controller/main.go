@@ -13,6 +13,8 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	// the images ship without a time zone database, schedules with a time zone need one
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -41,6 +43,7 @@ import (
 	log "sigs.k8s.io/controller-runtime/pkg/log"
 
 	kontrolerv1alpha1 "kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/artifacts"
 	"kontroler-controller/internal/config"
 	"kontroler-controller/internal/controller"
 	"kontroler-controller/internal/dag"
@@ -48,6 +51,8 @@ import (
 	_ "kontroler-controller/internal/metrics"
 	"kontroler-controller/internal/object"
 	"kontroler-controller/internal/queue"
+	"kontroler-controller/internal/sensors"
+	"kontroler-controller/internal/triggers"
 	kontrolerWebhook "kontroler-controller/internal/webhook"
 	"kontroler-controller/internal/workers"
 	//+kubebuilder:scaffold:imports
@@ -69,6 +74,7 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var triggerAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var configPath string
@@ -78,6 +84,8 @@ func main() {
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&triggerAddr, "trigger-bind-address", "0", "The address the trigger endpoints bind to. "+
+		"Use 0 to disable triggers.")
 	flag.StringVar(&configPath, "configpath", "", "Path to configuration file")
 	flag.StringVar(&tlsCertDir, "tls-cert-dir", "", "Directory containing TLS certificates for secure metrics endpoint")
 	flag.StringVar(&tlsCertName, "tls-cert-name", "tls.crt", "Name of the TLS certificate file")
@@ -343,7 +351,30 @@ func main() {
 		}
 	}()
 
-	taskAllocator := workers.NewTaskAllocator(clientset, id)
+	// task pods reach artifacts in the same store as logs
+	taskAllocator := workers.NewTaskAllocator(clientset, id, &workers.ArtifactConfig{
+		Artifacts: configController.Artifacts,
+		Store:     configController.LogStore,
+	})
+
+	// sensors wait for objects in the same store as logs and artifacts
+	artifactStore, err := artifacts.NewArtifactStore(rootCtx, configController.LogStore)
+	if err != nil {
+		setupLog.Error(err, "failed to create artifact store, object sensors will fail")
+	}
+
+	httpPolicy, err := sensors.NewHTTPPolicy(configController.Sensors.HTTP)
+	if err != nil {
+		setupLog.Error(err, "invalid sensor config")
+		os.Exit(1)
+	}
+
+	sensorClients, err := sensors.NewClients(config, artifactStore, httpPolicy)
+	if err != nil {
+		setupLog.Error(err, "failed to create sensor clients")
+		os.Exit(1)
+	}
+
 	var totalWorkers int
 	for _, workerConfig := range configController.Workers.Workers {
 		totalWorkers += workerConfig.Count
@@ -395,7 +426,7 @@ func main() {
 			queues[j] = que
 
 			wrkers[currentIndex] = workers.NewWorker(que, logStore, webhookChannel,
-				dbDAGManager, clientset, taskAllocator, pollDuration)
+				dbDAGManager, clientset, taskAllocator, sensorClients, pollDuration)
 			currentIndex++
 		}
 
@@ -424,9 +455,10 @@ func main() {
 	taskScheduler := dag.NewDagScheduler(dbDAGManager, dynamicClient)
 
 	if err = (&controller.DAGReconciler{
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		DbManager: dbDAGManager,
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		DbManager:        dbDAGManager,
+		ArtifactsEnabled: configController.Artifacts.Image != "",
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DAG")
 		os.Exit(1)
@@ -455,8 +487,25 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "DagTask")
 		os.Exit(1)
 	}
+
+	if err = (&controller.PoolReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		DbManager: dbDAGManager,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Pool")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
+	// triggers start runs through the scheduler, on every replica so events are not lost to a leader change
+	if triggerAddr != "0" {
+		if err := mgr.Add(triggers.NewServer(triggerAddr, mgr.GetClient(), clientset, dbDAGManager, taskScheduler)); err != nil {
+			setupLog.Error(err, "unable to add trigger server")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -487,6 +536,26 @@ func main() {
 			}
 		}()
 
+		// start timeout reconciler
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := controller.RunTimeoutReconciler(ctx, clientset, dbDAGManager, webhookChannel, 10*time.Second); err != nil {
+				setupLog.Error(err, "timeout reconciler stopped with error")
+				rootCancel()
+			}
+		}()
+
+		// start dagrun status sync
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := controller.RunDagRunStatusSync(ctx, mgr.GetClient(), dbDAGManager, 5*time.Second); err != nil {
+				setupLog.Error(err, "dagrun status sync stopped with error")
+				rootCancel()
+			}
+		}()
+
 		// Start the task watchers and workers
 		currentIndex := 0
 		for i, workerConfig := range configController.Workers.Workers {
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"database/sql"
 	"flag"
+	"kontroler-controller/internal/artifacts"
 	"kontroler-controller/internal/server/auth"
 	"kontroler-controller/internal/server/config"
 	"kontroler-controller/internal/server/db"
@@ -19,6 +20,8 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	// the images ship without a time zone database, schedules with a time zone need one
+	_ "time/tzdata"
 
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/websocket/v2"
@@ -147,9 +150,18 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to create a log fetcher")
 	}
 
+	// artifacts are only served when a store is configured, like logs
+	var artifactStore artifacts.ArtifactStore
+	if serverConfig.LogStorage.StoreType != "" {
+		artifactStore, err = artifacts.NewArtifactStore(ctx, serverConfig.LogStorage)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to create an artifact store")
+		}
+	}
+
 	logStreamer := ws.NewWebSocketLogStream(dbDAGManager, clientset)
 
-	app := internalRest.NewFiberHttpServer(dbDAGManager, kubClient, authManager, corsUiAddress, strings.ToLower(auditLogs) == "true", logFetcher)
+	app := internalRest.NewFiberHttpServer(dbDAGManager, kubClient, authManager, corsUiAddress, strings.ToLower(auditLogs) == "true", logFetcher, artifactStore)
 
 	// Apply authentication middleware BEFORE WebSocket upgrade
 	app.Use("/ws/logs", ws.Auth(authManager))
@@ -8,10 +8,15 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// the images ship without a time zone database, schedules with a time zone need one
+	_ "time/tzdata"
 
+	"kontroler-controller/internal/artifacts"
+	"kontroler-controller/internal/config"
 	"kontroler-controller/internal/db"
 	"kontroler-controller/internal/object"
 	"kontroler-controller/internal/queue"
+	"kontroler-controller/internal/sensors"
 	"kontroler-controller/internal/webhook"
 	"kontroler-controller/internal/workers"
 
@@ -106,19 +111,48 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the log store and artifacts are passed on by the controller through the environment
+	logStoreConfig, artifactsConfig, err := config.StoresFromEnv("/tmp/kontroler-logs")
+	if err != nil {
+		logf.Log.Error(err, "failed to configure log store")
+		os.Exit(1)
+	}
+
 	id := uuid.NewString()
-	taskAllocator := workers.NewTaskAllocator(clientset, id)
+	// task pods reach artifacts in the same store as logs
+	taskAllocator := workers.NewTaskAllocator(clientset, id, &workers.ArtifactConfig{
+		Artifacts: artifactsConfig,
+		Store:     logStoreConfig,
+	})
 
 	// Create a simple in-memory queue for the worker
 	que := queue.NewMemoryQueue(context.Background())
 
-	// Create a log store (filesystem by default)
-	logStore, err := object.NewFileSystemLogStore("/tmp/kontroler-logs")
+	logStore, err := createLogStore(logStoreConfig)
 	if err != nil {
 		logf.Log.Error(err, "failed to create log store (proceeding without log collection)")
 		logStore = nil
 	}
 
+	// sensors wait for objects in the same store as logs and artifacts
+	artifactStore, err := artifacts.NewArtifactStore(ctx, logStoreConfig)
+	if err != nil {
+		logf.Log.Error(err, "failed to create artifact store (object sensors will fail)")
+		artifactStore = nil
+	}
+
+	httpPolicy, err := sensors.NewHTTPPolicy(config.SensorsFromEnv().HTTP)
+	if err != nil {
+		logf.Log.Error(err, "invalid sensor config")
+		os.Exit(1)
+	}
+
+	sensorClients, err := sensors.NewClients(cfg, artifactStore, httpPolicy)
+	if err != nil {
+		logf.Log.Error(err, "failed to create sensor clients")
+		os.Exit(1)
+	}
+
 	// webhook channel
 	webhookChan := make(chan webhook.WebhookPayload, 10)
 
@@ -128,7 +162,7 @@ func main() {
 		pollDuration = 100 * time.Millisecond
 	}
 
-	w := workers.NewWorker(que, logStore, webhookChan, dbManager, clientset, taskAllocator, pollDuration)
+	w := workers.NewWorker(que, logStore, webhookChan, dbManager, clientset, taskAllocator, sensorClients, pollDuration)
 
 	// Start worker
 	if err := w.Run(ctx); err != nil {
@@ -139,3 +173,14 @@ func main() {
 	<-ctx.Done()
 	fmt.Println("worker exiting")
 }
+
+func createLogStore(logStoreConfig config.LogStore) (object.LogStore, error) {
+	switch logStoreConfig.StoreType {
+	case "filesystem":
+		return object.NewFileSystemLogStore(logStoreConfig.FileSystem.BaseDir)
+	case "s3":
+		return object.NewLogStore()
+	default:
+		return nil, fmt.Errorf("unsupported log store type: %s", logStoreConfig.StoreType)
+	}
+}
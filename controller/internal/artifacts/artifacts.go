@@ -0,0 +1,369 @@
+/*
+Package artifacts moves the files tasks publish between their pods and the object store.
+
+It backs the kontroler-artifacts binary: an init container copies the binary into a volume
+shared with the task container and downloads the artifacts of upstream tasks into it, and the
+task command runs through the binary, which stages the declared artifacts in the volume once it
+succeeds. A sidecar, the only container of the pod besides the init container holding the store
+credentials, uploads them while the task container waits for the outcome.
+*/
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"kontroler-controller/internal/config"
+)
+
+const (
+	// BinaryName is the name of the helper binary in its image and in the shared volume
+	BinaryName = "kontroler-artifacts"
+	// MountPath is where the volume shared by the helper and the task container is mounted
+	MountPath = "/kontroler"
+	// BinDir is where the helper binary is copied to
+	BinDir = MountPath + "/bin"
+	// Dir is where the artifacts of upstream tasks are downloaded to
+	Dir = MountPath + "/artifacts"
+	// UploadDir is where the task container stages its artifacts for the uploader
+	UploadDir = MountPath + "/upload"
+)
+
+// The task container holds no store credentials, it stages its artifacts and hands them over to the
+// uploader through these markers in the staging directory
+const (
+	stagedMarker   = ".staged"
+	uploadedMarker = ".uploaded"
+
+	markerPollInterval = 200 * time.Millisecond
+)
+
+// The helper reads the store from these variables. They, and AWS variables under EnvPrefix such as
+// KONTROLER_ARTIFACT_AWS_ACCESS_KEY_ID, are kept from the task command
+const (
+	EnvPrefix   = "KONTROLER_ARTIFACT_"
+	EnvStore    = EnvPrefix + "STORE"
+	EnvBucket   = EnvPrefix + "BUCKET"
+	EnvEndpoint = EnvPrefix + "ENDPOINT"
+	EnvDir      = EnvPrefix + "DIR"
+
+	envAWSPrefix = EnvPrefix + "AWS_"
+)
+
+// Transfer pairs an artifact in the store with a path in the pod, the file or directory
+// to upload or the directory to download into
+type Transfer struct {
+	Key  string
+	Path string
+}
+
+// ParseTransfer reads a transfer written as <key>=<path>
+func ParseTransfer(value string) (Transfer, error) {
+	key, path, ok := strings.Cut(value, "=")
+	if !ok || key == "" || path == "" {
+		return Transfer{}, fmt.Errorf("expected <key>=<path>, got %q", value)
+	}
+
+	return Transfer{Key: key, Path: path}, nil
+}
+
+func (t Transfer) String() string {
+	return t.Key + "=" + t.Path
+}
+
+// name is the name of the artifact, the root entry of its archive
+func (t Transfer) name() string {
+	return strings.TrimSuffix(path.Base(t.Key), ".tar.gz")
+}
+
+// StoreFromEnv creates the artifact store described by the environment of the helper
+func StoreFromEnv(ctx context.Context) (ArtifactStore, error) {
+	// the AWS SDK only reads its unprefixed variables
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, envAWSPrefix) {
+			if err := os.Setenv(strings.TrimPrefix(name, EnvPrefix), value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	storeConfig := config.LogStore{StoreType: os.Getenv(EnvStore)}
+	storeConfig.S3Configs.BucketName = os.Getenv(EnvBucket)
+	storeConfig.S3Configs.Endpoint = os.Getenv(EnvEndpoint)
+	storeConfig.FileSystem.BaseDir = os.Getenv(EnvDir)
+
+	return NewArtifactStore(ctx, storeConfig)
+}
+
+// CommandEnv drops the variables of the helper from environ, so the task command does not see them
+func CommandEnv(environ []string) []string {
+	env := make([]string, 0, len(environ))
+	for _, e := range environ {
+		if !strings.HasPrefix(e, EnvPrefix) {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+// Download unpacks every artifact into its directory
+func Download(ctx context.Context, store ArtifactStore, downloads []Transfer) error {
+	for _, download := range downloads {
+		if err := downloadOne(ctx, store, download); err != nil {
+			return fmt.Errorf("failed to download artifact %s: %w", download.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func downloadOne(ctx context.Context, store ArtifactStore, download Transfer) error {
+	reader, err := store.GetArtifact(ctx, download.Key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(download.Path, 0755); err != nil {
+		return err
+	}
+
+	return Unpack(reader, download.Path)
+}
+
+// Stage packs every artifact into dir, where the uploader picks the archives up
+func Stage(uploads []Transfer, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, upload := range uploads {
+		if err := stageOne(upload, dir); err != nil {
+			return fmt.Errorf("failed to stage artifact %s from %s: %w", upload.name(), upload.Path, err)
+		}
+	}
+
+	return nil
+}
+
+func stageOne(upload Transfer, dir string) error {
+	archive, err := os.Create(stagedPath(dir, upload.Key))
+	if err != nil {
+		return err
+	}
+
+	if err := Pack(upload.Path, upload.name(), archive); err != nil {
+		archive.Close()
+		return err
+	}
+	return archive.Close()
+}
+
+// Upload puts the archives staged in dir under their keys in the store
+func Upload(ctx context.Context, store ArtifactStore, keys []string, dir string) error {
+	for _, key := range keys {
+		if err := uploadOne(ctx, store, key, dir); err != nil {
+			return fmt.Errorf("failed to upload artifact %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func uploadOne(ctx context.Context, store ArtifactStore, key, dir string) error {
+	archive, err := openStaged(stagedPath(dir, key))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	return store.PutArtifact(ctx, key, archive)
+}
+
+// stagedPath is where the archive of the artifact stored under key is staged in dir
+func stagedPath(dir, key string) string {
+	return filepath.Join(dir, path.Base(key))
+}
+
+// openStaged opens a staged archive. The task container writes the staging directory, so anything
+// but a regular file, such as a link to a file only the uploader can read, is refused
+func openStaged(name string) (*os.File, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	opened, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !os.SameFile(info, opened) {
+		file.Close()
+		return nil, fmt.Errorf("%s changed while it was opened", name)
+	}
+
+	return file, nil
+}
+
+// MarkStaged tells the uploader the artifacts in dir are staged
+func MarkStaged(dir string) error {
+	return writeMarker(dir, stagedMarker, "")
+}
+
+// WaitStaged blocks until the artifacts in dir are staged or ctx is done
+func WaitStaged(ctx context.Context, dir string) error {
+	_, err := waitMarker(ctx, dir, stagedMarker)
+	return err
+}
+
+// MarkUploaded records the outcome of uploading the artifacts staged in dir
+func MarkUploaded(dir string, uploadErr error) error {
+	message := ""
+	if uploadErr != nil {
+		message = uploadErr.Error()
+	}
+	return writeMarker(dir, uploadedMarker, message)
+}
+
+// WaitUploaded blocks until the artifacts staged in dir are uploaded, returning the error
+// the uploader failed with
+func WaitUploaded(ctx context.Context, dir string) error {
+	message, err := waitMarker(ctx, dir, uploadedMarker)
+	if err != nil {
+		return err
+	}
+	if message != "" {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// writeMarker creates the marker through a rename, so it is never seen half written
+func writeMarker(dir, name, content string) error {
+	tmp, err := os.CreateTemp(dir, name+"-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+func waitMarker(ctx context.Context, dir, name string) (string, error) {
+	ticker := time.NewTicker(markerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run runs argv with the standard streams of the helper, passing on the signals it receives,
+// and returns its exit code. A command killed by a signal exits with 128 plus the signal number
+func Run(argv []string, env []string) (int, error) {
+	if len(argv) == 0 {
+		return 0, errors.New("no command to run")
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(signals)
+	close(done)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// Install copies the running binary into dir, so containers sharing the directory can run it
+func Install(dir string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return writeFile(src, filepath.Join(dir, BinaryName), 0755)
+}
@@ -0,0 +1,135 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadAndDownload(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileSystemArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "data", "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "data", "a.csv"), []byte("a,b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "data", "nested", "b.txt"), []byte("b"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "report.html"), []byte("<html>"), 0644))
+
+	staging := t.TempDir()
+	require.NoError(t, Stage([]Transfer{
+		{Key: ArtifactKey(1, "extract", nil, "data"), Path: filepath.Join(src, "data")},
+		{Key: ArtifactKey(1, "extract", nil, "report"), Path: filepath.Join(src, "report.html")},
+	}, staging))
+	require.NoError(t, Upload(ctx, store, []string{
+		ArtifactKey(1, "extract", nil, "data"),
+		ArtifactKey(1, "extract", nil, "report"),
+	}, staging))
+
+	dest := t.TempDir()
+	require.NoError(t, Download(ctx, store, []Transfer{
+		{Key: ArtifactKey(1, "extract", nil, "data"), Path: filepath.Join(dest, "extract")},
+		{Key: ArtifactKey(1, "extract", nil, "report"), Path: filepath.Join(dest, "extract")},
+	}))
+
+	content, err := os.ReadFile(filepath.Join(dest, "extract", "data", "a.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "a,b", string(content))
+
+	info, err := os.Stat(filepath.Join(dest, "extract", "data", "nested", "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// a single file is published under the name of the artifact
+	content, err = os.ReadFile(filepath.Join(dest, "extract", "report"))
+	require.NoError(t, err)
+	require.Equal(t, "<html>", string(content))
+
+	// a missing path fails staging, and an artifact that was not staged fails the upload
+	require.Error(t, Stage([]Transfer{
+		{Key: ArtifactKey(1, "extract", nil, "missing"), Path: filepath.Join(src, "missing")},
+	}, t.TempDir()))
+	require.Error(t, Upload(ctx, store, []string{ArtifactKey(1, "extract", nil, "missing")}, t.TempDir()))
+
+	// a staged link could point the uploader at files the task container can't read
+	linked := t.TempDir()
+	require.NoError(t, os.Symlink(filepath.Join(staging, "data.tar.gz"), filepath.Join(linked, "data.tar.gz")))
+	require.Error(t, Upload(ctx, store, []string{ArtifactKey(2, "extract", nil, "data")}, linked))
+
+	require.ErrorIs(t, Download(ctx, store, []Transfer{
+		{Key: ArtifactKey(1, "extract", nil, "missing"), Path: dest},
+	}), ErrArtifactNotFound)
+}
+
+func TestUploadHandover(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+
+	waitCtx, stop := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer stop()
+	require.ErrorIs(t, WaitStaged(waitCtx, dir), context.DeadlineExceeded)
+
+	require.NoError(t, MarkStaged(dir))
+	require.NoError(t, WaitStaged(ctx, dir))
+
+	require.NoError(t, MarkUploaded(dir, errors.New("bucket not found")))
+	require.EqualError(t, WaitUploaded(ctx, dir), "bucket not found")
+
+	require.NoError(t, MarkUploaded(dir, nil))
+	require.NoError(t, WaitUploaded(ctx, dir))
+}
+
+func TestUnpackRejectsEntriesOutsideOfDest(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0644, Size: 1, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	require.Error(t, Unpack(&buf, t.TempDir()))
+}
+
+func TestParseTransfer(t *testing.T) {
+	transfer, err := ParseTransfer("1/artifacts/extract/data.tar.gz=/out/data")
+	require.NoError(t, err)
+	require.Equal(t, Transfer{Key: "1/artifacts/extract/data.tar.gz", Path: "/out/data"}, transfer)
+	require.Equal(t, "data", transfer.name())
+
+	_, err = ParseTransfer("/out/data")
+	require.Error(t, err)
+}
+
+func TestCommandEnv(t *testing.T) {
+	require.Equal(t, []string{"PATH=/bin", "AWS_REGION=eu-west-2"}, CommandEnv([]string{
+		"PATH=/bin",
+		EnvStore + "=s3",
+		EnvPrefix + "AWS_SECRET_ACCESS_KEY=secret",
+		"AWS_REGION=eu-west-2",
+	}))
+}
+
+func TestRun(t *testing.T) {
+	code, err := Run([]string{"sh", "-c", "exit 3"}, os.Environ())
+	require.NoError(t, err)
+	require.Equal(t, 3, code)
+
+	code, err = Run([]string{"true"}, os.Environ())
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+
+	_, err = Run([]string{"/does/not/exist"}, os.Environ())
+	require.Error(t, err)
+}
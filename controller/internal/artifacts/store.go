@@ -0,0 +1,354 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"kontroler-controller/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrArtifactNotFound is returned when an artifact is not in the store
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// ArtifactStore keeps the files tasks publish for the tasks that run after them. Artifacts are
+// stored under the prefix of their run, next to its logs, so they are deleted along with them
+type ArtifactStore interface {
+	PutArtifact(ctx context.Context, key string, body io.ReadSeeker) error
+	GetArtifact(ctx context.Context, key string) (io.ReadCloser, error)
+	// ListArtifacts returns the artifacts published within a run
+	ListArtifacts(ctx context.Context, dagRunId int) ([]Artifact, error)
+	// ArtifactExists reports whether an object is stored under the key, sensors use it to wait for one
+	ArtifactExists(ctx context.Context, key string) (bool, error)
+}
+
+// Artifact is a stored artifact, MapIndex is set when it was published by an instance of a map task
+type Artifact struct {
+	TaskName string `json:"task"`
+	MapIndex *int   `json:"mapIndex,omitempty"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+}
+
+const artifactSuffix = ".tar.gz"
+
+// ArtifactKey returns where an artifact of a task run is stored, as a gzipped tar archive
+func ArtifactKey(dagRunId int, taskName string, mapIndex *int, name string) string {
+	if mapIndex != nil {
+		return fmt.Sprintf("%d/artifacts/%s/%d/%s%s", dagRunId, taskName, *mapIndex, name, artifactSuffix)
+	}
+
+	return fmt.Sprintf("%d/artifacts/%s/%s%s", dagRunId, taskName, name, artifactSuffix)
+}
+
+func artifactPrefix(dagRunId int) string {
+	return fmt.Sprintf("%d/artifacts/", dagRunId)
+}
+
+// parseArtifactKey reads the task, map index and name back from the part of a key after the run prefix
+func parseArtifactKey(rel string) (Artifact, bool) {
+	if !strings.HasSuffix(rel, artifactSuffix) {
+		return Artifact{}, false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(rel, artifactSuffix), "/")
+	switch len(parts) {
+	case 2:
+		return Artifact{TaskName: parts[0], Name: parts[1]}, true
+	case 3:
+		index, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return Artifact{}, false
+		}
+		return Artifact{TaskName: parts[0], MapIndex: &index, Name: parts[2]}, true
+	default:
+		return Artifact{}, false
+	}
+}
+
+// NewArtifactStore creates an artifact store on the same backend as the log store. It is kept apart
+// from the object package so the helper binary copied into task pods stays small
+func NewArtifactStore(ctx context.Context, storeConfig config.LogStore) (ArtifactStore, error) {
+	switch storeConfig.StoreType {
+	case "filesystem":
+		return NewFileSystemArtifactStore(storeConfig.FileSystem.BaseDir)
+	case "s3":
+		return NewS3ArtifactStore(ctx, storeConfig.S3Configs.BucketName, storeConfig.S3Configs.Endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported artifact store type: %s", storeConfig.StoreType)
+	}
+}
+
+type fileSystemArtifactStore struct {
+	baseDir string
+}
+
+func NewFileSystemArtifactStore(baseDir string) (ArtifactStore, error) {
+	if baseDir == "" {
+		return nil, fmt.Errorf("baseDir must be specified for the filesystem artifact store")
+	}
+
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+
+	return &fileSystemArtifactStore{baseDir: baseDir}, nil
+}
+
+// path resolves a key below the base directory, refusing keys that would leave it
+func (f *fileSystemArtifactStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid artifact key: %s", key)
+	}
+
+	return filepath.Join(f.baseDir, clean), nil
+}
+
+func (f *fileSystemArtifactStore) PutArtifact(ctx context.Context, key string, body io.ReadSeeker) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+
+	// write next to the target and rename, so readers never see a partial archive
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create artifact file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *fileSystemArtifactStore) GetArtifact(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrArtifactNotFound
+	}
+
+	return file, err
+}
+
+func (f *fileSystemArtifactStore) ArtifactExists(ctx context.Context, key string) (bool, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (f *fileSystemArtifactStore) ListArtifacts(ctx context.Context, dagRunId int) ([]Artifact, error) {
+	root := filepath.Join(f.baseDir, filepath.FromSlash(artifactPrefix(dagRunId)))
+
+	artifacts := []Artifact{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		artifact, ok := parseArtifactKey(filepath.ToSlash(rel))
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		artifact.Size = info.Size()
+
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+
+	return artifacts, nil
+}
+
+type s3ArtifactClient interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+type s3ArtifactStore struct {
+	client     s3ArtifactClient
+	bucketName *string
+}
+
+func NewS3ArtifactStore(ctx context.Context, bucketName, endpoint string) (ArtifactStore, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucketName must be specified for the s3 artifact store")
+	}
+
+	s3Config, err := loadS3Config()
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(s3Config, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+
+		// Better handles Minio with Kubernetes DNS
+		o.UsePathStyle = true
+	})
+
+	return &s3ArtifactStore{
+		client:     client,
+		bucketName: &bucketName,
+	}, nil
+}
+
+func (s *s3ArtifactStore) PutArtifact(ctx context.Context, key string, body io.ReadSeeker) error {
+	if _, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: s.bucketName,
+		Key:    aws.String(key),
+		Body:   body,
+	}); err != nil {
+		return fmt.Errorf("error uploading artifact: %w", err)
+	}
+
+	return nil
+}
+
+func (s *s3ArtifactStore) GetArtifact(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: s.bucketName,
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrArtifactNotFound
+		}
+		return nil, fmt.Errorf("error fetching artifact: %w", err)
+	}
+
+	return output.Body, nil
+}
+
+func (s *s3ArtifactStore) ArtifactExists(ctx context.Context, key string) (bool, error) {
+	if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: s.bucketName,
+		Key:    aws.String(key),
+	}); err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking artifact: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *s3ArtifactStore) ListArtifacts(ctx context.Context, dagRunId int) ([]Artifact, error) {
+	prefix := artifactPrefix(dagRunId)
+
+	artifacts := []Artifact{}
+	var cont *string
+	for {
+		output, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            s.bucketName,
+			Prefix:            aws.String(prefix),
+			ContinuationToken: cont,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing artifacts: %w", err)
+		}
+
+		for _, object := range output.Contents {
+			if object.Key == nil {
+				continue
+			}
+
+			artifact, ok := parseArtifactKey(strings.TrimPrefix(*object.Key, prefix))
+			if !ok {
+				continue
+			}
+			if object.Size != nil {
+				artifact.Size = *object.Size
+			}
+
+			artifacts = append(artifacts, artifact)
+		}
+
+		if output.IsTruncated == nil || !*output.IsTruncated {
+			break
+		}
+		cont = output.NextContinuationToken
+	}
+
+	return artifacts, nil
+}
+
+// loadS3Config reads credentials the same way as the log store
+func loadS3Config() (aws.Config, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	sessionToken := os.Getenv("AWS_SESSION_TOKEN")
+
+	if accessKey != "" && secretKey != "" {
+		return awsconfig.LoadDefaultConfig(context.TODO(),
+			awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, sessionToken)),
+		)
+	}
+
+	return awsconfig.LoadDefaultConfig(context.TODO())
+}
@@ -0,0 +1,72 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactKey(t *testing.T) {
+	index := 2
+
+	require.Equal(t, "7/artifacts/extract/data.tar.gz", ArtifactKey(7, "extract", nil, "data"))
+	require.Equal(t, "7/artifacts/extract/2/data.tar.gz", ArtifactKey(7, "extract", &index, "data"))
+
+	artifact, ok := parseArtifactKey("extract/2/data.tar.gz")
+	require.True(t, ok)
+	require.Equal(t, Artifact{TaskName: "extract", MapIndex: &index, Name: "data"}, artifact)
+
+	_, ok = parseArtifactKey("extract/data.txt")
+	require.False(t, ok)
+}
+
+func TestFileSystemArtifactStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileSystemArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	// nothing has been published for the run yet
+	artifacts, err := store.ListArtifacts(ctx, 1)
+	require.NoError(t, err)
+	require.Empty(t, artifacts)
+
+	_, err = store.GetArtifact(ctx, ArtifactKey(1, "extract", nil, "data"))
+	require.ErrorIs(t, err, ErrArtifactNotFound)
+
+	exists, err := store.ArtifactExists(ctx, ArtifactKey(1, "extract", nil, "data"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	index := 0
+	require.NoError(t, store.PutArtifact(ctx, ArtifactKey(1, "extract", nil, "data"), bytes.NewReader([]byte("archive"))))
+	require.NoError(t, store.PutArtifact(ctx, ArtifactKey(1, "map", &index, "part"), bytes.NewReader([]byte("map archive"))))
+	require.NoError(t, store.PutArtifact(ctx, ArtifactKey(2, "extract", nil, "data"), bytes.NewReader([]byte("other run"))))
+
+	exists, err = store.ArtifactExists(ctx, ArtifactKey(1, "extract", nil, "data"))
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	reader, err := store.GetArtifact(ctx, ArtifactKey(1, "extract", nil, "data"))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "archive", string(content))
+
+	artifacts, err = store.ListArtifacts(ctx, 1)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Artifact{
+		{TaskName: "extract", Name: "data", Size: 7},
+		{TaskName: "map", MapIndex: &index, Name: "part", Size: 11},
+	}, artifacts)
+
+	// keys cannot reach outside of the base directory
+	require.Error(t, store.PutArtifact(ctx, "../escape.tar.gz", bytes.NewReader(nil)))
+	_, err = store.GetArtifact(ctx, "../../etc/passwd")
+	require.Error(t, err)
+	_, err = store.ArtifactExists(ctx, "../../etc/passwd")
+	require.Error(t, err)
+}
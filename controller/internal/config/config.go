@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -13,6 +14,8 @@ type ControllerConfig struct {
 	LeaderElectionID string        `yaml:"leaderElectionID"`
 	Workers          WorkerConfigs `yaml:"workers"`
 	LogStore         LogStore      `yaml:"logStorage"`
+	Artifacts        Artifacts     `yaml:"artifacts"`
+	Sensors          Sensors       `yaml:"sensors"`
 }
 
 type LogStore struct {
@@ -30,6 +33,32 @@ type FileSystemLogStoreConfig struct {
 	BaseDir string `yaml:"baseDir"`
 }
 
+// Artifacts lets tasks publish files to the log store for the tasks that run after them,
+// it is disabled while no image is set
+type Artifacts struct {
+	// Image holding the kontroler-artifacts binary, usually the controller image
+	Image string `yaml:"image"`
+	// Secret in the namespace of each task holding the AWS variables task pods use for s3,
+	// such as AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION
+	CredentialsSecret string `yaml:"credentialsSecret,omitempty"`
+	// Claim of the volume holding the baseDir of a filesystem store, mounted into task pods
+	ClaimName string `yaml:"claimName,omitempty"`
+}
+
+// Sensors limits what sensor tasks can reach
+type Sensors struct {
+	HTTP HTTPSensors `yaml:"http"`
+}
+
+// HTTPSensors limits the hosts http sensors call. Entries are host names, where "*.example.com" matches
+// its subdomains, or CIDRs the addresses a host resolves to are matched against
+type HTTPSensors struct {
+	// A host must match an entry when any are set
+	Allow []string `yaml:"allow,omitempty"`
+	// A host matching an entry is refused, defaults to link-local addresses such as cloud metadata services
+	Deny []string `yaml:"deny,omitempty"`
+}
+
 type WorkerConfigs struct {
 	WorkerType   string         `yaml:"workerType"` // "memory" or "pebble"
 	QueueDir     string         `yaml:"queueDir"`   // directory for pebble queue storage
@@ -94,7 +123,7 @@ func ParseConfig(configPath string) (*ControllerConfig, error) {
 		cConfig.LogStore.StoreType = "filesystem"
 		// If defaulting to filesystem, check for LOG_DIR environment variable
 		if cConfig.LogStore.FileSystem.BaseDir == "" {
-			cConfig.LogStore.FileSystem.BaseDir = os.Getenv("LOG_DIR")
+			cConfig.LogStore.FileSystem.BaseDir = os.Getenv(EnvLogDir)
 		}
 	}
 
@@ -102,9 +131,86 @@ func ParseConfig(configPath string) (*ControllerConfig, error) {
 		return nil, err
 	}
 
+	if err := validateArtifacts(cConfig.Artifacts, cConfig.LogStore); err != nil {
+		return nil, err
+	}
+
 	return cConfig, nil
 }
 
+// Workers read the log store and artifacts from these variables, worker pools are given the ones of the controller
+const (
+	EnvLogStoreType               = "LOG_STORE_TYPE"
+	EnvLogDir                     = "LOG_DIR"
+	EnvS3BucketName               = "S3_BUCKETNAME"
+	EnvS3Endpoint                 = "S3_ENDPOINT"
+	EnvArtifactsImage             = "ARTIFACTS_IMAGE"
+	EnvArtifactsCredentialsSecret = "ARTIFACTS_CREDENTIALS_SECRET"
+	EnvArtifactsClaimName         = "ARTIFACTS_CLAIM_NAME"
+	// comma separated entries of Sensors.HTTP
+	EnvSensorHTTPAllow = "SENSOR_HTTP_ALLOW"
+	EnvSensorHTTPDeny  = "SENSOR_HTTP_DENY"
+)
+
+// StoresFromEnv reads the log store and artifacts of a worker from its environment. The log store
+// defaults to the filesystem under defaultLogDir
+func StoresFromEnv(defaultLogDir string) (LogStore, Artifacts, error) {
+	logStore := LogStore{StoreType: os.Getenv(EnvLogStoreType)}
+	logStore.FileSystem.BaseDir = os.Getenv(EnvLogDir)
+	logStore.S3Configs.BucketName = os.Getenv(EnvS3BucketName)
+	logStore.S3Configs.Endpoint = os.Getenv(EnvS3Endpoint)
+
+	if logStore.StoreType == "" {
+		logStore.StoreType = "filesystem"
+	}
+	if logStore.StoreType == "filesystem" && logStore.FileSystem.BaseDir == "" {
+		logStore.FileSystem.BaseDir = defaultLogDir
+	}
+
+	artifacts := Artifacts{
+		Image:             os.Getenv(EnvArtifactsImage),
+		CredentialsSecret: os.Getenv(EnvArtifactsCredentialsSecret),
+		ClaimName:         os.Getenv(EnvArtifactsClaimName),
+	}
+
+	if err := validateLogStore(&logStore); err != nil {
+		return LogStore{}, Artifacts{}, err
+	}
+
+	if err := validateArtifacts(artifacts, logStore); err != nil {
+		return LogStore{}, Artifacts{}, err
+	}
+
+	return logStore, artifacts, nil
+}
+
+// SensorsFromEnv reads the limits of the sensors of a worker from its environment
+func SensorsFromEnv() Sensors {
+	return Sensors{HTTP: HTTPSensors{
+		Allow: splitList(os.Getenv(EnvSensorHTTPAllow)),
+		Deny:  splitList(os.Getenv(EnvSensorHTTPDeny)),
+	}}
+}
+
+// splitList splits a comma separated list, an empty list is nil
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func validateArtifacts(artifacts Artifacts, logStore LogStore) error {
+	// task pods can only reach a filesystem store through its volume
+	if artifacts.Image != "" && logStore.StoreType == "filesystem" && artifacts.ClaimName == "" {
+		return fmt.Errorf("artifacts.claimName must be specified when artifacts use a filesystem log store")
+	}
+	return nil
+}
+
 func validateLogStore(logStore *LogStore) error {
 	switch logStore.StoreType {
 	case "filesystem":
@@ -237,6 +237,46 @@ workers:
   workers:
     - namespace: "default"
       count: 1
+`,
+			expectError: true,
+		},
+		{
+			name: "artifacts on s3",
+			configYaml: `
+leaderElectionID: "test-controller"
+workers:
+  workerType: "memory"
+  workers:
+    - namespace: "default"
+      count: 1
+logStorage:
+  storeType: "s3"
+  s3:
+    bucketName: "my-test-bucket"
+artifacts:
+  image: "greedykomodo/kontroler-controller:0.0.1"
+  credentialsSecret: "s3-creds"
+`,
+			validate: func(t *testing.T, cfg *ControllerConfig) {
+				assert.Equal(t, "greedykomodo/kontroler-controller:0.0.1", cfg.Artifacts.Image)
+				assert.Equal(t, "s3-creds", cfg.Artifacts.CredentialsSecret)
+			},
+		},
+		{
+			name: "artifacts on filesystem without a claim",
+			configYaml: `
+leaderElectionID: "test-controller"
+workers:
+  workerType: "memory"
+  workers:
+    - namespace: "default"
+      count: 1
+logStorage:
+  storeType: "filesystem"
+  fileSystem:
+    baseDir: "/logs"
+artifacts:
+  image: "greedykomodo/kontroler-controller:0.0.1"
 `,
 			expectError: true,
 		},
@@ -276,3 +316,33 @@ workers:
 		})
 	}
 }
+
+func TestStoresFromEnv(t *testing.T) {
+	t.Setenv(EnvLogStoreType, "")
+	t.Setenv(EnvLogDir, "")
+
+	// without any variables logs go to the default directory and artifacts are disabled
+	logStore, artifacts, err := StoresFromEnv("/tmp/kontroler-logs")
+	require.NoError(t, err)
+	assert.Equal(t, "filesystem", logStore.StoreType)
+	assert.Equal(t, "/tmp/kontroler-logs", logStore.FileSystem.BaseDir)
+	assert.Empty(t, artifacts.Image)
+
+	t.Setenv(EnvLogStoreType, "s3")
+	t.Setenv(EnvS3BucketName, "logs")
+	t.Setenv(EnvS3Endpoint, "http://minio:9000")
+	t.Setenv(EnvArtifactsImage, "kontroler:latest")
+	t.Setenv(EnvArtifactsCredentialsSecret, "s3-creds")
+
+	logStore, artifacts, err = StoresFromEnv("/tmp/kontroler-logs")
+	require.NoError(t, err)
+	assert.Equal(t, "logs", logStore.S3Configs.BucketName)
+	assert.Equal(t, "http://minio:9000", logStore.S3Configs.Endpoint)
+	assert.Equal(t, Artifacts{Image: "kontroler:latest", CredentialsSecret: "s3-creds"}, artifacts)
+
+	// task pods can't reach a filesystem store without its claim
+	t.Setenv(EnvLogStoreType, "filesystem")
+	t.Setenv(EnvLogDir, "/logs")
+	_, _, err = StoresFromEnv("/tmp/kontroler-logs")
+	require.Error(t, err)
+}
@@ -22,9 +22,13 @@ import (
 	"encoding/hex"
 	sterrors "errors"
 	"fmt"
+	"time"
 
 	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -43,11 +47,16 @@ type DAGReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
 	DbManager db.DBDAGManager
+	// ArtifactsEnabled is set when an artifacts image is configured, DAGs declaring artifacts are invalid otherwise
+	ArtifactsEnabled bool
 }
 
 // defaultConcurrency controls bounded parallelism for batch operations in controllers
 const defaultConcurrency = 8
 
+// dagStatusRefreshInterval is how often the last run and next scheduled time in the status of a valid DAG are refreshed
+const dagStatusRefreshInterval = 30 * time.Second
+
 //+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=dags,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=dags/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=dags/finalizers,verbs=update
@@ -79,6 +88,19 @@ func (r *DAGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return r.handleDeletion(ctx, req.NamespacedName)
 	}
 
+	if _, ok := dag.Annotations[kontrolerv1alpha1.DAGAnnotationInvalidateCache]; ok {
+		if err := r.invalidateCache(ctx, &dag); err != nil {
+			log.Log.Error(err, "failed to invalidate task cache", "dag", dag.Name)
+			return ctrl.Result{}, err
+		}
+		orig = dag.DeepCopy()
+	}
+
+	// The spec is already stored, only the parts of the status that come from the database need refreshing
+	if dag.Status.ObservedGeneration == dag.Generation && meta.IsStatusConditionTrue(dag.Status.Conditions, kontrolerv1alpha1.DAGConditionValid) {
+		return r.markDAGSuccessful(ctx, &dag)
+	}
+
 	// Process DSL if provided. Only do work when the DSL has changed (hash-based).
 	if dag.Spec.DSL != "" {
 		hashBytes := sha256.Sum256([]byte(dag.Spec.DSL))
@@ -127,11 +149,15 @@ func (r *DAGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return r.markDAGFailed(ctx, &dag, fmt.Sprintf("failed to validate dag: %s", err.Error()))
 	}
 
+	if err := dag.CheckArtifactsConfigured(r.ArtifactsEnabled); err != nil {
+		return r.markDAGFailed(ctx, &dag, fmt.Sprintf("failed to validate dag: %s", err.Error()))
+	}
+
 	// Store the DAG object in the database
 	if err := r.storeInDatabase(ctx, &dag, req.NamespacedName.Namespace); err != nil {
 		if err.Error() == "applying the same dag" {
 			log.Log.Info("reconcile event", "controller", "dag", "event", "applying the same dag")
-			return ctrl.Result{}, nil
+			return r.markDAGSuccessful(ctx, &dag)
 		}
 		return r.markDAGFailed(ctx, &dag, fmt.Sprintf("failed to store dag in db: %s", err.Error()))
 	}
@@ -142,15 +168,24 @@ func (r *DAGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 }
 
 func (r *DAGReconciler) markDAGFailed(ctx context.Context, dag *kontrolerv1alpha1.DAG, reason string) (ctrl.Result, error) {
+	old := dag.DeepCopy()
+	dag.Status.Phase = "Failed"
+	dag.Status.Message = reason
+	dag.Status.ObservedGeneration = dag.Generation
+	meta.SetStatusCondition(&dag.Status.Conditions, metav1.Condition{
+		Type:               kontrolerv1alpha1.DAGConditionValid,
+		Status:             metav1.ConditionFalse,
+		Reason:             "ReconcileFailed",
+		Message:            reason,
+		ObservedGeneration: dag.Generation,
+	})
+
 	// Avoid unnecessary updates
-	if dag.Status.Phase == "Failed" && dag.Status.Message == reason {
+	if equality.Semantic.DeepEqual(old.Status, dag.Status) {
 		log.Log.Info("DAG already marked as failed", "dag", dag.Name)
 		return ctrl.Result{}, nil
 	}
 
-	old := dag.DeepCopy()
-	dag.Status.Phase = "Failed"
-	dag.Status.Message = reason
 	if err := r.Status().Patch(ctx, dag, client.MergeFrom(old)); err != nil {
 		log.Log.Error(err, "failed to update DAG status", "dag", dag.Name)
 		return ctrl.Result{}, err
@@ -159,22 +194,127 @@ func (r *DAGReconciler) markDAGFailed(ctx context.Context, dag *kontrolerv1alpha
 	return ctrl.Result{}, nil
 }
 
+// markDAGSuccessful records that the DAG is stored, along with its stored version, latest run and schedule.
+// The DAG is requeued so these keep following the runs and the schedule
 func (r *DAGReconciler) markDAGSuccessful(ctx context.Context, dag *kontrolerv1alpha1.DAG) (ctrl.Result, error) {
-	// Avoid unnecessary updates
-	if dag.Status.Phase == "Successful" && dag.Status.Message == "DAG reconciled successfully" {
-		log.Log.Info("DAG already marked as successful", "dag", dag.Name)
-		return ctrl.Result{}, nil
-	}
-
 	old := dag.DeepCopy()
 	dag.Status.Phase = "Successful"
 	dag.Status.Message = "DAG reconciled successfully"
+	dag.Status.ObservedGeneration = dag.Generation
+	meta.SetStatusCondition(&dag.Status.Conditions, metav1.Condition{
+		Type:               kontrolerv1alpha1.DAGConditionValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Stored",
+		Message:            "DAG passed validation and is stored",
+		ObservedGeneration: dag.Generation,
+	})
+
+	if err := r.setStoredStatus(ctx, dag); err != nil {
+		log.Log.Error(err, "failed to get stored DAG status", "dag", dag.Name)
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{RequeueAfter: dagStatusRefreshInterval}
+
+	// Avoid unnecessary updates
+	if equality.Semantic.DeepEqual(old.Status, dag.Status) {
+		return result, nil
+	}
+
 	if err := r.Status().Patch(ctx, dag, client.MergeFrom(old)); err != nil {
 		log.Log.Error(err, "failed to update DAG status", "dag", dag.Name)
 		return ctrl.Result{}, err
 	}
-	log.Log.Info("DAG marked as successful", "dag", dag.Name)
-	return ctrl.Result{}, nil
+	log.Log.Info("DAG marked as successful", "dag", dag.Name, "version", *dag.Status.Version)
+	return result, nil
+}
+
+// setStoredStatus fills in the status of a DAG from its latest stored version and run
+func (r *DAGReconciler) setStoredStatus(ctx context.Context, dag *kontrolerv1alpha1.DAG) error {
+	stored, err := r.DbManager.GetDagStatus(ctx, dag.Name, dag.Namespace)
+	if err != nil {
+		return err
+	}
+
+	dag.Status.Version = &stored.Version
+	dag.Status.Hash = stored.Hash
+
+	// the schedule of a suspended DAG does not start runs, so the stored next time is stale
+	dag.Status.NextScheduledTime = nil
+	if !dag.Spec.Suspended {
+		dag.Status.NextScheduledTime = metaTime(stored.NextTime)
+	}
+
+	dag.Status.LastRun = nil
+	if stored.LastRun != nil {
+		dag.Status.LastRun = &kontrolerv1alpha1.DAGLastRun{
+			DagRunId: stored.LastRun.RunId,
+			Name:     stored.LastRun.Name,
+		}
+
+		summary, err := r.DbManager.GetDagRunSummary(ctx, stored.LastRun.RunId)
+		if err != nil {
+			return err
+		}
+		dag.Status.LastRun.Phase = dagRunPhase(summary)
+	}
+
+	setScheduleConditions(dag)
+	return nil
+}
+
+// setScheduleConditions sets the Scheduled and Suspended conditions of a DAG from its spec and next scheduled time
+func setScheduleConditions(dag *kontrolerv1alpha1.DAG) {
+	suspended := metav1.Condition{
+		Type:               kontrolerv1alpha1.DAGConditionSuspended,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Active",
+		Message:            "DAG is not suspended",
+		ObservedGeneration: dag.Generation,
+	}
+	if dag.Spec.Suspended {
+		suspended.Status = metav1.ConditionTrue
+		suspended.Reason = "Suspended"
+		suspended.Message = "DAG is suspended, its schedule and backfills wait until it is resumed"
+	}
+
+	scheduled := metav1.Condition{
+		Type:               kontrolerv1alpha1.DAGConditionScheduled,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: dag.Generation,
+	}
+	switch {
+	case dag.Spec.Schedule == "":
+		scheduled.Reason = "NoSchedule"
+		scheduled.Message = "DAG has no schedule, it only runs when a DagRun is created"
+	case dag.Spec.Suspended:
+		scheduled.Reason = "Suspended"
+		scheduled.Message = "DAG is suspended"
+	case dag.Status.NextScheduledTime == nil:
+		scheduled.Reason = "ScheduleEnded"
+		scheduled.Message = "no schedule times are left before the endTime of the DAG"
+	default:
+		scheduled.Status = metav1.ConditionTrue
+		scheduled.Reason = "Scheduled"
+		scheduled.Message = "DAG is started on its schedule"
+	}
+
+	meta.SetStatusCondition(&dag.Status.Conditions, suspended)
+	meta.SetStatusCondition(&dag.Status.Conditions, scheduled)
+}
+
+// invalidateCache drops the cached task results asked for by the invalidate-cache annotation, then removes it
+func (r *DAGReconciler) invalidateCache(ctx context.Context, dag *kontrolerv1alpha1.DAG) error {
+	tasks := kontrolerv1alpha1.CacheInvalidationTasks(dag.Annotations[kontrolerv1alpha1.DAGAnnotationInvalidateCache])
+	dropped, err := r.DbManager.InvalidateTaskCache(ctx, dag.Name, dag.Namespace, tasks)
+	if err != nil {
+		return err
+	}
+	log.Log.Info("task cache invalidated", "dag", dag.Name, "namespace", dag.Namespace, "tasks", tasks, "dropped", dropped)
+
+	old := dag.DeepCopy()
+	delete(dag.Annotations, kontrolerv1alpha1.DAGAnnotationInvalidateCache)
+	return r.Patch(ctx, dag, client.MergeFrom(old))
 }
 
 func (r *DAGReconciler) storeInDatabase(ctx context.Context, dag *kontrolerv1alpha1.DAG, namespace string) error {
@@ -190,7 +330,8 @@ func (r *DAGReconciler) deleteFromDatabase(ctx context.Context, namespacedName t
 func (r *DAGReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kontrolerv1alpha1.DAG{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		// annotations carry requests such as invalidating the task cache, which leave the spec alone
+		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})).
 		Complete(r)
 }
 
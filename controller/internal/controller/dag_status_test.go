@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+
+	cron "github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestDAGReconciler_Status checks that the status of a DAG records its stored version, schedule and latest run.
+func TestDAGReconciler_Status(t *testing.T) {
+	ctx := context.Background()
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	sqliteMgr, _, err := db.NewSqliteManager(ctx, &parser, &db.SQLiteConfig{DBPath: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to create sqlite manager: %v", err)
+	}
+	if err := sqliteMgr.InitaliseDatabase(ctx); err != nil {
+		t.Fatalf("failed to initalise sqlite db: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "status-dag", Namespace: "default"},
+		Spec: v1alpha1.DAGSpec{
+			Schedule: "0 * * * *",
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "only",
+					Image:   "alpine:latest",
+					Command: []string{"echo", "hello"},
+				},
+			},
+		},
+	}
+	invalid := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid-dag", Namespace: "default"},
+		Spec: v1alpha1.DAGSpec{
+			Schedule: "not a schedule",
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "only",
+					Image:   "alpine:latest",
+					Command: []string{"echo", "hello"},
+				},
+			},
+		},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dag, invalid).WithStatusSubresource(dag, invalid).Build()
+	r := &DAGReconciler{Client: k8sClient, Scheme: scheme, DbManager: sqliteMgr}
+
+	reconcile := func(obj *v1alpha1.DAG) *v1alpha1.DAG {
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		if err != nil {
+			t.Fatalf("failed to reconcile %s: %v", obj.Name, err)
+		}
+
+		got := &v1alpha1.DAG{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), got); err != nil {
+			t.Fatalf("failed to get %s: %v", obj.Name, err)
+		}
+
+		if meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.DAGConditionValid) && result.RequeueAfter != dagStatusRefreshInterval {
+			t.Fatalf("expected a valid DAG to be requeued after %s, got %s", dagStatusRefreshInterval, result.RequeueAfter)
+		}
+
+		return got
+	}
+
+	got := reconcile(dag)
+	if got.Status.Version == nil || *got.Status.Version != 0 || got.Status.Hash == "" || got.Status.NextScheduledTime == nil || got.Status.LastRun != nil {
+		t.Fatalf("expected the first stored version with a next scheduled time and no runs, got %+v", got.Status)
+	}
+	if got.Status.ObservedGeneration != got.Generation {
+		t.Fatalf("expected observedGeneration %d, got %d", got.Generation, got.Status.ObservedGeneration)
+	}
+	for _, condition := range []string{v1alpha1.DAGConditionValid, v1alpha1.DAGConditionScheduled} {
+		if !meta.IsStatusConditionTrue(got.Status.Conditions, condition) {
+			t.Fatalf("expected the %s condition to be True, got %+v", condition, got.Status.Conditions)
+		}
+	}
+	if !meta.IsStatusConditionFalse(got.Status.Conditions, v1alpha1.DAGConditionSuspended) {
+		t.Fatalf("expected the Suspended condition to be False, got %+v", got.Status.Conditions)
+	}
+
+	runId, err := sqliteMgr.CreateDAGRun(ctx, "status-run", &v1alpha1.DagRunSpec{DagName: "status-dag"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create dag run: %v", err)
+	}
+
+	// the requeue picks up the new run without the spec changing
+	got = reconcile(got)
+	if got.Status.LastRun == nil || got.Status.LastRun.DagRunId != runId || got.Status.LastRun.Name != "status-run" || got.Status.LastRun.Phase != v1alpha1.DagRunRunning {
+		t.Fatalf("expected the last run to be the running status-run, got %+v", got.Status.LastRun)
+	}
+
+	got = reconcile(invalid)
+	if got.Status.Phase != "Failed" || !meta.IsStatusConditionFalse(got.Status.Conditions, v1alpha1.DAGConditionValid) {
+		t.Fatalf("expected the invalid DAG to be Failed with a False Valid condition, got %+v", got.Status)
+	}
+}
@@ -19,6 +19,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"golang.org/x/sync/errgroup"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -117,14 +118,9 @@ func (r *DagRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			continue
 		}
 
-		if param.FromSecret != "" && paramDefault.IsSecret {
-			paramDefault.Value = param.FromSecret
-			paramMap[param.Name] = param
-			continue
-		}
-
-		if param.FromSecret == "" && !paramDefault.IsSecret {
-			paramDefault.Value = param.Value
+		// a reference may pick another secret, key or ConfigMap and parameters without a default take either,
+		// but a literal cannot stand in for a reference or the other way round
+		if paramDefault.Value == "" || param.IsReference() == (paramDefault.IsSecret || paramDefault.IsConfigMap) {
 			paramMap[param.Name] = param
 			continue
 		}
@@ -141,18 +137,61 @@ func (r *DagRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	var pvcName *string
 	if pvc != nil {
-		name, err := r.createPVC(ctx, &dagRun, pvc)
+		name := fmt.Sprintf(pvcNameFormat, dagRun.Name)
+		pvcName = &name
+	}
+
+	_, isSubDagRun := dagRun.Annotations[v1alpha1.ParentTaskRunAnnotation]
+
+	var runId int
+	status := "running"
+	if isSubDagRun {
+		// child runs are part of their parent run, which the concurrency policy has already let through
+		runId, err = r.DbManager.CreateDAGRun(ctx, dagRun.Name, &dagRun.Spec, paramMap, pvcName)
+		if err != nil {
+			log.Log.Error(err, "failed to create dag run entry", "dag_id", dagRun.Spec.DagName)
+			return ctrl.Result{}, err
+		}
+	} else {
+		admission, err := r.DbManager.StartDAGRun(ctx, dagRun.Name, &dagRun.Spec, paramMap, pvcName)
 		if err != nil {
+			log.Log.Error(err, "failed to create dag run entry", "dag_id", dagRun.Spec.DagName)
 			return ctrl.Result{}, err
 		}
 
-		pvcName = &name
+		if admission.Status == "skipped" {
+			log.Log.Info("dag run skipped as the dag has reached its maxActiveRuns", "dag_id", dagRun.Spec.DagName, "name", dagRun.Name)
+			return r.removeSkippedDagRun(ctx, &dagRun)
+		}
+
+		r.stopReplacedRuns(ctx, admission.Replaced)
+		runId, status = admission.RunId, admission.Status
 	}
 
-	runId, err := r.DbManager.CreateDAGRun(ctx, dagRun.Name, &dagRun.Spec, paramMap, pvcName)
-	if err != nil {
-		log.Log.Error(err, "failed to create dag run entry", "dag_id", dagRun.Spec.DagName)
-		return ctrl.Result{}, err
+	if pvc != nil {
+		if _, err := r.createPVC(ctx, &dagRun, pvc); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// runs started by a dagRef task report back to the task run waiting on them
+	if value, ok := dagRun.Annotations[v1alpha1.ParentTaskRunAnnotation]; ok {
+		parentTaskRunId, err := strconv.Atoi(value)
+		if err != nil {
+			log.Log.Error(err, "invalid parent task run annotation", "dag_id", dagRun.Spec.DagName, "value", value)
+			return ctrl.Result{}, nil
+		}
+
+		if err := r.DbManager.LinkSubDagRun(ctx, runId, parentTaskRunId); err != nil {
+			log.Log.Error(err, "failed to link dag run to parent task run", "dag_id", dagRun.Spec.DagName, "parentTaskRunId", parentTaskRunId)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// queued runs have their starting tasks enqueued by the scheduler once a slot frees up
+	if status == "queued" {
+		log.Log.Info("dag run queued until an active run finishes", "dag_id", dagRun.Spec.DagName, "runId", runId)
+		return r.setDagRunId(ctx, &dagRun, runId)
 	}
 
 	tasks, err := r.DbManager.GetStartingTasks(ctx, dagRun.Spec.DagName, runId)
@@ -165,17 +204,6 @@ func (r *DagRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	// Enqueue starting tasks as pending Task_Runs so workers will claim them
 	for _, task := range tasks {
-		// Update defaults with values from DagRun
-		for i := 0; i < len(task.Parameters); i++ {
-			if param, ok := paramMap[task.Parameters[i].Name]; ok {
-				if task.Parameters[i].IsSecret {
-					task.Parameters[i].Value = param.FromSecret
-				} else {
-					task.Parameters[i].Value = param.Value
-				}
-			}
-		}
-
 		exists, err := r.DbManager.TaskRunExists(ctx, runId, task.Id)
 		if err != nil {
 			log.Log.Error(err, "failed to check for existing pending task run", "dag_id", dagRun.Spec.DagName, "task_id", task.Id)
@@ -196,10 +224,14 @@ func (r *DagRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		log.Log.Info("enqueued pending task", "dag_id", dagRun.Spec.DagName, "task_id", task.Id, "taskRunId", taskRunId)
 	}
 
+	return r.setDagRunId(ctx, &dagRun, runId)
+}
+
+func (r *DagRunReconciler) setDagRunId(ctx context.Context, dagRun *kontrolerv1alpha1.DagRun, runId int) (ctrl.Result, error) {
 	old := dagRun.DeepCopy()
 	dagRun.Status.DagRunId = runId
 	if old.Status.DagRunId != dagRun.Status.DagRunId {
-		if err := r.Status().Patch(ctx, &dagRun, client.MergeFrom(old)); err != nil {
+		if err := r.Status().Patch(ctx, dagRun, client.MergeFrom(old)); err != nil {
 			log.Log.Error(err, "failed to update DagRun status with runID", "dag_id", dagRun.Spec.DagName)
 			return ctrl.Result{}, err
 		}
@@ -208,6 +240,47 @@ func (r *DagRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	return ctrl.Result{}, nil
 }
 
+// removeSkippedDagRun deletes a DagRun that the concurrency policy of its DAG did not let start.
+// No run was recorded for it, so the finalizer is dropped first as there is nothing to clean up
+func (r *DagRunReconciler) removeSkippedDagRun(ctx context.Context, dagRun *kontrolerv1alpha1.DagRun) (ctrl.Result, error) {
+	old := dagRun.DeepCopy()
+	dagRun.Finalizers = removeString(dagRun.Finalizers, dagRunFinalizer)
+	if err := r.Patch(ctx, dagRun, client.MergeFrom(old)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Delete(ctx, dagRun); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// stopReplacedRuns deletes the pods and workspaces of runs that were suspended to make room for a
+// newer run. The pods keep their log collection finalizer so the workers still gather their logs
+func (r *DagRunReconciler) stopReplacedRuns(ctx context.Context, runs []db.StoppedDagRun) {
+	for _, run := range runs {
+		log.Log.Info("dag run replaced by a newer run", "dagRunId", run.RunId, "dag_id", run.DagName, "taskRuns", len(run.TaskRuns))
+
+		for _, taskRun := range run.TaskRuns {
+			for _, pod := range taskRun.Pods {
+				obj := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+				if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+					log.Log.Error(err, "failed to delete pod of replaced run", "podName", pod.Name, "podNamespace", pod.Namespace, "dagRunId", run.RunId)
+				}
+			}
+		}
+
+		if run.PVCName == nil {
+			continue
+		}
+
+		if err := deletePVCByNameAndNamespace(ctx, r.Client, *run.PVCName, run.Namespace); client.IgnoreNotFound(err) != nil {
+			log.Log.Error(err, "failed to delete pvc of replaced run", "pvcName", *run.PVCName, "namespace", run.Namespace, "dagRunId", run.RunId)
+		}
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DagRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
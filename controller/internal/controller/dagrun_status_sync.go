@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type dagRunStatusSync struct {
+	client    client.Client
+	dbManager db.DBDAGManager
+	logger    logr.Logger
+}
+
+// RunDagRunStatusSync periodically copies the state of DAG runs from the database onto the status of their DagRuns,
+// so kubectl get and kubectl wait can follow a run. DagRuns are left alone once they are complete.
+func RunDagRunStatusSync(ctx context.Context, c client.Client, dbManager db.DBDAGManager, interval time.Duration) error {
+	s := &dagRunStatusSync{
+		client:    c,
+		dbManager: dbManager,
+		logger:    log.FromContext(ctx).WithName("dagrun-status-sync"),
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("stopping dagrun status sync")
+			return nil
+		case <-ticker.C:
+			s.syncDagRuns(ctx)
+		}
+	}
+}
+
+func (s *dagRunStatusSync) syncDagRuns(ctx context.Context) {
+	var dagRuns v1alpha1.DagRunList
+	if err := s.client.List(ctx, &dagRuns); err != nil {
+		s.logger.Error(err, "failed to list dag runs")
+		return
+	}
+
+	for i := range dagRuns.Items {
+		dagRun := &dagRuns.Items[i]
+
+		// skip runs the dagrun controller has not recorded yet and runs that are already complete
+		if dagRun.Status.DagRunId == 0 || meta.IsStatusConditionTrue(dagRun.Status.Conditions, v1alpha1.DagRunConditionComplete) {
+			continue
+		}
+
+		if err := s.syncDagRun(ctx, dagRun); err != nil {
+			s.logger.Error(err, "failed to sync dag run status", "name", dagRun.Name, "namespace", dagRun.Namespace, "runId", dagRun.Status.DagRunId)
+		}
+	}
+}
+
+func (s *dagRunStatusSync) syncDagRun(ctx context.Context, dagRun *v1alpha1.DagRun) error {
+	summary, err := s.dbManager.GetDagRunSummary(ctx, dagRun.Status.DagRunId)
+	if err != nil {
+		if errors.Is(err, db.ErrDagRunNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	old := dagRun.DeepCopy()
+	setDagRunStatus(&dagRun.Status, summary)
+	if equality.Semantic.DeepEqual(old.Status, dagRun.Status) {
+		return nil
+	}
+
+	return s.client.Status().Patch(ctx, dagRun, client.MergeFrom(old))
+}
+
+// setDagRunStatus fills in the status of a DagRun from the state of its run in the database
+func setDagRunStatus(status *v1alpha1.DagRunStatus, summary *db.DagRunSummary) {
+	status.Phase = dagRunPhase(summary)
+	status.StartTime = metaTime(summary.StartTime)
+	status.CompletionTime = metaTime(summary.FinishTime)
+	status.Tasks = v1alpha1.DagRunTaskCounts{
+		Pending:   summary.TaskRuns["pending"] + summary.TaskRuns["pending_dag"] + summary.TaskRuns["waiting"],
+		Running:   summary.TaskRuns["running"],
+		Succeeded: summary.TaskRuns["success"],
+		Cached:    summary.TaskRuns["cached"],
+		Failed:    summary.TaskRuns["failed"],
+		Skipped:   summary.TaskRuns["skipped"],
+		Suspended: summary.TaskRuns["suspended"],
+		TimedOut:  summary.TaskRuns["timed_out"],
+	}
+
+	reason := string(status.Phase)
+	if summary.FinishTime == nil {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    v1alpha1.DagRunConditionComplete,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: fmt.Sprintf("run is %s", summary.Status),
+		})
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    v1alpha1.DagRunConditionSucceeded,
+			Status:  metav1.ConditionUnknown,
+			Reason:  reason,
+			Message: "run has not finished",
+		})
+		return
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    v1alpha1.DagRunConditionComplete,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: fmt.Sprintf("run finished as %s", summary.Status),
+	})
+
+	succeeded := metav1.ConditionFalse
+	if status.Phase == v1alpha1.DagRunSucceeded {
+		succeeded = metav1.ConditionTrue
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    v1alpha1.DagRunConditionSucceeded,
+		Status:  succeeded,
+		Reason:  reason,
+		Message: fmt.Sprintf("%d of %d task runs succeeded", status.Tasks.Succeeded+status.Tasks.Cached, totalTaskRuns(summary)),
+	})
+}
+
+// dagRunPhase maps the status of a run to its phase. A run that failed but still has
+// tasks left running, e.g. ones with the all_done trigger rule, stays Running until they finish
+func dagRunPhase(summary *db.DagRunSummary) v1alpha1.DagRunPhase {
+	if summary.FinishTime == nil {
+		if summary.Status == "queued" {
+			return v1alpha1.DagRunQueued
+		}
+		return v1alpha1.DagRunRunning
+	}
+
+	switch summary.Status {
+	case "success":
+		return v1alpha1.DagRunSucceeded
+	case "suspended":
+		return v1alpha1.DagRunSuspended
+	case statusTimedOut:
+		return v1alpha1.DagRunTimedOut
+	default:
+		return v1alpha1.DagRunFailed
+	}
+}
+
+func totalTaskRuns(summary *db.DagRunSummary) int {
+	total := 0
+	for _, count := range summary.TaskRuns {
+		total += count
+	}
+	return total
+}
+
+// metaTime converts a time read from the database, truncated to the whole seconds the API server keeps
+func metaTime(t *time.Time) *metav1.Time {
+	if t == nil {
+		return nil
+	}
+
+	mt := metav1.NewTime(t.UTC().Truncate(time.Second))
+	return &mt
+}
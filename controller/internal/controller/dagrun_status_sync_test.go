@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+
+	"github.com/go-logr/logr"
+	cron "github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestDagRunStatusSync_FollowsRun checks that a DagRun status follows its run from running to succeeded.
+func TestDagRunStatusSync_FollowsRun(t *testing.T) {
+	ctx := context.Background()
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	sqliteMgr, _, err := db.NewSqliteManager(ctx, &parser, &db.SQLiteConfig{DBPath: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to create sqlite manager: %v", err)
+	}
+	if err := sqliteMgr.InitaliseDatabase(ctx); err != nil {
+		t.Fatalf("failed to initalise sqlite db: %v", err)
+	}
+
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "status-dag"},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "only",
+					Image:   "alpine:latest",
+					Command: []string{"echo", "hello"},
+				},
+			},
+		},
+	}
+
+	if err := sqliteMgr.InsertDAG(ctx, dag, "default"); err != nil {
+		t.Fatalf("failed to insert dag: %v", err)
+	}
+
+	runId, err := sqliteMgr.CreateDAGRun(ctx, "status-run", &v1alpha1.DagRunSpec{DagName: "status-dag"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create dag run: %v", err)
+	}
+
+	tasks, err := sqliteMgr.GetStartingTasks(ctx, "status-dag", runId)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("failed to get starting tasks: %v", err)
+	}
+
+	taskRunId, err := sqliteMgr.AddPendingTaskRun(ctx, runId, tasks[0].Id)
+	if err != nil {
+		t.Fatalf("failed to add pending task run: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+
+	dagRun := &v1alpha1.DagRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "status-run", Namespace: "default"},
+		Spec:       v1alpha1.DagRunSpec{DagName: "status-dag"},
+		Status:     v1alpha1.DagRunStatus{DagRunId: runId},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dagRun).WithStatusSubresource(dagRun).Build()
+	s := &dagRunStatusSync{client: k8sClient, dbManager: sqliteMgr, logger: logr.Discard()}
+
+	s.syncDagRuns(ctx)
+
+	got := &v1alpha1.DagRun{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(dagRun), got); err != nil {
+		t.Fatalf("failed to get dag run: %v", err)
+	}
+
+	if got.Status.Phase != v1alpha1.DagRunRunning || got.Status.StartTime == nil || got.Status.CompletionTime != nil {
+		t.Fatalf("expected a running dag run with only a start time, got %+v", got.Status)
+	}
+	if got.Status.Tasks.Pending != 1 {
+		t.Fatalf("expected 1 pending task run, got %d", got.Status.Tasks.Pending)
+	}
+	if !meta.IsStatusConditionFalse(got.Status.Conditions, v1alpha1.DagRunConditionComplete) {
+		t.Fatalf("expected the Complete condition to be False, got %+v", got.Status.Conditions)
+	}
+
+	finished := time.Now().Add(time.Minute)
+	if err := sqliteMgr.MarkPodStatus(ctx, types.UID("status-uid"), "status-pod", taskRunId, corev1.PodSucceeded, finished, nil, "default"); err != nil {
+		t.Fatalf("failed to mark pod status: %v", err)
+	}
+	if _, err := sqliteMgr.MarkSuccessAndGetNextTasks(ctx, taskRunId); err != nil {
+		t.Fatalf("failed to mark task run as successful: %v", err)
+	}
+
+	s.syncDagRuns(ctx)
+
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(dagRun), got); err != nil {
+		t.Fatalf("failed to get dag run: %v", err)
+	}
+
+	if got.Status.Phase != v1alpha1.DagRunSucceeded || got.Status.Tasks.Succeeded != 1 {
+		t.Fatalf("expected a succeeded dag run with 1 succeeded task run, got %+v", got.Status)
+	}
+	if got.Status.CompletionTime == nil || got.Status.CompletionTime.Unix() != finished.Unix() {
+		t.Fatalf("expected the completion time to be when the pod finished, got %v", got.Status.CompletionTime)
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.DagRunConditionComplete) ||
+		!meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.DagRunConditionSucceeded) {
+		t.Fatalf("expected the Complete and Succeeded conditions to be True, got %+v", got.Status.Conditions)
+	}
+}
@@ -78,6 +78,26 @@ func (r *DagTaskReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, r.handleDeletion(ctx, task.Name, req.NamespacedName.Namespace)
 	}
 
+	if err := task.Spec.Backoff.Validate(); err != nil {
+		log.Log.Error(err, "invalid backoff", "controller", "dagTask", "taskName", task.Name, "namespace", req.NamespacedName.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	if err := task.Spec.PodTemplate.Validate(task.Name); err != nil {
+		log.Log.Error(err, "invalid podTemplate", "controller", "dagTask", "taskName", task.Name, "namespace", req.NamespacedName.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	if err := task.Spec.CheckTemplates(); err != nil {
+		log.Log.Error(err, "invalid template", "controller", "dagTask", "taskName", task.Name, "namespace", req.NamespacedName.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	if err := kontrolerv1alpha1.ValidateArtifacts(task.Name, task.Spec.Artifacts); err != nil {
+		log.Log.Error(err, "invalid artifacts", "controller", "dagTask", "taskName", task.Name, "namespace", req.NamespacedName.Namespace)
+		return ctrl.Result{}, nil
+	}
+
 	// Store the DAG object in the database
 	if err := r.DbManager.AddTask(ctx, &task, req.NamespacedName.Namespace); err != nil {
 		if err.Error() == "applying the same task" {
@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kontrolerv1alpha1 "kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+)
+
+// PoolReconciler reconciles a Pool object
+type PoolReconciler struct {
+	client.Client
+	DbManager db.DBDAGManager
+	Scheme    *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=pools,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=pools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=pools/finalizers,verbs=update
+
+// Reconcile stores the slots of a pool in the database, where workers claim the tasks in
+// the pool against them. Deleted pools are removed, leaving their tasks waiting to be claimed
+func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	log.Log.Info("reconcile event", "controller", "pool", "req.Name", req.Name, "req.Namespace", req.Namespace)
+
+	var pool kontrolerv1alpha1.Pool
+	if err := r.Get(ctx, req.NamespacedName, &pool); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.handleDeletion(ctx, req.Name, req.Namespace)
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	if !pool.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.handleDeletion(ctx, pool.Name, req.Namespace)
+	}
+
+	if err := r.DbManager.UpsertPool(ctx, &pool, req.Namespace); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kontrolerv1alpha1.Pool{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
+
+func (r *PoolReconciler) handleDeletion(ctx context.Context, name, namespace string) error {
+	log.Log.Info("reconcile deletion", "controller", "pool", "req.Namespace", namespace, "poolName", name)
+
+	return r.DbManager.DeletePool(ctx, name, namespace)
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+	"kontroler-controller/internal/metrics"
+	"kontroler-controller/internal/webhook"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const statusTimedOut = "timed_out"
+
+type timeoutReconciler struct {
+	clientset       kubernetes.Interface
+	dbManager       db.DBDAGManager
+	webhookNotifier webhook.WebhookNotifier
+	logger          logr.Logger
+}
+
+// RunTimeoutReconciler periodically stops the task runs and DAG runs that have gone past their timeout.
+// Their pods are deleted and they are marked as timed_out, downstream tasks then react the same way they would to a failed task.
+func RunTimeoutReconciler(ctx context.Context, clientset kubernetes.Interface, dbManager db.DBDAGManager, webhookChan chan webhook.WebhookPayload, interval time.Duration) error {
+	r := &timeoutReconciler{
+		clientset:       clientset,
+		dbManager:       dbManager,
+		webhookNotifier: webhook.NewWebhookNotifier(webhookChan),
+		logger:          log.FromContext(ctx).WithName("timeout-reconciler"),
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("stopping timeout reconciler")
+			return nil
+		case <-ticker.C:
+			// runs go first so their tasks are reported as part of the run timing out
+			r.timeOutDagRuns(ctx)
+			r.timeOutTaskRuns(ctx)
+		}
+	}
+}
+
+func (r *timeoutReconciler) timeOutDagRuns(ctx context.Context) {
+	runs, err := r.dbManager.TimeOutDagRuns(ctx)
+	if err != nil {
+		r.logger.Error(err, "failed to time out dag runs")
+		return
+	}
+
+	for _, run := range runs {
+		r.logger.Info("dag run timed out", "runId", run.RunId, "dagName", run.DagName, "namespace", run.Namespace, "taskRuns", len(run.TaskRuns))
+		metrics.RecordDagRunTimeout(run.Namespace, run.DagName)
+
+		hook := r.getWebhook(ctx, run.RunId)
+		for _, taskRun := range run.TaskRuns {
+			r.stopTaskRun(ctx, taskRun, hook)
+		}
+
+		if hook != nil {
+			go r.webhookNotifier.NotifyDagRun(run.DagName, statusTimedOut, run.RunId, hook.URL, hook.VerifySSL)
+		}
+
+		r.deletePVC(ctx, run.Namespace, run.PVCName)
+	}
+}
+
+func (r *timeoutReconciler) timeOutTaskRuns(ctx context.Context) {
+	taskRuns, err := r.dbManager.TimeOutTaskRuns(ctx)
+	if err != nil {
+		r.logger.Error(err, "failed to time out task runs")
+		return
+	}
+
+	for _, taskRun := range taskRuns {
+		r.logger.Info("task run timed out", "taskRunId", taskRun.TaskRunId, "runId", taskRun.RunId, "taskName", taskRun.TaskName)
+
+		hook := r.getWebhook(ctx, taskRun.RunId)
+		r.stopTaskRun(ctx, taskRun, hook)
+
+		suspended, err := r.dbManager.MarkConnectingTasksAsSuspended(ctx, taskRun.RunId, taskRun.TaskRunId)
+		if err != nil {
+			r.logger.Error(err, "failed to mark connecting tasks as suspended", "taskRunId", taskRun.TaskRunId)
+			continue
+		}
+
+		if hook != nil {
+			for _, taskName := range suspended {
+				go r.webhookNotifier.NotifyTaskRun(taskName, "suspended", taskRun.RunId, taskRun.TaskRunId, hook.URL, hook.VerifySSL)
+			}
+		}
+
+		// tasks with trigger rules such as all_done or one_failed may now be able to run
+		tasks, err := r.dbManager.GetReadyTasks(ctx, taskRun.RunId)
+		if err != nil {
+			r.logger.Error(err, "failed to get ready tasks", "runId", taskRun.RunId)
+			continue
+		}
+
+		for _, task := range tasks {
+			if _, err := r.dbManager.AddPendingTaskRun(ctx, taskRun.RunId, task.Id); err != nil {
+				r.logger.Error(err, "failed to add pending task run", "runId", taskRun.RunId, "taskId", task.Id)
+			}
+		}
+
+		if len(tasks) > 0 {
+			continue
+		}
+
+		done, err := r.dbManager.CheckIfAllTasksDone(ctx, taskRun.RunId)
+		if err != nil {
+			r.logger.Error(err, "failed to check if dag run is complete", "runId", taskRun.RunId)
+			continue
+		}
+
+		if done {
+			r.deletePVC(ctx, taskRun.Namespace, taskRun.PVCName)
+		}
+	}
+}
+
+// stopTaskRun deletes the pods of a task run that timed out and reports it. The pods keep
+// their log collection finalizer so the workers still gather their logs as they terminate.
+func (r *timeoutReconciler) stopTaskRun(ctx context.Context, taskRun db.StoppedTaskRun, hook *v1alpha1.Webhook) {
+	metrics.RecordTaskOutcome(taskRun.Namespace, taskRun.DagName, taskRun.TaskName, statusTimedOut)
+
+	for _, pod := range taskRun.Pods {
+		if err := r.clientset.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			r.logger.Error(err, "failed to delete pod of timed out task run", "pod", pod.Name, "ns", pod.Namespace, "taskRunId", taskRun.TaskRunId)
+		}
+	}
+
+	if hook != nil {
+		go r.webhookNotifier.NotifyTaskRun(taskRun.TaskName, statusTimedOut, taskRun.RunId, taskRun.TaskRunId, hook.URL, hook.VerifySSL)
+	}
+}
+
+// getWebhook returns the webhook of a run, nil when it has none
+func (r *timeoutReconciler) getWebhook(ctx context.Context, runId int) *v1alpha1.Webhook {
+	hook, err := r.dbManager.GetWebhookDetails(ctx, runId)
+	if err != nil {
+		r.logger.Error(err, "failed to get webhook details", "runId", runId)
+		return nil
+	}
+
+	if hook.URL == "" {
+		return nil
+	}
+
+	return hook
+}
+
+func (r *timeoutReconciler) deletePVC(ctx context.Context, namespace string, pvcName *string) {
+	if pvcName == nil {
+		return
+	}
+
+	pvcs := r.clientset.CoreV1().PersistentVolumeClaims(namespace)
+	pvc, err := pvcs.Get(ctx, *pvcName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.logger.Error(err, "failed to get PVC", "pvc", *pvcName, "ns", namespace)
+		}
+		return
+	}
+
+	pvc.Finalizers = []string{}
+	if _, err := pvcs.Update(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+		r.logger.Error(err, "failed to remove finalizers from PVC", "pvc", *pvcName, "ns", namespace)
+		return
+	}
+
+	if err := pvcs.Delete(ctx, *pvcName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		r.logger.Error(err, "failed to delete PVC", "pvc", *pvcName, "ns", namespace)
+	}
+}
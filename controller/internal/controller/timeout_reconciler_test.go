@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+	"kontroler-controller/internal/webhook"
+
+	cron "github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// TestRunTimeoutReconciler_StopsTimedOutRuns checks that a run past its runTimeout has its pods deleted and its task runs marked as timed_out.
+func TestRunTimeoutReconciler_StopsTimedOutRuns(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := kfake.NewSimpleClientset()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "slow-pod",
+			Namespace:  "default",
+			Labels:     map[string]string{"managed-by": "kontroler", "kontroler/type": "task"},
+			Finalizers: []string{"kontroler/logcollection"},
+		},
+	}
+
+	if _, err := client.CoreV1().Pods("default").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	sqliteMgr, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{DBPath: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to create sqlite manager: %v", err)
+	}
+	if err := sqliteMgr.InitaliseDatabase(context.Background()); err != nil {
+		t.Fatalf("failed to initalise sqlite db: %v", err)
+	}
+
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "slow-dag"},
+		Spec: v1alpha1.DAGSpec{
+			RunTimeout: &metav1.Duration{Duration: time.Second},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "slow",
+					Image:   "alpine:latest",
+					Command: []string{"sleep", "60"},
+				},
+			},
+		},
+	}
+
+	if err := sqliteMgr.InsertDAG(ctx, dag, "default"); err != nil {
+		t.Fatalf("failed to insert dag: %v", err)
+	}
+
+	runId, err := sqliteMgr.CreateDAGRun(ctx, "slow-run", &v1alpha1.DagRunSpec{DagName: "slow-dag"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create dag run: %v", err)
+	}
+
+	tasks, err := sqliteMgr.GetStartingTasks(ctx, "slow-dag", runId)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("failed to get starting tasks: %v", err)
+	}
+
+	taskRunId, err := sqliteMgr.AddPendingTaskRun(ctx, runId, tasks[0].Id)
+	if err != nil {
+		t.Fatalf("failed to add pending task run: %v", err)
+	}
+
+	if err := sqliteMgr.MarkPodStatus(ctx, types.UID("slow-uid"), "slow-pod", taskRunId, corev1.PodRunning, time.Now(), nil, "default"); err != nil {
+		t.Fatalf("failed to mark pod status: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = RunTimeoutReconciler(ctx, client, sqliteMgr, make(chan webhook.WebhookPayload, 10), 500*time.Millisecond)
+		close(done)
+	}()
+
+	// the run times out within two seconds as the database only stores whole seconds
+	time.Sleep(2500 * time.Millisecond)
+	cancel()
+	<-done
+
+	status, err := sqliteMgr.GetTaskRunStatus(context.Background(), taskRunId)
+	if err != nil {
+		t.Fatalf("failed to get task run status: %v", err)
+	}
+	if status != "timed_out" {
+		t.Fatalf("expected task run to be timed_out, got %s", status)
+	}
+
+	deleted, err := client.CoreV1().Pods("default").Get(context.Background(), "slow-pod", metav1.GetOptions{})
+	if err == nil && deleted.DeletionTimestamp == nil {
+		t.Fatalf("expected pod to be deleted, but still exists")
+	}
+}
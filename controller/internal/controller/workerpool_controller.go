@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -14,6 +15,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	kontrolerv1alpha1 "kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/config"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -24,6 +26,11 @@ import (
 type WorkerPoolReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// LogStore and Artifacts of the controller, workers store logs and build task pods the same way
+	LogStore  config.LogStore
+	Artifacts config.Artifacts
+	// Sensors limits what the sensors of workers can reach, as it does for the controller
+	Sensors config.Sensors
 }
 
 const (
@@ -209,6 +216,9 @@ func (r *WorkerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			envs = append(envs, corev1.EnvVar{Name: "DB_SECRET_NAME", Value: wp.Spec.DBSecretRef})
 		}
 
+		envs = append(envs, r.storeEnvs()...)
+		envs = append(envs, r.sensorEnvs()...)
+
 		// build container spec
 		// ensure selector matches labels
 		dep.Spec.Selector = &metav1.LabelSelector{MatchLabels: dep.Labels}
@@ -225,6 +235,20 @@ func (r *WorkerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			},
 		}
 
+		// workers read and write a filesystem store through the volume task pods use for artifacts
+		if r.LogStore.StoreType == "filesystem" && r.Artifacts.ClaimName != "" {
+			dep.Spec.Template.Spec.Volumes = []corev1.Volume{{
+				Name: "log-store",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: r.Artifacts.ClaimName},
+				},
+			}}
+			dep.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{
+				Name:      "log-store",
+				MountPath: r.LogStore.FileSystem.BaseDir,
+			}}
+		}
+
 		// merge PodTemplate from spec if provided
 		if wp.Spec.PodTemplate != nil {
 			pt := wp.Spec.PodTemplate
@@ -241,6 +265,11 @@ func (r *WorkerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 				dep.Spec.Template.Spec.ServiceAccountName = defaultSA
 			}
 
+			// priority class
+			if pt.PriorityClassName != "" {
+				dep.Spec.Template.Spec.PriorityClassName = pt.PriorityClassName
+			}
+
 			// automount service account token
 			if pt.AutomountServiceAccountToken != nil {
 				a := *pt.AutomountServiceAccountToken
@@ -276,37 +305,17 @@ func (r *WorkerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 				dep.Spec.Template.Spec.Tolerations = tols
 			}
 
-			// volumes
-			if len(pt.Volumes) > 0 {
-				vols := dep.Spec.Template.Spec.Volumes
-				for _, v := range pt.Volumes {
-					vol := corev1.Volume{Name: v.Name}
-					if v.EmptyDir != nil {
-						vol.VolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
-					} else if v.PersistentVolumeClaim != nil {
-						vol.VolumeSource = corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim.ClaimName}}
-					}
-					vols = append(vols, vol)
-				}
-				dep.Spec.Template.Spec.Volumes = vols
-			}
-
-			// container volume mounts
-			if len(pt.VolumeMounts) > 0 {
-				mounts := dep.Spec.Template.Spec.Containers[0].VolumeMounts
-				for _, vm := range pt.VolumeMounts {
-					mounts = append(mounts, corev1.VolumeMount{Name: vm.Name, MountPath: vm.MountPath, ReadOnly: vm.ReadOnly})
-				}
-				dep.Spec.Template.Spec.Containers[0].VolumeMounts = mounts
-			}
+			// volumes and container volume mounts
+			parts := pt.ToK8sParts()
+			dep.Spec.Template.Spec.Volumes = append(dep.Spec.Template.Spec.Volumes, parts.Volumes...)
+			dep.Spec.Template.Spec.Containers[0].VolumeMounts = append(dep.Spec.Template.Spec.Containers[0].VolumeMounts, parts.VolumeMounts...)
 
-			// security context (only FSGroup supported)
+			// security contexts
 			if pt.SecurityContext != nil {
-				psc := &corev1.PodSecurityContext{}
-				if pt.SecurityContext.FSGroup != nil {
-					psc.FSGroup = pt.SecurityContext.FSGroup
-				}
-				dep.Spec.Template.Spec.SecurityContext = psc
+				dep.Spec.Template.Spec.SecurityContext = pt.SecurityContext.ToK8s()
+			}
+			if pt.ContainerSecurityContext != nil {
+				dep.Spec.Template.Spec.Containers[0].SecurityContext = pt.ContainerSecurityContext.ToK8s()
 			}
 
 			// resources for the first container if provided
@@ -402,3 +411,46 @@ func fmtIntPtr(p *int32) string {
 	}
 	return fmt.Sprintf("%d", *p)
 }
+
+// storeEnvs passes the log store and artifacts of the controller on to the workers
+func (r *WorkerPoolReconciler) storeEnvs() []corev1.EnvVar {
+	if r.LogStore.StoreType == "" {
+		return nil
+	}
+
+	envs := []corev1.EnvVar{{Name: config.EnvLogStoreType, Value: r.LogStore.StoreType}}
+	switch r.LogStore.StoreType {
+	case "filesystem":
+		envs = append(envs, corev1.EnvVar{Name: config.EnvLogDir, Value: r.LogStore.FileSystem.BaseDir})
+	case "s3":
+		envs = append(envs, corev1.EnvVar{Name: config.EnvS3BucketName, Value: r.LogStore.S3Configs.BucketName})
+		if r.LogStore.S3Configs.Endpoint != "" {
+			envs = append(envs, corev1.EnvVar{Name: config.EnvS3Endpoint, Value: r.LogStore.S3Configs.Endpoint})
+		}
+	}
+
+	if r.Artifacts.Image != "" {
+		envs = append(envs, corev1.EnvVar{Name: config.EnvArtifactsImage, Value: r.Artifacts.Image})
+		if r.Artifacts.CredentialsSecret != "" {
+			envs = append(envs, corev1.EnvVar{Name: config.EnvArtifactsCredentialsSecret, Value: r.Artifacts.CredentialsSecret})
+		}
+		if r.Artifacts.ClaimName != "" {
+			envs = append(envs, corev1.EnvVar{Name: config.EnvArtifactsClaimName, Value: r.Artifacts.ClaimName})
+		}
+	}
+
+	return envs
+}
+
+// sensorEnvs passes the sensor limits of the controller on to the workers, a deny list left unset keeps its default
+func (r *WorkerPoolReconciler) sensorEnvs() []corev1.EnvVar {
+	var envs []corev1.EnvVar
+	if len(r.Sensors.HTTP.Allow) > 0 {
+		envs = append(envs, corev1.EnvVar{Name: config.EnvSensorHTTPAllow, Value: strings.Join(r.Sensors.HTTP.Allow, ",")})
+	}
+	if r.Sensors.HTTP.Deny != nil {
+		envs = append(envs, corev1.EnvVar{Name: config.EnvSensorHTTPDeny, Value: strings.Join(r.Sensors.HTTP.Deny, ",")})
+	}
+
+	return envs
+}
@@ -0,0 +1,75 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// processDagCompletions starts the runs of DAGs whose onDagCompletion triggers wait for a run
+// that has finished. Runs are picked up from the database whichever way they finished, so runs
+// marked by MarkDAGRunOutcome start their downstream DAGs too. A finished run is only marked
+// handled once all its downstream runs exist, otherwise it is claimed again when its lease expires
+func (d *dagscheduler) processDagCompletions(ctx context.Context) {
+	runs, err := d.dbManager.ClaimFinishedDagRuns(ctx)
+	if err != nil {
+		log.Log.Error(err, "failed to find finished dag runs")
+		return
+	}
+
+	failed := map[int]bool{}
+	for _, run := range runs {
+		if err := d.startDownstreamDagRun(ctx, run); err != nil {
+			log.Log.Error(err, "failed to start downstream dag run", "dagName", run.DagName, "namespace", run.Namespace, "upstreamRun", run.UpstreamRunName)
+			failed[run.UpstreamRunId] = true
+		}
+	}
+
+	handled := map[int]bool{}
+	for _, run := range runs {
+		if failed[run.UpstreamRunId] || handled[run.UpstreamRunId] {
+			continue
+		}
+		handled[run.UpstreamRunId] = true
+
+		if err := d.dbManager.MarkDagRunCompletionHandled(ctx, run.UpstreamRunId); err != nil {
+			log.Log.Error(err, "failed to mark dag run completion as handled", "upstreamRun", run.UpstreamRunName, "namespace", run.UpstreamNamespace)
+		}
+	}
+}
+
+// startDownstreamDagRun creates the DagRun an upstream run finishing started, annotated with that run
+func (d *dagscheduler) startDownstreamDagRun(ctx context.Context, run db.DownstreamDagRun) error {
+	// the name follows from the upstream run and trigger, so a run claimed again after its lease expired
+	// finds the downstream runs created the first time rather than starting them again
+	name := fmt.Sprintf("dagrun-completion-%d-%d", run.UpstreamRunId, run.TriggerId)
+	dagRun := d.CreateDagRunObject(&db.DagInfo{DagName: run.DagName, Namespace: run.Namespace}, name)
+	dagRun.Spec.Parameters = run.Parameters
+	dagRun.Annotations = map[string]string{
+		v1alpha1.UpstreamDagRunAnnotation: run.UpstreamNamespace + "/" + run.UpstreamRunName,
+	}
+
+	if err := d.SubmitDagRun(ctx, dagRun, v1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			log.Log.Info("downstream dag run already created", "name", name, "namespace", run.Namespace, "upstreamRun", run.UpstreamRunName)
+			return nil
+		}
+
+		// a run the admission webhook rejects is rejected again on every attempt
+		if apierrors.IsForbidden(err) || apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) {
+			log.Log.Error(err, "downstream dag run rejected", "name", name, "namespace", run.Namespace, "dagName", run.DagName, "upstreamRun", run.UpstreamRunName)
+			return nil
+		}
+
+		return err
+	}
+
+	log.Log.Info("downstream dag run created", "name", name, "namespace", run.Namespace, "dagName", run.DagName, "upstreamRun", run.UpstreamRunName, "upstreamStatus", run.UpstreamStatus)
+	return nil
+}
@@ -2,6 +2,7 @@ package dag
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -26,12 +27,22 @@ const (
 
 	maxCreateDagRunRetries = 5
 	initialRetryBackoff    = 500 * time.Millisecond
+
+	// sub-DAG tasks, queued runs and finished runs are checked more often than schedules so they do not wait on the minute
+	subDagInterval = 5 * time.Second
 )
 
 // DagScheduler will every min run a check on the Database to determine if a dag should be started
-// For example, this could be based on a CronJob Schedule or a time window
+// For example, this could be based on a CronJob Schedule or a time window.
+// Missed schedule times are caught up on for DAGs that opt in, and backfill ranges are worked through in batches.
+// It also starts the child runs of dagRef tasks and completes those tasks once their child run finishes,
+// and starts queued runs once their DAG has a free slot
 type DagScheduler interface {
 	Run(context.Context)
+	// CreateDagRunObject constructs a DagRun object for the given dagInfo.
+	CreateDagRunObject(dagInfo *db.DagInfo, name string) *v1alpha1.DagRun
+	// SubmitDagRun creates the DagRun, retrying while the admission webhook is unavailable
+	SubmitDagRun(ctx context.Context, dagRun *v1alpha1.DagRun, opts v1.CreateOptions) error
 }
 
 type dagscheduler struct {
@@ -45,6 +56,11 @@ var gvr schema.GroupVersionResource = schema.GroupVersionResource{
 	Resource: "dagruns",
 }
 
+var pvcGVR schema.GroupVersionResource = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "persistentvolumeclaims",
+}
+
 func NewDagScheduler(dbManager db.DBDAGManager, dynamicClient dynamic.Interface) DagScheduler {
 	return &dagscheduler{
 		dbManager:     dbManager,
@@ -56,6 +72,9 @@ func (d *dagscheduler) Run(ctx context.Context) {
 	tmr := time.NewTimer(time.Minute)
 	defer tmr.Stop()
 
+	subDagTmr := time.NewTimer(subDagInterval)
+	defer subDagTmr.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -68,6 +87,12 @@ func (d *dagscheduler) Run(ctx context.Context) {
 				d.processDags(processCtx)
 			}()
 			tmr.Reset(time.Minute)
+		case <-subDagTmr.C:
+			// run inline so the same task run is never picked up twice
+			d.processSubDags(ctx)
+			d.processQueuedRuns(ctx)
+			d.processDagCompletions(ctx)
+			subDagTmr.Reset(subDagInterval)
 		}
 	}
 }
@@ -82,6 +107,15 @@ func (d *dagscheduler) processDags(ctx context.Context) {
 	}
 
 	log.Log.Info("number of dags found", "count", len(dagInfos))
+
+	backfills, err := d.dbManager.GetBackfillsToStartAndUpdate(ctx)
+	if err != nil {
+		log.Log.Error(err, "failed to find backfill runs to start")
+	} else if len(backfills) > 0 {
+		log.Log.Info("number of backfill runs found", "count", len(backfills))
+		dagInfos = append(dagInfos, backfills...)
+	}
+
 	opts := v1.CreateOptions{}
 
 	// wait group of goroutines to finish
@@ -100,47 +134,53 @@ func (d *dagscheduler) processDags(ctx context.Context) {
 }
 
 func (d *dagscheduler) createDagRun(ctx context.Context, dagInfo *db.DagInfo, opts v1.CreateOptions) {
-	log.Log.Info("attempting to create dagrun", "dagId", dagInfo.DagId)
+	log.Log.Info("attempting to create dagrun", "dagId", dagInfo.DagId, "scheduledTime", dagInfo.ScheduledTime)
 
 	name := "dagrun-" + uuid.New().String()
 	dagRun := d.CreateDagRunObject(dagInfo, name)
 
+	if err := d.SubmitDagRun(ctx, dagRun, opts); err != nil {
+		log.Log.Error(err, "failed to create DagRun", "dagId", dagInfo.DagId, "name", name, "namespace", dagInfo.Namespace)
+		return
+	}
+
+	log.Log.Info("DagRun created successfully", "dagId", dagInfo.DagId, "name", name, "namespace", dagInfo.Namespace)
+}
+
+// SubmitDagRun creates the DagRun, retrying while the admission webhook is unavailable
+func (d *dagscheduler) SubmitDagRun(ctx context.Context, dagRun *v1alpha1.DagRun, opts v1.CreateOptions) error {
 	unstructuredDagRun, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dagRun)
 	if err != nil {
-		log.Log.Error(err, "failed to convert DagRun to unstructured")
-		return
+		return fmt.Errorf("failed to convert DagRun to unstructured: %w", err)
 	}
 
 	unstructuredObj := &unstructured.Unstructured{Object: unstructuredDagRun}
 
 	backoff := initialRetryBackoff
-	for attempt := 1; attempt <= maxCreateDagRunRetries; attempt++ {
-		if _, err := d.dynamicClient.Resource(gvr).Namespace(dagInfo.Namespace).Create(ctx, unstructuredObj, opts); err != nil {
-			if attempt < maxCreateDagRunRetries && shouldRetryCreateDagRun(err) {
-				log.Log.Info("retrying DagRun creation after transient error", "dagId", dagInfo.DagId, "name", name, "namespace", dagInfo.Namespace, "attempt", attempt, "nextBackoff", backoff, "error", err.Error())
-
-				select {
-				case <-ctx.Done():
-					log.Log.Info("DagRun creation cancelled while waiting to retry", "dagId", dagInfo.DagId, "name", name, "namespace", dagInfo.Namespace)
-					return
-				case <-time.After(backoff):
-				}
-
-				if backoff < 5*time.Second {
-					backoff *= 2
-					if backoff > 5*time.Second {
-						backoff = 5 * time.Second
-					}
-				}
-				continue
-			}
+	for attempt := 1; ; attempt++ {
+		_, err := d.dynamicClient.Resource(gvr).Namespace(dagRun.Namespace).Create(ctx, unstructuredObj, opts)
+		if err == nil {
+			return nil
+		}
 
-			log.Log.Error(err, "failed to create DagRun", "dagId", dagInfo.DagId, "name", name, "namespace", dagInfo.Namespace, "attempt", attempt)
-			return
+		if attempt >= maxCreateDagRunRetries || !shouldRetryCreateDagRun(err) {
+			return err
 		}
 
-		log.Log.Info("DagRun created successfully", "dagId", dagInfo.DagId, "name", name, "namespace", dagInfo.Namespace, "attempt", attempt)
-		return
+		log.Log.Info("retrying DagRun creation after transient error", "name", dagRun.Name, "namespace", dagRun.Namespace, "attempt", attempt, "nextBackoff", backoff, "error", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff < 5*time.Second {
+			backoff *= 2
+			if backoff > 5*time.Second {
+				backoff = 5 * time.Second
+			}
+		}
 	}
 }
 
@@ -169,6 +209,11 @@ func shouldRetryCreateDagRun(err error) bool {
 
 // CreateDagRunObject constructs a DagRun object for the given dagInfo.
 func (d *dagscheduler) CreateDagRunObject(dagInfo *db.DagInfo, name string) *v1alpha1.DagRun {
+	var scheduledTime *v1.Time
+	if !dagInfo.ScheduledTime.IsZero() {
+		scheduledTime = &v1.Time{Time: dagInfo.ScheduledTime}
+	}
+
 	return &v1alpha1.DagRun{
 		TypeMeta: v1.TypeMeta{
 			APIVersion: apiVersion,
@@ -182,8 +227,9 @@ func (d *dagscheduler) CreateDagRunObject(dagInfo *db.DagInfo, name string) *v1a
 			},
 		},
 		Spec: v1alpha1.DagRunSpec{
-			DagName:    dagInfo.DagName,
-			Parameters: []v1alpha1.ParameterSpec{},
+			DagName:       dagInfo.DagName,
+			Parameters:    []v1alpha1.ParameterSpec{},
+			ScheduledTime: scheduledTime,
 		},
 	}
 }
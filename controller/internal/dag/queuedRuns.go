@@ -0,0 +1,29 @@
+package dag
+
+import (
+	"context"
+
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// processQueuedRuns starts the queued runs of DAGs that have a free slot, enqueuing their
+// starting tasks the same way the DagRun controller does for runs that start straight away
+func (d *dagscheduler) processQueuedRuns(ctx context.Context) {
+	runs, err := d.dbManager.StartQueuedDAGRuns(ctx)
+	if err != nil {
+		log.Log.Error(err, "failed to start queued dag runs")
+		return
+	}
+
+	for _, run := range runs {
+		log.Log.Info("starting queued dag run", "runId", run.RunId, "dagName", run.DagName, "namespace", run.Namespace)
+
+		tasks, err := d.dbManager.GetStartingTasks(ctx, run.DagName, run.RunId)
+		if err != nil {
+			log.Log.Error(err, "failed to get starting tasks for queued run", "runId", run.RunId, "dagName", run.DagName)
+			continue
+		}
+
+		d.allocateTasks(ctx, run.RunId, tasks)
+	}
+}
@@ -0,0 +1,153 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// processSubDags starts the child runs of dagRef tasks that are ready to run, then
+// completes the dagRef tasks whose child run has finished
+func (d *dagscheduler) processSubDags(ctx context.Context) {
+	subDagRuns, err := d.dbManager.StartSubDagRuns(ctx)
+	if err != nil {
+		log.Log.Error(err, "failed to find sub-dag tasks to start")
+	}
+
+	for _, subDagRun := range subDagRuns {
+		if err := d.startSubDagRun(ctx, subDagRun); err != nil {
+			log.Log.Error(err, "failed to start sub-dag run", "taskRunId", subDagRun.TaskRunId, "dagName", subDagRun.DagName)
+			d.failSubDagTask(ctx, subDagRun.RunId, subDagRun.TaskRunId)
+		}
+	}
+
+	finished, err := d.dbManager.GetFinishedSubDagRuns(ctx)
+	if err != nil {
+		log.Log.Error(err, "failed to find finished sub-dag runs")
+		return
+	}
+
+	for _, subDagRun := range finished {
+		log.Log.Info("sub-dag run finished", "taskRunId", subDagRun.TaskRunId, "childRunId", subDagRun.ChildRunId, "succeeded", subDagRun.Succeeded)
+
+		if subDagRun.Succeeded {
+			tasks, err := d.dbManager.MarkSuccessAndGetNextTasks(ctx, subDagRun.TaskRunId)
+			if err != nil {
+				log.Log.Error(err, "failed to mark sub-dag task as successful", "taskRunId", subDagRun.TaskRunId)
+				continue
+			}
+
+			d.allocateTasks(ctx, subDagRun.RunId, tasks)
+		} else {
+			d.failSubDagTask(ctx, subDagRun.RunId, subDagRun.TaskRunId)
+		}
+
+		d.deleteWorkspaceIfDone(ctx, subDagRun)
+	}
+}
+
+// startSubDagRun creates the child DagRun of a dagRef task, the DagRun controller
+// links it back to the task run through the parent annotation
+func (d *dagscheduler) startSubDagRun(ctx context.Context, subDagRun db.SubDagRun) error {
+	exists, _, err := d.dbManager.DagExists(ctx, subDagRun.DagName)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("dag %s does not exist", subDagRun.DagName)
+	}
+
+	// the name follows from the task run, so a task run started again after its lease expired
+	// finds the child run created the first time rather than starting a second one
+	name := "dagrun-subdag-" + strconv.Itoa(subDagRun.TaskRunId)
+	dagRun := d.CreateDagRunObject(&db.DagInfo{DagName: subDagRun.DagName, Namespace: subDagRun.Namespace}, name)
+	dagRun.Spec.Parameters = subDagRun.Parameters
+	// the child run keeps the priority its dagRef task was queued with, within the bounds a run can have
+	priority := min(max(subDagRun.Priority, v1alpha1.MinPriority), v1alpha1.MaxPriority)
+	dagRun.Spec.Priority = &priority
+	dagRun.Annotations = map[string]string{
+		v1alpha1.ParentTaskRunAnnotation: strconv.Itoa(subDagRun.TaskRunId),
+	}
+
+	if err := d.SubmitDagRun(ctx, dagRun, v1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			log.Log.Info("sub-dag run already created", "taskRunId", subDagRun.TaskRunId, "name", name, "namespace", subDagRun.Namespace)
+			return nil
+		}
+		return err
+	}
+
+	log.Log.Info("sub-dag run created", "taskRunId", subDagRun.TaskRunId, "name", name, "namespace", subDagRun.Namespace)
+	return nil
+}
+
+// failSubDagTask fails a dagRef task and lets its downstream tasks react the same way
+// they would to a failed pod
+func (d *dagscheduler) failSubDagTask(ctx context.Context, runId, taskRunId int) {
+	if err := d.dbManager.MarkTaskAsFailed(ctx, taskRunId); err != nil {
+		log.Log.Error(err, "failed to mark sub-dag task as failed", "taskRunId", taskRunId)
+		return
+	}
+
+	if _, err := d.dbManager.MarkConnectingTasksAsSuspended(ctx, runId, taskRunId); err != nil {
+		log.Log.Error(err, "failed to mark connecting tasks as suspended", "taskRunId", taskRunId)
+		return
+	}
+
+	tasks, err := d.dbManager.GetReadyTasks(ctx, runId)
+	if err != nil {
+		log.Log.Error(err, "failed to get ready tasks", "runId", runId)
+		return
+	}
+
+	d.allocateTasks(ctx, runId, tasks)
+}
+
+func (d *dagscheduler) allocateTasks(ctx context.Context, runId int, tasks []db.Task) {
+	for _, task := range tasks {
+		taskRunId, err := d.dbManager.AddPendingTaskRun(ctx, runId, task.Id)
+		if err != nil {
+			log.Log.Error(err, "failed to add pending task run", "dagRun_id", runId, "task_id", task.Id)
+			continue
+		}
+
+		log.Log.Info("enqueued pending task", "taskRunId", taskRunId, "task.Id", task.Id, "task.Name", task.Name)
+	}
+}
+
+// deleteWorkspaceIfDone removes the workspace of the parent run when the dagRef task
+// was the last one in it, as no pod finishing will do it instead
+func (d *dagscheduler) deleteWorkspaceIfDone(ctx context.Context, subDagRun db.FinishedSubDagRun) {
+	if subDagRun.PVCName == nil {
+		return
+	}
+
+	done, err := d.dbManager.CheckIfAllTasksDone(ctx, subDagRun.RunId)
+	if err != nil {
+		log.Log.Error(err, "failed to check if dag run is complete", "runId", subDagRun.RunId)
+		return
+	}
+
+	if !done {
+		return
+	}
+
+	pvcs := d.dynamicClient.Resource(pvcGVR).Namespace(subDagRun.Namespace)
+	if _, err := pvcs.Patch(ctx, *subDagRun.PVCName, types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), v1.PatchOptions{}); err != nil {
+		log.Log.Error(err, "failed to remove PVC finalizers", "pvc", *subDagRun.PVCName, "namespace", subDagRun.Namespace)
+		return
+	}
+
+	if err := pvcs.Delete(ctx, *subDagRun.PVCName, v1.DeleteOptions{}); err != nil {
+		log.Log.Error(err, "failed to delete PVC", "pvc", *subDagRun.PVCName, "namespace", subDagRun.Namespace, "dagRunId", subDagRun.RunId)
+	}
+}
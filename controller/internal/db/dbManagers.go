@@ -14,6 +14,15 @@ import (
 // Sentinel error returned when a Task_Run row cannot be found.
 var ErrTaskRunNotFound = errors.New("task run not found")
 
+// Sentinel error returned when a task run is no longer pending and claimed by the worker acting on it.
+var ErrTaskRunNotClaimed = errors.New("task run not claimed by worker")
+
+// Sentinel error returned when a DAG_Runs row cannot be found.
+var ErrDagRunNotFound = errors.New("dag run not found")
+
+// Sentinel error returned when no version of a DAG is stored.
+var ErrDagNotFound = errors.New("dag not found")
+
 type Task struct {
 	Id                  int
 	Name                string
@@ -24,14 +33,158 @@ type Task struct {
 	PodTemplate         *v1alpha1.PodTemplateSpec
 	Script              string
 	ScriptInjectorImage string
+	// Outputs declared by the task
+	Outputs []string
+	// Outputs published by the upstream tasks within the same run
+	UpstreamOutputs []TaskOutput
+	// Files or directories the task publishes through the object store
+	Artifacts []v1alpha1.ArtifactSpec
+	// Artifacts published by the upstream tasks within the same run
+	UpstreamArtifacts []TaskArtifact
+	// Index and value of the item when the task runs as an instance of a map task
+	MapIndex *int
+	MapItem  string
+	// Schedule time of the run, nil when it was not started by a schedule or backfill
+	ScheduledTime *time.Time
+	// Time the run stands for, its schedule time or else when it started
+	LogicalTime time.Time
+	// Values of the parameters of the run that are not read from secrets or ConfigMaps, for the templates of the task
+	RunParameters map[string]string
+	// Fingerprint of the definition of the task
+	Hash string
+	// How long a successful result of the task is reused for, 0 when it is not cached
+	CacheTTL time.Duration
+	// Condition the task waits for instead of running a pod, nil for tasks that run a pod
+	Sensor *v1alpha1.SensorSpec
 }
 
-type Parameter struct {
+type TaskOutput struct {
+	TaskName string
 	Name     string
-	IsSecret bool
 	Value    string
 }
 
+// TaskArtifact is an artifact published by a task run, MapIndex is set when the run was an instance of a map task
+type TaskArtifact struct {
+	TaskName string
+	MapIndex *int
+	Name     string
+}
+
+// Parameter is the value of a parameter, for secrets and ConfigMaps Value holds their name and Key the key to read
+type Parameter struct {
+	Name        string
+	IsSecret    bool
+	IsConfigMap bool
+	Key         string
+	Value       string
+}
+
+// SubDagRun is the run of a dagRef task that is ready to start its child DagRun
+type SubDagRun struct {
+	TaskRunId  int
+	RunId      int
+	Namespace  string
+	DagName    string
+	Parameters []v1alpha1.ParameterSpec
+	// Priority of the dagRef task run, which the child run takes on
+	Priority int32
+}
+
+// FinishedSubDagRun is the run of a dagRef task whose child run has finished
+type FinishedSubDagRun struct {
+	TaskRunId  int
+	RunId      int
+	ChildRunId int
+	Succeeded  bool
+	// Namespace and workspace of the parent run
+	Namespace string
+	PVCName   *string
+}
+
+// DownstreamDagRun is a run to start for a DAG whose onDagCompletion trigger waits for a run that finished
+type DownstreamDagRun struct {
+	DagName    string
+	Namespace  string
+	Parameters []v1alpha1.ParameterSpec
+	// Upstream run that finished and the status it finished with
+	UpstreamRunId     int
+	UpstreamRunName   string
+	UpstreamNamespace string
+	UpstreamStatus    string
+	// Trigger that matched the upstream run
+	TriggerId int
+}
+
+// StoppedTaskRun is a task run that was stopped, because it ran out of time or its DAG run was replaced
+type StoppedTaskRun struct {
+	TaskRunId int
+	RunId     int
+	TaskName  string
+	DagName   string
+	Namespace string
+	// Workspace of the run, if it has one
+	PVCName *string
+	// Pods started for the task run, which should be deleted
+	Pods []RunningPodInfo
+}
+
+// StoppedDagRun is a DAG run that was stopped, because it ran past its runTimeout or was replaced by a newer run
+type StoppedDagRun struct {
+	RunId     int
+	DagName   string
+	Namespace string
+	PVCName   *string
+	// Task runs that were still active when the run was stopped
+	TaskRuns []StoppedTaskRun
+}
+
+// DagRunAdmission is the outcome of starting a DAG run under the concurrency policy of its DAG
+type DagRunAdmission struct {
+	// Not set when the run was skipped
+	RunId int
+	// running, queued or skipped
+	Status string
+	// Active runs that were stopped to make room for this one
+	Replaced []StoppedDagRun
+}
+
+// QueuedDagRun is a queued DAG run that has been moved to running now that a slot is free
+type QueuedDagRun struct {
+	RunId     int
+	DagName   string
+	Namespace string
+}
+
+// DagRunSummary is the state of a DAG run as recorded in the database
+type DagRunSummary struct {
+	RunId  int
+	Status string
+	// Not set while the run is queued
+	StartTime *time.Time
+	// Set once the run has an outcome and none of its task runs are active anymore,
+	// it is when the last of its pods finished
+	FinishTime *time.Time
+	// Number of task runs in each status
+	TaskRuns map[string]int
+}
+
+// DagStatus is the state of the latest version of a DAG as recorded in the database
+type DagStatus struct {
+	Version int
+	Hash    string
+	// Not set when the DAG has no schedule or its schedule window has ended
+	NextTime *time.Time
+	// Latest run of any version of the DAG, nil when it has never run
+	LastRun *DagRunRef
+}
+
+// DagRunRef identifies a DAG run
+type DagRunRef struct {
+	RunId int
+	Name  string
+}
+
 type ConditionalRetry struct {
 	Enabled    bool
 	RetryCodes []int32
@@ -45,14 +198,25 @@ type RunningPodInfo struct {
 
 type DBDAGManager interface {
 	// InitaliseDatabase will ensure all create requires components such as tables in a relational database are within the database
+	// and that the upcoming next run times of DAGs with a time zone follow the zone rules of this build
 	InitaliseDatabase(ctx context.Context) error
 	GetID(ctx context.Context) (string, error)
-	// Gets all dags to start, then updates to the next time it should be executed
+	// Gets all dags to start, then updates to the next time it should be executed.
+	// DAGs with catchup enabled are returned once for each schedule time they missed
 	GetDAGsToStartAndUpdate(ctx context.Context, tm time.Time) ([]*DagInfo, error)
-	// InsertDAG will add in the new dag into the database, if the dag already exists, it should create a new version
+	// GetBackfillsToStartAndUpdate returns the next batch of schedule times of the DAG backfills still in progress,
+	// moving each backfill on past them
+	GetBackfillsToStartAndUpdate(ctx context.Context) ([]*DagInfo, error)
+	// InsertDAG will add in the new dag into the database, if the dag already exists, it should create a new version.
+	// A backfill range that has not been seen before is recorded for the scheduler to start
 	InsertDAG(ctx context.Context, dag *v1alpha1.DAG, namespace string) error
 	// Create the update to show that a new DAG has been started
 	CreateDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (int, error)
+	// StartDAGRun creates a DAG run like CreateDAGRun, applying the concurrency policy of the DAG first.
+	// The run may be skipped, queued, or started after the oldest active runs are marked as suspended
+	StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (DagRunAdmission, error)
+	// StartQueuedDAGRuns moves the oldest queued runs of each DAG to running while the DAG has free slots
+	StartQueuedDAGRuns(ctx context.Context) ([]QueuedDagRun, error)
 	// Get all the tasks in the DAG that do not have any dependencies
 	GetStartingTasks(ctx context.Context, dagName string, dagrun int) ([]Task, error)
 	// TaskRunExists reports whether a Task_Run row already exists for the run/task pair.
@@ -63,6 +227,14 @@ type DBDAGManager interface {
 	IncrementAttempts(ctx context.Context, taskRunId int) error
 	// Within the same transaction, and get next task(s) in the DAG
 	MarkSuccessAndGetNextTasks(ctx context.Context, taskRunId int) ([]Task, error)
+	// CompleteTaskRunFromCache looks for a result of the task with the same cache key that has not expired.
+	// When there is one the task run is marked as cached with its outputs and the next tasks are returned,
+	// otherwise the key is kept so the result of the task run is cached once it succeeds. The task run must
+	// still be pending and claimed by workerId, ErrTaskRunNotClaimed is returned when it is not
+	CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []Task, error)
+	// InvalidateTaskCache drops the cached results of the named tasks of a DAG, or of all of its tasks
+	// when none are named, and returns how many were dropped
+	InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error)
 	// Update the DAGRun to show the overall outcome
 	MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error
 	GetDagParameters(ctx context.Context, dagName string) (map[string]*Parameter, error)
@@ -81,6 +253,8 @@ type DBDAGManager interface {
 	GetWorkspacePVCTemplate(ctx context.Context, dagId int) (*v1alpha1.PVC, error)
 	CheckIfAllTasksDone(ctx context.Context, dagRunID int) (bool, error)
 	MarkConnectingTasksAsSuspended(ctx context.Context, dagRunID, taskRunId int) ([]string, error)
+	// GetReadyTasks returns tasks whose trigger rules allow them to run now, e.g. cleanup tasks after a failure
+	GetReadyTasks(ctx context.Context, dagRunId int) ([]Task, error)
 	AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error
 	SuspendDagRun(ctx context.Context, dagRunId int) ([]RunningPodInfo, error)
 	DeleteDagRun(ctx context.Context, dagRunId int) error
@@ -95,20 +269,79 @@ type DBDAGManager interface {
 	FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error
 	RecoverExpiredLeases(ctx context.Context) (int, error)
 
-	// Insert a Task_Runs row with status 'pending' for the given run and dag task id
+	// Insert a Task_Runs row with status 'pending' for the given run and dag task id,
+	// map tasks get one row per item and the id of the first one is returned. dagRef tasks
+	// are inserted as 'pending_dag' for the scheduler to start their child run
 	AddPendingTaskRun(ctx context.Context, runId int, dagTaskId int) (int, error)
 
-	// GetTaskForRun returns task details for a given run and dag_task_id, the namespace and any retry env JSON
-	GetTaskForRun(ctx context.Context, runId int, dagTaskId int) (Task, string, string, error)
+	// RetryTaskRun inserts a pending Task_Runs row repeating the given task run, including its map item.
+	// It returns the delay from the task's backoff, the new row cannot be claimed until it has passed
+	RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error)
+
+	// GetTaskForRun returns task details for a given run and dag_task_id, the namespace and the retry env JSON
+	// saved for taskRunId, if any. Instances of a map task share the run and dag_task_id but each have their own env
+	GetTaskForRun(ctx context.Context, runId int, dagTaskId int, taskRunId int) (Task, string, string, error)
 
 	// Claim a specific task_run_id immediately
 	ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (TaskClaim, error)
 
+	// DeferSensor releases the claim of a sensor task run whose condition does not hold yet, so it is claimed
+	// and checked again after interval. The deadline of the sensor is kept in timeout_at, set to timeout after
+	// the first check unless the task run times out sooner. It returns false, keeping the claim, once the
+	// deadline has passed, and ErrTaskRunNotClaimed when the task run is no longer pending and claimed by workerId
+	DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error)
+
 	// Save retry environment JSON for a pending task_run
 	SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error
 
+	// SaveTaskOutputs stores the outputs published by a task_run so downstream tasks can consume them
+	SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error
+
 	// GetTaskRunStatus returns the status of a task_run row
 	GetTaskRunStatus(ctx context.Context, taskRunId int) (string, error)
+
+	// StartSubDagRuns leases the pending task runs of dagRef tasks and returns the DAG and parameters
+	// each of them should start a child run with. A task run whose child run is not linked before the
+	// lease expires is returned again
+	StartSubDagRuns(ctx context.Context) ([]SubDagRun, error)
+	// LinkSubDagRun records the task run of the parent DAG run that started a run and moves that task run to running
+	LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error
+	// GetFinishedSubDagRuns returns the running task runs of dagRef tasks whose child run has finished
+	GetFinishedSubDagRuns(ctx context.Context) ([]FinishedSubDagRun, error)
+
+	// ClaimFinishedDagRuns leases the finished DAG runs whose downstream runs were not started yet, returning
+	// a run to start for every active DAG with an onDagCompletion trigger matching one of them. A finished run
+	// not marked handled before the lease expires is returned again
+	ClaimFinishedDagRuns(ctx context.Context) ([]DownstreamDagRun, error)
+	// MarkDagRunCompletionHandled records that the downstream runs of a finished DAG run have been started
+	MarkDagRunCompletionHandled(ctx context.Context, runId int) error
+
+	// TimeOutTaskRuns marks the active task runs that are past their task timeout as timed_out
+	// and fails their DAG run, returning them with the pods that should be deleted
+	TimeOutTaskRuns(ctx context.Context) ([]StoppedTaskRun, error)
+	// TimeOutDagRuns marks the DAG runs that are past their runTimeout as timed_out, along with
+	// their active task runs, returning them with the pods that should be deleted
+	TimeOutDagRuns(ctx context.Context) ([]StoppedDagRun, error)
+
+	// GetDagRunSummary returns the status of a DAG run, when it started and finished and
+	// how many of its task runs are in each status
+	GetDagRunSummary(ctx context.Context, runId int) (*DagRunSummary, error)
+
+	// GetDagStatus returns the latest stored version of a DAG along with its next scheduled time and latest run
+	GetDagStatus(ctx context.Context, dagName, namespace string) (*DagStatus, error)
+
+	// UpsertPool stores the slots of a pool, the tasks in it are claimed against them from then on
+	UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error
+	// DeletePool removes a pool, the tasks in it wait to be claimed until it is created again
+	DeletePool(ctx context.Context, name, namespace string) error
+	// GetPoolUsage returns every pool with the slots taken by its running tasks and asked for by those waiting
+	GetPoolUsage(ctx context.Context) ([]PoolUsage, error)
+
+	// RecordTriggerEvent records the DAG run started for an event of a trigger. When the event was
+	// already recorded nothing is stored, and the name of the run it started is returned instead
+	RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error)
+	// GetTriggerEvent returns the name of the DAG run recorded for an event of a trigger, false when none is
+	GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error)
 }
 
 // TaskClaim represents a claimed task that a worker should attempt to allocate
@@ -116,4 +349,16 @@ type TaskClaim struct {
 	TaskRunID int
 	TaskID    int
 	RunID     int
+	// Set when the task run is an instance of a map task
+	MapIndex *int
+	MapItem  string
+}
+
+// PoolUsage is how many slots of a pool are taken and how many are asked for by queued task runs
+type PoolUsage struct {
+	Name          string
+	Namespace     string
+	Slots         int
+	OccupiedSlots int
+	QueuedSlots   int
 }
@@ -5,8 +5,15 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"kontroler-controller/api/v1alpha1"
+
+	cron "github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func getTaskVersion(task *v1alpha1.TaskSpec) int {
@@ -23,6 +30,9 @@ func hashDagSpec(s *v1alpha1.DAGSpec) ([]byte, error) {
 	// set to false to avoid hashing the status
 	cpy.Suspended = false
 
+	// backfills are requests against the DAG rather than part of it, addBackfill stores them without a new version
+	cpy.Backfill = nil
+
 	// Convert the DAGSpec to JSON
 	data, err := json.Marshal(cpy)
 	if err != nil {
@@ -50,3 +60,746 @@ func hashDagTaskSpec(t *v1alpha1.DagTaskSpec) ([]byte, error) {
 
 	return hash.Sum(nil), nil
 }
+
+// hashInlineTask fingerprints the definition of an in-line task the same way a DagTask is,
+// so results cached for the task outlive new versions of its DAG that leave it unchanged
+func hashInlineTask(t *v1alpha1.TaskSpec) (string, error) {
+	hash, err := hashDagTaskSpec(&v1alpha1.DagTaskSpec{
+		Command:             t.Command,
+		Args:                t.Args,
+		Image:               t.Image,
+		Backoff:             t.Backoff,
+		Conditional:         t.Conditional,
+		Parameters:          t.Parameters,
+		PodTemplate:         t.PodTemplate,
+		Script:              t.Script,
+		ScriptInjectorImage: t.ScriptInjectorImage,
+		Outputs:             t.Outputs,
+		Artifacts:           t.Artifacts,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash), nil
+}
+
+// artifactsColumn serialises the artifacts of a task, nil when it declares none
+func artifactsColumn(artifacts []v1alpha1.ArtifactSpec) (*string, error) {
+	if len(artifacts) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(data)
+	return &value, nil
+}
+
+// appendTaskArtifacts adds the artifacts a run of an upstream task published
+func appendTaskArtifacts(artifacts []TaskArtifact, taskName string, mapIndex *int, raw string) ([]TaskArtifact, error) {
+	declared := []v1alpha1.ArtifactSpec{}
+	if err := json.Unmarshal([]byte(raw), &declared); err != nil {
+		return nil, err
+	}
+
+	for _, artifact := range declared {
+		artifacts = append(artifacts, TaskArtifact{TaskName: taskName, MapIndex: mapIndex, Name: artifact.Name})
+	}
+	return artifacts, nil
+}
+
+// sortTaskArtifacts keeps the artifacts downloaded for a task in a stable order
+func sortTaskArtifacts(artifacts []TaskArtifact) {
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		a, b := artifacts[i], artifacts[j]
+		if a.TaskName != b.TaskName {
+			return a.TaskName < b.TaskName
+		}
+		if (a.MapIndex == nil) != (b.MapIndex == nil) {
+			return a.MapIndex == nil
+		}
+		if a.MapIndex != nil && *a.MapIndex != *b.MapIndex {
+			return *a.MapIndex < *b.MapIndex
+		}
+		return a.Name < b.Name
+	})
+}
+
+func appendTaskOutputs(outputs []TaskOutput, taskName string, values map[string]string) []TaskOutput {
+	for name, value := range values {
+		outputs = append(outputs, TaskOutput{TaskName: taskName, Name: name, Value: value})
+	}
+	return outputs
+}
+
+// sortTaskOutputs keeps the env vars built from outputs in a stable order
+func sortTaskOutputs(outputs []TaskOutput) {
+	sort.Slice(outputs, func(i, j int) bool {
+		if outputs[i].TaskName != outputs[j].TaskName {
+			return outputs[i].TaskName < outputs[j].TaskName
+		}
+		return outputs[i].Name < outputs[j].Name
+	})
+}
+
+// priorityAgingSeconds is how long a task run waits to be claimed to gain a level of priority,
+// so task runs of low priority DAGs are still claimed while higher ones keep arriving
+const priorityAgingSeconds = 60
+
+// subDagLeaseSeconds is how long the scheduler holds a dagRef task run while it creates the child run,
+// a scheduler that stops before the child run exists leaves the task run to be started again after it
+const subDagLeaseSeconds = 60
+
+// completionLeaseSeconds is how long the scheduler holds a finished DAG run while it creates its downstream
+// runs, a scheduler that stops before they all exist leaves the run to be claimed again after it
+const completionLeaseSeconds = 60
+
+// dagGraphLockKey is the postgres advisory lock taken while a DAG is checked against the graph of stored DAGs
+const dagGraphLockKey = 0x6b6f6e74
+
+// claimCandidate is a task run ClaimTasks may claim, with the pool it takes slots from if any
+type claimCandidate struct {
+	TaskClaim
+	poolId    *int
+	poolSlots int
+}
+
+// admitCandidates keeps the candidates, in order, whose pool still has room for them once the
+// candidates ahead of them have taken their slots. freeSlots is updated as slots are taken
+func admitCandidates(candidates []claimCandidate, freeSlots map[int]int) []TaskClaim {
+	claims := []TaskClaim{}
+	for _, c := range candidates {
+		if c.poolId != nil {
+			if freeSlots[*c.poolId] < c.poolSlots {
+				continue
+			}
+			freeSlots[*c.poolId] -= c.poolSlots
+		}
+
+		claims = append(claims, c.TaskClaim)
+	}
+
+	return claims
+}
+
+// dagTask is the part of a DAG task needed to decide whether it can run
+type dagTask struct {
+	when        string
+	triggerRule string
+	upstream    []int
+	// set on map tasks, which expand into one task run per item
+	mapParameter      string
+	mapOutput         string
+	mapMaxParallelism int
+	// set on sub-DAG tasks, which start a run of another DAG instead of a pod
+	dagRef string
+	// how long a run of the task may take, in seconds
+	timeoutSeconds *int
+}
+
+func (t *dagTask) isMap() bool {
+	return t.mapParameter != "" || t.mapOutput != ""
+}
+
+// mapColumns returns the map columns of DAG_Tasks for a task, NULL when it is not a map task
+func mapColumns(spec *v1alpha1.MapSpec) (parameter, output *string, maxParallelism int) {
+	if spec == nil {
+		return nil, nil, 0
+	}
+
+	if spec.Parameter != "" {
+		parameter = &spec.Parameter
+	}
+
+	if spec.Output != "" {
+		output = &spec.Output
+	}
+
+	return parameter, output, spec.MaxParallelism
+}
+
+// dagRefColumns returns the sub-DAG columns of DAG_Tasks for a task, NULL when it does not run another DAG
+func dagRefColumns(ref *v1alpha1.DagRef) (name, parameters *string, err error) {
+	if ref == nil {
+		return nil, nil, nil
+	}
+
+	data, err := json.Marshal(ref.Parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value := string(data)
+	return &ref.Name, &value, nil
+}
+
+// finishedDagRun is a DAG run claimed by ClaimFinishedDagRuns
+type finishedDagRun struct {
+	runId  int
+	dagId  int
+	name   string
+	status string
+}
+
+// completionTrigger is a stored onDagCompletion trigger of an active DAG
+type completionTrigger struct {
+	triggerId         int
+	dagName           string
+	namespace         string
+	upstreamNamespace string
+	outcome           v1alpha1.DagCompletionOutcome
+	parameters        []v1alpha1.DagRefParameter
+}
+
+// completionTriggerColumns returns the outcome and parameters of an onDagCompletion trigger as they are stored
+func completionTriggerColumns(trigger *v1alpha1.DagCompletionTrigger) (string, string, error) {
+	data, err := json.Marshal(trigger.Parameters)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(trigger.GetOutcome()), string(data), nil
+}
+
+// matchingCompletionTriggers returns the triggers the status a run finished with starts a run for
+func matchingCompletionTriggers(triggers []completionTrigger, status string) []completionTrigger {
+	matched := []completionTrigger{}
+	for _, trigger := range triggers {
+		if trigger.outcome.Matches(status) {
+			matched = append(matched, trigger)
+		}
+	}
+	return matched
+}
+
+// downstreamDagRuns returns the runs a finished run starts, filling their parameters from the upstream run
+func downstreamDagRuns(run finishedDagRun, triggers []completionTrigger, upstream map[string]Parameter) []DownstreamDagRun {
+	runs := make([]DownstreamDagRun, 0, len(triggers))
+	for _, trigger := range triggers {
+		runs = append(runs, DownstreamDagRun{
+			DagName:           trigger.dagName,
+			Namespace:         trigger.namespace,
+			Parameters:        subDagParameters(trigger.parameters, upstream),
+			UpstreamRunId:     run.runId,
+			UpstreamRunName:   run.name,
+			UpstreamNamespace: trigger.upstreamNamespace,
+			UpstreamStatus:    run.status,
+			TriggerId:         trigger.triggerId,
+		})
+	}
+	return runs
+}
+
+// sensorColumn returns the sensor of a task as JSON, NULL when the task runs a pod
+func sensorColumn(sensor *v1alpha1.SensorSpec) (*string, error) {
+	if sensor == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(sensor)
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(data)
+	return &value, nil
+}
+
+// timeoutSeconds returns a timeout in whole seconds, NULL when it is not set
+func timeoutSeconds(timeout *metav1.Duration) *int {
+	if timeout == nil {
+		return nil
+	}
+
+	seconds := int(timeout.Duration / time.Second)
+	return &seconds
+}
+
+// cacheTTLSeconds returns how long results of a task are reused for in whole seconds, NULL when they are not cached
+func cacheTTLSeconds(cache *v1alpha1.CacheSpec) *int {
+	if cache == nil {
+		return nil
+	}
+
+	return timeoutSeconds(&cache.TTL)
+}
+
+// poolColumns returns the pool columns of DAG_Tasks for a task, a NULL pool when it takes no pool slots
+func poolColumns(task *v1alpha1.TaskSpec) (pool *string, slots int32) {
+	if task.Pool == "" {
+		return nil, 1
+	}
+
+	if task.PoolSlots == 0 {
+		return &task.Pool, 1
+	}
+
+	return &task.Pool, task.PoolSlots
+}
+
+// retryBackoff holds the retry delay columns of Tasks, each NULL when it is not set
+type retryBackoff struct {
+	initialDelaySeconds *int
+	maxDelaySeconds     *int
+	multiplier          *float64
+	jitter              *float64
+}
+
+// backoffColumns returns the retry delay columns of Tasks for a backoff, the backoff is expected to be valid
+func backoffColumns(b v1alpha1.Backoff) retryBackoff {
+	columns := retryBackoff{
+		initialDelaySeconds: timeoutSeconds(b.InitialDelay),
+		maxDelaySeconds:     timeoutSeconds(b.MaxDelay),
+	}
+
+	if b.Multiplier != "" {
+		if multiplier, err := b.ParseMultiplier(); err == nil {
+			columns.multiplier = &multiplier
+		}
+	}
+
+	if b.Jitter != "" {
+		if jitter, err := b.ParseJitter(); err == nil {
+			columns.jitter = &jitter
+		}
+	}
+
+	return columns
+}
+
+// delay returns how long to wait before a retry, counting retries from 1. The wait starts at the
+// initial delay and grows by the multiplier on each retry up to the max delay, then the jitter moves
+// it by up to that fraction either way. random is expected to be in [0, 1).
+func (b retryBackoff) delay(retry int, random float64) time.Duration {
+	if b.initialDelaySeconds == nil || retry < 1 {
+		return 0
+	}
+
+	multiplier := v1alpha1.DefaultBackoffMultiplier
+	if b.multiplier != nil {
+		multiplier = *b.multiplier
+	}
+
+	seconds := float64(*b.initialDelaySeconds) * math.Pow(multiplier, float64(retry-1))
+	if b.maxDelaySeconds != nil && seconds > float64(*b.maxDelaySeconds) {
+		seconds = float64(*b.maxDelaySeconds)
+	}
+
+	if b.jitter != nil {
+		seconds += seconds * *b.jitter * (2*random - 1)
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// scheduledStartSeconds returns the delay of a retry in seconds, NULL when it can start straight away
+func scheduledStartSeconds(delay time.Duration) *float64 {
+	if delay <= 0 {
+		return nil
+	}
+
+	seconds := delay.Seconds()
+	return &seconds
+}
+
+// activeRunLimit returns how many runs of a DAG may be active at once under its concurrency policy,
+// 0 when the policy sets no limit
+func activeRunLimit(policy string, maxActiveRuns int) int {
+	switch v1alpha1.ConcurrencyPolicy(policy) {
+	case v1alpha1.ConcurrencyPolicyForbid, v1alpha1.ConcurrencyPolicyReplace, v1alpha1.ConcurrencyPolicyQueue:
+		if maxActiveRuns < 1 {
+			return 1
+		}
+		return maxActiveRuns
+	default:
+		return 0
+	}
+}
+
+// activeRunCondition matches the runs r that take up one of the active runs of their DAG: running ones,
+// and failed ones that still have tasks left running, e.g. ones with the all_done trigger rule
+const activeRunCondition = `(r.status = 'running' OR (r.status = 'failed' AND EXISTS (
+	SELECT 1 FROM Task_Runs tr
+	WHERE tr.run_id = r.run_id
+	AND tr.status IN ('pending', 'pending_dag', 'running', 'waiting'))))`
+
+// queuedRunsToStart returns how many queued runs of a DAG fit alongside its active runs
+func queuedRunsToStart(limit, active, queued int) int {
+	if limit == 0 {
+		return queued
+	}
+
+	return max(0, min(limit-active, queued))
+}
+
+// backfillBatchSize caps the runs a pass of the scheduler starts across all backfills, so a long range
+// is worked through over several passes rather than flooding the cluster at once
+const backfillBatchSize = 50
+
+// catchupLimit returns how many of the schedule times a DAG missed should start a run, only the latest without catchup
+func catchupLimit(catchup bool, maxCatchupRuns int) int {
+	if !catchup {
+		return 1
+	}
+
+	if maxCatchupRuns < 1 {
+		return v1alpha1.DefaultMaxCatchupRuns
+	}
+
+	return maxCatchupRuns
+}
+
+// parseSchedule parses the cron schedule of a DAG in its time zone, the zone of the controller when it has none.
+// The schedule then follows the wall clock of that zone across DST transitions
+func parseSchedule(parser *cron.Parser, schedule, timezone string) (cron.Schedule, error) {
+	if timezone != "" {
+		schedule = "CRON_TZ=" + timezone + " " + schedule
+	}
+
+	return parser.Parse(schedule)
+}
+
+// scheduleWindow is the range of time a DAG starts scheduled runs in, either end may be open
+type scheduleWindow struct {
+	start *time.Time
+	end   *time.Time
+}
+
+func newScheduleWindow(start, end *metav1.Time) scheduleWindow {
+	return scheduleWindow{start: utcTime(start), end: utcTime(end)}
+}
+
+// next returns the first schedule time after t that falls within the window, the zero time when none are left
+func (w scheduleWindow) next(sched cron.Schedule, t time.Time) time.Time {
+	if w.start != nil && t.Before(*w.start) {
+		t = w.start.Add(-time.Second)
+	}
+
+	next := sched.Next(t)
+	if w.end != nil && next.After(*w.end) {
+		return time.Time{}
+	}
+
+	return next
+}
+
+// nextTimeColumn returns the next schedule time of a DAG in UTC, NULL when the schedule window has closed
+func nextTimeColumn(next time.Time) *time.Time {
+	if next.IsZero() {
+		return nil
+	}
+
+	utc := next.UTC()
+	return &utc
+}
+
+// missedScheduleTimes returns the schedule times from next up to now, keeping only the latest limit of them
+func missedScheduleTimes(sched cron.Schedule, window scheduleWindow, next, now time.Time, limit int) []time.Time {
+	times := []time.Time{}
+	for t := next; !t.IsZero() && !t.After(now); t = window.next(sched, t) {
+		times = append(times, t)
+		if len(times) > limit {
+			times = times[1:]
+		}
+	}
+
+	return times
+}
+
+// backfillScheduleTimes returns up to limit schedule times after the cursor, up to and including end,
+// along with whether no schedule times are left in the range after them
+func backfillScheduleTimes(sched cron.Schedule, cursor, end time.Time, limit int) ([]time.Time, bool) {
+	times := []time.Time{}
+	t := sched.Next(cursor)
+	for ; !t.IsZero() && !t.After(end) && len(times) < limit; t = sched.Next(t) {
+		times = append(times, t)
+	}
+
+	return times, t.IsZero() || t.After(end)
+}
+
+// utcTime returns a time in UTC, NULL when it is not set
+func utcTime(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+	return &utc
+}
+
+// finished reports whether a run has an outcome and none of its task runs are active anymore.
+// A failed run can still have tasks left running, e.g. ones with the all_done trigger rule
+func (s *DagRunSummary) finished() bool {
+	switch s.Status {
+	case "queued", "running":
+		return false
+	}
+
+	for _, status := range []string{"pending", "pending_dag", "running", "waiting"} {
+		if s.TaskRuns[status] > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// subDagParameters builds the parameters of a child run from the parameters of the
+// parent run, keeping values that came from secrets as secrets
+func subDagParameters(refs []v1alpha1.DagRefParameter, parent map[string]Parameter) []v1alpha1.ParameterSpec {
+	params := make([]v1alpha1.ParameterSpec, 0, len(refs))
+	for _, ref := range refs {
+		if ref.FromParameter == "" {
+			params = append(params, v1alpha1.ParameterSpec{Name: ref.Name, Value: ref.Value})
+			continue
+		}
+
+		value, ok := parent[ref.FromParameter]
+		if !ok {
+			continue
+		}
+
+		params = append(params, value.toRunParameter(ref.Name))
+	}
+
+	return params
+}
+
+// literalParameters returns the values of the parameters that are known before a task runs
+func literalParameters(params map[string]Parameter) map[string]string {
+	values := make(map[string]string, len(params))
+	for name, param := range params {
+		if !param.IsSecret && !param.IsConfigMap {
+			values[name] = param.Value
+		}
+	}
+	return values
+}
+
+// runParameter returns how a value given to a run is stored
+func runParameter(param v1alpha1.ParameterSpec) Parameter {
+	ref, configMap := param.Ref()
+	return newParameter(param.Name, param.Value, ref, configMap)
+}
+
+// dagParameter returns how the default of a DAG parameter is stored, required parameters have an empty default
+func dagParameter(param *v1alpha1.DagParameterSpec) Parameter {
+	ref, configMap := param.DefaultRef()
+	return newParameter(param.Name, param.DefaultValue, ref, configMap)
+}
+
+func newParameter(name, value string, ref *v1alpha1.KeyRef, configMap bool) Parameter {
+	if ref == nil {
+		return Parameter{Name: name, Value: value}
+	}
+	return Parameter{Name: name, IsSecret: !configMap, IsConfigMap: configMap, Key: ref.Key, Value: ref.Name}
+}
+
+// toRunParameter passes the parameter on to a run under name, keeping values read from secrets and ConfigMaps as references
+func (p Parameter) toRunParameter(name string) v1alpha1.ParameterSpec {
+	switch {
+	case p.IsConfigMap:
+		return v1alpha1.ParameterSpec{Name: name, ConfigMapKeyRef: &v1alpha1.KeyRef{Name: p.Value, Key: p.Key}}
+	case p.IsSecret:
+		if p.Key == "" || p.Key == v1alpha1.DefaultSecretKey {
+			return v1alpha1.ParameterSpec{Name: name, FromSecret: p.Value}
+		}
+		return v1alpha1.ParameterSpec{Name: name, FromSecret: p.Value + "/" + p.Key}
+	default:
+		return v1alpha1.ParameterSpec{Name: name, Value: p.Value}
+	}
+}
+
+// parseMapItems splits the value a map task expands over, either a JSON array or a comma separated list
+func parseMapItems(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(value, "[") {
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+
+	raw := []json.RawMessage{}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse map items: %w", err)
+	}
+
+	// strings are unquoted, any other JSON value is passed on as written
+	items := make([]string, 0, len(raw))
+	for _, item := range raw {
+		var str string
+		if err := json.Unmarshal(item, &str); err == nil {
+			items = append(items, str)
+		} else {
+			items = append(items, string(item))
+		}
+	}
+
+	return items, nil
+}
+
+// taskRunStatus is the status of a single task run, with the index of the item for map task instances
+type taskRunStatus struct {
+	taskId   int
+	mapIndex *int
+	status   string
+}
+
+// rollupTaskStatuses takes task runs in the order they were created and returns the status
+// of the latest attempt of each task. A map task is running until every instance has
+// finished, then failed if any of them failed.
+func rollupTaskStatuses(runs []taskRunStatus) map[int]string {
+	statuses := map[int]string{}
+	instances := map[int]map[int]string{}
+
+	for _, run := range runs {
+		if run.mapIndex == nil {
+			statuses[run.taskId] = run.status
+			continue
+		}
+
+		if instances[run.taskId] == nil {
+			instances[run.taskId] = map[int]string{}
+		}
+		instances[run.taskId][*run.mapIndex] = run.status
+	}
+
+	for taskId, latest := range instances {
+		status := "success"
+		for _, instance := range latest {
+			switch instance {
+			case "success", "cached", "skipped":
+			case "failed", "suspended", "timed_out":
+				if status == "success" {
+					status = "failed"
+				}
+			default:
+				status = "running"
+			}
+		}
+		statuses[taskId] = status
+	}
+
+	return statuses
+}
+
+type triggerDecision int
+
+const (
+	triggerWait triggerDecision = iota
+	triggerRun
+	triggerSkip
+	triggerSuspend
+)
+
+// evaluateTriggerRule decides what happens to a task given the latest status of
+// each of its upstream tasks, an empty status meaning the task has not finished
+func evaluateTriggerRule(rule string, upstream []string) triggerDecision {
+	var succeeded, failed, done int
+	for _, status := range upstream {
+		switch status {
+		case "success", "cached":
+			succeeded++
+			done++
+		case "skipped":
+			done++
+		case "failed", "suspended", "timed_out":
+			failed++
+			done++
+		}
+	}
+
+	allDone := done == len(upstream)
+
+	switch rule {
+	case v1alpha1.TriggerRuleAllDone:
+		if allDone {
+			return triggerRun
+		}
+	case v1alpha1.TriggerRuleOneFailed:
+		if failed > 0 {
+			return triggerRun
+		}
+		if allDone {
+			return triggerSkip
+		}
+	case v1alpha1.TriggerRuleOneSuccess:
+		if succeeded > 0 {
+			return triggerRun
+		}
+		if allDone && failed > 0 {
+			return triggerSuspend
+		}
+		if allDone {
+			return triggerSkip
+		}
+	default:
+		if failed > 0 {
+			return triggerSuspend
+		}
+		if allDone {
+			return triggerRun
+		}
+	}
+
+	return triggerWait
+}
+
+// decideTasks applies the trigger rules of the tasks in the run that have not
+// started yet. Tasks without upstream tasks are only considered when they are
+// one of the candidates.
+func decideTasks(graph map[int]*dagTask, statuses map[int]string, candidates []int) (run, skip, suspend []int) {
+	isCandidate := map[int]bool{}
+	for _, id := range candidates {
+		isCandidate[id] = true
+	}
+
+	ids := make([]int, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if _, started := statuses[id]; started {
+			continue
+		}
+
+		task := graph[id]
+		if len(task.upstream) == 0 {
+			if isCandidate[id] {
+				run = append(run, id)
+			}
+			continue
+		}
+
+		upstream := make([]string, 0, len(task.upstream))
+		for _, dep := range task.upstream {
+			upstream = append(upstream, statuses[dep])
+		}
+
+		switch evaluateTriggerRule(task.triggerRule, upstream) {
+		case triggerRun:
+			run = append(run, id)
+		case triggerSkip:
+			skip = append(skip, id)
+		case triggerSuspend:
+			suspend = append(suspend, id)
+		}
+	}
+
+	return run, skip, suspend
+}
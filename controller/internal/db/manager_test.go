@@ -2587,3 +2587,2305 @@ func testDAGManagerGetTaskRunInfo_ContextCancelled(t *testing.T, dm db.DBDAGMana
 		assert.Empty(t, namespace)
 	})
 }
+
+func testDAGManager_TaskOutputs_PassedDownstream(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_outputs",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "extract",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Outputs: []string{"rowCount", "fileName"},
+				},
+				{
+					Name:     "transform",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"extract"},
+				},
+				{
+					Name:     "load",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"transform"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "outputs-run", &v1alpha1.DagRunSpec{DagName: "test_dag_outputs"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_outputs", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	extract, _, _, err := dm.GetTaskForRun(ctx, runID, tasks[0].Id, 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"rowCount", "fileName"}, extract.Outputs)
+	require.Equal(t, []string{"echo"}, extract.Command)
+	require.Empty(t, extract.UpstreamOutputs)
+
+	extractRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	require.NoError(t, dm.SaveTaskOutputs(ctx, extractRunID, map[string]string{
+		"rowCount": "42",
+		"fileName": "data.csv",
+	}))
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, extractRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+
+	transform, _, _, err := dm.GetTaskForRun(ctx, runID, next[0].Id, 0)
+	require.NoError(t, err)
+	require.Equal(t, []db.TaskOutput{
+		{TaskName: "extract", Name: "fileName", Value: "data.csv"},
+		{TaskName: "extract", Name: "rowCount", Value: "42"},
+	}, transform.UpstreamOutputs)
+
+	transformRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	last, err := dm.MarkSuccessAndGetNextTasks(ctx, transformRunID)
+	require.NoError(t, err)
+	require.Len(t, last, 1)
+
+	// outputs are visible to transitive downstream tasks too
+	load, _, _, err := dm.GetTaskForRun(ctx, runID, last[0].Id, 0)
+	require.NoError(t, err)
+	require.Len(t, load.UpstreamOutputs, 2)
+}
+
+func testDAGManager_WhenConditions_SkipTasks(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_when",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "extract",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Outputs: []string{"rowCount"},
+				},
+				{
+					Name:     "load",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"extract"},
+					When:     "tasks.extract.outputs.rowCount > 0",
+				},
+				{
+					Name:     "alert",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"extract"},
+					When:     "tasks.extract.outputs.rowCount == 0",
+				},
+				{
+					Name:     "report",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"load", "alert"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "when-run", &v1alpha1.DagRunSpec{DagName: "test_dag_when"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_when", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	extractRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+	require.NoError(t, dm.SaveTaskOutputs(ctx, extractRunID, map[string]string{"rowCount": "0"}))
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, extractRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Equal(t, "alert", next[0].Name)
+
+	alertRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	// a skipped upstream task does not hold back its dependents
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, alertRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Equal(t, "report", next[0].Name)
+
+	reportRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, reportRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	done, err := dm.CheckIfAllTasksDone(ctx, runID)
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func testDAGManager_WhenConditions_StartingTasks(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_when_start",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "env", DefaultValue: "dev"},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "deploy",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					When:    `params.env == "prod"`,
+				},
+				{
+					Name:     "notify",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"deploy"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	// the default value skips deploy, which lets notify start straight away
+	devRunID, err := dm.CreateDAGRun(ctx, "when-dev-run", &v1alpha1.DagRunSpec{DagName: "test_dag_when_start"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_when_start", devRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "notify", tasks[0].Name)
+
+	prodRunID, err := dm.CreateDAGRun(ctx, "when-prod-run", &v1alpha1.DagRunSpec{DagName: "test_dag_when_start"}, map[string]v1alpha1.ParameterSpec{
+		"env": {Name: "env", Value: "prod"},
+	}, nil)
+	require.NoError(t, err)
+
+	tasks, err = dm.GetStartingTasks(ctx, "test_dag_when_start", prodRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "deploy", tasks[0].Name)
+}
+
+func testDAGManager_TriggerRules_AfterFailure(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_trigger_failure",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "build",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+				},
+				{
+					Name:     "deploy",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"build"},
+				},
+				{
+					Name:        "rollback",
+					Command:     []string{"echo"},
+					Image:       "alpine:latest",
+					RunAfter:    []string{"build"},
+					TriggerRule: v1alpha1.TriggerRuleOneFailed,
+				},
+				{
+					Name:        "cleanup",
+					Command:     []string{"echo"},
+					Image:       "alpine:latest",
+					RunAfter:    []string{"deploy", "rollback"},
+					TriggerRule: v1alpha1.TriggerRuleAllDone,
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "trigger-failure-run", &v1alpha1.DagRunSpec{DagName: "test_dag_trigger_failure"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_trigger_failure", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	buildRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+	require.NoError(t, dm.MarkTaskAsFailed(ctx, buildRunID))
+
+	// deploy can no longer run, rollback exists for exactly this case
+	suspended, err := dm.MarkConnectingTasksAsSuspended(ctx, runID, buildRunID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"deploy"}, suspended)
+
+	ready, err := dm.GetReadyTasks(ctx, runID)
+	require.NoError(t, err)
+	require.Len(t, ready, 1)
+	require.Equal(t, "rollback", ready[0].Name)
+
+	rollbackRunID, err := dm.MarkTaskAsStarted(ctx, runID, ready[0].Id)
+	require.NoError(t, err)
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, rollbackRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Equal(t, "cleanup", next[0].Name)
+
+	cleanupRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, cleanupRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	done, err := dm.CheckIfAllTasksDone(ctx, runID)
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func testDAGManager_TriggerRules_AfterSuccess(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_trigger_success",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "mirror-a",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+				},
+				{
+					Name:    "mirror-b",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+				},
+				{
+					Name:        "download",
+					Command:     []string{"echo"},
+					Image:       "alpine:latest",
+					RunAfter:    []string{"mirror-a", "mirror-b"},
+					TriggerRule: v1alpha1.TriggerRuleOneSuccess,
+				},
+				{
+					Name:        "alert",
+					Command:     []string{"echo"},
+					Image:       "alpine:latest",
+					RunAfter:    []string{"mirror-a", "mirror-b"},
+					TriggerRule: v1alpha1.TriggerRuleOneFailed,
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "trigger-success-run", &v1alpha1.DagRunSpec{DagName: "test_dag_trigger_success"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_trigger_success", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+
+	firstRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+	secondRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[1].Id)
+	require.NoError(t, err)
+
+	// one_success does not wait for the second mirror
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, firstRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Equal(t, "download", next[0].Name)
+
+	downloadRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	// once both mirrors succeed the alert is skipped rather than left pending
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, secondRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, downloadRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	done, err := dm.CheckIfAllTasksDone(ctx, runID)
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func testDAGManager_MapTasks_Parameter(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_map_param",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "partitions", DefaultValue: `["2024-01", "2024-02", "2024-03"]`},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "process",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Map: &v1alpha1.MapSpec{
+						Parameter:      "partitions",
+						MaxParallelism: 2,
+					},
+				},
+				{
+					Name:     "merge",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"process"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "map-param-run", &v1alpha1.DagRunSpec{DagName: "test_dag_map_param"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_map_param", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	_, err = dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	// only maxParallelism instances can be claimed at once
+	claims, err := dm.ClaimTasks(ctx, 10, "map-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 2)
+
+	items := map[int]string{}
+	for _, claim := range claims {
+		require.NotNil(t, claim.MapIndex)
+		items[*claim.MapIndex] = claim.MapItem
+		require.NoError(t, dm.FinalizeClaimToRunning(ctx, claim.TaskRunID, "map-worker", "pod-uid"))
+	}
+	require.Equal(t, map[int]string{0: "2024-01", 1: "2024-02"}, items)
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, claims[0].TaskRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	// the success of an instance lets the waiting one start
+	last, err := dm.ClaimTasks(ctx, 10, "map-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, last, 1)
+	require.Equal(t, 2, *last[0].MapIndex)
+	require.Equal(t, "2024-03", last[0].MapItem)
+	require.NoError(t, dm.FinalizeClaimToRunning(ctx, last[0].TaskRunID, "map-worker", "pod-uid"))
+
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, claims[1].TaskRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	// merge waits for every instance
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, last[0].TaskRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Equal(t, "merge", next[0].Name)
+
+	mergeRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	next, err = dm.MarkSuccessAndGetNextTasks(ctx, mergeRunID)
+	require.NoError(t, err)
+	require.Empty(t, next)
+
+	done, err := dm.CheckIfAllTasksDone(ctx, runID)
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func testDAGManager_MapTasks_EmptyOutput(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_map_output",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "list",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Outputs: []string{"files"},
+				},
+				{
+					Name:     "copy",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"list"},
+					Map:      &v1alpha1.MapSpec{Output: "list.files"},
+				},
+				{
+					Name:     "report",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"copy"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "map-output-run", &v1alpha1.DagRunSpec{DagName: "test_dag_map_output"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_map_output", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	listRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+	require.NoError(t, dm.SaveTaskOutputs(ctx, listRunID, map[string]string{"files": "[]"}))
+
+	// a map over nothing is skipped
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, listRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	require.Equal(t, "report", next[0].Name)
+}
+
+func testDAGManager_MapTasks_Failure(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_map_failure",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "hosts", DefaultValue: "web-1, web-2"},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "deploy",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Map: &v1alpha1.MapSpec{
+						Parameter:      "hosts",
+						MaxParallelism: 1,
+					},
+				},
+				{
+					Name:     "verify",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"deploy"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "map-failure-run", &v1alpha1.DagRunSpec{DagName: "test_dag_map_failure"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_map_failure", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	firstRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	// a retry runs the same item again
+	retryRunID, _, err := dm.RetryTaskRun(ctx, firstRunID)
+	require.NoError(t, err)
+
+	claim, err := dm.ClaimTaskByID(ctx, retryRunID, "map-worker", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claim.MapIndex)
+	require.Equal(t, 0, *claim.MapIndex)
+	require.Equal(t, "web-1", claim.MapItem)
+
+	// the failure abandons the waiting instance, so the map task is done
+	require.NoError(t, dm.MarkTaskAsFailed(ctx, retryRunID))
+
+	suspended, err := dm.MarkConnectingTasksAsSuspended(ctx, runID, retryRunID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"verify"}, suspended)
+
+	done, err := dm.CheckIfAllTasksDone(ctx, runID)
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func testDAGManager_MapTasks_RetryEnv(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_map_retry_env",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "hosts", DefaultValue: "web-1, web-2"},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "deploy",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Map: &v1alpha1.MapSpec{
+						Parameter: "hosts",
+					},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "map-retry-env-run", &v1alpha1.DagRunSpec{DagName: "test_dag_map_retry_env"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_map_retry_env", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	_, err = dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	claimed, err := dm.ClaimTasks(ctx, 10, "map-worker", time.Minute)
+	require.NoError(t, err)
+
+	var claims []db.TaskClaim
+	for _, claim := range claimed {
+		if claim.RunID == runID {
+			claims = append(claims, claim)
+		}
+	}
+	require.Len(t, claims, 2)
+
+	// only the second instance has an env saved, as if it was the one retried
+	envs := map[int]string{}
+	for _, claim := range claims {
+		require.NotNil(t, claim.MapIndex)
+		if *claim.MapIndex == 1 {
+			envs[claim.TaskRunID] = `[{"name":"MAP_ITEM","value":"web-2"}]`
+			require.NoError(t, dm.SaveRetryEnv(ctx, claim.TaskRunID, envs[claim.TaskRunID]))
+		} else {
+			envs[claim.TaskRunID] = ""
+		}
+	}
+
+	for _, claim := range claims {
+		_, _, retryEnv, err := dm.GetTaskForRun(ctx, runID, claim.TaskID, claim.TaskRunID)
+		require.NoError(t, err)
+		require.Equal(t, envs[claim.TaskRunID], retryEnv)
+	}
+}
+
+func testDAGManager_SubDags_Success(t *testing.T, dm db.DBDAGManager) {
+	child := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_child_dag",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "env", DefaultValue: "dev"},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:       "greet",
+					Command:    []string{"echo"},
+					Image:      "alpine:latest",
+					Parameters: []string{"env"},
+				},
+			},
+		},
+	}
+
+	parent := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_sub_dag",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "stage", DefaultValue: "prod"},
+				{Name: "token", DefaultFromSecret: "creds"},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "prepare",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+				},
+				{
+					Name:     "run-child",
+					RunAfter: []string{"prepare"},
+					DagRef: &v1alpha1.DagRef{
+						Name: "test_child_dag",
+						Parameters: []v1alpha1.DagRefParameter{
+							{Name: "env", FromParameter: "stage"},
+							{Name: "token", FromParameter: "token"},
+							{Name: "region", Value: "eu-west-1"},
+						},
+					},
+				},
+				{
+					Name:     "report",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"run-child"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, child, "default"))
+	require.NoError(t, dm.InsertDAG(ctx, parent, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "sub-dag-run", &v1alpha1.DagRunSpec{DagName: "test_dag_sub_dag"}, map[string]v1alpha1.ParameterSpec{
+		"stage": {Name: "stage", Value: "staging"},
+	}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_sub_dag", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	prepareRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	tasks, err = dm.MarkSuccessAndGetNextTasks(ctx, prepareRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "run-child", tasks[0].Name)
+
+	subDagTaskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	// workers never claim a sub-DAG task
+	claims, err := dm.ClaimTasks(ctx, 10, "sub-dag-worker", time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, claims)
+
+	subDagRuns, err := dm.StartSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, subDagRuns, 1)
+	require.Equal(t, subDagTaskRunID, subDagRuns[0].TaskRunId)
+	require.Equal(t, runID, subDagRuns[0].RunId)
+	require.Equal(t, "test_child_dag", subDagRuns[0].DagName)
+	require.Equal(t, "default", subDagRuns[0].Namespace)
+	require.ElementsMatch(t, []v1alpha1.ParameterSpec{
+		{Name: "env", Value: "staging"},
+		{Name: "token", FromSecret: "creds"},
+		{Name: "region", Value: "eu-west-1"},
+	}, subDagRuns[0].Parameters)
+
+	// the task is only started once while its lease holds
+	subDagRuns, err = dm.StartSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, subDagRuns)
+
+	// the task waits for its child run to exist before it runs
+	status, err := dm.GetTaskRunStatus(ctx, subDagTaskRunID)
+	require.NoError(t, err)
+	require.Equal(t, "pending_dag", status)
+
+	childRunID, err := dm.CreateDAGRun(ctx, "sub-dag-child-run", &v1alpha1.DagRunSpec{DagName: "test_child_dag"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, dm.LinkSubDagRun(ctx, childRunID, subDagTaskRunID))
+
+	status, err = dm.GetTaskRunStatus(ctx, subDagTaskRunID)
+	require.NoError(t, err)
+	require.Equal(t, "running", status)
+
+	finished, err := dm.GetFinishedSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, finished)
+
+	tasks, err = dm.GetStartingTasks(ctx, "test_child_dag", childRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	greetRunID, err := dm.AddPendingTaskRun(ctx, childRunID, tasks[0].Id)
+	require.NoError(t, err)
+
+	_, err = dm.MarkSuccessAndGetNextTasks(ctx, greetRunID)
+	require.NoError(t, err)
+
+	finished, err = dm.GetFinishedSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []db.FinishedSubDagRun{{
+		TaskRunId:  subDagTaskRunID,
+		RunId:      runID,
+		ChildRunId: childRunID,
+		Succeeded:  true,
+		Namespace:  "default",
+	}}, finished)
+
+	tasks, err = dm.MarkSuccessAndGetNextTasks(ctx, subDagTaskRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "report", tasks[0].Name)
+
+	finished, err = dm.GetFinishedSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, finished)
+}
+
+func testDAGManager_SubDags_Loop(t *testing.T, dm db.DBDAGManager) {
+	dagRefTask := func(name string) v1alpha1.TaskSpec {
+		return v1alpha1.TaskSpec{Name: "run-" + name, DagRef: &v1alpha1.DagRef{Name: name}}
+	}
+
+	first := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_loop_first"},
+		Spec:       v1alpha1.DAGSpec{Task: []v1alpha1.TaskSpec{dagRefTask("test_dag_loop_second")}},
+	}
+	second := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_loop_second"},
+		Spec:       v1alpha1.DAGSpec{Task: []v1alpha1.TaskSpec{dagRefTask("test_dag_loop_third")}},
+	}
+	third := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_loop_third"},
+		Spec:       v1alpha1.DAGSpec{Task: []v1alpha1.TaskSpec{dagRefTask("test_dag_loop_first")}},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, first, "default"))
+	require.NoError(t, dm.InsertDAG(ctx, second, "default"))
+
+	// the third DAG would run the first, which runs it again through the second
+	err := dm.InsertDAG(ctx, third, "default")
+	require.ErrorContains(t, err, "dagRef tasks form a loop")
+
+	// the same DAG in another namespace runs a different first DAG
+	require.NoError(t, dm.InsertDAG(ctx, third, "other"))
+}
+
+func testDAGManager_SubDags_Failure(t *testing.T, dm db.DBDAGManager) {
+	child := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_child_dag_failure",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "break",
+					Command: []string{"false"},
+					Image:   "alpine:latest",
+				},
+			},
+		},
+	}
+
+	parent := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_sub_dag_failure",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:   "run-child",
+					DagRef: &v1alpha1.DagRef{Name: "test_child_dag_failure"},
+				},
+				{
+					Name:     "report",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"run-child"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, child, "default"))
+	require.NoError(t, dm.InsertDAG(ctx, parent, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "sub-dag-failure-run", &v1alpha1.DagRunSpec{DagName: "test_dag_sub_dag_failure"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_sub_dag_failure", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	subDagTaskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	subDagRuns, err := dm.StartSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, subDagRuns, 1)
+	require.Empty(t, subDagRuns[0].Parameters)
+
+	childRunID, err := dm.CreateDAGRun(ctx, "sub-dag-failure-child-run", &v1alpha1.DagRunSpec{DagName: "test_child_dag_failure"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, dm.LinkSubDagRun(ctx, childRunID, subDagTaskRunID))
+
+	tasks, err = dm.GetStartingTasks(ctx, "test_child_dag_failure", childRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	breakRunID, err := dm.AddPendingTaskRun(ctx, childRunID, tasks[0].Id)
+	require.NoError(t, err)
+	require.NoError(t, dm.MarkTaskAsFailed(ctx, breakRunID))
+
+	finished, err := dm.GetFinishedSubDagRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, finished, 1)
+	require.Equal(t, subDagTaskRunID, finished[0].TaskRunId)
+	require.False(t, finished[0].Succeeded)
+
+	require.NoError(t, dm.MarkTaskAsFailed(ctx, subDagTaskRunID))
+
+	suspended, err := dm.MarkConnectingTasksAsSuspended(ctx, runID, subDagTaskRunID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"report"}, suspended)
+
+	done, err := dm.CheckIfAllTasksDone(ctx, runID)
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func testDAGManager_Timeouts(t *testing.T, dm db.DBDAGManager) {
+	taskTimeout := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_task_timeout",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "slow",
+					Command: []string{"sleep", "60"},
+					Image:   "alpine:latest",
+					Timeout: &metav1.Duration{Duration: time.Second},
+				},
+				{
+					Name:     "after",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"slow"},
+				},
+				{
+					Name:        "cleanup",
+					Command:     []string{"echo"},
+					Image:       "alpine:latest",
+					RunAfter:    []string{"slow"},
+					TriggerRule: v1alpha1.TriggerRuleAllDone,
+				},
+			},
+		},
+	}
+
+	runTimeout := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_run_timeout",
+		},
+		Spec: v1alpha1.DAGSpec{
+			RunTimeout: &metav1.Duration{Duration: time.Second},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "long",
+					Command: []string{"sleep", "60"},
+					Image:   "alpine:latest",
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, taskTimeout, "default"))
+	require.NoError(t, dm.InsertDAG(ctx, runTimeout, "default"))
+
+	taskRunID, err := dm.CreateDAGRun(ctx, "task-timeout-run", &v1alpha1.DagRunSpec{DagName: "test_dag_task_timeout"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_task_timeout", taskRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	slowRunID, err := dm.AddPendingTaskRun(ctx, taskRunID, tasks[0].Id)
+	require.NoError(t, err)
+
+	runRunID, err := dm.CreateDAGRun(ctx, "run-timeout-run", &v1alpha1.DagRunSpec{DagName: "test_dag_run_timeout"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err = dm.GetStartingTasks(ctx, "test_dag_run_timeout", runRunID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	longRunID, err := dm.AddPendingTaskRun(ctx, runRunID, tasks[0].Id)
+	require.NoError(t, err)
+
+	claims, err := dm.ClaimTasks(ctx, 10, "timeout-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 2)
+
+	// the slow task is left claimed, as if its pod was still being created
+	require.NoError(t, dm.FinalizeClaimToRunning(ctx, longRunID, "timeout-worker", "long-uid"))
+	require.NoError(t, dm.MarkPodStatus(ctx, types.UID("slow-uid"), "slow-pod", slowRunID, v1.PodPending, time.Now(), nil, "default"))
+
+	timedOutTasks, err := dm.TimeOutTaskRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, timedOutTasks)
+
+	timedOutRuns, err := dm.TimeOutDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, timedOutRuns)
+
+	time.Sleep(2100 * time.Millisecond)
+
+	timedOutRuns, err = dm.TimeOutDagRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, timedOutRuns, 1)
+	require.Equal(t, runRunID, timedOutRuns[0].RunId)
+	require.Equal(t, "test_dag_run_timeout", timedOutRuns[0].DagName)
+	require.Len(t, timedOutRuns[0].TaskRuns, 1)
+	require.Equal(t, longRunID, timedOutRuns[0].TaskRuns[0].TaskRunId)
+	require.Equal(t, "long", timedOutRuns[0].TaskRuns[0].TaskName)
+
+	status, err := dm.GetTaskRunStatus(ctx, longRunID)
+	require.NoError(t, err)
+	require.Equal(t, "timed_out", status)
+
+	timedOutTasks, err = dm.TimeOutTaskRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, timedOutTasks, 1)
+	require.Equal(t, slowRunID, timedOutTasks[0].TaskRunId)
+	require.Equal(t, "slow", timedOutTasks[0].TaskName)
+	require.Equal(t, "test_dag_task_timeout", timedOutTasks[0].DagName)
+	require.Equal(t, []db.RunningPodInfo{{Name: "slow-pod", Namespace: "default"}}, timedOutTasks[0].Pods)
+
+	status, err = dm.GetTaskRunStatus(ctx, slowRunID)
+	require.NoError(t, err)
+	require.Equal(t, "timed_out", status)
+
+	// a worker that was still creating the pod can no longer move the task run to running
+	require.Error(t, dm.FinalizeClaimToRunning(ctx, slowRunID, "timeout-worker", "slow-uid"))
+
+	suspended, err := dm.MarkConnectingTasksAsSuspended(ctx, taskRunID, slowRunID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"after"}, suspended)
+
+	ready, err := dm.GetReadyTasks(ctx, taskRunID)
+	require.NoError(t, err)
+	require.Len(t, ready, 1)
+	require.Equal(t, "cleanup", ready[0].Name)
+
+	timedOutTasks, err = dm.TimeOutTaskRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, timedOutTasks)
+}
+
+func testDAGManager_RetryBackoff(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_retry_backoff",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "flaky",
+					Command: []string{"echo", "flaky"},
+					Image:   "busybox",
+					Backoff: v1alpha1.Backoff{
+						Limit:        5,
+						InitialDelay: &metav1.Duration{Duration: time.Hour},
+						MaxDelay:     &metav1.Duration{Duration: 3 * time.Hour},
+						Multiplier:   "2",
+					},
+				},
+				{
+					Name:     "jittery",
+					Command:  []string{"echo", "jittery"},
+					Image:    "busybox",
+					RunAfter: []string{"flaky"},
+					Backoff: v1alpha1.Backoff{
+						Limit:        5,
+						InitialDelay: &metav1.Duration{Duration: time.Hour},
+						Jitter:       "0.5",
+					},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "retry-backoff-run", &v1alpha1.DagRunSpec{DagName: "test_dag_retry_backoff"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_retry_backoff", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	// each retry waits twice as long as the last, up to the max delay
+	for _, want := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		retryID, delay, err := dm.RetryTaskRun(ctx, taskRunID)
+		require.NoError(t, err)
+		require.Equal(t, want, delay)
+
+		// the retry cannot be claimed before its scheduled start
+		_, err = dm.ClaimTaskByID(ctx, retryID, "backoff-worker", time.Minute)
+		require.Error(t, err)
+
+		claims, err := dm.ClaimTasks(ctx, 10, "backoff-worker", time.Minute)
+		require.NoError(t, err)
+		for _, claim := range claims {
+			require.NotEqual(t, retryID, claim.TaskRunID)
+		}
+
+		taskRunID = retryID
+	}
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, taskRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+
+	jitteryRunID, err := dm.AddPendingTaskRun(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	// the jitter moves the delay by up to half of it either way
+	_, delay, err := dm.RetryTaskRun(ctx, jitteryRunID)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, delay, 30*time.Minute)
+	require.LessOrEqual(t, delay, 90*time.Minute)
+}
+
+func testDAGManager_RetryLimit(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_retry_limit",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "flaky",
+					Command: []string{"false"},
+					Image:   "busybox",
+					Backoff: v1alpha1.Backoff{Limit: 2},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "retry-limit-run", &v1alpha1.DagRunSpec{DagName: "test_dag_retry_limit"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_retry_limit", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	// the limit is the number of retries, so the task runs three times in all
+	retries := 0
+	for {
+		ok, err := dm.ShouldRerun(ctx, taskRunID, 1)
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+
+		taskRunID, _, err = dm.RetryTaskRun(ctx, taskRunID)
+		require.NoError(t, err)
+		retries++
+		require.LessOrEqual(t, retries, 2)
+	}
+	require.Equal(t, 2, retries)
+}
+
+func testDAGManager_ConcurrencyPolicy(t *testing.T, dm db.DBDAGManager) {
+	newDag := func(name string, policy v1alpha1.ConcurrencyPolicy) *v1alpha1.DAG {
+		return &v1alpha1.DAG{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.DAGSpec{
+				ConcurrencyPolicy: policy,
+				MaxActiveRuns:     1,
+				Task: []v1alpha1.TaskSpec{
+					{
+						Name:    "only",
+						Command: []string{"echo", "only"},
+						Image:   "busybox",
+					},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	params := map[string]v1alpha1.ParameterSpec{}
+
+	t.Run("forbid", func(t *testing.T) {
+		require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_forbid", v1alpha1.ConcurrencyPolicyForbid), "default"))
+		spec := &v1alpha1.DagRunSpec{DagName: "test_dag_forbid"}
+
+		first, err := dm.StartDAGRun(ctx, "forbid-run-1", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "running", first.Status)
+
+		second, err := dm.StartDAGRun(ctx, "forbid-run-2", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "skipped", second.Status)
+		require.Zero(t, second.RunId)
+
+		// starting an existing run again returns it as it is
+		again, err := dm.StartDAGRun(ctx, "forbid-run-1", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, first.RunId, again.RunId)
+		require.Equal(t, "running", again.Status)
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_queue", v1alpha1.ConcurrencyPolicyQueue), "default"))
+		spec := &v1alpha1.DagRunSpec{DagName: "test_dag_queue"}
+
+		first, err := dm.StartDAGRun(ctx, "queue-run-1", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "running", first.Status)
+
+		second, err := dm.StartDAGRun(ctx, "queue-run-2", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "queued", second.Status)
+
+		started, err := dm.StartQueuedDAGRuns(ctx)
+		require.NoError(t, err)
+		require.Empty(t, started)
+
+		tasks, err := dm.GetStartingTasks(ctx, "test_dag_queue", first.RunId)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+
+		taskRunID, err := dm.AddPendingTaskRun(ctx, first.RunId, tasks[0].Id)
+		require.NoError(t, err)
+
+		_, err = dm.MarkSuccessAndGetNextTasks(ctx, taskRunID)
+		require.NoError(t, err)
+
+		// the queued run starts once the first one has finished
+		started, err = dm.StartQueuedDAGRuns(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []db.QueuedDagRun{{RunId: second.RunId, DagName: "test_dag_queue", Namespace: "default"}}, started)
+
+		again, err := dm.StartDAGRun(ctx, "queue-run-2", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "running", again.Status)
+	})
+
+	t.Run("queue behind a failed run", func(t *testing.T) {
+		dag := newDag("test_dag_queue_failed", v1alpha1.ConcurrencyPolicyQueue)
+		dag.Spec.Task = append(dag.Spec.Task, v1alpha1.TaskSpec{Name: "other", Command: []string{"echo", "other"}, Image: "busybox"})
+		require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+		spec := &v1alpha1.DagRunSpec{DagName: "test_dag_queue_failed"}
+
+		first, err := dm.StartDAGRun(ctx, "queue-failed-run-1", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "running", first.Status)
+
+		tasks, err := dm.GetStartingTasks(ctx, "test_dag_queue_failed", first.RunId)
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+
+		failedID, err := dm.AddPendingTaskRun(ctx, first.RunId, tasks[0].Id)
+		require.NoError(t, err)
+		otherID, err := dm.AddPendingTaskRun(ctx, first.RunId, tasks[1].Id)
+		require.NoError(t, err)
+		require.NoError(t, dm.MarkTaskAsFailed(ctx, failedID))
+
+		// the failed run still has a task left running, so it keeps its slot
+		second, err := dm.StartDAGRun(ctx, "queue-failed-run-2", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "queued", second.Status)
+
+		started, err := dm.StartQueuedDAGRuns(ctx)
+		require.NoError(t, err)
+		require.Empty(t, started)
+
+		_, err = dm.MarkSuccessAndGetNextTasks(ctx, otherID)
+		require.NoError(t, err)
+
+		started, err = dm.StartQueuedDAGRuns(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []db.QueuedDagRun{{RunId: second.RunId, DagName: "test_dag_queue_failed", Namespace: "default"}}, started)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_replace", v1alpha1.ConcurrencyPolicyReplace), "default"))
+		spec := &v1alpha1.DagRunSpec{DagName: "test_dag_replace"}
+
+		first, err := dm.StartDAGRun(ctx, "replace-run-1", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "running", first.Status)
+
+		tasks, err := dm.GetStartingTasks(ctx, "test_dag_replace", first.RunId)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+
+		taskRunID, err := dm.AddPendingTaskRun(ctx, first.RunId, tasks[0].Id)
+		require.NoError(t, err)
+
+		second, err := dm.StartDAGRun(ctx, "replace-run-2", spec, params, nil)
+		require.NoError(t, err)
+		require.Equal(t, "running", second.Status)
+		require.Len(t, second.Replaced, 1)
+		require.Equal(t, first.RunId, second.Replaced[0].RunId)
+		require.Len(t, second.Replaced[0].TaskRuns, 1)
+		require.Equal(t, taskRunID, second.Replaced[0].TaskRuns[0].TaskRunId)
+
+		status, err := dm.GetTaskRunStatus(ctx, taskRunID)
+		require.NoError(t, err)
+		require.Equal(t, "suspended", status)
+	})
+}
+
+func testDAGManager_Catchup(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	newDag := func(name string, catchup bool, maxCatchupRuns int) *v1alpha1.DAG {
+		return &v1alpha1.DAG{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.DAGSpec{
+				Schedule:       "*/1 * * * *",
+				Catchup:        catchup,
+				MaxCatchupRuns: maxCatchupRuns,
+				Task: []v1alpha1.TaskSpec{
+					{
+						Name:    "task1",
+						Command: []string{"echo", "Hello"},
+						Image:   "busybox",
+					},
+				},
+			},
+		}
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_catchup", true, 3), "default"))
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_no_catchup", false, 0), "default"))
+
+	// the scheduler comes back ten minutes late
+	now := time.Now().Add(10 * time.Minute)
+	dags, err := dm.GetDAGsToStartAndUpdate(ctx, now)
+	require.NoError(t, err)
+
+	scheduled := map[string][]time.Time{}
+	for _, dag := range dags {
+		scheduled[dag.DagName] = append(scheduled[dag.DagName], dag.ScheduledTime)
+	}
+
+	// only the latest three missed minutes are caught up on
+	require.Len(t, scheduled["test_dag_catchup"], 3)
+	for i, scheduledTime := range scheduled["test_dag_catchup"] {
+		require.False(t, scheduledTime.After(now))
+		require.Zero(t, scheduledTime.Second())
+		if i > 0 {
+			require.Equal(t, time.Minute, scheduledTime.Sub(scheduled["test_dag_catchup"][i-1]))
+		}
+	}
+
+	require.Len(t, scheduled["test_dag_no_catchup"], 1)
+	require.True(t, scheduled["test_dag_no_catchup"][0].Equal(scheduled["test_dag_catchup"][2]))
+
+	dags, err = dm.GetDAGsToStartAndUpdate(ctx, now)
+	require.NoError(t, err)
+	require.Empty(t, dags)
+
+	// the schedule time reaches the tasks of the run
+	scheduledTime := metav1.NewTime(scheduled["test_dag_catchup"][0])
+	runID, err := dm.CreateDAGRun(ctx, "catchup-run", &v1alpha1.DagRunSpec{DagName: "test_dag_catchup", ScheduledTime: &scheduledTime}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_catchup", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	task, _, _, err := dm.GetTaskForRun(ctx, runID, tasks[0].Id, 0)
+	require.NoError(t, err)
+	require.NotNil(t, task.ScheduledTime)
+	require.True(t, task.ScheduledTime.Equal(scheduledTime.Time))
+	require.True(t, task.LogicalTime.Equal(scheduledTime.Time))
+}
+
+func testDAGManager_Backfill(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_backfill",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Schedule: "0 * * * *",
+			Backfill: &v1alpha1.Backfill{
+				Start: metav1.NewTime(start),
+				End:   metav1.NewTime(start.Add(71 * time.Hour)),
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "task1",
+					Command: []string{"echo", "Hello"},
+					Image:   "busybox",
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	// the 72 hours of the range are worked through in batches
+	first, err := dm.GetBackfillsToStartAndUpdate(ctx)
+	require.NoError(t, err)
+	require.Len(t, first, 50)
+	require.True(t, first[0].ScheduledTime.Equal(start))
+
+	second, err := dm.GetBackfillsToStartAndUpdate(ctx)
+	require.NoError(t, err)
+	require.Len(t, second, 22)
+	require.True(t, second[0].ScheduledTime.Equal(start.Add(50*time.Hour)))
+	require.True(t, second[21].ScheduledTime.Equal(start.Add(71*time.Hour)))
+	require.Equal(t, "test_dag_backfill", second[21].DagName)
+	require.Equal(t, "default", second[21].Namespace)
+
+	done, err := dm.GetBackfillsToStartAndUpdate(ctx)
+	require.NoError(t, err)
+	require.Empty(t, done)
+
+	// applying the same range again does not backfill it twice
+	require.EqualError(t, dm.InsertDAG(ctx, dag, "default"), "applying the same dag")
+
+	dag.Spec.Backfill = &v1alpha1.Backfill{
+		Start: metav1.NewTime(start.Add(100 * time.Hour)),
+		End:   metav1.NewTime(start.Add(102 * time.Hour)),
+	}
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	next, err := dm.GetBackfillsToStartAndUpdate(ctx)
+	require.NoError(t, err)
+	require.Len(t, next, 3)
+
+	// a backfill is not a change to the DAG itself
+	status, err := dm.GetDagStatus(ctx, "test_dag_backfill", "default")
+	require.NoError(t, err)
+	require.Equal(t, 0, status.Version)
+}
+
+func testDAGManager_ScheduleTimezone(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_timezone",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Schedule:  "0 9 * * *",
+			Timezone:  "Europe/London",
+			Catchup:   true,
+			StartTime: &metav1.Time{Time: time.Date(2030, 3, 30, 0, 0, 0, 0, time.UTC)},
+			EndTime:   &metav1.Time{Time: time.Date(2030, 4, 1, 12, 0, 0, 0, time.UTC)},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "task1",
+					Command: []string{"echo", "Hello"},
+					Image:   "busybox",
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	dags, err := dm.GetDAGsToStartAndUpdate(ctx, time.Date(2030, 4, 2, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	// 09:00 in London moves from 09:00 UTC to 08:00 UTC once the clocks go forward on the 31st
+	want := []time.Time{
+		time.Date(2030, 3, 30, 9, 0, 0, 0, time.UTC),
+		time.Date(2030, 3, 31, 8, 0, 0, 0, time.UTC),
+		time.Date(2030, 4, 1, 8, 0, 0, 0, time.UTC),
+	}
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	require.Len(t, dags, len(want))
+	for i, dag := range dags {
+		require.True(t, want[i].Equal(dag.ScheduledTime), "expected %s, got %s", want[i], dag.ScheduledTime)
+		require.Equal(t, 9, dag.ScheduledTime.In(london).Hour())
+	}
+
+	// no runs are left once the window has closed
+	dags, err = dm.GetDAGsToStartAndUpdate(ctx, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Empty(t, dags)
+}
+
+func testDAGManager_DagRunSummary(t *testing.T, dm db.DBDAGManager) {
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_run_summary",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "first",
+					Command: []string{"echo", "first"},
+					Image:   "busybox",
+				},
+				{
+					Name:     "second",
+					Command:  []string{"echo", "second"},
+					Image:    "busybox",
+					RunAfter: []string{"first"},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	_, err := dm.GetDagRunSummary(ctx, 987654)
+	require.ErrorIs(t, err, db.ErrDagRunNotFound)
+
+	runID, err := dm.CreateDAGRun(ctx, "run-summary", &v1alpha1.DagRunSpec{DagName: "test_dag_run_summary"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_run_summary", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	firstRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	summary, err := dm.GetDagRunSummary(ctx, runID)
+	require.NoError(t, err)
+	require.Equal(t, "running", summary.Status)
+	require.NotNil(t, summary.StartTime)
+	require.Nil(t, summary.FinishTime)
+	require.Equal(t, map[string]int{"pending": 1}, summary.TaskRuns)
+
+	// a retry replaces the task run it repeats
+	retryRunID, _, err := dm.RetryTaskRun(ctx, firstRunID)
+	require.NoError(t, err)
+
+	summary, err = dm.GetDagRunSummary(ctx, runID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"pending": 1}, summary.TaskRuns)
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, retryRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+
+	secondRunID, err := dm.AddPendingTaskRun(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	// the run finishes when its last pod does
+	podEnd := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, dm.MarkPodStatus(ctx, types.UID("summary-uid"), "summary-pod", secondRunID, v1.PodSucceeded, podEnd, nil, "default"))
+
+	_, err = dm.MarkSuccessAndGetNextTasks(ctx, secondRunID)
+	require.NoError(t, err)
+
+	summary, err = dm.GetDagRunSummary(ctx, runID)
+	require.NoError(t, err)
+	require.Equal(t, "success", summary.Status)
+	require.Equal(t, map[string]int{"success": 2}, summary.TaskRuns)
+	require.NotNil(t, summary.FinishTime)
+	require.True(t, podEnd.Equal(*summary.FinishTime), "expected finish time %s, got %s", podEnd, summary.FinishTime)
+}
+
+func testDAGManager_DagStatus(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+
+	_, err := dm.GetDagStatus(ctx, "test_dag_status", "default")
+	require.ErrorIs(t, err, db.ErrDagNotFound)
+
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_status",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "only",
+					Command: []string{"echo", "only"},
+					Image:   "busybox",
+				},
+			},
+		},
+	}
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	status, err := dm.GetDagStatus(ctx, "test_dag_status", "default")
+	require.NoError(t, err)
+	require.Equal(t, 0, status.Version)
+	require.NotEmpty(t, status.Hash)
+	require.Nil(t, status.NextTime)
+	require.Nil(t, status.LastRun)
+
+	runID, err := dm.CreateDAGRun(ctx, "dag-status-run", &v1alpha1.DagRunSpec{DagName: "test_dag_status"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	// a new version is stored and its next time set once the DAG is scheduled
+	dag.Spec.Schedule = "0 * * * *"
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	updated, err := dm.GetDagStatus(ctx, "test_dag_status", "default")
+	require.NoError(t, err)
+	require.Equal(t, 1, updated.Version)
+	require.NotEqual(t, status.Hash, updated.Hash)
+	require.NotNil(t, updated.NextTime)
+	require.Equal(t, &db.DagRunRef{RunId: runID, Name: "dag-status-run"}, updated.LastRun)
+}
+
+func testDAGManager_ParameterKeyRefs(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_key_refs",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "env", DefaultValue: "dev"},
+				{Name: "token", DefaultFromSecret: "creds/api-token"},
+				{Name: "legacy", DefaultFromSecret: "old-creds"},
+				{Name: "region", DefaultConfigMapKeyRef: &v1alpha1.KeyRef{Name: "settings", Key: "region"}},
+			},
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:       "task1",
+					Command:    []string{"echo", "Hello"},
+					Image:      "busybox",
+					Parameters: []string{"env", "token", "legacy", "region"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	params, err := dm.GetDagParameters(ctx, "test_dag_key_refs")
+	require.NoError(t, err)
+	require.Equal(t, db.Parameter{Name: "token", IsSecret: true, Key: "api-token", Value: "creds"}, *params["token"])
+	require.Equal(t, db.Parameter{Name: "region", IsConfigMap: true, Key: "region", Value: "settings"}, *params["region"])
+
+	// run values override the defaults, keeping where they are read from
+	runID, err := dm.CreateDAGRun(ctx, "key-refs-run", &v1alpha1.DagRunSpec{DagName: "test_dag_key_refs"}, map[string]v1alpha1.ParameterSpec{
+		"env":   {Name: "env", Value: "prod"},
+		"token": {Name: "token", SecretKeyRef: &v1alpha1.KeyRef{Name: "prod-creds", Key: "token"}},
+	}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_key_refs", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	task, _, _, err := dm.GetTaskForRun(ctx, runID, tasks[0].Id, 0)
+	require.NoError(t, err)
+	require.Equal(t, []db.Parameter{
+		{Name: "env", Value: "prod"},
+		{Name: "token", IsSecret: true, Key: "token", Value: "prod-creds"},
+		{Name: "legacy", IsSecret: true, Key: v1alpha1.DefaultSecretKey, Value: "old-creds"},
+		{Name: "region", IsConfigMap: true, Key: "region", Value: "settings"},
+	}, task.Parameters)
+
+	// templates can only read the values that are known before the task runs
+	require.Equal(t, map[string]string{"env": "prod"}, task.RunParameters)
+	require.False(t, task.LogicalTime.IsZero())
+}
+
+func testDAGManager_TaskCache(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_cache",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "extract",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Outputs: []string{"rowCount"},
+					Cache:   &v1alpha1.CacheSpec{TTL: metav1.Duration{Duration: time.Hour}},
+				},
+				{
+					Name:     "load",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"extract"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runExtract := func(name string) (int, int, db.Task) {
+		runID, err := dm.CreateDAGRun(ctx, name, &v1alpha1.DagRunSpec{DagName: "test_dag_cache"}, map[string]v1alpha1.ParameterSpec{}, nil)
+		require.NoError(t, err)
+
+		tasks, err := dm.GetStartingTasks(ctx, "test_dag_cache", runID)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+
+		task, _, _, err := dm.GetTaskForRun(ctx, runID, tasks[0].Id, 0)
+		require.NoError(t, err)
+
+		taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+		require.NoError(t, err)
+
+		_, err = dm.ClaimTaskByID(ctx, taskRunID, "cache-worker", time.Minute)
+		require.NoError(t, err)
+
+		return runID, taskRunID, task
+	}
+
+	_, firstRunID, extract := runExtract("cache-run-1")
+	require.Equal(t, time.Hour, extract.CacheTTL)
+	require.NotEmpty(t, extract.Hash)
+
+	// nothing is cached yet, so the task has to run
+	hit, _, err := dm.CompleteTaskRunFromCache(ctx, firstRunID, "cache-worker", "key-1")
+	require.NoError(t, err)
+	require.False(t, hit)
+
+	require.NoError(t, dm.SaveTaskOutputs(ctx, firstRunID, map[string]string{"rowCount": "42"}))
+	_, err = dm.MarkSuccessAndGetNextTasks(ctx, firstRunID)
+	require.NoError(t, err)
+
+	// a different key misses
+	_, otherRunID, _ := runExtract("cache-run-2")
+	hit, _, err = dm.CompleteTaskRunFromCache(ctx, otherRunID, "cache-worker", "key-2")
+	require.NoError(t, err)
+	require.False(t, hit)
+
+	// the same key replays the stored outputs and moves the run on
+	runID, cachedRunID, _ := runExtract("cache-run-3")
+	// a worker that no longer holds the claim can't complete the task run
+	_, _, err = dm.CompleteTaskRunFromCache(ctx, cachedRunID, "other-worker", "key-1")
+	require.ErrorIs(t, err, db.ErrTaskRunNotClaimed)
+
+	hit, next, err := dm.CompleteTaskRunFromCache(ctx, cachedRunID, "cache-worker", "key-1")
+	require.NoError(t, err)
+	require.True(t, hit)
+	require.Len(t, next, 1)
+
+	load, _, _, err := dm.GetTaskForRun(ctx, runID, next[0].Id, 0)
+	require.NoError(t, err)
+	require.Equal(t, []db.TaskOutput{{TaskName: "extract", Name: "rowCount", Value: "42"}}, load.UpstreamOutputs)
+
+	summary, err := dm.GetDagRunSummary(ctx, runID)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.TaskRuns["cached"])
+
+	// invalidating a task that isn't cached leaves the entry in place
+	removed, err := dm.InvalidateTaskCache(ctx, "test_dag_cache", "default", []string{"load"})
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+
+	removed, err = dm.InvalidateTaskCache(ctx, "test_dag_cache", "default", nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	_, afterRunID, _ := runExtract("cache-run-4")
+	hit, _, err = dm.CompleteTaskRunFromCache(ctx, afterRunID, "cache-worker", "key-1")
+	require.NoError(t, err)
+	require.False(t, hit)
+}
+
+func testDAGManager_TaskArtifacts(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_artifacts",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "extract",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+					Artifacts: []v1alpha1.ArtifactSpec{
+						{Name: "report", Path: "/out/report.html"},
+						{Name: "data", Path: "/out/data"},
+					},
+				},
+				{
+					Name:     "transform",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"extract"},
+				},
+				{
+					Name:     "load",
+					Command:  []string{"echo"},
+					Image:    "alpine:latest",
+					RunAfter: []string{"transform"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "artifacts-run", &v1alpha1.DagRunSpec{DagName: "test_dag_artifacts"}, map[string]v1alpha1.ParameterSpec{}, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_artifacts", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	extract, _, _, err := dm.GetTaskForRun(ctx, runID, tasks[0].Id, 0)
+	require.NoError(t, err)
+	require.Equal(t, dag.Spec.Task[0].Artifacts, extract.Artifacts)
+	require.Empty(t, extract.UpstreamArtifacts)
+
+	extractRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, extractRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+
+	transform, _, _, err := dm.GetTaskForRun(ctx, runID, next[0].Id, 0)
+	require.NoError(t, err)
+	require.Empty(t, transform.Artifacts)
+	require.Equal(t, []db.TaskArtifact{
+		{TaskName: "extract", Name: "data"},
+		{TaskName: "extract", Name: "report"},
+	}, transform.UpstreamArtifacts)
+
+	transformRunID, err := dm.MarkTaskAsStarted(ctx, runID, next[0].Id)
+	require.NoError(t, err)
+
+	last, err := dm.MarkSuccessAndGetNextTasks(ctx, transformRunID)
+	require.NoError(t, err)
+	require.Len(t, last, 1)
+
+	// artifacts are visible to transitive downstream tasks too
+	load, _, _, err := dm.GetTaskForRun(ctx, runID, last[0].Id, 0)
+	require.NoError(t, err)
+	require.Len(t, load.UpstreamArtifacts, 2)
+}
+
+// testDAGManager_TaskPriority queues a task run for each of three runs and claims them one at a time.
+// age moves back the time a task run was queued, to check that waiting task runs gain priority
+func testDAGManager_TaskPriority(t *testing.T, dm db.DBDAGManager, age func(taskRunId int, by time.Duration)) {
+	newDag := func(name string, priority, taskPriority int32) *v1alpha1.DAG {
+		return &v1alpha1.DAG{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.DAGSpec{
+				Priority: priority,
+				Task: []v1alpha1.TaskSpec{
+					{
+						Name:     "only",
+						Command:  []string{"echo", "only"},
+						Image:    "busybox",
+						Priority: taskPriority,
+					},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_priority_low", 0, 0), "default"))
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_priority_high", 5, 2), "default"))
+
+	queue := func(dagName, runName string, priority *int32) (int, int) {
+		runID, err := dm.CreateDAGRun(ctx, runName, &v1alpha1.DagRunSpec{DagName: dagName, Priority: priority}, map[string]v1alpha1.ParameterSpec{}, nil)
+		require.NoError(t, err)
+
+		tasks, err := dm.GetStartingTasks(ctx, dagName, runID)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+
+		taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+		require.NoError(t, err)
+
+		return runID, taskRunID
+	}
+
+	urgentPriority := int32(20)
+	low, _ := queue("test_dag_priority_low", "priority-low-run", nil)
+	high, _ := queue("test_dag_priority_high", "priority-high-run", nil)
+	urgent, _ := queue("test_dag_priority_low", "priority-urgent-run", &urgentPriority)
+
+	// the priority of a run replaces that of its DAG, the priority of the task is added to either
+	for _, want := range []int{urgent, high, low} {
+		claims, err := dm.ClaimTasks(ctx, 1, "priority-worker", time.Minute)
+		require.NoError(t, err)
+		require.Len(t, claims, 1)
+		require.Equal(t, want, claims[0].RunID)
+	}
+
+	// claimed task runs are not handed out again while their lease holds
+	claims, err := dm.ClaimTasks(ctx, 10, "priority-worker", time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, claims)
+
+	// a task run that has waited long enough goes ahead of higher priorities
+	starved, starvedTaskRunID := queue("test_dag_priority_low", "priority-starved-run", nil)
+	high, _ = queue("test_dag_priority_high", "priority-high-run-2", nil)
+	age(starvedTaskRunID, 10*time.Minute)
+
+	claims, err = dm.ClaimTasks(ctx, 1, "priority-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	require.Equal(t, starved, claims[0].RunID)
+
+	claims, err = dm.ClaimTasks(ctx, 1, "priority-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	require.Equal(t, high, claims[0].RunID)
+}
+
+// testDAGManager_Pools queues task runs in a pool and checks they are only claimed while the pool has free slots.
+// A slot is released once the pod of the task run finishes
+func testDAGManager_Pools(t *testing.T, dm db.DBDAGManager) {
+	newDag := func(name, pool string, poolSlots int32) *v1alpha1.DAG {
+		return &v1alpha1.DAG{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.DAGSpec{
+				Task: []v1alpha1.TaskSpec{
+					{
+						Name:      "only",
+						Command:   []string{"echo", "only"},
+						Image:     "busybox",
+						Pool:      pool,
+						PoolSlots: poolSlots,
+					},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_pool_narrow", "warehouse", 0), "default"))
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_pool_wide", "warehouse", 2), "default"))
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_pool_none", "", 0), "default"))
+
+	queue := func(dagName, runName string) (int, int) {
+		runID, err := dm.CreateDAGRun(ctx, runName, &v1alpha1.DagRunSpec{DagName: dagName}, map[string]v1alpha1.ParameterSpec{}, nil)
+		require.NoError(t, err)
+
+		tasks, err := dm.GetStartingTasks(ctx, dagName, runID)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+
+		taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+		require.NoError(t, err)
+
+		return runID, taskRunID
+	}
+
+	usage := func() []db.PoolUsage {
+		pools, err := dm.GetPoolUsage(ctx)
+		require.NoError(t, err)
+		return pools
+	}
+
+	narrow, narrowTaskRunID := queue("test_dag_pool_narrow", "pool-narrow-run")
+	wide, _ := queue("test_dag_pool_wide", "pool-wide-run")
+	none, _ := queue("test_dag_pool_none", "pool-none-run")
+
+	// tasks naming a pool that does not exist wait for it
+	claims, err := dm.ClaimTasks(ctx, 10, "pool-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	require.Equal(t, none, claims[0].RunID)
+
+	warehouse := &v1alpha1.Pool{
+		ObjectMeta: metav1.ObjectMeta{Name: "warehouse"},
+		Spec:       v1alpha1.PoolSpec{Slots: 2},
+	}
+	require.NoError(t, dm.UpsertPool(ctx, warehouse, "default"))
+	require.Equal(t, []db.PoolUsage{{Name: "warehouse", Namespace: "default", Slots: 2, OccupiedSlots: 0, QueuedSlots: 3}}, usage())
+
+	// both fit the free slots on their own, but only the first fits alongside the other
+	claims, err = dm.ClaimTasks(ctx, 10, "pool-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	require.Equal(t, narrow, claims[0].RunID)
+	require.Equal(t, []db.PoolUsage{{Name: "warehouse", Namespace: "default", Slots: 2, OccupiedSlots: 1, QueuedSlots: 2}}, usage())
+
+	// a running task run keeps its slot
+	require.NoError(t, dm.FinalizeClaimToRunning(ctx, narrowTaskRunID, "pool-worker", "pool-pod-uid"))
+	claims, err = dm.ClaimTasks(ctx, 10, "pool-worker", time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, claims)
+
+	// the slot is released once its pod has finished
+	require.NoError(t, dm.MarkPodStatus(ctx, types.UID(uuid.New().String()), "pool-narrow-pod", narrowTaskRunID, v1.PodSucceeded, time.Now(), nil, "default"))
+	claims, err = dm.ClaimTasks(ctx, 10, "pool-worker", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	require.Equal(t, wide, claims[0].RunID)
+	require.Equal(t, []db.PoolUsage{{Name: "warehouse", Namespace: "default", Slots: 2, OccupiedSlots: 2, QueuedSlots: 0}}, usage())
+
+	// claiming by id respects the pool too
+	_, waitingTaskRunID := queue("test_dag_pool_narrow", "pool-narrow-run-2")
+	_, err = dm.ClaimTaskByID(ctx, waitingTaskRunID, "pool-worker", time.Minute)
+	require.Error(t, err)
+
+	// more slots let the waiting task run through
+	warehouse.Spec.Slots = 3
+	require.NoError(t, dm.UpsertPool(ctx, warehouse, "default"))
+	claim, err := dm.ClaimTaskByID(ctx, waitingTaskRunID, "pool-worker", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, waitingTaskRunID, claim.TaskRunID)
+	require.Equal(t, []db.PoolUsage{{Name: "warehouse", Namespace: "default", Slots: 3, OccupiedSlots: 3, QueuedSlots: 0}}, usage())
+
+	require.NoError(t, dm.DeletePool(ctx, "warehouse", "default"))
+	require.Empty(t, usage())
+}
+
+func testDAGManager_TaskSensor(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	sensor := &v1alpha1.SensorSpec{
+		Interval: &metav1.Duration{Duration: 15 * time.Second},
+		Timeout:  metav1.Duration{Duration: time.Hour},
+		HTTP:     &v1alpha1.HTTPSensor{URL: "https://example.com/ready", StatusCodes: []int{200, 204}, BodyMatch: "ready"},
+	}
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_sensor",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:   "wait",
+					Sensor: sensor,
+				},
+				{
+					Name:     "load",
+					Command:  []string{"echo", "Hello"},
+					Image:    "busybox",
+					RunAfter: []string{"wait"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	runID, err := dm.CreateDAGRun(ctx, "sensor-run", &v1alpha1.DagRunSpec{DagName: "test_dag_sensor"}, nil, nil)
+	require.NoError(t, err)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_sensor", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	wait, _, _, err := dm.GetTaskForRun(ctx, runID, tasks[0].Id, 0)
+	require.NoError(t, err)
+	require.Equal(t, sensor, wait.Sensor)
+
+	taskRunID, err := dm.MarkTaskAsStarted(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	next, err := dm.MarkSuccessAndGetNextTasks(ctx, taskRunID)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+
+	load, _, _, err := dm.GetTaskForRun(ctx, runID, next[0].Id, 0)
+	require.NoError(t, err)
+	require.Nil(t, load.Sensor)
+}
+
+func testDAGManager_TriggerEvents(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+
+	name, created, err := dm.RecordTriggerEvent(ctx, "default", "deploy", "evt-1", "dagrun-1")
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "dagrun-1", name)
+
+	// a redelivered event returns the run it started
+	name, created, err = dm.RecordTriggerEvent(ctx, "default", "deploy", "evt-1", "dagrun-2")
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "dagrun-1", name)
+
+	// event ids are only unique within a trigger
+	_, created, err = dm.RecordTriggerEvent(ctx, "default", "release", "evt-1", "dagrun-3")
+	require.NoError(t, err)
+	require.True(t, created)
+
+	_, created, err = dm.RecordTriggerEvent(ctx, "other", "deploy", "evt-1", "dagrun-4")
+	require.NoError(t, err)
+	require.True(t, created)
+
+	name, found, err := dm.GetTriggerEvent(ctx, "default", "deploy", "evt-1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "dagrun-1", name)
+
+	_, found, err = dm.GetTriggerEvent(ctx, "default", "deploy", "evt-2")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func testDAGManager_DagCompletionTriggers(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	task := []v1alpha1.TaskSpec{
+		{
+			Name:    "run",
+			Command: []string{"echo"},
+			Image:   "alpine:latest",
+		},
+	}
+
+	upstream := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_upstream"},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{{Name: "date", DefaultValue: "2024-01-01"}},
+			Task:       task,
+		},
+	}
+
+	onSuccess := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_downstream"},
+		Spec: v1alpha1.DAGSpec{
+			Parameters: []v1alpha1.DagParameterSpec{
+				{Name: "date", DefaultValue: "today"},
+				{Name: "env", DefaultValue: "dev"},
+			},
+			Task: task,
+			Triggers: &v1alpha1.DagTriggers{
+				OnDagCompletion: []v1alpha1.DagCompletionTrigger{{
+					DagName: "test_dag_upstream",
+					Parameters: []v1alpha1.DagRefParameter{
+						{Name: "date", FromParameter: "date"},
+						{Name: "env", Value: "prod"},
+					},
+				}},
+			},
+		},
+	}
+
+	onFailure := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_downstream_failure"},
+		Spec: v1alpha1.DAGSpec{
+			Task: task,
+			Triggers: &v1alpha1.DagTriggers{
+				OnDagCompletion: []v1alpha1.DagCompletionTrigger{{
+					DagName:   "test_dag_upstream",
+					Namespace: "default",
+					Outcome:   v1alpha1.DagCompletionOutcomeFailed,
+				}},
+			},
+		},
+	}
+
+	suspended := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{Name: "test_dag_downstream_suspended"},
+		Spec: v1alpha1.DAGSpec{
+			Task:      task,
+			Suspended: true,
+			Triggers: &v1alpha1.DagTriggers{
+				OnDagCompletion: []v1alpha1.DagCompletionTrigger{{
+					DagName: "test_dag_upstream",
+					Outcome: v1alpha1.DagCompletionOutcomeAny,
+				}},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, upstream, "default"))
+	require.NoError(t, dm.InsertDAG(ctx, onSuccess, "default"))
+	require.NoError(t, dm.InsertDAG(ctx, onFailure, "reports"))
+	require.NoError(t, dm.InsertDAG(ctx, suspended, "default"))
+
+	// the upstream DAG waiting for its own downstream DAG would start itself
+	loop := upstream.DeepCopy()
+	loop.Spec.Triggers = &v1alpha1.DagTriggers{
+		OnDagCompletion: []v1alpha1.DagCompletionTrigger{{DagName: "test_dag_downstream"}},
+	}
+	require.ErrorContains(t, dm.InsertDAG(ctx, loop, "default"), "onDagCompletion triggers form a loop")
+
+	// runs finished by other tests start nothing here
+	_, err := dm.ClaimFinishedDagRuns(ctx)
+	require.NoError(t, err)
+
+	runID, err := dm.CreateDAGRun(ctx, "upstream-run", &v1alpha1.DagRunSpec{DagName: "test_dag_upstream"}, map[string]v1alpha1.ParameterSpec{
+		"date": {Name: "date", Value: "2024-06-01"},
+	}, nil)
+	require.NoError(t, err)
+
+	// a running run starts nothing yet
+	downstream, err := dm.ClaimFinishedDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, downstream)
+
+	tasks, err := dm.GetStartingTasks(ctx, "test_dag_upstream", runID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+	require.NoError(t, err)
+
+	_, err = dm.MarkSuccessAndGetNextTasks(ctx, taskRunID)
+	require.NoError(t, err)
+
+	downstream, err = dm.ClaimFinishedDagRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, downstream, 1)
+	require.NotZero(t, downstream[0].TriggerId)
+	require.Equal(t, []db.DownstreamDagRun{{
+		DagName:   "test_dag_downstream",
+		Namespace: "default",
+		Parameters: []v1alpha1.ParameterSpec{
+			{Name: "date", Value: "2024-06-01"},
+			{Name: "env", Value: "prod"},
+		},
+		UpstreamRunId:     runID,
+		UpstreamRunName:   "upstream-run",
+		UpstreamNamespace: "default",
+		UpstreamStatus:    "success",
+		TriggerId:         downstream[0].TriggerId,
+	}}, downstream)
+
+	// the finished run is leased while its downstream runs are created
+	downstream, err = dm.ClaimFinishedDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, downstream)
+
+	// each finished run only starts its downstream runs once
+	require.NoError(t, dm.MarkDagRunCompletionHandled(ctx, runID))
+	downstream, err = dm.ClaimFinishedDagRuns(ctx)
+	require.NoError(t, err)
+	require.Empty(t, downstream)
+
+	// outcomes recorded directly are picked up too, a timed out run counts as failed
+	runID, err = dm.CreateDAGRun(ctx, "upstream-run-timed-out", &v1alpha1.DagRunSpec{DagName: "test_dag_upstream"}, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, dm.MarkDAGRunOutcome(ctx, runID, "timed_out"))
+
+	downstream, err = dm.ClaimFinishedDagRuns(ctx)
+	require.NoError(t, err)
+	require.Len(t, downstream, 1)
+	require.Equal(t, []db.DownstreamDagRun{{
+		DagName:           "test_dag_downstream_failure",
+		Namespace:         "reports",
+		Parameters:        []v1alpha1.ParameterSpec{},
+		UpstreamRunId:     runID,
+		UpstreamRunName:   "upstream-run-timed-out",
+		UpstreamNamespace: "default",
+		UpstreamStatus:    "timed_out",
+		TriggerId:         downstream[0].TriggerId,
+	}}, downstream)
+
+	_, err = dm.DeleteDAG(ctx, "test_dag_downstream", "default")
+	require.NoError(t, err)
+
+	// without the downstream DAG there is no loop left to close
+	require.NoError(t, dm.InsertDAG(ctx, loop, "default"))
+}
+
+func testDAGManager_DeferSensor(t *testing.T, dm db.DBDAGManager) {
+	ctx := context.Background()
+	dag := &v1alpha1.DAG{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_dag_defer_sensor",
+		},
+		Spec: v1alpha1.DAGSpec{
+			Task: []v1alpha1.TaskSpec{
+				{
+					Name:    "wait",
+					Command: []string{"echo"},
+					Image:   "alpine:latest",
+				},
+			},
+		},
+	}
+
+	require.NoError(t, dm.InsertDAG(ctx, dag, "default"))
+
+	claimSensor := func(name string) int {
+		runID, err := dm.CreateDAGRun(ctx, name, &v1alpha1.DagRunSpec{DagName: "test_dag_defer_sensor"}, map[string]v1alpha1.ParameterSpec{}, nil)
+		require.NoError(t, err)
+
+		tasks, err := dm.GetStartingTasks(ctx, "test_dag_defer_sensor", runID)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+
+		taskRunID, err := dm.AddPendingTaskRun(ctx, runID, tasks[0].Id)
+		require.NoError(t, err)
+
+		_, err = dm.ClaimTaskByID(ctx, taskRunID, "sensor-worker", time.Minute)
+		require.NoError(t, err)
+
+		return taskRunID
+	}
+
+	taskRunID := claimSensor("defer-sensor-run-1")
+
+	// a worker that no longer holds the claim can't defer the sensor
+	_, err := dm.DeferSensor(ctx, taskRunID, "other-worker", time.Hour, time.Hour)
+	require.ErrorIs(t, err, db.ErrTaskRunNotClaimed)
+
+	deferred, err := dm.DeferSensor(ctx, taskRunID, "sensor-worker", time.Hour, time.Hour)
+	require.NoError(t, err)
+	require.True(t, deferred)
+
+	// the claim is released, but the task run is only claimed again once the interval has passed
+	_, err = dm.DeferSensor(ctx, taskRunID, "sensor-worker", time.Hour, time.Hour)
+	require.ErrorIs(t, err, db.ErrTaskRunNotClaimed)
+
+	claims, err := dm.ClaimTasks(ctx, 10, "next-worker", time.Minute)
+	require.NoError(t, err)
+	for _, claim := range claims {
+		require.NotEqual(t, taskRunID, claim.TaskRunID)
+	}
+
+	// once the timeout has passed the sensor is not deferred and stays claimed so the worker can fail it
+	taskRunID = claimSensor("defer-sensor-run-2")
+	deferred, err = dm.DeferSensor(ctx, taskRunID, "sensor-worker", time.Hour, 0)
+	require.NoError(t, err)
+	require.False(t, deferred)
+
+	require.NoError(t, dm.RenewLease(ctx, taskRunID, "sensor-worker", time.Minute))
+}
@@ -0,0 +1,5 @@
+ALTER TABLE Tasks
+  ADD COLUMN IF NOT EXISTS outputs TEXT[];
+
+ALTER TABLE Task_Runs
+  ADD COLUMN IF NOT EXISTS outputs JSONB;
@@ -0,0 +1,5 @@
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS whenExpr TEXT;
+
+ALTER TABLE DAG_Runs
+  ADD COLUMN IF NOT EXISTS skippedCount INTEGER NOT NULL DEFAULT 0;
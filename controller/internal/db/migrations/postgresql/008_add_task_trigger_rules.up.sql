@@ -0,0 +1,2 @@
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS triggerRule VARCHAR(32);
@@ -0,0 +1,8 @@
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS mapParameter TEXT,
+  ADD COLUMN IF NOT EXISTS mapOutput TEXT,
+  ADD COLUMN IF NOT EXISTS mapMaxParallelism INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE Task_Runs
+  ADD COLUMN IF NOT EXISTS map_index INTEGER,
+  ADD COLUMN IF NOT EXISTS map_item TEXT;
@@ -0,0 +1,9 @@
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS dagRefName TEXT,
+  ADD COLUMN IF NOT EXISTS dagRefParameters JSONB;
+
+ALTER TABLE DAG_Runs
+  ADD COLUMN IF NOT EXISTS parent_run_id INTEGER,
+  ADD COLUMN IF NOT EXISTS parent_task_run_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_dag_runs_parent_task_run_id ON DAG_Runs(parent_task_run_id);
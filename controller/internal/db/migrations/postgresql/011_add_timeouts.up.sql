@@ -0,0 +1,14 @@
+ALTER TABLE DAGs
+  ADD COLUMN IF NOT EXISTS runTimeoutSeconds INTEGER;
+
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS timeoutSeconds INTEGER;
+
+ALTER TABLE DAG_Runs
+  ADD COLUMN IF NOT EXISTS timeout_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE Task_Runs
+  ADD COLUMN IF NOT EXISTS timeout_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_dag_runs_timeout_at ON DAG_Runs (timeout_at);
+CREATE INDEX IF NOT EXISTS idx_task_runs_timeout_at ON Task_Runs (timeout_at);
@@ -0,0 +1,5 @@
+ALTER TABLE Tasks
+  ADD COLUMN IF NOT EXISTS backoffInitialDelaySeconds INTEGER,
+  ADD COLUMN IF NOT EXISTS backoffMaxDelaySeconds INTEGER,
+  ADD COLUMN IF NOT EXISTS backoffMultiplier DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS backoffJitter DOUBLE PRECISION;
@@ -0,0 +1,5 @@
+ALTER TABLE DAGs
+  ADD COLUMN IF NOT EXISTS concurrencyPolicy TEXT,
+  ADD COLUMN IF NOT EXISTS maxActiveRuns INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_dag_runs_dag_id_status ON DAG_Runs (dag_id, status);
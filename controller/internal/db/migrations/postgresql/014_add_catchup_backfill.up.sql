@@ -0,0 +1,20 @@
+ALTER TABLE DAGs
+  ADD COLUMN IF NOT EXISTS catchup BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS maxCatchupRuns INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE DAG_Runs
+  ADD COLUMN IF NOT EXISTS scheduled_time TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS DAG_Backfills (
+    backfill_id SERIAL PRIMARY KEY,
+    dag_name VARCHAR(255) NOT NULL,
+    namespace VARCHAR(63) NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    -- the last schedule time a run was started for, runs carry on from after it
+    cursor_time TIMESTAMP NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE(dag_name, namespace, start_time, end_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dag_backfills_completed ON DAG_Backfills(completed);
@@ -0,0 +1,4 @@
+ALTER TABLE DAGs
+  ADD COLUMN IF NOT EXISTS timezone TEXT,
+  ADD COLUMN IF NOT EXISTS startTime TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS endTime TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE DAG_Parameters
+  ADD COLUMN IF NOT EXISTS parameterSchema TEXT;
@@ -0,0 +1,7 @@
+ALTER TABLE DAG_Parameters
+  ADD COLUMN IF NOT EXISTS valueKey TEXT,
+  ADD COLUMN IF NOT EXISTS isConfigMap BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE DAG_Run_Parameters
+  ADD COLUMN IF NOT EXISTS valueKey TEXT,
+  ADD COLUMN IF NOT EXISTS isConfigMap BOOLEAN NOT NULL DEFAULT FALSE;
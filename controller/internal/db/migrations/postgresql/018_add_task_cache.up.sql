@@ -0,0 +1,20 @@
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS cacheTTLSeconds INTEGER;
+
+ALTER TABLE Task_Runs
+  ADD COLUMN IF NOT EXISTS cache_key VARCHAR(64);
+
+-- results of successful task runs, reused by later runs of the same task with the same inputs
+CREATE TABLE IF NOT EXISTS Task_Cache (
+    cache_id SERIAL PRIMARY KEY,
+    namespace VARCHAR(63) NOT NULL,
+    dag_name VARCHAR(255) NOT NULL,
+    task_name VARCHAR(255) NOT NULL,
+    cache_key VARCHAR(64) NOT NULL,
+    outputs JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE(namespace, dag_name, task_name, cache_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_cache_expires_at ON Task_Cache (expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE Tasks
+  ADD COLUMN IF NOT EXISTS artifacts JSONB;
@@ -0,0 +1,13 @@
+ALTER TABLE DAGs
+  ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE DAG_Tasks
+  ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE DAG_Runs
+  ADD COLUMN IF NOT EXISTS priority INTEGER;
+
+-- priority of the run plus that of its task, fixed when the task run is queued
+ALTER TABLE Task_Runs
+  ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS queued_at TIMESTAMP WITH TIME ZONE;
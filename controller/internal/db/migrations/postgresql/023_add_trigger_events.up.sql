@@ -0,0 +1,9 @@
+-- events with an id that triggers have started runs for, so a redelivered event does not start another
+CREATE TABLE IF NOT EXISTS Trigger_Events (
+    namespace VARCHAR(63) NOT NULL,
+    trigger_name VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    dag_run_name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (namespace, trigger_name, event_id)
+);
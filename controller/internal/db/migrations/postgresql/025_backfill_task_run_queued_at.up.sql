@@ -0,0 +1,4 @@
+-- task runs queued before priorities existed have no queued_at, so they never aged ahead of newer ones
+UPDATE Task_Runs
+  SET queued_at = COALESCE(claimed_at, now())
+  WHERE queued_at IS NULL AND status IN ('pending', 'pending_dag');
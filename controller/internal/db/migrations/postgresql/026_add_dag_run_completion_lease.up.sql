@@ -0,0 +1,2 @@
+-- held by the scheduler while it creates the runs a finished run starts, completion_handled is only set once they exist
+ALTER TABLE DAG_Runs ADD COLUMN IF NOT EXISTS completion_lease_expires_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+ALTER TABLE Tasks ADD COLUMN outputs TEXT;
+ALTER TABLE Task_Runs ADD COLUMN outputs TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE DAG_Tasks ADD COLUMN whenExpr TEXT;
+ALTER TABLE DAG_Runs ADD COLUMN skippedCount INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE DAG_Tasks ADD COLUMN triggerRule VARCHAR(32);
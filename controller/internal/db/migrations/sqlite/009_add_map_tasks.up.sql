@@ -0,0 +1,5 @@
+ALTER TABLE DAG_Tasks ADD COLUMN mapParameter TEXT;
+ALTER TABLE DAG_Tasks ADD COLUMN mapOutput TEXT;
+ALTER TABLE DAG_Tasks ADD COLUMN mapMaxParallelism INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Task_Runs ADD COLUMN map_index INTEGER;
+ALTER TABLE Task_Runs ADD COLUMN map_item TEXT;
@@ -0,0 +1,5 @@
+ALTER TABLE DAG_Tasks ADD COLUMN dagRefName TEXT;
+ALTER TABLE DAG_Tasks ADD COLUMN dagRefParameters TEXT;
+ALTER TABLE DAG_Runs ADD COLUMN parent_run_id INTEGER;
+ALTER TABLE DAG_Runs ADD COLUMN parent_task_run_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_dag_runs_parent_task_run_id ON DAG_Runs(parent_task_run_id);
@@ -0,0 +1,7 @@
+ALTER TABLE DAGs ADD COLUMN runTimeoutSeconds INTEGER;
+ALTER TABLE DAG_Tasks ADD COLUMN timeoutSeconds INTEGER;
+ALTER TABLE DAG_Runs ADD COLUMN timeout_at DATETIME;
+ALTER TABLE Task_Runs ADD COLUMN timeout_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_dag_runs_timeout_at ON DAG_Runs (timeout_at);
+CREATE INDEX IF NOT EXISTS idx_task_runs_timeout_at ON Task_Runs (timeout_at);
@@ -0,0 +1,4 @@
+ALTER TABLE Tasks ADD COLUMN backoffInitialDelaySeconds INTEGER;
+ALTER TABLE Tasks ADD COLUMN backoffMaxDelaySeconds INTEGER;
+ALTER TABLE Tasks ADD COLUMN backoffMultiplier REAL;
+ALTER TABLE Tasks ADD COLUMN backoffJitter REAL;
@@ -0,0 +1,4 @@
+ALTER TABLE DAGs ADD COLUMN concurrencyPolicy TEXT;
+ALTER TABLE DAGs ADD COLUMN maxActiveRuns INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_dag_runs_dag_id_status ON DAG_Runs (dag_id, status);
@@ -0,0 +1,3 @@
+ALTER TABLE DAGs ADD COLUMN timezone TEXT;
+ALTER TABLE DAGs ADD COLUMN startTime TIMESTAMP;
+ALTER TABLE DAGs ADD COLUMN endTime TIMESTAMP;
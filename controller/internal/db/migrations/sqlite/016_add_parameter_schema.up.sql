@@ -0,0 +1 @@
+ALTER TABLE DAG_Parameters ADD COLUMN parameterSchema TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE DAG_Parameters ADD COLUMN valueKey TEXT;
+ALTER TABLE DAG_Parameters ADD COLUMN isConfigMap BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE DAG_Run_Parameters ADD COLUMN valueKey TEXT;
+ALTER TABLE DAG_Run_Parameters ADD COLUMN isConfigMap BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE Tasks ADD COLUMN artifacts TEXT;
@@ -0,0 +1,7 @@
+ALTER TABLE DAGs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE DAG_Tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE DAG_Runs ADD COLUMN priority INTEGER;
+
+-- priority of the run plus that of its task, fixed when the task run is queued
+ALTER TABLE Task_Runs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Task_Runs ADD COLUMN queued_at DATETIME;
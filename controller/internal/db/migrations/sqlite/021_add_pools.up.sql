@@ -0,0 +1,34 @@
+ALTER TABLE DAG_Tasks ADD COLUMN pool VARCHAR(255);
+ALTER TABLE DAG_Tasks ADD COLUMN poolSlots INTEGER NOT NULL DEFAULT 1;
+
+-- slots shared by the tasks of every DAG in the namespace that run in the pool
+CREATE TABLE IF NOT EXISTS Pools (
+    pool_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    namespace VARCHAR(63) NOT NULL,
+    slots INTEGER NOT NULL,
+    UNIQUE(name, namespace)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dag_tasks_pool ON DAG_Tasks (pool);
+
+-- a task run holds the slots of its task while it is claimed or its pod has not finished,
+-- those still waiting to be claimed are queued
+CREATE VIEW IF NOT EXISTS Pool_Usage AS
+SELECT p.pool_id, p.name, p.namespace, p.slots,
+    COALESCE(SUM(u.occupied), 0) AS occupied_slots,
+    COALESCE(SUM(u.queued), 0) AS queued_slots
+FROM Pools p
+LEFT JOIN (
+    SELECT dt.pool, d.namespace,
+        CASE WHEN tr.status = 'running' OR (tr.claimed_by IS NOT NULL AND tr.lease_expires_at > datetime('now')) THEN dt.poolSlots ELSE 0 END AS occupied,
+        CASE WHEN tr.status = 'pending' AND (tr.claimed_by IS NULL OR tr.lease_expires_at <= datetime('now')) THEN dt.poolSlots ELSE 0 END AS queued
+    FROM Task_Runs tr
+    JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+    JOIN DAGs d ON d.dag_id = dt.dag_id
+    WHERE dt.pool IS NOT NULL
+    AND (tr.status = 'pending' OR (tr.status = 'running' AND NOT EXISTS (
+        SELECT 1 FROM Task_Pods tp WHERE tp.task_run_id = tr.task_run_id AND tp.status IN ('Succeeded', 'Failed')
+    )))
+) u ON u.pool = p.name AND u.namespace = p.namespace
+GROUP BY p.pool_id, p.name, p.namespace, p.slots;
@@ -0,0 +1,2 @@
+-- condition a sensor task waits for, checked by the worker instead of running a pod
+ALTER TABLE DAG_Tasks ADD COLUMN sensor TEXT;
@@ -0,0 +1,20 @@
+-- upstream DAGs whose finished runs start a run of a DAG version
+CREATE TABLE IF NOT EXISTS DAG_Completion_Triggers (
+    trigger_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dag_id INTEGER NOT NULL,
+    upstream_name VARCHAR(255) NOT NULL,
+    upstream_namespace VARCHAR(63) NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    parameters TEXT,
+    FOREIGN KEY (dag_id) REFERENCES DAGs(dag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dag_completion_triggers_upstream ON DAG_Completion_Triggers (upstream_name, upstream_namespace);
+
+-- set once a finished run has started the runs of the DAGs waiting for it
+ALTER TABLE DAG_Runs ADD COLUMN completion_handled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- runs that finished before triggers existed start nothing
+UPDATE DAG_Runs SET completion_handled = TRUE WHERE status NOT IN ('running', 'queued');
+
+CREATE INDEX IF NOT EXISTS idx_dag_runs_completion_handled ON DAG_Runs (completion_handled);
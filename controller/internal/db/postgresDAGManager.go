@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strings"
 	"time"
 
 	"kontroler-controller/api/v1alpha1"
 	"kontroler-controller/internal/db/migrations"
+	"kontroler-controller/pkg/conditions"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -58,7 +60,65 @@ func NewPostgresDAGManagerWithMetrics(ctx context.Context, pool *pgxpool.Pool, p
 }
 
 func (p *postgresDAGManager) InitaliseDatabase(ctx context.Context) error {
-	return p.migrations.MigrateUp(ctx)
+	if err := p.migrations.MigrateUp(ctx); err != nil {
+		return err
+	}
+
+	return p.refreshZonedNextTimes(ctx)
+}
+
+// refreshZonedNextTimes works out again the upcoming next run time of the scheduled DAGs with a time zone,
+// so times stored by an earlier release, or under older time zone rules, follow the zone as it is now.
+// Times that are already due are left for GetDAGsToStartAndUpdate to start
+func (p *postgresDAGManager) refreshZonedNextTimes(ctx context.Context) error {
+	now := time.Now()
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT dag_id, schedule, timezone, startTime, endTime
+			FROM DAGs
+			WHERE active = TRUE AND schedule != '' AND COALESCE(timezone, '') != '' AND nexttime > $1
+			FOR UPDATE;`, now.UTC())
+		if err != nil {
+			return wrapError("query_zoned_dags", err)
+		}
+
+		type zonedDag struct {
+			dagId    int
+			schedule string
+			timezone string
+			window   scheduleWindow
+		}
+
+		dags := []zonedDag{}
+		for rows.Next() {
+			var dag zonedDag
+			if err := rows.Scan(&dag.dagId, &dag.schedule, &dag.timezone, &dag.window.start, &dag.window.end); err != nil {
+				rows.Close()
+				return err
+			}
+			dags = append(dags, dag)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, dag := range dags {
+			sched, err := parseSchedule(p.parser, dag.schedule, dag.timezone)
+			if err != nil {
+				// the scheduler reports the schedule once the DAG is due
+				log.Log.Error(err, "failed to parse schedule of dag", "dagId", dag.dagId, "timezone", dag.timezone)
+				continue
+			}
+
+			if _, err := tx.Exec(ctx, `UPDATE DAGs SET nexttime = $1 WHERE dag_id = $2;`, nextTimeColumn(dag.window.next(sched, now)), dag.dagId); err != nil {
+				return wrapError("update_nexttime", err)
+			}
+		}
+
+		return nil
+	})
 }
 
 // Add new transaction helper
@@ -113,11 +173,20 @@ func (p *postgresDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, n
 
 		hashValue := fmt.Sprintf("%x", hashBytes)
 		if hash == hashValue {
+			backfillAdded, err := p.addBackfill(ctx, tx, dag.Name, namespace, dag.Spec.Backfill)
+			if err != nil {
+				return wrapError("add_backfill", err)
+			}
+
 			// check if suspended
 			if suspended != dag.Spec.Suspended {
 				return p.setSuspended(ctx, tx, dag.Name, namespace, dag.Spec.Suspended)
 			}
 
+			if backfillAdded {
+				return nil
+			}
+
 			return fmt.Errorf("applying the same dag")
 		}
 
@@ -125,6 +194,12 @@ func (p *postgresDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, n
 			version++
 		}
 
+		// the graph is read in the transaction that stores the DAG, so DAGs applied together
+		// cannot each pass on a graph missing the other
+		if err := p.checkDagLoops(ctx, tx, dag, namespace); err != nil {
+			return err
+		}
+
 		// DAG does not exist, insert it
 		if err := p.insertDAG(ctx, tx, dag, version, namespace, hashValue); err != nil {
 			return err
@@ -135,10 +210,57 @@ func (p *postgresDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, n
 			return err
 		}
 
+		if _, err := p.addBackfill(ctx, tx, dag.Name, namespace, dag.Spec.Backfill); err != nil {
+			return wrapError("add_backfill", err)
+		}
+
 		return nil
 	})
 }
 
+// checkDagLoops ensures storing the DAG does not let it run itself through the dagRef tasks or
+// onDagCompletion triggers of stored DAGs. The lock is held until the transaction ends, so only one DAG at a time is checked against the stored graph
+func (p *postgresDAGManager) checkDagLoops(ctx context.Context, tx pgx.Tx, dag *v1alpha1.DAG, namespace string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, dagGraphLockKey); err != nil {
+		return wrapError("lock_dag_graph", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT d.name, d.namespace, dt.dagRefName
+		FROM DAG_Tasks dt
+		JOIN DAGs d ON d.dag_id = dt.dag_id
+		WHERE d.active = TRUE AND dt.dagRefName IS NOT NULL;`)
+	if err != nil {
+		return wrapError("query_dag_refs", err)
+	}
+	defer rows.Close()
+
+	children := map[types.NamespacedName][]types.NamespacedName{}
+	for rows.Next() {
+		var parent types.NamespacedName
+		var child string
+		if err := rows.Scan(&parent.Name, &parent.Namespace, &child); err != nil {
+			return err
+		}
+		children[parent] = append(children[parent], types.NamespacedName{Namespace: parent.Namespace, Name: child})
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := dag.CheckDagRefLoops(namespace, children); err != nil {
+		return err
+	}
+
+	upstreams, err := p.getDagCompletionTriggers(ctx, tx)
+	if err != nil {
+		return wrapError("query_dag_completion_triggers", err)
+	}
+
+	return dag.CheckCompletionTriggerLoops(namespace, upstreams)
+}
+
 // insertDAG inserts a new DAG object into the database.
 func (p *postgresDAGManager) insertDAG(ctx context.Context, tx pgx.Tx, dag *v1alpha1.DAG, version int, namespace string, hash string) error {
 
@@ -147,21 +269,23 @@ func (p *postgresDAGManager) insertDAG(ctx context.Context, tx pgx.Tx, dag *v1al
 
 	// Could be an event driven only score
 	if dag.Spec.Schedule != "" {
-		sched, err := p.parser.Parse(dag.Spec.Schedule)
+		sched, err := parseSchedule(p.parser, dag.Spec.Schedule, dag.Spec.Timezone)
 		if err != nil {
 			return fmt.Errorf("failed when parsing: %w", err)
 		}
 
-		// Get the next occurrence of the scheduled time
-		t := sched.Next(time.Now())
-		nextTime = &t
+		// Get the next occurrence of the scheduled time within the schedule window
+		window := newScheduleWindow(dag.Spec.StartTime, dag.Spec.EndTime)
+		nextTime = nextTimeColumn(window.next(sched, time.Now()))
 	}
 
 	var dagID int
 	if err := tx.QueryRow(ctx, QueryInsertDAG,
 		dag.Name, version, hash, dag.Spec.Schedule, namespace,
 		nextTime, len(dag.Spec.Task), dag.Spec.Webhook.URL,
-		dag.Spec.Webhook.VerifySSL, dag.Spec.Workspace.Enabled, dag.Spec.Suspended).Scan(&dagID); err != nil {
+		dag.Spec.Webhook.VerifySSL, dag.Spec.Workspace.Enabled, dag.Spec.Suspended, timeoutSeconds(dag.Spec.RunTimeout),
+		string(dag.Spec.ConcurrencyPolicy), dag.Spec.MaxActiveRuns, dag.Spec.Catchup, dag.Spec.MaxCatchupRuns,
+		dag.Spec.Timezone, utcTime(dag.Spec.StartTime), utcTime(dag.Spec.EndTime), dag.Spec.Priority).Scan(&dagID); err != nil {
 		return fmt.Errorf("failed inserting DAG: %w", err)
 	}
 
@@ -197,9 +321,29 @@ func (p *postgresDAGManager) insertDAG(ctx context.Context, tx pgx.Tx, dag *v1al
 		}
 	}
 
+	if dag.Spec.Triggers != nil {
+		for _, trigger := range dag.Spec.Triggers.OnDagCompletion {
+			if err := p.insertCompletionTrigger(ctx, tx, dagID, &trigger, namespace); err != nil {
+				return fmt.Errorf("failed to insert onDagCompletion trigger: %w", err)
+			}
+		}
+	}
+
 	return nil
 }
 
+func (p *postgresDAGManager) insertCompletionTrigger(ctx context.Context, tx pgx.Tx, dagID int, trigger *v1alpha1.DagCompletionTrigger, namespace string) error {
+	outcome, parameters, err := completionTriggerColumns(trigger)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO DAG_Completion_Triggers (dag_id, upstream_name, upstream_namespace, outcome, parameters)
+	VALUES ($1, $2, $3, $4, $5);`, dagID, trigger.DagName, trigger.GetNamespace(namespace), outcome, parameters)
+	return err
+}
+
 func (p *postgresDAGManager) setSuspended(ctx context.Context, tx pgx.Tx, dagName, namespace string, suspended bool) error {
 	_, err := tx.Exec(ctx, `
 		UPDATE DAGs
@@ -213,14 +357,16 @@ func (p *postgresDAGManager) setSuspended(ctx context.Context, tx pgx.Tx, dagNam
 }
 
 func (p *postgresDAGManager) insertParameter(ctx context.Context, tx pgx.Tx, dagID int, parameter *v1alpha1.DagParameterSpec) error {
-	value := parameter.DefaultFromSecret
-	isSecret := parameter.DefaultValue == ""
-	if !isSecret {
-		value = parameter.DefaultValue
+	// required parameters have no default at all
+	value := dagParameter(parameter)
+
+	schema, err := json.Marshal(parameter.ParameterSchema)
+	if err != nil {
+		return err
 	}
 
 	// Map the task to the DAG
-	_, err := tx.Exec(ctx, QueryInsertParameter, dagID, parameter.Name, isSecret, value)
+	_, err = tx.Exec(ctx, QueryInsertParameter, dagID, parameter.Name, value.IsSecret, value.IsConfigMap, value.Key, value.Value, string(schema))
 	return err
 }
 
@@ -261,19 +407,43 @@ func (p *postgresDAGManager) insertTask(ctx context.Context, tx pgx.Tx, dagID in
 		}
 
 	} else {
+		hash, err := hashInlineTask(task)
+		if err != nil {
+			return err
+		}
+
+		artifactsJson, err := artifactsColumn(task.Artifacts)
+		if err != nil {
+			return err
+		}
+
+		backoff := backoffColumns(task.Backoff)
 		if err := tx.QueryRow(ctx, `
-		INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, TRUE, $12, $13) 
+		INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version, hash, outputs, backoffInitialDelaySeconds, backoffMaxDelaySeconds, backoffMultiplier, backoffJitter, artifacts) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, TRUE, $12, $13, $14, $15, $16, $17, $18, $19, $20) 
 		RETURNING task_id;`,
 			uuid.NewString(), task.Command, task.Args, task.Image, task.Parameters, task.Backoff.Limit,
-			task.Conditional.Enabled, task.Conditional.RetryCodes, jsonValue, task.Script, task.ScriptInjectorImage, namespace, version).Scan(&taskId); err != nil {
+			task.Conditional.Enabled, task.Conditional.RetryCodes, jsonValue, task.Script, task.ScriptInjectorImage, namespace, version, hash, task.Outputs,
+			backoff.initialDelaySeconds, backoff.maxDelaySeconds, backoff.multiplier, backoff.jitter, artifactsJson).Scan(&taskId); err != nil {
 			return fmt.Errorf("failed to insert line task: %w", err)
 		}
 	}
 
+	dagRefName, dagRefParameters, err := dagRefColumns(task.DagRef)
+	if err != nil {
+		return err
+	}
+
+	sensor, err := sensorColumn(task.Sensor)
+	if err != nil {
+		return err
+	}
+
+	mapParameter, mapOutput, mapMaxParallelism := mapColumns(task.Map)
+	pool, poolSlots := poolColumns(task)
 	if _, err := tx.Exec(ctx, `
-		INSERT INTO DAG_Tasks (dag_id, task_id, name, version)
-		VALUES ($1, $2, $3, $4)`, dagID, taskId, task.Name, version); err != nil {
+		INSERT INTO DAG_Tasks (dag_id, task_id, name, version, whenExpr, triggerRule, mapParameter, mapOutput, mapMaxParallelism, dagRefName, dagRefParameters, timeoutSeconds, cacheTTLSeconds, priority, pool, poolSlots, sensor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`, dagID, taskId, task.Name, version, task.When, task.TriggerRule, mapParameter, mapOutput, mapMaxParallelism, dagRefName, dagRefParameters, timeoutSeconds(task.Timeout), cacheTTLSeconds(task.Cache), task.Priority, pool, poolSlots, sensor); err != nil {
 		return fmt.Errorf("failed to insert dag task: %w", err)
 	}
 
@@ -361,47 +531,282 @@ func (p *postgresDAGManager) CreateDAGRun(ctx context.Context, name string, dag
 
 	var dagRunID int
 	if err := p.withTx(ctx, func(tx pgx.Tx) error {
-		// Map the task to the DAG
+		var err error
+		dagRunID, err = p.insertDAGRun(ctx, tx, dagId, name, "running", parameters, pvcName, utcTime(dag.ScheduledTime), dag.Priority)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	return dagRunID, nil
+}
+
+// insertDAGRun adds a run along with its parameters, the runTimeout of a queued run only starts once it is running
+func (p *postgresDAGManager) insertDAGRun(ctx context.Context, tx pgx.Tx, dagId int, name, status string, parameters map[string]v1alpha1.ParameterSpec, pvcName *string, scheduledTime *time.Time, priority *int32) (int, error) {
+	// Map the task to the DAG
+	var dagRunID int
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO DAG_Runs (dag_id, name, status, successfulCount, failedCount, suspendedCount, run_time, pvcName, timeout_at, scheduled_time, priority) 
+		VALUES ($1, $2, $4::text, 0, 0, 0, NOW(), $3, CASE WHEN $4::text = 'running' THEN (SELECT NOW() + runTimeoutSeconds * INTERVAL '1 second' FROM DAGs WHERE dag_id = $1) END, $5, $6) 
+		RETURNING run_id`, dagId, name, pvcName, status, scheduledTime, priority).Scan(&dagRunID); err != nil {
+		return 0, err
+	}
+
+	// Batch insert all parameters
+	if len(parameters) > 0 {
+		rows := make([][]interface{}, 0, len(parameters))
+		for _, param := range parameters {
+			value := runParameter(param)
+			rows = append(rows, []interface{}{
+				dagRunID,
+				param.Name,
+				value.Value,
+				value.IsSecret,
+				value.IsConfigMap,
+				value.Key,
+			})
+		}
+
+		_, err := tx.CopyFrom(
+			ctx,
+			pgx.Identifier{"dag_run_parameters"},
+			[]string{"run_id", "name", "value", "issecret", "isconfigmap", "valuekey"},
+			pgx.CopyFromRows(rows),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to batch insert parameters: %w", err)
+		}
+	}
+
+	return dagRunID, nil
+}
+
+func (p *postgresDAGManager) StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (DagRunAdmission, error) {
+	dagId, err := p.dagNameToDagId(ctx, dag.DagName)
+	if err != nil {
+		return DagRunAdmission{}, err
+	}
+
+	var existing DagRunAdmission
+	if err := p.pool.QueryRow(ctx, `SELECT run_id, status FROM DAG_Runs WHERE name = $1`, name).Scan(&existing.RunId, &existing.Status); err == nil {
+		return existing, nil
+	} else if err != pgx.ErrNoRows {
+		return DagRunAdmission{}, err
+	}
+
+	admission := DagRunAdmission{}
+	err = p.withTx(ctx, func(tx pgx.Tx) error {
+		// locking the DAG stops two runs from taking the last slot at the same time
+		var policy string
+		var maxActiveRuns int
 		if err := tx.QueryRow(ctx, `
-			INSERT INTO DAG_Runs (dag_id, name, status, successfulCount, failedCount, suspendedCount, run_time, pvcName) 
-			VALUES ($1, $2, 'running', 0, 0, 0, NOW(), $3) 
-			RETURNING run_id`, dagId, name, pvcName).Scan(&dagRunID); err != nil {
+			SELECT COALESCE(concurrencyPolicy, ''), maxActiveRuns
+			FROM DAGs
+			WHERE dag_id = $1
+			FOR UPDATE;`, dagId).Scan(&policy, &maxActiveRuns); err != nil {
 			return err
 		}
 
-		// Batch insert all parameters
-		if len(parameters) > 0 {
-			rows := make([][]interface{}, 0, len(parameters))
-			for _, param := range parameters {
-				value := param.Value
-				if param.FromSecret != "" {
-					value = param.FromSecret
+		status := "running"
+		if limit := activeRunLimit(policy, maxActiveRuns); limit > 0 {
+			// runs of earlier versions of the DAG count towards the limit too
+			var active, queued int
+			if err := tx.QueryRow(ctx, `
+				SELECT
+					COUNT(*) FILTER (WHERE `+activeRunCondition+`),
+					COUNT(*) FILTER (WHERE r.status = 'queued')
+				FROM DAG_Runs r
+				JOIN DAGs d ON d.dag_id = r.dag_id
+				WHERE d.name = $1;`, dag.DagName).Scan(&active, &queued); err != nil {
+				return err
+			}
+
+			switch v1alpha1.ConcurrencyPolicy(policy) {
+			case v1alpha1.ConcurrencyPolicyQueue:
+				// runs already waiting go first
+				if active >= limit || queued > 0 {
+					status = "queued"
+				}
+			case v1alpha1.ConcurrencyPolicyForbid:
+				if active >= limit {
+					admission.Status = "skipped"
+					return nil
 				}
-				rows = append(rows, []interface{}{
-					dagRunID,
-					param.Name,
-					value,
-					param.FromSecret != "",
-				})
-			}
-
-			_, err := tx.CopyFrom(
-				ctx,
-				pgx.Identifier{"dag_run_parameters"},
-				[]string{"run_id", "name", "value", "issecret"},
-				pgx.CopyFromRows(rows),
+			case v1alpha1.ConcurrencyPolicyReplace:
+				if active >= limit {
+					replaced, err := p.suspendOldestDagRuns(ctx, tx, dag.DagName, active-limit+1)
+					if err != nil {
+						return err
+					}
+					admission.Replaced = replaced
+				}
+			}
+		}
+
+		runId, err := p.insertDAGRun(ctx, tx, dagId, name, status, parameters, pvcName, utcTime(dag.ScheduledTime), dag.Priority)
+		if err != nil {
+			return err
+		}
+
+		admission.RunId = runId
+		admission.Status = status
+		return nil
+	})
+
+	if err != nil {
+		return DagRunAdmission{}, err
+	}
+
+	return admission, nil
+}
+
+// suspendOldestDagRuns marks the oldest active runs of a DAG as suspended, along with their active task runs.
+// Failed runs keep their status and only have their task runs suspended
+func (p *postgresDAGManager) suspendOldestDagRuns(ctx context.Context, tx pgx.Tx, dagName string, count int) ([]StoppedDagRun, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE DAG_Runs
+		SET status = CASE WHEN status = 'running' THEN 'suspended' ELSE status END
+		WHERE run_id IN (
+			SELECT r.run_id
+			FROM DAG_Runs r
+			JOIN DAGs d ON d.dag_id = r.dag_id
+			WHERE d.name = $1 AND `+activeRunCondition+`
+			ORDER BY r.run_id
+			LIMIT $2
+			FOR UPDATE OF r
+		)
+		RETURNING run_id, pvcName;`, dagName, count)
+	if err != nil {
+		return nil, err
+	}
+
+	suspended := []StoppedDagRun{}
+	for rows.Next() {
+		var run StoppedDagRun
+		if err := rows.Scan(&run.RunId, &run.PVCName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		suspended = append(suspended, run)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := p.stopDagRunTasks(ctx, tx, suspended, "suspended"); err != nil {
+		return nil, err
+	}
+
+	return suspended, nil
+}
+
+func (p *postgresDAGManager) StartQueuedDAGRuns(ctx context.Context) ([]QueuedDagRun, error) {
+	started := []QueuedDagRun{}
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// locking the DAGs with queued runs, as StartDAGRun does, stops a new run and a queued one
+		// from taking the last slot at the same time
+		if _, err := tx.Exec(ctx, `
+			SELECT d.dag_id
+			FROM DAGs d
+			WHERE d.version = (SELECT MAX(version) FROM DAGs l WHERE l.name = d.name)
+			AND EXISTS (
+				SELECT 1
+				FROM DAGs v
+				JOIN DAG_Runs r ON r.dag_id = v.dag_id
+				WHERE v.name = d.name AND r.status = 'queued'
 			)
+			ORDER BY d.dag_id
+			FOR UPDATE OF d;`); err != nil {
+			return err
+		}
+
+		// the policy of the latest version of a DAG applies to the runs of every version
+		rows, err := tx.Query(ctx, `
+			SELECT d.name, d.namespace, COALESCE(d.concurrencyPolicy, ''), d.maxActiveRuns,
+				COUNT(*) FILTER (WHERE `+activeRunCondition+`),
+				COUNT(*) FILTER (WHERE r.status = 'queued')
+			FROM DAGs d
+			JOIN DAGs v ON v.name = d.name
+			JOIN DAG_Runs r ON r.dag_id = v.dag_id
+			WHERE d.version = (SELECT MAX(version) FROM DAGs l WHERE l.name = d.name)
+			GROUP BY d.dag_id, d.name, d.namespace, d.concurrencyPolicy, d.maxActiveRuns
+			HAVING COUNT(*) FILTER (WHERE r.status = 'queued') > 0;`)
+		if err != nil {
+			return err
+		}
+
+		type queuedDag struct {
+			name      string
+			namespace string
+			toStart   int
+		}
+
+		dags := []queuedDag{}
+		for rows.Next() {
+			var dag queuedDag
+			var policy string
+			var maxActiveRuns, active, queued int
+			if err := rows.Scan(&dag.name, &dag.namespace, &policy, &maxActiveRuns, &active, &queued); err != nil {
+				rows.Close()
+				return err
+			}
+
+			dag.toStart = queuedRunsToStart(activeRunLimit(policy, maxActiveRuns), active, queued)
+			if dag.toStart > 0 {
+				dags = append(dags, dag)
+			}
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, dag := range dags {
+			rows, err := tx.Query(ctx, `
+				UPDATE DAG_Runs
+				SET status = 'running', run_time = NOW(),
+					timeout_at = (SELECT NOW() + runTimeoutSeconds * INTERVAL '1 second' FROM DAGs WHERE DAGs.dag_id = DAG_Runs.dag_id)
+				WHERE run_id IN (
+					SELECT r.run_id
+					FROM DAG_Runs r
+					JOIN DAGs d ON d.dag_id = r.dag_id
+					WHERE d.name = $1 AND r.status = 'queued'
+					ORDER BY r.run_id
+					LIMIT $2
+					FOR UPDATE OF r SKIP LOCKED
+				)
+				RETURNING run_id;`, dag.name, dag.toStart)
 			if err != nil {
-				return fmt.Errorf("failed to batch insert parameters: %w", err)
+				return err
+			}
+
+			for rows.Next() {
+				run := QueuedDagRun{DagName: dag.name, Namespace: dag.namespace}
+				if err := rows.Scan(&run.RunId); err != nil {
+					rows.Close()
+					return err
+				}
+				started = append(started, run)
+			}
+			rows.Close()
+
+			if err := rows.Err(); err != nil {
+				return err
 			}
 		}
 
 		return nil
-	}); err != nil {
-		return 0, err
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	return dagRunID, nil
+	return started, nil
 }
 
 func (p *postgresDAGManager) TaskRunExists(ctx context.Context, runId, dagTaskId int) (bool, error) {
@@ -432,7 +837,9 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 		t.podTemplate, 
 		dt.dag_id, 
 		t.script,
-		dr.pvcName
+		dr.pvcName,
+		dt.whenExpr,
+		dt.mapParameter
 	FROM 
 		Tasks t
 	JOIN 
@@ -465,6 +872,7 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 	tasks := []Task{}
 	paramsForTasks := [][]string{}
 	var dagIDForParams int = -1
+	hasConditions := false
 
 	for rows.Next() {
 		var task Task
@@ -473,11 +881,18 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 		var script sql.NullString
 		var dagId int
 		var pvcName sql.NullString
+		var when sql.NullString
+		var mapParameter sql.NullString
 
-		if err := rows.Scan(&task.Id, &task.Name, &task.Image, &task.Command, &task.Args, &parameters, &podTemplateJSON, &dagId, &script, &pvcName); err != nil {
+		if err := rows.Scan(&task.Id, &task.Name, &task.Image, &task.Command, &task.Args, &parameters, &podTemplateJSON, &dagId, &script, &pvcName, &when, &mapParameter); err != nil {
 			return nil, err
 		}
 
+		// a map over an empty list is skipped just like a false condition
+		if when.String != "" || mapParameter.String != "" {
+			hasConditions = true
+		}
+
 		// remember the DAG id (same for all rows)
 		dagIDForParams = dagId
 
@@ -517,6 +932,10 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 		tasks = append(tasks, task)
 	}
 
+	if hasConditions {
+		return p.getConditionalStartingTasks(ctx, dagIDForParams, dagrun, tasks)
+	}
+
 	// If there are parameters to fetch, batch query them
 	if len(paramsForTasks) > 0 {
 		// Build unique list of parameter names
@@ -535,7 +954,7 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 		// Query all parameter values in a single call
 		incrQueryCounter()
 		rowsParams, err := p.pool.Query(ctx, `
-		SELECT name, isSecret, defaultValue
+		SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue
 		FROM DAG_Parameters
 		WHERE dag_id = $1 AND name = ANY($2)
 		`, dagIDForParams, flattened)
@@ -546,13 +965,11 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 
 		paramMap := make(map[string]Parameter)
 		for rowsParams.Next() {
-			var name string
-			var isSecret bool
-			var value string
-			if err := rowsParams.Scan(&name, &isSecret, &value); err != nil {
+			var param Parameter
+			if err := rowsParams.Scan(&param.Name, &param.IsSecret, &param.IsConfigMap, &param.Key, &param.Value); err != nil {
 				return nil, err
 			}
-			paramMap[name] = Parameter{Name: name, IsSecret: isSecret, Value: value}
+			paramMap[param.Name] = param
 		}
 
 		// Ensure all requested params found
@@ -573,72 +990,34 @@ func (p *postgresDAGManager) GetStartingTasks(ctx context.Context, dagName strin
 	return tasks, nil
 }
 
-func (p *postgresDAGManager) MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error {
-	return p.withTx(ctx, func(tx pgx.Tx) error {
-		if _, err := tx.Exec(ctx, "UPDATE DAG_Runs SET status = $1 WHERE run_id = $2;", outcome, dagRunId); err != nil {
-			return err
-		}
-
-		return nil
-	})
-}
+// getConditionalStartingTasks evaluates the when expressions of the starting tasks,
+// skipping the ones whose condition is false and picking up the tasks that become runnable as a result
+func (p *postgresDAGManager) getConditionalStartingTasks(ctx context.Context, dagId, runId int, startingTasks []Task) ([]Task, error) {
+	taskIds := make([]int, 0, len(startingTasks))
+	for _, task := range startingTasks {
+		taskIds = append(taskIds, task.Id)
+	}
 
-func (p *postgresDAGManager) MarkSuccessAndGetNextTasks(ctx context.Context, taskRunId int) ([]Task, error) {
 	var tasks []Task
-
 	if err := p.withTx(ctx, func(tx pgx.Tx) error {
-		var runId int
-		err := tx.QueryRow(ctx, `
-		UPDATE Task_Runs 
-		SET status = 'success' 
-		WHERE task_run_id = $1 
-		RETURNING run_id`, taskRunId).Scan(&runId)
-		if err != nil && err != pgx.ErrNoRows {
-			return err
-		}
-
-		if _, err := tx.Exec(ctx, `
-			UPDATE DAG_Runs
-			SET successfulCount = successfulCount + 1
-			WHERE run_id = $1;`, runId); err != nil {
-			return err
-		}
-
-		var status string
-		err = tx.QueryRow(ctx, `
-			UPDATE DAG_Runs
-			SET status = 'success'
-			FROM DAGs
-			WHERE DAG_Runs.dag_id = DAGs.dag_id
-			AND DAGs.taskCount = DAG_Runs.successfulCount
-			AND DAG_Runs.run_id = $1
-			RETURNING DAG_Runs.status;
-		`, runId).Scan(&status)
-
-		if err != nil && err != pgx.ErrNoRows {
-			return err
-		}
-
-		if status == "success" {
-			return nil
-		}
-
-		dagId, err := p.getDAGIdFromRun(ctx, tx, runId)
+		runnable, _, err := p.resolveTasks(ctx, tx, runId, dagId, taskIds)
 		if err != nil {
 			return err
 		}
 
 		var parameters [][]string
-		tasks, parameters, err = p.getNextRunnableTasks(ctx, tx, taskRunId, runId, dagId)
+		tasks, parameters, err = p.getTasksByIds(ctx, tx, runnable, runId)
 		if err != nil {
 			return err
 		}
 
-		if err := p.fetchTaskParameters(ctx, tx, dagId, tasks, parameters); err != nil {
-			return err
+		if len(tasks) == 0 {
+			if _, err := p.markRunSuccessIfComplete(ctx, tx, runId); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		return p.fetchTaskParameters(ctx, tx, dagId, runId, tasks, parameters)
 	}); err != nil {
 		return nil, err
 	}
@@ -646,18 +1025,183 @@ func (p *postgresDAGManager) MarkSuccessAndGetNextTasks(ctx context.Context, tas
 	return tasks, nil
 }
 
-func (p *postgresDAGManager) getNextRunnableTasks(ctx context.Context, tx pgx.Tx, taskRunId, runId, dagId int) ([]Task, [][]string, error) {
-	dependencyCounts, err := p.getDependencyCounts(ctx, tx, dagId)
-	if err != nil {
-		return nil, nil, err
-	}
+func (p *postgresDAGManager) MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error {
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "UPDATE DAG_Runs SET status = $1 WHERE run_id = $2;", outcome, dagRunId); err != nil {
+			return err
+		}
 
-	metDependencies, err := p.getMetDependencies(ctx, tx, dagId, runId)
+		return nil
+	})
+}
+
+func (p *postgresDAGManager) MarkSuccessAndGetNextTasks(ctx context.Context, taskRunId int) ([]Task, error) {
+	var tasks []Task
+
+	if err := p.withTx(ctx, func(tx pgx.Tx) error {
+		if err := p.storeTaskCache(ctx, tx, taskRunId); err != nil {
+			return err
+		}
+
+		var err error
+		tasks, err = p.completeTaskRun(ctx, tx, taskRunId, "success")
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (p *postgresDAGManager) CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []Task, error) {
+	var tasks []Task
+	cached := false
+
+	if err := p.withTx(ctx, func(tx pgx.Tx) error {
+		var outputs *string
+		err := tx.QueryRow(ctx, `
+		SELECT tc.outputs::text
+		FROM Task_Runs tr
+		JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+		JOIN DAGs d ON d.dag_id = dt.dag_id
+		JOIN Task_Cache tc ON tc.namespace = d.namespace AND tc.dag_name = d.name AND tc.task_name = dt.name
+		WHERE tr.task_run_id = $1 AND tc.cache_key = $2 AND tc.expires_at > NOW();`, taskRunId, cacheKey).Scan(&outputs)
+		if err == pgx.ErrNoRows {
+			// keep the key so the result is cached once the task run succeeds
+			_, err := tx.Exec(ctx, `UPDATE Task_Runs SET cache_key = $1 WHERE task_run_id = $2`, cacheKey, taskRunId)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		// a task run that timed out or was claimed by another worker meanwhile is left as it is
+		tag, err := tx.Exec(ctx, `
+		UPDATE Task_Runs
+		SET cache_key = $1, outputs = $2::jsonb, claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
+		WHERE task_run_id = $3 AND status = 'pending' AND claimed_by = $4`, cacheKey, outputs, taskRunId, workerId)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrTaskRunNotClaimed
+		}
+
+		cached = true
+		tasks, err = p.completeTaskRun(ctx, tx, taskRunId, "cached")
+		return err
+	}); err != nil {
+		return false, nil, err
+	}
+
+	return cached, tasks, nil
+}
+
+func (p *postgresDAGManager) InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error) {
+	// a nil slice is sent as NULL rather than an empty array
+	if taskNames == nil {
+		taskNames = []string{}
+	}
+
+	tag, err := p.pool.Exec(ctx, `
+	DELETE FROM Task_Cache
+	WHERE dag_name = $1 AND namespace = $2 AND (cardinality($3::text[]) = 0 OR task_name = ANY($3));`, dagName, namespace, taskNames)
 	if err != nil {
-		return nil, nil, err
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// storeTaskCache keeps the result of a task run that succeeded for later runs with the same
+// cache key, when its task is cached. A newer result replaces the one already stored
+func (p *postgresDAGManager) storeTaskCache(ctx context.Context, tx pgx.Tx, taskRunId int) error {
+	_, err := tx.Exec(ctx, `
+	INSERT INTO Task_Cache (namespace, dag_name, task_name, cache_key, outputs, created_at, expires_at)
+	SELECT d.namespace, d.name, dt.name, tr.cache_key, tr.outputs, NOW(), NOW() + dt.cacheTTLSeconds * INTERVAL '1 second'
+	FROM Task_Runs tr
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	JOIN DAGs d ON d.dag_id = dt.dag_id
+	WHERE tr.task_run_id = $1 AND tr.cache_key IS NOT NULL AND dt.cacheTTLSeconds IS NOT NULL
+	ON CONFLICT (namespace, dag_name, task_name, cache_key) DO UPDATE
+	SET outputs = EXCLUDED.outputs, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at;`, taskRunId)
+	return err
+}
+
+// completeTaskRun marks a task run as finished with a successful outcome, success or cached,
+// counts it against its run and returns the tasks that can run next
+func (p *postgresDAGManager) completeTaskRun(ctx context.Context, tx pgx.Tx, taskRunId int, outcome string) ([]Task, error) {
+	var runId, taskId int
+	var mapIndex *int
+	err := tx.QueryRow(ctx, `
+	UPDATE Task_Runs 
+	SET status = $1 
+	WHERE task_run_id = $2 
+	RETURNING run_id, task_id, map_index`, outcome, taskRunId).Scan(&runId, &taskId, &mapIndex)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+
+	column := "successfulCount"
+	if mapIndex != nil {
+		status, err := p.advanceMapTask(ctx, tx, runId, taskId)
+		if err != nil {
+			return nil, err
+		}
+
+		// downstream tasks wait until every instance has finished
+		if status == "running" {
+			return nil, nil
+		}
+
+		if status == "failed" {
+			column = "failedCount"
+		}
+	}
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE DAG_Runs
+		SET %[1]s = %[1]s + 1
+		WHERE run_id = $1;`, column), runId); err != nil {
+		return nil, err
+	}
+
+	complete, err := p.markRunSuccessIfComplete(ctx, tx, runId)
+	if err != nil {
+		return nil, err
+	}
+
+	if complete {
+		return nil, nil
+	}
+
+	dagId, err := p.getDAGIdFromRun(ctx, tx, runId)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, parameters, err := p.getNextRunnableTasks(ctx, tx, runId, dagId)
+	if err != nil {
+		return nil, err
+	}
+
+	// skipped tasks may have been the last ones left in the run
+	if len(tasks) == 0 {
+		if _, err := p.markRunSuccessIfComplete(ctx, tx, runId); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.fetchTaskParameters(ctx, tx, dagId, runId, tasks, parameters); err != nil {
+		return nil, err
 	}
 
-	runnableTasks, err := p.getRunnableTasks(ctx, tx, dependencyCounts, metDependencies, taskRunId)
+	return tasks, nil
+}
+
+func (p *postgresDAGManager) getNextRunnableTasks(ctx context.Context, tx pgx.Tx, runId, dagId int) ([]Task, [][]string, error) {
+	runnableTasks, _, err := p.resolveTasks(ctx, tx, runId, dagId, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -665,96 +1209,350 @@ func (p *postgresDAGManager) getNextRunnableTasks(ctx context.Context, tx pgx.Tx
 	return p.getTasksByIds(ctx, tx, runnableTasks, runId)
 }
 
-func (p *postgresDAGManager) getRunnableTasks(ctx context.Context, tx pgx.Tx, dependencyCounts, metDependencies map[int]int, taskRunId int) ([]int, error) {
-	var runnableTasks []int
+// advanceMapTask starts the next waiting instance of a map task and returns the status of the map task as a whole
+func (p *postgresDAGManager) advanceMapTask(ctx context.Context, tx pgx.Tx, runId, taskId int) (string, error) {
+	if _, err := tx.Exec(ctx, `
+		UPDATE Task_Runs
+		SET status = 'pending'
+		WHERE task_run_id = (
+			SELECT task_run_id
+			FROM Task_Runs
+			WHERE run_id = $1 AND task_id = $2 AND status = 'waiting'
+			ORDER BY map_index
+			LIMIT 1
+		);`, runId, taskId); err != nil {
+		return "", err
+	}
+
+	statuses, err := p.getTaskStatuses(ctx, tx, runId)
+	if err != nil {
+		return "", err
+	}
+
+	return statuses[taskId], nil
+}
+
+// markRunSuccessIfComplete marks the run as successful once every task in it has either succeeded or been skipped
+func (p *postgresDAGManager) markRunSuccessIfComplete(ctx context.Context, tx pgx.Tx, runId int) (bool, error) {
+	var status string
+	err := tx.QueryRow(ctx, `
+		UPDATE DAG_Runs
+		SET status = 'success'
+		FROM DAGs
+		WHERE DAG_Runs.dag_id = DAGs.dag_id
+		AND DAGs.taskCount = DAG_Runs.successfulCount + DAG_Runs.skippedCount
+		AND DAG_Runs.run_id = $1
+		RETURNING DAG_Runs.status;
+	`, runId).Scan(&status)
+
+	if err != nil && err != pgx.ErrNoRows {
+		return false, err
+	}
+
+	return status == "success", nil
+}
+
+// resolveTasks applies the trigger rules and when expressions of the tasks in the
+// run that have not started yet. Tasks that can no longer run are suspended or
+// skipped, which can in turn decide further tasks, so it repeats until nothing
+// changes. Candidates are starting tasks that should be considered as well.
+// It returns the tasks that should run and the tasks it suspended.
+func (p *postgresDAGManager) resolveTasks(ctx context.Context, tx pgx.Tx, runId, dagId int, candidates []int) ([]int, []int, error) {
+	graph, err := p.getDAGGraph(ctx, tx, dagId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	runnable := []int{}
+	suspended := []int{}
+	seen := map[int]bool{}
 
-	for taskId, totalDeps := range dependencyCounts {
-		metDeps := metDependencies[taskId]
-		if totalDeps != metDeps {
-			continue
+	for {
+		statuses, err := p.getTaskStatuses(ctx, tx, runId)
+		if err != nil {
+			return nil, nil, err
 		}
-		var taskStatus string
-		err := tx.QueryRow(ctx, `
-                SELECT status
-                FROM Task_Runs
-                WHERE task_id = $1 AND run_id = $2;
-            `, taskId, taskRunId).Scan(&taskStatus)
 
-		if err == pgx.ErrNoRows {
-			runnableTasks = append(runnableTasks, taskId)
-			continue
-		} else if err != nil {
-			return nil, err
+		// starting tasks that were already enqueued are left to the caller
+		for _, id := range candidates {
+			if _, started := statuses[id]; started && !seen[id] {
+				seen[id] = true
+				runnable = append(runnable, id)
+			}
+		}
+
+		run, skip, suspend := decideTasks(graph, statuses, candidates)
+
+		var scope *conditions.Scope
+		for _, id := range run {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			task := graph[id]
+			if task.when != "" {
+				if scope == nil {
+					if scope, err = p.getConditionScope(ctx, tx, runId, dagId); err != nil {
+						return nil, nil, err
+					}
+				}
+
+				ok, err := conditions.Evaluate(task.when, *scope)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to evaluate when expression of task %d: %w", id, err)
+				}
+
+				if !ok {
+					skip = append(skip, id)
+					continue
+				}
+			}
+
+			// a map over no items has nothing to run
+			if task.isMap() {
+				items, err := p.getMapItems(ctx, tx, runId, dagId, task)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				if len(items) == 0 {
+					skip = append(skip, id)
+					continue
+				}
+			}
+
+			runnable = append(runnable, id)
+		}
+
+		if len(skip) == 0 && len(suspend) == 0 {
+			return runnable, suspended, nil
+		}
+
+		if len(skip) > 0 {
+			if err := p.markTasksWithStatus(ctx, tx, runId, skip, "skipped"); err != nil {
+				return nil, nil, err
+			}
 		}
-	}
 
-	return runnableTasks, nil
+		if len(suspend) > 0 {
+			if err := p.markTasksWithStatus(ctx, tx, runId, suspend, "suspended"); err != nil {
+				return nil, nil, err
+			}
+			suspended = append(suspended, suspend...)
+		}
+	}
 }
 
-func (p *postgresDAGManager) getDependencyCounts(ctx context.Context, tx pgx.Tx, dagId int) (map[int]int, error) {
+// getDAGGraph returns every task of the DAG along with its upstream tasks
+func (p *postgresDAGManager) getDAGGraph(ctx context.Context, tx pgx.Tx, dagId int) (map[int]*dagTask, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT d.task_id, COUNT(d.depends_on_task_id)
+		SELECT dag_task_id, COALESCE(whenExpr, ''), COALESCE(triggerRule, ''), COALESCE(mapParameter, ''), COALESCE(mapOutput, ''), mapMaxParallelism
+		FROM DAG_Tasks
+		WHERE dag_id = $1;`, dagId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	graph := map[int]*dagTask{}
+	for rows.Next() {
+		var id int
+		task := &dagTask{}
+		if err := rows.Scan(&id, &task.when, &task.triggerRule, &task.mapParameter, &task.mapOutput, &task.mapMaxParallelism); err != nil {
+			return nil, err
+		}
+		graph[id] = task
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	depRows, err := tx.Query(ctx, `
+		SELECT d.task_id, d.depends_on_task_id
 		FROM Dependencies d
 		JOIN DAG_Tasks dt ON d.task_id = dt.dag_task_id
-		WHERE dt.dag_id = $1
-		GROUP BY d.task_id`, dagId)
+		WHERE dt.dag_id = $1;`, dagId)
+	if err != nil {
+		return nil, err
+	}
+	defer depRows.Close()
+
+	for depRows.Next() {
+		var taskId, dependsOn int
+		if err := depRows.Scan(&taskId, &dependsOn); err != nil {
+			return nil, err
+		}
+
+		if task, ok := graph[taskId]; ok {
+			task.upstream = append(task.upstream, dependsOn)
+		}
+	}
 
+	return graph, depRows.Err()
+}
+
+// getTaskStatuses returns the status of the latest attempt of each task that has started in the run
+func (p *postgresDAGManager) getTaskStatuses(ctx context.Context, tx pgx.Tx, runId int) (map[int]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT task_id, map_index, status
+		FROM Task_Runs
+		WHERE run_id = $1
+		ORDER BY task_run_id;`, runId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	dependencyCounts := make(map[int]int)
+	runs := []taskRunStatus{}
 	for rows.Next() {
-		var taskId, totalDependencies int
-		if err := rows.Scan(&taskId, &totalDependencies); err != nil {
+		var run taskRunStatus
+		if err := rows.Scan(&run.taskId, &run.mapIndex, &run.status); err != nil {
 			return nil, err
 		}
-		dependencyCounts[taskId] = totalDependencies
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return dependencyCounts, nil
+	return rollupTaskStatuses(runs), nil
 }
 
-func (p *postgresDAGManager) getMetDependencies(ctx context.Context, tx pgx.Tx, dagId, runID int) (map[int]int, error) {
-	// Query to get the count of met dependencies for tasks in the same DAG and not already started/completed
-	rows, err := tx.Query(ctx, `
-		SELECT d.task_id, COUNT(d.depends_on_task_id)
-		FROM Dependencies d
-		JOIN Task_Runs tr ON d.depends_on_task_id = tr.task_id
-		WHERE tr.status = 'success'
-		AND d.task_id IN (
-			SELECT dag_task_id
-			FROM DAG_Tasks 
-			WHERE dag_id = $1
-		)
-		AND d.task_id NOT IN (
-			SELECT task_id 
-			FROM Task_Runs 
-			WHERE
-				status IN ('running', 'success', 'failed')
-			AND run_id = $2
-		)
-		AND tr.run_id = $2
-		GROUP BY d.task_id`, dagId, runID)
+// getMapItems resolves the items a map task expands over, from the run parameters or the output of an upstream task
+func (p *postgresDAGManager) getMapItems(ctx context.Context, tx pgx.Tx, runId, dagId int, task *dagTask) ([]string, error) {
+	var value sql.NullString
+	var err error
+
+	if task.mapParameter != "" {
+		// run values override the default of the DAG
+		err = tx.QueryRow(ctx, `
+			SELECT value FROM (
+				SELECT defaultValue AS value, 0 AS priority FROM DAG_Parameters WHERE dag_id = $1 AND name = $2
+				UNION ALL
+				SELECT value, 1 AS priority FROM DAG_Run_Parameters WHERE run_id = $3 AND name = $2
+			) params
+			ORDER BY priority DESC
+			LIMIT 1;`, dagId, task.mapParameter, runId).Scan(&value)
+	} else {
+		taskName, output, _ := strings.Cut(task.mapOutput, ".")
+		err = tx.QueryRow(ctx, `
+			SELECT tr.outputs ->> $4
+			FROM Task_Runs tr
+			JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+			WHERE tr.run_id = $1 AND dt.dag_id = $2 AND dt.name = $3 AND tr.status IN ('success', 'cached') AND tr.outputs IS NOT NULL
+			ORDER BY tr.task_run_id DESC
+			LIMIT 1;`, runId, dagId, taskName, output).Scan(&value)
+	}
+
+	// an upstream task that was skipped has no items to offer
+	if err == pgx.ErrNoRows {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get map items: %w", err)
+	}
+
+	return parseMapItems(value.String)
+}
+
+// getConditionScope collects the non-secret parameters of the run and the
+// results of the tasks that have finished so far
+func (p *postgresDAGManager) getConditionScope(ctx context.Context, tx pgx.Tx, runId, dagId int) (*conditions.Scope, error) {
+	scope := &conditions.Scope{
+		Params: map[string]string{},
+		Tasks:  map[string]conditions.TaskResult{},
+	}
 
+	// run values override the defaults of the DAG
+	rows, err := tx.Query(ctx, `
+		SELECT name, defaultValue, 0 AS priority FROM DAG_Parameters WHERE dag_id = $1 AND isSecret = FALSE AND isConfigMap = FALSE
+		UNION ALL
+		SELECT name, value, 1 AS priority FROM DAG_Run_Parameters WHERE run_id = $2 AND isSecret = FALSE AND isConfigMap = FALSE
+		ORDER BY priority;`, dagId, runId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Map to store met dependency counts for each task
-	metDependencies := make(map[int]int)
 	for rows.Next() {
-		var taskId, metDeps int
-		if err := rows.Scan(&taskId, &metDeps); err != nil {
+		var name, value string
+		var priority int
+		if err := rows.Scan(&name, &value, &priority); err != nil {
+			return nil, err
+		}
+		scope.Params[name] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// a result reused from the cache reads as a success
+	taskRows, err := tx.Query(ctx, `
+		SELECT dt.name, CASE tr.status WHEN 'cached' THEN 'success' ELSE tr.status END, tr.outputs
+		FROM Task_Runs tr
+		JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+		WHERE tr.run_id = $1
+		ORDER BY tr.task_run_id;`, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer taskRows.Close()
+
+	for taskRows.Next() {
+		var name, status string
+		var raw []byte
+		if err := taskRows.Scan(&name, &status, &raw); err != nil {
 			return nil, err
 		}
 
-		metDependencies[taskId] = metDeps
+		outputs := map[string]string{}
+		if raw != nil {
+			if err := json.Unmarshal(raw, &outputs); err != nil {
+				return nil, err
+			}
+		}
+
+		// later attempts replace earlier ones
+		scope.Tasks[name] = conditions.TaskResult{Status: status, Outputs: outputs}
+	}
+
+	return scope, taskRows.Err()
+}
+
+// markTasksWithStatus records tasks that will not run, either skipped or suspended, and counts them against the run
+func (p *postgresDAGManager) markTasksWithStatus(ctx context.Context, tx pgx.Tx, runId int, taskIds []int, status string) error {
+	rows := make([][]interface{}, 0, len(taskIds))
+	for _, taskId := range taskIds {
+		rows = append(rows, []interface{}{runId, taskId, status, 0})
+	}
+
+	if _, err := tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"task_runs"},
+		[]string{"run_id", "task_id", "status", "attempts"},
+		pgx.CopyFromRows(rows),
+	); err != nil {
+		return fmt.Errorf("failed to insert %s task runs: %w", status, err)
+	}
+
+	column := "skippedCount"
+	if status == "suspended" {
+		column = "suspendedCount"
 	}
 
-	return metDependencies, nil
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE DAG_Runs
+		SET %[1]s = %[1]s + $1
+		WHERE run_id = $2;`, column), len(taskIds), runId); err != nil {
+		return fmt.Errorf("failed to update %s: %w", column, err)
+	}
+
+	return nil
 }
 
 func (p *postgresDAGManager) getTasksByIds(ctx context.Context, tx pgx.Tx, taskIds []int, dagrunId int) ([]Task, [][]string, error) {
@@ -835,13 +1633,12 @@ func (p *postgresDAGManager) getTasksByIds(ctx context.Context, tx pgx.Tx, taskI
 	return tasks, parameters, nil
 }
 
-func (p *postgresDAGManager) fetchTaskParameters(ctx context.Context, tx pgx.Tx, dagId int, tasks []Task, parameters [][]string) error {
-	// Map param name -> list of task indices that reference it
-	paramIndices := make(map[string][]int)
+// fetchTaskParameters fills in the parameters of tasks, values given to the run overriding the defaults of the DAG
+func (p *postgresDAGManager) fetchTaskParameters(ctx context.Context, tx pgx.Tx, dagId, runId int, tasks []Task, parameters [][]string) error {
+	// Collect the names of the parameters any of the tasks reference
 	uniqueParams := make(map[string]struct{})
-	for i, taskParams := range parameters {
+	for _, taskParams := range parameters {
 		for _, param := range taskParams {
-			paramIndices[param] = append(paramIndices[param], i)
 			uniqueParams[param] = struct{}{}
 		}
 	}
@@ -862,37 +1659,41 @@ func (p *postgresDAGManager) fetchTaskParameters(ctx context.Context, tx pgx.Tx,
 
 	// Query all parameters in a single batch
 	rows, err := tx.Query(ctx, `
-		SELECT name, isSecret, defaultValue
+		SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue, 0 AS priority
 		FROM DAG_Parameters
 		WHERE dag_id = $1 AND name = ANY($2)
-	`, dagId, flattenedParams)
+		UNION ALL
+		SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), value, 1 AS priority
+		FROM DAG_Run_Parameters
+		WHERE run_id = $3 AND name = ANY($2)
+		ORDER BY priority
+	`, dagId, flattenedParams, runId)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	// Initialize parameter slices
-	for i := range tasks {
-		tasks[i].Parameters = []Parameter{}
-	}
-
-	// Map the results to their respective tasks
+	values := make(map[string]Parameter, len(flattenedParams))
 	for rows.Next() {
-		var name string
-		var isSecret bool
-		var value string
-
-		if err := rows.Scan(&name, &isSecret, &value); err != nil {
+		var param Parameter
+		var priority int
+		if err := rows.Scan(&param.Name, &param.IsSecret, &param.IsConfigMap, &param.Key, &param.Value, &priority); err != nil {
 			return err
 		}
+		values[param.Name] = param
+	}
 
-		indices := paramIndices[name]
-		for _, idx := range indices {
-			tasks[idx].Parameters = append(tasks[idx].Parameters, Parameter{
-				Name:     name,
-				IsSecret: isSecret,
-				Value:    value,
-			})
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Populate parameters for tasks in the order they declare them
+	for i := range tasks {
+		tasks[i].Parameters = []Parameter{}
+		for _, name := range parameters[i] {
+			if value, ok := values[name]; ok {
+				tasks[i].Parameters = append(tasks[i].Parameters, value)
+			}
 		}
 	}
 
@@ -942,37 +1743,91 @@ func (p *postgresDAGManager) MarkTaskAsStarted(ctx context.Context, runId int, t
 func (p *postgresDAGManager) ClaimTasks(ctx context.Context, limit int, workerId string, leaseTTL time.Duration) ([]TaskClaim, error) {
 	leaseInterval := fmt.Sprintf("%d seconds", int(leaseTTL.Seconds()))
 
-	rows, err := p.pool.Query(ctx, `
-	WITH candidates AS (
-		SELECT task_run_id
-		FROM Task_Runs
-		WHERE status = 'pending' AND (scheduled_start IS NULL OR scheduled_start <= now())
-		AND (claimed_by IS NULL OR lease_expires_at <= now())
-		FOR UPDATE SKIP LOCKED
+	claims := []TaskClaim{}
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// higher priorities go first, with task runs gaining a level for every priorityAgingSeconds they have
+		// been claimable so lower priorities are not starved, then the longest waiting. Tasks in a pool
+		// are left queued while it does not exist or has too few free slots
+		rows, err := tx.Query(ctx, `
+		SELECT tr.task_run_id, tr.task_id, tr.run_id, tr.map_index, COALESCE(tr.map_item, ''), pu.pool_id, dt.poolSlots
+		FROM Task_Runs tr
+		JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+		JOIN DAGs d ON d.dag_id = dt.dag_id
+		LEFT JOIN Pool_Usage pu ON pu.name = dt.pool AND pu.namespace = d.namespace
+		WHERE tr.status = 'pending' AND (tr.scheduled_start IS NULL OR tr.scheduled_start <= now())
+		AND (tr.claimed_by IS NULL OR tr.lease_expires_at <= now())
+		AND (dt.pool IS NULL OR dt.poolSlots <= pu.slots - pu.occupied_slots)
+		ORDER BY tr.priority + FLOOR(EXTRACT(EPOCH FROM now() - COALESCE(tr.scheduled_start, tr.queued_at, now())) / $2::integer) DESC,
+			COALESCE(tr.scheduled_start, tr.queued_at) ASC, tr.task_run_id ASC
 		LIMIT $1
-	)
-	UPDATE Task_Runs tr
-	SET claimed_by = $2, claimed_at = now(), lease_expires_at = now() + ($3::interval)
-	FROM candidates c
-	WHERE tr.task_run_id = c.task_run_id
-	AND tr.status = 'pending'
-	AND (tr.claimed_by IS NULL OR tr.lease_expires_at <= now())
-	RETURNING tr.task_run_id, tr.task_id, tr.run_id;`, limit, workerId, leaseInterval)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		FOR UPDATE OF tr SKIP LOCKED;`, limit, priorityAgingSeconds)
+		if err != nil {
+			return err
+		}
 
-	var claims []TaskClaim
-	for rows.Next() {
-		var c TaskClaim
-		if err := rows.Scan(&c.TaskRunID, &c.TaskID, &c.RunID); err != nil {
-			return nil, err
+		var candidates []claimCandidate
+		var poolIds []int
+		for rows.Next() {
+			var c claimCandidate
+			if err := rows.Scan(&c.TaskRunID, &c.TaskID, &c.RunID, &c.MapIndex, &c.MapItem, &c.poolId, &c.poolSlots); err != nil {
+				rows.Close()
+				return err
+			}
+			if c.poolId != nil {
+				poolIds = append(poolIds, *c.poolId)
+			}
+			candidates = append(candidates, c)
 		}
-		claims = append(claims, c)
-	}
+		rows.Close()
 
-	if err := rows.Err(); err != nil {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		freeSlots := map[int]int{}
+		if len(poolIds) > 0 {
+			// once the pools are locked the claims of other workers have settled, so their free slots are read again
+			if _, err := tx.Exec(ctx, `SELECT pool_id FROM Pools WHERE pool_id = ANY($1) ORDER BY pool_id FOR UPDATE;`, poolIds); err != nil {
+				return err
+			}
+
+			rows, err := tx.Query(ctx, `SELECT pool_id, slots - occupied_slots FROM Pool_Usage WHERE pool_id = ANY($1);`, poolIds)
+			if err != nil {
+				return err
+			}
+
+			for rows.Next() {
+				var poolId, free int
+				if err := rows.Scan(&poolId, &free); err != nil {
+					rows.Close()
+					return err
+				}
+				freeSlots[poolId] = free
+			}
+			rows.Close()
+
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+
+		claims = admitCandidates(candidates, freeSlots)
+		if len(claims) == 0 {
+			return nil
+		}
+
+		ids := make([]int, 0, len(claims))
+		for _, c := range claims {
+			ids = append(ids, c.TaskRunID)
+		}
+
+		_, err = tx.Exec(ctx, `
+		UPDATE Task_Runs
+		SET claimed_by = $2, claimed_at = now(), lease_expires_at = now() + ($3::interval)
+		WHERE task_run_id = ANY($1);`, ids, workerId, leaseInterval)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -996,6 +1851,43 @@ func (p *postgresDAGManager) RenewLease(ctx context.Context, taskRunId int, work
 	return nil
 }
 
+func (p *postgresDAGManager) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	deferred := false
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// later checks leave the deadline set by the first one, as it is always the earlier
+		var passed bool
+		if err := tx.QueryRow(ctx, `
+		UPDATE Task_Runs
+		SET timeout_at = LEAST(timeout_at, now() + $1::integer * INTERVAL '1 second')
+		WHERE task_run_id = $2 AND status = 'pending' AND claimed_by = $3
+		RETURNING timeout_at <= now()`, int(timeout.Seconds()), taskRunId, workerId).Scan(&passed); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrTaskRunNotClaimed
+			}
+			return err
+		}
+
+		if passed {
+			return nil
+		}
+
+		// the last check happens as the deadline passes
+		if _, err := tx.Exec(ctx, `
+		UPDATE Task_Runs
+		SET claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL,
+			scheduled_start = LEAST(now() + $1::integer * INTERVAL '1 second', timeout_at)
+		WHERE task_run_id = $2`, int(interval.Seconds()), taskRunId); err != nil {
+			return err
+		}
+
+		deferred = true
+		return nil
+	})
+
+	return deferred, err
+}
+
 // FinalizeClaimToRunning transitions a claimed task into running state and clears the claim.
 // Optionally a podUID can be provided which will be recorded by pod watchers separately.
 func (p *postgresDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
@@ -1003,7 +1895,7 @@ func (p *postgresDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRun
 	err := p.pool.QueryRow(ctx, `
 	UPDATE Task_Runs
 	SET status = 'running', claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
-	WHERE task_run_id = $1 AND claimed_by = $2
+	WHERE task_run_id = $1 AND claimed_by = $2 AND status = 'pending'
 	RETURNING run_id;`, taskRunId, workerId).Scan(&runId)
 	if err != nil {
 		return err
@@ -1014,6 +1906,7 @@ func (p *postgresDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRun
 }
 
 // RecoverExpiredLeases clears claims that have expired and returns the number of rows released.
+// ClaimTasks then hands the task runs out again by priority
 func (p *postgresDAGManager) RecoverExpiredLeases(ctx context.Context) (int, error) {
 	cmd, err := p.pool.Exec(ctx, `
 	UPDATE Task_Runs
@@ -1033,6 +1926,8 @@ type DagInfo struct {
 	WorkspaceEnabled bool
 	WebhookUrl       string
 	SSLVerification  bool
+	// Schedule time the run is started for
+	ScheduledTime time.Time
 }
 
 func (p *postgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm time.Time) ([]*DagInfo, error) {
@@ -1044,10 +1939,10 @@ func (p *postgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm tim
 	defer tx.Rollback(ctx)
 
 	rows, err := tx.Query(ctx, `
-        SELECT dag_id, name, schedule, namespace, workspaceEnabled, webhookUrl, sslVerification
+        SELECT dag_id, name, schedule, namespace, workspaceEnabled, webhookUrl, sslVerification, nexttime, catchup, maxCatchupRuns, COALESCE(timezone, ''), startTime, endTime
         FROM DAGs
         WHERE nexttime <= $1 AND schedule != '' AND active = TRUE AND suspended = FALSE;
-    `, tm)
+    `, tm.UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -1056,6 +1951,10 @@ func (p *postgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm tim
 	namespaces := []*DagInfo{}
 
 	schedules := []string{}
+	nextTimes := []time.Time{}
+	limits := []int{}
+	timezones := []string{}
+	windows := []scheduleWindow{}
 	for rows.Next() {
 		var dagId int
 		var name string
@@ -1064,12 +1963,18 @@ func (p *postgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm tim
 		var workEnabled bool
 		var webhookUrl string
 		var sslVerification bool
+		var nextTime time.Time
+		var catchup bool
+		var maxCatchupRuns int
+		var timezone string
+		var window scheduleWindow
 
-		if err := rows.Scan(&dagId, &name, &schedule, &namespace, &workEnabled, &webhookUrl, &sslVerification); err != nil {
+		if err := rows.Scan(&dagId, &name, &schedule, &namespace, &workEnabled, &webhookUrl, &sslVerification, &nextTime, &catchup, &maxCatchupRuns, &timezone, &window.start, &window.end); err != nil {
 			return nil, err
 		}
 
 		namespaces = append(namespaces, &DagInfo{
+			DagId:            dagId,
 			DagName:          name,
 			Namespace:        namespace,
 			WorkspaceEnabled: workEnabled,
@@ -1078,22 +1983,34 @@ func (p *postgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm tim
 		})
 
 		schedules = append(schedules, schedule)
+		nextTimes = append(nextTimes, nextTime)
+		limits = append(limits, catchupLimit(catchup, maxCatchupRuns))
+		timezones = append(timezones, timezone)
+		windows = append(windows, window)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
+	dagInfos := []*DagInfo{}
+
 	batch := &pgx.Batch{}
 	for i, schedule := range schedules {
 		// Parse the cron expression
-		sched, err := p.parser.Parse(schedule)
+		sched, err := parseSchedule(p.parser, schedule, timezones[i])
 		if err != nil {
 			return nil, err
 		}
 
-		// Get the next occurrence of the scheduled time
-		nextTime := sched.Next(time.Now())
+		for _, scheduledTime := range missedScheduleTimes(sched, windows[i], nextTimes[i], tm, limits[i]) {
+			dagInfo := *namespaces[i]
+			dagInfo.ScheduledTime = scheduledTime
+			dagInfos = append(dagInfos, &dagInfo)
+		}
+
+		// Get the next occurrence of the scheduled time, none once the schedule window has closed
+		nextTime := nextTimeColumn(windows[i].next(sched, tm))
 
 		batch.Queue(`
             UPDATE DAGs 
@@ -1108,12 +2025,118 @@ func (p *postgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm tim
 		return nil, err
 	}
 
-	return namespaces, nil
+	return dagInfos, nil
+}
+
+func (p *postgresDAGManager) GetBackfillsToStartAndUpdate(ctx context.Context) ([]*DagInfo, error) {
+	dagInfos := []*DagInfo{}
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// backfills of suspended DAGs wait until they are resumed
+		rows, err := tx.Query(ctx, `
+			SELECT b.backfill_id, b.end_time, b.cursor_time, d.dag_id, d.name, d.namespace, d.schedule, COALESCE(d.timezone, ''), d.workspaceEnabled, d.webhookUrl, d.sslVerification
+			FROM DAG_Backfills b
+			JOIN DAGs d ON d.name = b.dag_name AND d.namespace = b.namespace
+			WHERE b.completed = FALSE AND d.active = TRUE AND d.suspended = FALSE AND d.schedule != ''
+			ORDER BY b.backfill_id
+			FOR UPDATE OF b SKIP LOCKED;`)
+		if err != nil {
+			return err
+		}
+
+		type backfill struct {
+			id       int
+			end      time.Time
+			cursor   time.Time
+			schedule string
+			timezone string
+			info     DagInfo
+		}
+
+		backfills := []backfill{}
+		for rows.Next() {
+			var b backfill
+			var workEnabled sql.NullBool
+			var webhookUrl sql.NullString
+			var sslVerification sql.NullBool
+			if err := rows.Scan(&b.id, &b.end, &b.cursor, &b.info.DagId, &b.info.DagName, &b.info.Namespace, &b.schedule, &b.timezone, &workEnabled, &webhookUrl, &sslVerification); err != nil {
+				rows.Close()
+				return err
+			}
+			b.info.WorkspaceEnabled = workEnabled.Valid && workEnabled.Bool
+			b.info.WebhookUrl = webhookUrl.String
+			b.info.SSLVerification = sslVerification.Valid && sslVerification.Bool
+			backfills = append(backfills, b)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, b := range backfills {
+			remaining := backfillBatchSize - len(dagInfos)
+			if remaining == 0 {
+				break
+			}
+
+			sched, err := parseSchedule(p.parser, b.schedule, b.timezone)
+			if err != nil {
+				return err
+			}
+
+			times, completed := backfillScheduleTimes(sched, b.cursor, b.end, remaining)
+			for _, scheduledTime := range times {
+				dagInfo := b.info
+				dagInfo.ScheduledTime = scheduledTime
+				dagInfos = append(dagInfos, &dagInfo)
+			}
+
+			cursor := b.cursor
+			if len(times) > 0 {
+				cursor = times[len(times)-1]
+			}
+
+			if _, err := tx.Exec(ctx, `
+				UPDATE DAG_Backfills
+				SET cursor_time = $1, completed = $2
+				WHERE backfill_id = $3;`, cursor, completed, b.id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dagInfos, nil
+}
+
+// addBackfill records the backfill range of a DAG, ranges that were recorded before are ignored
+func (p *postgresDAGManager) addBackfill(ctx context.Context, tx pgx.Tx, dagName, namespace string, backfill *v1alpha1.Backfill) (bool, error) {
+	if backfill == nil {
+		return false, nil
+	}
+
+	start, end := backfill.Start.UTC(), backfill.End.UTC()
+
+	// the cursor sits just before the start so a schedule time on the start itself is included
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO DAG_Backfills (dag_name, namespace, start_time, end_time, cursor_time)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (dag_name, namespace, start_time, end_time) DO NOTHING;`, dagName, namespace, start, end, start.Add(-time.Second))
+	if err != nil {
+		return false, fmt.Errorf("failed to add backfill: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
 }
 
 func (p *postgresDAGManager) GetDagParameters(ctx context.Context, dagName string) (map[string]*Parameter, error) {
 	rows, err := p.pool.Query(ctx, `
-	SELECT name, isSecret, defaultValue
+	SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue
 	FROM DAG_Parameters
 	WHERE dag_id IN (
 		SELECT dag_id
@@ -1133,7 +2156,7 @@ func (p *postgresDAGManager) GetDagParameters(ctx context.Context, dagName strin
 	parameters := map[string]*Parameter{}
 	for rows.Next() {
 		var parameter Parameter
-		if err := rows.Scan(&parameter.Name, &parameter.IsSecret, &parameter.Value); err != nil {
+		if err := rows.Scan(&parameter.Name, &parameter.IsSecret, &parameter.IsConfigMap, &parameter.Key, &parameter.Value); err != nil {
 			return nil, err
 		}
 
@@ -1164,7 +2187,7 @@ func (p *postgresDAGManager) ShouldRerun(ctx context.Context, taskRunid int, exi
         JOIN DAG_Tasks dt ON t.task_id = dt.task_id
         JOIN Task_Runs r ON dt.dag_task_id = r.task_id
         WHERE r.task_run_id = $1
-          AND r.attempts <= t.backoffLimit
+          AND r.attempts < t.backoffLimit
           AND (t.isConditional = FALSE OR $2 = ANY(t.retryCodes))
     )
 	`
@@ -1180,24 +2203,45 @@ func (p *postgresDAGManager) ShouldRerun(ctx context.Context, taskRunid int, exi
 
 func (p *postgresDAGManager) MarkTaskAsFailed(ctx context.Context, taskRunId int) error {
 	return p.withTx(ctx, func(tx pgx.Tx) error {
-		if _, err := tx.Exec(ctx, `
+		var runId, taskId int
+		var mapIndex *int
+		if err := tx.QueryRow(ctx, `
 		UPDATE Task_Runs 
 		SET status = 'failed' 
-		WHERE task_run_id = $1;
-	`, taskRunId); err != nil {
+		WHERE task_run_id = $1
+		RETURNING run_id, task_id, map_index;
+	`, taskRunId).Scan(&runId, &taskId, &mapIndex); err != nil {
 			return err
 		}
 
+		failed := 1
+		if mapIndex != nil {
+			// instances that have not started yet are abandoned
+			if _, err := tx.Exec(ctx, `
+			UPDATE Task_Runs
+			SET status = 'suspended'
+			WHERE run_id = $1 AND task_id = $2 AND status = 'waiting';
+		`, runId, taskId); err != nil {
+				return err
+			}
+
+			statuses, err := p.getTaskStatuses(ctx, tx, runId)
+			if err != nil {
+				return err
+			}
+
+			// the map task is counted once its last running instance finishes
+			if statuses[taskId] == "running" {
+				failed = 0
+			}
+		}
+
 		if _, err := tx.Exec(ctx, `
 	    UPDATE DAG_Runs
 	    SET
-	        failedCount = failedCount + 1,
+	        failedCount = failedCount + $1,
 	        status = 'failed'
-	    WHERE run_id in (
-			SELECT run_id
-			FROM Task_Runs
-			WHERE task_run_id = $1
-		);`, taskRunId); err != nil {
+	    WHERE run_id = $2;`, failed, runId); err != nil {
 			return err
 		}
 
@@ -1347,6 +2391,20 @@ func (p *postgresDAGManager) DeleteDAG(ctx context.Context, name string, namespa
 		i++
 	}
 
+	if _, err := tx.Exec(ctx, `
+	DELETE FROM DAG_Backfills
+	WHERE dag_name = $1 AND namespace = $2;
+	`, name, namespace); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+	DELETE FROM Task_Cache
+	WHERE dag_name = $1 AND namespace = $2;
+	`, name, namespace); err != nil {
+		return nil, err
+	}
+
 	// Get the latest version of the DAG
 	if _, err := tx.Exec(ctx, `
 	DELETE FROM DAGs
@@ -1458,16 +2516,130 @@ func (p *postgresDAGManager) GetTaskScriptAndInjectorImage(ctx context.Context,
 
 func (p *postgresDAGManager) AddPendingTaskRun(ctx context.Context, runId int, dagTaskId int) (int, error) {
 	var taskRunId int
-	if err := p.pool.QueryRow(ctx, `
-	INSERT INTO Task_Runs (run_id, task_id, status, attempts)
-	VALUES ($1, $2, 'pending', 0)
-	RETURNING task_run_id`, runId, dagTaskId).Scan(&taskRunId); err != nil {
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		dagId, task, err := p.getDAGTask(ctx, tx, dagTaskId)
+		if err != nil {
+			return err
+		}
+
+		priority, err := p.getTaskRunPriority(ctx, tx, runId, dagTaskId)
+		if err != nil {
+			return err
+		}
+
+		// the scheduler starts the child run of a sub-DAG task, not a worker
+		if task.dagRef != "" {
+			return tx.QueryRow(ctx, `
+			INSERT INTO Task_Runs (run_id, task_id, status, attempts, timeout_at, priority, queued_at)
+			VALUES ($1, $2, 'pending_dag', 0, NOW() + $3::integer * INTERVAL '1 second', $4, NOW())
+			RETURNING task_run_id`, runId, dagTaskId, task.timeoutSeconds, priority).Scan(&taskRunId)
+		}
+
+		if !task.isMap() {
+			return tx.QueryRow(ctx, `
+			INSERT INTO Task_Runs (run_id, task_id, status, attempts, timeout_at, priority, queued_at)
+			VALUES ($1, $2, 'pending', 0, NOW() + $3::integer * INTERVAL '1 second', $4, NOW())
+			RETURNING task_run_id`, runId, dagTaskId, task.timeoutSeconds, priority).Scan(&taskRunId)
+		}
+
+		items, err := p.getMapItems(ctx, tx, runId, dagId, task)
+		if err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			return fmt.Errorf("map task %d has no items", dagTaskId)
+		}
+
+		// instances over the parallelism limit wait for a running one to succeed
+		for i, item := range items {
+			status := "pending"
+			if task.mapMaxParallelism > 0 && i >= task.mapMaxParallelism {
+				status = "waiting"
+			}
+
+			var id int
+			if err := tx.QueryRow(ctx, `
+			INSERT INTO Task_Runs (run_id, task_id, status, attempts, map_index, map_item, timeout_at, priority, queued_at)
+			VALUES ($1, $2, $3, 0, $4, $5, NOW() + $6::integer * INTERVAL '1 second', $7, NOW())
+			RETURNING task_run_id`, runId, dagTaskId, status, i, item, task.timeoutSeconds, priority).Scan(&id); err != nil {
+				return err
+			}
+
+			if i == 0 {
+				taskRunId = id
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
 		return 0, err
 	}
+
 	return taskRunId, nil
 }
 
-func (p *postgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTaskId int) (Task, string, string, error) {
+// getDAGTask returns the DAG a task belongs to along with how it runs
+func (p *postgresDAGManager) getDAGTask(ctx context.Context, tx pgx.Tx, dagTaskId int) (int, *dagTask, error) {
+	var dagId int
+	task := &dagTask{}
+
+	if err := tx.QueryRow(ctx, `
+		SELECT dag_id, COALESCE(whenExpr, ''), COALESCE(triggerRule, ''), COALESCE(mapParameter, ''), COALESCE(mapOutput, ''), mapMaxParallelism, COALESCE(dagRefName, ''), timeoutSeconds
+		FROM DAG_Tasks
+		WHERE dag_task_id = $1;`, dagTaskId).Scan(&dagId, &task.when, &task.triggerRule, &task.mapParameter, &task.mapOutput, &task.mapMaxParallelism, &task.dagRef, &task.timeoutSeconds); err != nil {
+		return 0, nil, err
+	}
+
+	return dagId, task, nil
+}
+
+// getTaskRunPriority is the priority of the run, or of its DAG when the run has none, plus that of the task
+func (p *postgresDAGManager) getTaskRunPriority(ctx context.Context, tx pgx.Tx, runId, dagTaskId int) (int, error) {
+	var priority int
+	if err := tx.QueryRow(ctx, `
+		SELECT dt.priority + COALESCE(r.priority, d.priority)
+		FROM DAG_Runs r
+		JOIN DAGs d ON d.dag_id = r.dag_id
+		JOIN DAG_Tasks dt ON dt.dag_task_id = $1
+		WHERE r.run_id = $2;`, dagTaskId, runId).Scan(&priority); err != nil {
+		return 0, err
+	}
+
+	return priority, nil
+}
+
+func (p *postgresDAGManager) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	var attempts int
+	var backoff retryBackoff
+	if err := p.pool.QueryRow(ctx, `
+	SELECT r.attempts, t.backoffInitialDelaySeconds, t.backoffMaxDelaySeconds, t.backoffMultiplier, t.backoffJitter
+	FROM Task_Runs r
+	JOIN DAG_Tasks dt ON dt.dag_task_id = r.task_id
+	JOIN Tasks t ON t.task_id = dt.task_id
+	WHERE r.task_run_id = $1`, taskRunId).Scan(&attempts, &backoff.initialDelaySeconds, &backoff.maxDelaySeconds, &backoff.multiplier, &backoff.jitter); err != nil {
+		return 0, 0, err
+	}
+
+	delay := backoff.delay(attempts+1, rand.Float64())
+
+	// the new row counts the retries made so far, ShouldRerun stops once it reaches the backoff limit
+	var newTaskRunId int
+	if err := p.pool.QueryRow(ctx, `
+	INSERT INTO Task_Runs (run_id, task_id, status, attempts, map_index, map_item, timeout_at, scheduled_start, priority, queued_at)
+	SELECT run_id, task_id, 'pending', attempts + 1, map_index, map_item, timeout_at, NOW() + $2::double precision * INTERVAL '1 second', priority, NOW()
+	FROM Task_Runs
+	WHERE task_run_id = $1
+	RETURNING task_run_id`, taskRunId, scheduledStartSeconds(delay)).Scan(&newTaskRunId); err != nil {
+		return 0, 0, err
+	}
+	return newTaskRunId, delay, nil
+}
+
+func (p *postgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTaskId int, taskRunId int) (Task, string, string, error) {
 	var task Task
 	var podTemplateJSON sql.NullString
 	var script sql.NullString
@@ -1478,25 +2650,48 @@ func (p *postgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTa
 	// For Postgres we need to scan parameter names into a temporary []string and
 	// then fetch their values from DAG_Parameters so we populate Task.Parameters correctly.
 	var paramNames []string
+	var dagId int
+	var cacheTTL *int
+	var artifactsJSON sql.NullString
+	var sensorJSON sql.NullString
 	err := p.pool.QueryRow(ctx, `
-	SELECT dat.dag_task_id, dat.name, t.image, t.command, t.args, t.parameters, t.scriptInjectorImage, t.script, t.podTemplate, d.namespace, dr.pvcName, tr.retry_env
+	SELECT dat.dag_task_id, dat.name, t.image, t.command, t.args, t.parameters, t.scriptInjectorImage, t.script, t.podTemplate, d.namespace, dr.pvcName, tr.retry_env, t.outputs, dr.scheduled_time, dr.run_time, dr.dag_id, COALESCE(t.hash, ''), dat.cacheTTLSeconds, t.artifacts::text, dat.sensor::text
 	FROM Tasks t
 	JOIN DAG_Tasks dat ON dat.task_id = t.task_id
 	JOIN DAG_Runs dr ON dat.dag_id = dr.dag_id
 	JOIN DAGs d ON dr.dag_id = d.dag_id
-	LEFT JOIN Task_Runs tr ON tr.run_id = dr.run_id AND tr.task_id = dat.dag_task_id
-	WHERE dr.run_id = $1 AND dat.dag_task_id = $2
-	LIMIT 1;
-	`, runId, dagTaskId).Scan(&task.Id, &task.Name, &task.Image, &task.Command, &task.Args, &paramNames, &task.ScriptInjectorImage, &script, &podTemplateJSON, &namespace, &pvcName, &retryEnv)
+	LEFT JOIN Task_Runs tr ON tr.task_run_id = $3 AND tr.run_id = dr.run_id AND tr.task_id = dat.dag_task_id
+	WHERE dr.run_id = $1 AND dat.dag_task_id = $2;
+	`, runId, dagTaskId, taskRunId).Scan(&task.Id, &task.Name, &task.Image, &task.Command, &task.Args, &paramNames, &task.ScriptInjectorImage, &script, &podTemplateJSON, &namespace, &pvcName, &retryEnv, &task.Outputs, &task.ScheduledTime, &task.LogicalTime, &dagId, &task.Hash, &cacheTTL, &artifactsJSON, &sensorJSON)
 
 	if err != nil {
 		return Task{}, "", "", err
 	}
 
+	if cacheTTL != nil {
+		task.CacheTTL = time.Duration(*cacheTTL) * time.Second
+	}
+
+	if task.ScheduledTime != nil {
+		task.LogicalTime = *task.ScheduledTime
+	}
+
 	if script.Valid {
 		task.Script = script.String
 	}
 
+	if artifactsJSON.Valid {
+		if err := json.Unmarshal([]byte(artifactsJSON.String), &task.Artifacts); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
+	if sensorJSON.Valid {
+		if err := json.Unmarshal([]byte(sensorJSON.String), &task.Sensor); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
 	if podTemplateJSON.Valid {
 		var podTemplate v1alpha1.PodTemplateSpec
 		if err := json.Unmarshal([]byte(podTemplateJSON.String), &podTemplate); err != nil {
@@ -1524,31 +2719,41 @@ func (p *postgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTa
 		task.Parameters = []Parameter{}
 	}
 
-	// If there are parameter names, fetch their values and populate Task.Parameters
-	if len(paramNames) > 0 {
-		// use a transaction to fetch dag id and parameter values consistently
-		err = p.withTx(ctx, func(tx pgx.Tx) error {
-			// determine dag id from run
-			dagId, err := p.getDAGIdFromRun(ctx, tx, runId)
-			if err != nil {
-				return err
-			}
-
+	// use a transaction to fetch the parameter values consistently
+	err = p.withTx(ctx, func(tx pgx.Tx) error {
+		// If there are parameter names, fetch their values and populate Task.Parameters
+		if len(paramNames) > 0 {
 			// prepare a single task slice and parameters matrix for fetchTaskParameters
 			tasks := []Task{task}
 			paramsMatrix := [][]string{paramNames}
 
-			if err := p.fetchTaskParameters(ctx, tx, dagId, tasks, paramsMatrix); err != nil {
+			if err := p.fetchTaskParameters(ctx, tx, dagId, runId, tasks, paramsMatrix); err != nil {
 				return err
 			}
 
 			// assign back the populated parameters
 			task.Parameters = tasks[0].Parameters
-			return nil
-		})
+		}
+
+		runParams, err := p.getRunParameters(ctx, tx, runId, dagId)
 		if err != nil {
-			return Task{}, "", "", err
+			return err
 		}
+		task.RunParameters = literalParameters(runParams)
+		return nil
+	})
+	if err != nil {
+		return Task{}, "", "", err
+	}
+
+	task.UpstreamOutputs, err = p.getUpstreamOutputs(ctx, runId, dagTaskId)
+	if err != nil {
+		return Task{}, "", "", err
+	}
+
+	task.UpstreamArtifacts, err = p.getUpstreamArtifacts(ctx, runId, dagTaskId)
+	if err != nil {
+		return Task{}, "", "", err
 	}
 
 	var retry string
@@ -1559,35 +2764,162 @@ func (p *postgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTa
 	return task, namespace, retry, nil
 }
 
-func (p *postgresDAGManager) SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error {
-	_, err := p.pool.Exec(ctx, `UPDATE Task_Runs SET retry_env = $2 WHERE task_run_id = $1`, taskRunId, envJSON)
-	return err
-}
-
-func (p *postgresDAGManager) ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (TaskClaim, error) {
-	leaseInterval := fmt.Sprintf("%d seconds", int(leaseTTL.Seconds()))
-	var c TaskClaim
-	err := p.pool.QueryRow(ctx, `
-	UPDATE Task_Runs
-	SET claimed_by = $2, claimed_at = now(), lease_expires_at = now() + ($3::interval)
-	WHERE task_run_id = $1 AND status = 'pending' AND (claimed_by IS NULL OR lease_expires_at <= now())
-	RETURNING task_run_id, task_id, run_id;`, taskRunId, workerId, leaseInterval).Scan(&c.TaskRunID, &c.TaskID, &c.RunID)
+// getUpstreamOutputs collects the outputs published by every successful task
+// the given dag task depends on, directly or transitively, within the run.
+func (p *postgresDAGManager) getUpstreamOutputs(ctx context.Context, runId, dagTaskId int) ([]TaskOutput, error) {
+	rows, err := p.pool.Query(ctx, `
+	WITH RECURSIVE upstream AS (
+		SELECT depends_on_task_id AS task_id
+		FROM Dependencies
+		WHERE task_id = $2
+		UNION
+		SELECT d.depends_on_task_id
+		FROM Dependencies d
+		JOIN upstream u ON d.task_id = u.task_id
+	)
+	SELECT dt.name, tr.outputs
+	FROM Task_Runs tr
+	JOIN upstream u ON tr.task_id = u.task_id
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	WHERE tr.run_id = $1 AND tr.status IN ('success', 'cached') AND tr.outputs IS NOT NULL AND tr.map_index IS NULL;
+	`, runId, dagTaskId)
 	if err != nil {
-		return TaskClaim{}, err
+		return nil, err
 	}
-	return c, nil
-}
+	defer rows.Close()
 
-func (p *postgresDAGManager) GetTaskRunStatus(ctx context.Context, taskRunId int) (string, error) {
-	var status string
-	if err := p.pool.QueryRow(ctx, `SELECT status FROM Task_Runs WHERE task_run_id = $1`, taskRunId).Scan(&status); err != nil {
-		if err == pgx.ErrNoRows {
-			return "", ErrTaskRunNotFound
+	outputs := []TaskOutput{}
+	for rows.Next() {
+		var taskName string
+		var raw []byte
+		if err := rows.Scan(&taskName, &raw); err != nil {
+			return nil, err
 		}
-		return "", err
-	}
-	return status, nil
-}
+
+		values := map[string]string{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, err
+		}
+
+		outputs = appendTaskOutputs(outputs, taskName, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortTaskOutputs(outputs)
+	return outputs, nil
+}
+
+// getUpstreamArtifacts collects the artifacts published by every successful run of the tasks
+// the given dag task depends on, directly or transitively, within the run.
+func (p *postgresDAGManager) getUpstreamArtifacts(ctx context.Context, runId, dagTaskId int) ([]TaskArtifact, error) {
+	rows, err := p.pool.Query(ctx, `
+	WITH RECURSIVE upstream AS (
+		SELECT depends_on_task_id AS task_id
+		FROM Dependencies
+		WHERE task_id = $2
+		UNION
+		SELECT d.depends_on_task_id
+		FROM Dependencies d
+		JOIN upstream u ON d.task_id = u.task_id
+	)
+	SELECT dt.name, tr.map_index, t.artifacts::text
+	FROM Task_Runs tr
+	JOIN upstream u ON tr.task_id = u.task_id
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	JOIN Tasks t ON t.task_id = dt.task_id
+	WHERE tr.run_id = $1 AND tr.status = 'success' AND t.artifacts IS NOT NULL;
+	`, runId, dagTaskId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := []TaskArtifact{}
+	for rows.Next() {
+		var taskName, raw string
+		var mapIndex *int
+		if err := rows.Scan(&taskName, &mapIndex, &raw); err != nil {
+			return nil, err
+		}
+
+		artifacts, err = appendTaskArtifacts(artifacts, taskName, mapIndex, raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortTaskArtifacts(artifacts)
+	return artifacts, nil
+}
+
+func (p *postgresDAGManager) SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error {
+	value, err := json.Marshal(outputs)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.pool.Exec(ctx, `UPDATE Task_Runs SET outputs = $2 WHERE task_run_id = $1`, taskRunId, string(value))
+	return err
+}
+
+func (p *postgresDAGManager) SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error {
+	_, err := p.pool.Exec(ctx, `UPDATE Task_Runs SET retry_env = $2 WHERE task_run_id = $1`, taskRunId, envJSON)
+	return err
+}
+
+func (p *postgresDAGManager) ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (TaskClaim, error) {
+	leaseInterval := fmt.Sprintf("%d seconds", int(leaseTTL.Seconds()))
+	var c TaskClaim
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// the pool of the task is locked so the claims of other workers have settled before its free slots are read
+		if _, err := tx.Exec(ctx, `
+		SELECT p.pool_id
+		FROM Task_Runs tr
+		JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+		JOIN DAGs d ON d.dag_id = dt.dag_id
+		JOIN Pools p ON p.name = dt.pool AND p.namespace = d.namespace
+		WHERE tr.task_run_id = $1
+		FOR UPDATE OF p;`, taskRunId); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, `
+		UPDATE Task_Runs
+		SET claimed_by = $2, claimed_at = now(), lease_expires_at = now() + ($3::interval)
+		WHERE task_run_id = $1 AND status = 'pending' AND (claimed_by IS NULL OR lease_expires_at <= now()) AND (scheduled_start IS NULL OR scheduled_start <= now())
+		AND NOT EXISTS (
+			SELECT 1
+			FROM DAG_Tasks dt
+			JOIN DAGs d ON d.dag_id = dt.dag_id
+			LEFT JOIN Pool_Usage pu ON pu.name = dt.pool AND pu.namespace = d.namespace
+			WHERE dt.dag_task_id = Task_Runs.task_id AND dt.pool IS NOT NULL
+			AND (pu.pool_id IS NULL OR dt.poolSlots > pu.slots - pu.occupied_slots)
+		)
+		RETURNING task_run_id, task_id, run_id, map_index, COALESCE(map_item, '');`, taskRunId, workerId, leaseInterval).Scan(&c.TaskRunID, &c.TaskID, &c.RunID, &c.MapIndex, &c.MapItem)
+	})
+	if err != nil {
+		return TaskClaim{}, err
+	}
+	return c, nil
+}
+
+func (p *postgresDAGManager) GetTaskRunStatus(ctx context.Context, taskRunId int) (string, error) {
+	var status string
+	if err := p.pool.QueryRow(ctx, `SELECT status FROM Task_Runs WHERE task_run_id = $1`, taskRunId).Scan(&status); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrTaskRunNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
 
 func (p *postgresDAGManager) AddTask(ctx context.Context, task *v1alpha1.DagTask, namespace string) error {
 	// Begin transaction
@@ -1633,13 +2965,20 @@ func (p *postgresDAGManager) AddTask(ctx context.Context, task *v1alpha1.DagTask
 		jsonValue = &json
 	}
 
+	artifactsJson, err := artifactsColumn(task.Spec.Artifacts)
+	if err != nil {
+		return err
+	}
+
 	newVersion := version + 1
 
+	backoff := backoffColumns(task.Spec.Backoff)
 	if _, err := tx.Exec(ctx, `
-    INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version, hash)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, FALSE, $12, $13, $14);`,
+    INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version, hash, outputs, backoffInitialDelaySeconds, backoffMaxDelaySeconds, backoffMultiplier, backoffJitter, artifacts)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, FALSE, $12, $13, $14, $15, $16, $17, $18, $19, $20);`,
 		task.Name, task.Spec.Command, task.Spec.Args, task.Spec.Image, task.Spec.Parameters, task.Spec.Backoff.Limit,
-		task.Spec.Conditional.Enabled, task.Spec.Conditional.RetryCodes, jsonValue, task.Spec.Script, task.Spec.ScriptInjectorImage, namespace, newVersion, hashValue); err != nil {
+		task.Spec.Conditional.Enabled, task.Spec.Conditional.RetryCodes, jsonValue, task.Spec.Script, task.Spec.ScriptInjectorImage, namespace, newVersion, hashValue, task.Spec.Outputs,
+		backoff.initialDelaySeconds, backoff.maxDelaySeconds, backoff.multiplier, backoff.jitter, artifactsJson); err != nil {
 		return err
 	}
 
@@ -1715,6 +3054,81 @@ func (p *postgresDAGManager) DeleteTask(ctx context.Context, taskName, namespace
 	return tx.Commit(ctx)
 }
 
+func (p *postgresDAGManager) UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO Pools (name, namespace, slots)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name, namespace) DO UPDATE SET slots = EXCLUDED.slots;`, pool.Name, namespace, pool.Spec.Slots)
+	return err
+}
+
+func (p *postgresDAGManager) DeletePool(ctx context.Context, name, namespace string) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM Pools WHERE name = $1 AND namespace = $2;`, name, namespace)
+	return err
+}
+
+func (p *postgresDAGManager) GetPoolUsage(ctx context.Context) ([]PoolUsage, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT name, namespace, slots, occupied_slots, queued_slots
+		FROM Pool_Usage
+		ORDER BY namespace, name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := []PoolUsage{}
+	for rows.Next() {
+		var pool PoolUsage
+		if err := rows.Scan(&pool.Name, &pool.Namespace, &pool.Slots, &pool.OccupiedSlots, &pool.QueuedSlots); err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+
+	return pools, rows.Err()
+}
+
+func (p *postgresDAGManager) RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error) {
+	tag, err := p.pool.Exec(ctx, `
+		INSERT INTO Trigger_Events (namespace, trigger_name, event_id, dag_run_name)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (namespace, trigger_name, event_id) DO NOTHING;`, namespace, triggerName, eventId, dagRunName)
+	if err != nil {
+		return "", false, err
+	}
+
+	if tag.RowsAffected() > 0 {
+		return dagRunName, true, nil
+	}
+
+	var existing string
+	if err := p.pool.QueryRow(ctx, `
+		SELECT dag_run_name
+		FROM Trigger_Events
+		WHERE namespace = $1 AND trigger_name = $2 AND event_id = $3;`, namespace, triggerName, eventId).Scan(&existing); err != nil {
+		return "", false, err
+	}
+
+	return existing, false, nil
+}
+
+func (p *postgresDAGManager) GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error) {
+	var dagRunName string
+	err := p.pool.QueryRow(ctx, `
+		SELECT dag_run_name
+		FROM Trigger_Events
+		WHERE namespace = $1 AND trigger_name = $2 AND event_id = $3;`, namespace, triggerName, eventId).Scan(&dagRunName)
+	if err == pgx.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return dagRunName, true, nil
+}
+
 func (p *postgresDAGManager) GetWebhookDetails(ctx context.Context, dagRunID int) (*v1alpha1.Webhook, error) {
 	webhook := &v1alpha1.Webhook{}
 
@@ -1757,122 +3171,95 @@ func (p *postgresDAGManager) MarkConnectingTasksAsSuspended(ctx context.Context,
 	var taskNames []string
 
 	err := p.withTx(ctx, func(tx pgx.Tx) error {
-		// Get all dependent tasks that need to be suspended using recursive CTE
-		rows, err := tx.Query(ctx, `
-            WITH RECURSIVE 
-                existing_runs AS (
-                    SELECT task_id
-                    FROM Task_Runs
-                    WHERE run_id = $1
-                ),
-                task_chain AS (
-                    -- Base case: direct dependencies of the failed task
-                    SELECT 
-                        d.task_id,
-                        dt.name,
-                        1 as depth
-                    FROM Task_Runs tr
-                    JOIN Dependencies d ON tr.task_id = d.depends_on_task_id
-                    JOIN DAG_Tasks dt ON d.task_id = dt.dag_task_id
-                    WHERE tr.task_run_id = $2
-
-                    UNION ALL
-
-                    -- Recursive case: downstream dependencies
-                    SELECT 
-                        d.task_id,
-                        dt.name,
-                        tc.depth + 1
-                    FROM task_chain tc
-                    JOIN Dependencies d ON tc.task_id = d.depends_on_task_id
-                    JOIN DAG_Tasks dt ON d.task_id = dt.dag_task_id
-                )
-            SELECT DISTINCT task_id, name, depth
-            FROM task_chain tc
-            WHERE NOT EXISTS (
-                SELECT 1 FROM existing_runs er WHERE er.task_id = tc.task_id
-            )
-            ORDER BY depth;
-        `, dagRunId, taskRunId)
+		dagId, err := p.getDAGIdFromRun(ctx, tx, dagRunId)
+		if err != nil {
+			return fmt.Errorf("failed to get dag id: %w", err)
+		}
 
+		// the trigger rules decide which downstream tasks can no longer run
+		_, suspended, err := p.resolveTasks(ctx, tx, dagRunId, dagId, nil)
 		if err != nil {
-			return fmt.Errorf("failed to get dependent tasks: %w", err)
+			return err
+		}
+
+		if len(suspended) == 0 {
+			return nil
 		}
-		defer rows.Close()
 
-		var updates [][]interface{}
-		seen := make(map[int]struct{})
+		rows, err := tx.Query(ctx, `
+            SELECT name
+            FROM DAG_Tasks
+            WHERE dag_task_id = ANY($1);
+        `, suspended)
+		if err != nil {
+			return fmt.Errorf("failed to get task names: %w", err)
+		}
+		defer rows.Close()
 
 		for rows.Next() {
-			var taskID int
 			var taskName string
-			var depth int
-			if err := rows.Scan(&taskID, &taskName, &depth); err != nil {
+			if err := rows.Scan(&taskName); err != nil {
 				return fmt.Errorf("failed to scan task: %w", err)
 			}
-
-			if _, exists := seen[taskID]; !exists {
-				seen[taskID] = struct{}{}
-				updates = append(updates, []interface{}{dagRunId, taskID, "suspended", 0})
-				taskNames = append(taskNames, taskName)
-			}
+			taskNames = append(taskNames, taskName)
 		}
 
-		if err = rows.Err(); err != nil {
-			return fmt.Errorf("error iterating tasks: %w", err)
-		}
+		return rows.Err()
+	})
 
-		// Early return if no tasks to suspend
-		if len(updates) == 0 {
-			return nil
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		// Batch insert suspended tasks
-		_, err = tx.CopyFrom(
-			ctx,
-			pgx.Identifier{"task_runs"},
-			[]string{"run_id", "task_id", "status", "attempts"},
-			pgx.CopyFromRows(updates),
-		)
+	return taskNames, nil
+}
+
+func (p *postgresDAGManager) GetReadyTasks(ctx context.Context, dagRunId int) ([]Task, error) {
+	var tasks []Task
+
+	if err := p.withTx(ctx, func(tx pgx.Tx) error {
+		dagId, err := p.getDAGIdFromRun(ctx, tx, dagRunId)
 		if err != nil {
-			return fmt.Errorf("failed to insert suspended tasks: %w", err)
+			return err
 		}
 
-		// Update suspended count
-		if _, err := tx.Exec(ctx, `
-            UPDATE DAG_Runs
-            SET suspendedCount = suspendedCount + $1
-            WHERE run_id = $2
-        `, len(updates), dagRunId); err != nil {
-			return fmt.Errorf("failed to update suspended count: %w", err)
+		var parameters [][]string
+		tasks, parameters, err = p.getNextRunnableTasks(ctx, tx, dagRunId, dagId)
+		if err != nil {
+			return err
 		}
 
-		return nil
-	})
+		if len(tasks) == 0 {
+			if _, err := p.markRunSuccessIfComplete(ctx, tx, dagRunId); err != nil {
+				return err
+			}
+		}
 
-	if err != nil {
+		return p.fetchTaskParameters(ctx, tx, dagId, dagRunId, tasks, parameters)
+	}); err != nil {
 		return nil, err
 	}
 
-	return taskNames, nil
+	return tasks, nil
 }
 
 func (p *postgresDAGManager) CheckIfAllTasksDone(ctx context.Context, dagRunID int) (bool, error) {
-	var taskCount, successCount, failedCount, suspendedCount int
+	var taskCount, successCount, failedCount, suspendedCount, skippedCount int
 	err := p.pool.QueryRow(ctx, `
         SELECT 
             (SELECT COUNT(*) FROM DAG_Tasks WHERE dag_id = dr.dag_id) as task_count,
             dr.successfulCount,
             dr.failedCount,
-            dr.suspendedCount
+            dr.suspendedCount,
+            dr.skippedCount
         FROM DAG_Runs dr
         WHERE dr.run_id = $1;
-    `, dagRunID).Scan(&taskCount, &successCount, &failedCount, &suspendedCount)
+    `, dagRunID).Scan(&taskCount, &successCount, &failedCount, &suspendedCount, &skippedCount)
 	if err != nil {
 		return false, err
 	}
 
-	return taskCount == successCount+failedCount+suspendedCount, nil
+	return taskCount == successCount+failedCount+suspendedCount+skippedCount, nil
 }
 
 func (p *postgresDAGManager) AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error {
@@ -1972,3 +3359,600 @@ func (p *postgresDAGManager) GetTaskRunInfo(ctx context.Context, taskRunId int)
 	}
 	return dagName, taskName, namespace, nil
 }
+
+func (p *postgresDAGManager) StartSubDagRuns(ctx context.Context) ([]SubDagRun, error) {
+	subDagRuns := []SubDagRun{}
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			UPDATE Task_Runs
+			SET claimed_at = NOW(),
+				lease_expires_at = NOW() + $1::integer * INTERVAL '1 second'
+			WHERE task_run_id IN (
+				SELECT task_run_id
+				FROM Task_Runs
+				WHERE status = 'pending_dag'
+				AND (lease_expires_at IS NULL OR lease_expires_at <= NOW())
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING task_run_id, run_id, task_id, priority;`, subDagLeaseSeconds)
+		if err != nil {
+			return err
+		}
+
+		taskIds := []int{}
+		for rows.Next() {
+			var subDagRun SubDagRun
+			var taskId int
+			if err := rows.Scan(&subDagRun.TaskRunId, &subDagRun.RunId, &taskId, &subDagRun.Priority); err != nil {
+				rows.Close()
+				return err
+			}
+			subDagRuns = append(subDagRuns, subDagRun)
+			taskIds = append(taskIds, taskId)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range subDagRuns {
+			var dagId int
+			var refsJSON []byte
+			if err := tx.QueryRow(ctx, `
+				SELECT dt.dag_id, d.namespace, dt.dagRefName, COALESCE(dt.dagRefParameters, '[]'::jsonb)
+				FROM DAG_Tasks dt
+				JOIN DAGs d ON d.dag_id = dt.dag_id
+				WHERE dt.dag_task_id = $1;`, taskIds[i]).Scan(&dagId, &subDagRuns[i].Namespace, &subDagRuns[i].DagName, &refsJSON); err != nil {
+				return err
+			}
+
+			refs := []v1alpha1.DagRefParameter{}
+			if err := json.Unmarshal(refsJSON, &refs); err != nil {
+				return err
+			}
+
+			parent, err := p.getRunParameters(ctx, tx, subDagRuns[i].RunId, dagId)
+			if err != nil {
+				return err
+			}
+
+			subDagRuns[i].Parameters = subDagParameters(refs, parent)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return subDagRuns, nil
+}
+
+// getRunParameters returns every parameter of a run, values given to the run overriding the defaults of the DAG
+func (p *postgresDAGManager) getRunParameters(ctx context.Context, tx pgx.Tx, runId, dagId int) (map[string]Parameter, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT name, defaultValue, isSecret, isConfigMap, COALESCE(valueKey, ''), 0 AS priority FROM DAG_Parameters WHERE dag_id = $1
+		UNION ALL
+		SELECT name, value, isSecret, isConfigMap, COALESCE(valueKey, ''), 1 AS priority FROM DAG_Run_Parameters WHERE run_id = $2
+		ORDER BY priority;`, dagId, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	params := map[string]Parameter{}
+	for rows.Next() {
+		var param Parameter
+		var priority int
+		if err := rows.Scan(&param.Name, &param.Value, &param.IsSecret, &param.IsConfigMap, &param.Key, &priority); err != nil {
+			return nil, err
+		}
+		params[param.Name] = param
+	}
+
+	return params, rows.Err()
+}
+
+func (p *postgresDAGManager) LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error {
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			UPDATE DAG_Runs
+			SET parent_task_run_id = $2,
+				parent_run_id = (SELECT run_id FROM Task_Runs WHERE task_run_id = $2)
+			WHERE run_id = $1;`, runId, parentTaskRunId); err != nil {
+			return err
+		}
+
+		// the dagRef task only runs once its child run exists
+		_, err := tx.Exec(ctx, `
+			UPDATE Task_Runs
+			SET status = 'running', claimed_at = NULL, lease_expires_at = NULL
+			WHERE task_run_id = $1 AND status = 'pending_dag';`, parentTaskRunId)
+		return err
+	})
+}
+
+func (p *postgresDAGManager) GetFinishedSubDagRuns(ctx context.Context) ([]FinishedSubDagRun, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT tr.task_run_id, tr.run_id, child.run_id, child.status, parentDag.namespace, parent.pvcName
+		FROM Task_Runs tr
+		JOIN DAG_Runs child ON child.parent_task_run_id = tr.task_run_id
+		JOIN DAGs childDag ON childDag.dag_id = child.dag_id
+		JOIN DAG_Runs parent ON parent.run_id = tr.run_id
+		JOIN DAGs parentDag ON parentDag.dag_id = parent.dag_id
+		WHERE tr.status = 'running'
+		AND (child.status = 'timed_out' OR childDag.taskCount = child.successfulCount + child.failedCount + child.suspendedCount + child.skippedCount);`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	finished := []FinishedSubDagRun{}
+	for rows.Next() {
+		var run FinishedSubDagRun
+		var status string
+		if err := rows.Scan(&run.TaskRunId, &run.RunId, &run.ChildRunId, &status, &run.Namespace, &run.PVCName); err != nil {
+			return nil, err
+		}
+
+		run.Succeeded = status == "success"
+		finished = append(finished, run)
+	}
+
+	return finished, rows.Err()
+}
+
+func (p *postgresDAGManager) ClaimFinishedDagRuns(ctx context.Context) ([]DownstreamDagRun, error) {
+	downstream := []DownstreamDagRun{}
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// a failed run is only finished once the tasks it still has running are done
+		rows, err := tx.Query(ctx, `
+			UPDATE DAG_Runs
+			SET completion_lease_expires_at = now() + $1::integer * INTERVAL '1 second'
+			WHERE run_id IN (
+				SELECT r.run_id
+				FROM DAG_Runs r
+				JOIN DAGs d ON d.dag_id = r.dag_id
+				WHERE r.completion_handled = FALSE
+				AND (r.completion_lease_expires_at IS NULL OR r.completion_lease_expires_at <= now())
+				AND (r.status IN ('timed_out', 'suspended') OR (r.status IN ('success', 'failed')
+					AND d.taskCount = r.successfulCount + r.failedCount + r.suspendedCount + r.skippedCount))
+				FOR UPDATE OF r SKIP LOCKED
+			)
+			RETURNING run_id, dag_id, name, status;`, completionLeaseSeconds)
+		if err != nil {
+			return err
+		}
+
+		finished := []finishedDagRun{}
+		for rows.Next() {
+			var run finishedDagRun
+			if err := rows.Scan(&run.runId, &run.dagId, &run.name, &run.status); err != nil {
+				rows.Close()
+				return err
+			}
+			finished = append(finished, run)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, run := range finished {
+			triggers, err := p.getCompletionTriggers(ctx, tx, run.dagId)
+			if err != nil {
+				return err
+			}
+
+			// a run starting nothing is handled straight away
+			matched := matchingCompletionTriggers(triggers, run.status)
+			if len(matched) == 0 {
+				if _, err := tx.Exec(ctx, `
+					UPDATE DAG_Runs
+					SET completion_handled = TRUE, completion_lease_expires_at = NULL
+					WHERE run_id = $1;`, run.runId); err != nil {
+					return err
+				}
+				continue
+			}
+
+			upstream, err := p.getRunParameters(ctx, tx, run.runId, run.dagId)
+			if err != nil {
+				return err
+			}
+
+			downstream = append(downstream, downstreamDagRuns(run, matched, upstream)...)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return downstream, nil
+}
+
+// getCompletionTriggers returns the onDagCompletion triggers of the active, unsuspended DAGs waiting for a DAG
+func (p *postgresDAGManager) getCompletionTriggers(ctx context.Context, tx pgx.Tx, upstreamDagId int) ([]completionTrigger, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT ct.trigger_id, d.name, d.namespace, up.namespace, ct.outcome, COALESCE(ct.parameters, '[]'::jsonb)
+		FROM DAGs up
+		JOIN DAG_Completion_Triggers ct ON ct.upstream_name = up.name AND ct.upstream_namespace = up.namespace
+		JOIN DAGs d ON d.dag_id = ct.dag_id
+		WHERE up.dag_id = $1 AND d.active = TRUE AND d.suspended = FALSE
+		ORDER BY ct.trigger_id;`, upstreamDagId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []completionTrigger{}
+	for rows.Next() {
+		var trigger completionTrigger
+		var outcome string
+		var paramsJSON []byte
+		if err := rows.Scan(&trigger.triggerId, &trigger.dagName, &trigger.namespace, &trigger.upstreamNamespace, &outcome, &paramsJSON); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(paramsJSON, &trigger.parameters); err != nil {
+			return nil, err
+		}
+		trigger.outcome = v1alpha1.DagCompletionOutcome(outcome)
+		triggers = append(triggers, trigger)
+	}
+
+	return triggers, rows.Err()
+}
+
+func (p *postgresDAGManager) MarkDagRunCompletionHandled(ctx context.Context, runId int) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE DAG_Runs
+		SET completion_handled = TRUE, completion_lease_expires_at = NULL
+		WHERE run_id = $1;`, runId)
+	return err
+}
+
+// getDagCompletionTriggers returns the upstream DAGs the onDagCompletion triggers of each active DAG wait for
+func (p *postgresDAGManager) getDagCompletionTriggers(ctx context.Context, tx pgx.Tx) (map[types.NamespacedName][]types.NamespacedName, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT d.name, d.namespace, ct.upstream_name, ct.upstream_namespace
+		FROM DAG_Completion_Triggers ct
+		JOIN DAGs d ON d.dag_id = ct.dag_id
+		WHERE d.active = TRUE;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	upstreams := map[types.NamespacedName][]types.NamespacedName{}
+	for rows.Next() {
+		var dag, upstream types.NamespacedName
+		if err := rows.Scan(&dag.Name, &dag.Namespace, &upstream.Name, &upstream.Namespace); err != nil {
+			return nil, err
+		}
+		upstreams[dag] = append(upstreams[dag], upstream)
+	}
+
+	return upstreams, rows.Err()
+}
+
+func (p *postgresDAGManager) TimeOutTaskRuns(ctx context.Context) ([]StoppedTaskRun, error) {
+	timedOut := []StoppedTaskRun{}
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			UPDATE Task_Runs
+			SET status = 'timed_out', claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
+			WHERE task_run_id IN (
+				SELECT task_run_id
+				FROM Task_Runs
+				WHERE status IN ('pending', 'pending_dag', 'running', 'waiting')
+				AND timeout_at <= NOW()
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING task_run_id, run_id, task_id;`)
+		if err != nil {
+			return err
+		}
+
+		taskIds := []int{}
+		for rows.Next() {
+			var run StoppedTaskRun
+			var taskId int
+			if err := rows.Scan(&run.TaskRunId, &run.RunId, &taskId); err != nil {
+				rows.Close()
+				return err
+			}
+			timedOut = append(timedOut, run)
+			taskIds = append(taskIds, taskId)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// the instances of a map task time out together and count as one failed task
+		counted := map[[2]int]bool{}
+		for i := range timedOut {
+			if err := p.describeStoppedTaskRun(ctx, tx, &timedOut[i], taskIds[i]); err != nil {
+				return err
+			}
+
+			key := [2]int{timedOut[i].RunId, taskIds[i]}
+			if counted[key] {
+				continue
+			}
+			counted[key] = true
+
+			if _, err := tx.Exec(ctx, `
+				UPDATE DAG_Runs
+				SET failedCount = failedCount + 1,
+					status = CASE WHEN status = 'timed_out' THEN status ELSE 'failed' END
+				WHERE run_id = $1;`, timedOut[i].RunId); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return timedOut, nil
+}
+
+func (p *postgresDAGManager) TimeOutDagRuns(ctx context.Context) ([]StoppedDagRun, error) {
+	timedOut := []StoppedDagRun{}
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		// a failed run can still have tasks left running, e.g. ones with the all_done trigger rule
+		rows, err := tx.Query(ctx, `
+			UPDATE DAG_Runs
+			SET status = 'timed_out'
+			WHERE timeout_at <= NOW()
+			AND (status = 'running' OR (status = 'failed' AND EXISTS (
+				SELECT 1 FROM Task_Runs tr
+				WHERE tr.run_id = DAG_Runs.run_id
+				AND tr.status IN ('pending', 'pending_dag', 'running', 'waiting')
+			)))
+			RETURNING run_id, pvcName;`)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var run StoppedDagRun
+			if err := rows.Scan(&run.RunId, &run.PVCName); err != nil {
+				rows.Close()
+				return err
+			}
+			timedOut = append(timedOut, run)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return p.stopDagRunTasks(ctx, tx, timedOut, "timed_out")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return timedOut, nil
+}
+
+// stopDagRunTasks fills in the names of runs that were stopped and gives their active task runs the given status
+func (p *postgresDAGManager) stopDagRunTasks(ctx context.Context, tx pgx.Tx, runs []StoppedDagRun, status string) error {
+	for i := range runs {
+		if err := tx.QueryRow(ctx, `
+			SELECT d.name, d.namespace
+			FROM DAG_Runs r
+			JOIN DAGs d ON d.dag_id = r.dag_id
+			WHERE r.run_id = $1;`, runs[i].RunId).Scan(&runs[i].DagName, &runs[i].Namespace); err != nil {
+			return err
+		}
+
+		taskRuns, err := p.stopActiveTaskRuns(ctx, tx, runs[i].RunId, status)
+		if err != nil {
+			return err
+		}
+
+		runs[i].TaskRuns = taskRuns
+	}
+
+	return nil
+}
+
+// stopActiveTaskRuns marks every task run of a run that has not finished with the given status
+func (p *postgresDAGManager) stopActiveTaskRuns(ctx context.Context, tx pgx.Tx, runId int, status string) ([]StoppedTaskRun, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE Task_Runs
+		SET status = $2, claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
+		WHERE run_id = $1
+		AND status IN ('pending', 'pending_dag', 'running', 'waiting')
+		RETURNING task_run_id, task_id;`, runId, status)
+	if err != nil {
+		return nil, err
+	}
+
+	taskRuns := []StoppedTaskRun{}
+	taskIds := []int{}
+	for rows.Next() {
+		run := StoppedTaskRun{RunId: runId}
+		var taskId int
+		if err := rows.Scan(&run.TaskRunId, &taskId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		taskRuns = append(taskRuns, run)
+		taskIds = append(taskIds, taskId)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range taskRuns {
+		if err := p.describeStoppedTaskRun(ctx, tx, &taskRuns[i], taskIds[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return taskRuns, nil
+}
+
+// describeStoppedTaskRun fills in the names and pods of a task run that was stopped
+func (p *postgresDAGManager) describeStoppedTaskRun(ctx context.Context, tx pgx.Tx, run *StoppedTaskRun, taskId int) error {
+	if err := tx.QueryRow(ctx, `
+		SELECT dt.name, d.name, d.namespace, r.pvcName
+		FROM DAG_Tasks dt
+		JOIN DAG_Runs r ON r.run_id = $1
+		JOIN DAGs d ON d.dag_id = r.dag_id
+		WHERE dt.dag_task_id = $2;`, run.RunId, taskId).Scan(&run.TaskName, &run.DagName, &run.Namespace, &run.PVCName); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT name, namespace
+		FROM Task_Pods
+		WHERE task_run_id = $1;`, run.TaskRunId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	run.Pods = []RunningPodInfo{}
+	for rows.Next() {
+		var pod RunningPodInfo
+		if err := rows.Scan(&pod.Name, &pod.Namespace); err != nil {
+			return err
+		}
+		run.Pods = append(run.Pods, pod)
+	}
+
+	return rows.Err()
+}
+
+func (p *postgresDAGManager) GetDagRunSummary(ctx context.Context, runId int) (*DagRunSummary, error) {
+	summary := &DagRunSummary{RunId: runId, TaskRuns: map[string]int{}}
+
+	var runTime time.Time
+	if err := p.pool.QueryRow(ctx, `
+		SELECT status, run_time
+		FROM DAG_Runs
+		WHERE run_id = $1;`, runId).Scan(&summary.Status, &runTime); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrDagRunNotFound
+		}
+		return nil, err
+	}
+
+	if summary.Status != "queued" {
+		summary.StartTime = &runTime
+	}
+
+	// a retry adds a task run, only the latest one of each task and map item is counted
+	rows, err := p.pool.Query(ctx, `
+		SELECT tr.status, COUNT(*)
+		FROM Task_Runs tr
+		WHERE tr.run_id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM Task_Runs later
+			WHERE later.run_id = tr.run_id
+			AND later.task_id = tr.task_id
+			AND later.map_index IS NOT DISTINCT FROM tr.map_index
+			AND later.task_run_id > tr.task_run_id
+		)
+		GROUP BY tr.status;`, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		summary.TaskRuns[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !summary.finished() {
+		return summary, nil
+	}
+
+	var podTime *time.Time
+	if err := p.pool.QueryRow(ctx, `
+		SELECT MAX(tp.updated_at)
+		FROM Task_Pods tp
+		JOIN Task_Runs tr ON tr.task_run_id = tp.task_run_id
+		WHERE tr.run_id = $1;`, runId).Scan(&podTime); err != nil {
+		return nil, err
+	}
+
+	finishTime := runTime
+	if podTime != nil && podTime.After(finishTime) {
+		finishTime = *podTime
+	}
+
+	summary.FinishTime = &finishTime
+	return summary, nil
+}
+
+func (p *postgresDAGManager) GetDagStatus(ctx context.Context, dagName, namespace string) (*DagStatus, error) {
+	status := &DagStatus{}
+	var schedule string
+	if err := p.pool.QueryRow(ctx, `
+		SELECT version, hash, schedule, nexttime
+		FROM DAGs
+		WHERE name = $1 AND namespace = $2
+		ORDER BY version DESC
+		LIMIT 1;`, dagName, namespace).Scan(&status.Version, &status.Hash, &schedule, &status.NextTime); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrDagNotFound
+		}
+		return nil, err
+	}
+
+	if schedule == "" {
+		status.NextTime = nil
+	}
+
+	lastRun := &DagRunRef{}
+	err := p.pool.QueryRow(ctx, `
+		SELECT r.run_id, r.name
+		FROM DAG_Runs r
+		JOIN DAGs d ON d.dag_id = r.dag_id
+		WHERE d.name = $1 AND d.namespace = $2
+		ORDER BY r.run_id DESC
+		LIMIT 1;`, dagName, namespace).Scan(&lastRun.RunId, &lastRun.Name)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+
+	if err == nil {
+		status.LastRun = lastRun
+	}
+
+	return status, nil
+}
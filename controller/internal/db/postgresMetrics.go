@@ -87,6 +87,7 @@ func (m *metricsPostgresDAGManager) collectContentMetrics() {
 
 	// Collect initial metrics
 	m.updateContentMetrics(logger)
+	m.updatePoolMetrics(logger)
 
 	for {
 		select {
@@ -95,10 +96,29 @@ func (m *metricsPostgresDAGManager) collectContentMetrics() {
 			return
 		case <-ticker.C:
 			m.updateContentMetrics(logger)
+			m.updatePoolMetrics(logger)
 		}
 	}
 }
 
+// updatePoolMetrics collects and updates the occupancy of every pool
+func (m *metricsPostgresDAGManager) updatePoolMetrics(logger logr.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pools, err := m.postgresDAGManager.GetPoolUsage(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to collect pool metrics")
+		metrics.RecordErrorMetrics("postgresql", "collect_content_metrics", "pool_usage_error")
+		return
+	}
+
+	metrics.ResetPoolMetrics()
+	for _, pool := range pools {
+		metrics.UpdatePoolMetrics(pool.Namespace, pool.Name, pool.Slots, pool.OccupiedSlots, pool.QueuedSlots)
+	}
+}
+
 // updateContentMetrics collects and updates database content metrics
 func (m *metricsPostgresDAGManager) updateContentMetrics(logger logr.Logger) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -170,18 +190,19 @@ func (m *metricsPostgresDAGManager) updateContentMetrics(logger logr.Logger) {
 		}
 
 		// Collect task run metrics for this namespace
-		var runningTasks, successTasks, failedTasks, pendingTasks int
+		var runningTasks, successTasks, cachedTasks, failedTasks, pendingTasks int
 		taskRunErr := m.pool.QueryRow(ctx, `
 			SELECT 
 				COUNT(CASE WHEN tr.status = 'running' THEN 1 END) as running,
 				COUNT(CASE WHEN tr.status = 'success' THEN 1 END) as success,
+				COUNT(CASE WHEN tr.status = 'cached' THEN 1 END) as cached,
 				COUNT(CASE WHEN tr.status = 'failed' THEN 1 END) as failed,
 				COUNT(CASE WHEN tr.status = 'pending' THEN 1 END) as pending
 			FROM Task_Runs tr
 			JOIN DAG_Runs dr ON tr.run_id = dr.run_id
 			JOIN DAGs d ON dr.dag_id = d.dag_id
 			WHERE d.namespace = $1
-		`, namespace).Scan(&runningTasks, &successTasks, &failedTasks, &pendingTasks)
+		`, namespace).Scan(&runningTasks, &successTasks, &cachedTasks, &failedTasks, &pendingTasks)
 
 		if taskRunErr != nil {
 			logger.Error(taskRunErr, "Failed to collect task run metrics", "namespace", namespace)
@@ -189,11 +210,12 @@ func (m *metricsPostgresDAGManager) updateContentMetrics(logger logr.Logger) {
 			continue
 		}
 
-		log.Log.Info("Task run metrics collected", "namespace", namespace, "running", runningTasks, "success", successTasks, "failed", failedTasks, "pending", pendingTasks)
+		log.Log.Info("Task run metrics collected", "namespace", namespace, "running", runningTasks, "success", successTasks, "cached", cachedTasks, "failed", failedTasks, "pending", pendingTasks)
 
 		taskRunCounts := map[string]int{
 			"running": runningTasks,
 			"success": successTasks,
+			"cached":  cachedTasks,
 			"failed":  failedTasks,
 			"pending": pendingTasks,
 		}
@@ -256,6 +278,13 @@ func (m *metricsPostgresDAGManager) GetDAGsToStartAndUpdate(ctx context.Context,
 	return result, err
 }
 
+func (m *metricsPostgresDAGManager) GetBackfillsToStartAndUpdate(ctx context.Context) ([]*DagInfo, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.GetBackfillsToStartAndUpdate(ctx)
+	m.recordTransactionMetrics("get_backfills_to_start_and_update", start, err)
+	return result, err
+}
+
 func (m *metricsPostgresDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, namespace string) error {
 	start := time.Now()
 	err := m.postgresDAGManager.InsertDAG(ctx, dag, namespace)
@@ -270,6 +299,20 @@ func (m *metricsPostgresDAGManager) CreateDAGRun(ctx context.Context, name strin
 	return result, err
 }
 
+func (m *metricsPostgresDAGManager) StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (DagRunAdmission, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.StartDAGRun(ctx, name, dag, parameters, pvcName)
+	m.recordTransactionMetrics("start_dag_run", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) StartQueuedDAGRuns(ctx context.Context) ([]QueuedDagRun, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.StartQueuedDAGRuns(ctx)
+	m.recordTransactionMetrics("start_queued_dag_runs", start, err)
+	return result, err
+}
+
 func (m *metricsPostgresDAGManager) TaskRunExists(ctx context.Context, runId, dagTaskId int) (bool, error) {
 	start := time.Now()
 	result, err := m.postgresDAGManager.TaskRunExists(ctx, runId, dagTaskId)
@@ -305,6 +348,20 @@ func (m *metricsPostgresDAGManager) MarkSuccessAndGetNextTasks(ctx context.Conte
 	return result, err
 }
 
+func (m *metricsPostgresDAGManager) CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []Task, error) {
+	start := time.Now()
+	cached, result, err := m.postgresDAGManager.CompleteTaskRunFromCache(ctx, taskRunId, workerId, cacheKey)
+	m.recordTransactionMetrics("complete_task_run_from_cache", start, err)
+	return cached, result, err
+}
+
+func (m *metricsPostgresDAGManager) InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.InvalidateTaskCache(ctx, dagName, namespace, taskNames)
+	m.recordQueryMetrics("delete", "task_cache", start, err)
+	return result, err
+}
+
 func (m *metricsPostgresDAGManager) MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error {
 	start := time.Now()
 	err := m.postgresDAGManager.MarkDAGRunOutcome(ctx, dagRunId, outcome)
@@ -417,6 +474,13 @@ func (m *metricsPostgresDAGManager) MarkConnectingTasksAsSuspended(ctx context.C
 	return result, err
 }
 
+func (m *metricsPostgresDAGManager) GetReadyTasks(ctx context.Context, dagRunId int) ([]Task, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.GetReadyTasks(ctx, dagRunId)
+	m.recordTransactionMetrics("get_ready_tasks", start, err)
+	return result, err
+}
+
 func (m *metricsPostgresDAGManager) AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error {
 	start := time.Now()
 	err := m.postgresDAGManager.AddPodDuration(ctx, taskRunId, durationSec)
@@ -467,6 +531,13 @@ func (m *metricsPostgresDAGManager) RenewLease(ctx context.Context, taskRunId in
 	return err
 }
 
+func (m *metricsPostgresDAGManager) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	start := time.Now()
+	deferred, err := m.postgresDAGManager.DeferSensor(ctx, taskRunId, workerId, interval, timeout)
+	m.recordQueryMetrics("update", "task_runs", start, err)
+	return deferred, err
+}
+
 func (m *metricsPostgresDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
 	start := time.Now()
 	err := m.postgresDAGManager.FinalizeClaimToRunning(ctx, taskRunId, workerId, podUID)
@@ -488,6 +559,13 @@ func (m *metricsPostgresDAGManager) SaveRetryEnv(ctx context.Context, taskRunId
 	return err
 }
 
+func (m *metricsPostgresDAGManager) SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error {
+	start := time.Now()
+	err := m.postgresDAGManager.SaveTaskOutputs(ctx, taskRunId, outputs)
+	m.recordQueryMetrics("update", "task_runs", start, err)
+	return err
+}
+
 func (m *metricsPostgresDAGManager) ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (TaskClaim, error) {
 	start := time.Now()
 	result, err := m.postgresDAGManager.ClaimTaskByID(ctx, taskRunId, workerId, leaseTTL)
@@ -502,6 +580,104 @@ func (m *metricsPostgresDAGManager) GetTaskRunStatus(ctx context.Context, taskRu
 	return result, err
 }
 
+func (m *metricsPostgresDAGManager) StartSubDagRuns(ctx context.Context) ([]SubDagRun, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.StartSubDagRuns(ctx)
+	m.recordTransactionMetrics("start_sub_dag_runs", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error {
+	start := time.Now()
+	err := m.postgresDAGManager.LinkSubDagRun(ctx, runId, parentTaskRunId)
+	m.recordQueryMetrics("update", "dag_runs", start, err)
+	return err
+}
+
+func (m *metricsPostgresDAGManager) GetFinishedSubDagRuns(ctx context.Context) ([]FinishedSubDagRun, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.GetFinishedSubDagRuns(ctx)
+	m.recordQueryMetrics("select", "dag_runs", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) ClaimFinishedDagRuns(ctx context.Context) ([]DownstreamDagRun, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.ClaimFinishedDagRuns(ctx)
+	m.recordTransactionMetrics("claim_finished_dag_runs", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) MarkDagRunCompletionHandled(ctx context.Context, runId int) error {
+	start := time.Now()
+	err := m.postgresDAGManager.MarkDagRunCompletionHandled(ctx, runId)
+	m.recordQueryMetrics("update", "dag_runs", start, err)
+	return err
+}
+
+func (m *metricsPostgresDAGManager) TimeOutTaskRuns(ctx context.Context) ([]StoppedTaskRun, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.TimeOutTaskRuns(ctx)
+	m.recordTransactionMetrics("time_out_task_runs", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) TimeOutDagRuns(ctx context.Context) ([]StoppedDagRun, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.TimeOutDagRuns(ctx)
+	m.recordTransactionMetrics("time_out_dag_runs", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) GetDagRunSummary(ctx context.Context, runId int) (*DagRunSummary, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.GetDagRunSummary(ctx, runId)
+	m.recordQueryMetrics("select", "dag_runs", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) GetDagStatus(ctx context.Context, dagName, namespace string) (*DagStatus, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.GetDagStatus(ctx, dagName, namespace)
+	m.recordQueryMetrics("select", "dags", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error {
+	start := time.Now()
+	err := m.postgresDAGManager.UpsertPool(ctx, pool, namespace)
+	m.recordQueryMetrics("insert", "pools", start, err)
+	return err
+}
+
+func (m *metricsPostgresDAGManager) DeletePool(ctx context.Context, name, namespace string) error {
+	start := time.Now()
+	err := m.postgresDAGManager.DeletePool(ctx, name, namespace)
+	m.recordQueryMetrics("delete", "pools", start, err)
+	return err
+}
+
+func (m *metricsPostgresDAGManager) GetPoolUsage(ctx context.Context) ([]PoolUsage, error) {
+	start := time.Now()
+	result, err := m.postgresDAGManager.GetPoolUsage(ctx)
+	m.recordQueryMetrics("select", "pools", start, err)
+	return result, err
+}
+
+func (m *metricsPostgresDAGManager) RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error) {
+	start := time.Now()
+	result, created, err := m.postgresDAGManager.RecordTriggerEvent(ctx, namespace, triggerName, eventId, dagRunName)
+	m.recordQueryMetrics("insert", "trigger_events", start, err)
+	return result, created, err
+}
+
+func (m *metricsPostgresDAGManager) GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error) {
+	start := time.Now()
+	result, found, err := m.postgresDAGManager.GetTriggerEvent(ctx, namespace, triggerName, eventId)
+	m.recordQueryMetrics("select", "trigger_events", start, err)
+	return result, found, err
+}
+
 func (m *metricsPostgresDAGManager) AddPendingTaskRun(ctx context.Context, runId int, dagTaskId int) (int, error) {
 	start := time.Now()
 	result, err := m.postgresDAGManager.AddPendingTaskRun(ctx, runId, dagTaskId)
@@ -509,9 +685,16 @@ func (m *metricsPostgresDAGManager) AddPendingTaskRun(ctx context.Context, runId
 	return result, err
 }
 
-func (m *metricsPostgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTaskId int) (Task, string, string, error) {
+func (m *metricsPostgresDAGManager) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	start := time.Now()
+	result, delay, err := m.postgresDAGManager.RetryTaskRun(ctx, taskRunId)
+	m.recordQueryMetrics("insert", "task_runs", start, err)
+	return result, delay, err
+}
+
+func (m *metricsPostgresDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTaskId int, taskRunId int) (Task, string, string, error) {
 	start := time.Now()
-	resultTask, namespace, retry, err := m.postgresDAGManager.GetTaskForRun(ctx, runId, dagTaskId)
+	resultTask, namespace, retry, err := m.postgresDAGManager.GetTaskForRun(ctx, runId, dagTaskId, taskRunId)
 	m.recordQueryMetrics("select", "tasks", start, err)
 	return resultTask, namespace, retry, err
 }
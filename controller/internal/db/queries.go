@@ -9,8 +9,8 @@ const (
 		ORDER BY version DESC;`
 
 	QueryInsertDAG = `
-		INSERT INTO DAGs (name, version, hash, schedule, namespace, active, nexttime, taskCount, webhookUrl, sslVerification, workspaceEnabled, suspended) 
-		VALUES ($1, $2, $3, $4, $5, TRUE, $6, $7, $8, $9, $10, $11)
+		INSERT INTO DAGs (name, version, hash, schedule, namespace, active, nexttime, taskCount, webhookUrl, sslVerification, workspaceEnabled, suspended, runTimeoutSeconds, concurrencyPolicy, maxActiveRuns, catchup, maxCatchupRuns, timezone, startTime, endTime, priority) 
+		VALUES ($1, $2, $3, $4, $5, TRUE, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 		RETURNING dag_id;`
 
 	QueryInsertWorkspace = `
@@ -18,8 +18,8 @@ const (
 		VALUES ($1, $2, $3, $4, $5, $6);`
 
 	QueryInsertParameter = `
-		INSERT INTO DAG_Parameters (dag_id, name, isSecret, defaultValue) 
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO DAG_Parameters (dag_id, name, isSecret, isConfigMap, valueKey, defaultValue, parameterSchema) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
 	QueryGetTaskByRef = `
 		SELECT task_id FROM Tasks
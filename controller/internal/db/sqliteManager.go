@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strings"
 	"time"
 
 	"kontroler-controller/api/v1alpha1"
 	"kontroler-controller/internal/db/migrations"
+	"kontroler-controller/pkg/conditions"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -135,7 +137,64 @@ func (s *sqliteDAGManager) withTx(ctx context.Context, fn func(*sql.Tx) error) e
 }
 
 func (s *sqliteDAGManager) InitaliseDatabase(ctx context.Context) error {
-	return s.migrations.MigrateUp(ctx)
+	if err := s.migrations.MigrateUp(ctx); err != nil {
+		return err
+	}
+
+	return s.refreshZonedNextTimes(ctx)
+}
+
+// refreshZonedNextTimes works out again the upcoming next run time of the scheduled DAGs with a time zone,
+// so times stored by an earlier release, or under older time zone rules, follow the zone as it is now.
+// Times that are already due are left for GetDAGsToStartAndUpdate to start
+func (s *sqliteDAGManager) refreshZonedNextTimes(ctx context.Context) error {
+	now := time.Now()
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT dag_id, schedule, timezone, startTime, endTime
+			FROM DAGs
+			WHERE active = 1 AND schedule != '' AND COALESCE(timezone, '') != '' AND nexttime > ?;`, now.UTC().Format("2006-01-02 15:04:05"))
+		if err != nil {
+			return err
+		}
+
+		type zonedDag struct {
+			dagId    int
+			schedule string
+			timezone string
+			window   scheduleWindow
+		}
+
+		dags := []zonedDag{}
+		for rows.Next() {
+			var dag zonedDag
+			if err := rows.Scan(&dag.dagId, &dag.schedule, &dag.timezone, &dag.window.start, &dag.window.end); err != nil {
+				rows.Close()
+				return err
+			}
+			dags = append(dags, dag)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, dag := range dags {
+			sched, err := parseSchedule(s.parser, dag.schedule, dag.timezone)
+			if err != nil {
+				// the scheduler reports the schedule once the DAG is due
+				log.Log.Error(err, "failed to parse schedule of dag", "dagId", dag.dagId, "timezone", dag.timezone)
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `UPDATE DAGs SET nexttime = ? WHERE dag_id = ?;`, nextTimeColumn(dag.window.next(sched, now)), dag.dagId); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
 func (s *sqliteDAGManager) GetID(ctx context.Context) (string, error) {
@@ -168,10 +227,10 @@ func (s *sqliteDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm time.
 	defer tx.Rollback()
 
 	rows, err := tx.Query(`
-        SELECT dag_id, name, schedule, namespace, workspaceEnabled
+        SELECT dag_id, name, schedule, namespace, workspaceEnabled, nexttime, catchup, maxCatchupRuns, COALESCE(timezone, ''), startTime, endTime
         FROM DAGs
         WHERE nexttime <= ? AND schedule != '' AND active = 1 AND suspended = 0;
-    `, tm.Format("2006-01-02 15:04:05"))
+    `, tm.UTC().Format("2006-01-02 15:04:05"))
 	if err != nil {
 		return nil, err
 	}
@@ -180,46 +239,65 @@ func (s *sqliteDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm time.
 	// Collect DAG info and schedules
 	namespaces := []*DagInfo{}
 	schedules := []string{}
-	dagIds := []int{}
+	nextTimes := []time.Time{}
+	limits := []int{}
+	timezones := []string{}
+	windows := []scheduleWindow{}
 	for rows.Next() {
 		var dagId int
-		var name, schedule, namespace string
+		var name, schedule, namespace, timezone string
 		var workEnabled sql.NullBool
+		var nextTime time.Time
+		var catchup bool
+		var maxCatchupRuns int
+		var window scheduleWindow
 
-		if err := rows.Scan(&dagId, &name, &schedule, &namespace, &workEnabled); err != nil {
+		if err := rows.Scan(&dagId, &name, &schedule, &namespace, &workEnabled, &nextTime, &catchup, &maxCatchupRuns, &timezone, &window.start, &window.end); err != nil {
 			return nil, err
 		}
 
 		namespaces = append(namespaces, &DagInfo{
+			DagId:            dagId,
 			DagName:          name,
 			Namespace:        namespace,
 			WorkspaceEnabled: workEnabled.Valid && workEnabled.Bool,
 		})
 		schedules = append(schedules, schedule)
-		dagIds = append(dagIds, dagId)
+		nextTimes = append(nextTimes, nextTime)
+		limits = append(limits, catchupLimit(catchup, maxCatchupRuns))
+		timezones = append(timezones, timezone)
+		windows = append(windows, window)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
+	dagInfos := []*DagInfo{}
+
 	// TODO: bath update nexttime for all DAGs
 	for i, schedule := range schedules {
 		// Parse the cron expression
-		sched, err := s.parser.Parse(schedule)
+		sched, err := parseSchedule(s.parser, schedule, timezones[i])
 		if err != nil {
 			return nil, err
 		}
 
-		// Calculate the next occurrence
-		nextTime := sched.Next(time.Now())
+		for _, scheduledTime := range missedScheduleTimes(sched, windows[i], nextTimes[i], tm, limits[i]) {
+			dagInfo := *namespaces[i]
+			dagInfo.ScheduledTime = scheduledTime
+			dagInfos = append(dagInfos, &dagInfo)
+		}
+
+		// Calculate the next occurrence, none once the schedule window has closed
+		nextTime := nextTimeColumn(windows[i].next(sched, tm))
 
 		// Update the nextTime for each DAG
 		_, err = tx.Exec(`
 			UPDATE DAGs 
 			SET nexttime = ? 
 			WHERE dag_id = ?;
-		`, nextTime, dagIds[i])
+		`, nextTime, namespaces[i].DagId)
 		if err != nil {
 			return nil, err
 		}
@@ -229,7 +307,113 @@ func (s *sqliteDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, tm time.
 		return nil, err
 	}
 
-	return namespaces, nil
+	return dagInfos, nil
+}
+
+func (s *sqliteDAGManager) GetBackfillsToStartAndUpdate(ctx context.Context) ([]*DagInfo, error) {
+	dagInfos := []*DagInfo{}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// backfills of suspended DAGs wait until they are resumed
+		rows, err := tx.QueryContext(ctx, `
+			SELECT b.backfill_id, b.end_time, b.cursor_time, d.dag_id, d.name, d.namespace, d.schedule, COALESCE(d.timezone, ''), d.workspaceEnabled
+			FROM DAG_Backfills b
+			JOIN DAGs d ON d.name = b.dag_name AND d.namespace = b.namespace
+			WHERE b.completed = 0 AND d.active = 1 AND d.suspended = 0 AND d.schedule != ''
+			ORDER BY b.backfill_id;`)
+		if err != nil {
+			return err
+		}
+
+		type backfill struct {
+			id       int
+			end      time.Time
+			cursor   time.Time
+			schedule string
+			timezone string
+			info     DagInfo
+		}
+
+		backfills := []backfill{}
+		for rows.Next() {
+			var b backfill
+			var workEnabled sql.NullBool
+			if err := rows.Scan(&b.id, &b.end, &b.cursor, &b.info.DagId, &b.info.DagName, &b.info.Namespace, &b.schedule, &b.timezone, &workEnabled); err != nil {
+				rows.Close()
+				return err
+			}
+			b.info.WorkspaceEnabled = workEnabled.Valid && workEnabled.Bool
+			backfills = append(backfills, b)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, b := range backfills {
+			remaining := backfillBatchSize - len(dagInfos)
+			if remaining == 0 {
+				break
+			}
+
+			sched, err := parseSchedule(s.parser, b.schedule, b.timezone)
+			if err != nil {
+				return err
+			}
+
+			times, completed := backfillScheduleTimes(sched, b.cursor, b.end, remaining)
+			for _, scheduledTime := range times {
+				dagInfo := b.info
+				dagInfo.ScheduledTime = scheduledTime
+				dagInfos = append(dagInfos, &dagInfo)
+			}
+
+			cursor := b.cursor
+			if len(times) > 0 {
+				cursor = times[len(times)-1]
+			}
+
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE DAG_Backfills
+				SET cursor_time = ?, completed = ?
+				WHERE backfill_id = ?;`, cursor, completed, b.id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dagInfos, nil
+}
+
+// addBackfill records the backfill range of a DAG, ranges that were recorded before are ignored
+func (s *sqliteDAGManager) addBackfill(ctx context.Context, tx *sql.Tx, dagName, namespace string, backfill *v1alpha1.Backfill) (bool, error) {
+	if backfill == nil {
+		return false, nil
+	}
+
+	start, end := backfill.Start.UTC(), backfill.End.UTC()
+
+	// the cursor sits just before the start so a schedule time on the start itself is included
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO DAG_Backfills (dag_name, namespace, start_time, end_time, cursor_time)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (dag_name, namespace, start_time, end_time) DO NOTHING;`, dagName, namespace, start, end, start.Add(-time.Second))
+	if err != nil {
+		return false, fmt.Errorf("failed to add backfill: %w", err)
+	}
+
+	added, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return added > 0, nil
 }
 
 func (s *sqliteDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, namespace string) error {
@@ -255,11 +439,20 @@ func (s *sqliteDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, nam
 
 		hashValue := fmt.Sprintf("%x", hashBytes)
 		if hash == hashValue {
+			backfillAdded, err := s.addBackfill(ctx, tx, dag.Name, namespace, dag.Spec.Backfill)
+			if err != nil {
+				return err
+			}
+
 			// check if suspended
 			if suspended != dag.Spec.Suspended {
 				return s.setSuspended(ctx, tx, dag.Name, namespace, dag.Spec.Suspended)
 			}
 
+			if backfillAdded {
+				return nil
+			}
+
 			return fmt.Errorf("applying the same dag")
 		}
 
@@ -267,6 +460,12 @@ func (s *sqliteDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, nam
 			version++
 		}
 
+		// the graph is read in the transaction that stores the DAG, so DAGs applied together
+		// cannot each pass on a graph missing the other
+		if err := s.checkDagLoops(ctx, tx, dag, namespace); err != nil {
+			return err
+		}
+
 		// DAG does not exist, insert it
 		if err := s.insertDAG(ctx, tx, dag, version, namespace, hashValue); err != nil {
 			return err
@@ -277,10 +476,53 @@ func (s *sqliteDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, nam
 			return err
 		}
 
+		if _, err := s.addBackfill(ctx, tx, dag.Name, namespace, dag.Spec.Backfill); err != nil {
+			return err
+		}
+
 		return nil
 	})
 }
 
+// checkDagLoops ensures storing the DAG does not let it run itself through the dagRef tasks or
+// onDagCompletion triggers of stored DAGs
+func (s *sqliteDAGManager) checkDagLoops(ctx context.Context, tx *sql.Tx, dag *v1alpha1.DAG, namespace string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT d.name, d.namespace, dt.dagRefName
+		FROM DAG_Tasks dt
+		JOIN DAGs d ON d.dag_id = dt.dag_id
+		WHERE d.active = TRUE AND dt.dagRefName IS NOT NULL;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	children := map[types.NamespacedName][]types.NamespacedName{}
+	for rows.Next() {
+		var parent types.NamespacedName
+		var child string
+		if err := rows.Scan(&parent.Name, &parent.Namespace, &child); err != nil {
+			return err
+		}
+		children[parent] = append(children[parent], types.NamespacedName{Namespace: parent.Namespace, Name: child})
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := dag.CheckDagRefLoops(namespace, children); err != nil {
+		return err
+	}
+
+	upstreams, err := s.getDagCompletionTriggers(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	return dag.CheckCompletionTriggerLoops(namespace, upstreams)
+}
+
 func (s *sqliteDAGManager) setSuspended(ctx context.Context, tx *sql.Tx, dagName, namespace string, suspended bool) error {
 	if _, err := tx.ExecContext(ctx, `
 		UPDATE DAGs
@@ -300,23 +542,25 @@ func (s *sqliteDAGManager) insertDAG(ctx context.Context, tx *sql.Tx, dag *v1alp
 
 	// Could be an event driven only score
 	if dag.Spec.Schedule != "" {
-		sched, err := s.parser.Parse(dag.Spec.Schedule)
+		sched, err := parseSchedule(s.parser, dag.Spec.Schedule, dag.Spec.Timezone)
 		if err != nil {
 			return err
 		}
 
-		// Get the next occurrence of the scheduled time
-		t := sched.Next(time.Now())
-		nextTime = &t
+		// Get the next occurrence of the scheduled time within the schedule window
+		window := newScheduleWindow(dag.Spec.StartTime, dag.Spec.EndTime)
+		nextTime = nextTimeColumn(window.next(sched, time.Now()))
 	}
 
 	var dagID int
 	if err := tx.QueryRowContext(ctx, `
-	INSERT INTO DAGs (name, version, hash, schedule, namespace, active, nexttime, taskCount, webhookUrl, sslVerification, suspended) 
-	VALUES (?, ?, ?, ?, ?, TRUE, ?, ?, ?, ?, ?)
+	INSERT INTO DAGs (name, version, hash, schedule, namespace, active, nexttime, taskCount, webhookUrl, sslVerification, suspended, runTimeoutSeconds, concurrencyPolicy, maxActiveRuns, catchup, maxCatchupRuns, timezone, startTime, endTime, priority) 
+	VALUES (?, ?, ?, ?, ?, TRUE, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	RETURNING dag_id`, dag.Name, version, hash, dag.Spec.Schedule,
 		namespace, nextTime, len(dag.Spec.Task), dag.Spec.Webhook.URL,
-		dag.Spec.Webhook.VerifySSL, dag.Spec.Suspended).Scan(&dagID); err != nil {
+		dag.Spec.Webhook.VerifySSL, dag.Spec.Suspended, timeoutSeconds(dag.Spec.RunTimeout),
+		string(dag.Spec.ConcurrencyPolicy), dag.Spec.MaxActiveRuns, dag.Spec.Catchup, dag.Spec.MaxCatchupRuns,
+		dag.Spec.Timezone, utcTime(dag.Spec.StartTime), utcTime(dag.Spec.EndTime), dag.Spec.Priority).Scan(&dagID); err != nil {
 		return err
 	}
 
@@ -352,9 +596,29 @@ func (s *sqliteDAGManager) insertDAG(ctx context.Context, tx *sql.Tx, dag *v1alp
 		}
 	}
 
+	if dag.Spec.Triggers != nil {
+		for _, trigger := range dag.Spec.Triggers.OnDagCompletion {
+			if err := s.insertCompletionTrigger(ctx, tx, dagID, &trigger, namespace); err != nil {
+				return fmt.Errorf("failed to insert onDagCompletion trigger: %w", err)
+			}
+		}
+	}
+
 	return nil
 }
 
+func (s *sqliteDAGManager) insertCompletionTrigger(ctx context.Context, tx *sql.Tx, dagID int, trigger *v1alpha1.DagCompletionTrigger, namespace string) error {
+	outcome, parameters, err := completionTriggerColumns(trigger)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO DAG_Completion_Triggers (dag_id, upstream_name, upstream_namespace, outcome, parameters)
+	VALUES (?, ?, ?, ?, ?);`, dagID, trigger.DagName, trigger.GetNamespace(namespace), outcome, parameters)
+	return err
+}
+
 func (s *sqliteDAGManager) insertWorkspace(ctx context.Context, tx *sql.Tx, dagID int, workspace *v1alpha1.PVC) error {
 	accessModesJSON, err := json.Marshal(workspace.AccessModes)
 	if err != nil {
@@ -418,6 +682,16 @@ func (s *sqliteDAGManager) insertTask(ctx context.Context, tx *sql.Tx, dagID int
 		return err
 	}
 
+	outputsJson, err := json.Marshal(task.Outputs)
+	if err != nil {
+		return err
+	}
+
+	artifactsJson, err := artifactsColumn(task.Artifacts)
+	if err != nil {
+		return err
+	}
+
 	var taskId int
 	inline := task.TaskRef == nil
 	if !inline {
@@ -432,19 +706,38 @@ func (s *sqliteDAGManager) insertTask(ctx context.Context, tx *sql.Tx, dagID int
 		// must provide a unique name - name is used not used for in-line and must just be unique
 		newUUID := uuid.New()
 
+		hash, err := hashInlineTask(task)
+		if err != nil {
+			return err
+		}
+
+		backoff := backoffColumns(task.Backoff)
 		if err := tx.QueryRowContext(ctx, `
-		INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?, ?) 
+		INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version, hash, outputs, backoffInitialDelaySeconds, backoffMaxDelaySeconds, backoffMultiplier, backoffJitter, artifacts) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?, ?, ?, ?, ?, ?, ?, ?, ?) 
 		RETURNING task_id;`,
 			newUUID.String(), commandJson, argsJson, task.Image, paramsJson, task.Backoff.Limit,
-			task.Conditional.Enabled, retryCodesJson, jsonValue, task.Script, task.ScriptInjectorImage, namespace, version).Scan(&taskId); err != nil {
+			task.Conditional.Enabled, retryCodesJson, jsonValue, task.Script, task.ScriptInjectorImage, namespace, version, hash, outputsJson,
+			backoff.initialDelaySeconds, backoff.maxDelaySeconds, backoff.multiplier, backoff.jitter, artifactsJson).Scan(&taskId); err != nil {
 			return err
 		}
 	}
 
+	dagRefName, dagRefParameters, err := dagRefColumns(task.DagRef)
+	if err != nil {
+		return err
+	}
+
+	sensor, err := sensorColumn(task.Sensor)
+	if err != nil {
+		return err
+	}
+
+	mapParameter, mapOutput, mapMaxParallelism := mapColumns(task.Map)
+	pool, poolSlots := poolColumns(task)
 	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO DAG_Tasks (dag_id, task_id, name, version)
-		VALUES (?, ?, ?, ?);`, dagID, taskId, task.Name, version); err != nil {
+		INSERT INTO DAG_Tasks (dag_id, task_id, name, version, whenExpr, triggerRule, mapParameter, mapOutput, mapMaxParallelism, dagRefName, dagRefParameters, timeoutSeconds, cacheTTLSeconds, priority, pool, poolSlots, sensor)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`, dagID, taskId, task.Name, version, task.When, task.TriggerRule, mapParameter, mapOutput, mapMaxParallelism, dagRefName, dagRefParameters, timeoutSeconds(task.Timeout), cacheTTLSeconds(task.Cache), task.Priority, pool, poolSlots, sensor); err != nil {
 		return err
 	}
 
@@ -489,16 +782,18 @@ func (s *sqliteDAGManager) createDependencyConnection(ctx context.Context, tx *s
 }
 
 func (s *sqliteDAGManager) insertParameter(tx *sql.Tx, dagID int, parameter *v1alpha1.DagParameterSpec) error {
-	value := parameter.DefaultFromSecret
-	isSecret := parameter.DefaultValue == ""
-	if !isSecret {
-		value = parameter.DefaultValue
+	// required parameters have no default at all
+	value := dagParameter(parameter)
+
+	schema, err := json.Marshal(parameter.ParameterSchema)
+	if err != nil {
+		return err
 	}
 
 	// Map the task to the DAG
 	if _, err := tx.Exec(`
-	INSERT INTO DAG_Parameters (dag_id, name, isSecret, defaultValue) 
-	VALUES (?, ?, ?, ?)`, dagID, parameter.Name, isSecret, value); err != nil {
+	INSERT INTO DAG_Parameters (dag_id, name, isSecret, isConfigMap, valueKey, defaultValue, parameterSchema) 
+	VALUES (?, ?, ?, ?, ?, ?, ?)`, dagID, parameter.Name, value.IsSecret, value.IsConfigMap, value.Key, value.Value, string(schema)); err != nil {
 		return err
 	}
 
@@ -536,31 +831,245 @@ func (s *sqliteDAGManager) CreateDAGRun(ctx context.Context, name string, dag *v
 
 	defer tx.Rollback()
 
+	dagRunID, err := s.insertDAGRun(ctx, tx, dagId, name, "running", parameters, pvcName, utcTime(dag.ScheduledTime), dag.Priority)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return dagRunID, nil
+}
+
+// insertDAGRun adds a run along with its parameters, the runTimeout of a queued run only starts once it is running.
+// A nil priority leaves the run with the priority of its DAG
+func (s *sqliteDAGManager) insertDAGRun(ctx context.Context, tx *sql.Tx, dagId int, name, status string, parameters map[string]v1alpha1.ParameterSpec, pvcName *string, scheduledTime *time.Time, priority *int32) (int, error) {
 	// Map the task to the DAG
 	var dagRunID int
 	if err := tx.QueryRowContext(ctx, `
-	INSERT INTO DAG_Runs (dag_id, name, status, successfulCount, failedCount, suspendedCount, run_time, pvcName) 
-	VALUES (?, ?, 'running', 0, 0, 0, datetime('now'), ?) 
-	RETURNING run_id`, dagId, name, pvcName).Scan(&dagRunID); err != nil {
+	INSERT INTO DAG_Runs (dag_id, name, status, successfulCount, failedCount, suspendedCount, run_time, pvcName, timeout_at, scheduled_time, priority) 
+	VALUES (?, ?, ?, 0, 0, 0, datetime('now'), ?, CASE WHEN ? = 'running' THEN (SELECT datetime('now', '+' || runTimeoutSeconds || ' seconds') FROM DAGs WHERE dag_id = ?) END, ?, ?) 
+	RETURNING run_id`, dagId, name, status, pvcName, status, dagId, scheduledTime, priority).Scan(&dagRunID); err != nil {
 		return 0, err
 	}
 
 	for _, param := range parameters {
-		value := param.Value
-		if param.FromSecret != "" {
-			value = param.FromSecret
+		value := runParameter(param)
+		if _, err := tx.Exec("INSERT INTO DAG_Run_Parameters (run_id, name, value, isSecret, isConfigMap, valueKey) VALUES (?, ?, ?, ?, ?, ?);", dagRunID, param.Name, value.Value, value.IsSecret, value.IsConfigMap, value.Key); err != nil {
+			return 0, err
+		}
+	}
+
+	return dagRunID, nil
+}
+
+func (s *sqliteDAGManager) StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (DagRunAdmission, error) {
+	dagId, err := s.dagNameToDagId(ctx, dag.DagName)
+	if err != nil {
+		return DagRunAdmission{}, err
+	}
+
+	var existing DagRunAdmission
+	if err := s.db.QueryRowContext(ctx, `SELECT run_id, status FROM DAG_Runs WHERE name = ?`, name).Scan(&existing.RunId, &existing.Status); err == nil {
+		return existing, nil
+	} else if err != sql.ErrNoRows {
+		return DagRunAdmission{}, err
+	}
+
+	admission := DagRunAdmission{}
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		var policy string
+		var maxActiveRuns int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE(concurrencyPolicy, ''), maxActiveRuns
+			FROM DAGs
+			WHERE dag_id = ?;`, dagId).Scan(&policy, &maxActiveRuns); err != nil {
+			return err
 		}
 
-		if _, err := tx.Exec("INSERT INTO DAG_Run_Parameters (run_id, name, value, isSecret) VALUES (?, ?, ?, ?);", dagRunID, param.Name, value, param.FromSecret != ""); err != nil {
-			return 0, err
+		status := "running"
+		if limit := activeRunLimit(policy, maxActiveRuns); limit > 0 {
+			// runs of earlier versions of the DAG count towards the limit too
+			var active, queued int
+			if err := tx.QueryRowContext(ctx, `
+				SELECT
+					COALESCE(SUM(CASE WHEN `+activeRunCondition+` THEN 1 ELSE 0 END), 0),
+					COALESCE(SUM(CASE WHEN r.status = 'queued' THEN 1 ELSE 0 END), 0)
+				FROM DAG_Runs r
+				JOIN DAGs d ON d.dag_id = r.dag_id
+				WHERE d.name = ?;`, dag.DagName).Scan(&active, &queued); err != nil {
+				return err
+			}
+
+			switch v1alpha1.ConcurrencyPolicy(policy) {
+			case v1alpha1.ConcurrencyPolicyQueue:
+				// runs already waiting go first
+				if active >= limit || queued > 0 {
+					status = "queued"
+				}
+			case v1alpha1.ConcurrencyPolicyForbid:
+				if active >= limit {
+					admission.Status = "skipped"
+					return nil
+				}
+			case v1alpha1.ConcurrencyPolicyReplace:
+				if active >= limit {
+					replaced, err := s.suspendOldestDagRuns(ctx, tx, dag.DagName, active-limit+1)
+					if err != nil {
+						return err
+					}
+					admission.Replaced = replaced
+				}
+			}
+		}
+
+		runId, err := s.insertDAGRun(ctx, tx, dagId, name, status, parameters, pvcName, utcTime(dag.ScheduledTime), dag.Priority)
+		if err != nil {
+			return err
 		}
+
+		admission.RunId = runId
+		admission.Status = status
+		return nil
+	})
+
+	if err != nil {
+		return DagRunAdmission{}, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, err
+	return admission, nil
+}
+
+// suspendOldestDagRuns marks the oldest active runs of a DAG as suspended, along with their active task runs.
+// Failed runs keep their status and only have their task runs suspended
+func (s *sqliteDAGManager) suspendOldestDagRuns(ctx context.Context, tx *sql.Tx, dagName string, count int) ([]StoppedDagRun, error) {
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE DAG_Runs
+		SET status = CASE WHEN status = 'running' THEN 'suspended' ELSE status END
+		WHERE run_id IN (
+			SELECT r.run_id
+			FROM DAG_Runs r
+			JOIN DAGs d ON d.dag_id = r.dag_id
+			WHERE d.name = ? AND `+activeRunCondition+`
+			ORDER BY r.run_id
+			LIMIT ?
+		)
+		RETURNING run_id, pvcName;`, dagName, count)
+	if err != nil {
+		return nil, err
 	}
 
-	return dagRunID, nil
+	suspended := []StoppedDagRun{}
+	for rows.Next() {
+		var run StoppedDagRun
+		if err := rows.Scan(&run.RunId, &run.PVCName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		suspended = append(suspended, run)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.stopDagRunTasks(ctx, tx, suspended, "suspended"); err != nil {
+		return nil, err
+	}
+
+	return suspended, nil
+}
+
+func (s *sqliteDAGManager) StartQueuedDAGRuns(ctx context.Context) ([]QueuedDagRun, error) {
+	started := []QueuedDagRun{}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// the policy of the latest version of a DAG applies to the runs of every version
+		rows, err := tx.QueryContext(ctx, `
+			SELECT d.name, d.namespace, COALESCE(d.concurrencyPolicy, ''), d.maxActiveRuns,
+				COALESCE(SUM(CASE WHEN `+activeRunCondition+` THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN r.status = 'queued' THEN 1 ELSE 0 END), 0)
+			FROM DAGs d
+			JOIN DAGs v ON v.name = d.name
+			JOIN DAG_Runs r ON r.dag_id = v.dag_id
+			WHERE d.version = (SELECT MAX(version) FROM DAGs l WHERE l.name = d.name)
+			GROUP BY d.dag_id, d.name, d.namespace, d.concurrencyPolicy, d.maxActiveRuns
+			HAVING SUM(CASE WHEN r.status = 'queued' THEN 1 ELSE 0 END) > 0;`)
+		if err != nil {
+			return err
+		}
+
+		type queuedDag struct {
+			name      string
+			namespace string
+			toStart   int
+		}
+
+		dags := []queuedDag{}
+		for rows.Next() {
+			var dag queuedDag
+			var policy string
+			var maxActiveRuns, active, queued int
+			if err := rows.Scan(&dag.name, &dag.namespace, &policy, &maxActiveRuns, &active, &queued); err != nil {
+				rows.Close()
+				return err
+			}
+
+			dag.toStart = queuedRunsToStart(activeRunLimit(policy, maxActiveRuns), active, queued)
+			if dag.toStart > 0 {
+				dags = append(dags, dag)
+			}
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, dag := range dags {
+			rows, err := tx.QueryContext(ctx, `
+				UPDATE DAG_Runs
+				SET status = 'running', run_time = datetime('now'),
+					timeout_at = (SELECT datetime('now', '+' || runTimeoutSeconds || ' seconds') FROM DAGs WHERE DAGs.dag_id = DAG_Runs.dag_id)
+				WHERE run_id IN (
+					SELECT r.run_id
+					FROM DAG_Runs r
+					JOIN DAGs d ON d.dag_id = r.dag_id
+					WHERE d.name = ? AND r.status = 'queued'
+					ORDER BY r.run_id
+					LIMIT ?
+				)
+				RETURNING run_id;`, dag.name, dag.toStart)
+			if err != nil {
+				return err
+			}
+
+			for rows.Next() {
+				run := QueuedDagRun{DagName: dag.name, Namespace: dag.namespace}
+				if err := rows.Scan(&run.RunId); err != nil {
+					rows.Close()
+					return err
+				}
+				started = append(started, run)
+			}
+			rows.Close()
+
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return started, nil
 }
 
 func (s *sqliteDAGManager) TaskRunExists(ctx context.Context, runId, dagTaskId int) (bool, error) {
@@ -610,7 +1119,9 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 		t.podTemplate, 
 		dt.dag_id, 
 		t.script,
-		dr.pvcName
+		dr.pvcName,
+		dt.whenExpr,
+		dt.mapParameter
 	FROM 
 		Tasks t
 	JOIN 
@@ -647,6 +1158,7 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 	tasks := []Task{}
 	paramsForTasks := [][]string{}
 	var dagIDForParams int = -1
+	hasConditions := false
 
 	for rows.Next() {
 		task := Task{}
@@ -658,11 +1170,18 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 		var argsJSON string
 		var paramJSON string
 		var pvcName sql.NullString
+		var when sql.NullString
+		var mapParameter sql.NullString
 
-		if err := rows.Scan(&task.Id, &task.Name, &task.Image, &commandJSON, &argsJSON, &paramJSON, &podTemplateJSON, &dagId, &task.Script, &pvcName); err != nil {
+		if err := rows.Scan(&task.Id, &task.Name, &task.Image, &commandJSON, &argsJSON, &paramJSON, &podTemplateJSON, &dagId, &task.Script, &pvcName, &when, &mapParameter); err != nil {
 			return nil, err
 		}
 
+		// a map over an empty list is skipped just like a false condition
+		if when.String != "" || mapParameter.String != "" {
+			hasConditions = true
+		}
+
 		if err := json.Unmarshal([]byte(commandJSON), &task.Command); err != nil {
 			return nil, err
 		}
@@ -709,6 +1228,10 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 		tasks = append(tasks, task)
 	}
 
+	if hasConditions {
+		return s.getConditionalStartingTasks(ctx, dagIDForParams, dagrun, tasks)
+	}
+
 	// If there are parameters to fetch, batch query them
 	if len(paramsForTasks) > 0 {
 		// Build unique list of parameter names
@@ -734,7 +1257,7 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 		}
 
 		query := fmt.Sprintf(`
-		SELECT name, isSecret, defaultValue
+		SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue
 		FROM DAG_Parameters
 		WHERE dag_id = ? AND name IN (%s);
 		`, strings.Join(placeholders, ","))
@@ -748,13 +1271,11 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 
 		paramMap := make(map[string]Parameter)
 		for rowsParams.Next() {
-			var name string
-			var isSecret bool
-			var value string
-			if err := rowsParams.Scan(&name, &isSecret, &value); err != nil {
+			var param Parameter
+			if err := rowsParams.Scan(&param.Name, &param.IsSecret, &param.IsConfigMap, &param.Key, &param.Value); err != nil {
 				return nil, err
 			}
-			paramMap[name] = Parameter{Name: name, IsSecret: isSecret, Value: value}
+			paramMap[param.Name] = param
 		}
 
 		// Ensure all requested params were found
@@ -775,7 +1296,48 @@ func (s *sqliteDAGManager) GetStartingTasks(ctx context.Context, dagName string,
 	return tasks, nil
 }
 
-func (s *sqliteDAGManager) MarkTaskAsStarted(ctx context.Context, runId, taskId int) (int, error) {
+// getConditionalStartingTasks evaluates the when expressions of the starting tasks,
+// skipping the ones whose condition is false and picking up the tasks that become runnable as a result
+func (s *sqliteDAGManager) getConditionalStartingTasks(ctx context.Context, dagId, runId int, startingTasks []Task) ([]Task, error) {
+	taskIds := make([]int, 0, len(startingTasks))
+	for _, task := range startingTasks {
+		taskIds = append(taskIds, task.Id)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	runnable, _, err := s.resolveTasks(ctx, tx, runId, dagId, taskIds)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, parameters, err := s.getTasksByIds(ctx, tx, runnable, runId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		if _, err := s.markRunSuccessIfComplete(ctx, tx, runId); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.fetchTaskParameters(ctx, tx, dagId, runId, tasks, parameters); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (s *sqliteDAGManager) MarkTaskAsStarted(ctx context.Context, runId, taskId int) (int, error) {
 	var taskRunId int
 
 	if err := s.db.QueryRowContext(ctx, `
@@ -800,32 +1362,56 @@ func (s *sqliteDAGManager) ClaimTasks(ctx context.Context, limit int, workerId s
 		_ = tx.Rollback()
 	}()
 
+	// higher priorities go first, with task runs gaining a level for every priorityAgingSeconds they have
+	// been claimable so lower priorities are not starved, then the longest waiting. Tasks in a pool
+	// are left queued while it does not exist or has too few free slots
 	rows, err := tx.QueryContext(ctx, `
-	SELECT task_run_id, task_id, run_id
-	FROM Task_Runs
-	WHERE status = 'pending' AND (scheduled_start IS NULL OR scheduled_start <= datetime('now'))
+	SELECT tr.task_run_id, tr.task_id, tr.run_id, tr.map_index, COALESCE(tr.map_item, ''), pu.pool_id, dt.poolSlots, COALESCE(pu.slots - pu.occupied_slots, 0)
+	FROM Task_Runs tr
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	JOIN DAGs d ON d.dag_id = dt.dag_id
+	LEFT JOIN Pool_Usage pu ON pu.name = dt.pool AND pu.namespace = d.namespace
+	WHERE tr.status = 'pending' AND (tr.scheduled_start IS NULL OR tr.scheduled_start <= datetime('now'))
+	AND (tr.claimed_by IS NULL OR tr.lease_expires_at <= datetime('now'))
+	AND (dt.pool IS NULL OR dt.poolSlots <= pu.slots - pu.occupied_slots)
+	ORDER BY tr.priority + CAST((julianday('now') - julianday(COALESCE(tr.scheduled_start, tr.queued_at, datetime('now')))) * 86400 / ? AS INTEGER) DESC,
+		COALESCE(tr.scheduled_start, tr.queued_at) ASC, tr.task_run_id ASC
 	LIMIT ?
-	`, limit)
+	`, priorityAgingSeconds, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var ids []int
-	var claims []TaskClaim
+	var candidates []claimCandidate
+	freeSlots := map[int]int{}
 	for rows.Next() {
-		var c TaskClaim
-		if err := rows.Scan(&c.TaskRunID, &c.TaskID, &c.RunID); err != nil {
+		var c claimCandidate
+		var free int
+		if err := rows.Scan(&c.TaskRunID, &c.TaskID, &c.RunID, &c.MapIndex, &c.MapItem, &c.poolId, &c.poolSlots, &free); err != nil {
 			return nil, err
 		}
-		ids = append(ids, c.TaskRunID)
-		claims = append(claims, c)
+		if c.poolId != nil {
+			freeSlots[*c.poolId] = free
+		}
+		candidates = append(candidates, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if len(ids) == 0 {
+	// candidates sharing a pool may not all fit in its free slots
+	claims := admitCandidates(candidates, freeSlots)
+	if len(claims) == 0 {
 		return []TaskClaim{}, tx.Commit()
 	}
 
+	ids := make([]int, 0, len(claims))
+	for _, c := range claims {
+		ids = append(ids, c.TaskRunID)
+	}
+
 	// Build placeholders for update
 	placeholders := make([]string, 0, len(ids))
 	args := make([]interface{}, 0, len(ids)+3)
@@ -870,9 +1456,46 @@ func (s *sqliteDAGManager) RenewLease(ctx context.Context, taskRunId int, worker
 	return nil
 }
 
+func (s *sqliteDAGManager) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	deferred := false
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// later checks leave the deadline set by the first one, as it is always the earlier
+		var passed bool
+		if err := tx.QueryRowContext(ctx, `
+		UPDATE Task_Runs
+		SET timeout_at = MIN(COALESCE(timeout_at, datetime('now', '+' || ? || ' seconds')), datetime('now', '+' || ? || ' seconds'))
+		WHERE task_run_id = ? AND status = 'pending' AND claimed_by = ?
+		RETURNING timeout_at <= datetime('now')`, int(timeout.Seconds()), int(timeout.Seconds()), taskRunId, workerId).Scan(&passed); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrTaskRunNotClaimed
+			}
+			return err
+		}
+
+		if passed {
+			return nil
+		}
+
+		// the last check happens as the deadline passes
+		if _, err := tx.ExecContext(ctx, `
+		UPDATE Task_Runs
+		SET claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL,
+			scheduled_start = MIN(datetime('now', '+' || ? || ' seconds'), timeout_at)
+		WHERE task_run_id = ?`, int(interval.Seconds()), taskRunId); err != nil {
+			return err
+		}
+
+		deferred = true
+		return nil
+	})
+
+	return deferred, err
+}
+
 func (s *sqliteDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
 	// Transition a claimed task into running state only if owned by workerId
-	res, err := s.db.ExecContext(ctx, `UPDATE Task_Runs SET status = 'running', claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL WHERE task_run_id = ? AND claimed_by = ?;`, taskRunId, workerId)
+	res, err := s.db.ExecContext(ctx, `UPDATE Task_Runs SET status = 'running', claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL WHERE task_run_id = ? AND claimed_by = ? AND status = 'pending';`, taskRunId, workerId)
 	if err != nil {
 		return err
 	}
@@ -886,6 +1509,7 @@ func (s *sqliteDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRunId
 	return nil
 }
 
+// RecoverExpiredLeases clears claims that have expired, ClaimTasks then hands the task runs out again by priority
 func (s *sqliteDAGManager) RecoverExpiredLeases(ctx context.Context) (int, error) {
 	res, err := s.db.ExecContext(ctx, `UPDATE Task_Runs SET claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL WHERE lease_expires_at <= datetime('now') AND status = 'pending';`)
 	if err != nil {
@@ -924,43 +1548,154 @@ func (s *sqliteDAGManager) MarkSuccessAndGetNextTasks(ctx context.Context, taskR
 	}
 	defer tx.Rollback()
 
-	var runId int
-	err = tx.QueryRowContext(ctx, `
-	UPDATE Task_Runs 
-	SET status = 'success' 
-	WHERE task_run_id = ? 
-	RETURNING run_id`, taskRunId).Scan(&runId)
-	if err != nil && err != pgx.ErrNoRows {
+	if err := s.storeTaskCache(ctx, tx, taskRunId); err != nil {
 		return nil, err
 	}
 
-	if _, err := tx.Exec(`
-			UPDATE DAG_Runs
-			SET successfulCount = successfulCount + 1
-			WHERE run_id = ?;`, runId); err != nil {
+	tasks, err := s.completeTaskRun(ctx, tx, taskRunId, "success")
+	if err != nil {
 		return nil, err
 	}
 
-	var status string
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (s *sqliteDAGManager) CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback()
+
+	var outputs sql.NullString
 	err = tx.QueryRowContext(ctx, `
-		UPDATE DAG_Runs
-		SET status = 'success'
-		FROM DAGs
-		WHERE DAG_Runs.dag_id = DAGs.dag_id
-		AND DAGs.taskCount = DAG_Runs.successfulCount
-		AND DAG_Runs.run_id = ?
-		RETURNING DAG_Runs.status;
-	`, runId).Scan(&status)
+	SELECT tc.outputs
+	FROM Task_Runs tr
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	JOIN DAGs d ON d.dag_id = dt.dag_id
+	JOIN Task_Cache tc ON tc.namespace = d.namespace AND tc.dag_name = d.name AND tc.task_name = dt.name
+	WHERE tr.task_run_id = ? AND tc.cache_key = ? AND tc.expires_at > datetime('now');`, taskRunId, cacheKey).Scan(&outputs)
+	if err == sql.ErrNoRows {
+		// keep the key so the result is cached once the task run succeeds
+		if _, err := tx.ExecContext(ctx, `UPDATE Task_Runs SET cache_key = ? WHERE task_run_id = ?`, cacheKey, taskRunId); err != nil {
+			return false, nil, err
+		}
 
-	if err != nil && err != sql.ErrNoRows {
+		return false, nil, tx.Commit()
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	// a task run that timed out or was claimed by another worker meanwhile is left as it is
+	res, err := tx.ExecContext(ctx, `
+	UPDATE Task_Runs
+	SET cache_key = ?, outputs = ?, claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
+	WHERE task_run_id = ? AND status = 'pending' AND claimed_by = ?`, cacheKey, outputs, taskRunId, workerId)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if rows, err := res.RowsAffected(); err != nil {
+		return false, nil, err
+	} else if rows == 0 {
+		return false, nil, ErrTaskRunNotClaimed
+	}
+
+	tasks, err := s.completeTaskRun(ctx, tx, taskRunId, "cached")
+	if err != nil {
+		return false, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, nil, err
+	}
+
+	return true, tasks, nil
+}
+
+func (s *sqliteDAGManager) InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error) {
+	query := `DELETE FROM Task_Cache WHERE dag_name = ? AND namespace = ?`
+	args := []interface{}{dagName, namespace}
+	if len(taskNames) > 0 {
+		query += fmt.Sprintf(" AND task_name IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(taskNames)), ","))
+		for _, name := range taskNames {
+			args = append(args, name)
+		}
+	}
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := res.RowsAffected()
+	return int(rows), err
+}
+
+// storeTaskCache keeps the result of a task run that succeeded for later runs with the same
+// cache key, when its task is cached. A newer result replaces the one already stored
+func (s *sqliteDAGManager) storeTaskCache(ctx context.Context, tx *sql.Tx, taskRunId int) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO Task_Cache (namespace, dag_name, task_name, cache_key, outputs, created_at, expires_at)
+	SELECT d.namespace, d.name, dt.name, tr.cache_key, tr.outputs, datetime('now'), datetime('now', '+' || dt.cacheTTLSeconds || ' seconds')
+	FROM Task_Runs tr
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	JOIN DAGs d ON d.dag_id = dt.dag_id
+	WHERE tr.task_run_id = ? AND tr.cache_key IS NOT NULL AND dt.cacheTTLSeconds IS NOT NULL
+	ON CONFLICT (namespace, dag_name, task_name, cache_key) DO UPDATE
+	SET outputs = excluded.outputs, created_at = excluded.created_at, expires_at = excluded.expires_at;`, taskRunId)
+	return err
+}
+
+// completeTaskRun marks a task run as finished with a successful outcome, success or cached,
+// counts it against its run and returns the tasks that can run next
+func (s *sqliteDAGManager) completeTaskRun(ctx context.Context, tx *sql.Tx, taskRunId int, outcome string) ([]Task, error) {
+	var runId, taskId int
+	var mapIndex *int
+	err := tx.QueryRowContext(ctx, `
+	UPDATE Task_Runs 
+	SET status = ? 
+	WHERE task_run_id = ? 
+	RETURNING run_id, task_id, map_index`, outcome, taskRunId).Scan(&runId, &taskId, &mapIndex)
+	if err != nil && err != pgx.ErrNoRows {
 		return nil, err
 	}
 
-	if status == "success" {
-		if err := tx.Commit(); err != nil {
+	column := "successfulCount"
+	if mapIndex != nil {
+		status, err := s.advanceMapTask(ctx, tx, runId, taskId)
+		if err != nil {
 			return nil, err
 		}
 
+		// downstream tasks wait until every instance has finished
+		if status == "running" {
+			return []Task{}, nil
+		}
+
+		if status == "failed" {
+			column = "failedCount"
+		}
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`
+			UPDATE DAG_Runs
+			SET %[1]s = %[1]s + 1
+			WHERE run_id = ?;`, column), runId); err != nil {
+		return nil, err
+	}
+
+	complete, err := s.markRunSuccessIfComplete(ctx, tx, runId)
+	if err != nil {
+		return nil, err
+	}
+
+	if complete {
 		return []Task{}, nil
 	}
 
@@ -969,22 +1704,68 @@ func (s *sqliteDAGManager) MarkSuccessAndGetNextTasks(ctx context.Context, taskR
 		return nil, err
 	}
 
-	tasks, parameters, err := s.getNextRunnableTasks(ctx, tx, taskRunId, runId, dagId)
+	tasks, parameters, err := s.getNextRunnableTasks(ctx, tx, runId, dagId)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.fetchTaskParameters(ctx, tx, dagId, tasks, parameters); err != nil {
-		return nil, err
+	// skipped tasks may have been the last ones left in the run
+	if len(tasks) == 0 {
+		if _, err := s.markRunSuccessIfComplete(ctx, tx, runId); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := s.fetchTaskParameters(ctx, tx, dagId, runId, tasks, parameters); err != nil {
 		return nil, err
 	}
 
 	return tasks, nil
 }
 
+// advanceMapTask starts the next waiting instance of a map task and returns the status of the map task as a whole
+func (s *sqliteDAGManager) advanceMapTask(ctx context.Context, tx *sql.Tx, runId, taskId int) (string, error) {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE Task_Runs
+		SET status = 'pending'
+		WHERE task_run_id = (
+			SELECT task_run_id
+			FROM Task_Runs
+			WHERE run_id = ? AND task_id = ? AND status = 'waiting'
+			ORDER BY map_index
+			LIMIT 1
+		);`, runId, taskId); err != nil {
+		return "", err
+	}
+
+	statuses, err := s.getTaskStatuses(ctx, tx, runId)
+	if err != nil {
+		return "", err
+	}
+
+	return statuses[taskId], nil
+}
+
+// markRunSuccessIfComplete marks the run as successful once every task in it has either succeeded or been skipped
+func (s *sqliteDAGManager) markRunSuccessIfComplete(ctx context.Context, tx *sql.Tx, runId int) (bool, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, `
+		UPDATE DAG_Runs
+		SET status = 'success'
+		FROM DAGs
+		WHERE DAG_Runs.dag_id = DAGs.dag_id
+		AND DAGs.taskCount = DAG_Runs.successfulCount + DAG_Runs.skippedCount
+		AND DAG_Runs.run_id = ?
+		RETURNING DAG_Runs.status;
+	`, runId).Scan(&status)
+
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	return status == "success", nil
+}
+
 func (s *sqliteDAGManager) getDAGIdFromRun(ctx context.Context, tx *sql.Tx, runId int) (int, error) {
 	var dagId int
 	err := tx.QueryRowContext(ctx, `
@@ -996,18 +1777,8 @@ func (s *sqliteDAGManager) getDAGIdFromRun(ctx context.Context, tx *sql.Tx, runI
 	return dagId, err
 }
 
-func (s *sqliteDAGManager) getNextRunnableTasks(ctx context.Context, tx *sql.Tx, taskRunId, runId int, dagId int) ([]Task, [][]string, error) {
-	dependencyCounts, err := s.getDependencyCounts(ctx, tx, dagId)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	metDependencies, err := s.getMetDependencies(ctx, tx, dagId, runId)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	runnableTasks, err := s.getRunnableTasks(ctx, tx, dependencyCounts, metDependencies, taskRunId)
+func (s *sqliteDAGManager) getNextRunnableTasks(ctx context.Context, tx *sql.Tx, runId int, dagId int) ([]Task, [][]string, error) {
+	runnableTasks, _, err := s.resolveTasks(ctx, tx, runId, dagId, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1015,192 +1786,403 @@ func (s *sqliteDAGManager) getNextRunnableTasks(ctx context.Context, tx *sql.Tx,
 	return s.getTasksByIds(ctx, tx, runnableTasks, runId)
 }
 
-func (s *sqliteDAGManager) getTasksByIds(ctx context.Context, tx *sql.Tx, taskIds []int, runId int) ([]Task, [][]string, error) {
-	params := make([]string, 0, len(taskIds))
-	args := make([]interface{}, 0, len(taskIds)+1)
-	args = append(args, runId)
-
-	for _, id := range taskIds {
-		params = append(params, "?")
-		args = append(args, id)
-	}
-	query := fmt.Sprintf(`
-		SELECT dat.dag_task_id, dat.name, t.image, t.command, t.args, t.parameters, t.scriptInjectorImage, t.script, t.podTemplate, dr.pvcName
-		FROM Tasks t
-		JOIN DAG_Tasks dat ON dat.task_id = t.task_id
-		JOIN DAG_Runs dr ON dat.dag_id = dr.dag_id
-		WHERE 
-			dr.run_id = ?
-		AND 
-			dat.dag_task_id IN (%s)`, strings.Join(params, ","))
-
-	rows, err := tx.QueryContext(ctx, query, args...)
+// resolveTasks applies the trigger rules and when expressions of the tasks in the
+// run that have not started yet. Tasks that can no longer run are suspended or
+// skipped, which can in turn decide further tasks, so it repeats until nothing
+// changes. Candidates are starting tasks that should be considered as well.
+// It returns the tasks that should run and the tasks it suspended.
+func (s *sqliteDAGManager) resolveTasks(ctx context.Context, tx *sql.Tx, runId, dagId int, candidates []int) ([]int, []int, error) {
+	graph, err := s.getDAGGraph(ctx, tx, dagId)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer rows.Close()
 
-	tasks := make([]Task, 0, len(taskIds))
-	parameters := make([][]string, 0, len(taskIds))
-
-	for rows.Next() {
-		var task Task
-		var commandJSON string
-		var argsJSON string
-		var paramsJson string
-		var podTemplateJSON *string
-		var pvcName sql.NullString
+	runnable := []int{}
+	suspended := []int{}
+	seen := map[int]bool{}
 
-		if err := rows.Scan(&task.Id, &task.Name, &task.Image, &commandJSON, &argsJSON, &paramsJson, &task.ScriptInjectorImage, &task.Script, &podTemplateJSON, &pvcName); err != nil {
+	for {
+		statuses, err := s.getTaskStatuses(ctx, tx, runId)
+		if err != nil {
 			return nil, nil, err
 		}
 
-		if err := json.Unmarshal([]byte(commandJSON), &task.Command); err != nil {
-			return nil, nil, err
+		// starting tasks that were already enqueued are left to the caller
+		for _, id := range candidates {
+			if _, started := statuses[id]; started && !seen[id] {
+				seen[id] = true
+				runnable = append(runnable, id)
+			}
 		}
 
-		if err := json.Unmarshal([]byte(argsJSON), &task.Args); err != nil {
-			return nil, nil, err
-		}
+		run, skip, suspend := decideTasks(graph, statuses, candidates)
 
-		params := []string{}
-		if err := json.Unmarshal([]byte(paramsJson), &params); err != nil {
-			return nil, nil, err
-		}
+		var scope *conditions.Scope
+		for _, id := range run {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			task := graph[id]
+			if task.when != "" {
+				if scope == nil {
+					if scope, err = s.getConditionScope(ctx, tx, runId, dagId); err != nil {
+						return nil, nil, err
+					}
+				}
 
-		parameters = append(parameters, params)
+				ok, err := conditions.Evaluate(task.when, *scope)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to evaluate when expression of task %d: %w", id, err)
+				}
 
-		var podTemplate *v1alpha1.PodTemplateSpec
-		if podTemplateJSON != nil {
-			if err := json.Unmarshal([]byte(*podTemplateJSON), &podTemplate); err != nil {
-				return nil, nil, err
+				if !ok {
+					skip = append(skip, id)
+					continue
+				}
 			}
-		} else {
-			podTemplate = &v1alpha1.PodTemplateSpec{}
-		}
 
-		if pvcName.Valid {
-			podTemplate.Volumes = append(podTemplate.Volumes, v1alpha1.Volume{
-				Name:                  "workspace",
-				PersistentVolumeClaim: &v1alpha1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName.String},
-			})
+			// a map over no items has nothing to run
+			if task.isMap() {
+				items, err := s.getMapItems(ctx, tx, runId, dagId, task)
+				if err != nil {
+					return nil, nil, err
+				}
 
-			podTemplate.VolumeMounts = append(podTemplate.VolumeMounts, v1alpha1.VolumeMount{
-				Name:      "workspace",
-				MountPath: "/workspace",
-			})
-		}
+				if len(items) == 0 {
+					skip = append(skip, id)
+					continue
+				}
+			}
 
-		task.PodTemplate = podTemplate
+			runnable = append(runnable, id)
+		}
 
-		tasks = append(tasks, task)
-	}
-	return tasks, parameters, nil
-}
+		if len(skip) == 0 && len(suspend) == 0 {
+			return runnable, suspended, nil
+		}
 
-func (s *sqliteDAGManager) getRunnableTasks(ctx context.Context, tx *sql.Tx, dependencyCounts, metDependencies map[int]int, taskRunId int) ([]int, error) {
-	var runnableTasks []int
+		if len(skip) > 0 {
+			if err := s.markTasksWithStatus(ctx, tx, runId, skip, "skipped"); err != nil {
+				return nil, nil, err
+			}
+		}
 
-	for taskId, totalDeps := range dependencyCounts {
-		metDeps := metDependencies[taskId]
-		if totalDeps != metDeps {
-			continue
+		if len(suspend) > 0 {
+			if err := s.markTasksWithStatus(ctx, tx, runId, suspend, "suspended"); err != nil {
+				return nil, nil, err
+			}
+			suspended = append(suspended, suspend...)
 		}
-		var taskStatus string
-		err := tx.QueryRowContext(ctx, `
-                SELECT status
-                FROM Task_Runs
-                WHERE task_id = ? AND run_id = ?;
-            `, taskId, taskRunId).Scan(&taskStatus)
+	}
+}
 
-		if err == sql.ErrNoRows {
-			runnableTasks = append(runnableTasks, taskId)
-			continue
-		} else if err != nil {
+// getDAGGraph returns every task of the DAG along with its upstream tasks
+func (s *sqliteDAGManager) getDAGGraph(ctx context.Context, tx *sql.Tx, dagId int) (map[int]*dagTask, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT dag_task_id, COALESCE(whenExpr, ''), COALESCE(triggerRule, ''), COALESCE(mapParameter, ''), COALESCE(mapOutput, ''), mapMaxParallelism
+		FROM DAG_Tasks
+		WHERE dag_id = ?;`, dagId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	graph := map[int]*dagTask{}
+	for rows.Next() {
+		var id int
+		task := &dagTask{}
+		if err := rows.Scan(&id, &task.when, &task.triggerRule, &task.mapParameter, &task.mapOutput, &task.mapMaxParallelism); err != nil {
 			return nil, err
 		}
+		graph[id] = task
 	}
 
-	return runnableTasks, nil
-}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-func (s *sqliteDAGManager) getMetDependencies(ctx context.Context, tx *sql.Tx, dagId, runID int) (map[int]int, error) {
-	// Query to get the count of met dependencies for tasks in the same DAG and not already started/completed
-	rows, err := tx.QueryContext(ctx, `
-		SELECT d.task_id, COUNT(d.depends_on_task_id)
+	depRows, err := tx.QueryContext(ctx, `
+		SELECT d.task_id, d.depends_on_task_id
 		FROM Dependencies d
-		JOIN Task_Runs tr ON d.depends_on_task_id = tr.task_id
-		WHERE tr.status = 'success'
-		AND d.task_id IN (
-			SELECT dag_task_id
-			FROM DAG_Tasks 
-			WHERE dag_id = ?
-		)
-		AND d.task_id NOT IN (
-			SELECT task_id 
-			FROM Task_Runs 
-			WHERE
-				status IN ('running', 'success', 'failed')
-			AND run_id = ?
-		)
-		AND tr.run_id = ?
-		GROUP BY d.task_id`, dagId, runID, runID)
+		JOIN DAG_Tasks dt ON d.task_id = dt.dag_task_id
+		WHERE dt.dag_id = ?;`, dagId)
+	if err != nil {
+		return nil, err
+	}
+	defer depRows.Close()
+
+	for depRows.Next() {
+		var taskId, dependsOn int
+		if err := depRows.Scan(&taskId, &dependsOn); err != nil {
+			return nil, err
+		}
+
+		if task, ok := graph[taskId]; ok {
+			task.upstream = append(task.upstream, dependsOn)
+		}
+	}
+
+	return graph, depRows.Err()
+}
 
+// getTaskStatuses returns the status of the latest attempt of each task that has started in the run
+func (s *sqliteDAGManager) getTaskStatuses(ctx context.Context, tx *sql.Tx, runId int) (map[int]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT task_id, map_index, status
+		FROM Task_Runs
+		WHERE run_id = ?
+		ORDER BY task_run_id;`, runId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Map to store met dependency counts for each task
-	metDependencies := make(map[int]int)
+	runs := []taskRunStatus{}
 	for rows.Next() {
-		var taskId, metDeps int
-		if err := rows.Scan(&taskId, &metDeps); err != nil {
+		var run taskRunStatus
+		if err := rows.Scan(&run.taskId, &run.mapIndex, &run.status); err != nil {
 			return nil, err
 		}
-		metDependencies[taskId] = metDeps
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return metDependencies, nil
+	return rollupTaskStatuses(runs), nil
 }
 
-func (s *sqliteDAGManager) getDependencyCounts(ctx context.Context, tx *sql.Tx, dagId int) (map[int]int, error) {
-	// Query to get the total dependencies for tasks associated with the given DAG
-	rows, err := tx.QueryContext(ctx, `
-		SELECT d.task_id, COUNT(d.depends_on_task_id)
-		FROM Dependencies d
-		JOIN DAG_Tasks dt ON d.task_id = dt.dag_task_id
-		WHERE dt.dag_id = ?
-		GROUP BY d.task_id`, dagId)
+// getMapItems resolves the items a map task expands over, from the run parameters or the output of an upstream task
+func (s *sqliteDAGManager) getMapItems(ctx context.Context, tx *sql.Tx, runId, dagId int, task *dagTask) ([]string, error) {
+	var value string
+	var err error
+
+	if task.mapParameter != "" {
+		// run values override the default of the DAG
+		err = tx.QueryRowContext(ctx, `
+			SELECT value FROM (
+				SELECT defaultValue AS value, 0 AS priority FROM DAG_Parameters WHERE dag_id = ? AND name = ?
+				UNION ALL
+				SELECT value, 1 AS priority FROM DAG_Run_Parameters WHERE run_id = ? AND name = ?
+			)
+			ORDER BY priority DESC
+			LIMIT 1;`, dagId, task.mapParameter, runId, task.mapParameter).Scan(&value)
+	} else {
+		taskName, output, _ := strings.Cut(task.mapOutput, ".")
+
+		var outputsJSON string
+		err = tx.QueryRowContext(ctx, `
+			SELECT tr.outputs
+			FROM Task_Runs tr
+			JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+			WHERE tr.run_id = ? AND dt.dag_id = ? AND dt.name = ? AND tr.status IN ('success', 'cached') AND tr.outputs IS NOT NULL
+			ORDER BY tr.task_run_id DESC
+			LIMIT 1;`, runId, dagId, taskName).Scan(&outputsJSON)
+
+		if err == nil {
+			outputs := map[string]string{}
+			if err := json.Unmarshal([]byte(outputsJSON), &outputs); err != nil {
+				return nil, err
+			}
+			value = outputs[output]
+		}
+	}
+
+	// an upstream task that was skipped has no items to offer
+	if err == sql.ErrNoRows {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get map items: %w", err)
+	}
+
+	return parseMapItems(value)
+}
 
+// getConditionScope collects the non-secret parameters of the run and the
+// results of the tasks that have finished so far
+func (s *sqliteDAGManager) getConditionScope(ctx context.Context, tx *sql.Tx, runId, dagId int) (*conditions.Scope, error) {
+	scope := &conditions.Scope{
+		Params: map[string]string{},
+		Tasks:  map[string]conditions.TaskResult{},
+	}
+
+	// run values override the defaults of the DAG
+	rows, err := tx.QueryContext(ctx, `
+		SELECT name, defaultValue, 0 AS priority FROM DAG_Parameters WHERE dag_id = ? AND isSecret = 0 AND isConfigMap = 0
+		UNION ALL
+		SELECT name, value, 1 AS priority FROM DAG_Run_Parameters WHERE run_id = ? AND isSecret = 0 AND isConfigMap = 0
+		ORDER BY priority;`, dagId, runId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Map to store dependency counts for each task
-	dependencyCounts := make(map[int]int)
 	for rows.Next() {
-		var taskId, totalDependencies int
-		if err := rows.Scan(&taskId, &totalDependencies); err != nil {
+		var name, value string
+		var priority int
+		if err := rows.Scan(&name, &value, &priority); err != nil {
+			return nil, err
+		}
+		scope.Params[name] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// a result reused from the cache reads as a success
+	taskRows, err := tx.QueryContext(ctx, `
+		SELECT dt.name, CASE tr.status WHEN 'cached' THEN 'success' ELSE tr.status END, tr.outputs
+		FROM Task_Runs tr
+		JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+		WHERE tr.run_id = ?
+		ORDER BY tr.task_run_id;`, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer taskRows.Close()
+
+	for taskRows.Next() {
+		var name, status string
+		var outputsJSON sql.NullString
+		if err := taskRows.Scan(&name, &status, &outputsJSON); err != nil {
 			return nil, err
 		}
-		dependencyCounts[taskId] = totalDependencies
+
+		outputs := map[string]string{}
+		if outputsJSON.Valid {
+			if err := json.Unmarshal([]byte(outputsJSON.String), &outputs); err != nil {
+				return nil, err
+			}
+		}
+
+		// later attempts replace earlier ones
+		scope.Tasks[name] = conditions.TaskResult{Status: status, Outputs: outputs}
+	}
+
+	return scope, taskRows.Err()
+}
+
+// markTasksWithStatus records tasks that will not run, either skipped or suspended, and counts them against the run
+func (s *sqliteDAGManager) markTasksWithStatus(ctx context.Context, tx *sql.Tx, runId int, taskIds []int, status string) error {
+	for _, taskId := range taskIds {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO Task_Runs (run_id, task_id, status, attempts)
+			VALUES (?, ?, ?, 0);`, runId, taskId, status); err != nil {
+			return fmt.Errorf("failed to insert %s task run: %w", status, err)
+		}
+	}
+
+	column := "skippedCount"
+	if status == "suspended" {
+		column = "suspendedCount"
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		UPDATE DAG_Runs
+		SET %[1]s = %[1]s + ?
+		WHERE run_id = ?;`, column), len(taskIds), runId); err != nil {
+		return fmt.Errorf("failed to update %s: %w", column, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteDAGManager) getTasksByIds(ctx context.Context, tx *sql.Tx, taskIds []int, runId int) ([]Task, [][]string, error) {
+	params := make([]string, 0, len(taskIds))
+	args := make([]interface{}, 0, len(taskIds)+1)
+	args = append(args, runId)
+
+	for _, id := range taskIds {
+		params = append(params, "?")
+		args = append(args, id)
+	}
+	query := fmt.Sprintf(`
+		SELECT dat.dag_task_id, dat.name, t.image, t.command, t.args, t.parameters, t.scriptInjectorImage, t.script, t.podTemplate, dr.pvcName
+		FROM Tasks t
+		JOIN DAG_Tasks dat ON dat.task_id = t.task_id
+		JOIN DAG_Runs dr ON dat.dag_id = dr.dag_id
+		WHERE 
+			dr.run_id = ?
+		AND 
+			dat.dag_task_id IN (%s)`, strings.Join(params, ","))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer rows.Close()
+
+	tasks := make([]Task, 0, len(taskIds))
+	parameters := make([][]string, 0, len(taskIds))
+
+	for rows.Next() {
+		var task Task
+		var commandJSON string
+		var argsJSON string
+		var paramsJson string
+		var podTemplateJSON *string
+		var pvcName sql.NullString
+
+		if err := rows.Scan(&task.Id, &task.Name, &task.Image, &commandJSON, &argsJSON, &paramsJson, &task.ScriptInjectorImage, &task.Script, &podTemplateJSON, &pvcName); err != nil {
+			return nil, nil, err
+		}
+
+		if err := json.Unmarshal([]byte(commandJSON), &task.Command); err != nil {
+			return nil, nil, err
+		}
+
+		if err := json.Unmarshal([]byte(argsJSON), &task.Args); err != nil {
+			return nil, nil, err
+		}
+
+		params := []string{}
+		if err := json.Unmarshal([]byte(paramsJson), &params); err != nil {
+			return nil, nil, err
+		}
+
+		parameters = append(parameters, params)
+
+		var podTemplate *v1alpha1.PodTemplateSpec
+		if podTemplateJSON != nil {
+			if err := json.Unmarshal([]byte(*podTemplateJSON), &podTemplate); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			podTemplate = &v1alpha1.PodTemplateSpec{}
+		}
+
+		if pvcName.Valid {
+			podTemplate.Volumes = append(podTemplate.Volumes, v1alpha1.Volume{
+				Name:                  "workspace",
+				PersistentVolumeClaim: &v1alpha1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName.String},
+			})
+
+			podTemplate.VolumeMounts = append(podTemplate.VolumeMounts, v1alpha1.VolumeMount{
+				Name:      "workspace",
+				MountPath: "/workspace",
+			})
+		}
+
+		task.PodTemplate = podTemplate
 
-	return dependencyCounts, nil
+		tasks = append(tasks, task)
+	}
+	return tasks, parameters, nil
 }
 
-func (s *sqliteDAGManager) fetchTaskParameters(ctx context.Context, tx *sql.Tx, dagId int, tasks []Task, parameters [][]string) error {
-	// Build a map of parameter name -> task indices that reference it
-	paramIndices := make(map[string][]int)
+// fetchTaskParameters fills in the parameters of tasks, values given to the run overriding the defaults of the DAG
+func (s *sqliteDAGManager) fetchTaskParameters(ctx context.Context, tx *sql.Tx, dagId, runId int, tasks []Task, parameters [][]string) error {
+	// Collect the names of the parameters any of the tasks reference
 	uniqueParams := make(map[string]struct{})
-	for i, taskParams := range parameters {
+	for _, taskParams := range parameters {
 		for _, p := range taskParams {
-			paramIndices[p] = append(paramIndices[p], i)
-			if _, ok := uniqueParams[p]; !ok {
-				uniqueParams[p] = struct{}{}
-			}
+			uniqueParams[p] = struct{}{}
 		}
 	}
 
@@ -1218,19 +2200,27 @@ func (s *sqliteDAGManager) fetchTaskParameters(ctx context.Context, tx *sql.Tx,
 		flattened = append(flattened, name)
 	}
 
-	// Build placeholders and args for the query: dag_id + param names
+	// Build placeholders and args for the query: dag_id + param names, then run_id + param names
 	placeholders := make([]string, 0, len(flattened))
-	args := make([]interface{}, 0, len(flattened)+1)
-	args = append(args, dagId)
+	names := make([]interface{}, 0, len(flattened))
 	for _, name := range flattened {
 		placeholders = append(placeholders, "?")
-		args = append(args, name)
+		names = append(names, name)
 	}
 
+	args := append([]interface{}{dagId}, names...)
+	args = append(args, runId)
+	args = append(args, names...)
+
 	query := fmt.Sprintf(`
-		SELECT name, isSecret, defaultValue
+		SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue, 0 AS priority
 		FROM DAG_Parameters
-		WHERE dag_id = ? AND name IN (%s);
+		WHERE dag_id = ? AND name IN (%[1]s)
+		UNION ALL
+		SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), value, 1 AS priority
+		FROM DAG_Run_Parameters
+		WHERE run_id = ? AND name IN (%[1]s)
+		ORDER BY priority;
 		`, strings.Join(placeholders, ","))
 
 	rows, err := tx.QueryContext(ctx, query, args...)
@@ -1239,27 +2229,27 @@ func (s *sqliteDAGManager) fetchTaskParameters(ctx context.Context, tx *sql.Tx,
 	}
 	defer rows.Close()
 
-	// Initialize task parameter slices
-	for i := range tasks {
-		tasks[i].Parameters = []Parameter{}
-	}
-
-	// Populate parameters for tasks from query results
+	values := make(map[string]Parameter, len(flattened))
 	for rows.Next() {
-		var name string
-		var isSecret bool
-		var value string
-		if err := rows.Scan(&name, &isSecret, &value); err != nil {
+		var param Parameter
+		var priority int
+		if err := rows.Scan(&param.Name, &param.IsSecret, &param.IsConfigMap, &param.Key, &param.Value, &priority); err != nil {
 			return err
 		}
+		values[param.Name] = param
+	}
 
-		indices := paramIndices[name]
-		for _, idx := range indices {
-			tasks[idx].Parameters = append(tasks[idx].Parameters, Parameter{
-				Name:     name,
-				IsSecret: isSecret,
-				Value:    value,
-			})
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Populate parameters for tasks in the order they declare them
+	for i := range tasks {
+		tasks[i].Parameters = []Parameter{}
+		for _, name := range parameters[i] {
+			if value, ok := values[name]; ok {
+				tasks[i].Parameters = append(tasks[i].Parameters, value)
+			}
 		}
 	}
 
@@ -1283,7 +2273,7 @@ func (s *sqliteDAGManager) MarkDAGRunOutcome(ctx context.Context, dagRunId int,
 
 func (s *sqliteDAGManager) GetDagParameters(ctx context.Context, dagName string) (map[string]*Parameter, error) {
 	rows, err := s.db.Query(`
-	SELECT name, isSecret, defaultValue
+	SELECT name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue
 	FROM DAG_Parameters
 	WHERE dag_id IN (
 		SELECT dag_id
@@ -1303,7 +2293,7 @@ func (s *sqliteDAGManager) GetDagParameters(ctx context.Context, dagName string)
 	parameters := map[string]*Parameter{}
 	for rows.Next() {
 		var parameter Parameter
-		if err := rows.Scan(&parameter.Name, &parameter.IsSecret, &parameter.Value); err != nil {
+		if err := rows.Scan(&parameter.Name, &parameter.IsSecret, &parameter.IsConfigMap, &parameter.Key, &parameter.Value); err != nil {
 			return nil, err
 		}
 
@@ -1355,8 +2345,8 @@ func (s *sqliteDAGManager) ShouldRerun(ctx context.Context, taskRunID int, exitC
 		return false, fmt.Errorf("failed to execute query: %w", err)
 	}
 
-	// Perform the check in Go
-	if attempts > backoffLimit {
+	// Perform the check in Go, attempts is the number of retries made before this task run
+	if attempts >= backoffLimit {
 		return false, nil
 	}
 
@@ -1385,24 +2375,45 @@ func (s *sqliteDAGManager) MarkTaskAsFailed(ctx context.Context, taskRunId int)
 
 	defer tx.Rollback()
 
-	if _, err := tx.Exec(`
+	var runId, taskId int
+	var mapIndex *int
+	if err := tx.QueryRowContext(ctx, `
 		UPDATE Task_Runs 
 		SET status = 'failed' 
-		WHERE task_run_id = ? ;
-	`, taskRunId); err != nil {
+		WHERE task_run_id = ?
+		RETURNING run_id, task_id, map_index;
+	`, taskRunId).Scan(&runId, &taskId, &mapIndex); err != nil {
 		return err
 	}
 
+	failed := 1
+	if mapIndex != nil {
+		// instances that have not started yet are abandoned
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE Task_Runs
+			SET status = 'suspended'
+			WHERE run_id = ? AND task_id = ? AND status = 'waiting';
+		`, runId, taskId); err != nil {
+			return err
+		}
+
+		statuses, err := s.getTaskStatuses(ctx, tx, runId)
+		if err != nil {
+			return err
+		}
+
+		// the map task is counted once its last running instance finishes
+		if statuses[taskId] == "running" {
+			failed = 0
+		}
+	}
+
 	if _, err := tx.Exec(`
 	    UPDATE DAG_Runs
 	    SET
-	        failedCount = failedCount + 1,
+	        failedCount = failedCount + ?,
 	        status = 'failed'
-	    WHERE run_id in (
-			SELECT run_id
-			FROM Task_Runs
-			WHERE task_run_id = ?
-		);`, taskRunId); err != nil {
+	    WHERE run_id = ?;`, failed, runId); err != nil {
 		return err
 	}
 
@@ -1591,6 +2602,30 @@ func (s *sqliteDAGManager) DeleteDAG(ctx context.Context, name string, namespace
 		return nil, err
 	}
 
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM DAG_Completion_Triggers
+		WHERE dag_id IN (SELECT dag_id FROM DAGs WHERE name = ? AND namespace = ?)
+		`, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM DAG_Backfills
+		WHERE dag_name = ? AND namespace = ?;
+		`, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM Task_Cache
+		WHERE dag_name = ? AND namespace = ?;
+		`, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	// Now, delete the DAG itself
 	_, err = tx.ExecContext(ctx, `
 		DELETE FROM DAGs
@@ -1655,14 +2690,126 @@ func (s *sqliteDAGManager) GetTaskScriptAndInjectorImage(ctx context.Context, ta
 
 func (s *sqliteDAGManager) AddPendingTaskRun(ctx context.Context, runId int, dagTaskId int) (int, error) {
 	var taskRunId int
-	err := s.db.QueryRowContext(ctx, `INSERT INTO Task_Runs (run_id, task_id, status, attempts) VALUES (?, ?, 'pending', 0) RETURNING task_run_id`, runId, dagTaskId).Scan(&taskRunId)
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		dagId, task, err := s.getDAGTask(ctx, tx, dagTaskId)
+		if err != nil {
+			return err
+		}
+
+		priority, err := s.getTaskRunPriority(ctx, tx, runId, dagTaskId)
+		if err != nil {
+			return err
+		}
+
+		// the scheduler starts the child run of a sub-DAG task, not a worker
+		if task.dagRef != "" {
+			return tx.QueryRowContext(ctx, `INSERT INTO Task_Runs (run_id, task_id, status, attempts, timeout_at, priority, queued_at) VALUES (?, ?, 'pending_dag', 0, datetime('now', '+' || ? || ' seconds'), ?, datetime('now')) RETURNING task_run_id`, runId, dagTaskId, task.timeoutSeconds, priority).Scan(&taskRunId)
+		}
+
+		if !task.isMap() {
+			return tx.QueryRowContext(ctx, `INSERT INTO Task_Runs (run_id, task_id, status, attempts, timeout_at, priority, queued_at) VALUES (?, ?, 'pending', 0, datetime('now', '+' || ? || ' seconds'), ?, datetime('now')) RETURNING task_run_id`, runId, dagTaskId, task.timeoutSeconds, priority).Scan(&taskRunId)
+		}
+
+		items, err := s.getMapItems(ctx, tx, runId, dagId, task)
+		if err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			return fmt.Errorf("map task %d has no items", dagTaskId)
+		}
+
+		// instances over the parallelism limit wait for a running one to succeed
+		for i, item := range items {
+			status := "pending"
+			if task.mapMaxParallelism > 0 && i >= task.mapMaxParallelism {
+				status = "waiting"
+			}
+
+			var id int
+			if err := tx.QueryRowContext(ctx, `
+				INSERT INTO Task_Runs (run_id, task_id, status, attempts, map_index, map_item, timeout_at, priority, queued_at)
+				VALUES (?, ?, ?, 0, ?, ?, datetime('now', '+' || ? || ' seconds'), ?, datetime('now'))
+				RETURNING task_run_id`, runId, dagTaskId, status, i, item, task.timeoutSeconds, priority).Scan(&id); err != nil {
+				return err
+			}
+
+			if i == 0 {
+				taskRunId = id
+			}
+		}
+
+		return nil
+	})
+
 	if err != nil {
 		return 0, err
 	}
+
 	return taskRunId, nil
 }
 
-func (s *sqliteDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTaskId int) (Task, string, string, error) {
+// getDAGTask returns the DAG a task belongs to along with how it runs
+func (s *sqliteDAGManager) getDAGTask(ctx context.Context, tx *sql.Tx, dagTaskId int) (int, *dagTask, error) {
+	var dagId int
+	task := &dagTask{}
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT dag_id, COALESCE(whenExpr, ''), COALESCE(triggerRule, ''), COALESCE(mapParameter, ''), COALESCE(mapOutput, ''), mapMaxParallelism, COALESCE(dagRefName, ''), timeoutSeconds
+		FROM DAG_Tasks
+		WHERE dag_task_id = ?;`, dagTaskId).Scan(&dagId, &task.when, &task.triggerRule, &task.mapParameter, &task.mapOutput, &task.mapMaxParallelism, &task.dagRef, &task.timeoutSeconds); err != nil {
+		return 0, nil, err
+	}
+
+	return dagId, task, nil
+}
+
+// getTaskRunPriority is the priority of the run, or of its DAG when the run has none, plus that of the task
+func (s *sqliteDAGManager) getTaskRunPriority(ctx context.Context, tx *sql.Tx, runId, dagTaskId int) (int, error) {
+	var priority int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT dt.priority + COALESCE(r.priority, d.priority)
+		FROM DAG_Runs r
+		JOIN DAGs d ON d.dag_id = r.dag_id
+		JOIN DAG_Tasks dt ON dt.dag_task_id = ?
+		WHERE r.run_id = ?;`, dagTaskId, runId).Scan(&priority); err != nil {
+		return 0, err
+	}
+
+	return priority, nil
+}
+
+func (s *sqliteDAGManager) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	var attempts int
+	var backoff retryBackoff
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT r.attempts, t.backoffInitialDelaySeconds, t.backoffMaxDelaySeconds, t.backoffMultiplier, t.backoffJitter
+		FROM Task_Runs r
+		JOIN DAG_Tasks dt ON dt.dag_task_id = r.task_id
+		JOIN Tasks t ON t.task_id = dt.task_id
+		WHERE r.task_run_id = ?`, taskRunId).Scan(&attempts, &backoff.initialDelaySeconds, &backoff.maxDelaySeconds, &backoff.multiplier, &backoff.jitter); err != nil {
+		return 0, 0, err
+	}
+
+	delay := backoff.delay(attempts+1, rand.Float64())
+
+	// the new row counts the retries made so far, ShouldRerun stops once it reaches the backoff limit
+	var newTaskRunId int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO Task_Runs (run_id, task_id, status, attempts, map_index, map_item, timeout_at, scheduled_start, priority, queued_at)
+		SELECT run_id, task_id, 'pending', attempts + 1, map_index, map_item, timeout_at, datetime('now', '+' || ? || ' seconds'), priority, datetime('now')
+		FROM Task_Runs
+		WHERE task_run_id = ?
+		RETURNING task_run_id`, scheduledStartSeconds(delay), taskRunId).Scan(&newTaskRunId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return newTaskRunId, delay, nil
+}
+
+func (s *sqliteDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTaskId int, taskRunId int) (Task, string, string, error) {
 	var task Task
 	var podTemplateJSON *string
 	var script sql.NullString
@@ -1672,21 +2819,69 @@ func (s *sqliteDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTask
 	var dagId int
 
 	var paramStr sql.NullString
+	var commandJSON sql.NullString
+	var argsJSON sql.NullString
+	var outputsJSON sql.NullString
+	var scheduledTime sql.NullTime
+	var runTime time.Time
+	var cacheTTL sql.NullInt64
+	var artifactsJSON sql.NullString
+	var sensorJSON sql.NullString
 	err := s.db.QueryRowContext(ctx, `
-	SELECT dat.dag_task_id, dat.name, t.image, t.command, t.args, t.parameters, t.scriptInjectorImage, t.script, t.podTemplate, d.namespace, dr.pvcName, tr.retry_env, dr.dag_id
+	SELECT dat.dag_task_id, dat.name, t.image, t.command, t.args, t.parameters, t.scriptInjectorImage, t.script, t.podTemplate, d.namespace, dr.pvcName, tr.retry_env, dr.dag_id, t.outputs, dr.scheduled_time, dr.run_time, COALESCE(t.hash, ''), dat.cacheTTLSeconds, t.artifacts, dat.sensor
 	FROM Tasks t
 	JOIN DAG_Tasks dat ON dat.task_id = t.task_id
 	JOIN DAG_Runs dr ON dat.dag_id = dr.dag_id
 	JOIN DAGs d ON dr.dag_id = d.dag_id
-	LEFT JOIN Task_Runs tr ON tr.run_id = dr.run_id AND tr.task_id = dat.dag_task_id
-	WHERE dr.run_id = ? AND dat.dag_task_id = ?
-	LIMIT 1;
-	`, runId, dagTaskId).Scan(&task.Id, &task.Name, &task.Image, &task.Command, &task.Args, &paramStr, &task.ScriptInjectorImage, &script, &podTemplateJSON, &namespace, &pvcName, &retryEnv, &dagId)
+	LEFT JOIN Task_Runs tr ON tr.task_run_id = ? AND tr.run_id = dr.run_id AND tr.task_id = dat.dag_task_id
+	WHERE dr.run_id = ? AND dat.dag_task_id = ?;
+	`, taskRunId, runId, dagTaskId).Scan(&task.Id, &task.Name, &task.Image, &commandJSON, &argsJSON, &paramStr, &task.ScriptInjectorImage, &script, &podTemplateJSON, &namespace, &pvcName, &retryEnv, &dagId, &outputsJSON, &scheduledTime, &runTime, &task.Hash, &cacheTTL, &artifactsJSON, &sensorJSON)
 
 	if err != nil {
 		return Task{}, "", "", err
 	}
 
+	if cacheTTL.Valid {
+		task.CacheTTL = time.Duration(cacheTTL.Int64) * time.Second
+	}
+
+	task.LogicalTime = runTime
+	if scheduledTime.Valid {
+		task.ScheduledTime = &scheduledTime.Time
+		task.LogicalTime = scheduledTime.Time
+	}
+
+	// SQLite stores the slices as JSON strings
+	if commandJSON.Valid {
+		if err := json.Unmarshal([]byte(commandJSON.String), &task.Command); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
+	if argsJSON.Valid {
+		if err := json.Unmarshal([]byte(argsJSON.String), &task.Args); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
+	if outputsJSON.Valid {
+		if err := json.Unmarshal([]byte(outputsJSON.String), &task.Outputs); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
+	if artifactsJSON.Valid {
+		if err := json.Unmarshal([]byte(artifactsJSON.String), &task.Artifacts); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
+	if sensorJSON.Valid {
+		if err := json.Unmarshal([]byte(sensorJSON.String), &task.Sensor); err != nil {
+			return Task{}, "", "", err
+		}
+	}
+
 	if script.Valid {
 		task.Script = script.String
 	}
@@ -1730,7 +2925,7 @@ func (s *sqliteDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTask
 
 	tasks := []Task{task}
 	paramsMatrix := [][]string{params}
-	if err := s.fetchTaskParameters(ctx, tx, dagId, tasks, paramsMatrix); err != nil {
+	if err := s.fetchTaskParameters(ctx, tx, dagId, runId, tasks, paramsMatrix); err != nil {
 		return Task{}, "", "", err
 	}
 
@@ -1741,6 +2936,22 @@ func (s *sqliteDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTask
 		task.Parameters = []Parameter{}
 	}
 
+	task.UpstreamOutputs, err = s.getUpstreamOutputs(ctx, tx, runId, dagTaskId)
+	if err != nil {
+		return Task{}, "", "", err
+	}
+
+	task.UpstreamArtifacts, err = s.getUpstreamArtifacts(ctx, tx, runId, dagTaskId)
+	if err != nil {
+		return Task{}, "", "", err
+	}
+
+	runParams, err := s.getRunParameters(ctx, tx, runId, dagId)
+	if err != nil {
+		return Task{}, "", "", err
+	}
+	task.RunParameters = literalParameters(runParams)
+
 	var retry string
 	if retryEnv.Valid {
 		retry = retryEnv.String
@@ -1753,14 +2964,135 @@ func (s *sqliteDAGManager) GetTaskForRun(ctx context.Context, runId int, dagTask
 	return task, namespace, retry, nil
 }
 
+// getUpstreamOutputs collects the outputs published by every successful task
+// the given dag task depends on, directly or transitively, within the run.
+func (s *sqliteDAGManager) getUpstreamOutputs(ctx context.Context, tx *sql.Tx, runId, dagTaskId int) ([]TaskOutput, error) {
+	rows, err := tx.QueryContext(ctx, `
+	WITH RECURSIVE upstream AS (
+		SELECT depends_on_task_id AS task_id
+		FROM Dependencies
+		WHERE task_id = ?
+		UNION
+		SELECT d.depends_on_task_id
+		FROM Dependencies d
+		JOIN upstream u ON d.task_id = u.task_id
+	)
+	SELECT dt.name, tr.outputs
+	FROM Task_Runs tr
+	JOIN upstream u ON tr.task_id = u.task_id
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	WHERE tr.run_id = ? AND tr.status IN ('success', 'cached') AND tr.outputs IS NOT NULL AND tr.map_index IS NULL;
+	`, dagTaskId, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	outputs := []TaskOutput{}
+	for rows.Next() {
+		var taskName string
+		var raw string
+		if err := rows.Scan(&taskName, &raw); err != nil {
+			return nil, err
+		}
+
+		values := map[string]string{}
+		if err := json.Unmarshal([]byte(raw), &values); err != nil {
+			return nil, err
+		}
+
+		outputs = appendTaskOutputs(outputs, taskName, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortTaskOutputs(outputs)
+	return outputs, nil
+}
+
+// getUpstreamArtifacts collects the artifacts published by every successful run of the tasks
+// the given dag task depends on, directly or transitively, within the run.
+func (s *sqliteDAGManager) getUpstreamArtifacts(ctx context.Context, tx *sql.Tx, runId, dagTaskId int) ([]TaskArtifact, error) {
+	rows, err := tx.QueryContext(ctx, `
+	WITH RECURSIVE upstream AS (
+		SELECT depends_on_task_id AS task_id
+		FROM Dependencies
+		WHERE task_id = ?
+		UNION
+		SELECT d.depends_on_task_id
+		FROM Dependencies d
+		JOIN upstream u ON d.task_id = u.task_id
+	)
+	SELECT dt.name, tr.map_index, t.artifacts
+	FROM Task_Runs tr
+	JOIN upstream u ON tr.task_id = u.task_id
+	JOIN DAG_Tasks dt ON dt.dag_task_id = tr.task_id
+	JOIN Tasks t ON t.task_id = dt.task_id
+	WHERE tr.run_id = ? AND tr.status = 'success' AND t.artifacts IS NOT NULL;
+	`, dagTaskId, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := []TaskArtifact{}
+	for rows.Next() {
+		var taskName, raw string
+		var mapIndex sql.NullInt64
+		if err := rows.Scan(&taskName, &mapIndex, &raw); err != nil {
+			return nil, err
+		}
+
+		var index *int
+		if mapIndex.Valid {
+			i := int(mapIndex.Int64)
+			index = &i
+		}
+
+		artifacts, err = appendTaskArtifacts(artifacts, taskName, index, raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortTaskArtifacts(artifacts)
+	return artifacts, nil
+}
+
 func (s *sqliteDAGManager) SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error {
 	_, err := s.db.ExecContext(ctx, `UPDATE Task_Runs SET retry_env = ? WHERE task_run_id = ?`, envJSON, taskRunId)
 	return err
 }
 
+func (s *sqliteDAGManager) SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error {
+	value, err := json.Marshal(outputs)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE Task_Runs SET outputs = ? WHERE task_run_id = ?`, string(value), taskRunId)
+	return err
+}
+
 func (s *sqliteDAGManager) ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (TaskClaim, error) {
 	leaseAt := time.Now().Add(leaseTTL).Format("2006-01-02 15:04:05")
-	res, err := s.db.ExecContext(ctx, `UPDATE Task_Runs SET claimed_by = ?, claimed_at = datetime('now'), lease_expires_at = ? WHERE task_run_id = ? AND status = 'pending' AND (claimed_by IS NULL OR lease_expires_at <= datetime('now'))`, workerId, leaseAt, taskRunId)
+	res, err := s.db.ExecContext(ctx, `
+	UPDATE Task_Runs SET claimed_by = ?, claimed_at = datetime('now'), lease_expires_at = ?
+	WHERE task_run_id = ? AND status = 'pending' AND (claimed_by IS NULL OR lease_expires_at <= datetime('now')) AND (scheduled_start IS NULL OR scheduled_start <= datetime('now'))
+	AND NOT EXISTS (
+		SELECT 1
+		FROM DAG_Tasks dt
+		JOIN DAGs d ON d.dag_id = dt.dag_id
+		LEFT JOIN Pool_Usage pu ON pu.name = dt.pool AND pu.namespace = d.namespace
+		WHERE dt.dag_task_id = Task_Runs.task_id AND dt.pool IS NOT NULL
+		AND (pu.pool_id IS NULL OR dt.poolSlots > pu.slots - pu.occupied_slots)
+	)`, workerId, leaseAt, taskRunId)
 	if err != nil {
 		return TaskClaim{}, err
 	}
@@ -1773,7 +3105,7 @@ func (s *sqliteDAGManager) ClaimTaskByID(ctx context.Context, taskRunId int, wor
 	}
 	// fetch task_id and run_id
 	var c TaskClaim
-	if err := s.db.QueryRowContext(ctx, `SELECT task_run_id, task_id, run_id FROM Task_Runs WHERE task_run_id = ?`, taskRunId).Scan(&c.TaskRunID, &c.TaskID, &c.RunID); err != nil {
+	if err := s.db.QueryRowContext(ctx, `SELECT task_run_id, task_id, run_id, map_index, COALESCE(map_item, '') FROM Task_Runs WHERE task_run_id = ?`, taskRunId).Scan(&c.TaskRunID, &c.TaskID, &c.RunID, &c.MapIndex, &c.MapItem); err != nil {
 		return TaskClaim{}, err
 	}
 	return c, nil
@@ -1855,13 +3187,25 @@ func (s *sqliteDAGManager) AddTask(ctx context.Context, task *v1alpha1.DagTask,
 		return err
 	}
 
+	outputsJson, err := json.Marshal(task.Spec.Outputs)
+	if err != nil {
+		return err
+	}
+
+	artifactsJson, err := artifactsColumn(task.Spec.Artifacts)
+	if err != nil {
+		return err
+	}
+
 	newVersion := version + 1
 
+	backoff := backoffColumns(task.Spec.Backoff)
 	if _, err := tx.ExecContext(ctx, `
-    INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version, hash)
-    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?, ?, ?);`,
+    INSERT INTO Tasks (name, command, args, image, parameters, backoffLimit, isConditional, retryCodes, podTemplate, script, scriptInjectorImage, inline, namespace, version, hash, outputs, backoffInitialDelaySeconds, backoffMaxDelaySeconds, backoffMultiplier, backoffJitter, artifacts)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
 		task.Name, commandJson, argsJson, task.Spec.Image, paramsJson, task.Spec.Backoff.Limit,
-		task.Spec.Conditional.Enabled, retryCodesJson, jsonValue, task.Spec.Script, task.Spec.ScriptInjectorImage, namespace, newVersion, hashValue); err != nil {
+		task.Spec.Conditional.Enabled, retryCodesJson, jsonValue, task.Spec.Script, task.Spec.ScriptInjectorImage, namespace, newVersion, hashValue, outputsJson,
+		backoff.initialDelaySeconds, backoff.maxDelaySeconds, backoff.multiplier, backoff.jitter, artifactsJson); err != nil {
 		return err
 	}
 
@@ -1916,6 +3260,86 @@ func (s *sqliteDAGManager) DeleteTask(ctx context.Context, taskName, namespace s
 	return tx.Commit()
 }
 
+func (s *sqliteDAGManager) UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO Pools (name, namespace, slots)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name, namespace) DO UPDATE SET slots = excluded.slots;`, pool.Name, namespace, pool.Spec.Slots)
+	return err
+}
+
+func (s *sqliteDAGManager) DeletePool(ctx context.Context, name, namespace string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM Pools WHERE name = ? AND namespace = ?;`, name, namespace)
+	return err
+}
+
+func (s *sqliteDAGManager) GetPoolUsage(ctx context.Context) ([]PoolUsage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, namespace, slots, occupied_slots, queued_slots
+		FROM Pool_Usage
+		ORDER BY namespace, name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := []PoolUsage{}
+	for rows.Next() {
+		var pool PoolUsage
+		if err := rows.Scan(&pool.Name, &pool.Namespace, &pool.Slots, &pool.OccupiedSlots, &pool.QueuedSlots); err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+
+	return pools, rows.Err()
+}
+
+func (s *sqliteDAGManager) RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO Trigger_Events (namespace, trigger_name, event_id, dag_run_name)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (namespace, trigger_name, event_id) DO NOTHING;`, namespace, triggerName, eventId, dagRunName)
+	if err != nil {
+		return "", false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return "", false, err
+	}
+
+	if inserted > 0 {
+		return dagRunName, true, nil
+	}
+
+	var existing string
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT dag_run_name
+		FROM Trigger_Events
+		WHERE namespace = ? AND trigger_name = ? AND event_id = ?;`, namespace, triggerName, eventId).Scan(&existing); err != nil {
+		return "", false, err
+	}
+
+	return existing, false, nil
+}
+
+func (s *sqliteDAGManager) GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error) {
+	var dagRunName string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT dag_run_name
+		FROM Trigger_Events
+		WHERE namespace = ? AND trigger_name = ? AND event_id = ?;`, namespace, triggerName, eventId).Scan(&dagRunName)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return dagRunName, true, nil
+}
+
 func (s *sqliteDAGManager) GetWebhookDetails(ctx context.Context, dagRunID int) (*v1alpha1.Webhook, error) {
 	webhook := &v1alpha1.Webhook{}
 
@@ -1998,150 +3422,85 @@ func (s *sqliteDAGManager) MarkConnectingTasksAsSuspended(ctx context.Context, d
 	}
 	defer tx.Rollback()
 
-	// Get existing task runs first to avoid duplicates
-	existingRuns, err := tx.QueryContext(ctx, `
-        SELECT task_id
-        FROM Task_Runs
-        WHERE run_id = ?;
-    `, dagRunId)
+	dagId, err := s.getDAGIdFromRun(ctx, tx, dagRunId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch existing task runs: %w", err)
+		return nil, fmt.Errorf("failed to get dag id: %w", err)
 	}
-	existingTaskRuns := make(map[int]bool)
-	for existingRuns.Next() {
-		var taskID int
-		if err := existingRuns.Scan(&taskID); err != nil {
-			existingRuns.Close()
-			return nil, fmt.Errorf("failed to scan existing task run: %w", err)
-		}
-		existingTaskRuns[taskID] = true
-	}
-	existingRuns.Close()
 
-	// Rest of implementation remains the same, just modify the DFS logic
-	rows, err := tx.QueryContext(ctx, `
-        SELECT d.depends_on_task_id, d.task_id
-        FROM Dependencies d
-        JOIN DAG_Tasks dt ON d.depends_on_task_id = dt.dag_task_id
-        WHERE dt.dag_id = (
-            SELECT dag_id FROM DAG_Runs WHERE run_id = ?
-        );
-    `, dagRunId)
+	// the trigger rules decide which downstream tasks can no longer run
+	_, suspended, err := s.resolveTasks(ctx, tx, dagRunId, dagId, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch dependencies: %w", err)
+		return nil, err
 	}
 
-	// Store dependencies in a map for fast lookups
-	dependencies := make(map[int][]int)
-	for rows.Next() {
-		var parentTaskID, dependentTaskID int
-		if err := rows.Scan(&parentTaskID, &dependentTaskID); err != nil {
-			rows.Close()
-			return nil, fmt.Errorf("failed to scan dependency row: %w", err)
+	// get task names
+	taskNames := []string{}
+	for _, taskID := range suspended {
+		var taskName string
+		if err := tx.QueryRowContext(ctx, `
+            SELECT name
+            FROM DAG_Tasks
+            WHERE dag_task_id = ?;
+        `, taskID).Scan(&taskName); err != nil {
+			return nil, fmt.Errorf("failed to get task name: %w", err)
 		}
-		dependencies[parentTaskID] = append(dependencies[parentTaskID], dependentTaskID)
-	}
-	rows.Close()
-
-	var startingTaskID int
-	if err := tx.QueryRowContext(ctx, `
-        SELECT task_id FROM Task_Runs WHERE task_run_id = ?;
-    `, taskRunId).Scan(&startingTaskID); err != nil {
-		return nil, fmt.Errorf("failed to get task id: %w", err)
+		taskNames = append(taskNames, taskName)
 	}
 
-	// DFS using stack
-	stack := []int{startingTaskID}
-	seen := make(map[int]bool)
-	uniqueUpdates := make(map[int]struct{})
-	var updates [][]interface{}
-	taskIdsSuspended := []int{}
-
-	for len(stack) > 0 {
-		currentTaskID := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		if seen[currentTaskID] {
-			continue
-		}
-		seen[currentTaskID] = true
+	return taskNames, tx.Commit()
+}
 
-		if dependentTasks, exists := dependencies[currentTaskID]; exists {
-			for _, taskID := range dependentTasks {
-				// Skip if task already has a status
-				if existingTaskRuns[taskID] {
-					continue
-				}
+func (s *sqliteDAGManager) GetReadyTasks(ctx context.Context, dagRunId int) ([]Task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-				if _, exists := uniqueUpdates[taskID]; !exists {
-					uniqueUpdates[taskID] = struct{}{}
-					taskIdsSuspended = append(taskIdsSuspended, taskID)
-					updates = append(updates, []interface{}{dagRunId, taskID, "suspended", 0})
-					stack = append(stack, taskID)
-				}
-			}
-		}
+	dagId, err := s.getDAGIdFromRun(ctx, tx, dagRunId)
+	if err != nil {
+		return nil, err
 	}
 
-	// Batch insert suspended tasks
-	if len(updates) > 0 {
-		stmt, err := tx.PrepareContext(ctx, `
-            INSERT INTO Task_Runs (run_id, task_id, status, attempts)
-            VALUES (?, ?, ?, ?);
-        `)
-		if err != nil {
-			return nil, fmt.Errorf("failed to prepare statement: %w", err)
-		}
-		defer stmt.Close()
+	tasks, parameters, err := s.getNextRunnableTasks(ctx, tx, dagRunId, dagId)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, update := range updates {
-			if _, err := stmt.ExecContext(ctx, update...); err != nil {
-				return nil, fmt.Errorf("failed to insert task run: %w", err)
-			}
+	if len(tasks) == 0 {
+		if _, err := s.markRunSuccessIfComplete(ctx, tx, dagRunId); err != nil {
+			return nil, err
 		}
 	}
 
-	// Update DAG Runs count
-	if _, err := tx.ExecContext(ctx, `
-        UPDATE DAG_Runs
-        SET suspendedCount = suspendedCount + ?
-        WHERE run_id = ?;`, len(updates), dagRunId); err != nil {
-		return nil, fmt.Errorf("failed to update DAG runs: %w", err)
+	if err := s.fetchTaskParameters(ctx, tx, dagId, dagRunId, tasks, parameters); err != nil {
+		return nil, err
 	}
 
-	// get task names
-	taskNames := []string{}
-	for _, taskID := range taskIdsSuspended {
-		var taskName string
-		if err := tx.QueryRowContext(ctx, `
-            SELECT name
-            FROM DAG_Tasks
-            WHERE dag_task_id = ?;
-        `, taskID).Scan(&taskName); err != nil {
-			return nil, fmt.Errorf("failed to get task name: %w", err)
-		}
-		taskNames = append(taskNames, taskName)
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	return taskNames, tx.Commit()
+	return tasks, nil
 }
 
 func (s *sqliteDAGManager) CheckIfAllTasksDone(ctx context.Context, dagRunID int) (bool, error) {
-	var taskCount, successCount, failedCount, suspendedCount int
+	var taskCount, successCount, failedCount, suspendedCount, skippedCount int
 	err := s.db.QueryRowContext(ctx, `
         SELECT 
             (SELECT COUNT(*) FROM DAG_Tasks WHERE dag_id = dr.dag_id) as task_count,
             dr.successfulCount,
             dr.failedCount,
-            dr.suspendedCount
+            dr.suspendedCount,
+            dr.skippedCount
         FROM DAG_Runs dr
         WHERE dr.run_id = ?;
-    `, dagRunID).Scan(&taskCount, &successCount, &failedCount, &suspendedCount)
+    `, dagRunID).Scan(&taskCount, &successCount, &failedCount, &suspendedCount, &skippedCount)
 	if err != nil {
 		return false, err
 	}
 
-	return taskCount == successCount+failedCount+suspendedCount, nil
+	return taskCount == successCount+failedCount+suspendedCount+skippedCount, nil
 }
 
 func (p *sqliteDAGManager) AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error {
@@ -2256,3 +3615,587 @@ func (s *sqliteDAGManager) GetTaskRunInfo(ctx context.Context, taskRunId int) (d
 	}
 	return dagName, taskName, namespace, nil
 }
+
+func (s *sqliteDAGManager) StartSubDagRuns(ctx context.Context) ([]SubDagRun, error) {
+	subDagRuns := []SubDagRun{}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE Task_Runs
+			SET claimed_at = datetime('now'),
+				lease_expires_at = datetime('now', '+' || ? || ' seconds')
+			WHERE status = 'pending_dag'
+			AND (lease_expires_at IS NULL OR lease_expires_at <= datetime('now'))
+			RETURNING task_run_id, run_id, task_id, priority;`, subDagLeaseSeconds)
+		if err != nil {
+			return err
+		}
+
+		taskIds := []int{}
+		for rows.Next() {
+			var subDagRun SubDagRun
+			var taskId int
+			if err := rows.Scan(&subDagRun.TaskRunId, &subDagRun.RunId, &taskId, &subDagRun.Priority); err != nil {
+				rows.Close()
+				return err
+			}
+			subDagRuns = append(subDagRuns, subDagRun)
+			taskIds = append(taskIds, taskId)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range subDagRuns {
+			var dagId int
+			var refsJSON string
+			if err := tx.QueryRowContext(ctx, `
+				SELECT dt.dag_id, d.namespace, dt.dagRefName, COALESCE(dt.dagRefParameters, '[]')
+				FROM DAG_Tasks dt
+				JOIN DAGs d ON d.dag_id = dt.dag_id
+				WHERE dt.dag_task_id = ?;`, taskIds[i]).Scan(&dagId, &subDagRuns[i].Namespace, &subDagRuns[i].DagName, &refsJSON); err != nil {
+				return err
+			}
+
+			refs := []v1alpha1.DagRefParameter{}
+			if err := json.Unmarshal([]byte(refsJSON), &refs); err != nil {
+				return err
+			}
+
+			parent, err := s.getRunParameters(ctx, tx, subDagRuns[i].RunId, dagId)
+			if err != nil {
+				return err
+			}
+
+			subDagRuns[i].Parameters = subDagParameters(refs, parent)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return subDagRuns, nil
+}
+
+// getRunParameters returns every parameter of a run, values given to the run overriding the defaults of the DAG
+func (s *sqliteDAGManager) getRunParameters(ctx context.Context, tx *sql.Tx, runId, dagId int) (map[string]Parameter, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT name, defaultValue, isSecret, isConfigMap, COALESCE(valueKey, ''), 0 AS priority FROM DAG_Parameters WHERE dag_id = ?
+		UNION ALL
+		SELECT name, value, isSecret, isConfigMap, COALESCE(valueKey, ''), 1 AS priority FROM DAG_Run_Parameters WHERE run_id = ?
+		ORDER BY priority;`, dagId, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	params := map[string]Parameter{}
+	for rows.Next() {
+		var param Parameter
+		var priority int
+		if err := rows.Scan(&param.Name, &param.Value, &param.IsSecret, &param.IsConfigMap, &param.Key, &priority); err != nil {
+			return nil, err
+		}
+		params[param.Name] = param
+	}
+
+	return params, rows.Err()
+}
+
+func (s *sqliteDAGManager) LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE DAG_Runs
+			SET parent_task_run_id = ?,
+				parent_run_id = (SELECT run_id FROM Task_Runs WHERE task_run_id = ?)
+			WHERE run_id = ?;`, parentTaskRunId, parentTaskRunId, runId); err != nil {
+			return err
+		}
+
+		// the dagRef task only runs once its child run exists
+		_, err := tx.ExecContext(ctx, `
+			UPDATE Task_Runs
+			SET status = 'running', claimed_at = NULL, lease_expires_at = NULL
+			WHERE task_run_id = ? AND status = 'pending_dag';`, parentTaskRunId)
+		return err
+	})
+}
+
+func (s *sqliteDAGManager) GetFinishedSubDagRuns(ctx context.Context) ([]FinishedSubDagRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tr.task_run_id, tr.run_id, child.run_id, child.status, parentDag.namespace, parent.pvcName
+		FROM Task_Runs tr
+		JOIN DAG_Runs child ON child.parent_task_run_id = tr.task_run_id
+		JOIN DAGs childDag ON childDag.dag_id = child.dag_id
+		JOIN DAG_Runs parent ON parent.run_id = tr.run_id
+		JOIN DAGs parentDag ON parentDag.dag_id = parent.dag_id
+		WHERE tr.status = 'running'
+		AND (child.status = 'timed_out' OR childDag.taskCount = child.successfulCount + child.failedCount + child.suspendedCount + child.skippedCount);`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	finished := []FinishedSubDagRun{}
+	for rows.Next() {
+		var run FinishedSubDagRun
+		var status string
+		if err := rows.Scan(&run.TaskRunId, &run.RunId, &run.ChildRunId, &status, &run.Namespace, &run.PVCName); err != nil {
+			return nil, err
+		}
+
+		run.Succeeded = status == "success"
+		finished = append(finished, run)
+	}
+
+	return finished, rows.Err()
+}
+
+func (s *sqliteDAGManager) ClaimFinishedDagRuns(ctx context.Context) ([]DownstreamDagRun, error) {
+	downstream := []DownstreamDagRun{}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// a failed run is only finished once the tasks it still has running are done
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE DAG_Runs
+			SET completion_lease_expires_at = datetime('now', '+' || ? || ' seconds')
+			WHERE completion_handled = FALSE
+			AND (completion_lease_expires_at IS NULL OR completion_lease_expires_at <= datetime('now'))
+			AND (status IN ('timed_out', 'suspended') OR (status IN ('success', 'failed') AND (
+				SELECT taskCount FROM DAGs WHERE DAGs.dag_id = DAG_Runs.dag_id
+			) = successfulCount + failedCount + suspendedCount + skippedCount))
+			RETURNING run_id, dag_id, name, status;`, completionLeaseSeconds)
+		if err != nil {
+			return err
+		}
+
+		finished := []finishedDagRun{}
+		for rows.Next() {
+			var run finishedDagRun
+			if err := rows.Scan(&run.runId, &run.dagId, &run.name, &run.status); err != nil {
+				rows.Close()
+				return err
+			}
+			finished = append(finished, run)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, run := range finished {
+			triggers, err := s.getCompletionTriggers(ctx, tx, run.dagId)
+			if err != nil {
+				return err
+			}
+
+			// a run starting nothing is handled straight away
+			matched := matchingCompletionTriggers(triggers, run.status)
+			if len(matched) == 0 {
+				if _, err := tx.ExecContext(ctx, `
+					UPDATE DAG_Runs
+					SET completion_handled = TRUE, completion_lease_expires_at = NULL
+					WHERE run_id = ?;`, run.runId); err != nil {
+					return err
+				}
+				continue
+			}
+
+			upstream, err := s.getRunParameters(ctx, tx, run.runId, run.dagId)
+			if err != nil {
+				return err
+			}
+
+			downstream = append(downstream, downstreamDagRuns(run, matched, upstream)...)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return downstream, nil
+}
+
+// getCompletionTriggers returns the onDagCompletion triggers of the active, unsuspended DAGs waiting for a DAG
+func (s *sqliteDAGManager) getCompletionTriggers(ctx context.Context, tx *sql.Tx, upstreamDagId int) ([]completionTrigger, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT ct.trigger_id, d.name, d.namespace, up.namespace, ct.outcome, COALESCE(ct.parameters, '[]')
+		FROM DAGs up
+		JOIN DAG_Completion_Triggers ct ON ct.upstream_name = up.name AND ct.upstream_namespace = up.namespace
+		JOIN DAGs d ON d.dag_id = ct.dag_id
+		WHERE up.dag_id = ? AND d.active = TRUE AND d.suspended = FALSE
+		ORDER BY ct.trigger_id;`, upstreamDagId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := []completionTrigger{}
+	for rows.Next() {
+		var trigger completionTrigger
+		var outcome, paramsJSON string
+		if err := rows.Scan(&trigger.triggerId, &trigger.dagName, &trigger.namespace, &trigger.upstreamNamespace, &outcome, &paramsJSON); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(paramsJSON), &trigger.parameters); err != nil {
+			return nil, err
+		}
+		trigger.outcome = v1alpha1.DagCompletionOutcome(outcome)
+		triggers = append(triggers, trigger)
+	}
+
+	return triggers, rows.Err()
+}
+
+func (s *sqliteDAGManager) MarkDagRunCompletionHandled(ctx context.Context, runId int) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE DAG_Runs
+		SET completion_handled = TRUE, completion_lease_expires_at = NULL
+		WHERE run_id = ?;`, runId)
+	return err
+}
+
+// getDagCompletionTriggers returns the upstream DAGs the onDagCompletion triggers of each active DAG wait for
+func (s *sqliteDAGManager) getDagCompletionTriggers(ctx context.Context, tx *sql.Tx) (map[types.NamespacedName][]types.NamespacedName, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT d.name, d.namespace, ct.upstream_name, ct.upstream_namespace
+		FROM DAG_Completion_Triggers ct
+		JOIN DAGs d ON d.dag_id = ct.dag_id
+		WHERE d.active = TRUE;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	upstreams := map[types.NamespacedName][]types.NamespacedName{}
+	for rows.Next() {
+		var dag, upstream types.NamespacedName
+		if err := rows.Scan(&dag.Name, &dag.Namespace, &upstream.Name, &upstream.Namespace); err != nil {
+			return nil, err
+		}
+		upstreams[dag] = append(upstreams[dag], upstream)
+	}
+
+	return upstreams, rows.Err()
+}
+
+func (s *sqliteDAGManager) TimeOutTaskRuns(ctx context.Context) ([]StoppedTaskRun, error) {
+	timedOut := []StoppedTaskRun{}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE Task_Runs
+			SET status = 'timed_out', claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
+			WHERE status IN ('pending', 'pending_dag', 'running', 'waiting')
+			AND timeout_at <= datetime('now')
+			RETURNING task_run_id, run_id, task_id;`)
+		if err != nil {
+			return err
+		}
+
+		taskIds := []int{}
+		for rows.Next() {
+			var run StoppedTaskRun
+			var taskId int
+			if err := rows.Scan(&run.TaskRunId, &run.RunId, &taskId); err != nil {
+				rows.Close()
+				return err
+			}
+			timedOut = append(timedOut, run)
+			taskIds = append(taskIds, taskId)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// the instances of a map task time out together and count as one failed task
+		counted := map[[2]int]bool{}
+		for i := range timedOut {
+			if err := s.describeStoppedTaskRun(ctx, tx, &timedOut[i], taskIds[i]); err != nil {
+				return err
+			}
+
+			key := [2]int{timedOut[i].RunId, taskIds[i]}
+			if counted[key] {
+				continue
+			}
+			counted[key] = true
+
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE DAG_Runs
+				SET failedCount = failedCount + 1,
+					status = CASE WHEN status = 'timed_out' THEN status ELSE 'failed' END
+				WHERE run_id = ?;`, timedOut[i].RunId); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return timedOut, nil
+}
+
+func (s *sqliteDAGManager) TimeOutDagRuns(ctx context.Context) ([]StoppedDagRun, error) {
+	timedOut := []StoppedDagRun{}
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// a failed run can still have tasks left running, e.g. ones with the all_done trigger rule
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE DAG_Runs
+			SET status = 'timed_out'
+			WHERE timeout_at <= datetime('now')
+			AND (status = 'running' OR (status = 'failed' AND EXISTS (
+				SELECT 1 FROM Task_Runs tr
+				WHERE tr.run_id = DAG_Runs.run_id
+				AND tr.status IN ('pending', 'pending_dag', 'running', 'waiting')
+			)))
+			RETURNING run_id, pvcName;`)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var run StoppedDagRun
+			if err := rows.Scan(&run.RunId, &run.PVCName); err != nil {
+				rows.Close()
+				return err
+			}
+			timedOut = append(timedOut, run)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return s.stopDagRunTasks(ctx, tx, timedOut, "timed_out")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return timedOut, nil
+}
+
+// stopDagRunTasks fills in the names of runs that were stopped and gives their active task runs the given status
+func (s *sqliteDAGManager) stopDagRunTasks(ctx context.Context, tx *sql.Tx, runs []StoppedDagRun, status string) error {
+	for i := range runs {
+		if err := tx.QueryRowContext(ctx, `
+			SELECT d.name, d.namespace
+			FROM DAG_Runs r
+			JOIN DAGs d ON d.dag_id = r.dag_id
+			WHERE r.run_id = ?;`, runs[i].RunId).Scan(&runs[i].DagName, &runs[i].Namespace); err != nil {
+			return err
+		}
+
+		taskRuns, err := s.stopActiveTaskRuns(ctx, tx, runs[i].RunId, status)
+		if err != nil {
+			return err
+		}
+
+		runs[i].TaskRuns = taskRuns
+	}
+
+	return nil
+}
+
+// stopActiveTaskRuns marks every task run of a run that has not finished with the given status
+func (s *sqliteDAGManager) stopActiveTaskRuns(ctx context.Context, tx *sql.Tx, runId int, status string) ([]StoppedTaskRun, error) {
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE Task_Runs
+		SET status = ?, claimed_by = NULL, claimed_at = NULL, lease_expires_at = NULL
+		WHERE run_id = ?
+		AND status IN ('pending', 'pending_dag', 'running', 'waiting')
+		RETURNING task_run_id, task_id;`, status, runId)
+	if err != nil {
+		return nil, err
+	}
+
+	taskRuns := []StoppedTaskRun{}
+	taskIds := []int{}
+	for rows.Next() {
+		run := StoppedTaskRun{RunId: runId}
+		var taskId int
+		if err := rows.Scan(&run.TaskRunId, &taskId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		taskRuns = append(taskRuns, run)
+		taskIds = append(taskIds, taskId)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range taskRuns {
+		if err := s.describeStoppedTaskRun(ctx, tx, &taskRuns[i], taskIds[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return taskRuns, nil
+}
+
+// describeStoppedTaskRun fills in the names and pods of a task run that was stopped
+func (s *sqliteDAGManager) describeStoppedTaskRun(ctx context.Context, tx *sql.Tx, run *StoppedTaskRun, taskId int) error {
+	if err := tx.QueryRowContext(ctx, `
+		SELECT dt.name, d.name, d.namespace, r.pvcName
+		FROM DAG_Tasks dt
+		JOIN DAG_Runs r ON r.run_id = ?
+		JOIN DAGs d ON d.dag_id = r.dag_id
+		WHERE dt.dag_task_id = ?;`, run.RunId, taskId).Scan(&run.TaskName, &run.DagName, &run.Namespace, &run.PVCName); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT name, namespace
+		FROM Task_Pods
+		WHERE task_run_id = ?;`, run.TaskRunId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	run.Pods = []RunningPodInfo{}
+	for rows.Next() {
+		var pod RunningPodInfo
+		if err := rows.Scan(&pod.Name, &pod.Namespace); err != nil {
+			return err
+		}
+		run.Pods = append(run.Pods, pod)
+	}
+
+	return rows.Err()
+}
+
+func (s *sqliteDAGManager) GetDagRunSummary(ctx context.Context, runId int) (*DagRunSummary, error) {
+	summary := &DagRunSummary{RunId: runId, TaskRuns: map[string]int{}}
+
+	var runTime time.Time
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT status, run_time
+		FROM DAG_Runs
+		WHERE run_id = ?;`, runId).Scan(&summary.Status, &runTime); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDagRunNotFound
+		}
+		return nil, err
+	}
+
+	if summary.Status != "queued" {
+		summary.StartTime = &runTime
+	}
+
+	// a retry adds a task run, only the latest one of each task and map item is counted
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tr.status, COUNT(*)
+		FROM Task_Runs tr
+		WHERE tr.run_id = ?
+		AND NOT EXISTS (
+			SELECT 1 FROM Task_Runs later
+			WHERE later.run_id = tr.run_id
+			AND later.task_id = tr.task_id
+			AND later.map_index IS tr.map_index
+			AND later.task_run_id > tr.task_run_id
+		)
+		GROUP BY tr.status;`, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		summary.TaskRuns[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !summary.finished() {
+		return summary, nil
+	}
+
+	finishTime := runTime
+	var podTime time.Time
+	err = s.db.QueryRowContext(ctx, `
+		SELECT tp.updated_at
+		FROM Task_Pods tp
+		JOIN Task_Runs tr ON tr.task_run_id = tp.task_run_id
+		WHERE tr.run_id = ?
+		ORDER BY tp.updated_at DESC
+		LIMIT 1;`, runId).Scan(&podTime)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err == nil && podTime.After(finishTime) {
+		finishTime = podTime
+	}
+
+	summary.FinishTime = &finishTime
+	return summary, nil
+}
+
+func (s *sqliteDAGManager) GetDagStatus(ctx context.Context, dagName, namespace string) (*DagStatus, error) {
+	status := &DagStatus{}
+	var schedule string
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT version, hash, schedule, nexttime
+		FROM DAGs
+		WHERE name = ? AND namespace = ?
+		ORDER BY version DESC
+		LIMIT 1;`, dagName, namespace).Scan(&status.Version, &status.Hash, &schedule, &status.NextTime); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDagNotFound
+		}
+		return nil, err
+	}
+
+	if schedule == "" {
+		status.NextTime = nil
+	}
+
+	lastRun := &DagRunRef{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT r.run_id, r.name
+		FROM DAG_Runs r
+		JOIN DAGs d ON d.dag_id = r.dag_id
+		WHERE d.name = ? AND d.namespace = ?
+		ORDER BY r.run_id DESC
+		LIMIT 1;`, dagName, namespace).Scan(&lastRun.RunId, &lastRun.Name)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err == nil {
+		status.LastRun = lastRun
+	}
+
+	return status, nil
+}
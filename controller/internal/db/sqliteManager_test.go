@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"kontroler-controller/api/v1alpha1"
 	"kontroler-controller/internal/db"
@@ -1348,3 +1349,348 @@ func TestSqliteDAGManager_GetTaskRunInfo_ContextCancelled(t *testing.T) {
 
 	testDAGManagerGetTaskRunInfo_ContextCancelled(t, dm)
 }
+
+func TestSqliteDAGManager_TaskOutputs(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TaskOutputs_PassedDownstream(t, dm)
+}
+
+func TestSqliteDAGManager_WhenConditions(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_WhenConditions_SkipTasks(t, dm)
+	testDAGManager_WhenConditions_StartingTasks(t, dm)
+}
+
+func TestSqliteDAGManager_TriggerRules(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TriggerRules_AfterFailure(t, dm)
+	testDAGManager_TriggerRules_AfterSuccess(t, dm)
+}
+
+func TestSqliteDAGManager_MapTasks(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_MapTasks_Parameter(t, dm)
+	testDAGManager_MapTasks_EmptyOutput(t, dm)
+	testDAGManager_MapTasks_Failure(t, dm)
+	testDAGManager_MapTasks_RetryEnv(t, dm)
+}
+
+func TestSqliteDAGManager_SubDags(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_SubDags_Success(t, dm)
+	testDAGManager_SubDags_Failure(t, dm)
+	testDAGManager_SubDags_Loop(t, dm)
+}
+
+func TestSqliteDAGManager_Timeouts(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_Timeouts(t, dm)
+}
+
+func TestSqliteDAGManager_RetryBackoff(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_RetryBackoff(t, dm)
+	testDAGManager_RetryLimit(t, dm)
+}
+
+func TestSqliteDAGManager_ConcurrencyPolicy(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_ConcurrencyPolicy(t, dm)
+}
+
+func TestSqliteDAGManager_Catchup(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_Catchup(t, dm)
+}
+
+func TestSqliteDAGManager_Backfill(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_Backfill(t, dm)
+}
+
+func TestSqliteDAGManager_ScheduleTimezone(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_ScheduleTimezone(t, dm)
+}
+
+func TestSqliteDAGManager_RefreshZonedNextTimes(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, dbConn, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	require.NoError(t, dm.InitaliseDatabase(ctx))
+
+	newDag := func(name, timezone string) *v1alpha1.DAG {
+		return &v1alpha1.DAG{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.DAGSpec{
+				Schedule: "0 9 * * *",
+				Timezone: timezone,
+				Task: []v1alpha1.TaskSpec{
+					{Name: "task1", Command: []string{"echo", "Hello"}, Image: "busybox"},
+				},
+			},
+		}
+	}
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_zoned", "Asia/Tokyo"), "default"))
+	require.NoError(t, dm.InsertDAG(ctx, newDag("test_dag_unzoned", ""), "default"))
+
+	// next run times worked out without the zone, as an earlier release stored them
+	stale := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Hour)
+	_, err = dbConn.Exec("UPDATE DAGs SET nexttime = ?", stale)
+	require.NoError(t, err)
+
+	require.NoError(t, dm.InitaliseDatabase(ctx))
+
+	// 09:00 in Tokyo is midnight UTC
+	zoned, err := dm.GetDagStatus(ctx, "test_dag_zoned", "default")
+	require.NoError(t, err)
+	require.NotNil(t, zoned.NextTime)
+	require.True(t, zoned.NextTime.After(time.Now()))
+	require.True(t, zoned.NextTime.Before(time.Now().Add(24*time.Hour)))
+	require.Equal(t, 0, zoned.NextTime.UTC().Hour())
+
+	unzoned, err := dm.GetDagStatus(ctx, "test_dag_unzoned", "default")
+	require.NoError(t, err)
+	require.NotNil(t, unzoned.NextTime)
+	require.True(t, stale.Equal(*unzoned.NextTime))
+}
+
+func TestSqliteDAGManager_DagRunSummary(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_DagRunSummary(t, dm)
+}
+
+func TestSqliteDAGManager_DagStatus(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_DagStatus(t, dm)
+}
+
+func TestSqliteDAGManager_ParameterKeyRefs(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_ParameterKeyRefs(t, dm)
+}
+
+func TestSqliteDAGManager_TaskCache(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TaskCache(t, dm)
+}
+
+func TestSqliteDAGManager_TaskArtifacts(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TaskArtifacts(t, dm)
+}
+
+func TestSqliteDAGManager_TaskPriority(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, dbConn, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TaskPriority(t, dm, func(taskRunId int, by time.Duration) {
+		_, err := dbConn.Exec(`UPDATE Task_Runs SET queued_at = datetime(queued_at, '-' || ? || ' seconds') WHERE task_run_id = ?`, int(by.Seconds()), taskRunId)
+		require.NoError(t, err)
+	})
+}
+
+func TestSqliteDAGManager_Pools(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_Pools(t, dm)
+}
+
+func TestSqliteDAGManager_TaskSensor(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TaskSensor(t, dm)
+}
+
+func TestSqliteDAGManager_TriggerEvents(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_TriggerEvents(t, dm)
+}
+
+func TestSqliteDAGManager_DagCompletionTriggers(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_DagCompletionTriggers(t, dm)
+}
+
+func TestSqliteDAGManager_DeferSensor(t *testing.T) {
+	dbPath := fmt.Sprintf("/tmp/%s.db", RandStringBytes(10))
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dm, _, err := db.NewSqliteManager(context.Background(), &parser, &db.SQLiteConfig{
+		DBPath: dbPath,
+	})
+	require.NoError(t, err)
+	err = dm.InitaliseDatabase(context.Background())
+	require.NoError(t, err)
+
+	testDAGManager_DeferSensor(t, dm)
+}
@@ -87,6 +87,7 @@ func (m *MetricsSqliteDAGManager) collectContentMetrics() {
 
 	// Collect initial metrics
 	m.updateContentMetrics(logger)
+	m.updatePoolMetrics(logger)
 
 	for {
 		select {
@@ -95,10 +96,29 @@ func (m *MetricsSqliteDAGManager) collectContentMetrics() {
 			return
 		case <-ticker.C:
 			m.updateContentMetrics(logger)
+			m.updatePoolMetrics(logger)
 		}
 	}
 }
 
+// updatePoolMetrics collects and updates the occupancy of every pool
+func (m *MetricsSqliteDAGManager) updatePoolMetrics(logger logr.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pools, err := m.sqliteDAGManager.GetPoolUsage(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to collect pool metrics")
+		metrics.RecordErrorMetrics("sqlite", "collect_content_metrics", "pool_usage_error")
+		return
+	}
+
+	metrics.ResetPoolMetrics()
+	for _, pool := range pools {
+		metrics.UpdatePoolMetrics(pool.Namespace, pool.Name, pool.Slots, pool.OccupiedSlots, pool.QueuedSlots)
+	}
+}
+
 // updateContentMetrics collects and updates database content metrics
 func (m *MetricsSqliteDAGManager) updateContentMetrics(logger logr.Logger) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -170,18 +190,19 @@ func (m *MetricsSqliteDAGManager) updateContentMetrics(logger logr.Logger) {
 		}
 
 		// Collect task run metrics for this namespace
-		var runningTasks, successTasks, failedTasks, pendingTasks int
+		var runningTasks, successTasks, cachedTasks, failedTasks, pendingTasks int
 		taskRunErr := m.db.QueryRowContext(ctx, `
 			SELECT 
 				COUNT(CASE WHEN tr.status = 'running' THEN 1 END) as running,
 				COUNT(CASE WHEN tr.status = 'success' THEN 1 END) as success,
+				COUNT(CASE WHEN tr.status = 'cached' THEN 1 END) as cached,
 				COUNT(CASE WHEN tr.status = 'failed' THEN 1 END) as failed,
 				COUNT(CASE WHEN tr.status = 'pending' THEN 1 END) as pending
 			FROM Task_Runs tr
 			JOIN DAG_Runs dr ON tr.run_id = dr.run_id
 			JOIN DAGs d ON dr.dag_id = d.dag_id
 			WHERE d.namespace = ?
-		`, namespace).Scan(&runningTasks, &successTasks, &failedTasks, &pendingTasks)
+		`, namespace).Scan(&runningTasks, &successTasks, &cachedTasks, &failedTasks, &pendingTasks)
 
 		if taskRunErr != nil {
 			logger.Error(taskRunErr, "Failed to collect task run metrics", "namespace", namespace)
@@ -189,11 +210,12 @@ func (m *MetricsSqliteDAGManager) updateContentMetrics(logger logr.Logger) {
 			continue
 		}
 
-		log.Log.Info("Task run metrics collected", "namespace", namespace, "running", runningTasks, "success", successTasks, "failed", failedTasks, "pending", pendingTasks)
+		log.Log.Info("Task run metrics collected", "namespace", namespace, "running", runningTasks, "success", successTasks, "cached", cachedTasks, "failed", failedTasks, "pending", pendingTasks)
 
 		taskRunCounts := map[string]int{
 			"running": runningTasks,
 			"success": successTasks,
+			"cached":  cachedTasks,
 			"failed":  failedTasks,
 			"pending": pendingTasks,
 		}
@@ -256,6 +278,13 @@ func (m *MetricsSqliteDAGManager) GetDAGsToStartAndUpdate(ctx context.Context, t
 	return result, err
 }
 
+func (m *MetricsSqliteDAGManager) GetBackfillsToStartAndUpdate(ctx context.Context) ([]*DagInfo, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.GetBackfillsToStartAndUpdate(ctx)
+	m.recordTransactionMetrics("get_backfills_to_start_and_update", start, err)
+	return result, err
+}
+
 func (m *MetricsSqliteDAGManager) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, namespace string) error {
 	start := time.Now()
 	err := m.sqliteDAGManager.InsertDAG(ctx, dag, namespace)
@@ -270,6 +299,20 @@ func (m *MetricsSqliteDAGManager) CreateDAGRun(ctx context.Context, name string,
 	return result, err
 }
 
+func (m *MetricsSqliteDAGManager) StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (DagRunAdmission, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.StartDAGRun(ctx, name, dag, parameters, pvcName)
+	m.recordTransactionMetrics("start_dag_run", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) StartQueuedDAGRuns(ctx context.Context) ([]QueuedDagRun, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.StartQueuedDAGRuns(ctx)
+	m.recordTransactionMetrics("start_queued_dag_runs", start, err)
+	return result, err
+}
+
 func (m *MetricsSqliteDAGManager) TaskRunExists(ctx context.Context, runId, dagTaskId int) (bool, error) {
 	start := time.Now()
 	result, err := m.sqliteDAGManager.TaskRunExists(ctx, runId, dagTaskId)
@@ -305,6 +348,20 @@ func (m *MetricsSqliteDAGManager) MarkSuccessAndGetNextTasks(ctx context.Context
 	return result, err
 }
 
+func (m *MetricsSqliteDAGManager) CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []Task, error) {
+	start := time.Now()
+	cached, result, err := m.sqliteDAGManager.CompleteTaskRunFromCache(ctx, taskRunId, workerId, cacheKey)
+	m.recordTransactionMetrics("complete_task_run_from_cache", start, err)
+	return cached, result, err
+}
+
+func (m *MetricsSqliteDAGManager) InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.InvalidateTaskCache(ctx, dagName, namespace, taskNames)
+	m.recordQueryMetrics("delete", "task_cache", start, err)
+	return result, err
+}
+
 func (m *MetricsSqliteDAGManager) MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error {
 	start := time.Now()
 	err := m.sqliteDAGManager.MarkDAGRunOutcome(ctx, dagRunId, outcome)
@@ -417,6 +474,13 @@ func (m *MetricsSqliteDAGManager) MarkConnectingTasksAsSuspended(ctx context.Con
 	return result, err
 }
 
+func (m *MetricsSqliteDAGManager) GetReadyTasks(ctx context.Context, dagRunId int) ([]Task, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.GetReadyTasks(ctx, dagRunId)
+	m.recordTransactionMetrics("get_ready_tasks", start, err)
+	return result, err
+}
+
 func (m *MetricsSqliteDAGManager) AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error {
 	start := time.Now()
 	err := m.sqliteDAGManager.AddPodDuration(ctx, taskRunId, durationSec)
@@ -459,6 +523,20 @@ func (m *MetricsSqliteDAGManager) SaveRetryEnv(ctx context.Context, taskRunId in
 	return err
 }
 
+func (m *MetricsSqliteDAGManager) SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error {
+	start := time.Now()
+	err := m.sqliteDAGManager.SaveTaskOutputs(ctx, taskRunId, outputs)
+	m.recordQueryMetrics("update", "task_runs", start, err)
+	return err
+}
+
+func (m *MetricsSqliteDAGManager) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	start := time.Now()
+	result, delay, err := m.sqliteDAGManager.RetryTaskRun(ctx, taskRunId)
+	m.recordQueryMetrics("insert", "task_runs", start, err)
+	return result, delay, err
+}
+
 func (m *MetricsSqliteDAGManager) ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (TaskClaim, error) {
 	start := time.Now()
 	result, err := m.sqliteDAGManager.ClaimTaskByID(ctx, taskRunId, workerId, leaseTTL)
@@ -473,6 +551,104 @@ func (m *MetricsSqliteDAGManager) GetTaskRunStatus(ctx context.Context, taskRunI
 	return result, err
 }
 
+func (m *MetricsSqliteDAGManager) StartSubDagRuns(ctx context.Context) ([]SubDagRun, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.StartSubDagRuns(ctx)
+	m.recordTransactionMetrics("start_sub_dag_runs", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error {
+	start := time.Now()
+	err := m.sqliteDAGManager.LinkSubDagRun(ctx, runId, parentTaskRunId)
+	m.recordQueryMetrics("update", "dag_runs", start, err)
+	return err
+}
+
+func (m *MetricsSqliteDAGManager) GetFinishedSubDagRuns(ctx context.Context) ([]FinishedSubDagRun, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.GetFinishedSubDagRuns(ctx)
+	m.recordQueryMetrics("select", "dag_runs", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) ClaimFinishedDagRuns(ctx context.Context) ([]DownstreamDagRun, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.ClaimFinishedDagRuns(ctx)
+	m.recordTransactionMetrics("claim_finished_dag_runs", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) MarkDagRunCompletionHandled(ctx context.Context, runId int) error {
+	start := time.Now()
+	err := m.sqliteDAGManager.MarkDagRunCompletionHandled(ctx, runId)
+	m.recordQueryMetrics("update", "dag_runs", start, err)
+	return err
+}
+
+func (m *MetricsSqliteDAGManager) TimeOutTaskRuns(ctx context.Context) ([]StoppedTaskRun, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.TimeOutTaskRuns(ctx)
+	m.recordTransactionMetrics("time_out_task_runs", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) TimeOutDagRuns(ctx context.Context) ([]StoppedDagRun, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.TimeOutDagRuns(ctx)
+	m.recordTransactionMetrics("time_out_dag_runs", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) GetDagRunSummary(ctx context.Context, runId int) (*DagRunSummary, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.GetDagRunSummary(ctx, runId)
+	m.recordQueryMetrics("select", "dag_runs", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) GetDagStatus(ctx context.Context, dagName, namespace string) (*DagStatus, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.GetDagStatus(ctx, dagName, namespace)
+	m.recordQueryMetrics("select", "dags", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error {
+	start := time.Now()
+	err := m.sqliteDAGManager.UpsertPool(ctx, pool, namespace)
+	m.recordQueryMetrics("insert", "pools", start, err)
+	return err
+}
+
+func (m *MetricsSqliteDAGManager) DeletePool(ctx context.Context, name, namespace string) error {
+	start := time.Now()
+	err := m.sqliteDAGManager.DeletePool(ctx, name, namespace)
+	m.recordQueryMetrics("delete", "pools", start, err)
+	return err
+}
+
+func (m *MetricsSqliteDAGManager) GetPoolUsage(ctx context.Context) ([]PoolUsage, error) {
+	start := time.Now()
+	result, err := m.sqliteDAGManager.GetPoolUsage(ctx)
+	m.recordQueryMetrics("select", "pools", start, err)
+	return result, err
+}
+
+func (m *MetricsSqliteDAGManager) RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error) {
+	start := time.Now()
+	result, created, err := m.sqliteDAGManager.RecordTriggerEvent(ctx, namespace, triggerName, eventId, dagRunName)
+	m.recordQueryMetrics("insert", "trigger_events", start, err)
+	return result, created, err
+}
+
+func (m *MetricsSqliteDAGManager) GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error) {
+	start := time.Now()
+	result, found, err := m.sqliteDAGManager.GetTriggerEvent(ctx, namespace, triggerName, eventId)
+	m.recordQueryMetrics("select", "trigger_events", start, err)
+	return result, found, err
+}
+
 func (m *MetricsSqliteDAGManager) ClaimTasks(ctx context.Context, limit int, workerId string, leaseTTL time.Duration) ([]TaskClaim, error) {
 	start := time.Now()
 	result, err := m.sqliteDAGManager.ClaimTasks(ctx, limit, workerId, leaseTTL)
@@ -487,6 +663,13 @@ func (m *MetricsSqliteDAGManager) RenewLease(ctx context.Context, taskRunId int,
 	return err
 }
 
+func (m *MetricsSqliteDAGManager) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	start := time.Now()
+	deferred, err := m.sqliteDAGManager.DeferSensor(ctx, taskRunId, workerId, interval, timeout)
+	m.recordQueryMetrics("update", "task_runs", start, err)
+	return deferred, err
+}
+
 func (m *MetricsSqliteDAGManager) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
 	start := time.Now()
 	err := m.sqliteDAGManager.FinalizeClaimToRunning(ctx, taskRunId, workerId, podUID)
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Pool occupancy metrics
+var (
+	PoolSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontroler_pool_slots",
+		Help: "Number of slots of a pool",
+	}, []string{"namespace", "pool"})
+
+	PoolOccupiedSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontroler_pool_occupied_slots",
+		Help: "Number of slots of a pool taken by claimed and running tasks",
+	}, []string{"namespace", "pool"})
+
+	PoolQueuedSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kontroler_pool_queued_slots",
+		Help: "Number of slots of a pool asked for by tasks waiting to be claimed",
+	}, []string{"namespace", "pool"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		PoolSlots,
+		PoolOccupiedSlots,
+		PoolQueuedSlots,
+	)
+}
+
+// ResetPoolMetrics drops the series of every pool, so deleted pools stop being reported
+func ResetPoolMetrics() {
+	PoolSlots.Reset()
+	PoolOccupiedSlots.Reset()
+	PoolQueuedSlots.Reset()
+}
+
+// UpdatePoolMetrics updates the occupancy metrics of a pool
+func UpdatePoolMetrics(namespace, pool string, slots, occupied, queued int) {
+	PoolSlots.WithLabelValues(namespace, pool).Set(float64(slots))
+	PoolOccupiedSlots.WithLabelValues(namespace, pool).Set(float64(occupied))
+	PoolQueuedSlots.WithLabelValues(namespace, pool).Set(float64(queued))
+}
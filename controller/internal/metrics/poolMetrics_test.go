@@ -0,0 +1,41 @@
+package metrics_test
+
+import (
+	"kontroler-controller/internal/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePoolMetrics(t *testing.T) {
+	metrics.ResetPoolMetrics()
+
+	metrics.UpdatePoolMetrics(testNamespace, "warehouse", 4, 3, 2)
+
+	assert.Equal(t, float64(4), testutil.ToFloat64(metrics.PoolSlots.WithLabelValues(testNamespace, "warehouse")), "Pool slots should be set")
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.PoolOccupiedSlots.WithLabelValues(testNamespace, "warehouse")), "Occupied slots should be set")
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.PoolQueuedSlots.WithLabelValues(testNamespace, "warehouse")), "Queued slots should be set")
+
+	// Later updates replace the previous values
+	metrics.UpdatePoolMetrics(testNamespace, "warehouse", 4, 1, 0)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.PoolOccupiedSlots.WithLabelValues(testNamespace, "warehouse")), "Occupied slots should be replaced")
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.PoolQueuedSlots.WithLabelValues(testNamespace, "warehouse")), "Queued slots should be replaced")
+}
+
+func TestResetPoolMetrics(t *testing.T) {
+	metrics.ResetPoolMetrics()
+
+	metrics.UpdatePoolMetrics(testNamespace, "warehouse", 4, 3, 2)
+	metrics.UpdatePoolMetrics(testNamespace, "api", 1, 1, 0)
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.PoolSlots), "Both pools should be reported")
+
+	// Deleted pools stop being reported once the metrics are reset
+	metrics.ResetPoolMetrics()
+	metrics.UpdatePoolMetrics(testNamespace, "api", 1, 0, 0)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.PoolSlots), "Only the remaining pool should be reported")
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.PoolOccupiedSlots), "Only the remaining pool should be reported")
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.PoolQueuedSlots), "Only the remaining pool should be reported")
+}
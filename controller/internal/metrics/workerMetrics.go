@@ -26,6 +26,12 @@ var (
 		Help: "Total number of task retries",
 	}, []string{"namespace", "dag_name", "task_name", "retry_reason"})
 
+	// DAG run timeout metrics
+	DagRunTimeoutTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kontroler_dag_run_timeout_total",
+		Help: "Total number of DAG runs stopped by their run timeout",
+	}, []string{"namespace", "dag_name"})
+
 	// Worker processing metrics
 	WorkerQueueSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "kontroler_worker_queue_size",
@@ -126,6 +132,14 @@ func init() {
 		}
 	}
 
+	if err := metrics.Registry.Register(DagRunTimeoutTotal); err != nil {
+		if ar, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			DagRunTimeoutTotal = ar.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			panic(err)
+		}
+	}
+
 	if err := metrics.Registry.Register(ClaimedInFlight); err != nil {
 		if ar, ok := err.(prometheus.AlreadyRegisteredError); ok {
 			ClaimedInFlight = ar.ExistingCollector.(*prometheus.GaugeVec)
@@ -150,6 +164,11 @@ func RecordTaskRetry(namespace, dagName, taskName, retryReason string) {
 	TaskRetryTotal.WithLabelValues(namespace, dagName, taskName, retryReason).Inc()
 }
 
+// RecordDagRunTimeout records metrics for a DAG run stopped by its run timeout
+func RecordDagRunTimeout(namespace, dagName string) {
+	DagRunTimeoutTotal.WithLabelValues(namespace, dagName).Inc()
+}
+
 // UpdateWorkerQueueSize updates the worker queue size metric
 func UpdateWorkerQueueSize(workerID string, size int) {
 	WorkerQueueSize.WithLabelValues(workerID).Set(float64(size))
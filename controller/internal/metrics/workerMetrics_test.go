@@ -84,6 +84,17 @@ func TestRecordTaskRetry(t *testing.T) {
 	assert.Equal(t, float64(1), reason2Counter, "Exit code 2 retry counter should be 1")
 }
 
+func TestRecordDagRunTimeout(t *testing.T) {
+	// Reset metrics before test
+	metrics.DagRunTimeoutTotal.Reset()
+
+	metrics.RecordDagRunTimeout(testNamespace, testDagName)
+	metrics.RecordDagRunTimeout(testNamespace, testDagName)
+
+	counter := testutil.ToFloat64(metrics.DagRunTimeoutTotal.WithLabelValues(testNamespace, testDagName))
+	assert.Equal(t, float64(2), counter, "DAG run timeout counter should be 2")
+}
+
 func TestUpdateWorkerQueueSize(t *testing.T) {
 	// Reset metrics before test
 	metrics.WorkerQueueSize.Reset()
@@ -0,0 +1,205 @@
+package sensors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/config"
+)
+
+// maxBodySize is how much of a response body is matched against
+const maxBodySize = 1 << 20
+
+type httpSensor struct {
+	url         string
+	statusCodes []int
+	bodyMatch   *regexp.Regexp
+	client      *http.Client
+}
+
+func newHTTPSensor(spec *v1alpha1.HTTPSensor, client *http.Client) (*httpSensor, error) {
+	sensor := &httpSensor{
+		url:         spec.URL,
+		statusCodes: spec.StatusCodes,
+		client:      client,
+	}
+
+	if len(sensor.statusCodes) == 0 {
+		sensor.statusCodes = []int{http.StatusOK}
+	}
+
+	if spec.BodyMatch != "" {
+		bodyMatch, err := regexp.Compile(spec.BodyMatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bodyMatch: %w", err)
+		}
+		sensor.bodyMatch = bodyMatch
+	}
+
+	return sensor, nil
+}
+
+func (s *httpSensor) Poke(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(s.statusCodes, resp.StatusCode) {
+		return false, nil
+	}
+
+	if s.bodyMatch == nil {
+		return true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return false, err
+	}
+
+	return s.bodyMatch.Match(body), nil
+}
+
+// defaultHTTPDeny keeps sensors from cloud metadata services, which listen on link-local addresses
+var defaultHTTPDeny = []string{"169.254.0.0/16", "fe80::/10"}
+
+// ErrHostNotAllowed is returned when an http sensor calls a host its policy refuses
+var ErrHostNotAllowed = errors.New("host is not allowed for http sensors")
+
+// HTTPPolicy decides which hosts http sensors may call. Hosts are checked as they are dialled, against
+// the addresses they resolve to at that moment, so redirects and DNS changes cannot get around it
+type HTTPPolicy struct {
+	allow hostList
+	deny  hostList
+}
+
+// hostList holds host names, lower cased with "*." kept for subdomain entries, and networks
+type hostList struct {
+	hosts []string
+	nets  []*net.IPNet
+}
+
+// NewHTTPPolicy creates the policy of the configured entries, the deny list defaults to link-local addresses
+func NewHTTPPolicy(cfg config.HTTPSensors) (*HTTPPolicy, error) {
+	deny := cfg.Deny
+	if deny == nil {
+		deny = defaultHTTPDeny
+	}
+
+	allowList, err := parseHostList(cfg.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http sensor allow list: %w", err)
+	}
+
+	denyList, err := parseHostList(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http sensor deny list: %w", err)
+	}
+
+	return &HTTPPolicy{allow: allowList, deny: denyList}, nil
+}
+
+func parseHostList(entries []string) (hostList, error) {
+	var list hostList
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return hostList{}, err
+			}
+			list.nets = append(list.nets, network)
+			continue
+		}
+
+		list.hosts = append(list.hosts, strings.ToLower(entry))
+	}
+
+	return list, nil
+}
+
+func (l hostList) empty() bool {
+	return len(l.hosts) == 0 && len(l.nets) == 0
+}
+
+func (l hostList) matches(host string, ip net.IP) bool {
+	host = strings.ToLower(host)
+	for _, entry := range l.hosts {
+		if entry == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(entry, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+
+	for _, network := range l.nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether a sensor may call host at ip
+func (p *HTTPPolicy) Allows(host string, ip net.IP) bool {
+	if p.deny.matches(host, ip) {
+		return false
+	}
+
+	return p.allow.empty() || p.allow.matches(host, ip)
+}
+
+// Client returns an http client that only dials hosts the policy allows. It ignores proxies,
+// which would make the dialled host the proxy rather than the target
+func (p *HTTPPolicy) Client() *http.Client {
+	dialer := &net.Dialer{Timeout: pokeTimeout}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		// every address must be allowed, a host resolving to a denied one is refused as a whole
+		for _, a := range addrs {
+			if !p.Allows(host, a.IP) {
+				return nil, fmt.Errorf("%w: %s (%s)", ErrHostNotAllowed, host, a.IP)
+			}
+		}
+
+		var dialErr error
+		for _, a := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(a.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			dialErr = err
+		}
+		return nil, dialErr
+	}
+
+	return &http.Client{Timeout: pokeTimeout, Transport: transport}
+}
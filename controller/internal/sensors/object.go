@@ -0,0 +1,16 @@
+package sensors
+
+import (
+	"context"
+
+	"kontroler-controller/internal/artifacts"
+)
+
+type objectSensor struct {
+	key   string
+	store artifacts.ArtifactStore
+}
+
+func (s *objectSensor) Poke(ctx context.Context) (bool, error) {
+	return s.store.ArtifactExists(ctx, s.key)
+}
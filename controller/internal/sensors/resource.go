@@ -0,0 +1,98 @@
+package sensors
+
+import (
+	"context"
+	"fmt"
+
+	"kontroler-controller/api/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+type resourceSensor struct {
+	apiVersion string
+	kind       string
+	name       string
+	namespace  string
+	condition  string
+	client     dynamic.Interface
+	mapper     meta.RESTMapper
+}
+
+// newResourceSensor creates a sensor for a resource in namespace, the namespace of the DAG. The worker
+// reads resources with its own permissions, so DAGs only watch resources in their own namespace
+func newResourceSensor(spec *v1alpha1.ResourceSensor, namespace string, client dynamic.Interface, mapper meta.RESTMapper) (*resourceSensor, error) {
+	if spec.Namespace != "" && spec.Namespace != namespace {
+		return nil, fmt.Errorf("resource sensors can only watch the namespace of their DAG %s, not %s", namespace, spec.Namespace)
+	}
+
+	return &resourceSensor{
+		apiVersion: spec.APIVersion,
+		kind:       spec.Kind,
+		name:       spec.Name,
+		namespace:  namespace,
+		condition:  spec.Condition,
+		client:     client,
+		mapper:     mapper,
+	}, nil
+}
+
+func (s *resourceSensor) Poke(ctx context.Context) (bool, error) {
+	gv, err := schema.ParseGroupVersion(s.apiVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid apiVersion %q: %w", s.apiVersion, err)
+	}
+
+	mapping, err := s.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: s.kind}, gv.Version)
+	if err != nil {
+		// the kind may be installed while the sensor waits, so look it up again on the next check
+		if resettable, ok := s.mapper.(meta.ResettableRESTMapper); ok && meta.IsNoMatchError(err) {
+			resettable.Reset()
+		}
+		return false, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return false, fmt.Errorf("resource sensors can only watch namespaced kinds, %s is cluster scoped", s.kind)
+	}
+
+	obj, err := s.client.Resource(mapping.Resource).Namespace(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if s.condition == "" {
+		return true, nil
+	}
+
+	return hasCondition(obj, s.condition), nil
+}
+
+// hasCondition reports whether the resource has a status condition of the type that is True
+func hasCondition(obj *unstructured.Unstructured, conditionType string) bool {
+	conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return false
+	}
+
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if condition["type"] == conditionType && condition["status"] == string(metav1.ConditionTrue) {
+			return true
+		}
+	}
+
+	return false
+}
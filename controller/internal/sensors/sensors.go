@@ -0,0 +1,95 @@
+package sensors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/artifacts"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// pokeTimeout bounds a single check, so a hanging endpoint cannot hold a sensor past its timeout
+const pokeTimeout = 30 * time.Second
+
+// Sensor checks the condition a sensor task waits for
+type Sensor interface {
+	// Poke reports whether the condition holds. An error only fails the check it happened in,
+	// the condition is checked again on the next interval
+	Poke(ctx context.Context) (bool, error)
+}
+
+// Clients are used by sensors to reach what they watch, a sensor fails when its client is not set
+type Clients struct {
+	HTTP *http.Client
+	// Store the controller keeps logs and artifacts in
+	Store   artifacts.ArtifactStore
+	Dynamic dynamic.Interface
+	// Maps the kind of a resource to the resource the dynamic client reads
+	Mapper meta.RESTMapper
+}
+
+// NewClients creates the clients of every kind of sensor, store may be nil when there is no object store.
+// HTTP sensors only call the hosts httpPolicy allows
+func NewClients(config *rest.Config, store artifacts.ArtifactStore, httpPolicy *HTTPPolicy) (*Clients, error) {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	return &Clients{
+		HTTP:    httpPolicy.Client(),
+		Store:   store,
+		Dynamic: dynamicClient,
+		Mapper:  restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}, nil
+}
+
+// New creates the sensor described by spec, namespace is the namespace of the DAG, the only one resources are looked for in
+func New(spec *v1alpha1.SensorSpec, namespace string, clients *Clients) (Sensor, error) {
+	if clients == nil {
+		return nil, errors.New("sensors are not configured on this worker")
+	}
+
+	switch {
+	case spec.HTTP != nil:
+		if clients.HTTP == nil {
+			return nil, errors.New("http sensors are not configured on this worker")
+		}
+		return newHTTPSensor(spec.HTTP, clients.HTTP)
+	case spec.Object != nil:
+		if clients.Store == nil {
+			return nil, errors.New("object sensors need an object store")
+		}
+		return &objectSensor{key: spec.Object.Key, store: clients.Store}, nil
+	case spec.Resource != nil:
+		if clients.Dynamic == nil || clients.Mapper == nil {
+			return nil, errors.New("resource sensors are not configured on this worker")
+		}
+		return newResourceSensor(spec.Resource, namespace, clients.Dynamic, clients.Mapper)
+	default:
+		return nil, errors.New("sensor has no condition")
+	}
+}
+
+// Check pokes the sensor once, a check that hangs is given up on after pokeTimeout
+func Check(ctx context.Context, sensor Sensor) (bool, error) {
+	pokeCtx, cancel := context.WithTimeout(ctx, pokeTimeout)
+	defer cancel()
+
+	return sensor.Poke(pokeCtx)
+}
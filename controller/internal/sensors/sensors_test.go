@@ -0,0 +1,228 @@
+package sensors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/artifacts"
+	"kontroler-controller/internal/config"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+type fakeSensor struct {
+	pokes   int
+	holdsAt int
+	err     error
+}
+
+func (s *fakeSensor) Poke(ctx context.Context) (bool, error) {
+	s.pokes++
+	if s.holdsAt > 0 && s.pokes >= s.holdsAt {
+		return true, nil
+	}
+	return false, s.err
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+
+	sensor := &fakeSensor{holdsAt: 1}
+	ok, err := Check(ctx, sensor)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// a failing check only reports the error, the sensor is checked again later
+	sensor = &fakeSensor{err: errors.New("connection refused")}
+	ok, err = Check(ctx, sensor)
+	require.ErrorContains(t, err, "connection refused")
+	require.False(t, ok)
+	require.Equal(t, 1, sensor.pokes)
+}
+
+func TestHTTPSensor(t *testing.T) {
+	ready := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if ready {
+			_, _ = w.Write([]byte(`{"state":"ready"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state":"loading"}`))
+	}))
+	defer server.Close()
+
+	clients := &Clients{HTTP: server.Client()}
+	ctx := context.Background()
+
+	sensor, err := New(&v1alpha1.SensorSpec{HTTP: &v1alpha1.HTTPSensor{URL: server.URL}}, "default", clients)
+	require.NoError(t, err)
+	ok, err := sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	sensor, err = New(&v1alpha1.SensorSpec{HTTP: &v1alpha1.HTTPSensor{URL: server.URL + "/missing"}}, "default", clients)
+	require.NoError(t, err)
+	ok, err = sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	sensor, err = New(&v1alpha1.SensorSpec{HTTP: &v1alpha1.HTTPSensor{URL: server.URL + "/missing", StatusCodes: []int{404}}}, "default", clients)
+	require.NoError(t, err)
+	ok, err = sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	sensor, err = New(&v1alpha1.SensorSpec{HTTP: &v1alpha1.HTTPSensor{URL: server.URL, BodyMatch: `"state":\s*"ready"`}}, "default", clients)
+	require.NoError(t, err)
+	ok, err = sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ready = true
+	ok, err = sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestHTTPPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	poke := func(cfg config.HTTPSensors) error {
+		policy, err := NewHTTPPolicy(cfg)
+		require.NoError(t, err)
+
+		sensor, err := New(&v1alpha1.SensorSpec{HTTP: &v1alpha1.HTTPSensor{URL: server.URL}}, "default", &Clients{HTTP: policy.Client()})
+		require.NoError(t, err)
+
+		_, err = sensor.Poke(context.Background())
+		return err
+	}
+
+	require.NoError(t, poke(config.HTTPSensors{}))
+	require.NoError(t, poke(config.HTTPSensors{Allow: []string{"127.0.0.0/8"}}))
+	require.ErrorIs(t, poke(config.HTTPSensors{Allow: []string{"10.0.0.0/8"}}), ErrHostNotAllowed)
+	require.ErrorIs(t, poke(config.HTTPSensors{Deny: []string{"127.0.0.1/32"}}), ErrHostNotAllowed)
+
+	policy, err := NewHTTPPolicy(config.HTTPSensors{Allow: []string{"*.example.com", "api.internal"}})
+	require.NoError(t, err)
+	ip := net.ParseIP("10.1.2.3")
+	require.True(t, policy.Allows("data.example.com", ip))
+	require.True(t, policy.Allows("API.internal", ip))
+	require.False(t, policy.Allows("example.com.evil.org", ip))
+	// cloud metadata services stay denied unless the deny list is replaced
+	require.False(t, policy.Allows("data.example.com", net.ParseIP("169.254.169.254")))
+
+	_, err = NewHTTPPolicy(config.HTTPSensors{Deny: []string{"10.0.0.0/33"}})
+	require.Error(t, err)
+}
+
+func TestObjectSensor(t *testing.T) {
+	ctx := context.Background()
+	store, err := artifacts.NewFileSystemArtifactStore(t.TempDir())
+	require.NoError(t, err)
+
+	sensor, err := New(&v1alpha1.SensorSpec{Object: &v1alpha1.ObjectSensor{Key: "incoming/2024-01-01.csv"}}, "default", &Clients{Store: store})
+	require.NoError(t, err)
+
+	ok, err := sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, store.PutArtifact(ctx, "incoming/2024-01-01.csv", strings.NewReader("a,b")))
+
+	ok, err = sensor.Poke(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestResourceSensor(t *testing.T) {
+	ctx := context.Background()
+	gvk := schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
+	gvr := schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gvk.GroupVersion()})
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "JobList"})
+	clients := &Clients{Dynamic: client, Mapper: mapper}
+
+	exists, err := New(&v1alpha1.SensorSpec{Resource: &v1alpha1.ResourceSensor{APIVersion: "batch/v1", Kind: "Job", Name: "load"}}, "team-a", clients)
+	require.NoError(t, err)
+	complete, err := New(&v1alpha1.SensorSpec{Resource: &v1alpha1.ResourceSensor{APIVersion: "batch/v1", Kind: "Job", Name: "load", Condition: "Complete"}}, "team-a", clients)
+	require.NoError(t, err)
+
+	ok, err := exists.Poke(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	job := &unstructured.Unstructured{}
+	job.SetGroupVersionKind(gvk)
+	job.SetName("load")
+	job.SetNamespace("team-a")
+	job, err = client.Resource(gvr).Namespace("team-a").Create(ctx, job, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	ok, err = exists.Poke(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = complete.Poke(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, unstructured.SetNestedSlice(job.Object, []interface{}{
+		map[string]interface{}{"type": "Complete", "status": "True"},
+	}, "status", "conditions"))
+	_, err = client.Resource(gvr).Namespace("team-a").Update(ctx, job, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	ok, err = complete.Poke(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	unknown, err := New(&v1alpha1.SensorSpec{Resource: &v1alpha1.ResourceSensor{APIVersion: "example.com/v1", Kind: "Widget", Name: "w"}}, "team-a", clients)
+	require.NoError(t, err)
+	_, err = unknown.Poke(ctx)
+	require.Error(t, err)
+
+	// resources of other namespaces and cluster scoped kinds are out of reach of a DAG
+	_, err = New(&v1alpha1.SensorSpec{Resource: &v1alpha1.ResourceSensor{APIVersion: "batch/v1", Kind: "Job", Name: "load", Namespace: "team-b"}}, "team-a", clients)
+	require.Error(t, err)
+
+	nodeGVK := schema.GroupVersionKind{Version: "v1", Kind: "Node"}
+	mapper.Add(nodeGVK, meta.RESTScopeRoot)
+	node, err := New(&v1alpha1.SensorSpec{Resource: &v1alpha1.ResourceSensor{APIVersion: "v1", Kind: "Node", Name: "worker-1"}}, "team-a", clients)
+	require.NoError(t, err)
+	_, err = node.Poke(ctx)
+	require.Error(t, err)
+}
+
+func TestNewWithoutClients(t *testing.T) {
+	spec := &v1alpha1.SensorSpec{Object: &v1alpha1.ObjectSensor{Key: "incoming/data.csv"}}
+
+	_, err := New(spec, "default", nil)
+	require.Error(t, err)
+
+	_, err = New(spec, "default", &Clients{HTTP: http.DefaultClient})
+	require.Error(t, err)
+
+	_, err = New(&v1alpha1.SensorSpec{}, "default", &Clients{HTTP: http.DefaultClient})
+	require.Error(t, err)
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	v1 "kontroler-controller/api/v1alpha1"
 	"time"
 )
@@ -17,13 +19,41 @@ type DBDAGMetaData struct {
 	Version     int           `json:"version"`
 	Active      bool          `json:"active"`
 	NextTime    *time.Time    `json:"nexttime"`
+	Timezone    string        `json:"timezone,omitempty"`
 	IsSuspended bool          `json:"isSuspended"`
 	Connections map[int][]int `json:"connections"`
 }
 
+// inTimezone moves the next run time of a DAG into the time zone its schedule is evaluated in,
+// leaving it as stored when the DAG has no time zone or it is unknown
+func (m *DBDAGMetaData) inTimezone() {
+	if m.NextTime == nil || m.Timezone == "" {
+		return
+	}
+
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return
+	}
+
+	next := m.NextTime.In(loc)
+	m.NextTime = &next
+}
+
 type DBTaskInfo struct {
 	Status string `json:"status"`
 	Name   string `json:"name"`
+	// Instances of a map task, one per item
+	Instances []DBTaskInstance `json:"instances,omitempty"`
+	// Run started by a dagRef task
+	ChildRunId *int `json:"childRunId,omitempty"`
+}
+
+// DBTaskInstance is the latest attempt of a single item of a map task
+type DBTaskInstance struct {
+	Index  int    `json:"index"`
+	Item   string `json:"item"`
+	Status string `json:"status"`
 }
 
 type DBDagRunMeta struct {
@@ -34,6 +64,8 @@ type DBDagRunMeta struct {
 	FailedCount     int    `json:"failedCount"`
 	Namespace       string `json:"namespace"`
 	Name            string `json:"name"`
+	// Run of the dagRef task that started this run
+	ParentRunId *int `json:"parentRunId,omitempty"`
 }
 
 type DBDagRun struct {
@@ -49,13 +81,15 @@ type DBDagRunAll struct {
 	FailedCount     int                `json:"failedCount"`
 	Connections     map[int][]int      `json:"connections"`
 	TaskInfo        map[int]DBTaskInfo `json:"taskInfo"`
+	ParentRunId     *int               `json:"parentRunId,omitempty"`
 }
 
 type DBTaskRunDetails struct {
-	Id       int          `json:"id"`
-	Status   string       `json:"status"`
-	Attempts int          `json:"attempts"`
-	Pods     []*DBTaskPod `json:"pods"`
+	Id       int               `json:"id"`
+	Status   string            `json:"status"`
+	Attempts int               `json:"attempts"`
+	Pods     []*DBTaskPod      `json:"pods"`
+	Outputs  map[string]string `json:"outputs"`
 }
 
 type DBTaskPod struct {
@@ -71,7 +105,39 @@ type DBParameter struct {
 	ID           int    `json:"id"`
 	Name         string `json:"name"`
 	IsSecret     bool   `json:"isSecret"`
+	IsConfigMap  bool   `json:"isConfigMap"`
+	Key          string `json:"key,omitempty"` // key of the secret or ConfigMap named by DefaultValue
 	DefaultValue string `json:"defaultValue"`
+	// The values the parameter accepts so clients can build forms and check runs
+	v1.ParameterSchema
+}
+
+// ToSpec converts the parameter back into the DAG parameter it was stored from
+func (p *DBParameter) ToSpec() v1.DagParameterSpec {
+	spec := v1.DagParameterSpec{Name: p.Name, ParameterSchema: p.ParameterSchema}
+	switch {
+	case p.IsConfigMap:
+		spec.DefaultConfigMapKeyRef = &v1.KeyRef{Name: p.DefaultValue, Key: p.Key}
+	case p.IsSecret:
+		spec.DefaultSecretKeyRef = &v1.KeyRef{Name: p.DefaultValue, Key: p.Key}
+	default:
+		spec.DefaultValue = p.DefaultValue
+	}
+	return spec
+}
+
+// parseParameterSchema reads the schema stored alongside a parameter, parameters stored
+// before schemas existed have none and accept any string
+func parseParameterSchema(raw *string) (v1.ParameterSchema, error) {
+	var schema v1.ParameterSchema
+	if raw == nil || *raw == "" {
+		return schema, nil
+	}
+
+	if err := json.Unmarshal([]byte(*raw), &schema); err != nil {
+		return schema, fmt.Errorf("failed to parse parameter schema: %w", err)
+	}
+	return schema, nil
 }
 
 // DBTaskDetails represents the details of a task returned by the DB layer.
@@ -114,12 +180,70 @@ type DBDashboardStats struct {
 	DailyDagRunCounts []DBDailyDagRunCount `json:"daily_dag_run_counts"`
 }
 
+// DBPool is how many slots of a pool are taken by running tasks and asked for by queued ones
+type DBPool struct {
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace"`
+	Slots         int    `json:"slots"`
+	OccupiedSlots int    `json:"occupiedSlots"`
+	QueuedSlots   int    `json:"queuedSlots"`
+}
+
 type DBDailyDagRunCount struct {
 	Day             time.Time `json:"day"`
 	SuccessfulCount int       `json:"successful_count"`
 	FailedCount     int       `json:"failed_count"`
 }
 
+// addTaskRun records a task run against its task. Runs are expected in the order they
+// were created, so later attempts replace earlier ones and the instances of a map task
+// are grouped under the task it expands.
+func addTaskRun(taskInfo map[int]DBTaskInfo, taskId int, name, status string, mapIndex *int, mapItem string, childRunId *int) {
+	task := taskInfo[taskId]
+	task.Name = name
+
+	if mapIndex == nil {
+		task.Status = status
+		task.ChildRunId = childRunId
+		taskInfo[taskId] = task
+		return
+	}
+
+	instance := DBTaskInstance{Index: *mapIndex, Item: mapItem, Status: status}
+	replaced := false
+	for i := range task.Instances {
+		if task.Instances[i].Index == instance.Index {
+			task.Instances[i] = instance
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		task.Instances = append(task.Instances, instance)
+	}
+
+	task.Status = mapTaskStatus(task.Instances)
+	taskInfo[taskId] = task
+}
+
+// mapTaskStatus is running until every instance has finished, then failed if any of them failed
+func mapTaskStatus(instances []DBTaskInstance) string {
+	status := "success"
+	for _, instance := range instances {
+		switch instance.Status {
+		case "success", "cached", "skipped":
+		case "failed", "suspended", "timed_out":
+			if status == "success" {
+				status = "failed"
+			}
+		default:
+			status = "running"
+		}
+	}
+
+	return status
+}
+
 type DbManager interface {
 	GetAllDagMetaData(ctx context.Context, limit int, offset int) ([]*DBDAGMetaData, error)
 	GetDagRuns(ctx context.Context, limit int, offset int) ([]*DBDagRunMeta, error)
@@ -137,6 +261,7 @@ type DbManager interface {
 	GetDagTaskPageCount(ctx context.Context, limit int) (int, error)
 	PodExists(ctx context.Context, podUID string) (bool, error)
 	GetPodNameAndNamespace(ctx context.Context, podUID string) (string, string, error)
+	GetPools(ctx context.Context) ([]*DBPool, error)
 
 	Close()
 }
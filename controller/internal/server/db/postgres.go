@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,7 +21,7 @@ func NewPostgresManager(ctx context.Context, pool *pgxpool.Pool) (DbManager, err
 }
 
 const ALL_DAG_METADATA_QUERY = `
-SELECT dag_id, name, namespace, version, schedule, active, nexttime, suspended
+SELECT dag_id, name, namespace, version, schedule, active, nexttime, COALESCE(timezone, ''), suspended
 FROM DAGs
 WHERE active = TRUE
 ORDER BY dag_id DESC
@@ -39,9 +40,10 @@ func (p *postgresManager) GetAllDagMetaData(ctx context.Context, limit int, offs
 	for rows.Next() {
 		var meta DBDAGMetaData
 		if err := rows.Scan(&meta.DagId, &meta.Name, &meta.Namespace, &meta.Version,
-			&meta.Schedule, &meta.Active, &meta.NextTime, &meta.IsSuspended); err != nil {
+			&meta.Schedule, &meta.Active, &meta.NextTime, &meta.Timezone, &meta.IsSuspended); err != nil {
 			return nil, err
 		}
+		meta.inTimezone()
 
 		// Get the connections
 		meta.Connections, err = p.getDagConnections(ctx, meta.DagId)
@@ -82,11 +84,16 @@ func (p *postgresManager) GetDagRun(ctx context.Context, dagRunId int) (*DBDagRu
 	SELECT
 		d.dag_task_id,
 		d.name,
-		COALESCE(r.status, 'pending') AS status
+		COALESCE(r.status, 'pending') AS status,
+		r.map_index,
+		COALESCE(r.map_item, ''),
+		c.run_id
 	FROM DAG_Tasks d
 	JOIN tasks t ON d.task_id = t.task_id
 	LEFT JOIN Task_Runs r ON r.task_id = d.dag_task_id AND r.run_id = $1
-	WHERE d.dag_id = $2;`, dagRunId, dagId)
+	LEFT JOIN DAG_Runs c ON c.parent_task_run_id = r.task_run_id
+	WHERE d.dag_id = $2
+	ORDER BY r.task_run_id;`, dagRunId, dagId)
 
 	if err != nil {
 		return nil, err
@@ -97,12 +104,13 @@ func (p *postgresManager) GetDagRun(ctx context.Context, dagRunId int) (*DBDagRu
 	taskInfo := map[int]DBTaskInfo{}
 	for rows.Next() {
 		var taskId int
-		task := DBTaskInfo{}
-		if err := rows.Scan(&taskId, &task.Name, &task.Status); err != nil {
+		var name, status, mapItem string
+		var mapIndex, childRunId *int
+		if err := rows.Scan(&taskId, &name, &status, &mapIndex, &mapItem, &childRunId); err != nil {
 			return nil, err
 		}
 
-		taskInfo[taskId] = task
+		addTaskRun(taskInfo, taskId, name, status, mapIndex, mapItem, childRunId)
 	}
 
 	return &DBDagRun{
@@ -113,7 +121,7 @@ func (p *postgresManager) GetDagRun(ctx context.Context, dagRunId int) (*DBDagRu
 
 func (p *postgresManager) GetDagRuns(ctx context.Context, limit int, offset int) ([]*DBDagRunMeta, error) {
 	rows, err := p.pool.Query(ctx, `
-		SELECT run_id, d.dag_id, status, successfulcount, failedcount, d.namespace, r.name
+		SELECT run_id, d.dag_id, status, successfulcount, failedcount, d.namespace, r.name, r.parent_run_id
 		FROM DAG_Runs r
 		JOIN DAGs d ON r.dag_id = d.dag_id
 		ORDER BY run_id DESC
@@ -128,7 +136,7 @@ func (p *postgresManager) GetDagRuns(ctx context.Context, limit int, offset int)
 	metas := []*DBDagRunMeta{}
 	for rows.Next() {
 		var meta DBDagRunMeta
-		if err := rows.Scan(&meta.Id, &meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount, &meta.Namespace, &meta.Name); err != nil {
+		if err := rows.Scan(&meta.Id, &meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount, &meta.Namespace, &meta.Name, &meta.ParentRunId); err != nil {
 			return nil, err
 		}
 
@@ -186,10 +194,10 @@ func (p *postgresManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDa
 	}
 
 	if err := p.pool.QueryRow(ctx, `
-	SELECT dag_id, status, successfulCount, failedCount
+	SELECT dag_id, status, successfulCount, failedCount, parent_run_id
 	FROM DAG_Runs
 	WHERE run_id = $1;
-	`, dagRunId).Scan(&meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount); err != nil {
+	`, dagRunId).Scan(&meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount, &meta.ParentRunId); err != nil {
 		return nil, err
 	}
 
@@ -204,11 +212,16 @@ func (p *postgresManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDa
 	SELECT
 		d.dag_task_id,
 		d.name,
-		COALESCE(r.status, 'pending') AS status
+		COALESCE(r.status, 'pending') AS status,
+		r.map_index,
+		COALESCE(r.map_item, ''),
+		c.run_id
 	FROM DAG_Tasks d
 	JOIN tasks t ON d.task_id = t.task_id
 	LEFT JOIN Task_Runs r ON r.task_id = d.dag_task_id AND r.run_id = $1
-	WHERE d.dag_id = $2;`, dagRunId, meta.DagId)
+	LEFT JOIN DAG_Runs c ON c.parent_task_run_id = r.task_run_id
+	WHERE d.dag_id = $2
+	ORDER BY r.task_run_id;`, dagRunId, meta.DagId)
 
 	if err != nil {
 		return nil, err
@@ -219,12 +232,13 @@ func (p *postgresManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDa
 	taskInfo := map[int]DBTaskInfo{}
 	for rows.Next() {
 		var taskId int
-		task := DBTaskInfo{}
-		if err := rows.Scan(&taskId, &task.Name, &task.Status); err != nil {
+		var name, status, mapItem string
+		var mapIndex, childRunId *int
+		if err := rows.Scan(&taskId, &name, &status, &mapIndex, &mapItem, &childRunId); err != nil {
 			return nil, err
 		}
 
-		taskInfo[taskId] = task
+		addTaskRun(taskInfo, taskId, name, status, mapIndex, mapItem, childRunId)
 	}
 
 	meta.Connections = connections
@@ -236,14 +250,22 @@ func (p *postgresManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDa
 func (p *postgresManager) GetTaskRunDetails(ctx context.Context, dagRunId, taskId int) (*DBTaskRunDetails, error) {
 	task := &DBTaskRunDetails{}
 
+	var outputs []byte
 	if err := p.pool.QueryRow(ctx, `
-	SELECT task_run_id, status, attempts
+	SELECT task_run_id, status, attempts, outputs
 	FROM Task_Runs
 	WHERE run_id = $1 AND task_id = $2;
-	`, dagRunId, taskId).Scan(&task.Id, &task.Status, &task.Attempts); err != nil {
+	`, dagRunId, taskId).Scan(&task.Id, &task.Status, &task.Attempts, &outputs); err != nil {
 		return nil, err
 	}
 
+	task.Outputs = map[string]string{}
+	if outputs != nil {
+		if err := json.Unmarshal(outputs, &task.Outputs); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get the current status of each task
 	rows, err := p.pool.Query(ctx, `
 	SELECT Pod_UID, exitCode, name, status, duration
@@ -369,7 +391,7 @@ func (p *postgresManager) GetDashboardStats(ctx context.Context) (*DBDashboardSt
 		dagRunsQuery := `
 			SELECT 
 				COUNT(CASE WHEN status = 'success' AND run_time >= NOW() - INTERVAL '30 days' THEN 1 END) AS successful_dag_runs,
-				COUNT(CASE WHEN status = 'failed' AND run_time >= NOW() - INTERVAL '30 days' THEN 1 END) AS failed_dag_runs,
+				COUNT(CASE WHEN status IN ('failed', 'timed_out') AND run_time >= NOW() - INTERVAL '30 days' THEN 1 END) AS failed_dag_runs,
 				COUNT(CASE WHEN run_time >= NOW() - INTERVAL '30 days' THEN 1 END) AS total_dag_runs,
 				COUNT(CASE WHEN status = 'running' THEN 1 END) AS active_dag_runs
 			FROM DAG_Runs;
@@ -383,7 +405,7 @@ func (p *postgresManager) GetDashboardStats(ctx context.Context) (*DBDashboardSt
 		taskOutcomesQuery := `
 			SELECT 
 				SUM(CASE WHEN tr.status = 'success' THEN 1 ELSE 0 END) AS completed_tasks,
-				SUM(CASE WHEN tr.status = 'failed' THEN 1 ELSE 0 END) AS failed_tasks
+				SUM(CASE WHEN tr.status IN ('failed', 'timed_out') THEN 1 ELSE 0 END) AS failed_tasks
 			FROM Task_Runs tr
 			JOIN (
 				SELECT task_run_id, MAX(updated_at) AS max_updated_at
@@ -443,7 +465,7 @@ func (p *postgresManager) GetDashboardStats(ctx context.Context) (*DBDashboardSt
 			SELECT
 				date_trunc('day', run_time) AS day,
 				COUNT(*) FILTER (WHERE status = 'success') AS successful_count,
-				COUNT(*) FILTER (WHERE status = 'failed') AS failed_count
+				COUNT(*) FILTER (WHERE status IN ('failed', 'timed_out')) AS failed_count
 			FROM DAG_Runs
 			WHERE run_time >= NOW() - INTERVAL '30 days'
 			GROUP BY day
@@ -541,7 +563,7 @@ func (p *postgresManager) GetDagNames(ctx context.Context, term string, limit in
 
 func (p *postgresManager) GetDagParameters(ctx context.Context, dagName string) ([]*DBParameter, error) {
 	rows, err := p.pool.Query(ctx, `
-	SELECT parameter_id, name, isSecret, defaultValue
+	SELECT parameter_id, name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue, parameterSchema
 	FROM DAG_Parameters
 	WHERE dag_id IN (
 		SELECT dag_id
@@ -561,7 +583,13 @@ func (p *postgresManager) GetDagParameters(ctx context.Context, dagName string)
 
 	for rows.Next() {
 		var param DBParameter
-		if err := rows.Scan(&param.ID, &param.Name, &param.IsSecret, &param.DefaultValue); err != nil {
+		var schema *string
+		if err := rows.Scan(&param.ID, &param.Name, &param.IsSecret, &param.IsConfigMap, &param.Key, &param.DefaultValue, &schema); err != nil {
+			return nil, err
+		}
+
+		param.ParameterSchema, err = parseParameterSchema(schema)
+		if err != nil {
 			return nil, err
 		}
 		params = append(params, &param)
@@ -708,3 +736,24 @@ func (p *postgresManager) GetPodNameAndNamespace(ctx context.Context, podUID str
 
 	return namespace, name, nil
 }
+
+func (p *postgresManager) GetPools(ctx context.Context) ([]*DBPool, error) {
+	rows, err := p.pool.Query(ctx, `
+	SELECT name, namespace, slots, occupied_slots, queued_slots
+	FROM Pool_Usage
+	ORDER BY namespace, name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := []*DBPool{}
+	for rows.Next() {
+		var pool DBPool
+		if err := rows.Scan(&pool.Name, &pool.Namespace, &pool.Slots, &pool.OccupiedSlots, &pool.QueuedSlots); err != nil {
+			return nil, err
+		}
+		pools = append(pools, &pool)
+	}
+	return pools, rows.Err()
+}
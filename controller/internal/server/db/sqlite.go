@@ -53,7 +53,7 @@ func NewSQLiteReadOnlyManager(ctx context.Context, config *SQLiteReadOnlyConfig)
 
 func (s *sqliteManager) GetAllDagMetaData(ctx context.Context, limit int, offset int) ([]*DBDAGMetaData, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT dag_id, name, namespace, version, schedule, active, nexttime, suspended
+		SELECT dag_id, name, namespace, version, schedule, active, nexttime, COALESCE(timezone, ''), suspended
 		FROM DAGs
 		WHERE active = TRUE
 		ORDER BY dag_id DESC
@@ -68,9 +68,10 @@ func (s *sqliteManager) GetAllDagMetaData(ctx context.Context, limit int, offset
 	for rows.Next() {
 		var meta DBDAGMetaData
 		if err := rows.Scan(&meta.DagId, &meta.Name, &meta.Namespace, &meta.Version,
-			&meta.Schedule, &meta.Active, &meta.NextTime, &meta.IsSuspended); err != nil {
+			&meta.Schedule, &meta.Active, &meta.NextTime, &meta.Timezone, &meta.IsSuspended); err != nil {
 			return nil, err
 		}
+		meta.inTimezone()
 
 		meta.Connections, err = s.getDagConnections(ctx, meta.DagId)
 		if err != nil {
@@ -108,11 +109,16 @@ func (s *sqliteManager) GetDagRun(ctx context.Context, dagRunId int) (*DBDagRun,
         SELECT
             d.dag_task_id,
             d.name,
-            COALESCE(r.status, 'pending') AS status
+            COALESCE(r.status, 'pending') AS status,
+            r.map_index,
+            COALESCE(r.map_item, ''),
+            c.run_id
         FROM DAG_Tasks d
         JOIN tasks t ON d.task_id = t.task_id
         LEFT JOIN Task_Runs r ON r.task_id = d.dag_task_id AND r.run_id = ?
-        WHERE d.dag_id = ?`, dagRunId, dagId)
+        LEFT JOIN DAG_Runs c ON c.parent_task_run_id = r.task_run_id
+        WHERE d.dag_id = ?
+        ORDER BY r.task_run_id`, dagRunId, dagId)
 
 	if err != nil {
 		return nil, err
@@ -122,11 +128,12 @@ func (s *sqliteManager) GetDagRun(ctx context.Context, dagRunId int) (*DBDagRun,
 	taskInfo := map[int]DBTaskInfo{}
 	for rows.Next() {
 		var taskId int
-		task := DBTaskInfo{}
-		if err := rows.Scan(&taskId, &task.Name, &task.Status); err != nil {
+		var name, status, mapItem string
+		var mapIndex, childRunId *int
+		if err := rows.Scan(&taskId, &name, &status, &mapIndex, &mapItem, &childRunId); err != nil {
 			return nil, err
 		}
-		taskInfo[taskId] = task
+		addTaskRun(taskInfo, taskId, name, status, mapIndex, mapItem, childRunId)
 	}
 
 	for key := range connections {
@@ -143,7 +150,7 @@ func (s *sqliteManager) GetDagRun(ctx context.Context, dagRunId int) (*DBDagRun,
 
 func (s *sqliteManager) GetDagRuns(ctx context.Context, limit int, offset int) ([]*DBDagRunMeta, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT run_id, d.dag_id, status, successfulcount, failedcount, d.namespace, r.name
+		SELECT run_id, d.dag_id, status, successfulcount, failedcount, d.namespace, r.name, r.parent_run_id
 		FROM DAG_Runs r
 		JOIN DAGs d ON r.dag_id = d.dag_id
 		ORDER BY run_id DESC
@@ -157,7 +164,7 @@ func (s *sqliteManager) GetDagRuns(ctx context.Context, limit int, offset int) (
 	metas := []*DBDagRunMeta{}
 	for rows.Next() {
 		var meta DBDagRunMeta
-		if err := rows.Scan(&meta.Id, &meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount, &meta.Namespace, &meta.Name); err != nil {
+		if err := rows.Scan(&meta.Id, &meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount, &meta.Namespace, &meta.Name, &meta.ParentRunId); err != nil {
 			return nil, err
 		}
 		metas = append(metas, &meta)
@@ -230,9 +237,9 @@ func (s *sqliteManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDagR
 	meta := &DBDagRunAll{Id: dagRunId}
 
 	if err := s.db.QueryRowContext(ctx, `
-        SELECT dag_id, status, successfulCount, failedCount
+        SELECT dag_id, status, successfulCount, failedCount, parent_run_id
         FROM DAG_Runs
-        WHERE run_id = ?`, dagRunId).Scan(&meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount); err != nil {
+        WHERE run_id = ?`, dagRunId).Scan(&meta.DagId, &meta.Status, &meta.SuccessfulCount, &meta.FailedCount, &meta.ParentRunId); err != nil {
 		return nil, err
 	}
 
@@ -246,11 +253,16 @@ func (s *sqliteManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDagR
         SELECT
             d.dag_task_id,
             d.name,
-            COALESCE(r.status, 'pending') AS status
+            COALESCE(r.status, 'pending') AS status,
+            r.map_index,
+            COALESCE(r.map_item, ''),
+            c.run_id
         FROM DAG_Tasks d
         JOIN tasks t ON d.task_id = t.task_id
         LEFT JOIN Task_Runs r ON r.task_id = d.dag_task_id AND r.run_id = ?
-        WHERE d.dag_id = ?`, dagRunId, meta.DagId)
+        LEFT JOIN DAG_Runs c ON c.parent_task_run_id = r.task_run_id
+        WHERE d.dag_id = ?
+        ORDER BY r.task_run_id`, dagRunId, meta.DagId)
 
 	if err != nil {
 		return nil, err
@@ -261,11 +273,12 @@ func (s *sqliteManager) GetDagRunAll(ctx context.Context, dagRunId int) (*DBDagR
 	taskInfo := map[int]DBTaskInfo{}
 	for rows.Next() {
 		var taskId int
-		task := DBTaskInfo{}
-		if err := rows.Scan(&taskId, &task.Name, &task.Status); err != nil {
+		var name, status, mapItem string
+		var mapIndex, childRunId *int
+		if err := rows.Scan(&taskId, &name, &status, &mapIndex, &mapItem, &childRunId); err != nil {
 			return nil, err
 		}
-		taskInfo[taskId] = task
+		addTaskRun(taskInfo, taskId, name, status, mapIndex, mapItem, childRunId)
 	}
 
 	meta.Connections = connections
@@ -308,7 +321,7 @@ func (s *sqliteManager) GetDagPageCount(ctx context.Context, limit int) (int, er
 
 func (s *sqliteManager) GetDagParameters(ctx context.Context, dagName string) ([]*DBParameter, error) {
 	rows, err := s.db.QueryContext(ctx, `
-	SELECT parameter_id, name, isSecret, defaultValue
+	SELECT parameter_id, name, isSecret, isConfigMap, COALESCE(valueKey, ''), defaultValue, parameterSchema
 	FROM DAG_Parameters
 	WHERE dag_id IN (
 		SELECT dag_id
@@ -328,7 +341,13 @@ func (s *sqliteManager) GetDagParameters(ctx context.Context, dagName string) ([
 
 	for rows.Next() {
 		var param DBParameter
-		if err := rows.Scan(&param.ID, &param.Name, &param.IsSecret, &param.DefaultValue); err != nil {
+		var schema *string
+		if err := rows.Scan(&param.ID, &param.Name, &param.IsSecret, &param.IsConfigMap, &param.Key, &param.DefaultValue, &schema); err != nil {
+			return nil, err
+		}
+
+		param.ParameterSchema, err = parseParameterSchema(schema)
+		if err != nil {
 			return nil, err
 		}
 		params = append(params, &param)
@@ -381,7 +400,7 @@ func (s *sqliteManager) GetDashboardStats(ctx context.Context) (*DBDashboardStat
 		dagRunsQuery := `
 			SELECT 
 				SUM(CASE WHEN status = 'success' AND run_time >= datetime('now', '-30 days') THEN 1 ELSE 0 END) AS successful_dag_runs,
-				SUM(CASE WHEN status = 'failed' AND run_time >= datetime('now', '-30 days') THEN 1 ELSE 0 END) AS failed_dag_runs,
+				SUM(CASE WHEN status IN ('failed', 'timed_out') AND run_time >= datetime('now', '-30 days') THEN 1 ELSE 0 END) AS failed_dag_runs,
 				SUM(CASE WHEN run_time >= datetime('now', '-30 days') THEN 1 ELSE 0 END) AS total_dag_runs,
 				SUM(CASE WHEN status = 'running' THEN 1 ELSE 0 END) AS active_dag_runs
 			FROM DAG_Runs;
@@ -425,7 +444,7 @@ func (s *sqliteManager) GetDashboardStats(ctx context.Context) (*DBDashboardStat
 		taskOutcomesQuery := `
 			SELECT 
 				SUM(CASE WHEN tr.status = 'success' THEN 1 ELSE 0 END) AS completed_tasks,
-				SUM(CASE WHEN tr.status = 'failed' THEN 1 ELSE 0 END) AS failed_tasks
+				SUM(CASE WHEN tr.status IN ('failed', 'timed_out') THEN 1 ELSE 0 END) AS failed_tasks
 			FROM Task_Runs tr
 			JOIN (
 				SELECT task_run_id, MAX(updated_at) AS max_updated_at
@@ -495,7 +514,7 @@ func (s *sqliteManager) GetDashboardStats(ctx context.Context) (*DBDashboardStat
 			SELECT
 				strftime('%Y-%m-%d', run_time) AS day,
 				SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) AS successful_count,
-				SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) AS failed_count
+				SUM(CASE WHEN status IN ('failed', 'timed_out') THEN 1 ELSE 0 END) AS failed_count
 			FROM DAG_Runs
 			WHERE run_time >= datetime('now', '-30 days')
 			GROUP BY day
@@ -706,14 +725,22 @@ func (s *sqliteManager) GetTaskDetails(ctx context.Context, taskId int) (*DBTask
 func (s *sqliteManager) GetTaskRunDetails(ctx context.Context, dagRunId int, taskId int) (*DBTaskRunDetails, error) {
 	task := &DBTaskRunDetails{}
 
+	var outputs sql.NullString
 	if err := s.db.QueryRowContext(ctx, `
-	SELECT task_run_id, status, attempts
+	SELECT task_run_id, status, attempts, outputs
 	FROM Task_Runs
 	WHERE run_id = ? AND task_id = ?;
-	`, dagRunId, taskId).Scan(&task.Id, &task.Status, &task.Attempts); err != nil {
+	`, dagRunId, taskId).Scan(&task.Id, &task.Status, &task.Attempts, &outputs); err != nil {
 		return nil, err
 	}
 
+	task.Outputs = map[string]string{}
+	if outputs.Valid {
+		if err := json.Unmarshal([]byte(outputs.String), &task.Outputs); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get the current status of each task
 	rows, err := s.db.QueryContext(ctx, `
 	SELECT Pod_UID, exitCode, name, status, duration
@@ -887,3 +914,24 @@ func generateQuestionMarks(slice []string) string {
 
 	return strings.Join(questionMarks, ", ")
 }
+
+func (s *sqliteManager) GetPools(ctx context.Context) ([]*DBPool, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, namespace, slots, occupied_slots, queued_slots
+		FROM Pool_Usage
+		ORDER BY namespace, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := []*DBPool{}
+	for rows.Next() {
+		var pool DBPool
+		if err := rows.Scan(&pool.Name, &pool.Namespace, &pool.Slots, &pool.OccupiedSlots, &pool.QueuedSlots); err != nil {
+			return nil, err
+		}
+		pools = append(pools, &pool)
+	}
+	return pools, rows.Err()
+}
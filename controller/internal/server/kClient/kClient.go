@@ -2,9 +2,14 @@ package kclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	v1 "kontroler-controller/api/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -13,6 +18,10 @@ import (
 	"k8s.io/client-go/rest"
 )
 
+// ErrDagRunSkipped is returned when the controller removes a new DagRun because its DAG already has
+// maxActiveRuns active runs and a concurrencyPolicy of Forbid
+var ErrDagRunSkipped = errors.New("dag run was skipped as the dag has reached its maxActiveRuns")
+
 var (
 	dagRunsGVR = schema.GroupVersionResource{
 		Group:    "kontroler.greedykomodo",
@@ -153,6 +162,10 @@ func CreateDagRun(ctx context.Context, drForm DagRunForm, isSecretMap map[string
 
 	// Wait for RunID with cleanup on failure
 	runID, err := waitForRunID(ctx, client, namespace, drForm.RunName, opts.RunIDTimeout)
+	if errors.Is(err, ErrDagRunSkipped) {
+		return 0, err
+	}
+
 	if err != nil && opts.Cleanup {
 		// Attempt cleanup on failure
 		deleteCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -180,6 +193,21 @@ func validateDagRunInputs(form DagRunForm, namespace string) error {
 	return nil
 }
 
+// ValidateDagRunParameters checks the parameters of a run form against the parameters of its DAG,
+// so a bad run is rejected before any pod starts
+func ValidateDagRunParameters(form DagRunForm, isSecretMap map[string]bool, specs []v1.DagParameterSpec) error {
+	params := make([]v1.ParameterSpec, 0, len(form.Parameters))
+	for name, value := range form.Parameters {
+		if isSecretMap[name] {
+			params = append(params, v1.ParameterSpec{Name: name, FromSecret: value})
+		} else {
+			params = append(params, v1.ParameterSpec{Name: name, Value: value})
+		}
+	}
+
+	return v1.ValidateRunParameters(specs, params)
+}
+
 func createDagRunResource(form DagRunForm, isSecretMap map[string]bool, namespace string) (*unstructured.Unstructured, error) {
 	parameters := make([]interface{}, 0, len(form.Parameters))
 
@@ -251,6 +279,10 @@ func waitForRunID(ctx context.Context, client dynamic.Interface, namespace, runN
 	for time.Now().Before(deadline) {
 		dagRun, err := client.Resource(dagRunsGVR).Namespace(namespace).Get(ctx, runName, metav1.GetOptions{})
 		if err != nil {
+			// the controller deletes runs that the concurrency policy of their DAG does not let start
+			if apierrors.IsNotFound(err) {
+				return 0, ErrDagRunSkipped
+			}
 			return 0, err
 		}
 
@@ -285,3 +317,42 @@ func SuspendDag(ctx context.Context, req *DagSuspendForm, client dynamic.Interfa
 
 	return nil
 }
+
+// InvalidateDagCache asks the controller to drop the cached task results of a DAG by annotating it
+func InvalidateDagCache(ctx context.Context, req *DagCacheInvalidateForm, client dynamic.Interface) error {
+	existing, err := client.Resource(dagsGVR).Namespace(req.Namespace).Get(ctx, req.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	updated := existing.DeepCopy()
+	annotations := updated.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[v1.DAGAnnotationInvalidateCache] = strings.Join(req.Tasks, ",")
+	updated.SetAnnotations(annotations)
+
+	_, err = client.Resource(dagsGVR).Namespace(req.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// BackfillDag sets the backfill range of a DAG, the controller then starts a run for every schedule time within it
+func BackfillDag(ctx context.Context, req *DagBackfillForm, client dynamic.Interface) error {
+	existing, err := client.Resource(dagsGVR).Namespace(req.Namespace).Get(ctx, req.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	updated := existing.DeepCopy()
+	backfill := map[string]interface{}{
+		"start": req.Start.UTC().Format(time.RFC3339),
+		"end":   req.End.UTC().Format(time.RFC3339),
+	}
+	if err := unstructured.SetNestedMap(updated.Object, backfill, "spec", "backfill"); err != nil {
+		return err
+	}
+
+	_, err = client.Resource(dagsGVR).Namespace(req.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
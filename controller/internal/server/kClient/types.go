@@ -1,6 +1,8 @@
 package kclient
 
 import (
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
 	v1 "kontroler-controller/api/v1alpha1"
 )
@@ -89,3 +91,18 @@ type DagSuspendForm struct {
 	Namespace string `json:"namespace"`
 	Suspend   bool   `json:"suspend"`
 }
+
+// DagCacheInvalidateForm names the tasks of a DAG whose cached results are dropped, every task when none are named
+type DagCacheInvalidateForm struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Tasks     []string `json:"tasks,omitempty"`
+}
+
+// DagBackfillForm is a range of schedule times to start runs of a DAG for, both ends are inclusive
+type DagBackfillForm struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
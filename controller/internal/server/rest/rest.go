@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"kontroler-controller/internal/artifacts"
 	"kontroler-controller/internal/server/auth"
 	"kontroler-controller/internal/server/db"
 	"kontroler-controller/internal/server/logs"
@@ -15,7 +16,7 @@ import (
 	"k8s.io/client-go/dynamic"
 )
 
-func NewFiberHttpServer(dbManager db.DbManager, kClient dynamic.Interface, authManager auth.AuthManager, corsUiAddress string, auditLogs bool, logFetcher logs.LogFetcher) *fiber.App {
+func NewFiberHttpServer(dbManager db.DbManager, kClient dynamic.Interface, authManager auth.AuthManager, corsUiAddress string, auditLogs bool, logFetcher logs.LogFetcher, artifactStore artifacts.ArtifactStore) *fiber.App {
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: true,
 	})
@@ -36,7 +37,7 @@ func NewFiberHttpServer(dbManager db.DbManager, kClient dynamic.Interface, authM
 		return Authentication(c, authManager)
 	})
 
-	addV1(app, dbManager, kClient, authManager, logFetcher)
+	addV1(app, dbManager, kClient, authManager, logFetcher, artifactStore)
 
 	return app
 }
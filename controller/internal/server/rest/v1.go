@@ -1,11 +1,15 @@
 package rest
 
 import (
+	"errors"
 	"fmt"
+	v1 "kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/artifacts"
 	"kontroler-controller/internal/server/auth"
 	"kontroler-controller/internal/server/db"
 	kclient "kontroler-controller/internal/server/kClient"
 	"kontroler-controller/internal/server/logs"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,18 +19,24 @@ import (
 	"k8s.io/client-go/dynamic"
 )
 
-func addV1(app *fiber.App, dbManager db.DbManager, kubClient dynamic.Interface, authManager auth.AuthManager, logFetcher logs.LogFetcher) {
+func addV1(app *fiber.App, dbManager db.DbManager, kubClient dynamic.Interface, authManager auth.AuthManager, logFetcher logs.LogFetcher, artifactStore artifacts.ArtifactStore) {
 
 	router := app.Group("/api/v1")
 
 	addDags(router, dbManager, kubClient)
 	addStats(router, dbManager)
+	addPools(router, dbManager)
 	addAccountAuth(router, authManager)
 
 	// check if a bucket has been selected/log fetching enabled
 	if logFetcher != nil {
 		addLogs(router, logFetcher, dbManager)
 	}
+
+	// artifacts are kept in the same store as logs
+	if artifactStore != nil {
+		addArtifacts(router, artifactStore)
+	}
 }
 
 func addDags(router fiber.Router, dbManager db.DbManager, kubClient dynamic.Interface) {
@@ -51,6 +61,64 @@ func addDags(router fiber.Router, dbManager db.DbManager, kubClient dynamic.Inte
 		})
 	})
 
+	dagRouter.Post("/backfill", roleMiddleware("editor"), func(c *fiber.Ctx) error {
+		var req kclient.DagBackfillForm
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "cannot parse JSON",
+			})
+		}
+
+		if req.Name == "" || req.Namespace == "" || req.Start.IsZero() || req.End.IsZero() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name, namespace, start and end are required",
+			})
+		}
+
+		if req.End.Before(req.Start) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "end must not be before start",
+			})
+		}
+
+		if err := kclient.BackfillDag(c.Context(), &req, kubClient); err != nil {
+			log.Error().Err(err).Msg("failed to backfill DAG")
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to backfill DAG: %v", err),
+			})
+		}
+		log.Info().Str("dag", req.Name).Time("start", req.Start).Time("end", req.End).Msg("DAG backfill requested")
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message": "DAG backfill requested successfully",
+		})
+	})
+
+	dagRouter.Post("/cache/invalidate", roleMiddleware("editor"), func(c *fiber.Ctx) error {
+		var req kclient.DagCacheInvalidateForm
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "cannot parse JSON",
+			})
+		}
+
+		if req.Name == "" || req.Namespace == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name and namespace are required",
+			})
+		}
+
+		if err := kclient.InvalidateDagCache(c.Context(), &req, kubClient); err != nil {
+			log.Error().Err(err).Msg("failed to invalidate DAG cache")
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to invalidate DAG cache: %v", err),
+			})
+		}
+		log.Info().Str("dag", req.Name).Strs("tasks", req.Tasks).Msg("DAG cache invalidation requested")
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message": "DAG cache invalidation requested successfully",
+		})
+	})
+
 	dagRouter.Get("/names", roleMiddleware("viewer"), func(c *fiber.Ctx) error {
 		term := c.Query("term")
 		if term == "" {
@@ -328,7 +396,30 @@ func addDags(router fiber.Router, dbManager db.DbManager, kubClient dynamic.Inte
 			return c.SendStatus(fiber.StatusBadRequest)
 		}
 
+		parameters, err := dbManager.GetDagParameters(c.Context(), dagrunForm.Name)
+		if err != nil {
+			log.Error().Err(err).Msg("Error getting parameters")
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		specs := make([]v1.DagParameterSpec, 0, len(parameters))
+		for _, parameter := range parameters {
+			specs = append(specs, parameter.ToSpec())
+		}
+
+		if err := kclient.ValidateDagRunParameters(dagrunForm, isSecretMap, specs); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
 		runId, err := kclient.CreateDagRun(c.Context(), dagrunForm, isSecretMap, dagrunForm.Namespace, kubClient, nil)
+		if errors.Is(err, kclient.ErrDagRunSkipped) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
 		if err != nil {
 			log.Error().Err(err).Msg("failed to create dagrun")
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -388,6 +479,20 @@ func addStats(router fiber.Router, dbManager db.DbManager) {
 	})
 }
 
+func addPools(router fiber.Router, dbManager db.DbManager) {
+	poolRouter := router.Group("/pools")
+
+	poolRouter.Get("/", roleMiddleware("viewer"), func(c *fiber.Ctx) error {
+		pools, err := dbManager.GetPools(c.Context())
+		if err != nil {
+			log.Error().Err(err).Msg("Error getting pools")
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(pools)
+	})
+}
+
 func addAccountAuth(router fiber.Router, authManager auth.AuthManager) {
 	authRouter := router.Group("/auth")
 
@@ -576,3 +681,66 @@ func addLogs(router fiber.Router, logFetcher logs.LogFetcher, db db.DbManager) {
 		return logs.ServeLogWithRange(c, runId, podUID, logFetcher)
 	})
 }
+
+// artifactPathRegex matches the task and artifact names that can form an artifact key
+var artifactPathRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func addArtifacts(router fiber.Router, artifactStore artifacts.ArtifactStore) {
+	artifactRouter := router.Group("/artifacts")
+
+	artifactRouter.Get("/run/:run", roleMiddleware("viewer"), func(c *fiber.Ctx) error {
+		runId, err := strconv.Atoi(c.Params("run"))
+		if err != nil {
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+
+		runArtifacts, err := artifactStore.ListArtifacts(c.Context(), runId)
+		if err != nil {
+			log.Error().Err(err).Int("runId", runId).Msg("failed to list artifacts")
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		if runArtifacts == nil {
+			runArtifacts = []artifacts.Artifact{}
+		}
+
+		return c.JSON(runArtifacts)
+	})
+
+	artifactRouter.Get("/run/:run/task/:task/:name", roleMiddleware("viewer"), func(c *fiber.Ctx) error {
+		runId, err := strconv.Atoi(c.Params("run"))
+		if err != nil {
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+
+		taskName, name := c.Params("task"), c.Params("name")
+		if !artifactPathRegex.MatchString(taskName) || !artifactPathRegex.MatchString(name) {
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+
+		// map task instances keep their artifacts apart
+		var mapIndex *int
+		if value := c.Query("mapIndex"); value != "" {
+			index, err := strconv.Atoi(value)
+			if err != nil || index < 0 {
+				return c.SendStatus(fiber.StatusBadRequest)
+			}
+			mapIndex = &index
+		}
+
+		reader, err := artifactStore.GetArtifact(c.Context(), artifacts.ArtifactKey(runId, taskName, mapIndex, name))
+		if errors.Is(err, artifacts.ErrArtifactNotFound) {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		if err != nil {
+			log.Error().Err(err).Int("runId", runId).Str("task", taskName).Str("artifact", name).Msg("failed to get artifact")
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		c.Set(fiber.HeaderContentType, "application/gzip")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.tar.gz"`, name))
+
+		// the stream is closed once it has been sent
+		return c.SendStream(reader)
+	})
+}
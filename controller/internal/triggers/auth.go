@@ -0,0 +1,92 @@
+package triggers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kontroler-controller/api/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errUnauthorized is returned when a request does not carry the credentials of its trigger
+var errUnauthorized = errors.New("unauthorized")
+
+// authenticate checks a request against every kind of verification its trigger sets
+func (s *Server) authenticate(ctx context.Context, r *http.Request, body []byte, trigger *v1alpha1.Trigger) error {
+	if trigger.Spec.BearerToken != nil {
+		token, err := s.readSecret(ctx, trigger.Namespace, trigger.Spec.BearerToken)
+		if err != nil {
+			return err
+		}
+
+		if err := verifyBearerToken(r.Header.Get("Authorization"), token); err != nil {
+			return err
+		}
+	}
+
+	if trigger.Spec.HMAC != nil {
+		key, err := s.readSecret(ctx, trigger.Namespace, &trigger.Spec.HMAC.SecretKeyRef)
+		if err != nil {
+			return err
+		}
+
+		if err := verifySignature(r.Header.Get(trigger.Spec.HMAC.GetSignatureHeader()), body, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func verifyBearerToken(authorization string, token []byte) error {
+	sent, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(sent), token) != 1 {
+		return fmt.Errorf("%w: invalid bearer token", errUnauthorized)
+	}
+
+	return nil
+}
+
+// verifySignature checks a hex encoded HMAC-SHA256 of the body, with or without a "sha256=" prefix
+func verifySignature(header string, body, key []byte) error {
+	signature, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("%w: missing or malformed signature", errUnauthorized)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("%w: invalid signature", errUnauthorized)
+	}
+
+	return nil
+}
+
+// readSecret reads a key of a secret in the namespace of the trigger, defaulting to the "secret" key
+func (s *Server) readSecret(ctx context.Context, namespace string, ref *v1alpha1.KeyRef) ([]byte, error) {
+	key := ref.Key
+	if key == "" {
+		key = v1alpha1.DefaultSecretKey
+	}
+
+	secret, err := s.secrets.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", ref.Name, err)
+	}
+
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("secret %s has no key %s", ref.Name, key)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,169 @@
+package triggers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kontroler-controller/api/v1alpha1"
+)
+
+const (
+	cloudEventsContentType      = "application/cloudevents+json"
+	cloudEventsBatchContentType = "application/cloudevents-batch+json"
+	// binary CloudEvents carry their attributes in headers with this prefix and their data in the body
+	cloudEventsHeaderPrefix = "Ce-"
+)
+
+// errUnsupportedEvent is returned for events that cannot start a single run, such as a batch of CloudEvents
+var errUnsupportedEvent = errors.New("unsupported event")
+
+// event is what a request to a trigger carries
+type event struct {
+	// id is empty when the event has none, each delivery of it then starts a run
+	id string
+	// fields parameters are read from, the body of a JSON event or the attributes of a CloudEvent with its data under "data"
+	fields interface{}
+}
+
+// parseEvent reads a JSON event or a CloudEvent in structured or binary mode from a request
+func parseEvent(r *http.Request, body []byte, spec *v1alpha1.TriggerSpec) (*event, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case mediaType == cloudEventsBatchContentType:
+		return nil, fmt.Errorf("%w: batches of CloudEvents are not supported", errUnsupportedEvent)
+	case mediaType == cloudEventsContentType:
+		fields, err := decodeJSON(body)
+		if err != nil {
+			return nil, err
+		}
+
+		attributes, ok := fields.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("CloudEvent must be a JSON object")
+		}
+
+		id, _ := attributes["id"].(string)
+		if id == "" {
+			return nil, errors.New("CloudEvent has no id")
+		}
+
+		return &event{id: id, fields: attributes}, nil
+	case r.Header.Get(cloudEventsHeaderPrefix+"Id") != "":
+		attributes := map[string]interface{}{}
+		for name, values := range r.Header {
+			if attribute, ok := strings.CutPrefix(name, cloudEventsHeaderPrefix); ok && len(values) > 0 {
+				attributes[strings.ToLower(attribute)] = values[0]
+			}
+		}
+
+		if len(body) > 0 {
+			data, err := decodeJSON(body)
+			if err != nil {
+				// data that is not JSON can still be read as a whole
+				data = string(body)
+			}
+			attributes["data"] = data
+		}
+
+		return &event{id: r.Header.Get(cloudEventsHeaderPrefix + "Id"), fields: attributes}, nil
+	}
+
+	var fields interface{}
+	if len(body) > 0 {
+		var err error
+		if fields, err = decodeJSON(body); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &event{fields: fields}
+	switch {
+	case spec.EventIDField != "":
+		if value, ok := lookupField(fields, spec.EventIDField); ok {
+			e.id = fieldValue(value)
+		}
+	case spec.EventIDHeader != "":
+		e.id = r.Header.Get(spec.EventIDHeader)
+	}
+
+	return e, nil
+}
+
+// parameters returns the run parameters of the trigger read from the event, fields that are
+// missing are left out so the DAG default applies
+func (e *event) parameters(spec *v1alpha1.TriggerSpec) []v1alpha1.ParameterSpec {
+	params := []v1alpha1.ParameterSpec{}
+	for _, param := range spec.Parameters {
+		value, ok := lookupField(e.fields, param.Field)
+		if !ok {
+			continue
+		}
+
+		params = append(params, v1alpha1.ParameterSpec{Name: param.Name, Value: fieldValue(value)})
+	}
+
+	return params
+}
+
+func decodeJSON(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// keep numbers as they were sent rather than as floats
+	decoder.UseNumber()
+
+	var fields interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	return fields, nil
+}
+
+// lookupField follows a dotted path through objects and arrays, array elements are selected by their index
+func lookupField(fields interface{}, path string) (interface{}, bool) {
+	current := fields
+	for _, segment := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, false
+			}
+			current = value[index]
+		default:
+			return nil, false
+		}
+	}
+
+	if current == nil {
+		return nil, false
+	}
+
+	return current, true
+}
+
+// fieldValue turns a field into a parameter value, strings are used as they are and anything else as its JSON
+func fieldValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
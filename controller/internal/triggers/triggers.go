@@ -0,0 +1,297 @@
+package triggers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+
+	"github.com/google/uuid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+//+kubebuilder:rbac:groups=kontroler.greedykomodo,resources=triggers,verbs=get;list;watch
+
+const (
+	// PathPrefix is where triggers are served, followed by the namespace and path of the trigger
+	PathPrefix = "/triggers/"
+
+	// maxBodySize is the largest event a trigger accepts
+	maxBodySize = 1 << 20
+	// event ids are stored in a column of this size
+	maxEventIDLength = 255
+)
+
+// DagRunCreator creates DagRuns the same way the scheduler creates scheduled runs
+type DagRunCreator interface {
+	CreateDagRunObject(dagInfo *db.DagInfo, name string) *v1alpha1.DagRun
+	SubmitDagRun(ctx context.Context, dagRun *v1alpha1.DagRun, opts metav1.CreateOptions) error
+}
+
+// EventStore remembers the events with an id that have started runs
+type EventStore interface {
+	RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error)
+	GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error)
+}
+
+// Server serves the endpoints of triggers, starting a run of the DAG of a trigger for every
+// verified event sent to it. It runs on every replica of the controller, not just the leader
+type Server struct {
+	addr     string
+	triggers client.Reader
+	secrets  kubernetes.Interface
+	events   EventStore
+	runs     DagRunCreator
+}
+
+// response is returned for an event that started a run, or one that had already started it
+type response struct {
+	DagRun    string `json:"dagRun"`
+	Namespace string `json:"namespace"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+func NewServer(addr string, triggers client.Reader, secrets kubernetes.Interface, events EventStore, runs DagRunCreator) *Server {
+	return &Server{
+		addr:     addr,
+		triggers: triggers,
+		secrets:  secrets,
+		events:   events,
+		runs:     runs,
+	}
+}
+
+// Start serves the triggers until ctx is done, it implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(PathPrefix, s)
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		log.Log.Info("serving triggers", "addr", s.addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection lets every replica serve triggers, events are only deduplicated through the database
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	namespace, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+	if !ok || namespace == "" || path == "" {
+		http.Error(w, "trigger not found", http.StatusNotFound)
+		return
+	}
+
+	ctx := r.Context()
+
+	trigger, status, err := s.findTrigger(ctx, namespace, path)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := trigger.Spec.Validate(); err != nil {
+		log.Log.Error(err, "invalid trigger", "trigger", trigger.Name, "namespace", namespace)
+		http.Error(w, "trigger is invalid", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := readBody(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "event is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, "failed to read event", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.authenticate(ctx, r, body, trigger); err != nil {
+		if errors.Is(err, errUnauthorized) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		log.Log.Error(err, "failed to authenticate trigger request", "trigger", trigger.Name, "namespace", namespace)
+		http.Error(w, "failed to authenticate request", http.StatusInternalServerError)
+		return
+	}
+
+	event, err := parseEvent(r, body, &trigger.Spec)
+	if err != nil {
+		if errors.Is(err, errUnsupportedEvent) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(event.id) > maxEventIDLength {
+		http.Error(w, "event id is too long", http.StatusBadRequest)
+		return
+	}
+
+	s.startRun(ctx, w, trigger, event)
+}
+
+// findTrigger returns the trigger serving a path of a namespace, with the status to respond with when there is none
+func (s *Server) findTrigger(ctx context.Context, namespace, path string) (*v1alpha1.Trigger, int, error) {
+	var triggers v1alpha1.TriggerList
+	if err := s.triggers.List(ctx, &triggers, client.InNamespace(namespace)); err != nil {
+		log.Log.Error(err, "failed to list triggers", "namespace", namespace)
+		return nil, http.StatusInternalServerError, errors.New("failed to find trigger")
+	}
+
+	var found *v1alpha1.Trigger
+	for i := range triggers.Items {
+		if triggers.Items[i].Spec.Path != path {
+			continue
+		}
+
+		if found != nil {
+			return nil, http.StatusConflict, errors.New("more than one trigger is served at this path")
+		}
+		found = &triggers.Items[i]
+	}
+
+	if found == nil {
+		return nil, http.StatusNotFound, errors.New("trigger not found")
+	}
+
+	return found, http.StatusOK, nil
+}
+
+// startRun creates the DagRun of an event, an event with an id that already started a run gets that run back.
+// The run of an event with an id is named after it and only recorded once it exists, so an event sent again
+// before it is recorded finds the run by its name instead of starting another one
+func (s *Server) startRun(ctx context.Context, w http.ResponseWriter, trigger *v1alpha1.Trigger, event *event) {
+	name := "dagrun-" + uuid.New().String()
+
+	if event.id != "" {
+		existing, found, err := s.events.GetTriggerEvent(ctx, trigger.Namespace, trigger.Name, event.id)
+		if err != nil {
+			log.Log.Error(err, "failed to look up trigger event", "trigger", trigger.Name, "namespace", trigger.Namespace, "eventId", event.id)
+			http.Error(w, "failed to look up event", http.StatusInternalServerError)
+			return
+		}
+
+		if found {
+			log.Log.Info("trigger event already started a run", "trigger", trigger.Name, "namespace", trigger.Namespace, "eventId", event.id, "name", existing)
+			writeResponse(w, http.StatusOK, response{DagRun: existing, Namespace: trigger.Namespace, Duplicate: true})
+			return
+		}
+
+		name = eventRunName(trigger, event.id)
+	}
+
+	dagRun := s.runs.CreateDagRunObject(&db.DagInfo{DagName: trigger.Spec.DagName, Namespace: trigger.Namespace}, name)
+	dagRun.Spec.Parameters = event.parameters(&trigger.Spec)
+	dagRun.Annotations = map[string]string{
+		v1alpha1.TriggerAnnotation: trigger.Name,
+	}
+	if event.id != "" {
+		dagRun.Annotations[v1alpha1.TriggerEventAnnotation] = event.id
+	}
+
+	if err := s.runs.SubmitDagRun(ctx, dagRun, metav1.CreateOptions{}); err != nil {
+		// the event started the run before, but it was not recorded yet
+		if event.id != "" && apierrors.IsAlreadyExists(err) {
+			s.recordEvent(ctx, trigger, event.id, name)
+			log.Log.Info("trigger event already started a run", "trigger", trigger.Name, "namespace", trigger.Namespace, "eventId", event.id, "name", name)
+			writeResponse(w, http.StatusOK, response{DagRun: name, Namespace: trigger.Namespace, Duplicate: true})
+			return
+		}
+
+		log.Log.Error(err, "failed to create DagRun for trigger", "trigger", trigger.Name, "namespace", trigger.Namespace, "name", name)
+
+		// runs the admission webhook rejects, e.g. for a missing parameter, are the sender's to fix
+		if apierrors.IsForbidden(err) || apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		http.Error(w, "failed to create DagRun", http.StatusInternalServerError)
+		return
+	}
+
+	if event.id != "" {
+		s.recordEvent(ctx, trigger, event.id, name)
+	}
+
+	log.Log.Info("DagRun created by trigger", "trigger", trigger.Name, "namespace", trigger.Namespace, "name", name, "eventId", event.id)
+	writeResponse(w, http.StatusCreated, response{DagRun: name, Namespace: trigger.Namespace})
+}
+
+// recordEvent records the run an event started. The run exists either way, so a failure is only logged,
+// the event finds its run by name when it is sent again
+func (s *Server) recordEvent(ctx context.Context, trigger *v1alpha1.Trigger, eventId, name string) {
+	if _, _, err := s.events.RecordTriggerEvent(context.WithoutCancel(ctx), trigger.Namespace, trigger.Name, eventId, name); err != nil {
+		log.Log.Error(err, "failed to record trigger event", "trigger", trigger.Name, "namespace", trigger.Namespace, "eventId", eventId, "name", name)
+	}
+}
+
+// eventRunName names the run of an event after the trigger and id of the event
+func eventRunName(trigger *v1alpha1.Trigger, eventId string) string {
+	sum := sha256.Sum256([]byte(trigger.Namespace + "/" + trigger.Name + "/" + eventId))
+	return "dagrun-" + hex.EncodeToString(sum[:16])
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Log.Error(err, "failed to write trigger response")
+	}
+}
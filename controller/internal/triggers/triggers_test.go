@@ -0,0 +1,356 @@
+package triggers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/dag"
+	"kontroler-controller/internal/db"
+
+	cron "github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var dagRunGVR = schema.GroupVersionResource{Group: "kontroler.greedykomodo", Version: "v1alpha1", Resource: "dagruns"}
+
+type testServer struct {
+	server  *Server
+	dynamic *dynamicfake.FakeDynamicClient
+}
+
+func newTestServer(t *testing.T, triggers ...*v1alpha1.Trigger) *testServer {
+	t.Helper()
+	ctx := context.Background()
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	dbManager, _, err := db.NewSqliteManager(ctx, &parser, &db.SQLiteConfig{DBPath: ":memory:"})
+	require.NoError(t, err)
+	require.NoError(t, dbManager.InitaliseDatabase(ctx))
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	objects := make([]runtime.Object, 0, len(triggers))
+	for _, trigger := range triggers {
+		objects = append(objects, trigger)
+	}
+	triggerClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+	secrets := kubefake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+		Data: map[string][]byte{
+			"token":       []byte("s3cret-token"),
+			"signing-key": []byte("signing-key"),
+		},
+	})
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme)
+
+	return &testServer{
+		server:  NewServer("0", triggerClient, secrets, dbManager, dag.NewDagScheduler(dbManager, dynamicClient)),
+		dynamic: dynamicClient,
+	}
+}
+
+func (ts *testServer) send(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	return rec
+}
+
+func (ts *testServer) dagRuns(t *testing.T) []unstructured.Unstructured {
+	t.Helper()
+
+	list, err := ts.dynamic.Resource(dagRunGVR).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	return list.Items
+}
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte("signing-key"))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder) response {
+	t.Helper()
+
+	var resp response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestServer_BearerToken(t *testing.T) {
+	ts := newTestServer(t, &v1alpha1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: "default"},
+		Spec: v1alpha1.TriggerSpec{
+			DagName:       "release",
+			Path:          "ci/deploy",
+			BearerToken:   &v1alpha1.KeyRef{Name: "webhook", Key: "token"},
+			EventIDHeader: "X-Delivery",
+			Parameters: []v1alpha1.TriggerParameter{
+				{Name: "version", Field: "release.tag"},
+				{Name: "build", Field: "builds.0.number"},
+				{Name: "missing", Field: "release.notes"},
+			},
+		},
+	})
+	body := `{"release": {"tag": "v1.2.0"}, "builds": [{"number": 42}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/triggers/default/ci/deploy", strings.NewReader(body))
+	require.Equal(t, http.StatusUnauthorized, ts.send(req).Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/ci/deploy", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer wrong")
+	require.Equal(t, http.StatusUnauthorized, ts.send(req).Code)
+	require.Empty(t, ts.dagRuns(t))
+
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/ci/deploy", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret-token")
+	req.Header.Set("X-Delivery", "delivery-1")
+	rec := ts.send(req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	resp := decodeResponse(t, rec)
+	require.False(t, resp.Duplicate)
+
+	runs := ts.dagRuns(t)
+	require.Len(t, runs, 1)
+	require.Equal(t, resp.DagRun, runs[0].GetName())
+	require.Equal(t, "deploy", runs[0].GetAnnotations()[v1alpha1.TriggerAnnotation])
+	require.Equal(t, "delivery-1", runs[0].GetAnnotations()[v1alpha1.TriggerEventAnnotation])
+
+	var dagRun v1alpha1.DagRun
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(runs[0].Object, &dagRun))
+	require.Equal(t, "release", dagRun.Spec.DagName)
+	require.Equal(t, []v1alpha1.ParameterSpec{
+		{Name: "version", Value: "v1.2.0"},
+		{Name: "build", Value: "42"},
+	}, dagRun.Spec.Parameters)
+
+	// a redelivered event gets the run it started back
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/ci/deploy", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret-token")
+	req.Header.Set("X-Delivery", "delivery-1")
+	rec = ts.send(req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, response{DagRun: resp.DagRun, Namespace: "default", Duplicate: true}, decodeResponse(t, rec))
+	require.Len(t, ts.dagRuns(t), 1)
+
+	// events without an id start a run every time
+	for i := 0; i < 2; i++ {
+		req = httptest.NewRequest(http.MethodPost, "/triggers/default/ci/deploy", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret-token")
+		require.Equal(t, http.StatusCreated, ts.send(req).Code)
+	}
+	require.Len(t, ts.dagRuns(t), 3)
+}
+
+func TestServer_CloudEvents(t *testing.T) {
+	ts := newTestServer(t, &v1alpha1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{Name: "object-created", Namespace: "default"},
+		Spec: v1alpha1.TriggerSpec{
+			DagName: "ingest",
+			Path:    "storage",
+			HMAC:    &v1alpha1.HMACAuth{SecretKeyRef: v1alpha1.KeyRef{Name: "webhook", Key: "signing-key"}},
+			Parameters: []v1alpha1.TriggerParameter{
+				{Name: "bucket", Field: "data.bucket"},
+				{Name: "source", Field: "source"},
+			},
+		},
+	})
+
+	structured := `{"specversion": "1.0", "id": "evt-1", "source": "/storage", "type": "object.created", "data": {"bucket": "raw"}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/triggers/default/storage", strings.NewReader(structured))
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	req.Header.Set(v1alpha1.DefaultSignatureHeader, sign(structured+" "))
+	require.Equal(t, http.StatusUnauthorized, ts.send(req).Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/storage", strings.NewReader(structured))
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	req.Header.Set(v1alpha1.DefaultSignatureHeader, sign(structured))
+	rec := ts.send(req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	// the same event sent in binary mode is recognised by its id
+	binary := `{"bucket": "raw"}`
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/storage", strings.NewReader(binary))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Id", "evt-1")
+	req.Header.Set("Ce-Source", "/storage")
+	req.Header.Set(v1alpha1.DefaultSignatureHeader, sign(binary))
+	rec = ts.send(req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, decodeResponse(t, rec).Duplicate)
+
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/storage", strings.NewReader(binary))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Id", "evt-2")
+	req.Header.Set("Ce-Source", "/storage")
+	req.Header.Set(v1alpha1.DefaultSignatureHeader, sign(binary))
+	rec = ts.send(req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	runs := ts.dagRuns(t)
+	require.Len(t, runs, 2)
+	for _, run := range runs {
+		var dagRun v1alpha1.DagRun
+		require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(run.Object, &dagRun))
+		require.Equal(t, []v1alpha1.ParameterSpec{
+			{Name: "bucket", Value: "raw"},
+			{Name: "source", Value: "/storage"},
+		}, dagRun.Spec.Parameters)
+	}
+
+	batch := `[` + structured + `]`
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/storage", strings.NewReader(batch))
+	req.Header.Set("Content-Type", "application/cloudevents-batch+json")
+	req.Header.Set(v1alpha1.DefaultSignatureHeader, sign(batch))
+	require.Equal(t, http.StatusUnsupportedMediaType, ts.send(req).Code)
+}
+
+func TestServer_RejectedRun(t *testing.T) {
+	ts := newTestServer(t, &v1alpha1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: "default"},
+		Spec: v1alpha1.TriggerSpec{
+			DagName:      "release",
+			Path:         "deploy",
+			BearerToken:  &v1alpha1.KeyRef{Name: "webhook", Key: "token"},
+			EventIDField: "id",
+		},
+	})
+
+	// the admission webhook rejects the run, for example for a missing required parameter
+	ts.dynamic.PrependReactor("create", "dagruns", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(dagRunGVR.GroupResource(), "", nil)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/triggers/default/deploy", strings.NewReader(`{"id": "evt-1"}`))
+	req.Header.Set("Authorization", "Bearer s3cret-token")
+	require.Equal(t, http.StatusUnprocessableEntity, ts.send(req).Code)
+
+	// the event is not recorded, so it can start a run once the problem is fixed
+	ts.dynamic.ReactionChain = ts.dynamic.ReactionChain[1:]
+
+	req = httptest.NewRequest(http.MethodPost, "/triggers/default/deploy", strings.NewReader(`{"id": "evt-1"}`))
+	req.Header.Set("Authorization", "Bearer s3cret-token")
+	require.Equal(t, http.StatusCreated, ts.send(req).Code)
+	require.Len(t, ts.dagRuns(t), 1)
+}
+
+func TestServer_UnrecordedRun(t *testing.T) {
+	trigger := &v1alpha1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: "default"},
+		Spec: v1alpha1.TriggerSpec{
+			DagName:      "release",
+			Path:         "deploy",
+			BearerToken:  &v1alpha1.KeyRef{Name: "webhook", Key: "token"},
+			EventIDField: "id",
+		},
+	}
+	ts := newTestServer(t, trigger)
+
+	// the run of the event was created, but the controller stopped before recording it
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(dagRunGVR.GroupVersion().WithKind("DagRun"))
+	existing.SetName(eventRunName(trigger, "evt-1"))
+	existing.SetNamespace("default")
+	_, err := ts.dynamic.Resource(dagRunGVR).Namespace("default").Create(context.Background(), existing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/triggers/default/deploy", strings.NewReader(`{"id": "evt-1"}`))
+		req.Header.Set("Authorization", "Bearer s3cret-token")
+		rec := ts.send(req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, response{DagRun: existing.GetName(), Namespace: "default", Duplicate: true}, decodeResponse(t, rec))
+	}
+	require.Len(t, ts.dagRuns(t), 1)
+
+	name, found, err := ts.server.events.GetTriggerEvent(context.Background(), "default", "deploy", "evt-1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, existing.GetName(), name)
+}
+
+func TestServer_Routing(t *testing.T) {
+	ts := newTestServer(t,
+		&v1alpha1.Trigger{
+			ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"},
+			Spec:       v1alpha1.TriggerSpec{DagName: "a", Path: "shared", BearerToken: &v1alpha1.KeyRef{Name: "webhook", Key: "token"}},
+		},
+		&v1alpha1.Trigger{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default"},
+			Spec:       v1alpha1.TriggerSpec{DagName: "b", Path: "shared", BearerToken: &v1alpha1.KeyRef{Name: "webhook", Key: "token"}},
+		},
+		&v1alpha1.Trigger{
+			ObjectMeta: metav1.ObjectMeta{Name: "open", Namespace: "default"},
+			Spec:       v1alpha1.TriggerSpec{DagName: "open", Path: "open"},
+		},
+	)
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodGet, "/triggers/default/shared", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/triggers/default", http.StatusNotFound},
+		{http.MethodPost, "/triggers/default/unknown", http.StatusNotFound},
+		{http.MethodPost, "/triggers/other/shared", http.StatusNotFound},
+		{http.MethodPost, "/triggers/default/shared", http.StatusConflict},
+		// a trigger that does not verify its requests is never served
+		{http.MethodPost, "/triggers/default/open", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		rec := ts.send(httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{}`)))
+		require.Equal(t, tt.status, rec.Code, "%s %s", tt.method, tt.target)
+	}
+	require.Empty(t, ts.dagRuns(t))
+}
+
+func TestLookupField(t *testing.T) {
+	fields, err := decodeJSON([]byte(`{"a": {"b": [{"c": "x"}, {"c": 1.50}]}, "t": true, "n": null}`))
+	require.NoError(t, err)
+
+	value, ok := lookupField(fields, "a.b.0.c")
+	require.True(t, ok)
+	require.Equal(t, "x", fieldValue(value))
+
+	value, ok = lookupField(fields, "a.b.1.c")
+	require.True(t, ok)
+	require.Equal(t, "1.50", fieldValue(value))
+
+	value, ok = lookupField(fields, "a.b.1")
+	require.True(t, ok)
+	require.Equal(t, `{"c":1.50}`, fieldValue(value))
+
+	value, ok = lookupField(fields, "t")
+	require.True(t, ok)
+	require.Equal(t, "true", fieldValue(value))
+
+	for _, path := range []string{"n", "a.b.2.c", "a.b.x", "a.c", "t.x"} {
+		_, ok = lookupField(fields, path)
+		require.False(t, ok, path)
+	}
+}
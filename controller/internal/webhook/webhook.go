@@ -20,6 +20,7 @@ type WebhookManager interface {
 type WebhookNotifier interface {
 	NotifyTaskRun(name string, status string, dagRunId, taskId int, url string, verifySSL bool)
 	NotifyPodEvent(name string, status string, dagRunId, taskId int, url string, verifySSL bool, duration int)
+	NotifyDagRun(dagName string, status string, dagRunId int, url string, verifySSL bool)
 }
 
 type WebhookDataBase struct {
@@ -50,6 +51,13 @@ type PodEventDetails struct {
 	Duration int    `json:"duration"`
 }
 
+type DagRunHookDetails struct {
+	WebhookDataBase
+	Status   string `json:"status"`
+	DagRunId int    `json:"dagRunId"`
+	DagName  string `json:"dagName"`
+}
+
 type webhookManager struct {
 	urlValidator SSLVerifier
 	webhookChan  chan WebhookPayload
@@ -105,6 +113,21 @@ func (w *webhookNotifier) NotifyPodEvent(name string, status string, dagRunId, t
 	}
 }
 
+func (w *webhookNotifier) NotifyDagRun(dagName string, status string, dagRunId int, url string, verifySSL bool) {
+	w.webhookChan <- WebhookPayload{
+		URL:       url,
+		VerifySSL: verifySSL,
+		Data: DagRunHookDetails{
+			WebhookDataBase: WebhookDataBase{
+				Type: "dagrun",
+			},
+			Status:   status,
+			DagRunId: dagRunId,
+			DagName:  dagName,
+		},
+	}
+}
+
 func (w *webhookManager) SendWebhook(url string, payload []byte) error {
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
 	if err != nil {
@@ -0,0 +1,163 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/artifacts"
+	"kontroler-controller/internal/config"
+	"kontroler-controller/internal/db"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	artifactsVolumeName     = "kontroler-artifacts"
+	artifactStoreVolumeName = "kontroler-artifact-store"
+)
+
+var errArtifactsNotConfigured = errors.New("task uses artifacts but no artifacts image is configured")
+
+// ArtifactConfig is how task pods reach the store artifacts are kept in
+type ArtifactConfig struct {
+	config.Artifacts
+	Store config.LogStore
+}
+
+// addArtifacts injects the init container that downloads the artifacts of upstream tasks and wraps the
+// task command so the artifacts it declares are staged for the uploader sidecar once it succeeds. Only
+// the init container and the sidecar reach the store, the task container never sees its credentials
+func (t *taskAllocator) addArtifacts(podSpec *v1.PodSpec, task *db.Task, dagRunId int) error {
+	if len(task.Artifacts) == 0 && len(task.UpstreamArtifacts) == 0 {
+		return nil
+	}
+
+	if t.artifacts == nil || t.artifacts.Image == "" {
+		return errArtifactsNotConfigured
+	}
+
+	container := &podSpec.Containers[0]
+	if len(task.Artifacts) > 0 && len(container.Command) == 0 {
+		return fmt.Errorf("task %s must set a command or script to upload artifacts", task.Name)
+	}
+
+	env, envFrom, storeMount := t.artifactStoreAccess(podSpec)
+
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+		Name: artifactsVolumeName,
+		VolumeSource: v1.VolumeSource{
+			EmptyDir: &v1.EmptyDirVolumeSource{},
+		},
+	})
+	mount := v1.VolumeMount{
+		Name:      artifactsVolumeName,
+		MountPath: artifacts.MountPath,
+	}
+
+	command := []string{"/" + artifacts.BinaryName, "download", "-install", artifacts.BinDir}
+	for _, upstream := range task.UpstreamArtifacts {
+		dir := path.Join(artifacts.Dir, upstream.TaskName)
+		if upstream.MapIndex != nil {
+			dir = path.Join(dir, strconv.Itoa(*upstream.MapIndex))
+		}
+
+		key := artifacts.ArtifactKey(dagRunId, upstream.TaskName, upstream.MapIndex, upstream.Name)
+		command = append(command, "-artifact", key+"="+dir)
+	}
+
+	download := v1.Container{
+		Name:         v1alpha1.ReservedArtifactsContainerName,
+		Image:        t.artifacts.Image,
+		Command:      command,
+		Env:          env,
+		EnvFrom:      envFrom,
+		VolumeMounts: []v1.VolumeMount{mount},
+	}
+	if storeMount != nil {
+		// downloads only read the store
+		readOnly := *storeMount
+		readOnly.ReadOnly = true
+		download.VolumeMounts = append(download.VolumeMounts, readOnly)
+	}
+	podSpec.InitContainers = append(podSpec.InitContainers, download)
+
+	container.VolumeMounts = append(container.VolumeMounts, mount)
+	if len(task.Artifacts) == 0 {
+		return nil
+	}
+
+	wrapper := []string{path.Join(artifacts.BinDir, artifacts.BinaryName), "exec"}
+	upload := []string{"/" + artifacts.BinaryName, "upload"}
+	for _, artifact := range task.Artifacts {
+		key := artifacts.ArtifactKey(dagRunId, task.Name, task.MapIndex, artifact.Name)
+		wrapper = append(wrapper, "-artifact", key+"="+artifact.Path)
+		upload = append(upload, "-artifact", key)
+	}
+	container.Command = append(append(wrapper, "--"), container.Command...)
+
+	// a native sidecar runs next to the task container and is stopped once it exits
+	restartAlways := v1.ContainerRestartPolicyAlways
+	uploader := v1.Container{
+		Name:          v1alpha1.ReservedArtifactsUploadContainerName,
+		Image:         t.artifacts.Image,
+		Command:       upload,
+		RestartPolicy: &restartAlways,
+		Env:           env,
+		EnvFrom:       envFrom,
+		VolumeMounts:  []v1.VolumeMount{mount},
+	}
+	if storeMount != nil {
+		uploader.VolumeMounts = append(uploader.VolumeMounts, *storeMount)
+	}
+	podSpec.InitContainers = append(podSpec.InitContainers, uploader)
+
+	return nil
+}
+
+// artifactStoreAccess returns what a container needs to reach the artifact store, adding the
+// volume of a filesystem store to the pod
+func (t *taskAllocator) artifactStoreAccess(podSpec *v1.PodSpec) ([]v1.EnvVar, []v1.EnvFromSource, *v1.VolumeMount) {
+	env := []v1.EnvVar{
+		{Name: artifacts.EnvStore, Value: t.artifacts.Store.StoreType},
+	}
+	var envFrom []v1.EnvFromSource
+	var mount *v1.VolumeMount
+
+	switch t.artifacts.Store.StoreType {
+	case "filesystem":
+		env = append(env, v1.EnvVar{Name: artifacts.EnvDir, Value: t.artifacts.Store.FileSystem.BaseDir})
+
+		podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+			Name: artifactStoreVolumeName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: t.artifacts.ClaimName,
+				},
+			},
+		})
+		mount = &v1.VolumeMount{
+			Name:      artifactStoreVolumeName,
+			MountPath: t.artifacts.Store.FileSystem.BaseDir,
+		}
+	case "s3":
+		env = append(env,
+			v1.EnvVar{Name: artifacts.EnvBucket, Value: t.artifacts.Store.S3Configs.BucketName},
+			v1.EnvVar{Name: artifacts.EnvEndpoint, Value: t.artifacts.Store.S3Configs.Endpoint},
+		)
+	}
+
+	// the secret holds AWS variables, prefixed so they stay out of the task command
+	if t.artifacts.CredentialsSecret != "" {
+		envFrom = append(envFrom, v1.EnvFromSource{
+			Prefix: artifacts.EnvPrefix,
+			SecretRef: &v1.SecretEnvSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: t.artifacts.CredentialsSecret},
+			},
+		})
+	}
+
+	return env, envFrom, mount
+}
@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/config"
+	"kontroler-controller/internal/db"
+	"kontroler-controller/internal/queue"
+	"kontroler-controller/internal/webhook"
+)
+
+func TestCreatePodSpec_Artifacts(t *testing.T) {
+	artifactConfig := &ArtifactConfig{
+		Artifacts: config.Artifacts{Image: "kontroler:latest", CredentialsSecret: "s3-creds"},
+		Store: config.LogStore{
+			StoreType: "s3",
+			S3Configs: config.S3LogStoreConfig{BucketName: "logs"},
+		},
+	}
+	ta := NewTaskAllocator(nil, "id", artifactConfig).(*taskAllocator)
+
+	mapIndex := 1
+	task := &db.Task{
+		Name:      "train",
+		Image:     "python:3.12",
+		Command:   []string{"python", "train.py"},
+		Artifacts: []v1alpha1.ArtifactSpec{{Name: "model", Path: "/out/model"}},
+		UpstreamArtifacts: []db.TaskArtifact{
+			{TaskName: "prepare", Name: "dataset"},
+			{TaskName: "split", MapIndex: &mapIndex, Name: "shard"},
+		},
+	}
+
+	podSpec, err := ta.createPodSpec(task, 7, []v1.EnvVar{{Name: "env", Value: "prod"}}, nil)
+	require.NoError(t, err)
+
+	require.Len(t, podSpec.InitContainers, 2)
+	download := podSpec.InitContainers[0]
+	require.Equal(t, v1alpha1.ReservedArtifactsContainerName, download.Name)
+	require.Equal(t, "kontroler:latest", download.Image)
+	require.Equal(t, []string{
+		"/kontroler-artifacts", "download", "-install", "/kontroler/bin",
+		"-artifact", "7/artifacts/prepare/dataset.tar.gz=/kontroler/artifacts/prepare",
+		"-artifact", "7/artifacts/split/1/shard.tar.gz=/kontroler/artifacts/split/1",
+	}, download.Command)
+	require.Equal(t, "s3-creds", download.EnvFrom[0].SecretRef.Name)
+	require.Equal(t, "KONTROLER_ARTIFACT_", download.EnvFrom[0].Prefix)
+
+	uploader := podSpec.InitContainers[1]
+	require.Equal(t, v1alpha1.ReservedArtifactsUploadContainerName, uploader.Name)
+	require.Equal(t, v1.ContainerRestartPolicyAlways, *uploader.RestartPolicy)
+	require.Equal(t, []string{
+		"/kontroler-artifacts", "upload",
+		"-artifact", "7/artifacts/train/model.tar.gz",
+	}, uploader.Command)
+	require.Equal(t, download.Env, uploader.Env)
+	require.Equal(t, "s3-creds", uploader.EnvFrom[0].SecretRef.Name)
+
+	// the task container only stages its artifacts, it never sees the store
+	container := podSpec.Containers[0]
+	require.Equal(t, []string{
+		"/kontroler/bin/kontroler-artifacts", "exec",
+		"-artifact", "7/artifacts/train/model.tar.gz=/out/model",
+		"--", "python", "train.py",
+	}, container.Command)
+	require.Equal(t, []v1.EnvVar{{Name: "env", Value: "prod"}}, container.Env)
+	require.Empty(t, container.EnvFrom)
+	require.Equal(t, []v1.VolumeMount{{Name: artifactsVolumeName, MountPath: "/kontroler"}}, container.VolumeMounts)
+}
+
+func TestCreatePodSpec_ArtifactsFileSystemStore(t *testing.T) {
+	artifactConfig := &ArtifactConfig{
+		Artifacts: config.Artifacts{Image: "kontroler:latest", ClaimName: "logs"},
+		Store: config.LogStore{
+			StoreType:  "filesystem",
+			FileSystem: config.FileSystemLogStoreConfig{BaseDir: "/logs"},
+		},
+	}
+	ta := NewTaskAllocator(nil, "id", artifactConfig).(*taskAllocator)
+
+	podSpec, err := ta.createPodSpec(&db.Task{
+		Name:              "train",
+		Image:             "python:3.12",
+		Command:           []string{"python", "train.py"},
+		Artifacts:         []v1alpha1.ArtifactSpec{{Name: "model", Path: "/out/model"}},
+		UpstreamArtifacts: []db.TaskArtifact{{TaskName: "prepare", Name: "dataset"}},
+	}, 7, nil, nil)
+	require.NoError(t, err)
+
+	store := v1.VolumeMount{Name: artifactStoreVolumeName, MountPath: "/logs"}
+	readOnly := store
+	readOnly.ReadOnly = true
+
+	require.Contains(t, podSpec.InitContainers[0].VolumeMounts, readOnly)
+	require.Contains(t, podSpec.InitContainers[1].VolumeMounts, store)
+	for _, mount := range podSpec.Containers[0].VolumeMounts {
+		require.NotEqual(t, artifactStoreVolumeName, mount.Name)
+	}
+}
+
+func TestCreatePodSpec_ArtifactsNotConfigured(t *testing.T) {
+	ta := NewTaskAllocator(nil, "id", nil).(*taskAllocator)
+
+	_, err := ta.createPodSpec(&db.Task{
+		Name:              "report",
+		Image:             "alpine",
+		Command:           []string{"cat"},
+		UpstreamArtifacts: []db.TaskArtifact{{TaskName: "prepare", Name: "dataset"}},
+	}, 7, nil, nil)
+	require.ErrorIs(t, err, errArtifactsNotConfigured)
+
+	podSpec, err := ta.createPodSpec(&db.Task{Name: "report", Image: "alpine", Command: []string{"cat"}}, 7, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, podSpec.InitContainers)
+}
+
+// artifactTaskDB hands out a task that uses artifacts
+type artifactTaskDB struct {
+	sensorDB
+}
+
+func (f *artifactTaskDB) GetTaskForRun(ctx context.Context, runId, taskId, taskRunId int) (db.Task, string, string, error) {
+	return db.Task{
+		Id:        taskId,
+		Name:      "train",
+		Image:     "python:3.12",
+		Command:   []string{"python", "train.py"},
+		Artifacts: []v1alpha1.ArtifactSpec{{Name: "model", Path: "/out/model"}},
+	}, "default", "", nil
+}
+
+func TestProcessClaim_FailsTaskWithoutArtifactsConfig(t *testing.T) {
+	fdb := &artifactTaskDB{}
+	w := NewWorker(queue.NewMemoryQueue(context.Background()), nil, make(chan webhook.WebhookPayload, 1), fdb, nil, NewTaskAllocator(nil, "id", nil), nil, 10*time.Millisecond).(*worker)
+
+	// the pod can never be built, so the task run fails rather than being claimed again
+	w.processClaim(context.Background(), db.TaskClaim{TaskRunID: 1, TaskID: 2, RunID: 3})
+	require.Equal(t, int32(1), atomic.LoadInt32(&fdb.failed))
+}
@@ -0,0 +1,42 @@
+package workers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"kontroler-controller/internal/db"
+)
+
+// cacheInputs are everything that decides the result of a run of a cached task
+type cacheInputs struct {
+	Hash            string          `json:"hash"`
+	Image           string          `json:"image"`
+	Command         []string        `json:"command"`
+	Args            []string        `json:"args"`
+	Script          string          `json:"script"`
+	Parameters      []db.Parameter  `json:"parameters"`
+	MapItem         string          `json:"mapItem"`
+	UpstreamOutputs []db.TaskOutput `json:"upstreamOutputs"`
+}
+
+// cacheKey fingerprints the inputs of a task run, once its templates have been expanded. Secret
+// and ConfigMap parameters are fingerprinted by their name and key as their content is not read here
+func cacheKey(task *db.Task) (string, error) {
+	data, err := json.Marshal(cacheInputs{
+		Hash:            task.Hash,
+		Image:           task.Image,
+		Command:         task.Command,
+		Args:            task.Args,
+		Script:          task.Script,
+		Parameters:      task.Parameters,
+		MapItem:         task.MapItem,
+		UpstreamOutputs: task.UpstreamOutputs,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,44 @@
+package workers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"kontroler-controller/internal/db"
+)
+
+func TestCacheKey(t *testing.T) {
+	task := &db.Task{
+		Hash:            "abc",
+		Image:           "alpine:latest",
+		Command:         []string{"sh", "-c"},
+		Args:            []string{"extract --date 2026-03-01T06:00:00Z"},
+		Parameters:      []db.Parameter{{Name: "region", Value: "eu-west-1"}, {Name: "creds", IsSecret: true, Value: "creds", Key: "token"}},
+		UpstreamOutputs: []db.TaskOutput{{TaskName: "prepare", Name: "path", Value: "/data"}},
+	}
+
+	key, err := cacheKey(task)
+	require.NoError(t, err)
+	require.Len(t, key, 64)
+
+	// the run id and logical time only matter once they are expanded into the task
+	same := *task
+	same.RunParameters = map[string]string{"region": "eu-west-1"}
+	sameKey, err := cacheKey(&same)
+	require.NoError(t, err)
+	require.Equal(t, key, sameKey)
+
+	for name, change := range map[string]func(*db.Task){
+		"definition": func(t *db.Task) { t.Hash = "def" },
+		"args":       func(t *db.Task) { t.Args = []string{"extract --date 2026-03-02T06:00:00Z"} },
+		"parameter":  func(t *db.Task) { t.Parameters = []db.Parameter{{Name: "region", Value: "us-east-1"}} },
+		"map item":   func(t *db.Task) { t.MapItem = "b" },
+		"upstream":   func(t *db.Task) { t.UpstreamOutputs = nil },
+	} {
+		changed := *task
+		change(&changed)
+		changedKey, err := cacheKey(&changed)
+		require.NoError(t, err)
+		require.NotEqual(t, key, changedKey, name)
+	}
+}
@@ -0,0 +1,83 @@
+package workers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"kontroler-controller/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// parseTaskOutputs reads the outputs a task published in its termination message.
+// The message is either a JSON object of string values or KEY=value lines, and
+// only the outputs declared by the task are kept.
+func parseTaskOutputs(message string, declared []string) map[string]string {
+	wanted := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		wanted[name] = true
+	}
+
+	outputs := map[string]string{}
+
+	trimmed := strings.TrimSpace(message)
+	if strings.HasPrefix(trimmed, "{") {
+		values := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(trimmed), &values); err == nil {
+			for name, value := range values {
+				if !wanted[name] {
+					continue
+				}
+
+				if str, ok := value.(string); ok {
+					outputs[name] = str
+					continue
+				}
+
+				// keep non-string values in their JSON form
+				raw, err := json.Marshal(value)
+				if err == nil {
+					outputs[name] = string(raw)
+				}
+			}
+
+			return outputs
+		}
+	}
+
+	for _, line := range strings.Split(trimmed, "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		if wanted[name] {
+			outputs[name] = strings.TrimRight(value, "\r")
+		}
+	}
+
+	return outputs
+}
+
+// outputEnvName builds the env var name an upstream output is exposed as,
+// e.g. task "fetch-data" with output "rowCount" becomes OUTPUT_FETCH_DATA_ROWCOUNT
+func outputEnvName(taskName, output string) string {
+	return v1alpha1.OutputEnvName(taskName, output)
+}
+
+// taskContainerStatus returns the status of the main task container, ignoring any
+// other container that may be running in the pod
+func taskContainerStatus(pod *v1.Pod) *v1.ContainerStatus {
+	if len(pod.Spec.Containers) == 0 {
+		return nil
+	}
+
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == pod.Spec.Containers[0].Name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+
+	return nil
+}
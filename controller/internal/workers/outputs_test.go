@@ -0,0 +1,78 @@
+package workers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"kontroler-controller/internal/db"
+)
+
+func TestParseTaskOutputs_KeyValueLines(t *testing.T) {
+	outputs := parseTaskOutputs("rowCount=42\nfileName=data=1.csv\nignored=true\nnot a pair\n", []string{"rowCount", "fileName"})
+	require.Equal(t, map[string]string{
+		"rowCount": "42",
+		"fileName": "data=1.csv",
+	}, outputs)
+}
+
+func TestParseTaskOutputs_JSON(t *testing.T) {
+	outputs := parseTaskOutputs(`{"rowCount": 42, "tag": "v1.2.3", "other": "x"}`, []string{"rowCount", "tag"})
+	require.Equal(t, map[string]string{
+		"rowCount": "42",
+		"tag":      "v1.2.3",
+	}, outputs)
+}
+
+func TestParseTaskOutputs_EmptyMessage(t *testing.T) {
+	require.Empty(t, parseTaskOutputs("", []string{"rowCount"}))
+}
+
+func TestCreateEnvs_UpstreamOutputs(t *testing.T) {
+	ta := NewTaskAllocator(nil, "id", nil)
+
+	envs := ta.CreateEnvs(&db.Task{
+		Parameters: []db.Parameter{{Name: "env", Value: "prod"}},
+		UpstreamOutputs: []db.TaskOutput{
+			{TaskName: "fetch-data", Name: "rowCount", Value: "42"},
+		},
+	})
+
+	require.Equal(t, []v1.EnvVar{
+		{Name: "env", Value: "prod"},
+		{Name: "OUTPUT_FETCH_DATA_ROWCOUNT", Value: "42"},
+	}, *envs)
+}
+
+func TestCreateEnvs_SecretAndConfigMapKeys(t *testing.T) {
+	ta := NewTaskAllocator(nil, "id", nil)
+
+	envs := ta.CreateEnvs(&db.Task{
+		Parameters: []db.Parameter{
+			{Name: "token", IsSecret: true, Key: "api-token", Value: "creds"},
+			{Name: "legacy", IsSecret: true, Value: "old-creds"},
+			{Name: "region", IsConfigMap: true, Key: "region", Value: "settings"},
+		},
+	})
+
+	require.Equal(t, []v1.EnvVar{
+		{Name: "token", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "creds"}, Key: "api-token"}}},
+		{Name: "legacy", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "old-creds"}, Key: "secret"}}},
+		{Name: "region", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "settings"}, Key: "region"}}},
+	}, *envs)
+}
+
+func TestTaskContainerStatus_MatchesMainContainer(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "task"}, {Name: "proxy"}},
+		},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{Name: "proxy"}, {Name: "task"}},
+		},
+	}
+
+	status := taskContainerStatus(pod)
+	require.NotNil(t, status)
+	require.Equal(t, "task", status.Name)
+}
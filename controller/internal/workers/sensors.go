@@ -0,0 +1,104 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kontroler-controller/internal/db"
+	"kontroler-controller/internal/metrics"
+	"kontroler-controller/internal/sensors"
+
+	log "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// runSensor checks the condition of a sensor task once. When it holds the outcome of the task run is recorded as
+// a finished pod would, otherwise the task run is queued for its next check, failing once the sensor times out.
+// claimCtx ends when the claim is lost, in which case the outcome is left to the worker that claims the task run next
+func (w *worker) runSensor(ctx, claimCtx context.Context, task *db.Task, namespace string, c db.TaskClaim) {
+	started := time.Now()
+
+	sensor, err := sensors.New(task.Sensor, namespace, w.sensorClients)
+	if err != nil {
+		log.Log.Info("sensor failed", "taskRunId", c.TaskRunID, "taskName", task.Name, "reason", err.Error())
+		w.failTaskRun(ctx, task, namespace, c, started)
+		return
+	}
+
+	ok, pokeErr := sensors.Check(claimCtx, sensor)
+	if claimCtx.Err() != nil {
+		log.Log.Info("sensor stopped before it finished", "taskRunId", c.TaskRunID, "taskName", task.Name)
+		return
+	}
+
+	if !ok {
+		w.deferSensor(ctx, task, namespace, c, pokeErr)
+		return
+	}
+
+	// only a task run still claimed by this worker is finished, the controller may have timed it out meanwhile
+	if err := w.dbManager.FinalizeClaimToRunning(ctx, c.TaskRunID, w.id, ""); err != nil {
+		log.Log.Error(err, "failed to finalize claim of sensor", "taskRunId", c.TaskRunID)
+		return
+	}
+
+	duration := time.Since(started).Seconds()
+	dagName, taskName, metricsNamespace := w.getTaskRunMetricsInfo(ctx, c.TaskRunID)
+
+	log.Log.Info("sensor succeeded", "taskRunId", c.TaskRunID, "taskName", task.Name)
+
+	tasks, err := w.dbManager.MarkSuccessAndGetNextTasks(ctx, c.TaskRunID)
+	if err != nil {
+		log.Log.Error(err, "failed to mark sensor as succeeded", "taskRunId", c.TaskRunID)
+		return
+	}
+
+	metrics.RecordTaskOutcome(metricsNamespace, dagName, taskName, "success")
+	metrics.RecordTaskExecutionDuration(metricsNamespace, dagName, taskName, "success", duration)
+
+	webhook, err := w.dbManager.GetWebhookDetails(ctx, c.RunID)
+	if err != nil {
+		log.Log.Error(err, errMsgWebhookDetails, "runId", c.RunID)
+	} else if webhook.URL != "" {
+		go w.webhookNotifier.NotifyTaskRun(task.Name, "success", c.RunID, c.TaskRunID, webhook.URL, webhook.VerifySSL)
+	}
+
+	if len(tasks) > 0 {
+		w.allocateNextTasks(ctx, c.RunID, tasks)
+		return
+	}
+
+	complete, err := w.checkIfDagRunIsComplete(ctx, c.RunID)
+	if err != nil || !complete {
+		return
+	}
+
+	w.deleteTaskWorkspace(ctx, task, namespace, c.RunID)
+}
+
+// deferSensor releases the claim of a sensor whose condition does not hold yet so it is checked again after its
+// interval, or fails it once its timeout has passed
+func (w *worker) deferSensor(ctx context.Context, task *db.Task, namespace string, c db.TaskClaim, pokeErr error) {
+	deferred, err := w.dbManager.DeferSensor(ctx, c.TaskRunID, w.id, task.Sensor.GetInterval(), task.Sensor.Timeout.Duration)
+	if errors.Is(err, db.ErrTaskRunNotClaimed) {
+		log.Log.Info("sensor no longer claimed by this worker", "taskRunId", c.TaskRunID, "taskName", task.Name)
+		return
+	}
+	if err != nil {
+		// the claim expires and the sensor is checked again by whichever worker claims it next
+		log.Log.Error(err, "failed to defer sensor", "taskRunId", c.TaskRunID)
+		return
+	}
+
+	if deferred {
+		log.Log.Info("sensor not ready, checking again later", "taskRunId", c.TaskRunID, "taskName", task.Name, "interval", task.Sensor.GetInterval().String())
+		return
+	}
+
+	reason := "condition did not hold"
+	if pokeErr != nil {
+		reason = pokeErr.Error()
+	}
+	log.Log.Info("sensor timed out", "taskRunId", c.TaskRunID, "taskName", task.Name, "reason", reason)
+	w.failTaskRun(ctx, task, namespace, c, time.Now())
+}
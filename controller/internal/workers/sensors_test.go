@@ -0,0 +1,105 @@
+package workers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/require"
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+	"kontroler-controller/internal/queue"
+	"kontroler-controller/internal/sensors"
+	"kontroler-controller/internal/webhook"
+)
+
+// sensorDB records the outcome a sensor task run is given
+type sensorDB struct {
+	fakeDB
+	finalizeErr error
+	// whether the sensor is still before its timeout when it is deferred
+	beforeTimeout bool
+	succeeded     int32
+	failed        int32
+	deferred      int32
+}
+
+func (f *sensorDB) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
+	return f.finalizeErr
+}
+func (f *sensorDB) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	if !f.beforeTimeout {
+		return false, nil
+	}
+	atomic.AddInt32(&f.deferred, 1)
+	return true, nil
+}
+func (f *sensorDB) MarkSuccessAndGetNextTasks(ctx context.Context, taskRunId int) ([]db.Task, error) {
+	atomic.AddInt32(&f.succeeded, 1)
+	return nil, nil
+}
+func (f *sensorDB) MarkTaskAsFailed(ctx context.Context, taskRunId int) error {
+	atomic.AddInt32(&f.failed, 1)
+	return nil
+}
+
+func newSensorTestWorker(t *testing.T, fdb db.DBDAGManager, clients *sensors.Clients) *worker {
+	t.Helper()
+
+	return NewWorker(queue.NewMemoryQueue(context.Background()), nil, make(chan webhook.WebhookPayload, 1), fdb, nil, nil, clients, 10*time.Millisecond).(*worker)
+}
+
+func TestRunSensor(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	task := &db.Task{
+		Name: "wait-for-api",
+		Sensor: &v1alpha1.SensorSpec{
+			Interval: &metav1.Duration{Duration: 5 * time.Millisecond},
+			Timeout:  metav1.Duration{Duration: 50 * time.Millisecond},
+			HTTP:     &v1alpha1.HTTPSensor{URL: server.URL},
+		},
+	}
+	claim := db.TaskClaim{TaskRunID: 1, RunID: 1}
+	ctx := context.Background()
+
+	// an endpoint that is not ready yet queues the task run for its next check
+	fdb := &sensorDB{beforeTimeout: true}
+	newSensorTestWorker(t, fdb, &sensors.Clients{HTTP: server.Client()}).runSensor(ctx, ctx, task, "default", claim)
+	require.Equal(t, int32(1), atomic.LoadInt32(&fdb.deferred))
+	require.Equal(t, int32(0), atomic.LoadInt32(&fdb.failed)+atomic.LoadInt32(&fdb.succeeded))
+
+	// once the timeout has passed the sensor fails the task run
+	fdb = &sensorDB{}
+	newSensorTestWorker(t, fdb, &sensors.Clients{HTTP: server.Client()}).runSensor(ctx, ctx, task, "default", claim)
+	require.Equal(t, int32(1), atomic.LoadInt32(&fdb.failed))
+	require.Equal(t, int32(0), atomic.LoadInt32(&fdb.succeeded))
+
+	status.Store(http.StatusOK)
+	fdb = &sensorDB{}
+	newSensorTestWorker(t, fdb, &sensors.Clients{HTTP: server.Client()}).runSensor(ctx, ctx, task, "default", claim)
+	require.Equal(t, int32(0), atomic.LoadInt32(&fdb.failed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&fdb.succeeded))
+
+	// a worker without sensor clients fails the task run rather than leaving it waiting
+	fdb = &sensorDB{}
+	newSensorTestWorker(t, fdb, nil).runSensor(ctx, ctx, task, "default", claim)
+	require.Equal(t, int32(1), atomic.LoadInt32(&fdb.failed))
+
+	// a lost claim leaves the outcome to the worker that claims the task run next
+	fdb = &sensorDB{}
+	claimCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	newSensorTestWorker(t, fdb, &sensors.Clients{HTTP: server.Client()}).runSensor(ctx, claimCtx, task, "default", claim)
+	require.Equal(t, int32(0), atomic.LoadInt32(&fdb.failed)+atomic.LoadInt32(&fdb.succeeded))
+}
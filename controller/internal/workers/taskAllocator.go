@@ -2,10 +2,12 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"kontroler-controller/api/v1alpha1"
 	"kontroler-controller/internal/db"
@@ -28,6 +30,11 @@ const (
 	annotationDagRunID  = "kontroler/dagRun-id"
 	annotationTaskID    = "kontroler/task-id"
 	annotationClaimedBy = "kontroler/claimed-by"
+	annotationOutputs   = "kontroler/outputs"
+
+	envMapItem       = "MAP_ITEM"
+	envMapIndex      = "MAP_INDEX"
+	envScheduledTime = "SCHEDULED_TIME"
 
 	finalizerLogCollection = "kontroler/logcollection"
 	initScriptCommand      = `printf %s > /script/my-script.sh && echo "Script created" || echo "Failed to write script" >&2 &&
@@ -41,6 +48,10 @@ var (
 	scriptExecCommand = []string{"sh", "-c", "/bin/sh /script/my-script.sh"}
 )
 
+// ErrInvalidPodSpec is returned by the allocator when the pod of a task cannot be built. The pod is built
+// the same way on every attempt, so the task cannot start until its DAG or the configuration changes
+var ErrInvalidPodSpec = errors.New("invalid pod spec")
+
 type TaskAllocator interface {
 	AllocateTask(context.Context, *db.Task, int, int, string, string) (types.UID, error)
 	AllocateTaskWithEnv(context.Context, *db.Task, int, int, string, []v1.EnvVar, *v1.ResourceRequirements, string) (types.UID, error)
@@ -51,9 +62,11 @@ type taskAllocator struct {
 	clientSet *kubernetes.Clientset
 	id        string
 	podPool   *sync.Pool
+	artifacts *ArtifactConfig
 }
 
-func NewTaskAllocator(clientSet *kubernetes.Clientset, id string) TaskAllocator {
+// NewTaskAllocator creates pods for tasks. Tasks using artifacts can only be allocated when artifacts is set
+func NewTaskAllocator(clientSet *kubernetes.Clientset, id string, artifacts *ArtifactConfig) TaskAllocator {
 	pool := &sync.Pool{
 		New: func() any {
 			return &v1.Pod{
@@ -73,6 +86,7 @@ func NewTaskAllocator(clientSet *kubernetes.Clientset, id string) TaskAllocator
 		clientSet: clientSet,
 		id:        id,
 		podPool:   pool,
+		artifacts: artifacts,
 	}
 }
 
@@ -90,7 +104,10 @@ func (t *taskAllocator) AllocateTaskWithEnv(ctx context.Context, task *db.Task,
 }
 
 func (t *taskAllocator) allocatePod(ctx context.Context, task *db.Task, dagRunId, taskRunId int, namespace string, envs []v1.EnvVar, resources *v1.ResourceRequirements, claimedBy string) (types.UID, error) {
-	podSpec := t.createPodSpec(task, envs, resources)
+	podSpec, err := t.createPodSpec(task, dagRunId, envs, resources)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidPodSpec, err)
+	}
 
 	// using pod pool to reduce struct re-creation
 	pod := t.podPool.Get().(*v1.Pod)
@@ -108,6 +125,13 @@ func (t *taskAllocator) allocatePod(ctx context.Context, task *db.Task, dagRunId
 		delete(pod.ObjectMeta.Annotations, annotationClaimedBy)
 	}
 
+	// outputs are read back from the termination message once the pod succeeds
+	if len(task.Outputs) > 0 {
+		pod.ObjectMeta.Annotations[annotationOutputs] = strings.Join(task.Outputs, ",")
+	} else {
+		delete(pod.ObjectMeta.Annotations, annotationOutputs)
+	}
+
 	// set podspec
 	pod.Spec = *podSpec
 
@@ -130,7 +154,7 @@ func (t *taskAllocator) allocatePod(ctx context.Context, task *db.Task, dagRunId
 	return "", fmt.Errorf("failed to create pod due to naming collisions")
 }
 
-func (t *taskAllocator) createPodSpec(task *db.Task, envs []v1.EnvVar, resources *v1.ResourceRequirements) *v1.PodSpec {
+func (t *taskAllocator) createPodSpec(task *db.Task, dagRunId int, envs []v1.EnvVar, resources *v1.ResourceRequirements) (*v1.PodSpec, error) {
 	podSpec := v1.PodSpec{
 		RestartPolicy: v1.RestartPolicyNever,
 		Volumes:       []v1.Volume{},
@@ -149,7 +173,7 @@ func (t *taskAllocator) createPodSpec(task *db.Task, envs []v1.EnvVar, resources
 		// Needs to have bash installed. ubuntu + UBI8 both *should* work
 		podSpec.InitContainers = []v1.Container{
 			{
-				Name:  "script-copier",
+				Name:  v1alpha1.ReservedScriptContainerName,
 				Image: scriptInjectorImage,
 				Command: []string{
 					"sh", "-c", fmt.Sprintf(initScriptCommand, shellescape.Quote(task.Script)),
@@ -188,6 +212,11 @@ func (t *taskAllocator) createPodSpec(task *db.Task, envs []v1.EnvVar, resources
 		t.addDefaultContainer(&podSpec, task, envs)
 	}
 
+	// artifacts are injected before the template so its init containers can read them
+	if err := t.addArtifacts(&podSpec, task, dagRunId); err != nil {
+		return nil, err
+	}
+
 	// Apply PodTemplate if provided
 	if task.PodTemplate != nil {
 		t.applyPodTemplate(&podSpec, task)
@@ -198,7 +227,7 @@ func (t *taskAllocator) createPodSpec(task *db.Task, envs []v1.EnvVar, resources
 		podSpec.Containers[0].Resources = *resources
 	}
 
-	return &podSpec
+	return &podSpec, nil
 }
 
 func (t *taskAllocator) addScriptVolume(podSpec *v1.PodSpec, task *db.Task) {
@@ -229,33 +258,66 @@ func (t *taskAllocator) addDefaultContainer(podSpec *v1.PodSpec, task *db.Task,
 // Helper function to apply PodTemplate attributes to the pod spec
 func (t *taskAllocator) applyPodTemplate(podSpec *v1.PodSpec, task *db.Task) {
 	// Convert CRD-safe PodTemplateSpec to k8s types
-	volumes, mounts, imagePullSecrets, securityContext, tolerations, affinity, resources := task.PodTemplate.ToK8sParts()
+	parts := task.PodTemplate.ToK8sParts()
 
-	podSpec.Volumes = append(podSpec.Volumes, volumes...)
-	podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, imagePullSecrets...)
-	podSpec.SecurityContext = securityContext
+	podSpec.Volumes = append(podSpec.Volumes, parts.Volumes...)
+	podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, parts.ImagePullSecrets...)
+	podSpec.SecurityContext = parts.SecurityContext
 	podSpec.NodeSelector = task.PodTemplate.NodeSelector
-	podSpec.Tolerations = append(podSpec.Tolerations, tolerations...)
-	podSpec.Affinity = affinity
+	podSpec.Tolerations = append(podSpec.Tolerations, parts.Tolerations...)
+	podSpec.Affinity = parts.Affinity
 	podSpec.ServiceAccountName = task.PodTemplate.ServiceAccountName
+	podSpec.PriorityClassName = task.PodTemplate.PriorityClassName
 	podSpec.AutomountServiceAccountToken = task.PodTemplate.AutomountServiceAccountToken
 	podSpec.ActiveDeadlineSeconds = task.PodTemplate.ActiveDeadlineSeconds
 
 	if podSpec.Containers[0].VolumeMounts == nil {
-		podSpec.Containers[0].VolumeMounts = mounts
+		podSpec.Containers[0].VolumeMounts = parts.VolumeMounts
 	} else {
-		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, mounts...)
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, parts.VolumeMounts...)
 	}
 
-	if resources != nil {
-		podSpec.Containers[0].Resources = *resources
+	if parts.Resources != nil {
+		podSpec.Containers[0].Resources = *parts.Resources
 	}
+
+	podSpec.Containers[0].SecurityContext = task.PodTemplate.ContainerSecurityContext.ToK8s()
+
+	// parameters and the variables kontroler sets take precedence over the template
+	for _, e := range parts.Env {
+		if !containsEnv(podSpec.Containers[0].Env, e.Name) {
+			podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, e)
+		}
+	}
+	podSpec.Containers[0].EnvFrom = append(podSpec.Containers[0].EnvFrom, parts.EnvFrom...)
+
+	// extra init containers and sidecars run after the script copier and artifacts download
+	podSpec.InitContainers = append(podSpec.InitContainers, task.PodTemplate.InitContainersToK8s()...)
 }
 
 func (t *taskAllocator) CreateEnvs(task *db.Task) *[]v1.EnvVar {
 	envs := []v1.EnvVar{}
 	for _, param := range task.Parameters {
-		if param.IsSecret {
+		switch {
+		case param.IsConfigMap:
+			envs = append(envs, v1.EnvVar{
+				Name: param.Name,
+				ValueFrom: &v1.EnvVarSource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: param.Value,
+						},
+						Key: param.Key,
+					},
+				},
+			})
+		case param.IsSecret:
+			// parameters stored before keys could be chosen read the "secret" key
+			key := param.Key
+			if key == "" {
+				key = v1alpha1.DefaultSecretKey
+			}
+
 			envs = append(envs, v1.EnvVar{
 				Name: param.Name,
 				ValueFrom: &v1.EnvVarSource{
@@ -263,12 +325,11 @@ func (t *taskAllocator) CreateEnvs(task *db.Task) *[]v1.EnvVar {
 						LocalObjectReference: v1.LocalObjectReference{
 							Name: param.Value,
 						},
-						// Current Version will also look into Key "secret"
-						Key: "secret",
+						Key: key,
 					},
 				},
 			})
-		} else {
+		default:
 			envs = append(envs, v1.EnvVar{
 				Name:  param.Name,
 				Value: param.Value,
@@ -276,6 +337,24 @@ func (t *taskAllocator) CreateEnvs(task *db.Task) *[]v1.EnvVar {
 		}
 	}
 
+	for _, output := range task.UpstreamOutputs {
+		envs = append(envs, v1.EnvVar{
+			Name:  outputEnvName(output.TaskName, output.Name),
+			Value: output.Value,
+		})
+	}
+
+	if task.MapIndex != nil {
+		envs = append(envs,
+			v1.EnvVar{Name: envMapItem, Value: task.MapItem},
+			v1.EnvVar{Name: envMapIndex, Value: strconv.Itoa(*task.MapIndex)},
+		)
+	}
+
+	if task.ScheduledTime != nil {
+		envs = append(envs, v1.EnvVar{Name: envScheduledTime, Value: task.ScheduledTime.UTC().Format(time.RFC3339)})
+	}
+
 	return &envs
 }
 
@@ -288,6 +367,15 @@ func containsVolumeMount(slice []v1alpha1.VolumeMount, item v1.VolumeMount) bool
 	return false
 }
 
+func containsEnv(envs []v1.EnvVar, name string) bool {
+	for _, e := range envs {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func containsVolume(slice []v1alpha1.Volume, item v1.Volume) bool {
 	for _, v := range slice {
 		if v.Name == item.Name {
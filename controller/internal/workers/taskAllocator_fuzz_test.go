@@ -26,7 +26,7 @@ func FuzzCreateEnvs(f *testing.F) {
 		}
 
 		// Create a taskAllocator instance
-		ta := workers.NewTaskAllocator(nil, "random")
+		ta := workers.NewTaskAllocator(nil, "random", nil)
 
 		// Run CreateEnvs function
 		envs := ta.CreateEnvs(&task)
@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"fmt"
+
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+	"kontroler-controller/pkg/templating"
+)
+
+// expandTemplates replaces the {{ params.<name> }}, {{ run.id }}, {{ run.logicalTime }} and
+// {{ task.name }} references in the image, command, args and script of a task, and in what its sensor waits for
+func expandTemplates(task *db.Task, runId int) error {
+	values := &templating.Values{
+		Params:      task.RunParameters,
+		RunID:       runId,
+		LogicalTime: task.LogicalTime,
+		TaskName:    task.Name,
+	}
+
+	var err error
+	if task.Image, err = templating.Expand(task.Image, values); err != nil {
+		return fmt.Errorf("image: %w", err)
+	}
+
+	if task.Script, err = templating.Expand(task.Script, values); err != nil {
+		return fmt.Errorf("script: %w", err)
+	}
+
+	for i := range task.Command {
+		if task.Command[i], err = templating.Expand(task.Command[i], values); err != nil {
+			return fmt.Errorf("command: %w", err)
+		}
+	}
+
+	for i := range task.Args {
+		if task.Args[i], err = templating.Expand(task.Args[i], values); err != nil {
+			return fmt.Errorf("args: %w", err)
+		}
+	}
+
+	if task.Sensor != nil {
+		if err := expandSensorTemplates(task.Sensor, values); err != nil {
+			return fmt.Errorf("sensor: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// expandSensorTemplates expands the URL, object key or resource name and namespace a sensor waits for
+func expandSensorTemplates(sensor *v1alpha1.SensorSpec, values *templating.Values) error {
+	var fields []*string
+	switch {
+	case sensor.HTTP != nil:
+		fields = []*string{&sensor.HTTP.URL}
+	case sensor.Object != nil:
+		fields = []*string{&sensor.Object.Key}
+	case sensor.Resource != nil:
+		fields = []*string{&sensor.Resource.Name, &sensor.Resource.Namespace}
+	}
+
+	for _, field := range fields {
+		expanded, err := templating.Expand(*field, values)
+		if err != nil {
+			return err
+		}
+		*field = expanded
+	}
+
+	return nil
+}
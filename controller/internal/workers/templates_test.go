@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"kontroler-controller/api/v1alpha1"
+	"kontroler-controller/internal/db"
+)
+
+func TestExpandTemplates(t *testing.T) {
+	task := &db.Task{
+		Name:          "extract",
+		Image:         "registry.local/extract:{{ params.version }}",
+		Command:       []string{"sh", "-c"},
+		Args:          []string{"extract --region {{ params.region }} --date {{ run.logicalTime }}"},
+		Script:        "echo {{ task.name }} {{ run.id }}",
+		LogicalTime:   time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC),
+		RunParameters: map[string]string{"region": "eu-west-1", "version": "1.4.0"},
+	}
+
+	require.NoError(t, expandTemplates(task, 7))
+	require.Equal(t, "registry.local/extract:1.4.0", task.Image)
+	require.Equal(t, []string{"sh", "-c"}, task.Command)
+	require.Equal(t, []string{"extract --region eu-west-1 --date 2026-03-01T06:00:00Z"}, task.Args)
+	require.Equal(t, "echo extract 7", task.Script)
+
+	task.Args = []string{"{{ params.missing }}"}
+	require.Error(t, expandTemplates(task, 7))
+}
+
+func TestExpandTemplates_Sensor(t *testing.T) {
+	task := &db.Task{
+		Name: "wait-for-export",
+		Sensor: &v1alpha1.SensorSpec{
+			Object: &v1alpha1.ObjectSensor{Key: "exports/{{ params.region }}/{{ run.logicalTime }}.csv"},
+		},
+		LogicalTime:   time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC),
+		RunParameters: map[string]string{"region": "eu-west-1"},
+	}
+
+	require.NoError(t, expandTemplates(task, 7))
+	require.Equal(t, "exports/eu-west-1/2026-03-01T06:00:00Z.csv", task.Sensor.Object.Key)
+}
@@ -28,6 +28,9 @@ func (f *fakeDBLease) GetID(ctx context.Context) (string, error)   { return "fak
 func (f *fakeDBLease) GetDAGsToStartAndUpdate(ctx context.Context, tm time.Time) ([]*db.DagInfo, error) {
 	return nil, nil
 }
+func (f *fakeDBLease) GetBackfillsToStartAndUpdate(ctx context.Context) ([]*db.DagInfo, error) {
+	return nil, nil
+}
 func (f *fakeDBLease) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, namespace string) error {
 	return nil
 }
@@ -35,6 +38,14 @@ func (f *fakeDBLease) CreateDAGRun(ctx context.Context, name string, dag *v1alph
 	return 0, nil
 }
 
+func (f *fakeDBLease) StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (db.DagRunAdmission, error) {
+	return db.DagRunAdmission{}, nil
+}
+
+func (f *fakeDBLease) StartQueuedDAGRuns(ctx context.Context) ([]db.QueuedDagRun, error) {
+	return nil, nil
+}
+
 // Many methods are unused by this test — provide simple stubs
 func (f *fakeDBLease) GetStartingTasks(ctx context.Context, dagName string, dagrun int) ([]db.Task, error) {
 	return nil, nil
@@ -46,6 +57,12 @@ func (f *fakeDBLease) IncrementAttempts(ctx context.Context, taskRunId int) erro
 func (f *fakeDBLease) MarkSuccessAndGetNextTasks(ctx context.Context, taskRunId int) ([]db.Task, error) {
 	return nil, nil
 }
+func (f *fakeDBLease) CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []db.Task, error) {
+	return false, nil, nil
+}
+func (f *fakeDBLease) InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error) {
+	return 0, nil
+}
 func (f *fakeDBLease) MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error {
 	return nil
 }
@@ -92,6 +109,12 @@ func (f *fakeDBLease) CheckIfAllTasksDone(ctx context.Context, dagRunID int) (bo
 func (f *fakeDBLease) MarkConnectingTasksAsSuspended(ctx context.Context, dagRunID, taskRunId int) ([]string, error) {
 	return nil, nil
 }
+func (f *fakeDBLease) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	return 0, 0, nil
+}
+func (f *fakeDBLease) GetReadyTasks(ctx context.Context, dagRunId int) ([]db.Task, error) {
+	return nil, nil
+}
 func (f *fakeDBLease) AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error {
 	return nil
 }
@@ -119,6 +142,10 @@ func (f *fakeDBLease) RenewLease(ctx context.Context, taskRunId int, workerId st
 	return nil
 }
 
+func (f *fakeDBLease) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	return true, nil
+}
+
 func (f *fakeDBLease) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
 	atomic.StoreInt32(&f.finalized, 1)
 	f.lastFinalPod = podUID
@@ -129,7 +156,7 @@ func (f *fakeDBLease) RecoverExpiredLeases(ctx context.Context) (int, error) { r
 func (f *fakeDBLease) AddPendingTaskRun(ctx context.Context, runId int, dagTaskId int) (int, error) {
 	return 0, nil
 }
-func (f *fakeDBLease) GetTaskForRun(ctx context.Context, runId int, dagTaskId int) (db.Task, string, string, error) {
+func (f *fakeDBLease) GetTaskForRun(ctx context.Context, runId int, dagTaskId int, taskRunId int) (db.Task, string, string, error) {
 	// return simple task
 	return db.Task{Id: dagTaskId, Name: "t", Image: "busybox", Command: []string{"/bin/sh"}, Args: []string{"-c", "echo hi"}}, "default", "", nil
 }
@@ -139,10 +166,70 @@ func (f *fakeDBLease) ClaimTaskByID(ctx context.Context, taskRunId int, workerId
 func (f *fakeDBLease) SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error {
 	return nil
 }
+func (f *fakeDBLease) SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error {
+	return nil
+}
+
 func (f *fakeDBLease) GetTaskRunStatus(ctx context.Context, taskRunId int) (string, error) {
 	return "", nil
 }
 
+func (f *fakeDBLease) StartSubDagRuns(ctx context.Context) ([]db.SubDagRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error {
+	return nil
+}
+
+func (f *fakeDBLease) GetFinishedSubDagRuns(ctx context.Context) ([]db.FinishedSubDagRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) TimeOutTaskRuns(ctx context.Context) ([]db.StoppedTaskRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) TimeOutDagRuns(ctx context.Context) ([]db.StoppedDagRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) GetDagRunSummary(ctx context.Context, runId int) (*db.DagRunSummary, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) GetDagStatus(ctx context.Context, dagName, namespace string) (*db.DagStatus, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error {
+	return nil
+}
+
+func (f *fakeDBLease) DeletePool(ctx context.Context, name, namespace string) error {
+	return nil
+}
+
+func (f *fakeDBLease) GetPoolUsage(ctx context.Context) ([]db.PoolUsage, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error) {
+	return dagRunName, true, nil
+}
+
+func (f *fakeDBLease) GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (f *fakeDBLease) ClaimFinishedDagRuns(ctx context.Context) ([]db.DownstreamDagRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDBLease) MarkDagRunCompletionHandled(ctx context.Context, runId int) error {
+	return nil
+}
+
 // fakeAllocator sleeps to simulate slow allocation and returns a pod UID
 type fakeAllocator struct {
 	delay time.Duration
@@ -167,7 +254,7 @@ func TestRenewLeaseCalledDuringSlowAllocation(t *testing.T) {
 	alloc := &fakeAllocator{delay: 700 * time.Millisecond} // longer than defaultLeaseTTL so renew should happen
 	q := queue.NewMemoryQueue(context.Background())
 	webhookChan := make(chan webhook.WebhookPayload, 1)
-	wIface := NewWorker(q, nil, webhookChan, fdb, nil, alloc, 10*time.Millisecond)
+	wIface := NewWorker(q, nil, webhookChan, fdb, nil, alloc, nil, 10*time.Millisecond)
 	w := wIface.(*worker)
 
 	// run processClaim directly with a fake claim
@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -31,12 +32,21 @@ func (f *fakeDB) GetID(ctx context.Context) (string, error)   { return "fake", n
 func (f *fakeDB) GetDAGsToStartAndUpdate(ctx context.Context, tm time.Time) ([]*db.DagInfo, error) {
 	return nil, nil
 }
+func (f *fakeDB) GetBackfillsToStartAndUpdate(ctx context.Context) ([]*db.DagInfo, error) {
+	return nil, nil
+}
 func (f *fakeDB) InsertDAG(ctx context.Context, dag *v1alpha1.DAG, namespace string) error {
 	return nil
 }
 func (f *fakeDB) CreateDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (int, error) {
 	return 0, nil
 }
+func (f *fakeDB) StartDAGRun(ctx context.Context, name string, dag *v1alpha1.DagRunSpec, parameters map[string]v1alpha1.ParameterSpec, pvcName *string) (db.DagRunAdmission, error) {
+	return db.DagRunAdmission{}, nil
+}
+func (f *fakeDB) StartQueuedDAGRuns(ctx context.Context) ([]db.QueuedDagRun, error) {
+	return nil, nil
+}
 func (f *fakeDB) GetStartingTasks(ctx context.Context, dagName string, dagrun int) ([]db.Task, error) {
 	return nil, nil
 }
@@ -47,6 +57,12 @@ func (f *fakeDB) IncrementAttempts(ctx context.Context, taskRunId int) error { r
 func (f *fakeDB) MarkSuccessAndGetNextTasks(ctx context.Context, taskRunId int) ([]db.Task, error) {
 	return nil, nil
 }
+func (f *fakeDB) CompleteTaskRunFromCache(ctx context.Context, taskRunId int, workerId string, cacheKey string) (bool, []db.Task, error) {
+	return false, nil, nil
+}
+func (f *fakeDB) InvalidateTaskCache(ctx context.Context, dagName, namespace string, taskNames []string) (int, error) {
+	return 0, nil
+}
 func (f *fakeDB) MarkDAGRunOutcome(ctx context.Context, dagRunId int, outcome string) error {
 	return nil
 }
@@ -96,6 +112,12 @@ func (f *fakeDB) CheckIfAllTasksDone(ctx context.Context, dagRunID int) (bool, e
 func (f *fakeDB) MarkConnectingTasksAsSuspended(ctx context.Context, dagRunID, taskRunId int) ([]string, error) {
 	return nil, nil
 }
+func (f *fakeDB) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	return 0, 0, nil
+}
+func (f *fakeDB) GetReadyTasks(ctx context.Context, dagRunId int) ([]db.Task, error) {
+	return nil, nil
+}
 func (f *fakeDB) AddPodDuration(ctx context.Context, taskRunId int, durationSec int64) error {
 	return nil
 }
@@ -120,6 +142,10 @@ func (f *fakeDB) RenewLease(ctx context.Context, taskRunId int, workerId string,
 	return nil
 }
 
+func (f *fakeDB) DeferSensor(ctx context.Context, taskRunId int, workerId string, interval, timeout time.Duration) (bool, error) {
+	return true, nil
+}
+
 func (f *fakeDB) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
 	return nil
 }
@@ -132,7 +158,7 @@ func (f *fakeDB) AddPendingTaskRun(ctx context.Context, runId int, dagTaskId int
 	return 0, nil
 }
 
-func (f *fakeDB) GetTaskForRun(ctx context.Context, runId int, dagTaskId int) (db.Task, string, string, error) {
+func (f *fakeDB) GetTaskForRun(ctx context.Context, runId int, dagTaskId int, taskRunId int) (db.Task, string, string, error) {
 	return db.Task{}, "default", "", nil
 }
 
@@ -142,14 +168,66 @@ func (f *fakeDB) ClaimTaskByID(ctx context.Context, taskRunId int, workerId stri
 
 func (f *fakeDB) SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error { return nil }
 
+func (f *fakeDB) SaveTaskOutputs(ctx context.Context, taskRunId int, outputs map[string]string) error {
+	return nil
+}
+
 func (f *fakeDB) GetTaskRunStatus(ctx context.Context, taskRunId int) (string, error) { return "", nil }
 
+func (f *fakeDB) StartSubDagRuns(ctx context.Context) ([]db.SubDagRun, error) { return nil, nil }
+
+func (f *fakeDB) LinkSubDagRun(ctx context.Context, runId, parentTaskRunId int) error { return nil }
+
+func (f *fakeDB) GetFinishedSubDagRuns(ctx context.Context) ([]db.FinishedSubDagRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) TimeOutTaskRuns(ctx context.Context) ([]db.StoppedTaskRun, error) { return nil, nil }
+
+func (f *fakeDB) TimeOutDagRuns(ctx context.Context) ([]db.StoppedDagRun, error) { return nil, nil }
+
+func (f *fakeDB) GetDagRunSummary(ctx context.Context, runId int) (*db.DagRunSummary, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) GetDagStatus(ctx context.Context, dagName, namespace string) (*db.DagStatus, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) UpsertPool(ctx context.Context, pool *v1alpha1.Pool, namespace string) error {
+	return nil
+}
+
+func (f *fakeDB) DeletePool(ctx context.Context, name, namespace string) error {
+	return nil
+}
+
+func (f *fakeDB) GetPoolUsage(ctx context.Context) ([]db.PoolUsage, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) RecordTriggerEvent(ctx context.Context, namespace, triggerName, eventId, dagRunName string) (string, bool, error) {
+	return dagRunName, true, nil
+}
+
+func (f *fakeDB) GetTriggerEvent(ctx context.Context, namespace, triggerName, eventId string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (f *fakeDB) ClaimFinishedDagRuns(ctx context.Context) ([]db.DownstreamDagRun, error) {
+	return nil, nil
+}
+
+func (f *fakeDB) MarkDagRunCompletionHandled(ctx context.Context, runId int) error {
+	return nil
+}
+
 func TestWorkerProcessesRunningPodAndWritesDB(t *testing.T) {
 	q := queue.NewMemoryQueue(context.Background())
 	fdb := &fakeDB{}
 	webhookChan := make(chan webhook.WebhookPayload, 1)
 	// create worker with minimal dependencies; clientset and taskAllocator not needed for this test
-	w := NewWorker(q, nil, webhookChan, fdb, nil, nil, 10*time.Millisecond)
+	w := NewWorker(q, nil, webhookChan, fdb, nil, nil, nil, 10*time.Millisecond)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -195,3 +273,120 @@ func TestWorkerProcessesRunningPodAndWritesDB(t *testing.T) {
 	require.Equal(t, 123, fdb.lastTaskRunID)
 	require.Equal(t, v1.PodRunning, fdb.lastPhase)
 }
+
+func TestTaskPhase_JudgesTaskContainerOnly(t *testing.T) {
+	w := &worker{}
+	always := v1.ContainerRestartPolicyAlways
+
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "proxy", RestartPolicy: &always}},
+			Containers:     []v1.Container{{Name: "task"}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodFailed,
+			InitContainerStatuses: []v1.ContainerStatus{
+				{Name: "proxy", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 143}}},
+			},
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "task", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}}},
+			},
+		},
+	}
+
+	// a sidecar exiting with an error as it is stopped does not fail the task
+	require.Equal(t, v1.PodSucceeded, taskPhase(pod))
+
+	pod.Status.ContainerStatuses[0].State.Terminated.ExitCode = 2
+	require.Equal(t, v1.PodFailed, taskPhase(pod))
+	require.Equal(t, int32(2), w.getExitCode(pod, 1))
+
+	// an init container failing means the task container never ran
+	pod.Status.ContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "PodInitializing"}}
+	require.Equal(t, v1.PodFailed, taskPhase(pod))
+	require.Equal(t, int32(-1), w.getExitCode(pod, 1))
+}
+
+// retryEnvDB keeps the retry env saved for each task run and hands out instances of a map task
+type retryEnvDB struct {
+	fakeDB
+	mu        sync.Mutex
+	envs      map[int]string
+	finalized chan struct{}
+}
+
+func (f *retryEnvDB) RetryTaskRun(ctx context.Context, taskRunId int) (int, time.Duration, error) {
+	return taskRunId + 10, 0, nil
+}
+func (f *retryEnvDB) SaveRetryEnv(ctx context.Context, taskRunId int, envJSON string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.envs[taskRunId] = envJSON
+	return nil
+}
+func (f *retryEnvDB) ClaimTaskByID(ctx context.Context, taskRunId int, workerId string, leaseTTL time.Duration) (db.TaskClaim, error) {
+	index := 1
+	return db.TaskClaim{TaskRunID: taskRunId, TaskID: 2, RunID: 3, MapIndex: &index, MapItem: "web-2"}, nil
+}
+func (f *retryEnvDB) FinalizeClaimToRunning(ctx context.Context, taskRunId int, workerId string, podUID string) error {
+	close(f.finalized)
+	return nil
+}
+func (f *retryEnvDB) GetTaskForRun(ctx context.Context, runId, dagTaskId, taskRunId int) (db.Task, string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return db.Task{
+		Id:         dagTaskId,
+		Name:       "deploy",
+		Image:      "alpine:latest",
+		Command:    []string{"echo"},
+		Parameters: []db.Parameter{{Name: "token", IsSecret: true, Key: "api-token", Value: "creds"}},
+	}, "default", f.envs[taskRunId], nil
+}
+
+// envAllocator hands the envs a retried task run is started with to the test
+type envAllocator struct {
+	fakeAllocator
+	envs chan []v1.EnvVar
+}
+
+func (f *envAllocator) AllocateTaskWithEnv(ctx context.Context, task *db.Task, dagRunId, taskRunId int, namespace string, envs []v1.EnvVar, resources *v1.ResourceRequirements, claimedBy string) (types.UID, error) {
+	f.envs <- envs
+	return types.UID("retry-pod-uid"), nil
+}
+
+func TestRetryFailedTask_MapInstanceKeepsEnv(t *testing.T) {
+	fdb := &retryEnvDB{envs: map[int]string{}, finalized: make(chan struct{})}
+	alloc := &envAllocator{envs: make(chan []v1.EnvVar, 1)}
+	w := NewWorker(queue.NewMemoryQueue(context.Background()), nil, make(chan webhook.WebhookPayload, 1), fdb, nil, alloc, nil, 10*time.Millisecond).(*worker)
+
+	// the failed pod ran the second instance with a secret read from a key other than the default
+	index := 1
+	envs := NewTaskAllocator(nil, "id", nil).CreateEnvs(&db.Task{
+		Parameters: []db.Parameter{{Name: "token", IsSecret: true, Key: "api-token", Value: "creds"}},
+		MapIndex:   &index,
+		MapItem:    "web-2",
+	})
+	pod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "task", Env: *envs}}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.retryFailedTask(ctx, pod, 3, 1, 1)
+
+	select {
+	case got := <-alloc.envs:
+		require.Equal(t, []v1.EnvVar{
+			{Name: "token", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "creds"}, Key: "api-token"}}},
+			{Name: envMapItem, Value: "web-2"},
+			{Name: envMapIndex, Value: "1"},
+		}, got)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the retry to be allocated with the env of the failed instance")
+	}
+
+	select {
+	case <-fdb.finalized:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the retry to be running")
+	}
+}
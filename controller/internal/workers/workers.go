@@ -15,6 +15,7 @@ import (
 	"kontroler-controller/internal/metrics"
 	"kontroler-controller/internal/object"
 	"kontroler-controller/internal/queue"
+	"kontroler-controller/internal/sensors"
 	"kontroler-controller/internal/webhook"
 	"kontroler-controller/internal/workers/container"
 
@@ -50,6 +51,7 @@ type worker struct {
 	taskAllocator   TaskAllocator
 	logStore        object.LogStore
 	webhookNotifier webhook.WebhookNotifier
+	sensorClients   *sensors.Clients
 	id              string
 	pollDuration    time.Duration
 	inFlight        int32
@@ -57,7 +59,7 @@ type worker struct {
 
 func NewWorker(queue queue.Queue, logStore object.LogStore, webhookChan chan webhook.WebhookPayload,
 	dbManager db.DBDAGManager, clientSet *kubernetes.Clientset, taskAllocator TaskAllocator,
-	pollDuration time.Duration) Worker[*v1.Pod] {
+	sensorClients *sensors.Clients, pollDuration time.Duration) Worker[*v1.Pod] {
 	return &worker{
 		queue:           queue,
 		logStore:        logStore,
@@ -65,6 +67,7 @@ func NewWorker(queue queue.Queue, logStore object.LogStore, webhookChan chan web
 		dbManager:       dbManager,
 		clientSet:       clientSet,
 		taskAllocator:   taskAllocator,
+		sensorClients:   sensorClients,
 		id:              uuid.NewString(),
 		pollDuration:    pollDuration,
 	}
@@ -151,17 +154,18 @@ func (w *worker) processClaim(ctx context.Context, c db.TaskClaim) {
 	// lease renew context
 	renewCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	leaseTTL := defaultLeaseTTL
 
 	// start lease renew goroutine
 	go func() {
-		ticker := time.NewTicker(defaultLeaseTTL / 2)
+		ticker := time.NewTicker(leaseTTL / 2)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-renewCtx.Done():
 				return
 			case <-ticker.C:
-				if err := w.dbManager.RenewLease(renewCtx, c.TaskRunID, w.id, defaultLeaseTTL); err != nil {
+				if err := w.dbManager.RenewLease(renewCtx, c.TaskRunID, w.id, leaseTTL); err != nil {
 					log.Log.Error(err, "failed to renew lease", "taskRunId", c.TaskRunID)
 					metrics.RecordLeaseRenew(w.id, "error")
 					// abort allocation/finalization on lease renewal failure
@@ -176,30 +180,51 @@ func (w *worker) processClaim(ctx context.Context, c db.TaskClaim) {
 	}()
 
 	// fetch task details and namespace plus retry env
-	task, namespace, retryEnv, err := w.dbManager.GetTaskForRun(ctx, c.RunID, c.TaskID)
+	task, namespace, retryEnv, err := w.dbManager.GetTaskForRun(ctx, c.RunID, c.TaskID, c.TaskRunID)
 	if err != nil {
 		log.Log.Error(err, "failed to get task for run", "runId", c.RunID, "taskId", c.TaskID)
 		return
 	}
 
+	// map task instances each run with their own item
+	task.MapIndex = c.MapIndex
+	task.MapItem = c.MapItem
+
+	if err := expandTemplates(&task, c.RunID); err != nil {
+		// the templates expand the same way on every claim, so the task run cannot start at all
+		log.Log.Error(err, "failed to expand task templates", "runId", c.RunID, "taskId", c.TaskID)
+		w.failTaskRun(ctx, &task, namespace, c, time.Now())
+		return
+	}
+
+	// sensors are checked once per claim, a condition that does not hold yet re-queues the task run
+	if task.Sensor != nil {
+		w.runSensor(ctx, renewCtx, &task, namespace, c)
+		return
+	}
+
+	// cached tasks reuse the result of an earlier run with the same inputs instead of starting a pod,
+	// artifacts are not part of those inputs so tasks passing them always run
+	if task.CacheTTL > 0 && len(task.Artifacts) == 0 && len(task.UpstreamArtifacts) == 0 && w.completeFromCache(ctx, &task, namespace, c) {
+		return
+	}
+
 	// Prepare envs: if retryEnv provided, use it; otherwise let allocator create envs
 	var podUID types.UID
 	if retryEnv != "" {
 		// parse retryEnv JSON into []v1.EnvVar to preserve ValueFrom fields
 		var envs []v1.EnvVar
-		if err := json.Unmarshal([]byte(retryEnv), &envs); err != nil {
-			log.Log.Error(err, "failed to parse retry env JSON", "taskRunId", c.TaskRunID)
+		if jsonErr := json.Unmarshal([]byte(retryEnv), &envs); jsonErr != nil {
+			log.Log.Error(jsonErr, "failed to parse retry env JSON", "taskRunId", c.TaskRunID)
 			// fall back to normal allocation
 			podUID, err = w.taskAllocator.AllocateTask(ctx, &task, c.RunID, c.TaskRunID, namespace, w.id)
 			if err != nil {
 				log.Log.Error(err, "failed to allocate pod for claimed task", "taskRunId", c.TaskRunID)
-				return
 			}
 		} else {
 			podUID, err = w.taskAllocator.AllocateTaskWithEnv(ctx, &task, c.RunID, c.TaskRunID, namespace, envs, nil, w.id)
 			if err != nil {
 				log.Log.Error(err, "failed to allocate pod for claimed task with retry env", "taskRunId", c.TaskRunID)
-				return
 			}
 		}
 	} else {
@@ -207,11 +232,18 @@ func (w *worker) processClaim(ctx context.Context, c db.TaskClaim) {
 		podUID, err = w.taskAllocator.AllocateTask(ctx, &task, c.RunID, c.TaskRunID, namespace, w.id)
 		if err != nil {
 			log.Log.Error(err, "failed to allocate pod for claimed task", "taskRunId", c.TaskRunID)
-			// leave claim to expire or be recovered
-			return
 		}
 	}
 
+	if err != nil {
+		if errors.Is(err, ErrInvalidPodSpec) {
+			// the task run cannot start at all, claiming it again would fail the same way
+			w.failTaskRun(ctx, &task, namespace, c, time.Now())
+		}
+		// otherwise leave claim to expire or be recovered
+		return
+	}
+
 	// finalize claim: set status to running
 	if err := w.dbManager.FinalizeClaimToRunning(ctx, c.TaskRunID, w.id, string(podUID)); err != nil {
 		log.Log.Error(err, "failed to finalize claim to running", "taskRunId", c.TaskRunID)
@@ -229,6 +261,93 @@ func (w *worker) processClaim(ctx context.Context, c db.TaskClaim) {
 	log.Log.Info("claim finalized and pod created", "taskRunId", c.TaskRunID, "podUID", podUID)
 }
 
+// failTaskRun fails a claimed task run that cannot succeed, without a pod, and lets its downstream
+// tasks react the same way they would to a failed pod. A task run no longer claimed by this worker is left as it is
+func (w *worker) failTaskRun(ctx context.Context, task *db.Task, namespace string, c db.TaskClaim, started time.Time) {
+	if err := w.dbManager.FinalizeClaimToRunning(ctx, c.TaskRunID, w.id, ""); err != nil {
+		log.Log.Error(err, "failed to finalize claim of failed task", "taskRunId", c.TaskRunID)
+		return
+	}
+
+	if err := w.dbManager.MarkTaskAsFailed(ctx, c.TaskRunID); err != nil {
+		log.Log.Error(err, "failed to mark task as failed", "taskRunId", c.TaskRunID)
+	}
+
+	dagName, taskName, metricsNamespace := w.getTaskRunMetricsInfo(ctx, c.TaskRunID)
+	metrics.RecordTaskOutcome(metricsNamespace, dagName, taskName, "failed")
+	metrics.RecordTaskExecutionDuration(metricsNamespace, dagName, taskName, "failed", time.Since(started).Seconds())
+
+	if w.handleFailedTaskRunDownstream(ctx, task.Name, c.TaskRunID, c.RunID) {
+		w.deleteTaskWorkspace(ctx, task, namespace, c.RunID)
+	}
+}
+
+// completeFromCache finishes a claimed task run with a cached result of its task and reports whether
+// there was one. When the lookup fails the task runs as it would without a cache
+func (w *worker) completeFromCache(ctx context.Context, task *db.Task, namespace string, c db.TaskClaim) bool {
+	key, err := cacheKey(task)
+	if err != nil {
+		log.Log.Error(err, "failed to compute task cache key", "taskRunId", c.TaskRunID)
+		return false
+	}
+
+	cached, tasks, err := w.dbManager.CompleteTaskRunFromCache(ctx, c.TaskRunID, w.id, key)
+	if errors.Is(err, db.ErrTaskRunNotClaimed) {
+		// the task run timed out or another worker claimed it, its outcome is no longer ours to record
+		log.Log.Info("task run no longer claimed, leaving it", "taskRunId", c.TaskRunID)
+		return true
+	}
+	if err != nil {
+		log.Log.Error(err, "failed to look up cached task result", "taskRunId", c.TaskRunID)
+		return false
+	}
+
+	if !cached {
+		return false
+	}
+
+	log.Log.Info("task result reused from cache", "taskRunId", c.TaskRunID, "runId", c.RunID, "taskName", task.Name)
+
+	dagName, taskName, metricsNamespace := w.getTaskRunMetricsInfo(ctx, c.TaskRunID)
+	metrics.RecordTaskOutcome(metricsNamespace, dagName, taskName, "cached")
+
+	webhook, err := w.dbManager.GetWebhookDetails(ctx, c.RunID)
+	if err != nil {
+		log.Log.Error(err, errMsgWebhookDetails, "runId", c.RunID)
+	} else if webhook.URL != "" {
+		go w.webhookNotifier.NotifyTaskRun(task.Name, "cached", c.RunID, c.TaskRunID, webhook.URL, webhook.VerifySSL)
+	}
+
+	if len(tasks) > 0 {
+		w.allocateNextTasks(ctx, c.RunID, tasks)
+		return true
+	}
+
+	complete, err := w.checkIfDagRunIsComplete(ctx, c.RunID)
+	if err != nil || !complete {
+		return true
+	}
+
+	w.deleteTaskWorkspace(ctx, task, namespace, c.RunID)
+	return true
+}
+
+// deleteTaskWorkspace deletes the workspace of a finished run for tasks that completed without a pod,
+// through the workspace volume added to the task when it was read
+func (w *worker) deleteTaskWorkspace(ctx context.Context, task *db.Task, namespace string, runId int) {
+	if task.PodTemplate == nil {
+		return
+	}
+
+	for _, volume := range task.PodTemplate.Volumes {
+		if volume.Name == "workspace" && volume.PersistentVolumeClaim != nil {
+			if err := w.deleteWorkspacePVC(ctx, namespace, volume.PersistentVolumeClaim.ClaimName); err != nil {
+				log.Log.Error(err, "failed to delete PVC", "namespace", namespace, "dagRunId", runId)
+			}
+		}
+	}
+}
+
 func (w *worker) Run(ctx context.Context) error {
 	log.Log.Info("worker started")
 
@@ -294,6 +413,12 @@ func (w *worker) handleOutcome(ctx context.Context, pod *v1.Pod, event string, e
 
 	w.handleLogCollection(ctx, pod)
 
+	// sidecars can exit with an error as they are stopped, only the task container decides the outcome
+	if phase := taskPhase(pod); phase != pod.Status.Phase {
+		pod = pod.DeepCopy()
+		pod.Status.Phase = phase
+	}
+
 	writeState := true
 
 	switch pod.Status.Phase {
@@ -322,6 +447,10 @@ func (w *worker) handleOutcome(ctx context.Context, pod *v1.Pod, event string, e
 func (w *worker) handleSuccessfulTaskRun(ctx context.Context, pod *v1.Pod, taskRunId int) {
 	log.Log.Info("task succeeded", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId)
 
+	if w.wasStopped(ctx, pod, taskRunId) {
+		return
+	}
+
 	w.recordSuccessMetrics(ctx, pod, taskRunId)
 
 	dagRunId, err := w.getDagRunID(pod)
@@ -335,6 +464,8 @@ func (w *worker) handleSuccessfulTaskRun(ctx context.Context, pod *v1.Pod, taskR
 		return
 	}
 
+	w.saveTaskOutputs(ctx, pod, taskRunId)
+
 	tasks, err := w.dbManager.MarkSuccessAndGetNextTasks(ctx, taskRunId)
 	if err != nil {
 		log.Log.Error(err, "failed to mark outcome and get next task", "podUID", pod.UID, "name", pod.Name, "event", "add/update")
@@ -346,6 +477,24 @@ func (w *worker) handleSuccessfulTaskRun(ctx context.Context, pod *v1.Pod, taskR
 	w.processNextTasks(ctx, pod, dagRunId, tasks)
 }
 
+// saveTaskOutputs stores the outputs the task container published, if the task declared any
+func (w *worker) saveTaskOutputs(ctx context.Context, pod *v1.Pod, taskRunId int) {
+	declared, ok := pod.Annotations[annotationOutputs]
+	if !ok || declared == "" {
+		return
+	}
+
+	var message string
+	if status := taskContainerStatus(pod); status != nil && status.State.Terminated != nil {
+		message = status.State.Terminated.Message
+	}
+
+	outputs := parseTaskOutputs(message, strings.Split(declared, ","))
+	if err := w.dbManager.SaveTaskOutputs(ctx, taskRunId, outputs); err != nil {
+		log.Log.Error(err, "failed to save task outputs", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId)
+	}
+}
+
 func (w *worker) recordSuccessMetrics(ctx context.Context, pod *v1.Pod, taskRunId int) {
 	// Get DAG and task names for metrics
 	dagName, taskName, namespace := w.getTaskRunMetricsInfo(ctx, taskRunId)
@@ -354,8 +503,7 @@ func (w *worker) recordSuccessMetrics(ctx context.Context, pod *v1.Pod, taskRunI
 	metrics.RecordTaskOutcome(namespace, dagName, taskName, "success")
 
 	// Record task execution duration if available
-	if len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].State.Terminated != nil {
-		terminated := pod.Status.ContainerStatuses[0].State.Terminated
+	if terminated := taskContainerTerminated(pod); terminated != nil {
 		duration := terminated.FinishedAt.Sub(terminated.StartedAt.Time).Seconds()
 		metrics.RecordTaskExecutionDuration(namespace, dagName, taskName, "success", duration)
 	}
@@ -378,7 +526,7 @@ func (w *worker) processNextTasks(ctx context.Context, pod *v1.Pod, dagRunId int
 		return
 	}
 
-	w.allocateNextTasks(ctx, pod, dagRunId, tasks)
+	w.allocateNextTasks(ctx, dagRunId, tasks)
 }
 
 func (w *worker) handleDagRunCompletion(ctx context.Context, pod *v1.Pod, dagRunId int) {
@@ -397,7 +545,7 @@ func (w *worker) handleDagRunCompletion(ctx context.Context, pod *v1.Pod, dagRun
 	}
 }
 
-func (w *worker) allocateNextTasks(ctx context.Context, pod *v1.Pod, dagRunId int, tasks []db.Task) {
+func (w *worker) allocateNextTasks(ctx context.Context, dagRunId int, tasks []db.Task) {
 	for _, task := range tasks {
 		// create pending task run for workers to claim
 		taskRunId, err := w.dbManager.AddPendingTaskRun(ctx, dagRunId, task.Id)
@@ -411,6 +559,11 @@ func (w *worker) allocateNextTasks(ctx context.Context, pod *v1.Pod, dagRunId in
 }
 
 func (t *worker) handleFailedTaskRun(ctx context.Context, pod *v1.Pod, taskRunId int) {
+	// pods of stopped tasks are deleted by the controller, which has already recorded the outcome
+	if t.wasStopped(ctx, pod, taskRunId) {
+		return
+	}
+
 	// Use computePodDurationAndExit to safely obtain exit code without
 	// dereferencing Terminated when it may be nil.
 	_, _, exitPtr := t.computePodDurationAndExit(pod, nil)
@@ -451,18 +604,64 @@ func (t *worker) handleFailedTaskRun(ctx context.Context, pod *v1.Pod, taskRunId
 	t.retryFailedTask(ctx, pod, dagRunId, taskRunId, exitcode)
 }
 
+// wasStopped reports whether the controller stopped the task run, because it ran out of time or
+// its DAG run was replaced by a newer one, in which case the outcome of its pod no longer matters
+func (w *worker) wasStopped(ctx context.Context, pod *v1.Pod, taskRunId int) bool {
+	status, err := w.dbManager.GetTaskRunStatus(ctx, taskRunId)
+	if err != nil {
+		log.Log.Error(err, "failed to get task run status", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId)
+		return false
+	}
+
+	if status != "timed_out" && status != "suspended" {
+		return false
+	}
+
+	log.Log.Info("task run has been stopped, ignoring pod outcome", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId, "status", status)
+	return true
+}
+
 func (t *worker) getExitCode(pod *v1.Pod, taskRunId int) int32 {
 	// mark exitcode as -1 if pod was deleted before it started - means something odd happened
+	// the same goes for a pod whose init containers failed before the task container ran
 	var exitcode int32 = -1
-	if len(pod.Status.ContainerStatuses) == 0 {
+	if terminated := taskContainerTerminated(pod); terminated == nil {
 		log.Log.Info("task failed without container status", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId)
 	} else {
-		log.Log.Info("task failed", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId, "exitcode", pod.Status.ContainerStatuses[0].State.Terminated.ExitCode)
-		exitcode = pod.Status.ContainerStatuses[0].State.Terminated.ExitCode
+		log.Log.Info("task failed", "podUID", pod.UID, "name", pod.Name, "taskRunId", taskRunId, "exitcode", terminated.ExitCode)
+		exitcode = terminated.ExitCode
 	}
 	return exitcode
 }
 
+// taskContainerTerminated returns the terminated state of the task container, nil when it has not terminated.
+// Init containers and sidecars are never looked at.
+func taskContainerTerminated(pod *v1.Pod) *v1.ContainerStateTerminated {
+	status := taskContainerStatus(pod)
+	if status == nil {
+		return nil
+	}
+
+	return status.State.Terminated
+}
+
+// taskPhase returns the phase of a finished pod as judged by its task container alone
+func taskPhase(pod *v1.Pod) v1.PodPhase {
+	if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+		return pod.Status.Phase
+	}
+
+	terminated := taskContainerTerminated(pod)
+	if terminated == nil {
+		return pod.Status.Phase
+	}
+
+	if terminated.ExitCode == 0 {
+		return v1.PodSucceeded
+	}
+	return v1.PodFailed
+}
+
 func (t *worker) recordFailureMetrics(ctx context.Context, pod *v1.Pod, taskRunId int) {
 	// Get DAG and task names for metrics
 	dagName, taskName, namespace := t.getTaskRunMetricsInfo(ctx, taskRunId)
@@ -471,8 +670,7 @@ func (t *worker) recordFailureMetrics(ctx context.Context, pod *v1.Pod, taskRunI
 	metrics.RecordTaskOutcome(namespace, dagName, taskName, "failed")
 
 	// Record task execution duration if available
-	if len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].State.Terminated != nil {
-		terminated := pod.Status.ContainerStatuses[0].State.Terminated
+	if terminated := taskContainerTerminated(pod); terminated != nil {
 		duration := terminated.FinishedAt.Sub(terminated.StartedAt.Time).Seconds()
 		metrics.RecordTaskExecutionDuration(namespace, dagName, taskName, "failed", duration)
 	}
@@ -486,28 +684,24 @@ func (t *worker) retryFailedTask(ctx context.Context, pod *v1.Pod, dagRunId, tas
 	metrics.RecordTaskRetry(namespace, dagName, taskName, fmt.Sprintf("exit_code_%d", exitcode))
 
 	// Create a new pending task run and save retry env
-	taskId, err := t.getTaskIdFromPod(pod)
-	if err != nil {
-		return
-	}
-
-	newTaskRunId, err := t.dbManager.AddPendingTaskRun(ctx, dagRunId, taskId)
+	newTaskRunId, delay, err := t.dbManager.RetryTaskRun(ctx, taskRunId)
 	if err != nil {
 		log.Log.Error(err, "failed to create pending task run for retry")
 		return
 	}
 
-	// Save retry env (serialize container env to JSON)
-	container := pod.Spec.Containers[0]
-	envs := make([]map[string]string, 0, len(container.Env))
-	for _, e := range container.Env {
-		envs = append(envs, map[string]string{"name": e.Name, "value": e.Value})
-	}
-	b, _ := json.Marshal(envs)
+	// Save retry env (serialize container env to JSON), keeping the secret and ConfigMap references of parameters
+	b, _ := json.Marshal(pod.Spec.Containers[0].Env)
 	if err := t.dbManager.SaveRetryEnv(ctx, newTaskRunId, string(b)); err != nil {
 		log.Log.Error(err, "failed to save retry env")
 	}
 
+	// A delayed retry is left for the claim loop to pick up once its scheduled start has passed
+	if delay > 0 {
+		log.Log.Info("retry task scheduled", "newTaskRunId", newTaskRunId, "delay", delay)
+		return
+	}
+
 	// Claim the new task immediately
 	claim, err := t.dbManager.ClaimTaskByID(ctx, newTaskRunId, t.id, defaultLeaseTTL)
 	if err != nil {
@@ -518,10 +712,6 @@ func (t *worker) retryFailedTask(ctx context.Context, pod *v1.Pod, dagRunId, tas
 	// Process the claimed task (this will allocate pod and finalize)
 	go t.processClaim(ctx, claim)
 
-	if err := t.dbManager.IncrementAttempts(ctx, newTaskRunId); err != nil {
-		log.Log.Error(err, "failed to increment attempts", "taskRunId", newTaskRunId)
-	}
-
 	log.Log.Info("retry task created and claimed", "newTaskRunId", newTaskRunId)
 }
 
@@ -578,8 +768,7 @@ func (w *worker) computePodDurationAndExit(pod *v1.Pod, eventStamp *time.Time) (
 	var dur int64 = 0
 	var exit *int32 = nil
 
-	if len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].State.Terminated != nil {
-		terminated := pod.Status.ContainerStatuses[0].State.Terminated
+	if terminated := taskContainerTerminated(pod); terminated != nil {
 		exit = &terminated.ExitCode
 
 		// Prefer the explicit termination times if present
@@ -708,11 +897,23 @@ func (w *worker) handleUnretryablePod(ctx context.Context, pod *v1.Pod, taskRunI
 	// Record metrics for unretryable failure
 	metrics.RecordTaskOutcome(namespace, dagName, taskName, "unretryable")
 
+	if !w.handleFailedTaskRunDownstream(ctx, pod.Spec.Containers[0].Name, taskRunId, dagRunId) {
+		return
+	}
+
+	if err := w.deletePVC(ctx, pod); err != nil {
+		log.Log.Error(err, "failed to delete PVC", "pod", pod.Name, "namespace", pod.Namespace, "dagRunId", dagRunId, "status", pod.Status.Phase)
+	}
+}
+
+// handleFailedTaskRunDownstream reports a task run that failed for good, suspends the tasks that can no
+// longer run after it and starts those whose trigger rules now allow them. It returns whether the run is complete
+func (w *worker) handleFailedTaskRunDownstream(ctx context.Context, taskName string, taskRunId, dagRunId int) bool {
 	webhook, err := w.dbManager.GetWebhookDetails(ctx, dagRunId)
 	if err != nil {
 		log.Log.Error(err, errMsgWebhookDetails, "runId", dagRunId)
 	} else if webhook.URL != "" {
-		go w.webhookNotifier.NotifyTaskRun(pod.Spec.Containers[0].Name, "failed", dagRunId, taskRunId, webhook.URL, webhook.VerifySSL)
+		go w.webhookNotifier.NotifyTaskRun(taskName, "failed", dagRunId, taskRunId, webhook.URL, webhook.VerifySSL)
 	}
 
 	taskNames, err := w.dbManager.MarkConnectingTasksAsSuspended(ctx, dagRunId, taskRunId)
@@ -725,22 +926,28 @@ func (w *worker) handleUnretryablePod(ctx context.Context, pod *v1.Pod, taskRunI
 		}
 	} else {
 		log.Log.Error(err, "failed to mark connecting tasks as suspended", "taskRunId", taskRunId)
-		return
+		return false
 	}
 
-	complete, err := w.checkIfDagRunIsComplete(ctx, dagRunId)
+	// tasks with trigger rules such as all_done or one_failed may now be able to run
+	readyTasks, err := w.dbManager.GetReadyTasks(ctx, dagRunId)
 	if err != nil {
-		log.Log.Error(err, "failed to check if dag run is complete", "runId", dagRunId)
-		return
+		log.Log.Error(err, "failed to get ready tasks", "runId", dagRunId)
+		return false
 	}
 
-	if !complete {
-		return
+	if len(readyTasks) > 0 {
+		w.allocateNextTasks(ctx, dagRunId, readyTasks)
+		return false
 	}
 
-	if err := w.deletePVC(ctx, pod); err != nil {
-		log.Log.Error(err, "failed to delete PVC", "pod", pod.Name, "namespace", pod.Namespace, "dagRunId", dagRunId, "status", pod.Status.Phase)
+	complete, err := w.checkIfDagRunIsComplete(ctx, dagRunId)
+	if err != nil {
+		log.Log.Error(err, "failed to check if dag run is complete", "runId", dagRunId)
+		return false
 	}
+
+	return complete
 }
 
 func (t *worker) deletePod(ctx context.Context, pod *v1.Pod, removeFinaliser bool) error {
@@ -837,26 +1044,30 @@ func (t *worker) handleConfigError(ctx context.Context, pod *v1.Pod, taskRunId,
 func (t *worker) deletePVC(ctx context.Context, pod *v1.Pod) error {
 	for _, volumes := range pod.Spec.Volumes {
 		if volumes.PersistentVolumeClaim != nil && volumes.Name == "workspace" {
-			// Fetch the PVC
-			pvc, err := t.clientSet.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(ctx, volumes.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
-			if err != nil {
-				return err
-			}
+			return t.deleteWorkspacePVC(ctx, pod.Namespace, volumes.PersistentVolumeClaim.ClaimName)
+		}
+	}
 
-			// Remove finalizers
-			pvc.Finalizers = []string{}
+	return nil
+}
 
-			// Update the PVC
-			_, err = t.clientSet.CoreV1().PersistentVolumeClaims(pod.Namespace).Update(ctx, pvc, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
+func (t *worker) deleteWorkspacePVC(ctx context.Context, namespace, claimName string) error {
+	// Fetch the PVC
+	pvc, err := t.clientSet.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
 
-			return t.clientSet.CoreV1().PersistentVolumeClaims(pod.Namespace).Delete(ctx, volumes.PersistentVolumeClaim.ClaimName, metav1.DeleteOptions{})
-		}
+	// Remove finalizers
+	pvc.Finalizers = []string{}
+
+	// Update the PVC
+	_, err = t.clientSet.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, pvc, metav1.UpdateOptions{})
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return t.clientSet.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, claimName, metav1.DeleteOptions{})
 }
 
 func (t *worker) checkIfDagRunIsComplete(ctx context.Context, runId int) (bool, error) {
@@ -0,0 +1,309 @@
+package conditions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Expression is a parsed `when` condition, e.g.
+//
+//	params.env == "prod" && tasks.extract.outputs.rowCount > 0
+type Expression struct {
+	Or []*AndExpr `parser:"@@ ( '||' @@ )*"`
+}
+
+// AndExpr is a chain of conditions that must all hold
+type AndExpr struct {
+	And []*UnaryExpr `parser:"@@ ( '&&' @@ )*"`
+}
+
+// UnaryExpr is either a negated condition or a comparison
+type UnaryExpr struct {
+	Not        *UnaryExpr  `parser:"  '!' @@"`
+	Comparison *Comparison `parser:"| @@"`
+}
+
+// Comparison compares two operands, or tests a single operand for truthiness
+type Comparison struct {
+	Left  *Operand `parser:"@@"`
+	Op    string   `parser:"( @( '==' | '!=' | '<=' | '>=' | '<' | '>' )"`
+	Right *Operand `parser:"  @@ )?"`
+}
+
+// Operand is a literal, a reference into the run or a nested expression
+type Operand struct {
+	String *string     `parser:"  @String"`
+	Number *string     `parser:"| @Number"`
+	Bool   *string     `parser:"| @( 'true' | 'false' )"`
+	Sub    *Expression `parser:"| '(' @@ ')'"`
+	Ref    *Reference  `parser:"| @@"`
+}
+
+// Reference points at a run parameter or the result of another task:
+// params.<name>, tasks.<name>.status or tasks.<name>.outputs.<output>
+type Reference struct {
+	Parts []string `parser:"@Ident ( '.' @Ident )*"`
+}
+
+// TaskResult is what an expression can see of a task that has already finished
+type TaskResult struct {
+	Status  string
+	Outputs map[string]string
+}
+
+// Scope holds the values references are resolved against
+type Scope struct {
+	Params map[string]string
+	Tasks  map[string]TaskResult
+}
+
+var (
+	conditionLexer = lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Whitespace", Pattern: `\s+`},
+		{Name: "String", Pattern: `"(\\.|[^"\\])*"|'[^']*'`},
+		{Name: "Number", Pattern: `-?\d+(\.\d+)?`},
+		{Name: "Ident", Pattern: `[a-zA-Z_][a-zA-Z0-9_-]*`},
+		{Name: "Operator", Pattern: `==|!=|<=|>=|&&|\|\||[<>!().]`},
+	})
+
+	parser = participle.MustBuild[Expression](
+		participle.Lexer(conditionLexer),
+		participle.Elide("Whitespace"),
+		participle.Map(unquote, "String"),
+		participle.UseLookahead(2),
+	)
+)
+
+func unquote(token lexer.Token) (lexer.Token, error) {
+	if strings.HasPrefix(token.Value, "'") {
+		token.Value = token.Value[1 : len(token.Value)-1]
+		return token, nil
+	}
+
+	value, err := strconv.Unquote(token.Value)
+	if err != nil {
+		return token, fmt.Errorf("invalid string %s: %w", token.Value, err)
+	}
+
+	token.Value = value
+	return token, nil
+}
+
+// Parse parses a `when` expression and checks that every reference in it is well formed
+func Parse(input string) (*Expression, error) {
+	expr, err := parser.ParseString("", input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse condition: %w", err)
+	}
+
+	for _, ref := range expr.references() {
+		if err := ref.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return expr, nil
+}
+
+// Evaluate parses and evaluates the expression in one go
+func Evaluate(input string, scope Scope) (bool, error) {
+	expr, err := Parse(input)
+	if err != nil {
+		return false, err
+	}
+
+	return expr.Evaluate(scope), nil
+}
+
+// Params returns the names of the parameters the expression reads
+func (e *Expression) Params() []string {
+	names := []string{}
+	for _, ref := range e.references() {
+		if ref.Parts[0] == "params" {
+			names = append(names, ref.Parts[1])
+		}
+	}
+
+	return names
+}
+
+// Tasks returns the names of the tasks the expression reads
+func (e *Expression) Tasks() []string {
+	names := []string{}
+	for _, ref := range e.references() {
+		if ref.Parts[0] == "tasks" {
+			names = append(names, ref.Parts[1])
+		}
+	}
+
+	return names
+}
+
+// Evaluate reports whether the expression holds for the given scope.
+// References to values that do not exist resolve to an empty string.
+func (e *Expression) Evaluate(scope Scope) bool {
+	for _, and := range e.Or {
+		if and.evaluate(scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *AndExpr) evaluate(scope Scope) bool {
+	for _, unary := range a.And {
+		if !unary.evaluate(scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (u *UnaryExpr) evaluate(scope Scope) bool {
+	if u.Not != nil {
+		return !u.Not.evaluate(scope)
+	}
+
+	return u.Comparison.evaluate(scope)
+}
+
+func (c *Comparison) evaluate(scope Scope) bool {
+	left := c.Left.value(scope)
+	if c.Right == nil {
+		truthy, err := strconv.ParseBool(left)
+		return err == nil && truthy
+	}
+
+	right := c.Right.value(scope)
+
+	switch c.Op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	// Ordering only makes sense for numbers, anything else does not match
+	l, lerr := strconv.ParseFloat(left, 64)
+	r, rerr := strconv.ParseFloat(right, 64)
+	if lerr != nil || rerr != nil {
+		return false
+	}
+
+	switch c.Op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+
+	return false
+}
+
+func equal(left, right string) bool {
+	l, lerr := strconv.ParseFloat(left, 64)
+	r, rerr := strconv.ParseFloat(right, 64)
+	if lerr == nil && rerr == nil {
+		return l == r
+	}
+
+	return left == right
+}
+
+func (o *Operand) value(scope Scope) string {
+	switch {
+	case o.String != nil:
+		return *o.String
+	case o.Number != nil:
+		return *o.Number
+	case o.Bool != nil:
+		return *o.Bool
+	case o.Sub != nil:
+		return strconv.FormatBool(o.Sub.Evaluate(scope))
+	default:
+		return o.Ref.resolve(scope)
+	}
+}
+
+func (r *Reference) validate() error {
+	path := strings.Join(r.Parts, ".")
+
+	switch r.Parts[0] {
+	case "params":
+		if len(r.Parts) == 2 {
+			return nil
+		}
+	case "tasks":
+		if len(r.Parts) == 3 && r.Parts[2] == "status" {
+			return nil
+		}
+
+		if len(r.Parts) == 4 && r.Parts[2] == "outputs" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid reference %q, expected params.<name>, tasks.<name>.status or tasks.<name>.outputs.<output>", path)
+}
+
+func (r *Reference) resolve(scope Scope) string {
+	if r.Parts[0] == "params" {
+		return scope.Params[r.Parts[1]]
+	}
+
+	task, ok := scope.Tasks[r.Parts[1]]
+	if !ok {
+		return ""
+	}
+
+	if r.Parts[2] == "status" {
+		return task.Status
+	}
+
+	return task.Outputs[r.Parts[3]]
+}
+
+func (e *Expression) references() []*Reference {
+	refs := []*Reference{}
+	for _, and := range e.Or {
+		for _, unary := range and.And {
+			refs = append(refs, unary.references()...)
+		}
+	}
+
+	return refs
+}
+
+func (u *UnaryExpr) references() []*Reference {
+	if u.Not != nil {
+		return u.Not.references()
+	}
+
+	refs := u.Comparison.Left.references()
+	if u.Comparison.Right != nil {
+		refs = append(refs, u.Comparison.Right.references()...)
+	}
+
+	return refs
+}
+
+func (o *Operand) references() []*Reference {
+	switch {
+	case o.Sub != nil:
+		return o.Sub.references()
+	case o.Ref != nil:
+		return []*Reference{o.Ref}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package conditions_test
+
+import (
+	"testing"
+
+	"kontroler-controller/pkg/conditions"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	scope := conditions.Scope{
+		Params: map[string]string{
+			"env":   "prod",
+			"debug": "true",
+		},
+		Tasks: map[string]conditions.TaskResult{
+			"extract": {
+				Status:  "success",
+				Outputs: map[string]string{"rowCount": "42"},
+			},
+			"fetch-data": {
+				Status: "skipped",
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: "string equality", expr: `params.env == "prod"`, want: true},
+		{name: "single quoted string", expr: `params.env == 'dev'`, want: false},
+		{name: "inequality", expr: `params.env != "dev"`, want: true},
+		{name: "truthy parameter", expr: `params.debug`, want: true},
+		{name: "negation", expr: `!params.debug`, want: false},
+		{name: "task status", expr: `tasks.extract.status == "success"`, want: true},
+		{name: "hyphenated task name", expr: `tasks.fetch-data.status == "skipped"`, want: true},
+		{name: "numeric output", expr: `tasks.extract.outputs.rowCount > 10`, want: true},
+		{name: "numeric equality", expr: `tasks.extract.outputs.rowCount == 42.0`, want: true},
+		{name: "ordering on strings", expr: `params.env > 1`, want: false},
+		{name: "missing parameter", expr: `params.region == ""`, want: true},
+		{name: "missing task", expr: `tasks.load.status == "success"`, want: false},
+		{name: "and", expr: `params.env == "prod" && tasks.extract.outputs.rowCount >= 43`, want: false},
+		{name: "or", expr: `params.env == "dev" || tasks.extract.status == "success"`, want: true},
+		{name: "grouping", expr: `!(params.env == "dev" || params.debug == false)`, want: true},
+		{name: "boolean literal", expr: `true`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conditions.Evaluate(tt.expr, scope)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{
+		`params.env ==`,
+		`env == "prod"`,
+		`params.env.value == "prod"`,
+		`tasks.extract == "success"`,
+		`tasks.extract.outputs == "1"`,
+		`params.env = "prod"`,
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := conditions.Parse(expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExpression_References(t *testing.T) {
+	expr, err := conditions.Parse(`params.env == "prod" && (tasks.extract.outputs.rowCount > 0 || !tasks.check.status == "failed")`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"env"}, expr.Params())
+	assert.Equal(t, []string{"extract", "check"}, expr.Tasks())
+}
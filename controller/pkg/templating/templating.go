@@ -0,0 +1,115 @@
+package templating
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Kinds of references a template can hold
+const (
+	KindParams = "params"
+	KindRun    = "run"
+	KindTask   = "task"
+)
+
+// Fields of the run and the task a template can reference
+const (
+	RunID          = "id"
+	RunLogicalTime = "logicalTime"
+	TaskName       = "name"
+)
+
+// referenceRegex matches {{ params.<name> }}, {{ run.<field> }} and {{ task.<field> }}. Other uses
+// of double braces, e.g. docker --format '{{.State}}' in a script, are left as they are
+var referenceRegex = regexp.MustCompile(`\{\{\s*(params|run|task)\.([^\s{}]+)\s*\}\}`)
+
+// Reference is a {{ kind.name }} found in a template
+type Reference struct {
+	Kind string
+	Name string
+}
+
+func (r Reference) String() string {
+	return r.Kind + "." + r.Name
+}
+
+// Values holds what the references of a template expand to
+type Values struct {
+	// Literal values of the parameters of the run
+	Params      map[string]string
+	RunID       int
+	LogicalTime time.Time
+	TaskName    string
+}
+
+// References returns the references in s, in the order they appear
+func References(s string) []Reference {
+	refs := []Reference{}
+	for _, match := range referenceRegex.FindAllStringSubmatch(s, -1) {
+		refs = append(refs, Reference{Kind: match[1], Name: match[2]})
+	}
+	return refs
+}
+
+// Check validates the references in s, params holds the parameters a template can read
+func Check(s string, params map[string]bool) error {
+	for _, ref := range References(s) {
+		switch ref.Kind {
+		case KindParams:
+			if !params[ref.Name] {
+				return fmt.Errorf("template references unknown parameter: %s", ref.Name)
+			}
+		case KindRun:
+			if ref.Name != RunID && ref.Name != RunLogicalTime {
+				return fmt.Errorf("template references unknown run field: %s", ref.Name)
+			}
+		case KindTask:
+			if ref.Name != TaskName {
+				return fmt.Errorf("template references unknown task field: %s", ref.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Expand replaces the references in s with their values
+func Expand(s string, values *Values) (string, error) {
+	var err error
+	expanded := referenceRegex.ReplaceAllStringFunc(s, func(match string) string {
+		parts := referenceRegex.FindStringSubmatch(match)
+		ref := Reference{Kind: parts[1], Name: parts[2]}
+
+		value, ok := values.lookup(ref)
+		if !ok && err == nil {
+			err = fmt.Errorf("template references unknown %s", ref)
+		}
+		return value
+	})
+
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+func (v *Values) lookup(ref Reference) (string, bool) {
+	switch ref.Kind {
+	case KindParams:
+		value, ok := v.Params[ref.Name]
+		return value, ok
+	case KindRun:
+		switch ref.Name {
+		case RunID:
+			return strconv.Itoa(v.RunID), true
+		case RunLogicalTime:
+			return v.LogicalTime.UTC().Format(time.RFC3339), true
+		}
+	case KindTask:
+		if ref.Name == TaskName {
+			return v.TaskName, true
+		}
+	}
+	return "", false
+}
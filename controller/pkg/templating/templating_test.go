@@ -0,0 +1,48 @@
+package templating_test
+
+import (
+	"testing"
+	"time"
+
+	"kontroler-controller/pkg/templating"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	values := &templating.Values{
+		Params:      map[string]string{"region": "eu-west-1"},
+		RunID:       42,
+		LogicalTime: time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC),
+		TaskName:    "extract",
+	}
+
+	got, err := templating.Expand(`aws s3 cp s3://data-{{ params.region }}/{{run.logicalTime}} /tmp/{{ task.name }}-{{ run.id }}`, values)
+	require.NoError(t, err)
+	assert.Equal(t, "aws s3 cp s3://data-eu-west-1/2026-03-01T06:00:00Z /tmp/extract-42", got)
+
+	// other uses of double braces are left alone
+	got, err = templating.Expand(`docker inspect --format '{{.State.Status}}' {{ params.region }}`, values)
+	require.NoError(t, err)
+	assert.Equal(t, `docker inspect --format '{{.State.Status}}' eu-west-1`, got)
+
+	_, err = templating.Expand(`{{ params.missing }}`, values)
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	params := map[string]bool{"region": true}
+
+	assert.NoError(t, templating.Check(`{{ params.region }} {{ run.id }} {{ run.logicalTime }} {{ task.name }}`, params))
+	assert.Error(t, templating.Check(`{{ params.zone }}`, params))
+	assert.Error(t, templating.Check(`{{ run.name }}`, params))
+	assert.Error(t, templating.Check(`{{ task.image }}`, params))
+}
+
+func TestReferences(t *testing.T) {
+	assert.Equal(t, []templating.Reference{
+		{Kind: templating.KindParams, Name: "region"},
+		{Kind: templating.KindRun, Name: "id"},
+	}, templating.References(`{{params.region}}-{{ run.id }}`))
+}
@@ -11,7 +11,9 @@ def update_helm_crds():
     crd_files = {
         "kontroler.greedykomodo_dags.yaml": "dags.yaml",
         "kontroler.greedykomodo_dagruns.yaml": "dagrun.yaml",
-        "kontroler.greedykomodo_dagtasks.yaml": "dagtasks.yaml"
+        "kontroler.greedykomodo_dagtasks.yaml": "dagtasks.yaml",
+        "kontroler.greedykomodo_pools.yaml": "pools.yaml",
+        "kontroler.greedykomodo_triggers.yaml": "triggers.yaml"
     }
     
     # Create helm crds directory if it doesn't exist